@@ -2,15 +2,20 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"encoding/json"
 	gocontext "context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/cgast/agsh/internal/approval"
+	"github.com/cgast/agsh/internal/config"
+	"github.com/cgast/agsh/pkg/agsh"
 	agshctx "github.com/cgast/agsh/pkg/context"
 	"github.com/cgast/agsh/pkg/events"
 	"github.com/cgast/agsh/pkg/platform"
@@ -23,22 +28,47 @@ import (
 type agentState struct {
 	mu          sync.Mutex
 	loadedSpec  *spec.ProjectSpec
+	loadedPath  string
 	pendingPlan *spec.ExecutionPlan
 	planID      string
+	planHash    string // spec.PlanHash of pendingPlan when it was generated
+	tally       *approval.Tally
+	approver    string // who approvals over the connection count as
+	exec        *executionTracker
+	idempotency *idempotencyCache
+	results     *protocol.ResultStore // truncated payloads for result.fetch
+	limits      spec.Limits // run limits for specs without their own
+	loadOpts    []spec.LoadOption
 }
 
+// errPlanRunning is returned when a plan is approved while another executes.
+var errPlanRunning = errors.New("another plan is already executing")
+
 // runAgentMode starts the JSON-RPC agent mode loop on stdin/stdout.
-func runAgentMode(registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus) {
+func runAgentMode(registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cfg config.Config, cpMgr verify.CheckpointManager) {
 	handler := protocol.NewHandler()
-	state := &agentState{}
+	state := &agentState{
+		approver:    agentApprover(),
+		exec:        newExecutionTracker(llmBudget()),
+		idempotency: newIdempotencyCache(time.Duration(cfg.Agent.IdempotencyWindow) * time.Second),
+		results:     protocol.NewResultStore(maxResultSize(cfg.Agent), 0),
+		limits:      configLimits(cfg.Executor),
+		loadOpts:    specLoadOptions(cfg.Sandbox),
+	}
 
-	// Set up checkpoint manager.
-	cpDir := filepath.Join(os.TempDir(), "agsh-agent-checkpoints")
-	cpMgr, _ := verify.NewFileCheckpointManager(cpDir)
+	out := newRPCWriter(json.NewEncoder(os.Stdout))
+	subs := newEventSubscriptions(bus, out)
+	defer subs.closeAll()
+	watches := newContextWatches(store, out)
+	defer watches.closeAll()
 
 	// Register all methods.
-	registerCoreMethods(handler, registry, store, bus, cpMgr)
+	registerCoreMethods(handler, registry, store, bus, state, cpMgr)
 	registerProjectMethods(handler, registry, store, bus, state, cpMgr)
+	registerEventMethods(handler, subs)
+	registerWatchMethods(handler, watches)
+	registerStreamMethods(handler, registry, store, bus, cpMgr, out)
+	registerStatusMethods(handler, state)
 
 	// Emit agent start event.
 	bus.Publish(events.NewEvent(events.EventAgentMessage, map[string]any{
@@ -50,30 +80,39 @@ func runAgentMode(registry *platform.Registry, store agshctx.ContextStore, bus *
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024) // 1MB max line
 
-	encoder := json.NewEncoder(os.Stdout)
-
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
 
-		resp := handler.HandleRaw([]byte(line))
-		if err := encoder.Encode(resp); err != nil {
-			fmt.Fprintf(os.Stderr, "error encoding response: %v\n", err)
-		}
+		// Requests run concurrently so a slow execute does not block
+		// status queries or $/cancelRequest; responses are written as
+		// each one completes.
+		handler.Dispatch([]byte(line), func(resp any) {
+			if err := out.Write(resp); err != nil {
+				fmt.Fprintf(os.Stderr, "error encoding response: %v\n", err)
+			}
+		})
 	}
 
 	if err := scanner.Err(); err != nil {
 		fmt.Fprintf(os.Stderr, "stdin read error: %v\n", err)
 	}
+
+	// Let in-flight requests finish before closing the store.
+	handler.Wait()
 }
 
 // registerCoreMethods registers the base set of JSON-RPC methods.
-func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cpMgr verify.CheckpointManager) {
+func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, state *agentState, cpMgr verify.CheckpointManager) {
 	// commands.list
 	h.Register(protocol.MethodCommandsList, func(params json.RawMessage) (any, *protocol.Error) {
-		cmds := registry.List("")
+		p, err := protocol.ParseParams[protocol.CommandsListParams](params)
+		if err != nil {
+			return nil, err
+		}
+		cmds := registry.List(p.Namespace)
 		infos := make([]protocol.CommandInfo, len(cmds))
 		for i, cmd := range cmds {
 			infos[i] = protocol.CommandInfo{
@@ -82,7 +121,10 @@ func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store
 				Namespace:   cmd.Namespace(),
 			}
 		}
-		return infos, nil
+		if !p.Grouped {
+			return infos, nil
+		}
+		return groupByNamespace(registry, infos), nil
 	})
 
 	// commands.describe
@@ -93,10 +135,18 @@ func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store
 		}
 		cmd, resolveErr := registry.Resolve(p.Name)
 		if resolveErr != nil {
-			return nil, &protocol.Error{Code: protocol.CodeCommandNotFound, Message: resolveErr.Error()}
+			return nil, commandError(protocol.CodeCommandNotFound, p.Name, resolveErr)
 		}
 		inSchema := cmd.InputSchema()
 		outSchema := cmd.OutputSchema()
+		var aliases []protocol.AliasInfo
+		for _, a := range registry.Aliases(cmd.Name()) {
+			aliases = append(aliases, protocol.AliasInfo{Name: a.Name, Deprecated: a.Deprecated, Message: a.Message})
+		}
+		var deprecation string
+		if a, ok := registry.LookupAlias(p.Name); ok && a.Deprecated {
+			deprecation = deprecationMessage(a)
+		}
 		return protocol.CommandDetail{
 			Name:        cmd.Name(),
 			Description: cmd.Description(),
@@ -112,144 +162,90 @@ func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store
 				Required:   outSchema.Required,
 			},
 			Credentials: cmd.RequiredCredentials(),
+			Aliases:     aliases,
+			Deprecation: deprecation,
+			Examples:    convertExamples(platform.Examples(cmd)),
 		}, nil
 	})
 
-	// execute
-	h.Register(protocol.MethodExecute, func(params json.RawMessage) (any, *protocol.Error) {
-		p, err := protocol.ParseParams[protocol.ExecuteParams](params)
+	// commands.search
+	h.Register(protocol.MethodCommandsSearch, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.CommandsSearchParams](params)
 		if err != nil {
 			return nil, err
 		}
-
-		cmd, resolveErr := registry.Resolve(p.Command)
-		if resolveErr != nil {
-			return nil, &protocol.Error{Code: protocol.CodeCommandNotFound, Message: resolveErr.Error()}
+		cmds := registry.Search(p.Query)
+		if p.Limit > 0 && len(cmds) > p.Limit {
+			cmds = cmds[:p.Limit]
 		}
-
-		// Build input envelope from args.
-		input := agshctx.NewEnvelope(p.Args, "application/json", "agent")
-
-		bus.Publish(events.Event{
-			Type:      events.EventCommandStart,
-			Timestamp: time.Now(),
-			Data:      map[string]any{"command": p.Command, "intent": p.Intent},
-		})
-
-		start := time.Now()
-		output, execErr := cmd.Execute(gocontext.Background(), input, store)
-		duration := time.Since(start)
-
-		if execErr != nil {
-			bus.Publish(events.Event{
-				Type:      events.EventCommandError,
-				Timestamp: time.Now(),
-				Data:      map[string]any{"command": p.Command, "error": execErr.Error()},
-				Duration:  duration,
-			})
-			return nil, &protocol.Error{Code: protocol.CodeCommandFailed, Message: execErr.Error()}
+		infos := make([]protocol.CommandInfo, len(cmds))
+		for i, cmd := range cmds {
+			infos[i] = protocol.CommandInfo{
+				Name:        cmd.Name(),
+				Description: cmd.Description(),
+				Namespace:   cmd.Namespace(),
+			}
 		}
+		return infos, nil
+	})
 
-		bus.Publish(events.Event{
-			Type:      events.EventCommandEnd,
-			Timestamp: time.Now(),
-			Data:      map[string]any{"command": p.Command, "status": "ok"},
-			Duration:  duration,
-		})
-
-		result := protocol.ExecuteResult{
-			Payload: output.Payload,
-			Meta: map[string]any{
-				"content_type": output.Meta.ContentType,
-				"source":       output.Meta.Source,
-				"tags":         output.Meta.Tags,
-			},
+	// commands.export_schema
+	h.Register(protocol.MethodCommandsExport, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.CommandsExportParams](params)
+		if err != nil {
+			return nil, err
 		}
-
-		// Run verification if requested.
-		if len(p.Verify) > 0 {
-			intent := assertionDefsToIntent(p.Verify, p.Intent)
-			engine := verify.NewEngine()
-
-			bus.Publish(events.NewEvent(events.EventVerifyStart, map[string]any{
-				"command":    p.Command,
-				"assertions": len(p.Verify),
-			}))
-
-			vResult, _ := engine.Verify(output, intent)
-			result.Verification = &protocol.VerificationInfo{
-				Passed:  vResult.Passed,
-				Results: convertVerifyResults(vResult.Results),
+		cmds := registry.List(p.Namespace)
+		sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name() < cmds[j].Name() })
+
+		switch p.Format {
+		case "", protocol.ExportFormatJSONSchema:
+			return exportJSONSchemas(cmds), nil
+		case protocol.ExportFormatOpenAI:
+			return exportOpenAITools(cmds), nil
+		default:
+			return nil, &protocol.Error{
+				Code:    protocol.CodeInvalidParams,
+				Message: fmt.Sprintf("unknown format %q (want %s or %s)", p.Format, protocol.ExportFormatJSONSchema, protocol.ExportFormatOpenAI),
 			}
-
-			bus.Publish(events.NewEvent(events.EventVerifyResult, map[string]any{
-				"command": p.Command,
-				"passed":  vResult.Passed,
-			}))
 		}
-
-		// Add provenance.
-		for _, step := range output.Provenance {
-			result.Provenance = append(result.Provenance, protocol.ProvenanceStep{
-				Command:  step.Command,
-				Duration: step.Duration.String(),
-				Status:   step.Status,
-			})
-		}
-
-		return result, nil
 	})
 
-	// pipeline
-	h.Register(protocol.MethodPipeline, func(params json.RawMessage) (any, *protocol.Error) {
-		p, err := protocol.ParseParams[protocol.PipelineParams](params)
+	// execute
+	h.RegisterContext(protocol.MethodExecute, func(ctx gocontext.Context, params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ExecuteParams](params)
 		if err != nil {
 			return nil, err
 		}
-
-		steps := make([]agshctx.PipelineStep, len(p.Steps))
-		for i, s := range p.Steps {
-			steps[i] = agshctx.PipelineStep{
-				Command: s.Command,
-				Intent:  s.Intent,
-				OnError: s.OnError,
-			}
+		result, rpcErr, replayed := state.idempotency.do(ctx, protocol.MethodExecute, p.IdempotencyKey, p, func() (any, *protocol.Error) {
+			return executeAgentCommand(ctx, p, registry, store, bus)
+		})
+		if rpcErr != nil {
+			return nil, rpcErr
 		}
-
-		executor := &registryExecutor{registry: registry}
-		publisher := &eventBusPublisher{bus: bus}
-
-		pipeline := &agshctx.Pipeline{
-			Steps:    steps,
-			Context:  store,
-			Executor: executor,
-			Events:   publisher,
+		res := limitExecuteResult(state.results, result.(protocol.ExecuteResult), p)
+		if replayed {
+			res.Provenance = append(append([]protocol.ProvenanceStep(nil), res.Provenance...), dedupProvenance(p.Command))
 		}
+		return res, nil
+	})
 
-		if cpMgr != nil {
-			pipeline.Checkpointer = &checkpointAdapter{
-				manager: cpMgr,
-				store:   store,
-			}
+	// pipeline
+	h.RegisterContext(protocol.MethodPipeline, func(ctx gocontext.Context, params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.PipelineParams](params)
+		if err != nil {
+			return nil, err
 		}
+		return limitOutput(state.results, runAgentPipeline(ctx, p, registry, store, bus, cpMgr, nil), p.MaxResultSize, p.Summarize), nil
+	})
 
-		ctx := gocontext.Background()
-		input := agshctx.NewEnvelope(nil, "text/plain", "agent")
-
-		result, execErr := pipeline.Run(ctx, input)
-		if execErr != nil {
-			return map[string]any{
-				"success": false,
-				"error":   execErr.Error(),
-				"steps":   len(result.Steps),
-			}, nil
+	// result.fetch
+	h.Register(protocol.MethodResultFetch, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ResultFetchParams](params)
+		if err != nil {
+			return nil, err
 		}
-
-		return map[string]any{
-			"success": result.Success,
-			"steps":   len(result.Steps),
-			"output":  result.Output.Payload,
-		}, nil
+		return state.results.Fetch(p)
 	})
 
 	// context.get
@@ -258,6 +254,17 @@ func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store
 		if err != nil {
 			return nil, err
 		}
+		if p.WithVersion {
+			vs, ok := store.(agshctx.VersionedStore)
+			if !ok {
+				return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: "the context store does not version values"}
+			}
+			val, version, getErr := vs.GetVersioned(p.Scope, p.Key)
+			if getErr != nil {
+				return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: getErr.Error()}
+			}
+			return protocol.ContextValue{Value: val, Version: version}, nil
+		}
 		val, getErr := store.Get(p.Scope, p.Key)
 		if getErr != nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: getErr.Error()}
@@ -271,18 +278,112 @@ func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store
 		if err != nil {
 			return nil, err
 		}
-		if setErr := store.Set(p.Scope, p.Key, p.Value); setErr != nil {
+		if p.ExpectedVersion != nil {
+			vs, ok := store.(agshctx.VersionedStore)
+			if !ok {
+				return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: "the context store does not version values"}
+			}
+			version, setErr := vs.CompareAndSet(agentSource(p.Source), p.Scope, p.Key, p.Value, *p.ExpectedVersion)
+			var conflict *agshctx.ConflictError
+			if errors.As(setErr, &conflict) {
+				return nil, conflictError(conflict)
+			}
+			if setErr != nil {
+				return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: setErr.Error()}
+			}
+			return protocol.ContextValue{Version: version}, nil
+		}
+		if setErr := agshctx.WithSource(store, agentSource(p.Source)).Set(p.Scope, p.Key, p.Value); setErr != nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: setErr.Error()}
 		}
+		if p.WithVersion {
+			return protocol.ContextValue{Version: agshctx.ValueVersion(p.Value)}, nil
+		}
+		return "ok", nil
+	})
 
-		bus.Publish(events.NewEvent(events.EventContextChange, map[string]any{
-			"scope": p.Scope,
-			"key":   p.Key,
-		}))
-
+	// context.delete
+	h.Register(protocol.MethodContextDelete, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ContextDeleteParams](params)
+		if err != nil {
+			return nil, err
+		}
+		if delErr := agshctx.WithSource(store, agentSource(p.Source)).Delete(p.Scope, p.Key); delErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: delErr.Error()}
+		}
 		return "ok", nil
 	})
 
+	// context.list
+	h.Register(protocol.MethodContextList, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ContextListParams](params)
+		if err != nil {
+			return nil, err
+		}
+		entries, listErr := store.List(p.Scope)
+		if listErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: listErr.Error()}
+		}
+		return entries, nil
+	})
+
+	// context.describe
+	h.Register(protocol.MethodContextDescribe, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ContextDescribeParams](params)
+		if err != nil {
+			return nil, err
+		}
+		keys, descErr := agshctx.Describe(store, p.Scope)
+		if descErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: descErr.Error()}
+		}
+		return keys, nil
+	})
+
+	// context.history
+	h.Register(protocol.MethodContextHistory, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ContextHistoryParams](params)
+		if err != nil {
+			return nil, err
+		}
+		entries, histErr := agshctx.KeyHistory(store, p.Scope, p.Key)
+		if histErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: histErr.Error()}
+		}
+		return entries, nil
+	})
+
+	// context.export
+	h.Register(protocol.MethodContextExport, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ContextExportParams](params)
+		if err != nil {
+			return nil, err
+		}
+		export, exportErr := agshctx.ExportStore(store, agshctx.ExportFilter{Scopes: p.Scopes, Include: p.Include, Exclude: p.Exclude})
+		if exportErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: exportErr.Error()}
+		}
+		return export, nil
+	})
+
+	// context.import
+	h.Register(protocol.MethodContextImport, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ContextImportParams](params)
+		if err != nil {
+			return nil, err
+		}
+		var export agshctx.Export
+		if jsonErr := json.Unmarshal(p.Data, &export); jsonErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: "invalid data: " + jsonErr.Error()}
+		}
+		filter := agshctx.ExportFilter{Scopes: p.Scopes, Include: p.Include, Exclude: p.Exclude}
+		n, importErr := agshctx.ImportStore(store, export, filter, p.Replace)
+		if importErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: importErr.Error()}
+		}
+		return protocol.ContextImportResult{Imported: n}, nil
+	})
+
 	// checkpoint.save
 	h.Register(protocol.MethodCheckpointSave, func(params json.RawMessage) (any, *protocol.Error) {
 		p, err := protocol.ParseParams[protocol.CheckpointParams](params)
@@ -292,9 +393,19 @@ func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store
 		if cpMgr == nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: "checkpoint manager not available"}
 		}
-		snap, snapErr := verify.CaptureSnapshot(store, "")
+		var opts []verify.SnapshotOption
+		if len(p.Scopes) > 0 {
+			opts = append(opts, verify.WithScopes(p.Scopes...))
+		}
+		if len(p.Include) > 0 || len(p.Exclude) > 0 {
+			opts = append(opts, verify.WithKeyPatterns(p.Include, p.Exclude))
+		}
+		if p.MaxValueSize > 0 {
+			opts = append(opts, verify.WithMaxValueSize(p.MaxValueSize))
+		}
+		snap, snapErr := verify.CaptureSnapshot(store, "", opts...)
 		if snapErr != nil {
-			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: snapErr.Error()}
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: snapErr.Error()}
 		}
 		if saveErr := cpMgr.Save(p.Name, snap); saveErr != nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: saveErr.Error()}
@@ -320,7 +431,11 @@ func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store
 		if restoreErr != nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: restoreErr.Error()}
 		}
-		if err := verify.RestoreSnapshot(store, snap); err != nil {
+		var opts []verify.RestoreOption
+		if p.Additive {
+			opts = append(opts, verify.WithAdditiveRestore())
+		}
+		if err := verify.RestoreSnapshot(store, snap, opts...); err != nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: err.Error()}
 		}
 
@@ -331,11 +446,58 @@ func registerCoreMethods(h *protocol.Handler, registry *platform.Registry, store
 		return map[string]any{"restored": p.Name}, nil
 	})
 
+	// checkpoint.list
+	h.Register(protocol.MethodCheckpointList, func(params json.RawMessage) (any, *protocol.Error) {
+		if cpMgr == nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: "checkpoint manager not available"}
+		}
+		infos, listErr := cpMgr.List()
+		if listErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: listErr.Error()}
+		}
+		if infos == nil {
+			infos = []verify.CheckpointInfo{}
+		}
+		return infos, nil
+	})
+
+	// checkpoint.delete
+	h.Register(protocol.MethodCheckpointDelete, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.CheckpointParams](params)
+		if err != nil {
+			return nil, err
+		}
+		if cpMgr == nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: "checkpoint manager not available"}
+		}
+		if delErr := cpMgr.Delete(p.Name); delErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: delErr.Error()}
+		}
+		return map[string]any{"deleted": p.Name}, nil
+	})
+
 	// history
 	h.Register(protocol.MethodHistory, func(params json.RawMessage) (any, *protocol.Error) {
 		history := bus.History(time.Time{})
 		return history, nil
 	})
+
+	// doctor
+	h.RegisterContext(protocol.MethodDoctor, func(ctx gocontext.Context, params json.RawMessage) (any, *protocol.Error) {
+		ctx, cancel := gocontext.WithTimeout(ctx, doctorTimeout)
+		defer cancel()
+		result := protocol.DoctorResult{Healthy: true, Checks: []protocol.HealthCheckInfo{}}
+		for _, res := range registry.CheckHealth(ctx) {
+			result.Healthy = result.Healthy && res.OK
+			result.Checks = append(result.Checks, protocol.HealthCheckInfo{
+				Name:       res.Name,
+				OK:         res.OK,
+				Error:      res.Error,
+				DurationMs: res.Duration.Milliseconds(),
+			})
+		}
+		return result, nil
+	})
 }
 
 // registerProjectMethods registers project.* lifecycle methods.
@@ -347,7 +509,7 @@ func registerProjectMethods(h *protocol.Handler, registry *platform.Registry, st
 			return nil, err
 		}
 
-		projSpec, loadErr := spec.LoadSpec(p.Path, p.Params)
+		projSpec, loadErr := spec.LoadSpec(p.Path, p.Params, state.loadOpts...)
 		if loadErr != nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: loadErr.Error()}
 		}
@@ -359,8 +521,10 @@ func registerProjectMethods(h *protocol.Handler, registry *platform.Registry, st
 
 		state.mu.Lock()
 		state.loadedSpec = &projSpec
+		state.loadedPath = p.Path
 		state.pendingPlan = nil
 		state.planID = ""
+		state.planHash = ""
 		state.mu.Unlock()
 
 		bus.Publish(events.NewEvent(events.EventSpecLoaded, map[string]any{
@@ -388,17 +552,24 @@ func registerProjectMethods(h *protocol.Handler, registry *platform.Registry, st
 			return nil, &protocol.Error{Code: protocol.CodeNoPendingPlan, Message: "no spec loaded; call project.load first"}
 		}
 
-		lister := &registryLister{registry: registry}
-		plan, planErr := spec.GeneratePlan(*state.loadedSpec, lister)
+		plan, planErr := agsh.PlanSpec(*state.loadedSpec, state.loadedPath, registry, state.loadOpts...)
 		if planErr != nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: planErr.Error()}
 		}
+		applyLimits(&plan, state.limits)
+		planHash, hashErr := spec.PlanHash(plan)
+		if hashErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: hashErr.Error()}
+		}
 
 		state.pendingPlan = &plan
 		state.planID = fmt.Sprintf("plan-%d", time.Now().UnixMilli())
+		state.planHash = planHash
+		state.tally = &approval.Tally{Required: approval.Required(plan, currentApprovalPolicy().TwoPersonDestructive)}
 
 		bus.Publish(events.NewEvent(events.EventPlanGenerated, map[string]any{
 			"plan_id":       state.planID,
+			"plan_hash":     planHash,
 			"spec":          plan.Spec,
 			"steps":         len(plan.Steps),
 			"risk_summary":  plan.EstimatedRisk,
@@ -424,6 +595,8 @@ func registerProjectMethods(h *protocol.Handler, registry *platform.Registry, st
 
 		return map[string]any{
 			"plan_id":          state.planID,
+			"plan_hash":        planHash,
+			"approvals_needed": state.tally.Required,
 			"spec":             plan.Spec,
 			"steps":            planSteps,
 			"risk_summary":     plan.EstimatedRisk,
@@ -432,29 +605,69 @@ func registerProjectMethods(h *protocol.Handler, registry *platform.Registry, st
 		}, nil
 	})
 
-	// project.approve
-	h.Register(protocol.MethodProjectApprove, func(params json.RawMessage) (any, *protocol.Error) {
-		state.mu.Lock()
-		defer state.mu.Unlock()
+	// project.approve: the approval names the plan by id or hash, and is
+	// rejected if the pending plan is not the one named or has changed
+	// since it was generated.
+	h.RegisterContext(protocol.MethodProjectApprove, func(ctx gocontext.Context, params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ProjectApproveParams](params)
+		if err != nil {
+			return nil, err
+		}
+		if p.PlanID == "" && p.PlanHash == "" {
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: "plan_id or plan_hash is required"}
+		}
 
+		state.mu.Lock()
 		if state.pendingPlan == nil {
+			state.mu.Unlock()
 			return nil, &protocol.Error{Code: protocol.CodeNoPendingPlan, Message: "no pending plan to approve"}
 		}
+		if state.exec.busy() {
+			state.mu.Unlock()
+			return nil, &protocol.Error{Code: protocol.CodeCommandFailed, Message: errPlanRunning.Error()}
+		}
+		if mismatch := checkApproval(p, state.planID, state.planHash, *state.pendingPlan); mismatch != nil {
+			state.mu.Unlock()
+			return nil, mismatch
+		}
+		d := agentDecision(*state.pendingPlan, state.planID, state.approver, p.Approver, approval.SourceAgent, true, "")
+		if addErr := recordDecision(state.tally, d); addErr != nil {
+			state.mu.Unlock()
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: addErr.Error()}
+		}
+		if !state.tally.Approved() {
+			defer state.mu.Unlock()
+			return map[string]any{
+				"plan_id":          state.planID,
+				"status":           "awaiting_approval",
+				"approvals":        state.tally.Approvals(),
+				"approvals_needed": state.tally.Required,
+			}, nil
+		}
 
-		bus.Publish(events.NewEvent(events.EventPlanApproved, map[string]any{
-			"plan_id": state.planID,
-		}))
-
-		// Execute the plan.
+		// Take the plan and release the lock so status queries are not
+		// blocked while it executes.
 		plan := *state.pendingPlan
+		planID, planHash := state.planID, state.planHash
+		decisions := state.tally.Decisions
+		projSpec, specPath := state.loadedSpec, state.loadedPath
 		state.pendingPlan = nil
+		state.mu.Unlock()
 
-		result, execErr := executeAgentPlan(plan, registry, store, bus, cpMgr)
+		bus.Publish(events.NewEvent(events.EventPlanApproved, map[string]any{
+			"plan_id":   planID,
+			"plan_hash": planHash,
+			"approvers": approverNames(decisions),
+		}))
+
+		rec := startRunRecord(projSpec, specPath, plan, bus)
+		rec.writeApprovals(decisions)
+		result, execErr := executeAgentPlan(ctx, plan, planID, registry, store, bus, cpMgr, state.exec, rec)
 		if execErr != nil {
-			return nil, &protocol.Error{Code: protocol.CodeCommandFailed, Message: execErr.Error()}
+			return nil, commandError(protocol.CodeCommandFailed, "", execErr)
 		}
 
-		return result, nil
+		return limitOutput(state.results, result, 0, false), nil
 	})
 
 	// project.reject
@@ -471,59 +684,143 @@ func registerProjectMethods(h *protocol.Handler, registry *platform.Registry, st
 			return nil, &protocol.Error{Code: protocol.CodeNoPendingPlan, Message: "no pending plan to reject"}
 		}
 
+		d := agentDecision(*state.pendingPlan, state.planID, state.approver, p.Approver, approval.SourceAgent, false, p.Feedback)
+		if addErr := recordDecision(state.tally, d); addErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: addErr.Error()}
+		}
+
 		bus.Publish(events.NewEvent(events.EventPlanRejected, map[string]any{
 			"plan_id":  state.planID,
 			"feedback": p.Feedback,
+			"approver": d.Approver,
 		}))
 
 		state.pendingPlan = nil
 		state.planID = ""
+		state.planHash = ""
 
 		return map[string]any{"status": "rejected", "feedback": p.Feedback}, nil
 	})
 
 	// project.run — load + plan + auto-approve + execute.
-	h.Register(protocol.MethodProjectRun, func(params json.RawMessage) (any, *protocol.Error) {
+	h.RegisterContext(protocol.MethodProjectRun, func(ctx gocontext.Context, params json.RawMessage) (any, *protocol.Error) {
 		p, err := protocol.ParseParams[protocol.ProjectLoadParams](params)
 		if err != nil {
 			return nil, err
 		}
 
-		projSpec, loadErr := spec.LoadSpec(p.Path, p.Params)
-		if loadErr != nil {
-			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: loadErr.Error()}
-		}
+		result, rpcErr, replayed := state.idempotency.do(ctx, protocol.MethodProjectRun, p.IdempotencyKey, p, func() (any, *protocol.Error) {
+			projSpec, loadErr := spec.LoadSpec(p.Path, p.Params, state.loadOpts...)
+			if loadErr != nil {
+				return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: loadErr.Error()}
+			}
 
-		vr := spec.ValidateSpec(projSpec)
-		if !vr.Valid() {
-			return nil, &protocol.Error{Code: protocol.CodeSpecInvalid, Message: vr.Error()}
-		}
+			vr := spec.ValidateSpec(projSpec)
+			if !vr.Valid() {
+				return nil, &protocol.Error{Code: protocol.CodeSpecInvalid, Message: vr.Error()}
+			}
 
-		bus.Publish(events.NewEvent(events.EventSpecLoaded, map[string]any{
-			"name": projSpec.Meta.Name,
-		}))
+			bus.Publish(events.NewEvent(events.EventSpecLoaded, map[string]any{
+				"name": projSpec.Meta.Name,
+			}))
 
-		lister := &registryLister{registry: registry}
-		plan, planErr := spec.GeneratePlan(projSpec, lister)
-		if planErr != nil {
-			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: planErr.Error()}
-		}
+			plan, planErr := agsh.PlanSpec(projSpec, p.Path, registry, state.loadOpts...)
+			if planErr != nil {
+				return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: planErr.Error()}
+			}
+			applyLimits(&plan, state.limits)
 
-		bus.Publish(events.NewEvent(events.EventPlanGenerated, map[string]any{
-			"spec":  plan.Spec,
-			"steps": len(plan.Steps),
-		}))
+			bus.Publish(events.NewEvent(events.EventPlanGenerated, map[string]any{
+				"spec":  plan.Spec,
+				"steps": len(plan.Steps),
+			}))
 
-		bus.Publish(events.NewEvent(events.EventPlanApproved, map[string]any{
-			"auto": true,
-		}))
+			tally := approval.Tally{Required: approval.Required(plan, currentApprovalPolicy().TwoPersonDestructive)}
+			if tally.Required > 1 {
+				return nil, &protocol.Error{Code: protocol.CodeCommandFailed, Message: "the plan has write steps and approval.two_person_destructive requires two approvers; use project.plan and project.approve"}
+			}
+			planID := fmt.Sprintf("plan-%d", time.Now().UnixMilli())
+			if addErr := recordDecision(&tally, agentDecision(plan, planID, state.approver, p.Approver, approval.SourceAuto, true, "")); addErr != nil {
+				return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: addErr.Error()}
+			}
+			bus.Publish(events.NewEvent(events.EventPlanApproved, map[string]any{
+				"plan_id":   planID,
+				"auto":      true,
+				"approvers": approverNames(tally.Decisions),
+			}))
+
+			rec := startRunRecord(&projSpec, p.Path, plan, bus)
+			rec.writeApprovals(tally.Decisions)
+			result, execErr := executeAgentPlan(ctx, plan, planID, registry, store, bus, cpMgr, state.exec, rec)
+			if execErr != nil {
+				return nil, commandError(protocol.CodeCommandFailed, "", execErr)
+			}
+			return result, nil
+		})
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		if replayed {
+			// Copy so the cached result is not modified.
+			res := make(map[string]any)
+			for k, v := range result.(map[string]any) {
+				res[k] = v
+			}
+			res["provenance"] = []protocol.ProvenanceStep{dedupProvenance(protocol.MethodProjectRun)}
+			return limitOutput(state.results, res, 0, false), nil
+		}
+		return limitOutput(state.results, result.(map[string]any), 0, false), nil
+	})
+
+	// project.resume: continue a failed or interrupted run after its
+	// completed steps. The run's plan was approved when it first started.
+	h.RegisterContext(protocol.MethodProjectResume, func(ctx gocontext.Context, params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ProjectResumeParams](params)
+		if err != nil {
+			return nil, err
+		}
+		if p.RunID == "" {
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: "run_id is required"}
+		}
 
-		result, execErr := executeAgentPlan(plan, registry, store, bus, cpMgr)
+		rec, plan, resumeErr := resumeRunRecord(p.RunID, bus)
+		if resumeErr != nil {
+			return nil, commandError(protocol.CodeInternalError, "", resumeErr)
+		}
+		planID := fmt.Sprintf("plan-%d", time.Now().UnixMilli())
+		result, execErr := executeAgentPlan(ctx, plan, planID, registry, store, bus, cpMgr, state.exec, rec)
 		if execErr != nil {
-			return nil, &protocol.Error{Code: protocol.CodeCommandFailed, Message: execErr.Error()}
+			return nil, commandError(protocol.CodeCommandFailed, "", execErr)
 		}
+		result["resumed_at"] = len(rec.completed)
+		return limitOutput(state.results, result, 0, false), nil
+	})
 
-		return result, nil
+	// project.intent: the intent trace of a recorded run, linking its
+	// goal to the steps taken and the success criteria checked.
+	h.Register(protocol.MethodProjectIntent, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ProjectIntentParams](params)
+		if err != nil {
+			return nil, err
+		}
+		trace, readErr := readIntentTrace(cmp.Or(p.RunID, "latest"))
+		if readErr != nil {
+			return nil, commandError(protocol.CodeInvalidParams, "", readErr)
+		}
+		return trace, nil
+	})
+
+	// project.answer: answer the question of a running ask step, sent as
+	// an approval.required notification with kind "input".
+	h.Register(protocol.MethodProjectAnswer, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ProjectAnswerParams](params)
+		if err != nil {
+			return nil, err
+		}
+		if answerErr := answerQuestion(bus, p.ID, p.Answer, "agent"); answerErr != nil {
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: answerErr.Error()}
+		}
+		return map[string]any{"id": p.ID, "status": "answered"}, nil
 	})
 
 	// project.validate
@@ -533,7 +830,7 @@ func registerProjectMethods(h *protocol.Handler, registry *platform.Registry, st
 			return nil, err
 		}
 
-		projSpec, loadErr := spec.LoadSpec(p.Path, p.Params)
+		projSpec, loadErr := spec.LoadSpec(p.Path, p.Params, state.loadOpts...)
 		if loadErr != nil {
 			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: loadErr.Error()}
 		}
@@ -570,45 +867,390 @@ func registerProjectMethods(h *protocol.Handler, registry *platform.Registry, st
 	})
 }
 
-// executeAgentPlan runs a plan through the pipeline and verifies success criteria.
-func executeAgentPlan(plan spec.ExecutionPlan, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cpMgr verify.CheckpointManager) (map[string]any, error) {
-	executor := &registryExecutor{registry: registry}
-	publisher := &eventBusPublisher{bus: bus}
+// groupByNamespace arranges commands under their namespace's metadata,
+// with namespaces and commands sorted by name.
+func groupByNamespace(registry *platform.Registry, infos []protocol.CommandInfo) []protocol.NamespaceInfo {
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	byName := make(map[string]*protocol.NamespaceInfo)
+	var names []string
+	for _, info := range infos {
+		group, ok := byName[info.Namespace]
+		if !ok {
+			ns := registry.NamespaceInfo(info.Namespace)
+			group = &protocol.NamespaceInfo{
+				Name:        ns.Name,
+				Description: ns.Description,
+				Credentials: ns.Credentials,
+				Risk:        ns.Risk,
+			}
+			byName[info.Namespace] = group
+			names = append(names, info.Namespace)
+		}
+		group.Commands = append(group.Commands, info)
+	}
+	sort.Strings(names)
+	groups := make([]protocol.NamespaceInfo, len(names))
+	for i, name := range names {
+		groups[i] = *byName[name]
+	}
+	return groups
+}
+
+// agentSource names the client in context history entries: the source
+// the client gave, or "agent".
+func agentSource(source string) string {
+	if source == "" {
+		return "agent"
+	}
+	return source
+}
+
+// conflictError reports a failed context.set with expected_version, with
+// the version found in its data.
+func conflictError(e *agshctx.ConflictError) *protocol.Error {
+	return &protocol.Error{
+		Code:    protocol.CodeContextConflict,
+		Message: e.Error(),
+		Data:    protocol.ContextConflictData{Scope: e.Scope, Key: e.Key, ExpectedVersion: e.Expected, Version: e.Actual},
+	}
+}
+
+// checkApproval returns the error rejecting an approval that names a plan
+// other than the pending one, by id or hash, or nil. The pending plan is
+// hashed again so a plan changed after it was generated is rejected even
+// when the approval names it by id.
+func checkApproval(p protocol.ProjectApproveParams, planID, planHash string, pending spec.ExecutionPlan) *protocol.Error {
+	if p.PlanID != "" && p.PlanID != planID {
+		return &protocol.Error{Code: protocol.CodePlanMismatch, Message: fmt.Sprintf("plan %s is not the pending plan %s", p.PlanID, planID)}
+	}
+	current, err := spec.PlanHash(pending)
+	if err != nil {
+		return &protocol.Error{Code: protocol.CodeInternalError, Message: err.Error()}
+	}
+	if current != planHash {
+		return &protocol.Error{Code: protocol.CodePlanMismatch, Message: fmt.Sprintf("plan %s changed since it was generated; call project.plan again", planID)}
+	}
+	if p.PlanHash != "" && p.PlanHash != current {
+		return &protocol.Error{Code: protocol.CodePlanMismatch, Message: fmt.Sprintf("plan hash %s does not match the pending plan %s", p.PlanHash, planID)}
+	}
+	return nil
+}
+
+// commandError builds the JSON-RPC error for a failed command or plan,
+// with the classified error in Data. command names the offending command
+// when err does not.
+func commandError(code int, command string, err error) *protocol.Error {
+	return &protocol.Error{Code: code, Message: err.Error(), Data: errorData(err, command)}
+}
+
+// errorData classifies err for protocol.Error.Data.
+func errorData(err error, command string) *protocol.ErrorData {
+	ce := platform.Classify(err)
+	data := &protocol.ErrorData{
+		Code:      ce.Code,
+		Category:  ce.Category,
+		Retriable: ce.Retriable,
+		Hint:      ce.Hint,
+		Command:   ce.Command,
+	}
+	if data.Command == "" {
+		data.Command = command
+	}
+	if ce.Step >= 0 {
+		data.Step = &ce.Step
+	}
+	for _, f := range ce.Fields {
+		data.Fields = append(data.Fields, protocol.FieldError{Field: f.Field, Problem: f.Problem})
+	}
+	return data
+}
+
+// executeAgentCommand runs a single command for the execute method family,
+// including optional verification.
+func executeAgentCommand(ctx gocontext.Context, p protocol.ExecuteParams, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus) (protocol.ExecuteResult, *protocol.Error) {
+	cmd, resolveErr := registry.Resolve(p.Command)
+	if resolveErr != nil {
+		// Accept tool names from commands.export_schema as well.
+		if alt, err := registry.Resolve(platform.CommandName(p.Command)); err == nil {
+			cmd, resolveErr = alt, nil
+			p.Command = cmd.Name()
+		}
+	}
+	if resolveErr != nil {
+		return protocol.ExecuteResult{}, commandError(protocol.CodeCommandNotFound, p.Command, resolveErr)
+	}
+
+	// Build input envelope from args.
+	input := agshctx.NewEnvelope(p.Args, "application/json", "agent")
+
+	bus.Publish(events.Event{
+		Type:      events.EventCommandStart,
+		Timestamp: time.Now(),
+		Data:      map[string]any{"command": p.Command, "intent": p.Intent},
+	})
+
+	start := time.Now()
+	output, execErr := registry.Invoke(ctx, cmd, input, store)
+	duration := time.Since(start)
+
+	if execErr != nil {
+		bus.Publish(events.Event{
+			Type:      events.EventCommandError,
+			Timestamp: time.Now(),
+			Data:      map[string]any{"command": p.Command, "error": execErr.Error()},
+			Duration:  duration,
+		})
+		return protocol.ExecuteResult{}, commandError(protocol.CodeCommandFailed, p.Command, execErr)
+	}
+
+	bus.Publish(events.Event{
+		Type:      events.EventCommandEnd,
+		Timestamp: time.Now(),
+		Data:      map[string]any{"command": p.Command, "status": "ok"},
+		Duration:  duration,
+	})
+
+	result := protocol.ExecuteResult{
+		Payload: output.Payload,
+		Meta: map[string]any{
+			"content_type": output.Meta.ContentType,
+			"source":       output.Meta.Source,
+			"tags":         output.Meta.Tags,
+		},
+	}
+
+	// Run verification if requested.
+	if len(p.Verify) > 0 {
+		intent := assertionDefsToIntent(p.Verify, p.Intent)
+		engine := verify.NewEngine(verify.WithContextStore(store))
+
+		bus.Publish(events.NewEvent(events.EventVerifyStart, map[string]any{
+			"command":    p.Command,
+			"assertions": len(p.Verify),
+		}))
+
+		vResult, _ := engine.VerifyContext(ctx, output, intent)
+		result.Verification = &protocol.VerificationInfo{
+			Passed:  vResult.Passed,
+			Results: convertVerifyResults(vResult.Results),
+		}
 
-	pipelineSteps := make([]agshctx.PipelineStep, len(plan.Steps))
-	for i, step := range plan.Steps {
-		pipelineSteps[i] = agshctx.PipelineStep{
-			Command:          step.Command,
-			Args:             step.Args,
-			Intent:           step.Intent,
-			OnError:          step.OnError,
-			CheckpointBefore: step.CheckpointBefore,
+		bus.Publish(events.NewEvent(events.EventVerifyResult, map[string]any{
+			"command":    p.Command,
+			"passed":     vResult.Passed,
+			"summary":    fmt.Sprintf("%d/%d assertions passed", agsh.CountPassed(vResult.Results), len(vResult.Results)),
+			"assertions": vResult.Details(),
+		}))
+	}
+
+	// Add provenance.
+	for _, step := range output.Provenance {
+		result.Provenance = append(result.Provenance, protocol.ProvenanceStep{
+			Command:  step.Command,
+			Duration: step.Duration.String(),
+			Status:   step.Status,
+		})
+	}
+
+	return result, nil
+}
+
+// runAgentPipeline runs a multi-step pipeline for the pipeline method family.
+// Step args become each step's input payload and step assertions are checked
+// through a stepAssertionVerifier. The optional observer is notified as each
+// step completes.
+func runAgentPipeline(ctx gocontext.Context, p protocol.PipelineParams, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cpMgr verify.CheckpointManager, observer agshctx.StepObserver) map[string]any {
+	steps := make([]agshctx.PipelineStep, len(p.Steps))
+	verifier := &stepAssertionVerifier{
+		defs:    make([][]protocol.AssertionDef, len(p.Steps)),
+		intents: make([]string, len(p.Steps)),
+		results: make([]*protocol.VerificationInfo, len(p.Steps)),
+		details: make([][]verify.AssertionDetail, len(p.Steps)),
+		store:   store,
+	}
+	for i, s := range p.Steps {
+		steps[i] = agshctx.PipelineStep{
+			ID:              s.ID,
+			Command:         s.Command,
+			Intent:          s.Intent,
+			OnError:         s.OnError,
+			OnVerifyFailure: s.OnVerifyFailure,
+			Params:          s.Args,
+			Needs:           s.Needs,
+			Inputs:          s.Inputs,
+
+			VerifyOutputSchema: s.VerifyOutputSchema,
 		}
+		verifier.defs[i] = s.Verify
+		verifier.intents[i] = s.Intent
 	}
 
+	executor := &agsh.Executor{Registry: registry}
+	publisher := &agsh.Publisher{Bus: bus}
+
 	pipeline := &agshctx.Pipeline{
-		Steps:    pipelineSteps,
-		Context:  store,
-		Executor: executor,
-		Events:   publisher,
+		Steps:     steps,
+		Context:   store,
+		Executor:  executor,
+		Events:    publisher,
+		Verifier:  verifier,
+		Observer:  observer,
+		Artifacts: agshctx.DirArtifactStore{Root: runsDir()},
 	}
 
 	if cpMgr != nil {
-		pipeline.Checkpointer = &checkpointAdapter{
-			manager: cpMgr,
-			store:   store,
+		pipeline.Checkpointer = &agsh.Checkpointer{
+			Manager: cpMgr,
+			Store:   store,
 		}
 	}
 
-	ctx := gocontext.Background()
 	input := agshctx.NewEnvelope(nil, "text/plain", "agent")
 
 	result, execErr := pipeline.Run(ctx, input)
+	stepResults := make([]protocol.PipelineStepResult, len(result.Steps))
+	for i, sr := range result.Steps {
+		stepResults[i] = protocol.PipelineStepResult{
+			Command:      sr.Step.Command,
+			Status:       sr.Status,
+			Duration:     sr.Duration.String(),
+			Error:        sr.Error,
+			Verification: verifier.results[sr.Index],
+			RolledBack:   sr.RolledBack,
+			Abandoned:    sr.Abandoned,
+			Artifacts:    stepArtifactPaths(sr),
+			OutputSchema: stepSchemaCheck(sr),
+		}
+	}
+
 	if execErr != nil {
-		return nil, execErr
+		return map[string]any{
+			"success":      false,
+			"error":        execErr.Error(),
+			"error_detail": errorData(execErr, ""),
+			"steps":        len(result.Steps),
+			"step_results": stepResults,
+		}
+	}
+
+	return map[string]any{
+		"success":      result.Success,
+		"steps":        len(result.Steps),
+		"output":       result.Output.Payload,
+		"step_results": stepResults,
+	}
+}
+
+// stepAssertionVerifier bridges per-step protocol assertions to
+// agshctx.StepVerifier, keeping each step's results for the response and
+// its assertion details for the verify.result event. Steps without
+// assertions always pass.
+type stepAssertionVerifier struct {
+	defs    [][]protocol.AssertionDef
+	intents []string
+	results []*protocol.VerificationInfo
+	details [][]verify.AssertionDetail
+	store   agshctx.ContextStore // read by context.* targets
+}
+
+func (v *stepAssertionVerifier) VerifyStep(stepIndex int, envelope agshctx.Envelope) (bool, string, error) {
+	return v.VerifyStepContext(gocontext.Background(), stepIndex, envelope)
+}
+
+// VerifyStepContext implements agshctx.ContextStepVerifier.
+func (v *stepAssertionVerifier) VerifyStepContext(ctx gocontext.Context, stepIndex int, envelope agshctx.Envelope) (bool, string, error) {
+	if stepIndex >= len(v.defs) || len(v.defs[stepIndex]) == 0 {
+		return true, "no assertions", nil
 	}
 
-	response := map[string]any{
+	intent := assertionDefsToIntent(v.defs[stepIndex], v.intents[stepIndex])
+	vResult, err := verify.NewEngine(verify.WithContextStore(v.store)).VerifyContext(ctx, envelope, intent)
+	v.results[stepIndex] = &protocol.VerificationInfo{
+		Passed:  vResult.Passed,
+		Results: convertVerifyResults(vResult.Results),
+	}
+	v.details[stepIndex] = vResult.Details()
+	if err != nil {
+		return false, "", err
+	}
+
+	summary := fmt.Sprintf("%d/%d assertions passed", agsh.CountPassed(vResult.Results), len(vResult.Results))
+	return vResult.Passed, summary, nil
+}
+
+// StepAssertions implements agshctx.StepAssertionReporter.
+func (v *stepAssertionVerifier) StepAssertions(stepIndex int) any {
+	if stepIndex >= len(v.details) || v.details[stepIndex] == nil {
+		return nil
+	}
+	return v.details[stepIndex]
+}
+
+// executeAgentPlan runs a plan through the pipeline and verifies success criteria.
+// Progress is reported to tracker, which may be nil, and the run is
+// recorded in rec, which may be nil.
+func executeAgentPlan(ctx gocontext.Context, plan spec.ExecutionPlan, planID string, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cpMgr verify.CheckpointManager, tracker *executionTracker, rec *runRecord) (response map[string]any, err error) {
+	var (
+		result        agshctx.PipelineResult
+		summaryVerify *verify.VerificationResult
+	)
+	started := time.Now()
+	defer func() {
+		summary := newRunSummary(plan, result, summaryVerify, started)
+		summary.RunID = rec.id()
+		rec.writeManifest(plan, result, summaryVerify)
+		rec.writeIntentTrace(plan, result, summaryVerify)
+		rec.finish(result, summaryVerify, summary, err)
+	}()
+
+	if !tracker.begin(planID, len(plan.Steps), plan.Limits.MaxDuration) {
+		return nil, errPlanRunning
+	}
+	defer tracker.end()
+
+	executor := &agsh.Executor{Registry: registry}
+	publisher := &agsh.Publisher{Bus: bus}
+
+	pipelineSteps := agsh.PipelineSteps(plan)
+	saveProject(store, plan)
+
+	// Ask steps wait for project.answer (or the inspector).
+	asker := &busAsker{bus: bus, remote: true}
+
+	pipeline := &agshctx.Pipeline{
+		Steps:       pipelineSteps,
+		Context:     store,
+		Executor:    executor,
+		Events:      publisher,
+		Artifacts:   agshctx.DirArtifactStore{Root: runsDir()},
+		Observer:    stepObservers{tracker, rec},
+		ID:          rec.id(),
+		Resume:      rec.resumeFrom(),
+		Specs:       &agsh.SpecRunner{Registry: registry, Store: store, Bus: bus, Checkpoints: cpMgr, Asker: asker},
+		Asker:       asker,
+		Timeout:     agsh.PlanTimeout(plan),
+		OnTimeout:   plan.Limits.OnTimeout,
+		IntentDrift: plan.IntentDrift,
+	}
+
+	if cpMgr != nil {
+		pipeline.Checkpointer = &agsh.Checkpointer{
+			Manager: cpMgr,
+			Store:   store,
+		}
+	}
+
+	input := agshctx.NewEnvelope(nil, "text/plain", "agent")
+
+	if ctx, err = agsh.PlanContext(ctx, plan); err != nil {
+		return nil, err
+	}
+	result, err = pipeline.Run(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	response = map[string]any{
 		"success": result.Success,
 		"steps":   len(result.Steps),
 		"output":  result.Output.Payload,
@@ -616,44 +1258,117 @@ func executeAgentPlan(plan spec.ExecutionPlan, registry *platform.Registry, stor
 
 	// Verify success criteria.
 	if len(plan.SuccessCriteria) > 0 {
-		intent := specCriteriaToIntent(plan.SuccessCriteria)
-		engine := verify.NewEngine()
-
 		bus.Publish(events.NewEvent(events.EventVerifyStart, map[string]any{
 			"type":       "success_criteria",
 			"assertions": len(plan.SuccessCriteria),
 		}))
 
-		vResult, _ := engine.Verify(result.Output, intent)
+		vResult, _ := agsh.VerifyCriteria(ctx, plan, result, store)
+		summaryVerify = &vResult
 
 		bus.Publish(events.NewEvent(events.EventVerifyResult, map[string]any{
+			"type":       "success_criteria",
 			"passed":     vResult.Passed,
-			"assertions": len(vResult.Results),
+			"summary":    fmt.Sprintf("%d/%d assertions passed", agsh.CountPassed(vResult.Results), len(vResult.Results)),
+			"assertions": vResult.Details(),
 		}))
 
+		info := &protocol.VerificationInfo{
+			Passed:  vResult.Passed,
+			Results: convertVerifyResults(vResult.Results),
+		}
+		tracker.verified(info)
 		response["verification"] = map[string]any{
-			"passed":  vResult.Passed,
-			"results": convertVerifyResults(vResult.Results),
+			"passed":  info.Passed,
+			"results": info.Results,
 		}
 
 		if !vResult.Passed {
-			return nil, fmt.Errorf("verification failed: %d/%d assertions passed",
-				countPassed(vResult.Results), len(vResult.Results))
+			err := fmt.Errorf("%w: %d/%d assertions passed", agshctx.ErrVerificationFailed,
+				agsh.CountPassed(vResult.Results), len(vResult.Results))
+			if plan.OnVerifyFailure == "rollback" {
+				name, rbErr := agsh.Rollback(pipeline.Checkpointer, result)
+				if rbErr != nil {
+					return nil, fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+				}
+				bus.Publish(events.NewEvent(events.EventCheckpointRestore, map[string]any{
+					"name":   name,
+					"reason": "verify_failure",
+				}))
+				return nil, fmt.Errorf("%w (rolled back to checkpoint %s)", err, name)
+			}
+			return nil, err
 		}
 	}
 
+	summary := newRunSummary(plan, result, summaryVerify, started)
+	summary.RunID = rec.id()
+	response["summary"] = summary
 	return response, nil
 }
 
 // Helper functions.
 
+// exportJSONSchemas renders commands with standard JSON Schema input and output.
+func exportJSONSchemas(cmds []platform.PlatformCommand) []map[string]any {
+	out := make([]map[string]any, len(cmds))
+	for i, cmd := range cmds {
+		input := cmd.InputSchema().JSONSchema()
+		input["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+		input["title"] = cmd.Name()
+		// Only object inputs are valid instances of the schema.
+		var examples []any
+		for _, ex := range platform.Examples(cmd) {
+			if _, ok := ex.Input.(map[string]any); ok {
+				examples = append(examples, ex.Input)
+			}
+		}
+		if len(examples) > 0 {
+			input["examples"] = examples
+		}
+		out[i] = map[string]any{
+			"name":          cmd.Name(),
+			"description":   cmd.Description(),
+			"namespace":     cmd.Namespace(),
+			"input_schema":  input,
+			"output_schema": cmd.OutputSchema().JSONSchema(),
+		}
+	}
+	return out
+}
+
+// exportOpenAITools renders commands as OpenAI function-calling tools. Tool
+// names use platform.ToolName since ':' is not allowed there.
+func exportOpenAITools(cmds []platform.PlatformCommand) []map[string]any {
+	out := make([]map[string]any, len(cmds))
+	for i, cmd := range cmds {
+		out[i] = map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        platform.ToolName(cmd.Name()),
+				"description": cmd.Description(),
+				"parameters":  cmd.InputSchema().JSONSchema(),
+			},
+		}
+	}
+	return out
+}
+
+func convertExamples(examples []platform.Example) []protocol.ExampleInfo {
+	var out []protocol.ExampleInfo
+	for _, ex := range examples {
+		out = append(out, protocol.ExampleInfo{Description: ex.Description, Input: ex.Input, Output: ex.Output})
+	}
+	return out
+}
+
 func convertSchemaFields(fields map[string]platform.SchemaField) map[string]protocol.SchemaFieldInfo {
 	if fields == nil {
 		return nil
 	}
 	result := make(map[string]protocol.SchemaFieldInfo, len(fields))
 	for k, v := range fields {
-		result[k] = protocol.SchemaFieldInfo{Type: v.Type, Description: v.Description}
+		result[k] = protocol.SchemaFieldInfo{Type: v.Type, Description: v.Description, Aliases: v.Aliases}
 	}
 	return result
 }
@@ -682,6 +1397,9 @@ func convertVerifyResults(results []verify.AssertionResult) []protocol.Assertion
 			Actual:  r.Actual,
 			Message: r.Message,
 		}
+		if r.Usage != nil {
+			out[i].Usage = llmUsage(*r.Usage)
+		}
 	}
 	return out
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cgast/agsh/internal/approval"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/platform"
+	llmplatform "github.com/cgast/agsh/pkg/platform/llm"
+	"github.com/cgast/agsh/pkg/protocol"
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+func TestConflictError(t *testing.T) {
+	e := conflictError(&agshctx.ConflictError{Scope: "session", Key: "k", Expected: "v1", Actual: "v2"})
+	if e.Code != protocol.CodeContextConflict {
+		t.Errorf("code = %d", e.Code)
+	}
+	want := protocol.ContextConflictData{Scope: "session", Key: "k", ExpectedVersion: "v1", Version: "v2"}
+	if e.Data != want {
+		t.Errorf("data = %+v, want %+v", e.Data, want)
+	}
+}
+
+func TestProjectApproveCountsConnection(t *testing.T) {
+	// The audit log goes to .agsh in the working directory.
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".agsh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	plan := spec.ExecutionPlan{Spec: "deploy", Steps: []spec.PlanStep{{Command: "fs:write", Risk: "write"}}}
+	hash, err := spec.PlanHash(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &agentState{
+		pendingPlan: &plan,
+		planID:      "plan-1",
+		planHash:    hash,
+		tally:       &approval.Tally{Required: approval.Required(plan, true)},
+		approver:    "agent:test@1",
+		exec:        newExecutionTracker(llmplatform.NewBudget(0)),
+	}
+	h := protocol.NewHandler()
+	registerProjectMethods(h, platform.NewRegistry(), nil, events.NewMemoryBus(), state, nil)
+
+	approve := func(name string) protocol.Response {
+		t.Helper()
+		req := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"project.approve","params":{"plan_id":"plan-1","approver":%q}}`, name)
+		data, err := json.Marshal(h.HandleMessage([]byte(req)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp protocol.Response
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// Two names over one connection are one approver.
+	if resp := approve("alice"); resp.Error != nil {
+		t.Fatalf("first approval: %v", resp.Error)
+	}
+	resp := approve("bob")
+	if resp.Error == nil || resp.Error.Code != protocol.CodeInvalidParams {
+		t.Fatalf("second approval over the same connection = %+v, want an invalid params error", resp)
+	}
+	if state.tally.Approved() || state.pendingPlan == nil {
+		t.Error("one connection satisfied two_person_destructive")
+	}
+	if d := state.tally.Decisions[0]; d.Approver != "agent:test@1" || d.Name != "alice" {
+		t.Errorf("decision = %+v, want the connection as approver and the given name", d)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cgast/agsh/internal/approval"
+	"github.com/cgast/agsh/internal/config"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+// auditLog is the log approval decisions are appended to.
+var auditLog = sync.OnceValue(func() *approval.Log {
+	return &approval.Log{Path: auditLogPath()}
+})
+
+// approvalPolicy is the approval config of the long-running modes; a config
+// reload replaces it.
+var approvalPolicy atomic.Pointer[config.ApprovalConfig]
+
+// setApprovalPolicy makes cfg the approval config of plans approved from
+// now on.
+func setApprovalPolicy(cfg config.ApprovalConfig) {
+	approvalPolicy.Store(&cfg)
+}
+
+// currentApprovalPolicy returns the approval config last set, or the zero
+// config if none was.
+func currentApprovalPolicy() config.ApprovalConfig {
+	if cfg := approvalPolicy.Load(); cfg != nil {
+		return *cfg
+	}
+	return config.ApprovalConfig{}
+}
+
+// auditLogPath returns the audit log path, next to the runs.
+func auditLogPath() string {
+	if _, err := os.Stat(".agsh"); err == nil {
+		return filepath.Join(".agsh", "audit.jsonl")
+	}
+	return statePath(projectState().State, "audit.jsonl")
+}
+
+// newDecision describes a decision on plan, identified by its hash.
+func newDecision(plan spec.ExecutionPlan, planID, approver, source string, approved bool, feedback string) approval.Decision {
+	hash, _ := spec.PlanHash(plan)
+	return approval.Decision{
+		Approver: approver,
+		Source:   source,
+		Approved: approved,
+		Spec:     plan.Spec,
+		PlanID:   planID,
+		PlanHash: hash,
+		Feedback: feedback,
+		Time:     time.Now().UTC(),
+	}
+}
+
+// recordDecision adds d to tally and, if it counts, to the audit log.
+func recordDecision(tally *approval.Tally, d approval.Decision) error {
+	if err := tally.Add(d); err != nil {
+		return err
+	}
+	if err := auditLog().Append(d); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	return nil
+}
+
+// approverNames lists who approved in decisions.
+func approverNames(decisions []approval.Decision) []string {
+	var names []string
+	for _, d := range decisions {
+		if d.Approved {
+			names = append(names, d.Approver)
+		}
+	}
+	return names
+}
+
+// approveRun gets plan approved for `agsh run`: automatically when the
+// flag and config allow it, otherwise at the terminal. It returns the
+// decisions, for the run record.
+func approveRun(scanner *bufio.Scanner, bus events.EventBus, plan spec.ExecutionPlan, cfg config.ApprovalConfig, flag string) ([]approval.Decision, error) {
+	auto, err := autoApprove(plan, cfg, flag)
+	if err != nil {
+		return nil, err
+	}
+	if auto {
+		tally := approval.Tally{Required: approval.Required(plan, cfg.TwoPersonDestructive)}
+		if tally.Required > 1 {
+			return nil, fmt.Errorf("refusing to auto-approve a plan with write steps; approval.two_person_destructive requires %d approvers", tally.Required)
+		}
+		if err := recordDecision(&tally, newDecision(plan, "", approval.CurrentUser(), approval.SourceAuto, true, "")); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(os.Stderr, "\nPlan auto-approved.")
+		return tally.Decisions, nil
+	}
+	if !stdinIsTerminal() {
+		return nil, fmt.Errorf("plan requires approval but stdin is not a terminal (use --yes or --approve=never)")
+	}
+	decisions, ok := approvePlan(scanner, bus, plan, cfg)
+	if !ok {
+		return decisions, fmt.Errorf("execution cancelled")
+	}
+	return decisions, nil
+}
+
+// approvePlan asks the user running agsh to approve plan on the terminal.
+// The terminal vouches for that user alone, so a plan needing two
+// approvers is refused. The request is published, so notifications and
+// the inspector see it.
+func approvePlan(scanner *bufio.Scanner, bus events.EventBus, plan spec.ExecutionPlan, cfg config.ApprovalConfig) ([]approval.Decision, bool) {
+	tally := approval.Tally{Required: approval.Required(plan, cfg.TwoPersonDestructive)}
+	approver := approval.CurrentUser()
+	if tally.Required > 1 {
+		fmt.Fprintf(os.Stderr, "\nThe plan has write steps and approval.two_person_destructive requires %d approvers, but only %s can approve at this terminal.\n", tally.Required, approver)
+		return nil, false
+	}
+
+	bus.Publish(events.NewEvent(events.EventPlanApproval, map[string]any{
+		"spec":    plan.Spec,
+		"message": fmt.Sprintf("plan for %s awaiting approval", plan.Spec),
+	}))
+	ok := approveExecution(scanner)
+	if err := recordDecision(&tally, newDecision(plan, "", approver, approval.SourceTerminal, ok, "")); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return tally.Decisions, false
+	}
+	return tally.Decisions, ok
+}
+
+// agentApprover is who approvals over agent mode's one connection, stdin
+// and stdout, count as, whatever name the client gives.
+func agentApprover() string {
+	return fmt.Sprintf("%s:%s@%d", approval.SourceAgent, approval.CurrentUser(), os.Getpid())
+}
+
+// agentDecision describes a decision the client of agent mode made on plan
+// as approver, recording the name it gave.
+func agentDecision(plan spec.ExecutionPlan, planID, approver, name, source string, approved bool, feedback string) approval.Decision {
+	d := newDecision(plan, planID, approver, source, approved, feedback)
+	d.Name = name
+	return d
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	gocontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+)
+
+// inputRequest is the data of an input.requested event.
+type inputRequest struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"` // "input", to tell it from plan approvals
+	agshctx.Question
+}
+
+// inputAnswer is the data of an input.answered event.
+type inputAnswer struct {
+	ID     string `json:"id"`
+	Answer string `json:"answer"`
+	Source string `json:"source"` // "terminal", "inspector" or "agent"
+}
+
+var askSeq atomic.Int64
+
+// busAsker implements agshctx.Asker over the event bus. It publishes an
+// input.requested event, which the inspector shows and agent mode forwards
+// as an approval.required notification, and waits for the input.answered
+// event with the same id. With a scanner it also prompts on the terminal.
+type busAsker struct {
+	bus     events.EventBus
+	scanner *bufio.Scanner // reads terminal answers; nil without a terminal
+	remote  bool           // the inspector or an agent can answer too
+}
+
+// newRunAsker returns the asker for `agsh run` and the REPL: the terminal
+// when stdin is one, and the inspector when it runs. It returns nil when
+// neither can answer, so ask steps fail instead of waiting forever.
+func newRunAsker(bus events.EventBus, scanner *bufio.Scanner, inspector bool) agshctx.Asker {
+	if !stdinIsTerminal() {
+		scanner = nil
+	}
+	if scanner == nil && !inspector {
+		return nil
+	}
+	return &busAsker{bus: bus, scanner: scanner, remote: inspector}
+}
+
+func (a *busAsker) Ask(ctx gocontext.Context, q agshctx.Question) (string, error) {
+	req := inputRequest{
+		ID:       fmt.Sprintf("ask-%d-%d", time.Now().UnixMilli(), askSeq.Add(1)),
+		Kind:     "input",
+		Question: q,
+	}
+	answers := a.bus.Subscribe(events.EventInputAnswered)
+	defer a.bus.Unsubscribe(answers)
+	a.bus.Publish(events.NewEvent(events.EventInputRequested, req))
+
+	var eof chan struct{} // closed when stdin ends without an answer
+	if a.scanner != nil {
+		eof = make(chan struct{})
+		go a.prompt(req, eof)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-eof:
+			if !a.remote {
+				return "", errors.New("stdin closed before an answer")
+			}
+			eof = nil
+		case ev, ok := <-answers:
+			if !ok {
+				return "", errors.New("event bus closed")
+			}
+			if ans, ok := ev.Data.(inputAnswer); ok && ans.ID == req.ID {
+				return ans.Answer, nil
+			}
+		}
+	}
+}
+
+// prompt asks on the terminal until it gets a valid answer, then publishes
+// it. An answer typed after the question was answered elsewhere is
+// dropped.
+func (a *busAsker) prompt(req inputRequest, eof chan<- struct{}) {
+	for {
+		fmt.Fprintf(os.Stderr, "\n%s %s ", req.Prompt, promptHint(req.Question))
+		if !a.scanner.Scan() {
+			close(eof)
+			return
+		}
+		if _, pending := findQuestion(a.bus, req.ID); !pending {
+			return
+		}
+		answer := strings.TrimSpace(a.scanner.Text())
+		if err := checkAnswer(req.Question, answer); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			continue
+		}
+		a.bus.Publish(events.NewEvent(events.EventInputAnswered, inputAnswer{ID: req.ID, Answer: answer, Source: "terminal"}))
+		return
+	}
+}
+
+func promptHint(q agshctx.Question) string {
+	switch {
+	case len(q.Choices) > 0 && q.Default != "":
+		return fmt.Sprintf("[%s] (default %s)", strings.Join(q.Choices, "/"), q.Default)
+	case len(q.Choices) > 0:
+		return fmt.Sprintf("[%s]", strings.Join(q.Choices, "/"))
+	case q.Default != "":
+		return fmt.Sprintf("(default %s)", q.Default)
+	}
+	return ">"
+}
+
+// checkAnswer rejects an answer the asking step would fail on.
+func checkAnswer(q agshctx.Question, answer string) error {
+	if answer == "" {
+		answer = q.Default
+	}
+	if len(q.Choices) > 0 && !slices.Contains(q.Choices, answer) {
+		return fmt.Errorf("answer must be one of %s", strings.Join(q.Choices, ", "))
+	}
+	return nil
+}
+
+// findQuestion returns the question of an unanswered input request.
+func findQuestion(bus events.EventBus, id string) (agshctx.Question, bool) {
+	var (
+		q     agshctx.Question
+		found bool
+	)
+	for _, ev := range bus.History(time.Time{}) {
+		switch data := ev.Data.(type) {
+		case inputRequest:
+			if data.ID == id {
+				q, found = data.Question, true
+			}
+		case inputAnswer:
+			if data.ID == id {
+				found = false
+			}
+		}
+	}
+	return q, found
+}
+
+// answerQuestion answers a pending input request on behalf of source.
+func answerQuestion(bus events.EventBus, id, answer, source string) error {
+	q, ok := findQuestion(bus, id)
+	if !ok {
+		return fmt.Errorf("no pending question %q", id)
+	}
+	answer = strings.TrimSpace(answer)
+	if err := checkAnswer(q, answer); err != nil {
+		return err
+	}
+	bus.Publish(events.NewEvent(events.EventInputAnswered, inputAnswer{ID: id, Answer: answer, Source: source}))
+	return nil
+}
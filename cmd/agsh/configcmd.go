@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cgast/agsh/internal/config"
+)
+
+// handleConfig implements `agsh config validate|show`.
+func handleConfig() error {
+	if len(os.Args) < 3 {
+		printConfigUsage()
+		return nil
+	}
+
+	switch os.Args[2] {
+	case "validate":
+		return handleConfigValidate()
+	case "show":
+		effective := false
+		for _, arg := range os.Args[3:] {
+			if arg == "--effective" {
+				effective = true
+			}
+		}
+		return handleConfigShow(effective)
+	default:
+		printConfigUsage()
+		return fmt.Errorf("unknown config command: %s", os.Args[2])
+	}
+}
+
+func printConfigUsage() {
+	fmt.Println("Usage: agsh config <command>")
+	fmt.Println("  agsh config validate          Check config files, env overrides and platforms.yaml")
+	fmt.Println("  agsh config show              Print the values that differ from defaults")
+	fmt.Println("  agsh config show --effective  Print every value with its source")
+	fmt.Println()
+	fmt.Println("Precedence: defaults < " + config.GlobalConfigPath() + " < " + configPath() +
+		" < " + config.EnvPrefix + "* env vars < --mode / --set key=value flags")
+}
+
+// handleConfigValidate reports unknown fields and invalid values in the
+// runtime and platform config files.
+func handleConfigValidate() error {
+	failed := false
+
+	opts := configLoadOptions()
+	for _, path := range []string{opts.GlobalPath, opts.ProjectPath} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if _, err := config.Resolve(path); err != nil {
+			failed = true
+			fmt.Printf("%s:\n", path)
+			printConfigError(err)
+		} else {
+			fmt.Printf("%s: ok\n", path)
+		}
+	}
+	if _, err := config.Load(opts); err != nil {
+		failed = true
+		fmt.Println("effective configuration:")
+		printConfigError(err)
+	}
+
+	if _, err := config.LoadPlatformConfig(platformConfigPath()); err != nil {
+		failed = true
+		fmt.Printf("%s:\n", platformConfigPath())
+		printConfigError(err)
+	} else {
+		fmt.Printf("%s: ok\n", platformConfigPath())
+	}
+
+	if failed {
+		return fmt.Errorf("config validation failed")
+	}
+	return nil
+}
+
+func printConfigError(err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			printConfigError(e)
+		}
+		return
+	}
+	var verr *config.ValidationError
+	if errors.As(err, &verr) {
+		for _, fe := range verr.Errors {
+			fmt.Printf("  - %s\n", fe)
+		}
+		return
+	}
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			fmt.Printf("  - %s\n", line)
+		}
+	}
+}
+
+// handleConfigShow prints the configuration. With effective, every field is
+// listed with the file it came from, or "default".
+func handleConfigShow(effective bool) error {
+	r, err := config.Load(configLoadOptions())
+	if err != nil {
+		return err
+	}
+	settings, err := r.Settings()
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, s := range settings {
+		if len(s.Key) > width {
+			width = len(s.Key)
+		}
+	}
+	for _, s := range settings {
+		if !effective && s.Source == config.SourceDefault {
+			continue
+		}
+		value, _ := json.Marshal(s.Value)
+		if effective {
+			fmt.Printf("%-*s  %s  (%s)\n", width, s.Key, value, s.Source)
+		} else {
+			fmt.Printf("%-*s  %s\n", width, s.Key, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// handleContextCommand implements `agsh context export|import|history`.
+func handleContextCommand(store agshctx.ContextStore) error {
+	if len(os.Args) < 3 {
+		printContextUsage()
+		return nil
+	}
+
+	var (
+		filter  agshctx.ExportFilter
+		out     string
+		replace bool
+		asJSON  bool
+		args    []string
+	)
+	rest := os.Args[3:]
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		switch {
+		case arg == "--replace":
+			replace = true
+		case arg == "--json":
+			asJSON = true
+		case (arg == "--scope" || arg == "--key" || arg == "--exclude" || arg == "--out") && i+1 < len(rest):
+			i++
+			switch arg {
+			case "--scope":
+				if rest[i] != "all" {
+					filter.Scopes = append(filter.Scopes, strings.Split(rest[i], ",")...)
+				}
+			case "--key":
+				filter.Include = append(filter.Include, rest[i])
+			case "--exclude":
+				filter.Exclude = append(filter.Exclude, rest[i])
+			case "--out":
+				out = rest[i]
+			}
+		case strings.HasPrefix(arg, "--"):
+			return withExitCode(exitUsage, fmt.Errorf("unknown flag: %s", arg))
+		default:
+			args = append(args, arg)
+		}
+	}
+
+	switch os.Args[2] {
+	case "export":
+		if len(args) != 0 {
+			return withExitCode(exitUsage, fmt.Errorf("usage: agsh context export [--scope all|SCOPE,...] [--key PATTERN] [--exclude PATTERN] [--out FILE]"))
+		}
+		return exportContext(store, filter, out)
+	case "import":
+		if len(args) != 1 {
+			return withExitCode(exitUsage, fmt.Errorf("usage: agsh context import FILE|- [--scope all|SCOPE,...] [--key PATTERN] [--exclude PATTERN] [--replace]"))
+		}
+		return importContext(store, args[0], filter, replace)
+	case "history":
+		if len(args) < 1 || len(args) > 2 {
+			return withExitCode(exitUsage, fmt.Errorf("usage: agsh context history SCOPE [KEY] [--json]"))
+		}
+		key := ""
+		if len(args) == 2 {
+			key = args[1]
+		}
+		return showContextHistory(store, args[0], key, asJSON)
+	default:
+		printContextUsage()
+		return withExitCode(exitUsage, fmt.Errorf("unknown context command: %s", os.Args[2]))
+	}
+}
+
+func printContextUsage() {
+	fmt.Println("Usage: agsh context <command>")
+	fmt.Println("  agsh context export [--out FILE]  Write the context store as JSON (stdout by default)")
+	fmt.Println("  agsh context import FILE|-        Read an export back, overwriting existing keys")
+	fmt.Println("  agsh context history SCOPE [KEY]  Show how keys changed, oldest first (--json for JSON)")
+	fmt.Println()
+	fmt.Println("  --scope all|SCOPE,...  Scopes to include: project, session, step, history (default all)")
+	fmt.Println("  --key PATTERN          Only keys matching the pattern (repeatable, e.g. 'report.*')")
+	fmt.Println("  --exclude PATTERN      Skip keys matching the pattern (repeatable)")
+	fmt.Println("  --replace              On import, also delete selected keys the export lacks")
+}
+
+func exportContext(store agshctx.ContextStore, filter agshctx.ExportFilter, out string) error {
+	export, err := agshctx.ExportStore(store, filter)
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	if out == "" || out == "-" {
+		return printJSON(export)
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("write export: %w", err)
+	}
+	keys := 0
+	for _, items := range export.Scopes {
+		keys += len(items)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d keys from %d scopes to %s\n", keys, len(export.Scopes), out)
+	return nil
+}
+
+func importContext(store agshctx.ContextStore, file string, filter agshctx.ExportFilter, replace bool) error {
+	var (
+		data []byte
+		err  error
+	)
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return fmt.Errorf("read export: %w", err)
+	}
+	var export agshctx.Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("%s: not a context export: %w", file, err)
+	}
+	n, err := agshctx.ImportStore(store, export, filter, replace)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d keys\n", n)
+	return nil
+}
+
+func showContextHistory(store agshctx.ContextStore, scope, key string, asJSON bool) error {
+	entries, err := agshctx.KeyHistory(store, scope, key)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		if entries == nil {
+			entries = []agshctx.HistoryEntry{}
+		}
+		return printJSON(entries)
+	}
+	printHistory(entries)
+	return nil
+}
+
+// printHistory writes history entries as a table, as the CLI and the REPL
+// show them.
+func printHistory(entries []agshctx.HistoryEntry) {
+	if len(entries) == 0 {
+		fmt.Println("No recorded changes.")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEQ\tTIME\tOP\tKEY\tOLD\tNEW\tSOURCE")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s/%s\t%s\t%s\t%s\n", e.Seq, e.Time.Local().Format(time.DateTime),
+			e.Op, e.Scope, e.Key, orDash(e.OldHash), orDash(e.NewHash), orDash(e.Source))
+	}
+	tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/cgast/agsh/pkg/agsh"
 	agshctx "github.com/cgast/agsh/pkg/context"
 	"github.com/cgast/agsh/pkg/events"
 	"github.com/cgast/agsh/internal/config"
@@ -37,7 +38,7 @@ func runDemo01(workspaceDir, outputPath string) error {
 	defer store.Close()
 
 	ctx := gocontext.Background()
-	publisher := &eventBusPublisher{bus: bus}
+	publisher := &agsh.Publisher{Bus: bus}
 
 	// Subscribe to events for observability.
 	ch := bus.Subscribe()
@@ -172,9 +173,23 @@ func runDemo01(workspaceDir, outputPath string) error {
 		"success": true,
 	}, 2, 0)
 
-	// Verify: output is not empty.
-	if summary == "" {
-		return fmt.Errorf("verification failed: summary is empty")
+	// Verify the summary and the counts kept in the session.
+	intent := verify.Intent{
+		Description: "Verify heading summary",
+		Assertions: []verify.Assertion{
+			{Type: "not_empty", Target: "output", Message: "Summary must not be empty"},
+			{Type: "count_gte", Target: "context.session.file_count", Expected: 1, Message: "At least one markdown file must be counted"},
+			{Type: "not_empty", Target: "context.session.total_headings", Message: "The total heading count must be stored"},
+		},
+	}
+	vResult, err := verify.NewEngine(verify.WithContextStore(store)).VerifyContext(ctx, agshctx.NewEnvelope(summary, "text/markdown", "demo"), intent)
+	if err != nil {
+		return fmt.Errorf("verification error: %w", err)
+	}
+	for _, ar := range vResult.Results {
+		if !ar.Passed {
+			return fmt.Errorf("verification failed: %s", ar.Message)
+		}
 	}
 
 	fmt.Fprintf(os.Stderr, "\n=== Output ===\n")
@@ -202,7 +217,7 @@ func runDemo02(specPath string) error {
 	}
 	defer store.Close()
 
-	publisher := &eventBusPublisher{bus: bus}
+	publisher := &agsh.Publisher{Bus: bus}
 
 	// Subscribe to events for observability.
 	ch := bus.Subscribe()
@@ -226,7 +241,7 @@ func runDemo02(specPath string) error {
 	// Set up the protocol handler to demonstrate plan generation.
 	handler := protocol.NewHandler()
 	state := &agentState{}
-	registerCoreMethods(handler, registry, store, bus, cpMgr)
+	registerCoreMethods(handler, registry, store, bus, state, cpMgr)
 	registerProjectMethods(handler, registry, store, bus, state, cpMgr)
 
 	reqID := 0
@@ -369,7 +384,7 @@ func runDemo02(specPath string) error {
 
 	outputEnvelope := agshctx.NewEnvelope(report, "text/markdown", "demo")
 	engine := verify.NewEngine()
-	vResult, err := engine.Verify(outputEnvelope, intent)
+	vResult, err := engine.VerifyContext(gocontext.Background(), outputEnvelope, intent)
 	if err != nil {
 		return fmt.Errorf("verification error: %w", err)
 	}
@@ -482,7 +497,7 @@ func runDemo03(inputCSV string) error {
 	defer store.Close()
 
 	ctx := gocontext.Background()
-	publisher := &eventBusPublisher{bus: bus}
+	publisher := &agsh.Publisher{Bus: bus}
 
 	// Subscribe to events for observability.
 	ch := bus.Subscribe()
@@ -585,7 +600,7 @@ func runDemo03(inputCSV string) error {
 
 	outputEnvelope := agshctx.NewEnvelope(tableOutput, "text/markdown", "transform")
 	engine := verify.NewEngine()
-	vResult, err := engine.Verify(outputEnvelope, intent)
+	vResult, err := engine.VerifyContext(ctx, outputEnvelope, intent)
 	if err != nil {
 		return fmt.Errorf("verification error: %w", err)
 	}
@@ -704,7 +719,7 @@ func runDemo04(specPath string) error {
 	cpDir := filepath.Join(os.TempDir(), "agsh-demo04-checkpoints")
 	cpMgr, _ := verify.NewFileCheckpointManager(cpDir)
 
-	registerCoreMethods(handler, registry, store, bus, cpMgr)
+	registerCoreMethods(handler, registry, store, bus, state, cpMgr)
 	registerProjectMethods(handler, registry, store, bus, state, cpMgr)
 
 	// Helper to send a JSON-RPC request and display the result.
@@ -0,0 +1,57 @@
+package main
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cgast/agsh/internal/config"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// doctorTimeout bounds how long `agsh doctor` waits for backend checks.
+const doctorTimeout = 15 * time.Second
+
+// handleDoctor implements `agsh doctor`: it validates the configuration and
+// runs every backend health check (GitHub token, HTTP egress), so bad
+// credentials surface before a plan fails halfway through.
+func handleDoctor(registry *platform.Registry) error {
+	failed := false
+
+	if _, err := config.Load(configLoadOptions()); err != nil {
+		failed = true
+		fmt.Println("config: FAIL")
+		printConfigError(err)
+	} else {
+		fmt.Println("config: ok")
+	}
+	platCfg, err := config.LoadPlatformConfig(platformConfigPath())
+	if err != nil {
+		failed = true
+		fmt.Println("platforms: FAIL")
+		printConfigError(err)
+	} else {
+		fmt.Println("platforms: ok")
+	}
+	if !platCfg.GitHub.Configured() {
+		fmt.Printf("github: not configured for %s (set github.token in %s)\n", platCfg.GitHub.Host(), platformConfigPath())
+	}
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), doctorTimeout)
+	defer cancel()
+	for _, res := range registry.CheckHealth(ctx) {
+		if res.OK {
+			fmt.Printf("%s: ok (%s)\n", res.Name, res.Duration.Round(time.Millisecond))
+			continue
+		}
+		failed = true
+		fmt.Printf("%s: FAIL\n", res.Name)
+		printConfigError(errors.New(res.Error))
+	}
+
+	if failed {
+		return fmt.Errorf("some checks failed")
+	}
+	return nil
+}
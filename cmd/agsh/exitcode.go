@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Exit codes, so CI and wrapper scripts can branch on the failure class.
+const (
+	exitFailure      = 1 // execution error, or anything not classified below
+	exitUsage        = 2 // invalid flags or arguments
+	exitSpecInvalid  = 3 // spec could not be loaded, validated or planned
+	exitVerifyFailed = 4 // success criteria or step verification failed
+	exitNotApproved  = 5 // plan rejected, or approval not possible
+	exitSandbox      = 6 // a command was refused by the sandbox
+	exitTimeout      = 7 // the run exceeded its deadline
+)
+
+// exitCodeError attaches an exit code to an error.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+func (e *exitCodeError) Unwrap() error { return e.err }
+
+// withExitCode marks err to exit the process with code. A nil err stays
+// nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitCodeError{code: code, err: err}
+}
+
+// exitCode returns the process exit code for err: the code attached with
+// withExitCode, else a code derived from the errors it wraps.
+func exitCode(err error) int {
+	var ec *exitCodeError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &ec):
+		return ec.code
+	case errors.Is(err, sandbox.ErrViolation):
+		return exitSandbox
+	case errors.Is(err, agshctx.ErrVerificationFailed):
+		return exitVerifyFailed
+	case errors.Is(err, agshctx.ErrTimeout):
+		return exitTimeout
+	}
+	return exitFailure
+}
+
+// exitOnError prints err, with a remediation hint when it is classified
+// with one, and exits with its exit code; it returns if err is nil.
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	if ce := platform.Classify(err); ce.Hint != "" {
+		fmt.Fprintf(os.Stderr, "hint: %s\n", ce.Hint)
+	}
+	os.Exit(exitCode(err))
+}
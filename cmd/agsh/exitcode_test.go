@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: 0},
+		{name: "unclassified", err: errors.New("boom"), want: exitFailure},
+		{name: "attached", err: withExitCode(exitUsage, errors.New("bad flag")), want: exitUsage},
+		{name: "attached and wrapped", err: fmt.Errorf("run: %w", withExitCode(exitNotApproved, errors.New("rejected"))), want: exitNotApproved},
+		{name: "attached wins", err: withExitCode(exitSpecInvalid, agshctx.ErrTimeout), want: exitSpecInvalid},
+		{name: "sandbox", err: fmt.Errorf("fs:read: %w", sandbox.ErrViolation), want: exitSandbox},
+		{name: "verification", err: fmt.Errorf("step 2: %w", agshctx.ErrVerificationFailed), want: exitVerifyFailed},
+		{name: "timeout", err: &agshctx.StepError{Step: 1, Command: "fs:list", Err: agshctx.ErrTimeout}, want: exitTimeout},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+	if withExitCode(exitUsage, nil) != nil {
+		t.Error("withExitCode(code, nil) is not nil")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+// idempotencyCache replays results of requests that carry an idempotency
+// key, so an agent resending after a network hiccup does not re-run write
+// commands. Only successful results are cached; a failed request may be
+// retried with the same key. A key is bound to the params it was first sent
+// with: reusing it with other params is an error, not a replay.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	done   chan struct{} // closed when the first request finishes
+	params [sha256.Size]byte
+	result any
+	err    *protocol.Error
+	at     time.Time
+}
+
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		window:  window,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// do runs fn once per method and key within the window. Repeats with the
+// same params return the first result with replayed set; a repeat arriving
+// while the first request is still running waits for it, or for ctx to be
+// done. An empty key, or a zero window, always runs fn.
+func (c *idempotencyCache) do(ctx gocontext.Context, method, key string, params any, fn func() (any, *protocol.Error)) (result any, rpcErr *protocol.Error, replayed bool) {
+	if key == "" || c == nil || c.window <= 0 {
+		result, rpcErr = fn()
+		return result, rpcErr, false
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: fmt.Sprintf("idempotency key %q: %v", key, err)}, false
+	}
+	hash := sha256.Sum256(data)
+	entryKey := method + "\x00" + key
+
+	var e *idempotencyEntry
+	for e == nil {
+		c.mu.Lock()
+		c.evictLocked()
+		prev, ok := c.entries[entryKey]
+		if !ok {
+			e = &idempotencyEntry{done: make(chan struct{}), params: hash}
+			c.entries[entryKey] = e
+		}
+		c.mu.Unlock()
+
+		if ok {
+			if prev.params != hash {
+				return nil, &protocol.Error{
+					Code:    protocol.CodeInvalidParams,
+					Message: fmt.Sprintf("idempotency key %q was already used with different params", key),
+				}, false
+			}
+			select {
+			case <-prev.done:
+			case <-ctx.Done():
+				return nil, &protocol.Error{
+					Code:    protocol.CodeInternalError,
+					Message: fmt.Sprintf("waiting for the request with idempotency key %q: %v", key, ctx.Err()),
+				}, false
+			}
+			if prev.err == nil {
+				return prev.result, nil, true
+			}
+			// The earlier attempt failed and was dropped; run it here.
+		}
+	}
+
+	e.result, e.err = fn()
+	e.at = time.Now()
+	if e.err != nil {
+		c.mu.Lock()
+		delete(c.entries, entryKey)
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return e.result, e.err, false
+}
+
+// evictLocked drops finished entries older than the window.
+func (c *idempotencyCache) evictLocked() {
+	cutoff := time.Now().Add(-c.window)
+	for key, e := range c.entries {
+		select {
+		case <-e.done:
+			if e.at.Before(cutoff) {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+}
+
+// dedupProvenance returns the provenance entry recorded on replayed results.
+func dedupProvenance(command string) protocol.ProvenanceStep {
+	return protocol.ProvenanceStep{
+		Command: command,
+		Status:  protocol.ProvenanceDeduplicated,
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	gocontext "context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	fail := &protocol.Error{Code: protocol.CodeCommandFailed, Message: "boom"}
+	tests := []struct {
+		name       string
+		window     time.Duration
+		key        string
+		age        time.Duration // how long ago the first request finished
+		firstErr   *protocol.Error
+		wantRuns   int
+		wantReplay bool
+	}{
+		{name: "repeat within the window", window: time.Minute, key: "k", wantRuns: 1, wantReplay: true},
+		{name: "repeat after the window", window: time.Minute, key: "k", age: 2 * time.Minute, wantRuns: 2},
+		{name: "no key", window: time.Minute, wantRuns: 2},
+		{name: "zero window", key: "k", wantRuns: 2},
+		{name: "failed first attempt", window: time.Minute, key: "k", firstErr: fail, wantRuns: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newIdempotencyCache(tt.window)
+			runs := 0
+			run := func(err *protocol.Error) func() (any, *protocol.Error) {
+				return func() (any, *protocol.Error) {
+					runs++
+					if err != nil {
+						return nil, err
+					}
+					return runs, nil
+				}
+			}
+
+			c.do(gocontext.Background(), "execute", tt.key, "params", run(tt.firstErr))
+			for _, e := range c.entries {
+				e.at = e.at.Add(-tt.age)
+			}
+			result, rpcErr, replayed := c.do(gocontext.Background(), "execute", tt.key, "params", run(nil))
+			if rpcErr != nil || runs != tt.wantRuns || replayed != tt.wantReplay {
+				t.Errorf("second do = %v, %v, replayed %v after %d runs; want %d runs, replayed %v",
+					result, rpcErr, replayed, runs, tt.wantRuns, tt.wantReplay)
+			}
+			if tt.wantReplay && result != 1 {
+				t.Errorf("replayed result = %v, want the first one", result)
+			}
+		})
+	}
+}
+
+func TestIdempotencyCacheKeysPerMethod(t *testing.T) {
+	c := newIdempotencyCache(time.Minute)
+	c.do(gocontext.Background(), "execute", "k", "params", func() (any, *protocol.Error) { return "execute", nil })
+	result, _, replayed := c.do(gocontext.Background(), "project.run", "k", "params", func() (any, *protocol.Error) { return "project.run", nil })
+	if replayed || result != "project.run" {
+		t.Errorf("project.run with execute's key = %v, replayed %v", result, replayed)
+	}
+}
+
+func TestIdempotencyCacheWaitsForFirst(t *testing.T) {
+	c := newIdempotencyCache(time.Minute)
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.do(gocontext.Background(), "execute", "k", "params", func() (any, *protocol.Error) {
+			close(started)
+			<-release
+			return "first", nil
+		})
+	}()
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+
+	// The repeat arrives while the first request runs and gets its result.
+	result, _, replayed := c.do(gocontext.Background(), "execute", "k", "params", func() (any, *protocol.Error) { return "second", nil })
+	if !replayed || result != "first" {
+		t.Errorf("repeat = %v, replayed %v; want the first result", result, replayed)
+	}
+	wg.Wait()
+}
+
+func TestIdempotencyCacheRejectsOtherParams(t *testing.T) {
+	c := newIdempotencyCache(time.Minute)
+	c.do(gocontext.Background(), "execute", "k", map[string]any{"command": "fs:write", "path": "a"}, func() (any, *protocol.Error) { return "a", nil })
+
+	runs := 0
+	_, rpcErr, replayed := c.do(gocontext.Background(), "execute", "k", map[string]any{"command": "fs:write", "path": "b"}, func() (any, *protocol.Error) {
+		runs++
+		return "b", nil
+	})
+	if rpcErr == nil || rpcErr.Code != protocol.CodeInvalidParams || replayed || runs != 0 {
+		t.Errorf("reused key = %v, replayed %v after %d runs; want an invalid params error", rpcErr, replayed, runs)
+	}
+}
+
+func TestIdempotencyCacheWaitHonorsContext(t *testing.T) {
+	c := newIdempotencyCache(time.Minute)
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	go c.do(gocontext.Background(), "execute", "k", "params", func() (any, *protocol.Error) {
+		close(started)
+		<-release
+		return "first", nil
+	})
+	<-started
+
+	// The repeat gives up waiting for the first request once cancelled.
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, rpcErr, replayed := c.do(ctx, "execute", "k", "params", func() (any, *protocol.Error) { return "second", nil })
+	if rpcErr == nil || replayed {
+		t.Errorf("cancelled repeat = %v, replayed %v; want an error", rpcErr, replayed)
+	}
+}
@@ -10,31 +10,66 @@ import (
 // templateDir is the default directory for spec templates.
 const templateDir = "templates"
 
-// handleInit implements `agsh init [--template=name] [--output=path]`.
+// handleInit implements `agsh init`. With a project template it scaffolds
+// a whole project; other names copy a spec template from templates/.
+// Without flags on a terminal it runs an interactive wizard; --yes accepts
+// the defaults instead.
+//
+//	agsh init [--template=name] [--dir=path] [--name=project] [--owner=github-owner] [--yes] [--force]
+//	agsh init --template=spec-template [--output=path]
 func handleInit() error {
+	opts := initOptions{}
 	templateName := ""
+	yes := false
 	outputPath := "project.agsh.yaml"
 
 	for _, arg := range os.Args[2:] {
-		if strings.HasPrefix(arg, "--template=") {
+		switch {
+		case strings.HasPrefix(arg, "--template="):
 			templateName = strings.TrimPrefix(arg, "--template=")
-		} else if strings.HasPrefix(arg, "--output=") {
+		case strings.HasPrefix(arg, "--output="):
 			outputPath = strings.TrimPrefix(arg, "--output=")
+		case strings.HasPrefix(arg, "--dir="):
+			opts.dir = strings.TrimPrefix(arg, "--dir=")
+		case strings.HasPrefix(arg, "--name="):
+			opts.name = strings.TrimPrefix(arg, "--name=")
+		case strings.HasPrefix(arg, "--owner="):
+			opts.owner = strings.TrimPrefix(arg, "--owner=")
+		case arg == "--yes" || arg == "-y":
+			yes = true
+		case arg == "--force":
+			opts.force = true
 		}
 	}
 
+	if templateName == "" && yes {
+		templateName = projectKits[0].name
+	}
 	if templateName == "" {
-		return listTemplates()
+		if len(os.Args) > 2 || !stdinIsTerminal() {
+			return listTemplates()
+		}
+		return runInitWizard(opts)
 	}
 
+	if kit, ok := findProjectKit(templateName); ok {
+		opts.kit = kit
+		return scaffoldProject(opts)
+	}
 	return scaffoldFromTemplate(templateName, outputPath)
 }
 
 // listTemplates shows available templates.
 func listTemplates() error {
-	fmt.Println("Usage: agsh init --template=<name> [--output=<path>]")
+	fmt.Println("Usage: agsh init --template=<name> [--dir=<path>] [--name=<project>] [--owner=<github-owner>] [--yes] [--force]")
+	fmt.Println("       agsh init --template=<spec-template> [--output=<path>]")
+	fmt.Println()
+	fmt.Println("Project templates (config, platforms, spec and workspace):")
+	for _, k := range projectKits {
+		fmt.Printf("  - %-15s %s\n", k.name, k.description)
+	}
 	fmt.Println()
-	fmt.Println("Available templates:")
+	fmt.Println("Spec templates:")
 
 	templates, err := findTemplates()
 	if err != nil {
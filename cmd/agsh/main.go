@@ -1,21 +1,37 @@
 package main
 
 import (
+	gocontext "context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cgast/agsh/internal/config"
 	"github.com/cgast/agsh/internal/inspector"
+	"github.com/cgast/agsh/internal/notify"
+	"github.com/cgast/agsh/internal/policy"
 	"github.com/cgast/agsh/internal/sandbox"
+	"github.com/cgast/agsh/internal/storeserver"
 	agshctx "github.com/cgast/agsh/pkg/context"
 	"github.com/cgast/agsh/pkg/events"
 	"github.com/cgast/agsh/pkg/platform"
+	dataplatform "github.com/cgast/agsh/pkg/platform/data"
+	"github.com/cgast/agsh/pkg/platform/embed"
 	"github.com/cgast/agsh/pkg/platform/fs"
 	ghplatform "github.com/cgast/agsh/pkg/platform/github"
+	glplatform "github.com/cgast/agsh/pkg/platform/gitlab"
 	httpplatform "github.com/cgast/agsh/pkg/platform/http"
+	jiraplatform "github.com/cgast/agsh/pkg/platform/jira"
+	llmplatform "github.com/cgast/agsh/pkg/platform/llm"
+	mailplatform "github.com/cgast/agsh/pkg/platform/mail"
+	webplatform "github.com/cgast/agsh/pkg/platform/web"
+	"github.com/cgast/agsh/pkg/platform/workspace"
 	"github.com/cgast/agsh/pkg/verify"
 )
 
@@ -24,95 +40,165 @@ func main() {
 	if len(os.Args) >= 2 {
 		switch os.Args[1] {
 		case "demo":
-			if err := handleDemo(); err != nil {
-				fmt.Fprintf(os.Stderr, "error: %v\n", err)
-				os.Exit(1)
-			}
+			exitOnError(handleDemo())
 			return
 		case "init":
-			if err := handleInit(); err != nil {
-				fmt.Fprintf(os.Stderr, "error: %v\n", err)
-				os.Exit(1)
-			}
+			exitOnError(handleInit())
+			return
+		case "config":
+			exitOnError(handleConfig())
 			return
 		case "validate":
-			if err := handleValidate(); err != nil {
-				fmt.Fprintf(os.Stderr, "error: %v\n", err)
-				os.Exit(1)
-			}
+			exitOnError(handleValidate())
+			return
+		case "spec":
+			exitOnError(handleSpec())
+			return
+		case "runs":
+			exitOnError(handleRuns())
 			return
 		}
 	}
 
-	mode := detectMode()
-
-	// Load configuration.
-	cfg, err := config.LoadConfig(configPath())
+	// Load configuration: defaults < global < project < env < flags.
+	// A config that does not load is refused: the layers that failed are
+	// left out, with their allowlist, policy and sandbox, so running on
+	// would fail open.
+	resolved, err := config.Load(configLoadOptions())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "warning: loading config: %v\n", err)
+		exitOnError(withExitCode(exitUsage, fmt.Errorf("loading config: %w (check it with agsh config validate)", err)))
 	}
+	cfg := resolved.Config
+	mode := cfg.Mode
 	platCfg, err := config.LoadPlatformConfig(platformConfigPath())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "warning: loading platform config: %v\n", err)
 	}
 
-	// Override mode from config if not set via flag/env.
-	if mode == "" && cfg.Mode != "" {
-		mode = cfg.Mode
-	}
-
 	// Initialize core components.
 	bus := events.NewMemoryBus()
 	registry := platform.NewRegistry()
 
 	// Create sandbox from config for filesystem enforcement.
-	var sb *sandbox.Sandbox
-	if len(cfg.Sandbox.AllowedPaths) > 0 || len(cfg.Sandbox.DeniedPaths) > 0 || cfg.Sandbox.MaxFileSize != "" {
-		var err error
-		sb, err = sandbox.New(sandbox.Config{
-			AllowedPaths: cfg.Sandbox.AllowedPaths,
-			DeniedPaths:  cfg.Sandbox.DeniedPaths,
-			MaxFileSize:  cfg.Sandbox.MaxFileSize,
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: sandbox init: %v\n", err)
-		}
+	sb, err := newSandbox(cfg.Sandbox)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: sandbox init: %v\n", err)
+	}
+	watcher := fs.NewWatcher(fileWatchInterval, func(c fs.FileChange) {
+		bus.Publish(events.NewEvent(events.EventFSChanged, c))
+	})
+	defer watcher.Close()
+	llmClient := registerCommandsSandboxed(registry, platCfg, sb, watcher)
+	registry.SetMiddleware(executorMiddleware(cfg, sb, bus)...)
+	registry.OnDeprecated(func(a platform.Alias) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", deprecationMessage(a))
+		bus.Publish(events.NewEvent(events.EventCommandDeprecated, map[string]any{
+			"alias":   a.Name,
+			"command": a.Target,
+			"message": a.Message,
+		}))
+	})
+
+	// Plan generation and health checks need the registry but not the
+	// context store.
+	if len(os.Args) >= 2 && os.Args[1] == "plan" {
+		exitOnError(handlePlan(registry))
+		return
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "doctor" {
+		exitOnError(handleDoctor(registry))
+		return
 	}
-	registerCommandsSandboxed(registry, platCfg, sb)
 
-	// Initialize context store.
+	// Initialize context store. Another agsh process may already have it
+	// open, in which case it is reached through that process.
 	dbPath := contextStorePath()
-	store, err := agshctx.NewBoltStore(dbPath)
+	store, err := storeserver.OpenContextStore(dbPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: failed to open context store: %v\n", err)
 		os.Exit(1)
 	}
 	defer store.Close()
+	store.SetHistoryLimit(cfg.History.MaxEntries)
+
+	if len(os.Args) >= 2 && os.Args[1] == "context" {
+		exitOnError(handleContextCommand(store))
+		return
+	}
+
+	// llm_judge assertions are decided by the configured model, with
+	// verdicts cached in the store.
+	verify.SetLLMJudge(newLLMJudge(cfg.Verify, llmClient, store))
+
+	// Plans are approved under the approval config current when they are
+	// approved, which a reload can replace.
+	setApprovalPolicy(cfg.Approval)
+
+	// Every change to the store, by this or another process, is published
+	// as a context.change event for the inspector and event subscribers.
+	go publishContextChanges(store.Watch(agshctx.WatchFilter{}), bus)
+
+	// Notify the user when a run needs them or is done, if configured.
+	stopNotify := func() {}
+	if cfg.Notify.Method != "" {
+		n := notify.New(notify.Options{Method: cfg.Notify.Method, Command: cfg.Notify.Command, On: cfg.Notify.On}, os.Stderr)
+		stopNotify = n.Watch(bus, func(err error) {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		})
+	}
+	defer stopNotify()
+
+	// Shared checkpoint manager for the REPL and inspector.
+	cpMgr := newCheckpointManager(cfg.Checkpoint)
+	if c, ok := cpMgr.(io.Closer); ok {
+		defer c.Close()
+	}
 
 	// Start inspector if enabled via flag or config.
 	inspectorPort := detectInspectorPort(cfg)
 	if inspectorPort > 0 {
-		cpDir := filepath.Join(os.TempDir(), "agsh-checkpoints")
-		cpMgr, _ := verify.NewFileCheckpointManager(cpDir)
 		srv := inspector.New(bus, store, registry, cpMgr)
-		srv.StartAsync(inspectorPort)
-		fmt.Fprintf(os.Stderr, "Inspector running at http://localhost:%d\n", inspectorPort)
+		srv.SetSessionName(inspectorSessionName(mode))
+		srv.SetRunsDir(runsDir())
+		srv.SetAnswerFunc(func(id, answer string) error {
+			return answerQuestion(bus, id, answer, "inspector")
+		})
+		// With another agsh process's inspector on the port, this process
+		// joins it as a session.
+		url, leave, err := srv.StartShared(inspectorPort)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: inspector not started: %v\n", err)
+		} else {
+			defer leave()
+			fmt.Fprintf(os.Stderr, "Inspector running at %s\n", url)
+		}
 	}
 
 	// Handle subcommands that need full initialization.
 	if len(os.Args) >= 2 && os.Args[1] == "run" {
-		if err := handleRun(registry, store, bus); err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
-		}
+		err := handleRun(registry, store, bus, cfg, cpMgr)
+		stopNotify() // announce the run's end before exiting
+		exitOnError(err)
 		return
 	}
 
+	// Long-running modes pick up config edits without a restart.
+	if mode == "interactive" || mode == "agent" {
+		ctx, cancel := gocontext.WithCancel(gocontext.Background())
+		defer cancel()
+		go config.Watch(ctx, configLoadOptions(), platformConfigPath(), configWatchInterval,
+			func(r config.Reload) { applyConfigReload(registry, bus, watcher, store, r) },
+			func(err error) {
+				fmt.Fprintf(os.Stderr, "warning: config not reloaded: %v\n", err)
+			},
+		)
+	}
+
 	switch mode {
 	case "interactive":
-		runInteractiveREPL(registry, store, bus)
+		runInteractiveREPL(registry, store, bus, cpMgr, configLimits(cfg.Executor), specLoadOptions(cfg.Sandbox))
 	case "agent":
-		runAgentMode(registry, store, bus)
+		runAgentMode(registry, store, bus, cfg, cpMgr)
 	default:
 		fmt.Fprintf(os.Stderr, "unknown mode: %s\n", mode)
 		os.Exit(1)
@@ -162,59 +248,374 @@ func handleDemo() error {
 	}
 }
 
-func detectMode() string {
-	// Check for explicit --mode flag.
-	for i, arg := range os.Args[1:] {
-		if arg == "--mode=agent" {
-			return "agent"
+// configLoadOptions returns the config layers for this invocation.
+func configLoadOptions() config.LoadOptions {
+	return config.LoadOptions{
+		GlobalPath:  config.GlobalConfigPath(),
+		ProjectPath: configPath(),
+		LookupEnv:   os.LookupEnv,
+		Flags:       configFlags(os.Args[1:]),
+	}
+}
+
+// configFlags collects config overrides from --mode and --set key=value.
+func configFlags(args []string) []config.Override {
+	var overrides []config.Override
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--mode" && i+1 < len(args):
+			i++
+			overrides = append(overrides, config.Override{Key: "mode", Value: args[i], Source: "flag:--mode"})
+		case strings.HasPrefix(arg, "--mode="):
+			overrides = append(overrides, config.Override{Key: "mode", Value: strings.TrimPrefix(arg, "--mode="), Source: "flag:--mode"})
+		case arg == "--set" && i+1 < len(args):
+			i++
+			if k, v, ok := strings.Cut(args[i], "="); ok {
+				overrides = append(overrides, config.Override{Key: k, Value: v, Source: "flag:--set " + k})
+			}
+		case strings.HasPrefix(arg, "--set="):
+			if k, v, ok := strings.Cut(strings.TrimPrefix(arg, "--set="), "="); ok {
+				overrides = append(overrides, config.Override{Key: k, Value: v, Source: "flag:--set " + k})
+			}
 		}
-		if arg == "--mode" && i+2 < len(os.Args) {
-			return os.Args[i+2]
+	}
+	return overrides
+}
+
+// newSandbox creates the filesystem sandbox described by cfg, or nil if cfg
+// sets no restrictions.
+func newSandbox(cfg config.SandboxConfig) (*sandbox.Sandbox, error) {
+	if len(cfg.AllowedPaths) == 0 && len(cfg.DeniedPaths) == 0 && cfg.MaxFileSize == "" {
+		return nil, nil
+	}
+	return sandbox.New(sandbox.Config{
+		AllowedPaths: cfg.AllowedPaths,
+		DeniedPaths:  cfg.DeniedPaths,
+		MaxFileSize:  cfg.MaxFileSize,
+		PathCase:     cfg.PathCase,
+	})
+}
+
+// executorMiddleware builds the command middleware chain named by cfg.
+// Names and policy rules have already been validated with the config.
+// Commands the policy stops are published on bus as policy.denied, and
+// commands stopped for tainted input as taint.blocked.
+func executorMiddleware(cfg config.Config, sb *sandbox.Sandbox, bus events.EventBus) []platform.Middleware {
+	backoff, _ := time.ParseDuration(cfg.Executor.RetryBackoff)
+	var mws []platform.Middleware
+	for _, name := range cfg.Executor.Middleware {
+		switch name {
+		case "timing":
+			mws = append(mws, platform.Timing())
+		case "allowlist":
+			mws = append(mws, platform.Allowlist(cfg.Executor.AllowedCommands))
+		case "taint":
+			mws = append(mws, platform.Taint(taintRules(cfg.Taint), func(command string, fields, sources []string) {
+				bus.Publish(events.NewEvent(events.EventTaintBlocked, map[string]any{
+					"command": command,
+					"fields":  fields,
+					"sources": sources,
+				}))
+			}))
+		case "policy":
+			if len(cfg.Policy) > 0 {
+				engine, err := policy.New(cfg.PolicyRules())
+				if err != nil {
+					// Fail closed: no command runs under rules that do
+					// not compile.
+					fmt.Fprintf(os.Stderr, "error: policy: %v; refusing all commands\n", err)
+					mws = append(mws, platform.PolicyInvalid(err))
+					continue
+				}
+				mws = append(mws, platform.Policy(engine, func(command string, d policy.Decision) {
+					bus.Publish(events.NewEvent(events.EventPolicyDenied, map[string]any{
+						"command": command,
+						"rule":    d.Rule,
+						"effect":  d.Effect,
+						"message": d.Message,
+					}))
+				}))
+			}
+		case "sandbox":
+			// Runs of specs with a sandbox of their own are checked even
+			// without a configured one.
+			var checker platform.PathChecker
+			if sb != nil {
+				checker = sb
+			}
+			mws = append(mws, platform.Sandbox(checker))
+		case "retry":
+			mws = append(mws, platform.Retry(cfg.Executor.RetryAttempts, backoff))
 		}
 	}
+	return mws
+}
 
-	// Check environment variable.
-	if m := os.Getenv("AGSH_MODE"); m != "" {
-		return m
+// taintRules converts the taint config for platform.Taint.
+func taintRules(cfg config.TaintConfig) platform.TaintRules {
+	rules := platform.TaintRules{Sources: cfg.Sources, TrustedDomains: cfg.TrustedDomains}
+	for _, s := range cfg.Sinks {
+		rules.Sinks = append(rules.Sinks, platform.TaintSink{Command: s.Command, Fields: s.Fields})
 	}
+	return rules
+}
 
-	// Default based on terminal detection.
-	fi, err := os.Stdin.Stat()
-	if err != nil {
-		return "interactive"
+// deprecationMessage explains what to use instead of a deprecated alias.
+func deprecationMessage(a platform.Alias) string {
+	msg := fmt.Sprintf("%s is deprecated, use %s", a.Name, a.Target)
+	if a.Message != "" {
+		msg += ": " + a.Message
 	}
-	if (fi.Mode() & os.ModeCharDevice) != 0 {
-		return "interactive"
+	return msg
+}
+
+// fileWatchInterval is how often fs:watch scans watched paths.
+const fileWatchInterval = time.Second
+
+// configWatchInterval is how often long-running modes check config files.
+const configWatchInterval = 2 * time.Second
+
+// applyConfigReload rebuilds the command set from a reloaded config, so new
+// sandbox rules, domain allowlists and credentials take effect, and swaps
+// it into the registry together with a rebuilt middleware chain in one
+// step. The LLM judge and the approval policy are replaced too.
+func applyConfigReload(registry *platform.Registry, bus *events.MemoryBus, watcher *fs.Watcher, store agshctx.ContextStore, r config.Reload) {
+	sb, err := newSandbox(r.Config.Config.Sandbox)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: config not reloaded: sandbox: %v\n", err)
+		return
 	}
+	next := platform.NewRegistry()
+	llmClient := registerCommandsSandboxed(next, r.Platform, sb, watcher)
+	next.SetMiddleware(executorMiddleware(r.Config.Config, sb, bus)...)
+	registry.ReplaceAll(next)
+	verify.SetLLMJudge(newLLMJudge(r.Config.Config.Verify, llmClient, store))
+	setApprovalPolicy(r.Config.Config.Approval)
 
-	return "interactive"
+	bus.Publish(events.NewEvent(events.EventConfigReloaded, map[string]any{
+		"files":    r.Changed,
+		"commands": len(next.Names()),
+	}))
+	fmt.Fprintf(os.Stderr, "Config reloaded (%s)\n", strings.Join(r.Changed, ", "))
 }
 
 func registerCommands(registry *platform.Registry, platCfg config.PlatformConfig) {
-	registerCommandsSandboxed(registry, platCfg, nil)
+	registerCommandsSandboxed(registry, platCfg, nil, nil)
 }
 
-func registerCommandsSandboxed(registry *platform.Registry, platCfg config.PlatformConfig, sb *sandbox.Sandbox) {
+// registerCommandsSandboxed registers the built-in commands. watcher backs
+// fs:watch; with a nil watcher fs:watch reports that it is unavailable.
+// It returns the client of the llm commands, or nil when none is
+// configured.
+func registerCommandsSandboxed(registry *platform.Registry, platCfg config.PlatformConfig, sb *sandbox.Sandbox, watcher *fs.Watcher) *llmplatform.Client {
 	// Built-in filesystem commands with optional sandbox enforcement.
+	registry.RegisterNamespace(fs.Namespace)
 	registry.Register(&fs.ListCommand{Sandbox: sb})
 	registry.Register(&fs.ReadCommand{Sandbox: sb})
+	registry.Register(&fs.StatCommand{Sandbox: sb})
+	registry.Register(&fs.GrepCommand{Sandbox: sb})
 	registry.Register(&fs.WriteCommand{Sandbox: sb})
+	registry.Register(&fs.PatchCommand{Sandbox: sb})
+	registry.Register(&fs.WatchCommand{Sandbox: sb, Watcher: watcher})
+	registry.Register(&fs.ZipCommand{Sandbox: sb})
+	registry.Register(&fs.UnzipCommand{Sandbox: sb})
 
-	// GitHub commands (only if token is configured).
-	if platCfg.GitHub.Token != "" {
-		ghClient, err := ghplatform.NewClient(platCfg.GitHub.Token)
+	registry.RegisterNamespace(dataplatform.Namespace)
+	registry.Register(&dataplatform.HashCommand{Sandbox: sb})
+
+	registry.RegisterNamespace(workspace.Namespace)
+	registry.Register(&workspace.MapCommand{Sandbox: sb})
+
+	// GitHub commands (only if a token or account is configured).
+	if platCfg.GitHub.Configured() {
+		ghClient, err := newGitHubRouter(registry, platCfg.GitHub)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "warning: github client init: %v\n", err)
 		} else {
+			registry.RegisterNamespace(ghplatform.Namespace)
 			registry.Register(ghplatform.NewRepoInfoCommand(ghClient))
 			registry.Register(ghplatform.NewPRListCommand(ghClient))
 			registry.Register(ghplatform.NewIssueCreateCommand(ghClient))
+			registry.Register(ghplatform.NewGraphQLCommand(ghClient))
+		}
+	}
+
+	// GitLab commands (only if token is configured).
+	if platCfg.GitLab.Token != "" {
+		glClient, err := glplatform.NewClient(platCfg.GitLab.Token, platCfg.GitLab.BaseURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: gitlab client init: %v\n", err)
+		} else {
+			registry.RegisterNamespace(glplatform.Namespace)
+			registry.RegisterHealthCheck("gitlab", glClient)
+			registry.Register(glplatform.NewProjectInfoCommand(glClient))
+			registry.Register(glplatform.NewMRListCommand(glClient))
+			registry.Register(glplatform.NewIssueCreateCommand(glClient))
+		}
+	}
+
+	// Jira commands (only if token is configured).
+	if platCfg.Jira.Token != "" {
+		jiraClient, err := jiraplatform.NewClient(platCfg.Jira.BaseURL, platCfg.Jira.Email, platCfg.Jira.Token)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: jira client init: %v\n", err)
+		} else {
+			registry.RegisterNamespace(jiraplatform.Namespace)
+			registry.RegisterHealthCheck("jira", jiraClient)
+			registry.Register(jiraplatform.NewSearchCommand(jiraClient))
+			registry.Register(jiraplatform.NewIssueCreateCommand(jiraClient))
+			registry.Register(jiraplatform.NewIssueCommentCommand(jiraClient))
 		}
 	}
 
+	// LLM commands (only if an endpoint is configured). All llm commands
+	// share the process's token budget, which a reload only re-limits.
+	var (
+		embedder  embed.Embedder
+		llmClient *llmplatform.Client
+	)
+	if platCfg.LLM.BaseURL != "" {
+		budget := llmBudget()
+		budget.SetLimit(platCfg.LLM.TokenBudget)
+		client, err := llmplatform.NewClient(platCfg.LLM.BaseURL, platCfg.LLM.APIKey, platCfg.LLM.Model, budget)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: llm client init: %v\n", err)
+		} else {
+			llmClient = client
+			registry.RegisterNamespace(llmplatform.Namespace)
+			registry.RegisterHealthCheck("llm", llmClient)
+			registry.Register(llmplatform.NewCompleteCommand(llmClient))
+			registry.Register(llmplatform.NewSummarizeCommand(llmClient))
+			if platCfg.LLM.EmbeddingModel != "" {
+				embedder = llmplatform.NewEmbedder(llmClient, platCfg.LLM.EmbeddingModel)
+			}
+		}
+	}
+
+	// Embedding index over the workspace and context, with the local
+	// embedder unless an embedding model is configured.
+	registry.RegisterNamespace(embed.Namespace)
+	registry.Register(&embed.IndexCommand{Sandbox: sb, Path: embedIndexPath(), Embedder: embedder})
+	registry.Register(&embed.SearchCommand{Path: embedIndexPath(), Embedder: embedder})
+
+	// Mail commands (only if an SMTP host is configured).
+	if platCfg.Mail.Host != "" {
+		registry.RegisterNamespace(mailplatform.Namespace)
+		registry.Register(mailplatform.NewSendCommand(mailplatform.Settings{
+			Host:              platCfg.Mail.Host,
+			Port:              platCfg.Mail.Port,
+			Username:          platCfg.Mail.Username,
+			Password:          platCfg.Mail.Password,
+			From:              platCfg.Mail.From,
+			AllowedRecipients: platCfg.Mail.AllowedRecipients,
+		}))
+	}
+
 	// HTTP commands (with domain allowlisting).
-	registry.Register(httpplatform.NewGetCommand(platCfg.HTTP.AllowedDomains))
-	registry.Register(httpplatform.NewPostCommand(platCfg.HTTP.AllowedDomains))
+	registry.RegisterNamespace(httpplatform.Namespace)
+	registry.RegisterHealthCheck("http", httpplatform.NewEgressCheck(platCfg.HTTP.AllowedDomains))
+	httpSettings := httpplatform.Settings{
+		AllowedDomains: platCfg.HTTP.AllowedDomains,
+		Headers:        platCfg.HTTP.Headers,
+		Proxy:          platCfg.HTTP.Proxy,
+		Auth:           make(map[string]httpplatform.AuthProfile, len(platCfg.HTTP.Auth)),
+	}
+	for name, a := range platCfg.HTTP.Auth {
+		profile := httpplatform.AuthProfile{
+			Bearer: a.Bearer, Username: a.Username, Password: a.Password,
+			Headers: a.Headers, Domains: a.Domains,
+		}
+		if a.OAuth2 != nil {
+			profile.OAuth2 = &httpplatform.OAuth2{
+				TokenURL: a.OAuth2.TokenURL, ClientID: a.OAuth2.ClientID,
+				ClientSecret: a.OAuth2.ClientSecret, Scopes: a.OAuth2.Scopes,
+			}
+		}
+		httpSettings.Auth[name] = profile
+	}
+	registry.Register(httpplatform.NewGetCommand(httpSettings))
+	registry.Register(httpplatform.NewPostCommand(httpSettings))
+	registry.Register(httpplatform.NewDownloadCommand(httpSettings, sb))
+
+	// Web page extraction (same allowlist as http).
+	registry.RegisterNamespace(webplatform.Namespace)
+	registry.Register(webplatform.NewExtractCommand(platCfg.HTTP.AllowedDomains))
+	return llmClient
+}
+
+// newGitHubClient creates a client for github.com, or for the GitHub
+// Enterprise instance at baseURL.
+func newGitHubClient(token, baseURL, uploadURL string) (*ghplatform.Client, error) {
+	if baseURL != "" {
+		return ghplatform.NewEnterpriseClient(token, baseURL, uploadURL)
+	}
+	return ghplatform.NewClient(token)
+}
+
+// newGitHubRouter creates the default GitHub client with each configured
+// account routed by owner, and registers a health check per credential.
+func newGitHubRouter(registry *platform.Registry, cfg config.GitHubConfig) (*ghplatform.Client, error) {
+	root := &ghplatform.Client{}
+	if token := cfg.HostToken(); token != "" {
+		var err error
+		root, err = newGitHubClient(token, cfg.BaseURL, cfg.UploadURL)
+		if err != nil {
+			return nil, err
+		}
+		registry.RegisterHealthCheck("github", root)
+	}
+
+	names := make([]string, 0, len(cfg.Accounts))
+	for name := range cfg.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := cfg.Accounts[name]
+		client, err := newGitHubClient(a.Token, a.BaseURL, a.UploadURL)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", name, err)
+		}
+		if err := root.Route(name, client, a.Owners); err != nil {
+			return nil, err
+		}
+		registry.RegisterHealthCheck("github:"+name, client)
+	}
+	return root, nil
+}
+
+// newLLMJudge returns the judge of llm_judge assertions: the llm model,
+// or verify.llm_judge_model at verify.llm_judge_endpoint when set, with
+// its verdicts cached in store. It returns nil, so that llm_judge
+// assertions are skipped, when no model is configured.
+func newLLMJudge(vcfg config.VerifyConfig, llmClient *llmplatform.Client, store agshctx.ContextStore) verify.Judge {
+	if llmClient == nil && vcfg.LLMJudgeEndpoint == "" {
+		return nil
+	}
+	client := llmClient
+	if vcfg.LLMJudgeEndpoint != "" || vcfg.LLMJudgeModel != "" {
+		var err error
+		if client, err = llmClient.Derive(vcfg.LLMJudgeEndpoint, vcfg.LLMJudgeModel); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: llm judge: %v\n", err)
+			return nil
+		}
+	}
+	return verify.CachedJudge{Next: llmJudge{client}, Store: store}
+}
+
+// llmJudge decides llm_judge assertions with an llm client's model.
+type llmJudge struct {
+	client *llmplatform.Client
+}
+
+func (j llmJudge) Judge(ctx gocontext.Context, criteria, output string) (verify.Verdict, error) {
+	res, err := j.client.Judge(ctx, criteria, output)
+	v := verify.Verdict{Passed: res.Passed, Reason: res.Reason}
+	if c := res.Completion; c.Model != "" {
+		v.Usage = verify.Usage{Model: c.Model, Calls: 1, PromptTokens: c.PromptTokens, CompletionTokens: c.CompletionTokens}
+	}
+	return v, err
 }
 
 func configPath() string {
@@ -225,12 +626,63 @@ func platformConfigPath() string {
 	return filepath.Join(".agsh", "platforms.yaml")
 }
 
+// llmBudget is the token budget of the process's llm clients. It outlives
+// config reloads, so tokens spent before one still count.
+var llmBudget = sync.OnceValue(func() *llmplatform.Budget {
+	return llmplatform.NewBudget(0)
+})
+
+// projectState returns the state directories of the working directory,
+// for when it has no .agsh directory. It loads the config itself, as some
+// commands need state before the config is loaded.
+var projectState = sync.OnceValue(func() config.StateDirs {
+	r, _ := config.Load(configLoadOptions())
+	return config.ProjectStateDirs(r.Config.StateDir, ".")
+})
+
+// statePath returns the path of a state file or directory in dir, one of
+// the projectState directories, or prefixed with agsh- in the temp
+// directory if dir cannot be created.
+func statePath(dir, name string) string {
+	if err := os.MkdirAll(dir, 0755); err == nil {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(os.TempDir(), "agsh-"+name)
+}
+
+// checkpointStorePath returns the checkpoint database path, next to the
+// context store.
+func checkpointStorePath() string {
+	if _, err := os.Stat(".agsh"); err == nil {
+		return filepath.Join(".agsh", "checkpoints.db")
+	}
+	return statePath(projectState().Data, "checkpoints.db")
+}
+
+// embedIndexPath returns the embedding index path. Without .agsh it is
+// cached, as it can be rebuilt.
+func embedIndexPath() string {
+	if _, err := os.Stat(".agsh"); err == nil {
+		return filepath.Join(".agsh", "embed.json")
+	}
+	return statePath(projectState().Cache, "embed.json")
+}
+
+// runsDir is where pipeline runs keep step artifacts.
+func runsDir() string {
+	if _, err := os.Stat(".agsh"); err == nil {
+		return filepath.Join(".agsh", "runs")
+	}
+	return statePath(projectState().State, "runs")
+}
+
 func contextStorePath() string {
-	// Use project-local .agsh directory if it exists, otherwise temp.
+	// Use project-local .agsh directory if it exists, otherwise the
+	// project's user data directory.
 	if _, err := os.Stat(".agsh"); err == nil {
 		return filepath.Join(".agsh", "context.db")
 	}
-	return filepath.Join(os.TempDir(), "agsh-context.db")
+	return statePath(projectState().Data, "context.db")
 }
 
 // detectInspectorPort parses --inspector and --inspector-port flags.
@@ -277,3 +729,37 @@ func detectInspectorPort(cfg config.Config) int {
 
 	return 0
 }
+
+// inspectorSessionName names this process's session in the inspector:
+// the subcommand and its arguments, or the mode.
+func inspectorSessionName(mode string) string {
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			args = append(args, arg)
+		}
+	}
+	if len(args) > 0 {
+		return strings.Join(args, " ")
+	}
+	return mode
+}
+
+// publishContextChanges publishes watched changes on the bus until the
+// store is closed.
+func publishContextChanges(changes <-chan agshctx.Change, bus events.EventBus) {
+	for c := range changes {
+		data := map[string]any{
+			"scope": c.Scope,
+			"key":   c.Key,
+			"op":    c.Op,
+		}
+		if c.Source != "" {
+			data["source"] = c.Source
+		}
+		if c.Op == "delete" {
+			data["deleted"] = true
+		}
+		bus.Publish(events.NewEvent(events.EventContextChange, data))
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	llmplatform "github.com/cgast/agsh/pkg/platform/llm"
+)
+
+func TestLLMJudge(t *testing.T) {
+	replies := []string{"PASS\nThe report is grouped by repo.", "Maybe."}
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := replies[calls]
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{
+			"model":   "judge-test",
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": reply}}},
+			"usage":   map[string]any{"prompt_tokens": 40, "completion_tokens": 8},
+		})
+	}))
+	defer srv.Close()
+	client, err := llmplatform.NewClient(srv.URL, "", "judge-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	judge := llmJudge{client}
+
+	v, err := judge.Judge(gocontext.Background(), "grouped by repo", "## agsh")
+	if err != nil || !v.Passed || v.Reason != "The report is grouped by repo." {
+		t.Errorf("Judge = %+v, %v", v, err)
+	}
+	if v.Usage.Model != "judge-test" || v.Usage.Calls != 1 || v.Usage.Tokens() != 48 {
+		t.Errorf("usage = %+v", v.Usage)
+	}
+	// A reply without a verdict is an error, but its tokens were spent.
+	v, err = judge.Judge(gocontext.Background(), "has totals", "| a | 1 |")
+	if err == nil || v.Usage.Calls != 1 {
+		t.Errorf("Judge = %+v, %v; want an error with usage", v, err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cgast/agsh/pkg/platform"
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+// handlePlan implements `agsh plan <spec.yaml> [--param k=v ...] [--format json|yaml|md] [--output path]`.
+// It generates the execution plan without running anything, so the plan can
+// be reviewed out of band (PR comments, tickets) before `agsh run`.
+func handlePlan(registry *platform.Registry) error {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: agsh plan <spec.yaml> [--param key=value ...] [--format json|yaml|md] [--output path]")
+		return nil
+	}
+
+	format := "md"
+	outputPath := ""
+	args := os.Args[3:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--format" && i+1 < len(args):
+			i++
+			format = args[i]
+		case strings.HasPrefix(args[i], "--format="):
+			format = strings.TrimPrefix(args[i], "--format=")
+		case args[i] == "--output" && i+1 < len(args):
+			i++
+			outputPath = args[i]
+		case strings.HasPrefix(args[i], "--output="):
+			outputPath = strings.TrimPrefix(args[i], "--output=")
+		}
+	}
+
+	plan, err := loadPlan(os.Args[2], parseRunParams(args), registry)
+	if err != nil {
+		return err
+	}
+
+	data, err := renderPlan(plan, format)
+	if err != nil {
+		return err
+	}
+
+	if outputPath == "" {
+		fmt.Print(string(data))
+		return nil
+	}
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("write plan: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Plan written to %s\n", outputPath)
+	return nil
+}
+
+// renderPlan serializes an execution plan in the requested format.
+func renderPlan(plan spec.ExecutionPlan, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("marshal plan: %w", err)
+		}
+		return append(data, '\n'), nil
+	case "yaml":
+		// Round-trip through JSON so YAML keys match the JSON field names.
+		raw, err := json.Marshal(plan)
+		if err != nil {
+			return nil, fmt.Errorf("marshal plan: %w", err)
+		}
+		var generic map[string]any
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return nil, fmt.Errorf("marshal plan: %w", err)
+		}
+		data, err := yaml.Marshal(generic)
+		if err != nil {
+			return nil, fmt.Errorf("marshal plan: %w", err)
+		}
+		return data, nil
+	case "md", "markdown":
+		return []byte(planMarkdown(plan)), nil
+	}
+	return nil, fmt.Errorf("unknown plan format %q (expected json, yaml, or md)", format)
+}
+
+// planMarkdown renders a plan as a markdown document suitable for review.
+func planMarkdown(plan spec.ExecutionPlan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Execution Plan: %s\n\n", plan.Spec)
+	fmt.Fprintf(&b, "**Risk:** %s\n\n", plan.EstimatedRisk)
+
+	b.WriteString("## Steps\n\n")
+	b.WriteString("| # | Command | Args | Risk | Checkpoint | Intent |\n")
+	b.WriteString("|---|---------|------|------|------------|--------|\n")
+	for i, step := range plan.Steps {
+		checkpoint := ""
+		if step.CheckpointBefore {
+			checkpoint = "yes"
+		}
+		fmt.Fprintf(&b, "| %d | `%s` | %s | %s | %s | %s |\n",
+			i+1, step.Command, mdCell(strings.Join(step.Args, " ")), step.Risk, checkpoint, mdCell(step.Intent))
+	}
+
+	if len(plan.SuccessCriteria) > 0 {
+		b.WriteString("\n## Success Criteria\n\n")
+		for _, a := range plan.SuccessCriteria {
+			line := fmt.Sprintf("- `%s`", a.Type)
+			if a.Target != "" {
+				line += fmt.Sprintf(" on `%s`", a.Target)
+			}
+			if a.Expected != nil {
+				line += fmt.Sprintf(": `%v`", a.Expected)
+			}
+			if a.Message != "" {
+				line += " — " + a.Message
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	if outputs := plan.Output.Declared(); len(outputs) > 0 {
+		b.WriteString("\n## Output\n\n")
+		for _, a := range outputs {
+			fmt.Fprintf(&b, "- %s: `%s` (%s)\n", a.Name, a.Path, a.Format)
+		}
+	}
+	return b.String()
+}
+
+// mdCell escapes a value for use inside a markdown table cell.
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -6,50 +6,52 @@ import (
 	"fmt"
 	gocontext "context"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cgast/agsh/pkg/agsh"
 	agshctx "github.com/cgast/agsh/pkg/context"
 	"github.com/cgast/agsh/pkg/events"
 	"github.com/cgast/agsh/pkg/platform"
+	"github.com/cgast/agsh/pkg/spec"
+	"github.com/cgast/agsh/pkg/verify"
 )
 
-// registryExecutor adapts a platform.Registry into a context.CommandExecutor.
-type registryExecutor struct {
-	registry *platform.Registry
-}
-
-func (e *registryExecutor) Execute(ctx gocontext.Context, name string, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
-	cmd, err := e.registry.Resolve(name)
-	if err != nil {
-		return agshctx.Envelope{}, err
-	}
-	return cmd.Execute(ctx, input, store)
-}
+// replSession holds the mutable state of an interactive REPL session.
+type replSession struct {
+	registry  *platform.Registry
+	store     agshctx.ContextStore
+	bus       *events.MemoryBus
+	cpMgr     verify.CheckpointManager
+	executor  *agsh.Executor
+	publisher *agsh.Publisher
+	scanner   *bufio.Scanner
+	limits    spec.Limits // run limits for specs without their own
+	loadOpts  []spec.LoadOption
 
-// eventBusPublisher adapts events.EventBus into a context.EventPublisher.
-type eventBusPublisher struct {
-	bus events.EventBus
+	// last is the output envelope of the most recent pipeline ($last).
+	last    agshctx.Envelope
+	hasLast bool
 }
 
-func (p *eventBusPublisher) PublishPipelineEvent(eventType string, data any, stepIndex int, duration time.Duration) {
-	p.bus.Publish(events.Event{
-		Type:      events.EventType(eventType),
-		Timestamp: time.Now(),
-		Data:      data,
-		StepIndex: stepIndex,
-		Duration:  duration,
-	})
-}
-
-func runInteractiveREPL(registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus) {
+func runInteractiveREPL(registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cpMgr verify.CheckpointManager, limits spec.Limits, loadOpts []spec.LoadOption) {
 	fmt.Println("agsh v0.1.0 — Agent Shell")
 	fmt.Println("Type 'help' for available commands, 'exit' to quit.")
 	fmt.Println()
 
 	scanner := bufio.NewScanner(os.Stdin)
-	executor := &registryExecutor{registry: registry}
-	publisher := &eventBusPublisher{bus: bus}
+	sess := &replSession{
+		registry:  registry,
+		store:     store,
+		bus:       bus,
+		cpMgr:     cpMgr,
+		executor:  &agsh.Executor{Registry: registry},
+		publisher: &agsh.Publisher{Bus: bus},
+		scanner:   scanner,
+		limits:    limits,
+		loadOpts:  loadOpts,
+	}
 
 	for {
 		fmt.Print("agsh> ")
@@ -72,8 +74,18 @@ func runInteractiveREPL(registry *platform.Registry, store agshctx.ContextStore,
 			printCommands(registry)
 		case strings.HasPrefix(line, "context "):
 			handleContext(line, store)
+		case line == "verify" || strings.HasPrefix(line, "verify "):
+			sess.handleVerify(line)
+		case line == "checkpoint" || strings.HasPrefix(line, "checkpoint "):
+			sess.handleCheckpoint(line)
+		case line == "history" || strings.HasPrefix(line, "history "):
+			sess.handleHistory(line)
+		case line == "run" || strings.HasPrefix(line, "run "):
+			sess.handleRunSpec(line)
+		case line == "plan" || strings.HasPrefix(line, "plan "):
+			sess.handlePlanSpec(line)
 		default:
-			executeLine(line, executor, store, publisher)
+			sess.executeLine(line)
 		}
 	}
 }
@@ -85,10 +97,20 @@ func printHelp(registry *platform.Registry) {
 	fmt.Println("  context list      List context store contents")
 	fmt.Println("  context get S K   Get a value from scope S, key K")
 	fmt.Println("  context set S K V Set a value in scope S, key K")
+	fmt.Println("  context history S [K]  Show how keys in scope S changed")
+	fmt.Println("  verify TYPE [EXP] Verify $last against an assertion (e.g. verify contains ## )")
+	fmt.Println("  checkpoint save N Save a checkpoint of the context store")
+	fmt.Println("  checkpoint restore N [--additive]  Restore a named checkpoint")
+	fmt.Println("  checkpoint list   List saved checkpoints")
+	fmt.Println("  history [n]       Show the last n events (default 20)")
+	fmt.Println("  plan SPEC [--param k=v]  Show the execution plan for a spec")
+	fmt.Println("  run SPEC [--param k=v]   Plan, approve, and execute a spec")
 	fmt.Println("  exit              Exit the shell")
 	fmt.Println()
 	fmt.Println("Pipeline syntax:")
 	fmt.Println("  command1 arg | command2 arg   Pipe envelope between commands")
+	fmt.Println("  pipeline > file               Write the final output to file")
+	fmt.Println("  pipeline >> file              Append the final output to file")
 	fmt.Println()
 	fmt.Println("Registered platform commands:")
 	for _, cmd := range registry.List("") {
@@ -110,7 +132,7 @@ func printCommands(registry *platform.Registry) {
 func handleContext(line string, store agshctx.ContextStore) {
 	parts := strings.Fields(line)
 	if len(parts) < 2 {
-		fmt.Println("Usage: context [list|get|set] ...")
+		fmt.Println("Usage: context [list|get|set|history] ...")
 		return
 	}
 
@@ -149,17 +171,39 @@ func handleContext(line string, store agshctx.ContextStore) {
 			return
 		}
 		val := strings.Join(parts[4:], " ")
-		if err := store.Set(parts[2], parts[3], val); err != nil {
+		if err := agshctx.WithSource(store, "repl").Set(parts[2], parts[3], val); err != nil {
 			fmt.Printf("error: %v\n", err)
 			return
 		}
 		fmt.Println("OK")
+	case "history":
+		if len(parts) < 3 {
+			fmt.Println("Usage: context history <scope> [key]")
+			return
+		}
+		key := ""
+		if len(parts) >= 4 {
+			key = parts[3]
+		}
+		entries, err := agshctx.KeyHistory(store, parts[2], key)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		printHistory(entries)
 	default:
-		fmt.Println("Usage: context [list|get|set] ...")
+		fmt.Println("Usage: context [list|get|set|history] ...")
 	}
 }
 
-func executeLine(line string, executor *registryExecutor, store agshctx.ContextStore, publisher *eventBusPublisher) {
+func (s *replSession) executeLine(line string) {
+	// Split off a trailing "> file" or ">> file" redirection.
+	line, redirectPath, appendMode, err := parseRedirect(line)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+
 	// Parse pipeline: command1 arg1 arg2 | command2 arg1
 	segments := strings.Split(line, "|")
 
@@ -181,6 +225,9 @@ func executeLine(line string, executor *registryExecutor, store agshctx.ContextS
 	if len(steps) == 0 {
 		return
 	}
+	if !s.confirmPipeline(steps) {
+		return
+	}
 
 	// Build input envelope from first command's args.
 	var input agshctx.Envelope
@@ -193,9 +240,13 @@ func executeLine(line string, executor *registryExecutor, store agshctx.ContextS
 
 	pipeline := &agshctx.Pipeline{
 		Steps:    steps,
-		Context:  store,
-		Executor: executor,
-		Events:   publisher,
+		Context:  s.store,
+		Executor: s.executor,
+		Events:   s.publisher,
+		Asker:    newRunAsker(s.bus, s.scanner, false), // for policy approvals
+	}
+	if s.cpMgr != nil {
+		pipeline.Checkpointer = &agsh.Checkpointer{Manager: s.cpMgr, Store: s.store}
 	}
 
 	ctx := gocontext.Background()
@@ -205,11 +256,322 @@ func executeLine(line string, executor *registryExecutor, store agshctx.ContextS
 		return
 	}
 
+	// Remember the output as $last for verify and friends.
+	s.last = result.Output
+	s.hasLast = true
+
+	if redirectPath != "" {
+		if err := s.redirectOutput(ctx, result.Output, redirectPath, appendMode); err != nil {
+			fmt.Printf("error: %v\n", err)
+		}
+		return
+	}
+
 	// Display output.
 	output := result.Output
 	displayEnvelope(output)
 }
 
+// confirmPipeline classifies the steps of a typed pipeline as the planner
+// does, the first by the payload its arguments form, checkpointing before
+// write steps, and when a step writes shows the pipeline as a plan and asks
+// for approval unless approval.mode lets it run unattended. It reports
+// whether the pipeline may run.
+func (s *replSession) confirmPipeline(steps []agshctx.PipelineStep) bool {
+	plan := spec.ExecutionPlan{Spec: "repl"}
+	lister := &agsh.Lister{Registry: s.registry}
+	for i := range steps {
+		var payload any
+		if i == 0 && len(steps[0].Args) > 0 {
+			payload = strings.Join(steps[0].Args, " ")
+		}
+		steps[i].Risk = spec.CommandRisk(steps[i].Command, payload, lister)
+		steps[i].CheckpointBefore = steps[i].Risk != "read-only"
+		plan.Steps = append(plan.Steps, spec.PlanStep{
+			Command:          steps[i].Command,
+			Args:             steps[i].Args,
+			Intent:           fmt.Sprintf("Run %s", steps[i].Command),
+			Risk:             steps[i].Risk,
+			CheckpointBefore: steps[i].CheckpointBefore,
+		})
+	}
+	if !agsh.PlanHasWrites(plan) {
+		return true
+	}
+	if auto, _ := autoApprove(plan, currentApprovalPolicy(), ""); auto {
+		return true
+	}
+
+	plan.EstimatedRisk = spec.RiskSummary(plan.Steps)
+	fmt.Fprintf(os.Stderr, "\n=== Pipeline Plan ===\n")
+	displayPlan(plan)
+	if !stdinIsTerminal() {
+		fmt.Println("error: pipeline has write steps and needs approval, but stdin is not a terminal (set approval.mode: never to run it unattended)")
+		return false
+	}
+	if _, ok := approvePlan(s.scanner, s.bus, plan, currentApprovalPolicy()); !ok {
+		fmt.Fprintln(os.Stderr, "Execution cancelled.")
+		return false
+	}
+	return true
+}
+
+// parseRedirect splits a trailing `> path` or `>> path` off a pipeline line.
+// The operator must start a token outside quotes and be followed by exactly
+// one more token, so a ">" inside an argument or JSON is left alone. It
+// returns the remaining pipeline, the target path (empty when there is no
+// redirection), and whether the output should be appended; an operator
+// without a path is an error.
+func parseRedirect(line string) (string, string, bool, error) {
+	trimmed := strings.TrimRight(line, " \t")
+	op := -1
+	var quote rune
+	for i, r := range trimmed {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '>' && (i == 0 || trimmed[i-1] == ' ' || trimmed[i-1] == '\t'):
+			op = i
+		}
+	}
+	if op < 0 || quote != 0 {
+		return line, "", false, nil
+	}
+
+	rest := trimmed[op+1:]
+	appendMode := strings.HasPrefix(rest, ">")
+	if appendMode {
+		rest = rest[1:]
+	}
+	path := strings.TrimLeft(rest, " \t")
+	switch {
+	case path == "":
+		return line, "", false, fmt.Errorf("redirection requires a file path")
+	case strings.HasPrefix(path, ">"):
+		return line, "", false, fmt.Errorf("invalid redirection %q", trimmed[op:])
+	case strings.ContainsAny(path, " \t|\"'"):
+		return line, "", false, nil // not a trailing redirection
+	}
+	return strings.TrimSpace(trimmed[:op]), path, appendMode, nil
+}
+
+// redirectOutput routes the final envelope through fs:write so the sandbox
+// applies exactly as it would inside a pipeline; `>>` uses its append mode.
+func (s *replSession) redirectOutput(ctx gocontext.Context, env agshctx.Envelope, path string, appendMode bool) error {
+	args := map[string]any{
+		"path":    path,
+		"content": envelopeText(env),
+	}
+	if appendMode {
+		args["mode"] = "append"
+	}
+	input := agshctx.NewEnvelope(args, "application/json", "repl")
+	out, err := s.executor.Execute(ctx, "fs:write", input, s.store)
+	if err != nil {
+		return err
+	}
+	if m, ok := out.Payload.(map[string]any); ok {
+		fmt.Printf("Wrote %v bytes to %v\n", m["bytes_written"], m["path"])
+	}
+	return nil
+}
+
+// envelopeText renders an envelope payload as file content: strings are
+// written verbatim, everything else as indented JSON.
+func envelopeText(env agshctx.Envelope) string {
+	switch v := env.Payload.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	data, err := json.MarshalIndent(env.Payload, "", "  ")
+	if err != nil {
+		return env.PayloadString()
+	}
+	return string(data) + "\n"
+}
+
+// handleVerify implements `verify [target=T] <type> [expected...]` against $last.
+func (s *replSession) handleVerify(line string) {
+	assertion, err := parseAssertionExpr(strings.TrimSpace(strings.TrimPrefix(line, "verify")))
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		fmt.Println("Usage: verify [target=T] <type> [expected]")
+		return
+	}
+	if !s.hasLast {
+		fmt.Println("error: nothing to verify; run a pipeline first")
+		return
+	}
+
+	s.bus.Publish(events.NewEvent(events.EventVerifyStart, map[string]any{
+		"source":     "repl",
+		"assertions": 1,
+	}))
+
+	intent := verify.Intent{Description: "repl verify", Assertions: []verify.Assertion{assertion}}
+	vResult, err := verify.NewEngine(verify.WithContextStore(s.store)).VerifyContext(gocontext.Background(), s.last, intent)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+
+	s.bus.Publish(events.NewEvent(events.EventVerifyResult, map[string]any{
+		"source":     "repl",
+		"passed":     vResult.Passed,
+		"assertions": vResult.Details(),
+	}))
+
+	for _, ar := range vResult.Results {
+		status := "PASS"
+		if !ar.Passed {
+			status = "FAIL"
+		}
+		msg := ar.Message
+		if msg == "" {
+			msg = "ok"
+		}
+		fmt.Printf("[%s] %s: %s\n", status, ar.Assertion.Type, msg)
+	}
+}
+
+// parseAssertionExpr parses a REPL assertion expression of the form
+// `[target=T] <type> [expected...]`. Surrounding quotes on the expected
+// value are stripped, and numeric-looking values stay strings since the
+// checkers convert as needed.
+func parseAssertionExpr(expr string) (verify.Assertion, error) {
+	var a verify.Assertion
+	rest := strings.TrimSpace(expr)
+	if strings.HasPrefix(rest, "target=") {
+		target, after, _ := strings.Cut(rest, " ")
+		a.Target = strings.TrimPrefix(target, "target=")
+		rest = strings.TrimSpace(after)
+	}
+	typ, expected, _ := strings.Cut(rest, " ")
+	if typ == "" {
+		return verify.Assertion{}, fmt.Errorf("missing assertion type")
+	}
+	if verify.GetChecker(typ) == nil {
+		return verify.Assertion{}, fmt.Errorf("unknown assertion type %q", typ)
+	}
+	a.Type = typ
+	if expected = strings.TrimSpace(expected); expected != "" {
+		if unquoted, err := strconv.Unquote(expected); err == nil {
+			expected = unquoted
+		}
+		a.Expected = expected
+	}
+	return a, nil
+}
+
+// handleCheckpoint implements `checkpoint save|restore|list [name]`.
+func (s *replSession) handleCheckpoint(line string) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 {
+		fmt.Println("Usage: checkpoint [save|restore|list] [name]")
+		return
+	}
+	if s.cpMgr == nil {
+		fmt.Println("error: checkpoint manager not available")
+		return
+	}
+
+	switch parts[1] {
+	case "save":
+		if len(parts) < 3 {
+			fmt.Println("Usage: checkpoint save <name>")
+			return
+		}
+		snap, err := verify.CaptureSnapshot(s.store, "")
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		if err := s.cpMgr.Save(parts[2], snap); err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		s.bus.Publish(events.NewEvent(events.EventCheckpointSave, map[string]any{
+			"name": parts[2],
+		}))
+		fmt.Printf("Saved checkpoint %q\n", parts[2])
+	case "restore":
+		if len(parts) < 3 {
+			fmt.Println("Usage: checkpoint restore <name> [--additive]")
+			return
+		}
+		snap, err := s.cpMgr.Restore(parts[2])
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		var opts []verify.RestoreOption
+		if len(parts) > 3 && parts[3] == "--additive" {
+			opts = append(opts, verify.WithAdditiveRestore())
+		}
+		if err := verify.RestoreSnapshot(s.store, snap, opts...); err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		s.bus.Publish(events.NewEvent(events.EventCheckpointRestore, map[string]any{
+			"name": parts[2],
+		}))
+		fmt.Printf("Restored checkpoint %q\n", parts[2])
+	case "list":
+		infos, err := s.cpMgr.List()
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		if len(infos) == 0 {
+			fmt.Println("(no checkpoints)")
+			return
+		}
+		for _, info := range infos {
+			fmt.Printf("  %-30s %s\n", info.Name, info.Timestamp.Format(time.RFC3339))
+		}
+	default:
+		fmt.Println("Usage: checkpoint [save|restore|list] [name]")
+	}
+}
+
+// handleHistory implements `history [n]`, printing the last n events.
+func (s *replSession) handleHistory(line string) {
+	n := 20
+	parts := strings.Fields(line)
+	if len(parts) >= 2 {
+		v, err := strconv.Atoi(parts[1])
+		if err != nil || v <= 0 {
+			fmt.Println("Usage: history [n]")
+			return
+		}
+		n = v
+	}
+
+	history := s.bus.History(time.Time{})
+	if len(history) > n {
+		history = history[len(history)-n:]
+	}
+	if len(history) == 0 {
+		fmt.Println("(no events)")
+		return
+	}
+	for _, ev := range history {
+		data := ""
+		if ev.Data != nil {
+			if b, err := json.Marshal(ev.Data); err == nil {
+				data = string(b)
+			}
+		}
+		fmt.Printf("  %s  %-24s %s\n", ev.Timestamp.Format("15:04:05"), ev.Type, data)
+	}
+}
+
 func displayEnvelope(env agshctx.Envelope) {
 	switch v := env.Payload.(type) {
 	case string:
@@ -228,3 +590,55 @@ func displayEnvelope(env agshctx.Envelope) {
 		fmt.Println(string(data))
 	}
 }
+
+// handleRunSpec implements `run <spec.yaml> [--param k=v ...]` inside the REPL,
+// reusing the session's registry, store, and event bus.
+func (s *replSession) handleRunSpec(line string) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 {
+		fmt.Println("Usage: run <spec.yaml> [--param key=value ...]")
+		return
+	}
+
+	projSpec, plan, err := loadSpecAndPlan(parts[1], parseRunParams(parts[2:]), s.registry, s.loadOpts...)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+
+	applyLimits(&plan, s.limits)
+	fmt.Fprintf(os.Stderr, "\n=== Execution Plan ===\n")
+	displayPlan(plan)
+
+	decisions, ok := approvePlan(s.scanner, s.bus, plan, currentApprovalPolicy())
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Execution cancelled.")
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\n=== Executing ===\n")
+	rec := startRunRecord(&projSpec, parts[1], plan, s.bus)
+	rec.writeApprovals(decisions)
+	asker := newRunAsker(s.bus, s.scanner, false)
+	if err := executePlan(plan, s.registry, s.store, s.bus, s.cpMgr, asker, "", rec); err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+}
+
+// handlePlanSpec implements `plan <spec.yaml> [--param k=v ...]` inside the REPL.
+func (s *replSession) handlePlanSpec(line string) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 {
+		fmt.Println("Usage: plan <spec.yaml> [--param key=value ...]")
+		return
+	}
+
+	plan, err := loadPlan(parts[1], parseRunParams(parts[2:]), s.registry, s.loadOpts...)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\n=== Execution Plan ===\n")
+	displayPlan(plan)
+}
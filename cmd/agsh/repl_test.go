@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestParseRedirect(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantLine   string
+		wantPath   string
+		wantAppend bool
+		wantErr    bool
+	}{
+		{line: "fs:list .", wantLine: "fs:list ."},
+		{line: "fs:list . > out.txt", wantLine: "fs:list .", wantPath: "out.txt"},
+		{line: "fs:list . >> out.txt  ", wantLine: "fs:list .", wantPath: "out.txt", wantAppend: true},
+		{line: "fs:list . >out.txt", wantLine: "fs:list .", wantPath: "out.txt"},
+		{line: "fs:list . >>out.txt", wantLine: "fs:list .", wantPath: "out.txt", wantAppend: true},
+		{line: "fs:list | data:hash > a/b.json", wantLine: "fs:list | data:hash", wantPath: "a/b.json"},
+
+		// A ">" that is not a trailing, unquoted token is an argument.
+		{line: "data:filter a>b", wantLine: "data:filter a>b"},
+		{line: `data:filter {"op":">","x":1}`, wantLine: `data:filter {"op":">","x":1}`},
+		{line: `data:filter "> out.txt"`, wantLine: `data:filter "> out.txt"`},
+		{line: "data:filter > 3 items", wantLine: "data:filter > 3 items"},
+		{line: "fs:list > out.txt | data:hash", wantLine: "fs:list > out.txt | data:hash"},
+		{line: `data:filter {"op":">"} > out.txt`, wantLine: `data:filter {"op":">"}`, wantPath: "out.txt"},
+
+		{line: "fs:list . >", wantErr: true},
+		{line: "fs:list . >> ", wantErr: true},
+		{line: "fs:list . >>> out.txt", wantErr: true},
+	}
+	for _, tt := range tests {
+		line, path, appendMode, err := parseRedirect(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseRedirect(%q): expected an error", tt.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRedirect(%q): %v", tt.line, err)
+			continue
+		}
+		if line != tt.wantLine || path != tt.wantPath || appendMode != tt.wantAppend {
+			t.Errorf("parseRedirect(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.line, line, path, appendMode, tt.wantLine, tt.wantPath, tt.wantAppend)
+		}
+	}
+}
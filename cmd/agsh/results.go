@@ -0,0 +1,65 @@
+package main
+
+import (
+	"maps"
+
+	"github.com/cgast/agsh/internal/config"
+	"github.com/cgast/agsh/internal/sandbox"
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+// maxResultSize returns agent.max_result_size in bytes; 0 means no limit.
+func maxResultSize(cfg config.AgentConfig) int {
+	if cfg.MaxResultSize == "" {
+		return 0
+	}
+	n, _ := sandbox.ParseFileSize(cfg.MaxResultSize) // validated with the config
+	return int(n)
+}
+
+// limitExecuteResult truncates the payload of an execute result that
+// exceeds the maximum result size, or with summarize replaces it with its
+// summary, recording which as "truncated" or "summarized" in a copy of its
+// meta so a cached result is not modified.
+func limitExecuteResult(results *protocol.ResultStore, res protocol.ExecuteResult, p protocol.ExecuteParams) protocol.ExecuteResult {
+	key := "summarized"
+	var note any = true
+	if p.Summarize {
+		res.Payload = results.Summarize(res.Payload)
+	} else {
+		payload, tr := results.Limit(res.Payload, p.MaxResultSize)
+		if tr == nil {
+			return res
+		}
+		res.Payload = payload
+		key, note = "truncated", tr
+	}
+	res.Meta = maps.Clone(res.Meta)
+	if res.Meta == nil {
+		res.Meta = make(map[string]any)
+	}
+	res.Meta[key] = note
+	return res
+}
+
+// limitOutput does the same for the "output" of a pipeline or project run
+// response, recording it at the top level of a copy of the response.
+func limitOutput(results *protocol.ResultStore, response map[string]any, maxSize int, summarize bool) map[string]any {
+	if _, ok := response["output"]; !ok {
+		return response
+	}
+	if summarize {
+		response = maps.Clone(response)
+		response["output"] = results.Summarize(response["output"])
+		response["summarized"] = true
+		return response
+	}
+	output, tr := results.Limit(response["output"], maxSize)
+	if tr == nil {
+		return response
+	}
+	response = maps.Clone(response)
+	response["output"] = output
+	response["truncated"] = tr
+	return response
+}
@@ -2,13 +2,23 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"encoding/json"
 	gocontext "context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/cgast/agsh/internal/config"
+	"github.com/cgast/agsh/internal/sandbox"
+	"github.com/cgast/agsh/internal/storeserver"
+	"github.com/cgast/agsh/pkg/agsh"
 	agshctx "github.com/cgast/agsh/pkg/context"
 	"github.com/cgast/agsh/pkg/events"
 	"github.com/cgast/agsh/pkg/platform"
@@ -16,69 +26,124 @@ import (
 	"github.com/cgast/agsh/pkg/verify"
 )
 
-// registryLister adapts platform.Registry to spec.CommandLister.
-type registryLister struct {
-	registry *platform.Registry
-}
+// handleRun implements `agsh run <spec.yaml> [--param key=value ...] [--yes|--approve=mode] [--output format]`
+// and `agsh run --resume <run-id> ...`. With --output, a run summary is
+// printed instead of the final payload.
+func handleRun(registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cfg config.Config, cpMgr verify.CheckpointManager) error {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: agsh run <spec.yaml> [--param key=value ...] [--yes | --approve=plan|destructive|never] [--output json|yaml|md|quiet]")
+		fmt.Println("       agsh run --resume <run-id|latest> [--yes | --approve=...] [--output ...]")
+		return nil
+	}
 
-func (l *registryLister) Names() []string {
-	return l.registry.Names()
-}
+	approveFlag, err := parseApproveFlag(os.Args[2:])
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	output, err := parseOutputFlag(os.Args[2:])
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+
+	resumeID, resuming, err := parseResumeFlag(os.Args[2:])
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
 
-func (l *registryLister) MatchGlob(pattern string) []string {
-	cmds := l.registry.MatchGlob(pattern)
-	names := make([]string, len(cmds))
-	for i, cmd := range cmds {
-		names[i] = cmd.Name()
+	var (
+		projSpec spec.ProjectSpec
+		plan     spec.ExecutionPlan
+		rec      *runRecord
+	)
+	if resuming {
+		rec, plan, err = resumeRunRecord(resumeID, bus)
+		if err != nil {
+			return fmt.Errorf("resume: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Resuming run %s at step %d/%d\n", rec.id(), len(rec.completed)+1, len(plan.Steps))
+	} else {
+		projSpec, plan, err = loadSpecAndPlan(os.Args[2], parseRunParams(os.Args[3:]), registry, specLoadOptions(cfg.Sandbox)...)
+		if err != nil {
+			return err
+		}
 	}
-	return names
+
+	applyLimits(&plan, configLimits(cfg.Executor))
+
+	// Display plan.
+	fmt.Fprintf(os.Stderr, "\n=== Execution Plan ===\n")
+	displayPlan(plan)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	decisions, err := approveRun(scanner, bus, plan, cfg.Approval, approveFlag)
+	if err != nil {
+		return withExitCode(exitNotApproved, err)
+	}
+
+	// Execute the plan as a pipeline.
+	fmt.Fprintf(os.Stderr, "\n=== Executing ===\n")
+	if !resuming {
+		rec = startRunRecord(&projSpec, os.Args[2], plan, bus)
+	}
+	rec.writeApprovals(decisions)
+	asker := newRunAsker(bus, scanner, detectInspectorPort(cfg) > 0)
+	return executePlan(plan, registry, store, bus, cpMgr, asker, output, rec)
 }
 
-// handleRun implements `agsh run <spec.yaml> [--param key=value ...]`.
-func handleRun(registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus) error {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: agsh run <spec.yaml> [--param key=value ...]")
-		return nil
+// parseResumeFlag extracts --resume <id> or --resume=<id> from args.
+func parseResumeFlag(args []string) (string, bool, error) {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--resume":
+			if i+1 >= len(args) || strings.HasPrefix(args[i+1], "-") {
+				return "", false, fmt.Errorf("--resume requires a run id (see agsh runs list)")
+			}
+			return args[i+1], true, nil
+		case strings.HasPrefix(args[i], "--resume="):
+			return strings.TrimPrefix(args[i], "--resume="), true, nil
+		}
 	}
+	return "", false, nil
+}
 
-	specPath := os.Args[2]
-	params := parseRunParams(os.Args[3:])
+// loadPlan loads and validates a spec and generates its execution plan.
+// Its errors exit with exitSpecInvalid.
+func loadPlan(specPath string, params map[string]string, registry *platform.Registry, opts ...spec.LoadOption) (spec.ExecutionPlan, error) {
+	_, plan, err := loadSpecAndPlan(specPath, params, registry, opts...)
+	return plan, err
+}
 
-	// Load and validate spec.
+// loadSpecAndPlan is loadPlan, also returning the resolved spec.
+func loadSpecAndPlan(specPath string, params map[string]string, registry *platform.Registry, opts ...spec.LoadOption) (spec.ProjectSpec, spec.ExecutionPlan, error) {
 	fmt.Fprintf(os.Stderr, "Loading spec: %s\n", specPath)
-	projSpec, err := spec.LoadSpec(specPath, params)
+	projSpec, err := spec.LoadSpec(specPath, params, opts...)
 	if err != nil {
-		return fmt.Errorf("load spec: %w", err)
+		return projSpec, spec.ExecutionPlan{}, withExitCode(exitSpecInvalid, fmt.Errorf("load spec: %w", err))
 	}
 
 	vr := spec.ValidateSpec(projSpec)
 	if !vr.Valid() {
-		return fmt.Errorf("spec validation failed:\n  %s", strings.Join(validationMessages(vr), "\n  "))
+		return projSpec, spec.ExecutionPlan{}, withExitCode(exitSpecInvalid, fmt.Errorf("spec validation failed:\n  %s", strings.Join(validationMessages(vr), "\n  ")))
 	}
 
 	fmt.Fprintf(os.Stderr, "Spec: %s — %s\n", projSpec.Meta.Name, projSpec.Meta.Description)
 	fmt.Fprintf(os.Stderr, "Goal: %s\n", strings.TrimSpace(projSpec.Goal))
 
-	// Generate plan.
-	lister := &registryLister{registry: registry}
-	plan, err := spec.GeneratePlan(projSpec, lister)
+	plan, err := agsh.PlanSpec(projSpec, specPath, registry, opts...)
 	if err != nil {
-		return fmt.Errorf("generate plan: %w", err)
+		return projSpec, spec.ExecutionPlan{}, withExitCode(exitSpecInvalid, fmt.Errorf("generate plan: %w", err))
 	}
+	return projSpec, plan, nil
+}
 
-	// Display plan.
-	fmt.Fprintf(os.Stderr, "\n=== Execution Plan ===\n")
-	displayPlan(plan)
-
-	// Ask for approval (interactive only).
-	if !approveExecution() {
-		fmt.Fprintln(os.Stderr, "Execution cancelled.")
+// specLoadOptions returns the options specs are run with: the paths of
+// their file and dir params must be allowed by the sandbox cfg describes.
+func specLoadOptions(cfg config.SandboxConfig) []spec.LoadOption {
+	sb, err := newSandbox(cfg)
+	if err != nil || sb == nil {
 		return nil
 	}
-
-	// Execute the plan as a pipeline.
-	fmt.Fprintf(os.Stderr, "\n=== Executing ===\n")
-	return executePlan(plan, registry, store, bus)
+	return []spec.LoadOption{spec.WithPathCheck(sb.CheckPath)}
 }
 
 // parseRunParams extracts --param key=value pairs from args.
@@ -100,6 +165,62 @@ func parseRunParams(args []string) map[string]string {
 	return params
 }
 
+// parseApproveFlag extracts --yes/-y or --approve=<mode> from args.
+// --yes is shorthand for --approve=never. Returns "" when neither is set.
+func parseApproveFlag(args []string) (string, error) {
+	mode := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--yes" || args[i] == "-y":
+			mode = "never"
+		case args[i] == "--approve" && i+1 < len(args):
+			i++
+			mode = args[i]
+		case strings.HasPrefix(args[i], "--approve="):
+			mode = strings.TrimPrefix(args[i], "--approve=")
+		}
+	}
+	switch mode {
+	case "", "plan", "destructive", "never":
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid --approve mode %q (expected plan, destructive, or never)", mode)
+}
+
+// autoApprove decides whether a plan may run without an interactive prompt.
+// The flag overrides the configured approval mode, but plans containing write
+// or destructive steps are only auto-approved when the config opts in via
+// approval.mode "never" or approval.allow_auto_destructive.
+func autoApprove(plan spec.ExecutionPlan, cfg config.ApprovalConfig, flag string) (bool, error) {
+	mode := cfg.Mode
+	if flag != "" {
+		mode = flag
+	}
+
+	mutating := agsh.PlanHasWrites(plan)
+	auto := false
+	switch mode {
+	case "never":
+		auto = true
+	case "destructive":
+		auto = !mutating
+	}
+
+	if auto && mutating && flag != "" && cfg.Mode != "never" && !cfg.AllowAutoDestructive {
+		return false, fmt.Errorf("refusing to auto-approve a plan with write steps; set approval.allow_auto_destructive: true in .agsh/config.yaml to permit it")
+	}
+	return auto, nil
+}
+
+// stdinIsTerminal reports whether stdin is attached to a terminal.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
 // displayPlan prints a human-readable representation of the execution plan.
 func displayPlan(plan spec.ExecutionPlan) {
 	fmt.Fprintf(os.Stderr, "Spec: %s\n", plan.Spec)
@@ -114,21 +235,67 @@ func displayPlan(plan spec.ExecutionPlan) {
 		if len(step.Args) > 0 {
 			args = " " + strings.Join(step.Args, " ")
 		}
-		fmt.Fprintf(os.Stderr, "  %d. %s%s (%s)%s\n", i+1, step.Command, args, step.Risk, checkpoint)
+		id := ""
+		if step.ID != "" {
+			id = step.ID + ": "
+		}
+		fmt.Fprintf(os.Stderr, "  %d. %s%s%s (%s)%s\n", i+1, id, step.Command, args, step.Risk, checkpoint)
 		fmt.Fprintf(os.Stderr, "     Intent: %s\n", step.Intent)
+		if deps := planStepDeps(step); len(deps) > 0 {
+			fmt.Fprintf(os.Stderr, "     Needs: %s\n", strings.Join(deps, ", "))
+		}
 	}
 	if len(plan.SuccessCriteria) > 0 {
 		fmt.Fprintf(os.Stderr, "Success criteria: %d assertion(s)\n", len(plan.SuccessCriteria))
 	}
-	if plan.Output.Path != "" {
-		fmt.Fprintf(os.Stderr, "Output: %s (%s)\n", plan.Output.Path, plan.Output.Format)
+	for _, a := range plan.Output.Declared() {
+		label := "Output"
+		if a.Name != spec.MainOutput {
+			label += " " + a.Name
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s (%s)\n", label, a.Path, a.Format)
+	}
+	if plan.Limits.MaxDuration != "" {
+		fmt.Fprintf(os.Stderr, "Deadline: %s (then %s)\n", plan.Limits.MaxDuration, cmp.Or(plan.Limits.OnTimeout, "stop"))
+	}
+}
+
+// configLimits returns the run limits config sets for specs without their
+// own.
+func configLimits(cfg config.ExecutorConfig) spec.Limits {
+	return spec.Limits{MaxDuration: cfg.MaxRunDuration, OnTimeout: cfg.OnRunTimeout}
+}
+
+// applyLimits fills the limits a plan's spec leaves unset from defaults.
+// A spec can shorten the configured deadline but not extend it.
+func applyLimits(plan *spec.ExecutionPlan, defaults spec.Limits) {
+	specMax, _ := time.ParseDuration(plan.Limits.MaxDuration) // validated with the spec or config
+	configMax, _ := time.ParseDuration(defaults.MaxDuration)
+	if specMax == 0 || configMax > 0 && configMax < specMax {
+		plan.Limits.MaxDuration = defaults.MaxDuration
+	}
+	if plan.Limits.OnTimeout == "" {
+		plan.Limits.OnTimeout = defaults.OnTimeout
+	}
+}
+
+// planStepDeps lists the steps a plan step waits for, from its needs and
+// inputs.
+func planStepDeps(step spec.PlanStep) []string {
+	deps := append([]string(nil), step.Needs...)
+	for _, ref := range step.Inputs {
+		if id, _, err := agshctx.ParseOutputRef(ref); err == nil && !slices.Contains(deps, id) {
+			deps = append(deps, id)
+		}
 	}
+	sort.Strings(deps[len(step.Needs):])
+	return deps
 }
 
-// approveExecution asks the user to approve before executing.
-func approveExecution() bool {
+// approveExecution asks the user to approve before executing, reading the
+// answer from scanner so callers that already own stdin can share it.
+func approveExecution(scanner *bufio.Scanner) bool {
 	fmt.Fprintf(os.Stderr, "\nProceed with execution? [Y/n] ")
-	scanner := bufio.NewScanner(os.Stdin)
 	if !scanner.Scan() {
 		return false
 	}
@@ -136,77 +303,120 @@ func approveExecution() bool {
 	return answer == "" || answer == "y" || answer == "yes"
 }
 
-// checkpointAdapter bridges verify.CheckpointManager + verify.CaptureSnapshot to pipeline.Checkpointer.
-type checkpointAdapter struct {
-	manager verify.CheckpointManager
-	store   agshctx.ContextStore
-	workdir string
-}
+// newCheckpointManager creates the checkpoint manager selected by
+// cfg.Backend, pruning according to the retention settings. It returns nil,
+// after printing a warning, if the manager cannot be created or cfg is
+// invalid.
+func newCheckpointManager(cfg config.CheckpointConfig) verify.CheckpointManager {
+	policy := verify.RetentionPolicy{MaxCount: cfg.MaxCount}
+	if cfg.MaxAge != "" {
+		d, err := time.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: checkpoint.max_age: %v\n", err)
+			return nil
+		}
+		policy.MaxAge = d
+	}
+	if cfg.MaxTotalSize != "" {
+		n, err := sandbox.ParseFileSize(cfg.MaxTotalSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: checkpoint.max_total_size: %v\n", err)
+			return nil
+		}
+		policy.MaxTotalSize = n
+	}
 
-func (c *checkpointAdapter) SaveCheckpoint(name string) error {
-	snap, err := verify.CaptureSnapshot(c.store, c.workdir)
-	if err != nil {
-		return fmt.Errorf("capture snapshot: %w", err)
+	switch cfg.Backend {
+	case "", "bolt":
+		// Shared like the context store, so a run works while a REPL has
+		// the database open.
+		mgr, err := storeserver.OpenCheckpointStore(checkpointStorePath(), verify.WithRetention(policy), verify.WithDeltas(cfg.DeltaLimit))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not open checkpoint store: %v\n", err)
+			return nil
+		}
+		return mgr
+	case "file":
+		mgr, err := verify.NewFileCheckpointManager(statePath(projectState().Data, "checkpoints"), verify.WithRetention(policy))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not create checkpoint manager: %v\n", err)
+			return nil
+		}
+		return mgr
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: unknown checkpoint.backend %q\n", cfg.Backend)
+		return nil
 	}
-	return c.manager.Save(name, snap)
 }
 
-func (c *checkpointAdapter) RestoreCheckpoint(name string) error {
-	snap, err := c.manager.Restore(name)
-	if err != nil {
-		return err
+// saveProject records the plan's spec in the project context.
+func saveProject(store agshctx.ContextStore, plan spec.ExecutionPlan) {
+	project := agshctx.Project{SpecName: plan.Spec, Goal: plan.Goal, OutputPath: plan.Output.Path}
+	if plan.Limits.MaxDuration != "" {
+		project.Budgets = &agshctx.Budgets{MaxDuration: plan.Limits.MaxDuration}
+	}
+	if err := agshctx.SaveProject(store, project); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 	}
-	return verify.RestoreSnapshot(c.store, snap)
 }
 
-// executePlan runs an ExecutionPlan through the pipeline engine.
-func executePlan(plan spec.ExecutionPlan, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus) error {
-	executor := &registryExecutor{registry: registry}
-	publisher := &eventBusPublisher{bus: bus}
-
-	// Convert plan steps to pipeline steps.
-	pipelineSteps := make([]agshctx.PipelineStep, len(plan.Steps))
-	for i, step := range plan.Steps {
-		pipelineSteps[i] = agshctx.PipelineStep{
-			Command:          step.Command,
-			Args:             step.Args,
-			Intent:           step.Intent,
-			OnError:          step.OnError,
-			CheckpointBefore: step.CheckpointBefore,
-		}
-	}
+// executePlan runs an ExecutionPlan through the pipeline engine. asker
+// answers ask steps and may be nil. output selects a run summary format
+// (see renderRunSummary); "" prints the final payload. The run is recorded
+// in rec, which may be nil.
+func executePlan(plan spec.ExecutionPlan, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cpMgr verify.CheckpointManager, asker agshctx.Asker, output string, rec *runRecord) (err error) {
+	executor := &agsh.Executor{Registry: registry}
+	publisher := &agsh.Publisher{Bus: bus}
 
-	// Store spec info in project context.
-	store.Set(agshctx.ScopeProject, "spec_name", plan.Spec)
-	store.Set(agshctx.ScopeProject, "output_path", plan.Output.Path)
+	pipelineSteps := agsh.PipelineSteps(plan)
 
-	// Set up checkpoint manager.
-	cpDir := filepath.Join(os.TempDir(), "agsh-checkpoints", plan.Spec)
-	cpMgr, err := verify.NewFileCheckpointManager(cpDir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: could not create checkpoint manager: %v\n", err)
-	}
+	saveProject(store, plan)
 
 	pipeline := &agshctx.Pipeline{
-		Steps:    pipelineSteps,
-		Context:  store,
-		Executor: executor,
-		Events:   publisher,
+		Steps:       pipelineSteps,
+		Context:     store,
+		Executor:    executor,
+		Events:      publisher,
+		Artifacts:   agshctx.DirArtifactStore{Root: runsDir()},
+		Observer:    rec,
+		ID:          rec.id(),
+		Resume:      rec.resumeFrom(),
+		Specs:       &agsh.SpecRunner{Registry: registry, Store: store, Bus: bus, Checkpoints: cpMgr, Asker: asker},
+		Asker:       asker,
+		Timeout:     agsh.PlanTimeout(plan),
+		OnTimeout:   plan.Limits.OnTimeout,
+		IntentDrift: plan.IntentDrift,
 	}
 
 	if cpMgr != nil {
-		pipeline.Checkpointer = &checkpointAdapter{
-			manager: cpMgr,
-			store:   store,
-			workdir: "",
+		pipeline.Checkpointer = &agsh.Checkpointer{
+			Manager: cpMgr,
+			Store:   store,
 		}
 	}
 
-	ctx := gocontext.Background()
+	// Ctrl-C stops the run between steps, leaving it resumable.
+	ctx, stop := signal.NotifyContext(gocontext.Background(), os.Interrupt)
+	defer stop()
+	if ctx, err = agsh.PlanContext(ctx, plan); err != nil {
+		return err
+	}
 	input := agshctx.NewEnvelope(nil, "text/plain", "run")
 
+	started := time.Now()
+	var vResult *verify.VerificationResult
 	result, err := pipeline.Run(ctx, input)
+	defer func() {
+		summary := newRunSummary(plan, result, vResult, started)
+		summary.RunID = rec.id()
+		rec.writeManifest(plan, result, vResult)
+		rec.writeIntentTrace(plan, result, vResult)
+		rec.finish(result, vResult, summary, err)
+	}()
 	if err != nil {
+		if rec != nil {
+			fmt.Fprintf(os.Stderr, "Resume with: agsh run --resume %s\n", rec.id())
+		}
 		return fmt.Errorf("execution failed: %w", err)
 	}
 
@@ -217,18 +427,33 @@ func executePlan(plan spec.ExecutionPlan, registry *platform.Registry, store ags
 	} else {
 		fmt.Fprintf(os.Stderr, "Execution completed with errors\n")
 	}
+	for _, sr := range result.Steps {
+		for _, d := range sr.Drift {
+			fmt.Fprintf(os.Stderr, "  warning: step %d (%s) drifted from its intent: %s\n", sr.Index+1, sr.Step.Command, d)
+		}
+	}
 
 	// Verify success criteria against final output.
+	var runErr error
 	if len(plan.SuccessCriteria) > 0 {
 		fmt.Fprintf(os.Stderr, "\n=== Verification ===\n")
-		intent := specCriteriaToIntent(plan.SuccessCriteria)
-		engine := verify.NewEngine()
-		vResult, verifyErr := engine.Verify(result.Output, intent)
+		bus.Publish(events.NewEvent(events.EventVerifyStart, map[string]any{
+			"type":       "success_criteria",
+			"assertions": len(plan.SuccessCriteria),
+		}))
+		vr, verifyErr := agsh.VerifyCriteria(ctx, plan, result, store)
 		if verifyErr != nil {
 			return fmt.Errorf("verification error: %w", verifyErr)
 		}
-
-		for _, ar := range vResult.Results {
+		vResult = &vr
+		bus.Publish(events.NewEvent(events.EventVerifyResult, map[string]any{
+			"type":       "success_criteria",
+			"passed":     vr.Passed,
+			"summary":    fmt.Sprintf("%d/%d assertions passed", agsh.CountPassed(vr.Results), len(vr.Results)),
+			"assertions": vr.Details(),
+		}))
+
+		for _, ar := range vr.Results {
 			status := "PASS"
 			if !ar.Passed {
 				status = "FAIL"
@@ -236,52 +461,53 @@ func executePlan(plan spec.ExecutionPlan, registry *platform.Registry, store ags
 			fmt.Fprintf(os.Stderr, "  [%s] %s: %s\n", status, ar.Assertion.Type, ar.Message)
 		}
 
-		if !vResult.Passed {
-			return fmt.Errorf("verification failed: %d/%d assertions passed",
-				countPassed(vResult.Results), len(vResult.Results))
+		if !vr.Passed {
+			runErr = fmt.Errorf("%w: %d/%d assertions passed", agshctx.ErrVerificationFailed,
+				agsh.CountPassed(vr.Results), len(vr.Results))
+			if plan.OnVerifyFailure == "rollback" {
+				name, rbErr := agsh.Rollback(pipeline.Checkpointer, result)
+				if rbErr != nil {
+					runErr = fmt.Errorf("%w (rollback failed: %v)", runErr, rbErr)
+				} else {
+					fmt.Fprintf(os.Stderr, "Rolled back to checkpoint %s.\n", name)
+					runErr = fmt.Errorf("%w (rolled back to checkpoint %s)", runErr, name)
+				}
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "All %d assertions passed.\n", len(vr.Results))
+		}
+		if u := vr.Usage(); u.Calls > 0 || u.Cached > 0 {
+			fmt.Fprintf(os.Stderr, "LLM judge: %s\n", llmUsageText(*llmUsage(u)))
+		}
+	}
+
+	// A run summary is printed even when verification failed, so
+	// automation can see which assertions did.
+	if output != "" {
+		summary := newRunSummary(plan, result, vResult, started)
+		summary.RunID = rec.id()
+		data, err := renderRunSummary(summary, output)
+		if err != nil {
+			return err
 		}
-		fmt.Fprintf(os.Stderr, "All %d assertions passed.\n", len(vResult.Results))
+		os.Stdout.Write(data)
+		return runErr
+	}
+	if runErr != nil {
+		return runErr
 	}
 
 	// Print the final output.
-	output, err := json.MarshalIndent(result.Output.Payload, "", "  ")
+	data, err := json.MarshalIndent(result.Output.Payload, "", "  ")
 	if err != nil {
 		fmt.Println(result.Output.PayloadString())
 	} else {
-		fmt.Println(string(output))
+		fmt.Println(string(data))
 	}
 
 	return nil
 }
 
-// specCriteriaToIntent converts spec assertions to a verify.Intent.
-func specCriteriaToIntent(criteria []spec.Assertion) verify.Intent {
-	assertions := make([]verify.Assertion, len(criteria))
-	for i, c := range criteria {
-		assertions[i] = verify.Assertion{
-			Type:     c.Type,
-			Target:   c.Target,
-			Expected: c.Expected,
-			Message:  c.Message,
-		}
-	}
-	return verify.Intent{
-		Description: "success criteria",
-		Assertions:  assertions,
-	}
-}
-
-// countPassed counts the number of passed assertion results.
-func countPassed(results []verify.AssertionResult) int {
-	n := 0
-	for _, r := range results {
-		if r.Passed {
-			n++
-		}
-	}
-	return n
-}
-
 // validationMessages extracts messages from a ValidationResult.
 func validationMessages(vr spec.ValidationResult) []string {
 	msgs := make([]string, len(vr.Errors))
@@ -300,13 +526,21 @@ func handleValidate() error {
 
 	specPath := os.Args[2]
 	projSpec, err := spec.LoadSpec(specPath, nil)
-	if err != nil {
-		return fmt.Errorf("load spec: %w", err)
+	var vr spec.ValidationResult
+	switch {
+	case errors.As(err, &vr):
+		// The spec has fields of another version than its own, or a file
+		// or dir param names a missing path.
+	case err != nil:
+		return withExitCode(exitSpecInvalid, fmt.Errorf("load spec: %w", err))
+	default:
+		vr = spec.ValidateSpec(projSpec)
 	}
-
-	vr := spec.ValidateSpec(projSpec)
 	if vr.Valid() {
 		fmt.Printf("Spec %q is valid.\n", projSpec.Meta.Name)
+		if projSpec.APIVersion != spec.LatestAPIVersion {
+			fmt.Printf("It is in %s; `agsh spec migrate %s` rewrites it in %s.\n", projSpec.APIVersion, specPath, spec.LatestAPIVersion)
+		}
 		return nil
 	}
 
@@ -314,5 +548,5 @@ func handleValidate() error {
 	for _, e := range vr.Errors {
 		fmt.Printf("  - %s: %s\n", e.Field, e.Message)
 	}
-	return fmt.Errorf("validation failed")
+	return withExitCode(exitSpecInvalid, fmt.Errorf("validation failed"))
 }
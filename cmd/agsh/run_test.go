@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cgast/agsh/internal/config"
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+func TestParseApproveFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"m.agsh.yaml"}, want: ""},
+		{args: []string{"m.agsh.yaml", "--yes"}, want: "never"},
+		{args: []string{"m.agsh.yaml", "-y"}, want: "never"},
+		{args: []string{"m.agsh.yaml", "--approve", "destructive"}, want: "destructive"},
+		{args: []string{"m.agsh.yaml", "--approve=plan"}, want: "plan"},
+		{args: []string{"--yes", "--approve=plan"}, want: "plan"}, // the last flag wins
+		{args: []string{"--approve"}, want: ""},                   // no mode follows
+		{args: []string{"--approve=always"}, wantErr: true},
+		{args: []string{"--approve", "sometimes"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseApproveFlag(tt.args)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseApproveFlag(%q) = %q, %v; want %q, error %v", tt.args, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAutoApprove(t *testing.T) {
+	reads := spec.ExecutionPlan{Steps: []spec.PlanStep{{Command: "fs:list", Risk: "read-only"}}}
+	writes := spec.ExecutionPlan{Steps: []spec.PlanStep{{Command: "fs:list", Risk: "read-only"}, {Command: "fs:write", Risk: "write"}}}
+
+	tests := []struct {
+		name    string
+		plan    spec.ExecutionPlan
+		cfg     config.ApprovalConfig
+		flag    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "plan mode asks", plan: reads, cfg: config.ApprovalConfig{Mode: "plan"}},
+		{name: "always asks", plan: reads, cfg: config.ApprovalConfig{Mode: "always"}},
+		{name: "destructive mode runs reads", plan: reads, cfg: config.ApprovalConfig{Mode: "destructive"}, want: true},
+		{name: "destructive mode asks for writes", plan: writes, cfg: config.ApprovalConfig{Mode: "destructive"}},
+		{name: "never mode runs writes", plan: writes, cfg: config.ApprovalConfig{Mode: "never"}, want: true},
+		{name: "flag overrides the mode", plan: reads, cfg: config.ApprovalConfig{Mode: "plan"}, flag: "never", want: true},
+		{name: "flag asks despite never mode", plan: reads, cfg: config.ApprovalConfig{Mode: "never"}, flag: "plan"},
+		{name: "flag cannot approve writes alone", plan: writes, cfg: config.ApprovalConfig{Mode: "plan"}, flag: "never", wantErr: true},
+		{name: "flag approves writes when allowed", plan: writes, cfg: config.ApprovalConfig{Mode: "plan", AllowAutoDestructive: true}, flag: "never", want: true},
+		{name: "flag approves writes under never mode", plan: writes, cfg: config.ApprovalConfig{Mode: "never"}, flag: "never", want: true},
+	}
+	for _, tt := range tests {
+		got, err := autoApprove(tt.plan, tt.cfg, tt.flag)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: autoApprove = %v, %v; want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cgast/agsh/internal/approval"
+	"github.com/cgast/agsh/internal/runlog"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/protocol"
+	"github.com/cgast/agsh/pkg/spec"
+	"github.com/cgast/agsh/pkg/verify"
+	"gopkg.in/yaml.v3"
+)
+
+// runRecord persists one spec run under runsDir(). A nil *runRecord
+// records nothing, so a run whose directory cannot be created still
+// executes.
+type runRecord struct {
+	run     *runlog.Run
+	bus     *events.MemoryBus
+	started time.Time
+
+	// completed holds the steps an earlier attempt finished when the run
+	// is being resumed; steps holds the results recorded so far, by step
+	// index. Steps of a graph pipeline finish concurrently.
+	completed []agshctx.StepResult
+	mu        sync.Mutex
+	steps     map[int]agshctx.StepResult
+}
+
+// startRunRecord creates the run directory and writes the resolved spec
+// and the plan. projSpec may be nil when only the plan is known.
+func startRunRecord(projSpec *spec.ProjectSpec, specPath string, plan spec.ExecutionPlan, bus *events.MemoryBus) *runRecord {
+	run, err := runlog.Create(runsDir(), plan.Spec, specPath, len(plan.Steps))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: run not recorded: %v\n", err)
+		return nil
+	}
+	rec := &runRecord{run: run, bus: bus, started: time.Now()}
+	if projSpec != nil {
+		if data, err := yaml.Marshal(projSpec); err == nil {
+			rec.warn(run.WriteFile("spec.yaml", data))
+		}
+	}
+	rec.warn(run.WriteJSON("plan.json", plan))
+	return rec
+}
+
+// resumeRunRecord reopens a failed or interrupted run so it can continue
+// after its completed steps. It returns the run's original plan.
+func resumeRunRecord(id string, bus *events.MemoryBus) (*runRecord, spec.ExecutionPlan, error) {
+	var plan spec.ExecutionPlan
+	run, err := runlog.Open(runsDir(), id)
+	if err != nil {
+		return nil, plan, err
+	}
+	if run.Status == runlog.StatusSucceeded {
+		return nil, plan, fmt.Errorf("run %s already succeeded; nothing to resume", run.ID)
+	}
+	if err := run.ReadJSON("plan.json", &plan); err != nil {
+		return nil, plan, fmt.Errorf("run %s: read plan: %w", run.ID, err)
+	}
+	var steps []agshctx.StepResult
+	if err := run.ReadJSON("steps.json", &steps); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, plan, fmt.Errorf("run %s: read steps: %w", run.ID, err)
+	}
+	completed := agshctx.CompletedSteps(steps)
+	if len(completed) >= len(plan.Steps) {
+		return nil, plan, fmt.Errorf("run %s completed all %d steps; nothing to resume", run.ID, len(plan.Steps))
+	}
+	if err := run.Restart(); err != nil {
+		return nil, plan, err
+	}
+	return &runRecord{run: run, bus: bus, started: time.Now(), completed: completed}, plan, nil
+}
+
+// resumeFrom returns the step results a pipeline resumes after.
+func (r *runRecord) resumeFrom() []agshctx.StepResult {
+	if r == nil {
+		return nil
+	}
+	return r.completed
+}
+
+// id returns the run id, which is also the pipeline id so step artifacts
+// land in the run directory.
+func (r *runRecord) id() string {
+	if r == nil {
+		return ""
+	}
+	return r.run.ID
+}
+
+// StepCompleted implements agshctx.StepObserver, rewriting steps.json
+// after every step so an interrupted run can be resumed.
+func (r *runRecord) StepCompleted(stepIndex int, result agshctx.StepResult) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.steps == nil {
+		r.steps = make(map[int]agshctx.StepResult)
+	}
+	r.steps[stepIndex] = result
+	indexes := make([]int, 0, len(r.steps))
+	for i := range r.steps {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	steps := make([]agshctx.StepResult, len(indexes))
+	for n, i := range indexes {
+		steps[n] = r.steps[i]
+	}
+	r.warn(r.run.WriteJSON("steps.json", steps))
+}
+
+// finish writes the event log, step results, verification report and
+// summary, and marks the run succeeded or failed.
+func (r *runRecord) finish(result agshctx.PipelineResult, vResult *verify.VerificationResult, summary protocol.RunSummary, runErr error) {
+	if r == nil {
+		return
+	}
+	if r.bus != nil {
+		r.warn(runlog.AppendJSONLines(r.run, "events.jsonl", r.bus.History(r.started)))
+	}
+	r.warn(r.run.WriteJSON("steps.json", result.Steps))
+	if vResult != nil {
+		r.warn(r.run.WriteJSON("verification.json", vResult))
+	}
+	r.warn(r.run.WriteJSON("summary.json", summary))
+	r.warn(r.run.Finish(summary.Success, runErr))
+}
+
+// writeManifest records the outputs the plan declares in the run
+// directory as manifest.json, marked with the outcome of the success
+// criteria that check them.
+func (r *runRecord) writeManifest(plan spec.ExecutionPlan, result agshctx.PipelineResult, vResult *verify.VerificationResult) {
+	if r == nil || len(plan.Output.Declared()) == 0 {
+		return
+	}
+	m, err := spec.BuildManifest(plan.Output, result.Steps)
+	if err != nil {
+		r.warn(fmt.Errorf("manifest: %w", err))
+		return
+	}
+	m.Spec, m.RunID = plan.Spec, r.id()
+	if vResult != nil {
+		for _, ar := range vResult.Results {
+			m.MarkVerified(ar.Assertion.Target, ar.Passed)
+		}
+	}
+	r.warn(r.run.WriteJSON("manifest.json", m))
+}
+
+// writeIntentTrace records in the run directory, as intent.json, how the
+// run's steps served its goal and what its success criteria found.
+func (r *runRecord) writeIntentTrace(plan spec.ExecutionPlan, result agshctx.PipelineResult, vResult *verify.VerificationResult) {
+	if r == nil {
+		return
+	}
+	t := spec.BuildIntentTrace(plan, result.Steps)
+	t.RunID = r.id()
+	if vResult != nil {
+		for _, ar := range vResult.Results {
+			t.AddCriterion(plan, ar.Assertion.Type, ar.Assertion.Target, ar.Passed, ar.Message)
+		}
+	}
+	r.warn(r.run.WriteJSON("intent.json", t))
+}
+
+// writeApprovals records the decisions that approved the run as
+// approvals.json.
+func (r *runRecord) writeApprovals(decisions []approval.Decision) {
+	if r == nil || len(decisions) == 0 {
+		return
+	}
+	r.warn(r.run.WriteJSON("approvals.json", decisions))
+}
+
+// readIntentTrace returns the intent trace of the run id names, which may
+// be a prefix or "latest".
+func readIntentTrace(id string) (spec.IntentTrace, error) {
+	var t spec.IntentTrace
+	run, err := runlog.Open(runsDir(), id)
+	if err != nil {
+		return t, err
+	}
+	if err := run.ReadJSON("intent.json", &t); err != nil {
+		return t, fmt.Errorf("run %s: no intent trace: %w", run.ID, err)
+	}
+	return t, nil
+}
+
+// stepObservers notifies several observers of each step.
+type stepObservers []agshctx.StepObserver
+
+func (o stepObservers) StepCompleted(stepIndex int, result agshctx.StepResult) {
+	for _, obs := range o {
+		obs.StepCompleted(stepIndex, result)
+	}
+}
+
+func (r *runRecord) warn(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// handleRuns implements `agsh runs list|show`.
+func handleRuns() error {
+	if len(os.Args) < 3 {
+		printRunsUsage()
+		return nil
+	}
+	asJSON := false
+	var args []string
+	for _, arg := range os.Args[3:] {
+		if arg == "--json" {
+			asJSON = true
+		} else {
+			args = append(args, arg)
+		}
+	}
+
+	switch os.Args[2] {
+	case "list":
+		return listRuns(asJSON)
+	case "show":
+		if len(args) != 1 {
+			return withExitCode(exitUsage, fmt.Errorf("usage: agsh runs show <id|latest> [--json]"))
+		}
+		return showRun(args[0], asJSON)
+	default:
+		printRunsUsage()
+		return withExitCode(exitUsage, fmt.Errorf("unknown runs command: %s", os.Args[2]))
+	}
+}
+
+func printRunsUsage() {
+	fmt.Println("Usage: agsh runs <command>")
+	fmt.Println("  agsh runs list [--json]              List recorded runs, newest first")
+	fmt.Println("  agsh runs show <id|latest> [--json]  Show a run's steps, verification and files")
+	fmt.Println()
+	fmt.Println("Runs are recorded in " + runsDir() + ". An id prefix is enough to select a run.")
+}
+
+func listRuns(asJSON bool) error {
+	runs, err := runlog.List(runsDir())
+	if err != nil {
+		return fmt.Errorf("list runs: %w", err)
+	}
+	if asJSON {
+		if runs == nil {
+			runs = []runlog.Meta{}
+		}
+		return printJSON(runs)
+	}
+	if len(runs) == 0 {
+		fmt.Println("No runs recorded.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tSTEPS\tSTARTED\tDURATION\tSPEC")
+	for _, m := range runs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", m.ID, m.Status, m.Steps,
+			m.StartedAt.Local().Format("2006-01-02 15:04:05"), m.Duration().Round(time.Millisecond), m.Spec)
+	}
+	return tw.Flush()
+}
+
+func showRun(id string, asJSON bool) error {
+	run, err := runlog.Open(runsDir(), id)
+	if err != nil {
+		return err
+	}
+	files, err := run.Files()
+	if err != nil {
+		return fmt.Errorf("run %s: %w", run.ID, err)
+	}
+	var summary *protocol.RunSummary
+	var s protocol.RunSummary
+	if run.ReadJSON("summary.json", &s) == nil {
+		summary = &s
+	}
+	annotations, err := run.Annotations()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return printJSON(map[string]any{
+			"run":         run.Meta,
+			"dir":         run.Dir,
+			"summary":     summary,
+			"annotations": annotations,
+			"files":       files,
+		})
+	}
+
+	fmt.Printf("Run:      %s\n", run.ID)
+	fmt.Printf("Spec:     %s", run.Spec)
+	if run.SpecPath != "" {
+		fmt.Printf(" (%s)", run.SpecPath)
+	}
+	fmt.Println()
+	fmt.Printf("Status:   %s\n", run.Status)
+	fmt.Printf("Started:  %s\n", run.StartedAt.Local().Format(time.RFC3339))
+	fmt.Printf("Duration: %s\n", run.Duration().Round(time.Millisecond))
+	if run.Error != "" {
+		fmt.Printf("Error:    %s\n", run.Error)
+	}
+	if summary != nil {
+		// The header of the markdown report repeats the meta printed above.
+		md := runSummaryMarkdown(*summary)
+		if i := strings.Index(md, "## Steps"); i >= 0 {
+			md = md[i:]
+		}
+		fmt.Println()
+		fmt.Print(md)
+	}
+	if len(annotations) > 0 {
+		fmt.Println("\nAnnotations:")
+		for _, a := range annotations {
+			fmt.Printf("  %s  %-16s %s\n", a.EventTimestamp.Local().Format("15:04:05.000"), a.EventType, a.Note)
+		}
+	}
+	fmt.Printf("\nFiles in %s:\n", run.Dir)
+	for _, f := range files {
+		fmt.Printf("  %s\n", f)
+	}
+	return nil
+}
+
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scaffoldFS holds the starter files for each project template, under
+// scaffold/<template>/.
+//
+//go:embed all:scaffold
+var scaffoldFS embed.FS
+
+// projectKit is a project template for `agsh init`.
+type projectKit struct {
+	name        string
+	description string
+	github      bool     // needs GitHub credentials
+	domains     []string // http allowlist written to platforms.yaml
+}
+
+var projectKits = []projectKit{
+	{name: "github-report", description: "Weekly markdown report of GitHub activity", github: true, domains: []string{"api.github.com"}},
+	{name: "file-transform", description: "Transform a local CSV file into a verified markdown table"},
+	{name: "data-pipeline", description: "Fetch JSON over HTTP, merge with local data, write a summary", domains: []string{"api.github.com"}},
+}
+
+func findProjectKit(name string) (projectKit, bool) {
+	for _, k := range projectKits {
+		if k.name == name {
+			return k, true
+		}
+	}
+	return projectKit{}, false
+}
+
+// initOptions are the answers that drive project scaffolding, from flags or
+// the wizard.
+type initOptions struct {
+	kit   projectKit
+	dir   string
+	name  string
+	owner string
+	force bool // overwrite existing files
+}
+
+// runInitWizard asks for the project template and settings, then scaffolds.
+func runInitWizard(opts initOptions) error {
+	scanner := bufio.NewScanner(os.Stdin)
+	ask := func(prompt, def string) string {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", prompt, def)
+		} else {
+			fmt.Printf("%s: ", prompt)
+		}
+		if !scanner.Scan() {
+			return def
+		}
+		if answer := strings.TrimSpace(scanner.Text()); answer != "" {
+			return answer
+		}
+		return def
+	}
+
+	fmt.Println("Project templates:")
+	for i, k := range projectKits {
+		fmt.Printf("  %d. %-15s %s\n", i+1, k.name, k.description)
+	}
+	for opts.kit.name == "" {
+		answer := ask("Template", projectKits[0].name)
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(projectKits) {
+			opts.kit = projectKits[n-1]
+		} else if k, ok := findProjectKit(answer); ok {
+			opts.kit = k
+		} else {
+			fmt.Printf("Unknown template %q.\n", answer)
+		}
+	}
+
+	opts.dir = ask("Project directory", defaultString(opts.dir, "."))
+	opts.name = ask("Project name", defaultString(opts.name, defaultProjectName(opts.dir)))
+	if opts.kit.github {
+		opts.owner = ask("GitHub owner (user or org)", defaultString(opts.owner, "${GITHUB_OWNER}"))
+	}
+
+	fmt.Println()
+	if !approveExecution(scanner) {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+	return scaffoldProject(opts)
+}
+
+// scaffoldProject writes .agsh/config.yaml, .agsh/platforms.yaml, the
+// starter spec and workspace files for opts.kit into opts.dir. Existing
+// files are kept unless opts.force is set.
+func scaffoldProject(opts initOptions) error {
+	if opts.dir == "" {
+		opts.dir = "."
+	}
+	if opts.name == "" {
+		opts.name = defaultProjectName(opts.dir)
+	}
+	if opts.owner == "" {
+		opts.owner = "${GITHUB_OWNER}"
+	}
+
+	absDir, err := filepath.Abs(opts.dir)
+	if err != nil {
+		return fmt.Errorf("resolve project dir: %w", err)
+	}
+
+	files := map[string][]byte{
+		filepath.Join(".agsh", "config.yaml"):    []byte(starterConfig(absDir)),
+		filepath.Join(".agsh", "platforms.yaml"): []byte(starterPlatforms(opts.kit, opts.owner)),
+	}
+
+	replacer := strings.NewReplacer(
+		"__PROJECT_NAME__", opts.name,
+		"__GITHUB_OWNER__", opts.owner,
+		"__AUTHOR__", currentUserName(),
+		"__DATE__", time.Now().Format("2006-01-02"),
+	)
+	root := path.Join("scaffold", opts.kit.name)
+	err = fs.WalkDir(scaffoldFS, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := scaffoldFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(p, root+"/")
+		files[filepath.FromSlash(rel)] = []byte(replacer.Replace(string(data)))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read template %s: %w", opts.kit.name, err)
+	}
+
+	var created, skipped []string
+	for _, rel := range sortedKeys(files) {
+		target := filepath.Join(opts.dir, rel)
+		if _, err := os.Stat(target); err == nil && !opts.force {
+			skipped = append(skipped, rel)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("create %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, files[rel], 0644); err != nil {
+			return fmt.Errorf("write %s: %w", target, err)
+		}
+		created = append(created, rel)
+	}
+
+	fmt.Printf("Initialized %s project %q in %s\n", opts.kit.name, opts.name, opts.dir)
+	for _, rel := range created {
+		fmt.Printf("  created %s\n", rel)
+	}
+	for _, rel := range skipped {
+		fmt.Printf("  kept    %s (exists; use --force to overwrite)\n", rel)
+	}
+
+	fmt.Println()
+	fmt.Println("Next steps:")
+	if opts.dir != "." {
+		fmt.Printf("  cd %s\n", opts.dir)
+	}
+	if opts.kit.github {
+		fmt.Println("  export GITHUB_TOKEN=...   # read by .agsh/platforms.yaml")
+	}
+	fmt.Println("  agsh config validate")
+	fmt.Println("  agsh run project.agsh.yaml")
+	return nil
+}
+
+// starterConfig returns a .agsh/config.yaml sandboxed to the project dir.
+// The dir is quoted, so a path with YAML syntax in it stays a string.
+func starterConfig(projectDir string) string {
+	return fmt.Sprintf(`# agsh runtime configuration. See "agsh config show --effective".
+mode: interactive
+log_level: info
+
+sandbox:
+  workdir: %[1]q
+  allowed_paths:
+    - %[1]q
+    - /tmp
+  max_file_size: 10MB
+
+approval:
+  mode: plan
+  timeout: 300
+
+verify:
+  fail_fast: true
+
+checkpoint:
+  backend: bolt
+  max_count: 50
+`, projectDir)
+}
+
+// starterPlatforms returns a .agsh/platforms.yaml whose secrets are read
+// from the environment.
+func starterPlatforms(kit projectKit, owner string) string {
+	var b strings.Builder
+	b.WriteString("# Platform credentials. ${VAR} placeholders are read from the environment.\n")
+	b.WriteString("github:\n")
+	b.WriteString("  token: ${GITHUB_TOKEN}\n")
+	fmt.Fprintf(&b, "  default_owner: %q\n", owner)
+	b.WriteString("\nhttp:\n")
+	if len(kit.domains) == 0 {
+		b.WriteString("  allowed_domains: []\n")
+	} else {
+		b.WriteString("  allowed_domains:\n")
+		for _, d := range kit.domains {
+			fmt.Fprintf(&b, "    - %s\n", d)
+		}
+	}
+	return b.String()
+}
+
+func defaultProjectName(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "my-project"
+	}
+	return filepath.Base(abs)
+}
+
+func currentUserName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "agsh"
+}
+
+func defaultString(s, def string) string {
+	if s != "" {
+		return s
+	}
+	return def
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cgast/agsh/internal/config"
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+func TestStarterConfig(t *testing.T) {
+	for _, dir := range []string{
+		"/home/me/project",
+		"/home/me/my project",
+		"/tmp/a: b",
+		"/tmp/#notes",
+		`/tmp/"quoted"`,
+		`C:\Users\me\project`,
+	} {
+		var cfg config.Config
+		if err := yaml.Unmarshal([]byte(starterConfig(dir)), &cfg); err != nil {
+			t.Errorf("%s: %v", dir, err)
+			continue
+		}
+		if cfg.Sandbox.Workdir != dir || !slices.Equal(cfg.Sandbox.AllowedPaths, []string{dir, "/tmp"}) {
+			t.Errorf("%s: sandbox = %+v", dir, cfg.Sandbox)
+		}
+	}
+}
+
+func TestStarterPlatforms(t *testing.T) {
+	tests := []struct {
+		kit   string
+		owner string
+	}{
+		{kit: "github-report", owner: "octo-org"},
+		{kit: "file-transform", owner: "${GITHUB_OWNER}"},
+		{kit: "data-pipeline", owner: "name: with # yaml"},
+	}
+	for _, tt := range tests {
+		kit, _ := findProjectKit(tt.kit)
+		var cfg config.PlatformConfig
+		if err := yaml.Unmarshal([]byte(starterPlatforms(kit, tt.owner)), &cfg); err != nil {
+			t.Errorf("%s: %v", tt.kit, err)
+			continue
+		}
+		if cfg.GitHub.DefaultOwner != tt.owner {
+			t.Errorf("%s: default_owner = %q, want %q", tt.kit, cfg.GitHub.DefaultOwner, tt.owner)
+		}
+		if !slices.Equal(cfg.HTTP.AllowedDomains, kit.domains) {
+			t.Errorf("%s: allowed_domains = %v, want %v", tt.kit, cfg.HTTP.AllowedDomains, kit.domains)
+		}
+	}
+}
+
+func TestScaffoldProject(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "test-token")
+	for _, kit := range projectKits {
+		t.Run(kit.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "my project")
+			if err := scaffoldProject(initOptions{kit: kit, dir: dir, name: "demo", owner: "octo-org"}); err != nil {
+				t.Fatal(err)
+			}
+
+			resolved, err := config.Load(config.LoadOptions{ProjectPath: filepath.Join(dir, ".agsh", "config.yaml")})
+			if err != nil {
+				t.Fatalf("config: %v", err)
+			}
+			if resolved.Config.Sandbox.Workdir != dir {
+				t.Errorf("workdir = %q, want %q", resolved.Config.Sandbox.Workdir, dir)
+			}
+			if _, err := config.LoadPlatformConfig(filepath.Join(dir, ".agsh", "platforms.yaml")); err != nil {
+				t.Errorf("platforms: %v", err)
+			}
+			s, err := spec.LoadSpec(filepath.Join(dir, "project.agsh.yaml"), nil)
+			if err != nil {
+				t.Fatalf("spec: %v", err)
+			}
+			if vr := spec.ValidateSpec(s); !vr.Valid() {
+				t.Errorf("spec: %v", vr.Error())
+			}
+		})
+	}
+}
+
+func TestScaffoldProjectKeepsFiles(t *testing.T) {
+	dir := t.TempDir()
+	kit, _ := findProjectKit("file-transform")
+	cfgPath := filepath.Join(dir, ".agsh", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(cfgPath, []byte("mode: agent\n"), 0644)
+
+	if err := scaffoldProject(initOptions{kit: kit, dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != "mode: agent\n" {
+		t.Errorf("existing config was overwritten: %q", data)
+	}
+	if err := scaffoldProject(initOptions{kit: kit, dir: dir, force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != starterConfig(dir) {
+		t.Errorf("--force kept the existing config: %q", data)
+	}
+}
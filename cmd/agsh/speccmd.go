@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+// handleSpec implements `agsh spec migrate` and `agsh spec schema`.
+func handleSpec() error {
+	if len(os.Args) < 3 {
+		printSpecUsage()
+		return nil
+	}
+
+	switch os.Args[2] {
+	case "migrate":
+		return handleSpecMigrate(os.Args[3:])
+	case "schema":
+		return handleSpecSchema()
+	default:
+		printSpecUsage()
+		return withExitCode(exitUsage, fmt.Errorf("unknown spec command: %s", os.Args[2]))
+	}
+}
+
+func printSpecUsage() {
+	fmt.Println("Usage: agsh spec <command>")
+	fmt.Println("  agsh spec migrate <spec.yaml>...          Rewrite specs in the latest version (" + spec.LatestAPIVersion + ")")
+	fmt.Println("  agsh spec migrate --check <spec.yaml>...  Only report the specs that need it")
+	fmt.Println("  agsh spec schema                          Print the JSON Schema of spec files, for editors")
+}
+
+// handleSpecSchema prints the JSON Schema of spec files.
+func handleSpecSchema() error {
+	data, err := json.MarshalIndent(spec.JSONSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// handleSpecMigrate rewrites each spec file in the latest version, or with
+// --check fails if any is older.
+func handleSpecMigrate(args []string) error {
+	check := false
+	var paths []string
+	for _, arg := range args {
+		if arg == "--check" {
+			check = true
+		} else {
+			paths = append(paths, arg)
+		}
+	}
+	if len(paths) == 0 {
+		printSpecUsage()
+		return withExitCode(exitUsage, fmt.Errorf("no spec files given"))
+	}
+
+	failed := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		out, from, err := spec.Migrate(data)
+		var vr spec.ValidationResult
+		switch {
+		case errors.As(err, &vr):
+			fmt.Printf("%s: fields of another version than %s:\n", path, from)
+			for _, e := range vr.Errors {
+				fmt.Printf("  - %s: %s\n", e.Field, e.Message)
+			}
+			failed++
+		case err != nil:
+			fmt.Printf("%s: %v\n", path, err)
+			failed++
+		case from == spec.LatestAPIVersion:
+			fmt.Printf("%s: already %s\n", path, from)
+		case check:
+			fmt.Printf("%s: %s, needs migrating to %s\n", path, from, spec.LatestAPIVersion)
+			failed++
+		default:
+			info, err := os.Stat(path)
+			if err == nil {
+				err = os.WriteFile(path, out, info.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Printf("%s: %v\n", path, err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: migrated %s -> %s\n", path, from, spec.LatestAPIVersion)
+		}
+	}
+	if failed > 0 {
+		return withExitCode(exitSpecInvalid, fmt.Errorf("%d of %d spec(s) not migrated", failed, len(paths)))
+	}
+	return nil
+}
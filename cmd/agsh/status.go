@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	llmplatform "github.com/cgast/agsh/pkg/platform/llm"
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+// executionTracker records the progress of plan executions so that an agent
+// reconnecting mid-run can ask what is happening. It has its own lock so
+// status queries never wait on a running plan.
+type executionTracker struct {
+	mu      sync.Mutex
+	status  protocol.ExecutionStatus
+	started time.Time
+
+	budget      *llmplatform.Budget // tokens are counted from it; may be nil
+	startTokens int                 // budget.Used() when the execution began
+}
+
+func newExecutionTracker(budget *llmplatform.Budget) *executionTracker {
+	return &executionTracker{status: protocol.ExecutionStatus{CurrentStep: -1}, budget: budget}
+}
+
+// begin marks the start of a plan execution limited to maxDuration (empty
+// for none). It returns false if another plan is still running; plans
+// share the context store, so only one may execute at a time.
+func (t *executionTracker) begin(planID string, steps int, maxDuration string) bool {
+	if t == nil {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.Running {
+		return false
+	}
+	t.started = time.Now()
+	t.startTokens = t.budget.Used()
+	t.status = protocol.ExecutionStatus{
+		Running:          true,
+		PlanID:           planID,
+		StepsTotal:       steps,
+		StartedAt:        t.started.Format(time.RFC3339),
+		Budgets:          &protocol.BudgetStatus{MaxDuration: maxDuration},
+		LastVerification: t.status.LastVerification,
+	}
+	if steps > 0 {
+		t.status.CurrentStep = 0
+	} else {
+		t.status.CurrentStep = -1
+	}
+	return true
+}
+
+// busy reports whether a plan is currently executing.
+func (t *executionTracker) busy() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status.Running
+}
+
+// StepCompleted implements agshctx.StepObserver.
+func (t *executionTracker) StepCompleted(stepIndex int, result agshctx.StepResult) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.StepsCompleted++
+	if stepIndex+1 < t.status.StepsTotal {
+		t.status.CurrentStep = stepIndex + 1
+	} else {
+		t.status.CurrentStep = -1
+	}
+}
+
+// verified records the outcome of success-criteria verification.
+func (t *executionTracker) verified(info *protocol.VerificationInfo) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.LastVerification = info
+}
+
+// end marks the current execution as finished.
+func (t *executionTracker) end() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Running = false
+	t.status.CurrentStep = -1
+	t.status.Elapsed = time.Since(t.started).Round(time.Millisecond).String()
+	t.countTokens()
+}
+
+// countTokens updates the token counts of the execution. t.mu must be held.
+func (t *executionTracker) countTokens() {
+	if b := t.status.Budgets; b != nil {
+		used := t.budget.Used()
+		*b = protocol.BudgetStatus{
+			Tokens:      used - t.startTokens,
+			TokensUsed:  used,
+			TokenLimit:  t.budget.Limit(),
+			MaxDuration: b.MaxDuration,
+		}
+	}
+}
+
+// snapshot returns the current execution status.
+func (t *executionTracker) snapshot() protocol.ExecutionStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.Running {
+		t.status.Elapsed = time.Since(t.started).Round(time.Millisecond).String()
+		t.countTokens()
+	}
+	status := t.status
+	if status.Budgets != nil {
+		budgets := *status.Budgets
+		status.Budgets = &budgets
+	}
+	return status
+}
+
+// registerStatusMethods registers project.status and execution.status.
+func registerStatusMethods(h *protocol.Handler, state *agentState) {
+	// project.status
+	h.Register(protocol.MethodProjectStatus, func(params json.RawMessage) (any, *protocol.Error) {
+		state.mu.Lock()
+		status := protocol.ProjectStatus{}
+		if state.loadedSpec != nil {
+			s := state.loadedSpec
+			status.Spec = &protocol.SpecSummary{
+				Name:            s.Meta.Name,
+				Description:     s.Meta.Description,
+				Goal:            s.Goal,
+				Constraints:     s.Constraints,
+				SuccessCriteria: len(s.SuccessCriteria),
+			}
+		}
+		if state.pendingPlan != nil {
+			status.PendingPlanID = state.planID
+			status.PendingPlanHash = state.planHash
+		}
+		state.mu.Unlock()
+
+		status.Execution = state.exec.snapshot()
+		return status, nil
+	})
+
+	// execution.status
+	h.Register(protocol.MethodExecutionStatus, func(params json.RawMessage) (any, *protocol.Error) {
+		return state.exec.snapshot(), nil
+	})
+}
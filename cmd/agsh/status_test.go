@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	llmplatform "github.com/cgast/agsh/pkg/platform/llm"
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+func TestExecutionTrackerBudgets(t *testing.T) {
+	budget := llmplatform.NewBudget(1000)
+	charge := func(n int) {
+		budget.Reserve(n)
+		budget.Settle(n, n)
+	}
+	tracker := newExecutionTracker(budget)
+	if s := tracker.snapshot(); s.Budgets != nil {
+		t.Errorf("budgets before any execution = %+v", s.Budgets)
+	}
+
+	charge(100) // before the execution
+	tracker.begin("plan-1", 2, "5m")
+	charge(50)
+	want := protocol.BudgetStatus{Tokens: 50, TokensUsed: 150, TokenLimit: 1000, MaxDuration: "5m"}
+	if s := tracker.snapshot(); s.Budgets == nil || *s.Budgets != want || s.Elapsed == "" {
+		t.Errorf("running: budgets = %+v, elapsed %q; want %+v", s.Budgets, s.Elapsed, want)
+	}
+
+	tracker.end()
+	charge(25) // after the execution
+	if s := tracker.snapshot(); s.Budgets == nil || *s.Budgets != want {
+		t.Errorf("finished: budgets = %+v, want %+v", s.Budgets, want)
+	}
+}
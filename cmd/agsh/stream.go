@@ -0,0 +1,180 @@
+package main
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/platform"
+	"github.com/cgast/agsh/pkg/protocol"
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+// streamChunkSize bounds the data carried by a single stream.chunk notification.
+const streamChunkSize = 32 * 1024
+
+// streamer emits stream.* notifications for a single streaming request.
+type streamer struct {
+	id  string
+	out *rpcWriter
+}
+
+func (s *streamer) notify(method string, params any) {
+	if err := s.out.Write(protocol.NewNotification(method, params)); err != nil {
+		fmt.Fprintf(os.Stderr, "error encoding notification: %v\n", err)
+	}
+}
+
+// chunks sends a step's payload as a sequence of stream.chunk notifications.
+func (s *streamer) chunks(step int, env agshctx.Envelope) {
+	text := envelopeText(env)
+	seq := 0
+	for {
+		n := len(text)
+		if n > streamChunkSize {
+			// Split on a rune boundary so each chunk is valid UTF-8. A
+			// rune is at most utf8.UTFMax bytes; if no rune starts within
+			// that, the text is not UTF-8 and is split anywhere.
+			n = streamChunkSize
+			for n > streamChunkSize-utf8.UTFMax && !utf8.RuneStart(text[n]) {
+				n--
+			}
+			if !utf8.RuneStart(text[n]) {
+				n = streamChunkSize
+			}
+		}
+		s.notify(protocol.NotifyStreamChunk, protocol.StreamChunk{
+			StreamID: s.id,
+			Step:     step,
+			Seq:      seq,
+			Data:     text[:n],
+			Final:    n == len(text),
+		})
+		text = text[n:]
+		seq++
+		if text == "" {
+			return
+		}
+	}
+}
+
+// StepCompleted implements agshctx.StepObserver.
+func (s *streamer) StepCompleted(stepIndex int, result agshctx.StepResult) {
+	if result.Status == "ok" {
+		s.chunks(stepIndex, result.Output)
+	}
+	s.notify(protocol.NotifyStreamStep, protocol.StreamStep{
+		StreamID: s.id,
+		Step:     stepIndex,
+		Command:  result.Step.Command,
+		Status:   result.Status,
+		Duration: result.Duration.String(),
+		Error:    result.Error,
+	})
+}
+
+func (s *streamer) end(success bool, errMsg string) {
+	s.notify(protocol.NotifyStreamEnd, protocol.StreamEnd{
+		StreamID: s.id,
+		Success:  success,
+		Error:    errMsg,
+	})
+}
+
+// streamIDs hands out request-scoped stream ids when the caller supplies none.
+type streamIDs struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (g *streamIDs) resolve(requested string) string {
+	if requested != "" {
+		return requested
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next++
+	return fmt.Sprintf("stream-%d", g.next)
+}
+
+// registerStreamMethods registers execute.stream and pipeline.stream. Both
+// behave like their non-streaming counterparts but report progress per
+// step: as each step finishes, its payload is sent as stream.chunk
+// notifications followed by stream.step, and stream.end follows the last
+// step. A command's output is not streamed while it is still running. The
+// chunks carry the whole payload, so the final response leaves it out and
+// is marked "streamed" instead.
+func registerStreamMethods(h *protocol.Handler, registry *platform.Registry, store agshctx.ContextStore, bus *events.MemoryBus, cpMgr verify.CheckpointManager, out *rpcWriter) {
+	ids := &streamIDs{}
+
+	h.RegisterContext(protocol.MethodExecuteStream, func(ctx gocontext.Context, params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ExecuteParams](params)
+		if err != nil {
+			return nil, err
+		}
+		s := &streamer{id: ids.resolve(p.StreamID), out: out}
+
+		result, rpcErr := executeAgentCommand(ctx, p, registry, store, bus)
+		if rpcErr != nil {
+			s.notify(protocol.NotifyStreamStep, protocol.StreamStep{
+				StreamID: s.id,
+				Command:  p.Command,
+				Status:   "error",
+				Error:    rpcErr.Message,
+			})
+			s.end(false, rpcErr.Message)
+			return nil, rpcErr
+		}
+
+		s.chunks(0, agshctx.NewEnvelope(result.Payload, "", ""))
+		s.notify(protocol.NotifyStreamStep, protocol.StreamStep{
+			StreamID: s.id,
+			Command:  p.Command,
+			Status:   "ok",
+		})
+		s.end(true, "")
+
+		return map[string]any{
+			"stream_id": s.id,
+			"result":    streamedResult(result),
+		}, nil
+	})
+
+	h.RegisterContext(protocol.MethodPipelineStream, func(ctx gocontext.Context, params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.PipelineParams](params)
+		if err != nil {
+			return nil, err
+		}
+		s := &streamer{id: ids.resolve(p.StreamID), out: out}
+
+		result := runAgentPipeline(ctx, p, registry, store, bus, cpMgr, s)
+		success, _ := result["success"].(bool)
+		errMsg, _ := result["error"].(string)
+		s.end(success, errMsg)
+
+		if _, ok := result["output"]; ok {
+			delete(result, "output")
+			result["streamed"] = true
+		}
+		result["stream_id"] = s.id
+		return result, nil
+	})
+}
+
+// streamedResult returns res without the payload its chunks already
+// carried, marked "streamed" in its metadata.
+func streamedResult(res protocol.ExecuteResult) protocol.ExecuteResult {
+	res.Payload = nil
+	res.Meta = maps.Clone(res.Meta)
+	if res.Meta == nil {
+		res.Meta = make(map[string]any)
+	}
+	res.Meta["streamed"] = true
+	return res
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+func TestStreamerChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    int // chunks
+	}{
+		{name: "empty", payload: "", want: 1},
+		{name: "one chunk", payload: "hello", want: 1},
+		{name: "exact size", payload: strings.Repeat("a", streamChunkSize), want: 1},
+		{name: "two chunks", payload: strings.Repeat("a", streamChunkSize+1), want: 2},
+		// A three-byte rune straddling the boundary moves to the next chunk.
+		{name: "rune boundary", payload: strings.Repeat("a", streamChunkSize-1) + "€", want: 2},
+		// Text that is not UTF-8 has no rune boundary to split on.
+		{name: "invalid UTF-8", payload: strings.Repeat("\x80", streamChunkSize+1), want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			s := &streamer{id: "s1", out: newRPCWriter(json.NewEncoder(&buf))}
+			s.chunks(3, agshctx.NewEnvelope(tt.payload, "", ""))
+
+			var got strings.Builder
+			dec := json.NewDecoder(&buf)
+			n := 0
+			for dec.More() {
+				var msg struct {
+					Method string               `json:"method"`
+					Params protocol.StreamChunk `json:"params"`
+				}
+				if err := dec.Decode(&msg); err != nil {
+					t.Fatal(err)
+				}
+				c := msg.Params
+				if msg.Method != protocol.NotifyStreamChunk || c.StreamID != "s1" || c.Step != 3 || c.Seq != n {
+					t.Errorf("chunk %d = %s %+v", n, msg.Method, c)
+				}
+				if !utf8.ValidString(c.Data) {
+					t.Errorf("chunk %d is not valid UTF-8", n)
+				}
+				if c.Final != (n == tt.want-1) {
+					t.Errorf("chunk %d: final = %v", n, c.Final)
+				}
+				got.WriteString(c.Data)
+				n++
+			}
+			if n != tt.want {
+				t.Errorf("chunks = %d, want %d", n, tt.want)
+			}
+			// JSON encodes each byte that is not UTF-8 as U+FFFD, as
+			// converting to runes does.
+			if got.String() != string([]rune(tt.payload)) {
+				t.Error("chunks do not reassemble the payload")
+			}
+		})
+	}
+}
+
+func TestStreamedResult(t *testing.T) {
+	meta := map[string]any{"command": "fs:read"}
+	res := protocol.ExecuteResult{Payload: "contents", Meta: meta}
+	got := streamedResult(res)
+	if got.Payload != nil {
+		t.Errorf("payload = %v, want it left out", got.Payload)
+	}
+	if got.Meta["streamed"] != true || got.Meta["command"] != "fs:read" {
+		t.Errorf("meta = %v", got.Meta)
+	}
+	if _, ok := meta["streamed"]; ok {
+		t.Error("streamedResult modified the caller's meta")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+// rpcWriter serializes JSON-RPC messages onto an output stream. Responses
+// and server-initiated notifications are written from different goroutines,
+// so every write goes through the mutex to keep lines intact.
+type rpcWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newRPCWriter(enc *json.Encoder) *rpcWriter {
+	return &rpcWriter{enc: enc}
+}
+
+// Write encodes a single message as one line of JSON.
+func (w *rpcWriter) Write(msg any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(msg)
+}
+
+// eventSubscriptions forwards bus events to the agent as JSON-RPC notifications.
+type eventSubscriptions struct {
+	mu   sync.Mutex
+	bus  events.EventBus
+	out  *rpcWriter
+	next int
+	subs map[string]<-chan events.Event
+}
+
+func newEventSubscriptions(bus events.EventBus, out *rpcWriter) *eventSubscriptions {
+	return &eventSubscriptions{
+		bus:  bus,
+		out:  out,
+		subs: make(map[string]<-chan events.Event),
+	}
+}
+
+// eventNotification is the params payload of an event notification.
+type eventNotification struct {
+	Subscription string `json:"subscription"`
+	events.Event
+}
+
+// subscribe starts forwarding events matching patterns and returns the subscription id.
+func (s *eventSubscriptions) subscribe(patterns []string) string {
+	ch := s.bus.Subscribe()
+
+	s.mu.Lock()
+	s.next++
+	id := fmt.Sprintf("sub-%d", s.next)
+	s.subs[id] = ch
+	s.mu.Unlock()
+
+	go func() {
+		for ev := range ch {
+			if !events.MatchType(patterns, ev.Type) {
+				continue
+			}
+			method := protocol.NotifyEventPrefix + string(ev.Type)
+			if ev.Type == events.EventPlanApproval || ev.Type == events.EventInputRequested {
+				method = protocol.NotifyApprovalRequired
+			}
+			if err := s.out.Write(protocol.NewNotification(method, eventNotification{Subscription: id, Event: ev})); err != nil {
+				fmt.Fprintf(os.Stderr, "error encoding notification: %v\n", err)
+			}
+		}
+	}()
+	return id
+}
+
+// unsubscribe stops a subscription. Returns false if the id is unknown.
+func (s *eventSubscriptions) unsubscribe(id string) bool {
+	s.mu.Lock()
+	ch, ok := s.subs[id]
+	delete(s.subs, id)
+	s.mu.Unlock()
+
+	if ok {
+		s.bus.Unsubscribe(ch)
+	}
+	return ok
+}
+
+// closeAll stops every active subscription.
+func (s *eventSubscriptions) closeAll() {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.subs))
+	for id := range s.subs {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		s.unsubscribe(id)
+	}
+}
+
+// registerEventMethods registers events.subscribe and events.unsubscribe.
+func registerEventMethods(h *protocol.Handler, subs *eventSubscriptions) {
+	h.Register(protocol.MethodEventsSubscribe, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.EventsSubscribeParams](params)
+		if err != nil {
+			return nil, err
+		}
+		id := subs.subscribe(p.Types)
+		return map[string]any{"subscription": id, "types": p.Types}, nil
+	})
+
+	h.Register(protocol.MethodEventsUnsubscribe, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.EventsUnsubscribeParams](params)
+		if err != nil {
+			return nil, err
+		}
+		if !subs.unsubscribe(p.Subscription) {
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: fmt.Sprintf("unknown subscription: %s", p.Subscription)}
+		}
+		return map[string]any{"unsubscribed": p.Subscription}, nil
+	})
+}
+
+// contextWatches forwards context store changes to the agent as
+// context.changed notifications.
+type contextWatches struct {
+	mu      sync.Mutex
+	store   agshctx.Watchable
+	out     *rpcWriter
+	next    int
+	watches map[string]<-chan agshctx.Change
+}
+
+func newContextWatches(store agshctx.ContextStore, out *rpcWriter) *contextWatches {
+	w, _ := store.(agshctx.Watchable)
+	return &contextWatches{
+		store:   w,
+		out:     out,
+		watches: make(map[string]<-chan agshctx.Change),
+	}
+}
+
+// changeNotification is the params payload of a context.changed notification.
+type changeNotification struct {
+	Watch string `json:"watch"`
+	agshctx.Change
+}
+
+// watch starts forwarding the changes f selects and returns the watch id.
+func (w *contextWatches) watch(f agshctx.WatchFilter) string {
+	ch := w.store.Watch(f)
+
+	w.mu.Lock()
+	w.next++
+	id := fmt.Sprintf("watch-%d", w.next)
+	w.watches[id] = ch
+	w.mu.Unlock()
+
+	go func() {
+		for c := range ch {
+			if err := w.out.Write(protocol.NewNotification(protocol.NotifyContextChanged, changeNotification{Watch: id, Change: c})); err != nil {
+				fmt.Fprintf(os.Stderr, "error encoding notification: %v\n", err)
+			}
+		}
+	}()
+	return id
+}
+
+// unwatch stops a watch. Returns false if the id is unknown.
+func (w *contextWatches) unwatch(id string) bool {
+	w.mu.Lock()
+	ch, ok := w.watches[id]
+	delete(w.watches, id)
+	w.mu.Unlock()
+
+	if ok {
+		w.store.Unwatch(ch)
+	}
+	return ok
+}
+
+// closeAll stops every active watch.
+func (w *contextWatches) closeAll() {
+	w.mu.Lock()
+	ids := make([]string, 0, len(w.watches))
+	for id := range w.watches {
+		ids = append(ids, id)
+	}
+	w.mu.Unlock()
+
+	for _, id := range ids {
+		w.unwatch(id)
+	}
+}
+
+// registerWatchMethods registers context.watch and context.unwatch.
+func registerWatchMethods(h *protocol.Handler, watches *contextWatches) {
+	h.Register(protocol.MethodContextWatch, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ContextWatchParams](params)
+		if err != nil {
+			return nil, err
+		}
+		if watches.store == nil {
+			return nil, &protocol.Error{Code: protocol.CodeInternalError, Message: "the context store does not report changes"}
+		}
+		id := watches.watch(agshctx.WatchFilter{Scopes: p.Scopes, Keys: p.Keys})
+		return map[string]any{"watch": id, "scopes": p.Scopes, "keys": p.Keys}, nil
+	})
+
+	h.Register(protocol.MethodContextUnwatch, func(params json.RawMessage) (any, *protocol.Error) {
+		p, err := protocol.ParseParams[protocol.ContextUnwatchParams](params)
+		if err != nil {
+			return nil, err
+		}
+		if !watches.unwatch(p.Watch) {
+			return nil, &protocol.Error{Code: protocol.CodeInvalidParams, Message: fmt.Sprintf("unknown watch: %s", p.Watch)}
+		}
+		return map[string]any{"unwatched": p.Watch}, nil
+	})
+}
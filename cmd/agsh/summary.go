@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/protocol"
+	"github.com/cgast/agsh/pkg/spec"
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+// runOutputFormats are the values accepted by `agsh run --output`.
+var runOutputFormats = []string{"json", "yaml", "md", "quiet"}
+
+// parseOutputFlag extracts --output <format> from args. Returns "" when it
+// is not set, meaning the final payload is printed as before.
+func parseOutputFlag(args []string) (string, error) {
+	format := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--output" && i+1 < len(args):
+			i++
+			format = args[i]
+		case strings.HasPrefix(args[i], "--output="):
+			format = strings.TrimPrefix(args[i], "--output=")
+		}
+	}
+	if format == "markdown" {
+		format = "md"
+	}
+	if format == "" {
+		return "", nil
+	}
+	for _, f := range runOutputFormats {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid --output format %q (expected %s)", format, strings.Join(runOutputFormats, ", "))
+}
+
+// newRunSummary builds the summary of a finished run. vResult is nil when
+// the plan has no success criteria.
+func newRunSummary(plan spec.ExecutionPlan, result agshctx.PipelineResult, vResult *verify.VerificationResult, started time.Time) protocol.RunSummary {
+	summary := protocol.RunSummary{
+		Spec:      plan.Spec,
+		Success:   result.Success,
+		StartedAt: started.UTC().Format(time.RFC3339),
+		Duration:  time.Since(started).Round(time.Millisecond).String(),
+		Steps:     make([]protocol.RunStep, len(result.Steps)),
+		Output:    result.Output.Payload,
+	}
+	for i, sr := range result.Steps {
+		summary.Steps[i] = newRunStep(sr)
+		if sr.CheckpointSaved != "" {
+			summary.Checkpoints = append(summary.Checkpoints, sr.CheckpointSaved)
+		}
+		if path := artifactPath(sr); path != "" {
+			summary.Artifacts = append(summary.Artifacts, path)
+		}
+	}
+	if vResult != nil {
+		summary.Verification = &protocol.VerificationInfo{
+			Passed:  vResult.Passed,
+			Results: convertVerifyResults(vResult.Results),
+		}
+		summary.Success = summary.Success && vResult.Passed
+	}
+	summary.LLMUsage = runLLMUsage(result.Steps, vResult)
+	return summary
+}
+
+// runLLMUsage totals what a run spent on LLMs: the calls of its llm steps,
+// which tag their outputs with their token counts, and of its llm_judge
+// assertions. It returns nil for a run that spent nothing.
+func runLLMUsage(steps []agshctx.StepResult, vResult *verify.VerificationResult) *protocol.LLMUsage {
+	var total verify.Usage
+	var walk func([]agshctx.StepResult)
+	walk = func(steps []agshctx.StepResult) {
+		for _, sr := range steps {
+			walk(sr.Children)
+			tags := sr.Output.Meta.Tags
+			if sr.Status != "ok" || tags["prompt_tokens"] == "" {
+				continue
+			}
+			prompt, _ := strconv.Atoi(tags["prompt_tokens"])
+			completion, _ := strconv.Atoi(tags["completion_tokens"])
+			total = total.Add(verify.Usage{Model: tags["model"], Calls: 1, PromptTokens: prompt, CompletionTokens: completion})
+		}
+	}
+	walk(steps)
+	if vResult != nil {
+		total = total.Add(vResult.Usage())
+	}
+	if total.Calls == 0 && total.Cached == 0 {
+		return nil
+	}
+	return llmUsage(total)
+}
+
+func llmUsage(u verify.Usage) *protocol.LLMUsage {
+	return &protocol.LLMUsage{
+		Model:            u.Model,
+		Calls:            u.Calls,
+		Cached:           u.Cached,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+	}
+}
+
+// newRunStep summarizes a step result, including the steps of the spec
+// it used, if any.
+func newRunStep(sr agshctx.StepResult) protocol.RunStep {
+	step := protocol.RunStep{
+		ID:         sr.Step.ID,
+		Command:    sr.Step.Command,
+		Args:       sr.Step.Args,
+		Intent:     sr.Step.Intent,
+		Status:     sr.Status,
+		Duration:   sr.Duration.Round(time.Millisecond).String(),
+		Error:      sr.Error,
+		Checkpoint: sr.CheckpointSaved,
+		Verified:   sr.VerifyPassed,
+		RolledBack: sr.RolledBack,
+		Abandoned:  sr.Abandoned,
+		Artifacts:  stepArtifactPaths(sr),
+		Drift:      sr.Drift,
+
+		OutputSchema: stepSchemaCheck(sr),
+	}
+	for _, child := range sr.Children {
+		step.Steps = append(step.Steps, newRunStep(child))
+	}
+	return step
+}
+
+// artifactPath returns the file a successful step wrote, if any.
+func artifactPath(sr agshctx.StepResult) string {
+	if sr.Status != "ok" {
+		return ""
+	}
+	payload, ok := sr.Output.Payload.(map[string]any)
+	if !ok {
+		return ""
+	}
+	var key string
+	switch sr.Output.Meta.Source {
+	case "fs:write", "fs:zip":
+		key = "path"
+	case "fs:unzip":
+		key = "dest"
+	default:
+		return ""
+	}
+	path, _ := payload[key].(string)
+	return path
+}
+
+// stepArtifactPaths returns the paths of the artifacts a step attached.
+func stepArtifactPaths(sr agshctx.StepResult) []string {
+	var paths []string
+	for _, a := range sr.Artifacts {
+		paths = append(paths, a.Path)
+	}
+	return paths
+}
+
+// stepSchemaCheck returns the result of checking a step's output against
+// its command's output schema, or nil when it was not checked.
+func stepSchemaCheck(sr agshctx.StepResult) *protocol.SchemaCheckInfo {
+	prov := sr.Output.Provenance
+	if len(prov) == 0 || prov[len(prov)-1].SchemaCheck == nil {
+		return nil
+	}
+	c := prov[len(prov)-1].SchemaCheck
+	return &protocol.SchemaCheckInfo{Passed: c.Passed, Problems: c.Problems, Error: c.Error}
+}
+
+// llmUsageText describes LLM spend, e.g. "1200 tokens in 3 calls (2
+// verdicts cached)".
+func llmUsageText(u protocol.LLMUsage) string {
+	text := fmt.Sprintf("%d tokens in %d calls", u.PromptTokens+u.CompletionTokens, u.Calls)
+	if u.Cached > 0 {
+		text += fmt.Sprintf(" (%d verdicts cached)", u.Cached)
+	}
+	return text
+}
+
+// renderRunSummary serializes a run summary in the requested format.
+// "quiet" renders nothing.
+func renderRunSummary(summary protocol.RunSummary, format string) ([]byte, error) {
+	switch format {
+	case "quiet":
+		return nil, nil
+	case "json":
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("marshal run summary: %w", err)
+		}
+		return append(data, '\n'), nil
+	case "yaml":
+		// Round-trip through JSON so YAML keys match the JSON field names.
+		raw, err := json.Marshal(summary)
+		if err != nil {
+			return nil, fmt.Errorf("marshal run summary: %w", err)
+		}
+		var generic map[string]any
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return nil, fmt.Errorf("marshal run summary: %w", err)
+		}
+		data, err := yaml.Marshal(generic)
+		if err != nil {
+			return nil, fmt.Errorf("marshal run summary: %w", err)
+		}
+		return data, nil
+	case "md", "markdown":
+		return []byte(runSummaryMarkdown(summary)), nil
+	}
+	return nil, fmt.Errorf("unknown run output format %q (expected %s)", format, strings.Join(runOutputFormats, ", "))
+}
+
+// runSummaryMarkdown renders a run summary as a markdown report.
+func runSummaryMarkdown(summary protocol.RunSummary) string {
+	var b strings.Builder
+	status := "succeeded"
+	if !summary.Success {
+		status = "failed"
+	}
+	fmt.Fprintf(&b, "# Run: %s\n\n", summary.Spec)
+	fmt.Fprintf(&b, "**Status:** %s  \n**Started:** %s  \n**Duration:** %s", status, summary.StartedAt, summary.Duration)
+	if u := summary.LLMUsage; u != nil {
+		fmt.Fprintf(&b, "  \n**LLM usage:** %s", llmUsageText(*u))
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString("## Steps\n\n")
+	b.WriteString("| # | Command | Status | Duration | Checkpoint | Notes |\n")
+	b.WriteString("|---|---------|--------|----------|------------|-------|\n")
+	for i, step := range summary.Steps {
+		notes := step.Error
+		if step.Error == "" && len(step.Drift) > 0 {
+			notes = "drift: " + strings.Join(step.Drift, "; ")
+		}
+		if step.RolledBack {
+			notes = strings.TrimSpace(notes + " (rolled back)")
+		}
+		if step.Abandoned {
+			notes = strings.TrimSpace(notes + " (abandoned, may still be running)")
+		}
+		fmt.Fprintf(&b, "| %d | `%s` | %s | %s | %s | %s |\n",
+			i+1, step.Command, step.Status, step.Duration, step.Checkpoint, mdCell(notes))
+	}
+
+	if v := summary.Verification; v != nil {
+		b.WriteString("\n## Verification\n\n")
+		for _, r := range v.Results {
+			mark := "PASS"
+			if !r.Passed {
+				mark = "FAIL"
+			}
+			fmt.Fprintf(&b, "- [%s] `%s`: %s\n", mark, r.Type, r.Message)
+		}
+	}
+
+	if len(summary.Artifacts) > 0 {
+		b.WriteString("\n## Artifacts\n\n")
+		for _, a := range summary.Artifacts {
+			fmt.Fprintf(&b, "- `%s`\n", a)
+		}
+	}
+	var attached []string
+	for i, step := range summary.Steps {
+		for _, a := range step.Artifacts {
+			attached = append(attached, fmt.Sprintf("- step %d `%s`: `%s`\n", i+1, step.Command, a))
+		}
+	}
+	if len(attached) > 0 {
+		b.WriteString("\n## Step Artifacts\n\n")
+		b.WriteString(strings.Join(attached, ""))
+	}
+	if len(summary.Checkpoints) > 0 {
+		b.WriteString("\n## Checkpoints\n\n")
+		for _, c := range summary.Checkpoints {
+			fmt.Fprintf(&b, "- `%s`\n", c)
+		}
+	}
+	return b.String()
+}
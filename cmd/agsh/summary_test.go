@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/protocol"
+	"github.com/cgast/agsh/pkg/spec"
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+// testRunResult is a run that wrote a file, attached an artifact and saved
+// a checkpoint before a step failed.
+func testRunResult() agshctx.PipelineResult {
+	write := agshctx.NewEnvelope(map[string]any{"path": "/tmp/out/report.md"}, "application/json", "fs:write")
+	return agshctx.PipelineResult{
+		Success: true,
+		Output:  agshctx.NewEnvelope("done", "text/plain", "fs:write"),
+		Steps: []agshctx.StepResult{
+			{
+				Step:            agshctx.PipelineStep{ID: "write", Command: "fs:write"},
+				Output:          write,
+				Status:          "ok",
+				Duration:        12 * time.Millisecond,
+				CheckpointSaved: "before-write",
+				Artifacts:       []agshctx.ArtifactRef{{Name: "report", Path: "/tmp/out/report.md"}},
+			},
+			{
+				Step:   agshctx.PipelineStep{ID: "ls", Command: "fs:list"},
+				Output: agshctx.NewEnvelope(map[string]any{"path": "/tmp"}, "application/json", "fs:list"),
+				Status: "ok",
+				Drift:  []string{"wrote /tmp/x"},
+			},
+			{
+				Step:       agshctx.PipelineStep{ID: "fetch", Command: "http:get"},
+				Status:     "error",
+				Error:      "connection | refused",
+				RolledBack: true,
+			},
+		},
+	}
+}
+
+func TestNewRunSummary(t *testing.T) {
+	plan := spec.ExecutionPlan{Spec: "report"}
+	failed := &verify.VerificationResult{
+		Passed: false,
+		Results: []verify.AssertionResult{
+			{Assertion: verify.Assertion{Type: "not_empty"}, Passed: true},
+			{Assertion: verify.Assertion{Type: "contains"}, Passed: false, Message: "missing heading"},
+		},
+	}
+	passed := &verify.VerificationResult{Passed: true}
+
+	tests := []struct {
+		name        string
+		vResult     *verify.VerificationResult
+		wantSuccess bool
+		wantVerify  bool
+	}{
+		{name: "no success criteria", wantSuccess: true},
+		{name: "verification passed", vResult: passed, wantSuccess: true, wantVerify: true},
+		{name: "verification failed", vResult: failed, wantVerify: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newRunSummary(plan, testRunResult(), tt.vResult, time.Now())
+			if got.Spec != "report" || got.Success != tt.wantSuccess || got.Output != "done" {
+				t.Errorf("summary = %s, success %v, output %v", got.Spec, got.Success, got.Output)
+			}
+			if (got.Verification != nil) != tt.wantVerify {
+				t.Fatalf("verification = %+v", got.Verification)
+			}
+			if tt.vResult != nil && (got.Verification.Passed != tt.vResult.Passed || len(got.Verification.Results) != len(tt.vResult.Results)) {
+				t.Errorf("verification = %+v", got.Verification)
+			}
+			if !slices.Equal(got.Artifacts, []string{"/tmp/out/report.md"}) {
+				t.Errorf("artifacts = %v", got.Artifacts)
+			}
+			if !slices.Equal(got.Checkpoints, []string{"before-write"}) {
+				t.Errorf("checkpoints = %v", got.Checkpoints)
+			}
+			if len(got.Steps) != 3 || got.Steps[0].Duration != "12ms" || !got.Steps[2].RolledBack {
+				t.Errorf("steps = %+v", got.Steps)
+			}
+		})
+	}
+}
+
+func TestRenderRunSummary(t *testing.T) {
+	failed := &verify.VerificationResult{
+		Results: []verify.AssertionResult{{Assertion: verify.Assertion{Type: "contains"}, Message: "missing heading"}},
+	}
+	summary := newRunSummary(spec.ExecutionPlan{Spec: "report"}, testRunResult(), failed, time.Now())
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, out []byte)
+	}{
+		{format: "quiet", check: func(t *testing.T, out []byte) {
+			if len(out) != 0 {
+				t.Errorf("quiet rendered %q", out)
+			}
+		}},
+		{format: "json", check: func(t *testing.T, out []byte) {
+			var got protocol.RunSummary
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Success || got.Verification == nil || got.Verification.Passed || len(got.Checkpoints) != 1 || len(got.Artifacts) != 1 {
+				t.Errorf("json summary = %+v", got)
+			}
+		}},
+		{format: "yaml", check: func(t *testing.T, out []byte) {
+			var got map[string]any
+			if err := yaml.Unmarshal(out, &got); err != nil {
+				t.Fatal(err)
+			}
+			// Keys follow the JSON field names.
+			if got["success"] != false || got["started_at"] == nil || got["checkpoints"] == nil {
+				t.Errorf("yaml summary = %v", got)
+			}
+		}},
+		{format: "md", check: func(t *testing.T, out []byte) {
+			md := string(out)
+			for _, want := range []string{
+				"# Run: report",
+				"**Status:** failed",
+				"| 1 | `fs:write` | ok | 12ms | before-write |  |",
+				"drift: wrote /tmp/x",
+				`connection \| refused (rolled back)`,
+				"- [FAIL] `contains`: missing heading",
+				"## Artifacts\n\n- `/tmp/out/report.md`",
+				"## Step Artifacts\n\n- step 1 `fs:write`: `/tmp/out/report.md`",
+				"## Checkpoints\n\n- `before-write`",
+			} {
+				if !strings.Contains(md, want) {
+					t.Errorf("markdown lacks %q:\n%s", want, md)
+				}
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			out, err := renderRunSummary(summary, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, out)
+		})
+	}
+
+	if _, err := renderRunSummary(summary, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestRunSummaryMarkdownSuccess(t *testing.T) {
+	md := runSummaryMarkdown(protocol.RunSummary{
+		Spec:     "quick",
+		Success:  true,
+		Steps:    []protocol.RunStep{{Command: "fs:list", Status: "ok", Duration: "1ms"}},
+		LLMUsage: &protocol.LLMUsage{Calls: 2, PromptTokens: 100, CompletionTokens: 20, Cached: 1},
+	})
+	if !strings.Contains(md, "**Status:** succeeded") || !strings.Contains(md, "**LLM usage:** 120 tokens in 2 calls (1 verdicts cached)") {
+		t.Errorf("markdown = %s", md)
+	}
+	for _, section := range []string{"## Verification", "## Artifacts", "## Step Artifacts", "## Checkpoints"} {
+		if strings.Contains(md, section) {
+			t.Errorf("markdown has an empty %s section", section)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package approval records who approved or rejected a plan, and counts
+// whether enough distinct people approved it. Plans with write or
+// destructive steps need two approvers when
+// approval.two_person_destructive is set:
+//
+//	approval:
+//	  two_person_destructive: true
+//
+// Each decision is appended to the project's audit log and written with
+// the run it approved.
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+// Sources of decisions.
+const (
+	SourceTerminal  = "terminal"
+	SourceInspector = "inspector"
+	SourceAgent     = "agent"
+	SourceAuto      = "auto" // --yes, --approve or approval.mode
+)
+
+// Decision is one approval or rejection of a plan. Approver is who agsh
+// knows made it: the login name at the terminal, the agent process serving
+// the connection, or the inspector client's host. Name is what the
+// approver called themselves, which is recorded but not verified.
+type Decision struct {
+	Approver string    `json:"approver"`
+	Name     string    `json:"name,omitempty"`
+	Source   string    `json:"source"`
+	Approved bool      `json:"approved"`
+	Spec     string    `json:"spec"`
+	PlanID   string    `json:"plan_id,omitempty"`
+	PlanHash string    `json:"plan_hash"`
+	Feedback string    `json:"feedback,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// ErrSameApprover is returned by Tally.Add when an approver approves a
+// plan twice.
+var ErrSameApprover = errors.New("already approved by this approver")
+
+// Required returns how many distinct approvers plan needs: two when
+// twoPersonDestructive is set and a step is not read-only, as for
+// approval.allow_auto_destructive, otherwise one.
+func Required(plan spec.ExecutionPlan, twoPersonDestructive bool) int {
+	if twoPersonDestructive && slices.ContainsFunc(plan.Steps, func(s spec.PlanStep) bool { return s.Risk != "read-only" }) {
+		return 2
+	}
+	return 1
+}
+
+// Tally collects the decisions on one plan.
+type Tally struct {
+	Required  int
+	Decisions []Decision
+}
+
+// Add records d. An approval by an approver who already approved the plan
+// is refused with ErrSameApprover, whatever Name it gives.
+func (t *Tally) Add(d Decision) error {
+	if d.Approved && slices.Contains(t.approvers(), d.Approver) {
+		return fmt.Errorf("%s: %w; plan needs %d different approvers", d.Approver, ErrSameApprover, t.Required)
+	}
+	t.Decisions = append(t.Decisions, d)
+	return nil
+}
+
+// Approved reports whether the plan has its required number of distinct
+// approvers and no rejection.
+func (t *Tally) Approved() bool {
+	return !t.Rejected() && len(t.approvers()) >= max(t.Required, 1)
+}
+
+// Rejected reports whether anyone rejected the plan.
+func (t *Tally) Rejected() bool {
+	return slices.ContainsFunc(t.Decisions, func(d Decision) bool { return !d.Approved })
+}
+
+// Approvals returns the number of distinct approvers so far.
+func (t *Tally) Approvals() int {
+	return len(t.approvers())
+}
+
+func (t *Tally) approvers() []string {
+	var names []string
+	for _, d := range t.Decisions {
+		if d.Approved && !slices.Contains(names, d.Approver) {
+			names = append(names, d.Approver)
+		}
+	}
+	return names
+}
+
+// Log is an audit log of decisions, one JSON object per line.
+type Log struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Append adds d to the log, creating it if needed.
+func (l *Log) Append(d Decision) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o755); err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	if err := json.NewEncoder(f).Encode(d); err != nil {
+		f.Close()
+		return fmt.Errorf("audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	return nil
+}
+
+// CurrentUser names the user running agsh, for decisions made at the
+// terminal: the login name, else $USER, else "unknown".
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
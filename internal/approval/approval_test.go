@@ -0,0 +1,87 @@
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+func TestRequired(t *testing.T) {
+	reads := spec.ExecutionPlan{Steps: []spec.PlanStep{{Risk: "read-only"}}}
+	writes := spec.ExecutionPlan{Steps: []spec.PlanStep{{Risk: "read-only"}, {Risk: "write"}}}
+	destructive := spec.ExecutionPlan{Steps: []spec.PlanStep{{Risk: "read-only"}, {Risk: "destructive"}}}
+
+	for _, tt := range []struct {
+		plan      spec.ExecutionPlan
+		twoPerson bool
+		want      int
+	}{
+		{reads, true, 1},
+		{writes, false, 1},
+		{writes, true, 2},
+		{destructive, false, 1},
+		{destructive, true, 2},
+	} {
+		if got := Required(tt.plan, tt.twoPerson); got != tt.want {
+			t.Errorf("Required(%v, %v) = %d, want %d", tt.plan.Steps, tt.twoPerson, got, tt.want)
+		}
+	}
+}
+
+func TestTally(t *testing.T) {
+	tally := Tally{Required: 2}
+	if err := tally.Add(Decision{Approver: "alice", Approved: true}); err != nil {
+		t.Fatalf("Add(alice): %v", err)
+	}
+	if tally.Approved() {
+		t.Error("approved with one of two approvers")
+	}
+	if err := tally.Add(Decision{Approver: "alice", Approved: true}); !errors.Is(err, ErrSameApprover) {
+		t.Errorf("second approval by alice: err = %v, want ErrSameApprover", err)
+	}
+	if err := tally.Add(Decision{Approver: "alice", Name: "bob", Approved: true}); !errors.Is(err, ErrSameApprover) {
+		t.Errorf("approval by alice named bob: err = %v, want ErrSameApprover", err)
+	}
+	if err := tally.Add(Decision{Approver: "bob", Approved: true}); err != nil {
+		t.Fatalf("Add(bob): %v", err)
+	}
+	if !tally.Approved() || tally.Approvals() != 2 {
+		t.Errorf("tally = %+v, want approved by two", tally)
+	}
+
+	tally.Add(Decision{Approver: "carol", Approved: false, Feedback: "not on a Friday"})
+	if tally.Approved() || !tally.Rejected() {
+		t.Error("rejection did not stop the approval")
+	}
+}
+
+func TestLogAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "audit.jsonl")
+	log := &Log{Path: path}
+	for _, d := range []Decision{
+		{Approver: "alice", Source: SourceTerminal, Approved: true, PlanHash: "sha256:ab"},
+		{Approver: "bob", Source: SourceAgent, Feedback: "too risky", PlanHash: "sha256:ab"},
+	} {
+		if err := log.Append(d); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log has %d lines, want 2:\n%s", len(lines), data)
+	}
+	var d Decision
+	if err := json.Unmarshal([]byte(lines[1]), &d); err != nil || d.Approver != "bob" || d.Approved || d.Feedback != "too risky" {
+		t.Errorf("second entry = %+v (%v)", d, err)
+	}
+}
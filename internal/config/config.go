@@ -1,23 +1,135 @@
 package config
 
 import (
+	"bytes"
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"regexp"
+	"runtime"
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/cgast/agsh/internal/policy"
 )
 
 // Config represents the runtime configuration from .agsh/config.yaml.
 type Config struct {
-	Mode      string          `yaml:"mode"`
-	LogLevel  string          `yaml:"log_level"`
-	Sandbox   SandboxConfig   `yaml:"sandbox"`
-	Approval  ApprovalConfig  `yaml:"approval"`
-	Verify    VerifyConfig    `yaml:"verify"`
-	History   HistoryConfig   `yaml:"history"`
-	Inspector InspectorConfig `yaml:"inspector"`
+	Mode       string           `yaml:"mode"`
+	LogLevel   string           `yaml:"log_level"`
+	Sandbox    SandboxConfig    `yaml:"sandbox"`
+	Approval   ApprovalConfig   `yaml:"approval"`
+	Verify     VerifyConfig     `yaml:"verify"`
+	History    HistoryConfig    `yaml:"history"`
+	Inspector  InspectorConfig  `yaml:"inspector"`
+	Agent      AgentConfig      `yaml:"agent"`
+	Checkpoint CheckpointConfig `yaml:"checkpoint"`
+	Executor   ExecutorConfig   `yaml:"executor"`
+
+	// Policy rules are checked, in order, before every command the policy
+	// middleware sees; see package policy for the condition syntax.
+	Policy []PolicyRule `yaml:"policy"`
+
+	// Taint decides which command outputs are untrusted and which inputs
+	// untrusted data must not reach.
+	Taint TaintConfig `yaml:"taint"`
+
+	// Notify tells the user when a run needs them or is done.
+	Notify NotifyConfig `yaml:"notify"`
+
+	// StateDir is where projects without a .agsh directory keep their
+	// context store, checkpoints and run records; empty uses the XDG
+	// base directories (see ProjectStateDirs). Also AGSH_STATE_DIR.
+	StateDir string `yaml:"state_dir"`
+}
+
+// NotifyConfig defines local notifications; see package notify.
+type NotifyConfig struct {
+	Method  string   `yaml:"method"`  // "bell", "osc" or "command"; empty disables notifications
+	Command []string `yaml:"command"` // argv for method command; {title}, {message} and {event} are replaced
+	On      []string `yaml:"on"`      // "approval", "verify_failed", "run_finished"; empty means all
+}
+
+// PolicyRule allows, denies or requires approval for the commands matching
+// Command whose input meets When.
+type PolicyRule struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"` // glob pattern, e.g. "github:issue:*"
+	When    string `yaml:"when"`    // e.g. "repo != cgast/*"; empty always holds
+	Effect  string `yaml:"effect"`  // "allow", "deny" or "require_approval"
+	Message string `yaml:"message"`
+}
+
+// PolicyRules converts the policy rules for policy.New.
+func (c Config) PolicyRules() []policy.Rule {
+	rules := make([]policy.Rule, len(c.Policy))
+	for i, r := range c.Policy {
+		rules[i] = policy.Rule{Name: r.Name, Command: r.Command, When: r.When, Effect: r.Effect, Message: r.Message}
+	}
+	return rules
+}
+
+// TaintConfig configures the taint middleware.
+type TaintConfig struct {
+	Sources        []string    `yaml:"sources"`         // commands whose output is always untrusted, e.g. "llm:*"
+	TrustedDomains []string    `yaml:"trusted_domains"` // hosts whose responses are trusted, e.g. "*.github.com"
+	Sinks          []TaintSink `yaml:"sinks"`
+}
+
+// TaintSink names command inputs untrusted data must not reach.
+type TaintSink struct {
+	Command string   `yaml:"command"` // glob pattern, e.g. "fs:write"
+	Fields  []string `yaml:"fields"`  // input fields; empty means the whole input
+}
+
+// MiddlewareNames lists the built-in executor middlewares.
+var MiddlewareNames = []string{"timing", "allowlist", "taint", "policy", "sandbox", "retry"}
+
+// ExecutorConfig defines the middleware chain every command runs through.
+type ExecutorConfig struct {
+	// Middleware names the chain in order, outermost first; names come
+	// from MiddlewareNames.
+	Middleware []string `yaml:"middleware"`
+	// AllowedCommands are glob patterns (e.g. "fs:*") for the allowlist
+	// middleware; empty allows every command.
+	AllowedCommands []string `yaml:"allowed_commands"`
+	RetryAttempts   int      `yaml:"retry_attempts"` // total attempts; 1 disables retries
+	RetryBackoff    string   `yaml:"retry_backoff"`  // duration before the first retry, doubled after
+
+	// MaxRunDuration is the deadline for a spec run, e.g. "1h"; empty
+	// means none. A spec's limits.max_duration can shorten it but not
+	// extend it. OnRunTimeout is the default limits.on_timeout.
+	MaxRunDuration string `yaml:"max_run_duration"`
+	OnRunTimeout   string `yaml:"on_run_timeout"` // "stop" or "rollback"
+}
+
+// CheckpointConfig defines checkpoint storage and retention. Zero retention
+// values are unlimited.
+type CheckpointConfig struct {
+	Backend      string `yaml:"backend"` // "bolt" (.agsh/checkpoints.db) or "file" (user cache dir)
+	MaxCount     int    `yaml:"max_count"`
+	MaxAge       string `yaml:"max_age"`        // duration, e.g. "168h"
+	MaxTotalSize string `yaml:"max_total_size"` // e.g. "100MB"
+	// DeltaLimit is how many delta checkpoints (bolt backend only) may
+	// follow a full snapshot before a new one is written; 0 stores every
+	// checkpoint in full.
+	DeltaLimit int `yaml:"delta_limit"`
+}
+
+// AgentConfig defines JSON-RPC agent mode settings.
+type AgentConfig struct {
+	// IdempotencyWindow is how long, in seconds, results of requests
+	// carrying an idempotency_key are cached for replay.
+	IdempotencyWindow int `yaml:"idempotency_window"`
+
+	// MaxResultSize caps the JSON-encoded payload of a response, e.g.
+	// "256KB". Larger payloads are truncated and can be paged with
+	// result.fetch; empty or "0" means no limit.
+	MaxResultSize string `yaml:"max_result_size"`
 }
 
 // InspectorConfig defines inspector GUI settings.
@@ -32,12 +144,25 @@ type SandboxConfig struct {
 	AllowedPaths []string `yaml:"allowed_paths"`
 	DeniedPaths  []string `yaml:"denied_paths"`
 	MaxFileSize  string   `yaml:"max_file_size"`
+
+	// PathCase is how the sandbox compares paths: "auto" (default;
+	// case-insensitive on Windows and macOS), "sensitive" or
+	// "insensitive", for file systems unlike their OS's default.
+	PathCase string `yaml:"path_case"`
 }
 
 // ApprovalConfig defines how execution approval works.
 type ApprovalConfig struct {
 	Mode    string `yaml:"mode"`    // "always", "plan", "destructive", "never"
 	Timeout int    `yaml:"timeout"` // seconds
+
+	// AllowAutoDestructive permits --yes/--approve to auto-approve plans
+	// containing write or destructive steps. Off by default.
+	AllowAutoDestructive bool `yaml:"allow_auto_destructive"`
+
+	// TwoPersonDestructive requires two different approvers for plans
+	// with write or destructive steps, which then cannot be auto-approved.
+	TwoPersonDestructive bool `yaml:"two_person_destructive"`
 }
 
 // VerifyConfig defines verification defaults.
@@ -56,18 +181,146 @@ type HistoryConfig struct {
 // PlatformConfig represents platform credentials from .agsh/platforms.yaml.
 type PlatformConfig struct {
 	GitHub GitHubConfig `yaml:"github"`
+	GitLab GitLabConfig `yaml:"gitlab"`
+	Jira   JiraConfig   `yaml:"jira"`
+	LLM    LLMConfig    `yaml:"llm"`
+	Mail   MailConfig   `yaml:"mail"`
 	HTTP   HTTPConfig   `yaml:"http"`
 }
 
-// GitHubConfig holds GitHub platform settings.
+// GitHubConfig holds GitHub platform settings. BaseURL points the github
+// commands at a GitHub Enterprise Server instance instead of github.com.
 type GitHubConfig struct {
-	Token        string `yaml:"token"`
-	DefaultOwner string `yaml:"default_owner"`
+	Token        string            `yaml:"token"`
+	DefaultOwner string            `yaml:"default_owner"`
+	BaseURL      string            `yaml:"base_url"`   // e.g. https://ghe.example.com/api/v3/
+	UploadURL    string            `yaml:"upload_url"` // defaults to the base URL's host
+	Tokens       map[string]string `yaml:"tokens"`     // per-host tokens, keyed by host name
+
+	// Accounts are extra named credentials used for the owners they list;
+	// other owners use the settings above.
+	Accounts map[string]GitHubAccount `yaml:"accounts"`
 }
 
-// HTTPConfig holds HTTP platform settings.
+// GitHubAccount is a named GitHub credential with the owners routed to it.
+type GitHubAccount struct {
+	Token     string   `yaml:"token"`
+	BaseURL   string   `yaml:"base_url"`
+	UploadURL string   `yaml:"upload_url"`
+	Owners    []string `yaml:"owners"` // users or orgs, or patterns like "acme-*"
+}
+
+// Configured reports whether any GitHub credential is set.
+func (g GitHubConfig) Configured() bool {
+	return g.HostToken() != "" || len(g.Accounts) > 0
+}
+
+// Host returns the host the github commands talk to: the host of BaseURL,
+// or "github.com".
+func (g GitHubConfig) Host() string {
+	if g.BaseURL != "" {
+		if u, err := url.Parse(g.BaseURL); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+	return "github.com"
+}
+
+// HostToken returns the token for Host: its entry in Tokens if there is
+// one, otherwise Token.
+func (g GitHubConfig) HostToken() string {
+	if t := g.Tokens[g.Host()]; t != "" {
+		return t
+	}
+	return g.Token
+}
+
+// GitLabConfig holds GitLab platform settings.
+type GitLabConfig struct {
+	Token   string `yaml:"token"`
+	BaseURL string `yaml:"base_url"` // instance root; default https://gitlab.com
+}
+
+// JiraConfig holds Jira platform settings. With Email set, Token is a Jira
+// Cloud API token; without it, a Server/Data Center personal access token.
+type JiraConfig struct {
+	BaseURL string `yaml:"base_url"` // site root, e.g. https://acme.atlassian.net
+	Email   string `yaml:"email"`
+	Token   string `yaml:"token"`
+}
+
+// LLMConfig holds settings for the llm commands, which talk to any
+// OpenAI-compatible chat completions endpoint. TokenBudget caps the tokens
+// all llm commands may use per session; 0 means no limit. EmbeddingModel,
+// if set, is used by the embed commands instead of the local embedder.
+type LLMConfig struct {
+	BaseURL        string `yaml:"base_url"` // e.g. https://api.openai.com/v1
+	APIKey         string `yaml:"api_key"`
+	Model          string `yaml:"model"`
+	EmbeddingModel string `yaml:"embedding_model"`
+	TokenBudget    int    `yaml:"token_budget"`
+}
+
+// MailConfig holds SMTP settings for mail:send. Mail can only go to
+// AllowedRecipients: addresses or "@domain" entries.
+type MailConfig struct {
+	Host              string   `yaml:"host"`
+	Port              int      `yaml:"port"` // default 587; 465 for implicit TLS
+	Username          string   `yaml:"username"`
+	Password          string   `yaml:"password"`
+	From              string   `yaml:"from"`
+	AllowedRecipients []string `yaml:"allowed_recipients"`
+}
+
+// HTTPConfig holds HTTP platform settings. Headers are sent with every
+// request; Auth holds named credentials a request selects with its auth
+// argument. Secrets belong in environment variables, referenced as ${VAR}.
 type HTTPConfig struct {
-	AllowedDomains []string `yaml:"allowed_domains"`
+	AllowedDomains []string                   `yaml:"allowed_domains"`
+	Headers        map[string]string          `yaml:"headers"`
+	Proxy          string                     `yaml:"proxy"` // e.g. http://proxy:3128; default HTTPS_PROXY/HTTP_PROXY
+	Auth           map[string]HTTPAuthProfile `yaml:"auth"`
+}
+
+// HTTPAuthProfile is a named HTTP credential: a bearer token, basic auth,
+// an OAuth2 client or custom headers. Domains restricts the hosts it is
+// sent to; an OAuth2 profile is also used unasked for those hosts.
+type HTTPAuthProfile struct {
+	Bearer   string            `yaml:"bearer"`
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	OAuth2   *HTTPOAuth2       `yaml:"oauth2"`
+	Headers  map[string]string `yaml:"headers"`
+	Domains  []string          `yaml:"domains"` // empty: any allowed domain
+}
+
+// HTTPOAuth2 is an OAuth2 client using the client credentials grant.
+type HTTPOAuth2 struct {
+	TokenURL     string   `yaml:"token_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// defaultSandbox returns the default sandbox on the OS goos names: the
+// workspace and temp directories are allowed and the system directories
+// denied.
+func defaultSandbox(goos string) SandboxConfig {
+	if goos == "windows" {
+		systemRoot := cmp.Or(os.Getenv("SystemRoot"), `C:\Windows`)
+		return SandboxConfig{
+			Workdir:      `C:\workspace`,
+			AllowedPaths: []string{`C:\workspace`, os.TempDir()},
+			DeniedPaths:  []string{systemRoot, `C:\Program Files`, `C:\Program Files (x86)`},
+			MaxFileSize:  "10MB",
+		}
+	}
+	return SandboxConfig{
+		Workdir:      "/workspace",
+		AllowedPaths: []string{"/workspace", "/tmp"},
+		DeniedPaths:  []string{"/etc", "/usr"},
+		MaxFileSize:  "10MB",
+	}
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -75,12 +328,7 @@ func DefaultConfig() Config {
 	return Config{
 		Mode:     "interactive",
 		LogLevel: "info",
-		Sandbox: SandboxConfig{
-			Workdir:      "/workspace",
-			AllowedPaths: []string{"/workspace", "/tmp"},
-			DeniedPaths:  []string{"/etc", "/usr"},
-			MaxFileSize:  "10MB",
-		},
+		Sandbox:  defaultSandbox(runtime.GOOS),
 		Approval: ApprovalConfig{
 			Mode:    "plan",
 			Timeout: 300,
@@ -92,27 +340,66 @@ func DefaultConfig() Config {
 			MaxEntries: 10000,
 			Persist:    true,
 		},
+		Agent: AgentConfig{
+			IdempotencyWindow: 600,
+			MaxResultSize:     "256KB",
+		},
+		Checkpoint: CheckpointConfig{
+			Backend:      "bolt",
+			MaxCount:     50,
+			MaxAge:       "168h",
+			MaxTotalSize: "100MB",
+			DeltaLimit:   10,
+		},
+		Executor: ExecutorConfig{
+			Middleware:    []string{"timing", "allowlist", "taint", "policy", "sandbox", "retry"},
+			RetryAttempts: 1,
+			RetryBackoff:  "500ms",
+		},
+		Taint: TaintConfig{
+			Sources: []string{"llm:*"},
+			Sinks: []TaintSink{
+				{Command: "fs:write", Fields: []string{"path"}},
+				{Command: "fs:patch", Fields: []string{"path", "diff"}},
+				{Command: "fs:zip", Fields: []string{"path"}},
+				{Command: "fs:unzip", Fields: []string{"dest"}},
+				{Command: "http:download", Fields: []string{"path"}},
+				{Command: "mail:send", Fields: []string{"to", "cc"}},
+			},
+		},
 	}
 }
 
 // LoadConfig reads and parses a runtime config YAML file.
 // Returns default config if the file doesn't exist.
 func LoadConfig(path string) (Config, error) {
-	cfg := DefaultConfig()
+	r, err := Resolve(path)
+	return r.Config, err
+}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
+// Resolve loads a single runtime config file over the defaults, recording
+// which fields the file set. Unknown fields are errors, and the result is
+// validated; on error the returned config is still usable, with defaults
+// for whatever could not be applied. See Load for layered resolution.
+func Resolve(path string) (Resolved, error) {
+	return Load(LoadOptions{ProjectPath: path})
+}
+
+// decodeStrict decodes YAML into out, rejecting fields out does not have,
+// and into doc for source tracking. An empty document is not an error.
+func decodeStrict(data []byte, out any, doc *yaml.Node) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
 		}
-		return cfg, fmt.Errorf("read config %s: %w", path, err)
+		return err
 	}
-
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	if doc != nil {
+		return yaml.Unmarshal(data, doc)
 	}
-
-	return cfg, nil
+	return nil
 }
 
 // LoadPlatformConfig reads and parses a platform credentials YAML file.
@@ -131,9 +418,12 @@ func LoadPlatformConfig(path string) (PlatformConfig, error) {
 	// Interpolate environment variables before parsing.
 	interpolated := interpolateEnvVars(string(data))
 
-	if err := yaml.Unmarshal([]byte(interpolated), &cfg); err != nil {
+	if err := decodeStrict([]byte(interpolated), &cfg, nil); err != nil {
 		return cfg, fmt.Errorf("parse platform config %s: %w", path, err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("platform config %s: %w", path, err)
+	}
 
 	return cfg, nil
 }
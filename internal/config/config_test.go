@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -17,6 +18,29 @@ func TestDefaultConfig(t *testing.T) {
 	if !cfg.Verify.FailFast {
 		t.Error("Verify.FailFast should be true by default")
 	}
+	if cfg.Agent.IdempotencyWindow != 600 {
+		t.Errorf("Agent.IdempotencyWindow = %d, want %d", cfg.Agent.IdempotencyWindow, 600)
+	}
+	sinks := make(map[string]bool)
+	for _, s := range cfg.Taint.Sinks {
+		sinks[s.Command] = true
+	}
+	for _, cmd := range []string{"fs:write", "fs:patch", "http:download"} {
+		if !sinks[cmd] {
+			t.Errorf("%s is not a default taint sink", cmd)
+		}
+	}
+}
+
+func TestDefaultSandbox(t *testing.T) {
+	if sb := defaultSandbox("linux"); sb.Workdir != "/workspace" || sb.DeniedPaths[0] != "/etc" {
+		t.Errorf("linux sandbox = %+v", sb)
+	}
+	t.Setenv("SystemRoot", `D:\Windows`)
+	sb := defaultSandbox("windows")
+	if sb.Workdir != `C:\workspace` || sb.DeniedPaths[0] != `D:\Windows` || len(sb.AllowedPaths) != 2 {
+		t.Errorf("windows sandbox = %+v", sb)
+	}
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -29,8 +53,11 @@ log_level: debug
 approval:
   mode: never
   timeout: 60
+  allow_auto_destructive: true
 verify:
   fail_fast: false
+agent:
+  idempotency_window: 30
 `
 	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
 		t.Fatal(err)
@@ -53,6 +80,12 @@ verify:
 	if cfg.Approval.Timeout != 60 {
 		t.Errorf("Approval.Timeout = %d, want %d", cfg.Approval.Timeout, 60)
 	}
+	if !cfg.Approval.AllowAutoDestructive {
+		t.Error("Approval.AllowAutoDestructive should be true")
+	}
+	if cfg.Agent.IdempotencyWindow != 30 {
+		t.Errorf("Agent.IdempotencyWindow = %d, want %d", cfg.Agent.IdempotencyWindow, 30)
+	}
 	if cfg.Verify.FailFast {
 		t.Error("Verify.FailFast should be false")
 	}
@@ -104,6 +137,125 @@ http:
 	}
 }
 
+func TestGitHubConfigHostToken(t *testing.T) {
+	cfg := GitHubConfig{
+		Token:  "public",
+		Tokens: map[string]string{"ghe.example.com": "enterprise"},
+	}
+	if cfg.Host() != "github.com" || cfg.HostToken() != "public" {
+		t.Errorf("github.com: host %q, token %q", cfg.Host(), cfg.HostToken())
+	}
+
+	cfg.BaseURL = "https://ghe.example.com/api/v3/"
+	if cfg.Host() != "ghe.example.com" || cfg.HostToken() != "enterprise" {
+		t.Errorf("enterprise: host %q, token %q", cfg.Host(), cfg.HostToken())
+	}
+
+	cfg.BaseURL = "https://other.example.com"
+	if cfg.HostToken() != "public" {
+		t.Errorf("host without a token entry should fall back to token, got %q", cfg.HostToken())
+	}
+}
+
+func TestLoadPlatformConfigInvalidURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "platforms.yaml")
+	os.WriteFile(path, []byte("github:\n  base_url: ghe.example.com\n  upload_url: https://ghe.example.com\n"), 0644)
+
+	_, err := LoadPlatformConfig(path)
+	if err == nil {
+		t.Fatal("expected error for invalid base_url")
+	}
+	if !strings.Contains(err.Error(), "github.base_url") {
+		t.Errorf("error %q does not mention github.base_url", err)
+	}
+}
+
+func TestLoadPlatformConfigAccounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "platforms.yaml")
+	t.Setenv("TEST_WORK_TOKEN", "ghp_work")
+	yaml := `
+github:
+  accounts:
+    work:
+      token: "${TEST_WORK_TOKEN}"
+      owners: ["acme", "acme-*"]
+    broken:
+      owners: []
+`
+	os.WriteFile(path, []byte(yaml), 0644)
+
+	cfg, err := LoadPlatformConfig(path)
+	if err == nil {
+		t.Fatal("expected error for account without token and owners")
+	}
+	for _, field := range []string{"github.accounts.broken.token", "github.accounts.broken.owners"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+	if cfg.GitHub.Accounts["work"].Token != "ghp_work" {
+		t.Errorf("work token = %q", cfg.GitHub.Accounts["work"].Token)
+	}
+	if !cfg.GitHub.Configured() {
+		t.Error("accounts alone should count as configured")
+	}
+}
+
+func TestLoadPlatformConfigHTTPAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "platforms.yaml")
+	t.Setenv("TEST_API_TOKEN", "tok123")
+	yaml := `
+http:
+  proxy: "socks4://proxy:1080"
+  headers:
+    User-Agent: agsh
+  auth:
+    my-api:
+      bearer: "${TEST_API_TOKEN}"
+      domains: ["api.example.com"]
+    both:
+      bearer: x
+      username: y
+    empty: {}
+    nouser:
+      password: secret
+    service:
+      oauth2:
+        token_url: "https://auth.example.com/oauth/token"
+        client_id: agsh
+        client_secret: "${TEST_API_TOKEN}"
+      domains: ["api.example.com"]
+    mixed:
+      bearer: x
+      oauth2: {client_id: agsh}
+`
+	os.WriteFile(path, []byte(yaml), 0644)
+
+	cfg, err := LoadPlatformConfig(path)
+	if err == nil {
+		t.Fatal("expected errors for proxy and broken profiles")
+	}
+	for _, field := range []string{"http.proxy", "http.auth.both", "http.auth.empty", "http.auth.nouser.password", "http.auth.mixed: set only one", "http.auth.mixed.oauth2.token_url"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+	for _, valid := range []string{"http.auth.my-api", "http.auth.service"} {
+		if strings.Contains(err.Error(), valid) {
+			t.Errorf("%s should be valid: %v", valid, err)
+		}
+	}
+	if o := cfg.HTTP.Auth["service"].OAuth2; o == nil || o.ClientSecret != "tok123" {
+		t.Errorf("oauth2 = %+v", o)
+	}
+	if cfg.HTTP.Auth["my-api"].Bearer != "tok123" {
+		t.Errorf("bearer = %q", cfg.HTTP.Auth["my-api"].Bearer)
+	}
+	if cfg.HTTP.Headers["User-Agent"] != "agsh" {
+		t.Errorf("headers = %v", cfg.HTTP.Headers)
+	}
+}
+
 func TestLoadPlatformConfigMissing(t *testing.T) {
 	cfg, err := LoadPlatformConfig("/nonexistent/path/platforms.yaml")
 	if err != nil {
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceDefault marks a value that no config file set.
+const SourceDefault = "default"
+
+// Resolved is a loaded configuration together with where each field's value
+// came from.
+type Resolved struct {
+	Config Config
+
+	// Sources maps dotted field paths (e.g. "approval.mode") to the file
+	// that set them. Fields left at their default are absent.
+	Sources map[string]string
+}
+
+// Setting is one leaf value of the effective configuration.
+type Setting struct {
+	Key    string
+	Value  any
+	Source string
+}
+
+// Settings flattens the effective configuration into leaf values sorted by
+// key, each annotated with its source.
+func (r Resolved) Settings() ([]Setting, error) {
+	data, err := yaml.Marshal(r.Config)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+
+	var settings []Setting
+	err = walkLeaves(&doc, "", func(key string, node *yaml.Node) error {
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return fmt.Errorf("decode %s: %w", key, err)
+		}
+		source := r.Sources[key]
+		if source == "" {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{Key: key, Value: value, Source: source})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings, nil
+}
+
+// walkLeaves calls fn for every non-mapping value in a YAML document, keyed
+// by its dotted path. Sequences are treated as single values.
+func walkLeaves(node *yaml.Node, prefix string, fn func(key string, node *yaml.Node) error) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := walkLeaves(child, prefix, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if err := walkLeaves(node.Content[i+1], key, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if prefix == "" {
+			return nil
+		}
+		return fn(prefix, node)
+	}
+}
+
+// recordSources marks every leaf set in doc as coming from source.
+func recordSources(doc *yaml.Node, source string, sources map[string]string) {
+	walkLeaves(doc, "", func(key string, _ *yaml.Node) error {
+		sources[key] = source
+		return nil
+	})
+}
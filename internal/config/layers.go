@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes environment variables that override config fields:
+// approval.mode is AGSH_APPROVAL_MODE, sandbox.allowed_paths is
+// AGSH_SANDBOX_ALLOWED_PATHS (comma-separated).
+const EnvPrefix = "AGSH_"
+
+// LoadOptions selects the layers Load merges. Precedence, lowest first:
+// built-in defaults, GlobalPath, ProjectPath, environment, Flags.
+type LoadOptions struct {
+	GlobalPath  string // user-global config; "" skips the layer
+	ProjectPath string // project-local config; "" skips the layer
+
+	// LookupEnv reads environment overrides; nil skips the layer.
+	LookupEnv func(key string) (string, bool)
+
+	// Flags are command-line overrides keyed by dotted field path.
+	Flags []Override
+}
+
+// Override sets one field from a command-line flag.
+type Override struct {
+	Key    string // dotted field path, e.g. "approval.mode"
+	Value  string // parsed as YAML; comma-separated for lists
+	Source string // e.g. "--mode"
+}
+
+// GlobalConfigPath returns the user-global config file:
+// $XDG_CONFIG_HOME/agsh/config.yaml, or ~/.config/agsh/config.yaml
+// (%AppData%\agsh\config.yaml on Windows).
+func GlobalConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" && runtime.GOOS == "windows" {
+		dir, _ = os.UserConfigDir()
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "agsh", "config.yaml")
+}
+
+// Load resolves the layered configuration described by opts, recording the
+// layer that set each field. A layer that fails to read or parse, or an
+// override with an unknown field or bad value, is left out and the other
+// layers still apply, so every problem can be reported at once. The merged
+// result is validated once all layers are applied; the error joins every
+// problem found. With an error the result lacks whatever the bad layers
+// set, such as their allowlist or sandbox, so it is for reporting only and
+// must not be run with.
+func Load(opts LoadOptions) (Resolved, error) {
+	r := Resolved{Config: DefaultConfig(), Sources: make(map[string]string)}
+	var errs []error
+
+	for _, path := range []string{opts.GlobalPath, opts.ProjectPath} {
+		if path == "" {
+			continue
+		}
+		if err := r.applyFile(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var overrides []Override
+	if opts.LookupEnv != nil {
+		overrides = append(overrides, envOverrides(opts.LookupEnv)...)
+	}
+	overrides = append(overrides, opts.Flags...)
+	for _, o := range overrides {
+		if err := r.applyOverride(o); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := r.Config.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return r, errors.Join(errs...)
+}
+
+// applyFile merges a config file over r. A missing file is skipped, and a
+// file that does not parse changes nothing.
+func (r *Resolved) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	cfg := r.Config
+	if err := decodeStrict(data, &cfg, &doc); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	r.Config = cfg
+	recordSources(&doc, path, r.Sources)
+	return nil
+}
+
+// applyOverride sets a single field from an environment variable or flag.
+func (r *Resolved) applyOverride(o Override) error {
+	kinds, err := leafKinds()
+	if err != nil {
+		return err
+	}
+	kind, ok := kinds[o.Key]
+	if !ok {
+		return fmt.Errorf("%s: unknown config field %q", o.Source, o.Key)
+	}
+
+	var value any
+	if kind == yaml.SequenceNode {
+		items := []string{}
+		for _, item := range strings.Split(o.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value = items
+	} else if err := yaml.Unmarshal([]byte(o.Value), &value); err != nil || value == nil {
+		value = o.Value
+	}
+
+	// Nest the value under its path and decode it like a config file.
+	parts := strings.Split(o.Key, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		value = map[string]any{parts[i]: value}
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", o.Source, err)
+	}
+	cfg := r.Config
+	if err := decodeStrict(data, &cfg, nil); err != nil {
+		return fmt.Errorf("%s: invalid value %q for %s: %w", o.Source, o.Value, o.Key, err)
+	}
+	r.Config = cfg
+	r.Sources[o.Key] = o.Source
+	return nil
+}
+
+// envOverrides collects AGSH_* variables that name a config field, in key
+// order so that application is deterministic.
+func envOverrides(lookup func(string) (string, bool)) []Override {
+	kinds, err := leafKinds()
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(kinds))
+	for key := range kinds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var overrides []Override
+	for _, key := range keys {
+		name := EnvVarName(key)
+		if val, ok := lookup(name); ok && val != "" {
+			overrides = append(overrides, Override{Key: key, Value: val, Source: "env:" + name})
+		}
+	}
+	return overrides
+}
+
+// EnvVarName returns the environment variable that overrides a field.
+func EnvVarName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// leafKinds maps every config field path to its YAML node kind.
+func leafKinds() (map[string]yaml.Kind, error) {
+	data, err := yaml.Marshal(DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	kinds := make(map[string]yaml.Kind)
+	walkLeaves(&doc, "", func(key string, node *yaml.Node) error {
+		kinds[key] = node.Kind
+		return nil
+	})
+	return kinds, nil
+}
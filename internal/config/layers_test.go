@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	global := filepath.Join(dir, "global.yaml")
+	project := filepath.Join(dir, "project.yaml")
+	os.WriteFile(global, []byte("log_level: debug\napproval:\n  mode: never\n  timeout: 10\n"), 0644)
+	os.WriteFile(project, []byte("approval:\n  mode: always\n"), 0644)
+
+	env := map[string]string{
+		"AGSH_APPROVAL_TIMEOUT":      "20",
+		"AGSH_SANDBOX_ALLOWED_PATHS": "/a, /b",
+	}
+	r, err := Load(LoadOptions{
+		GlobalPath:  global,
+		ProjectPath: project,
+		LookupEnv: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+		Flags: []Override{{Key: "log_level", Value: "warn", Source: "flag:--set log_level"}},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	cfg := r.Config
+	if cfg.Approval.Mode != "always" {
+		t.Errorf("Approval.Mode = %q, want project value", cfg.Approval.Mode)
+	}
+	if cfg.Approval.Timeout != 20 {
+		t.Errorf("Approval.Timeout = %d, want env value", cfg.Approval.Timeout)
+	}
+	if cfg.LogLevel != "warn" {
+		t.Errorf("LogLevel = %q, want flag value", cfg.LogLevel)
+	}
+	if len(cfg.Sandbox.AllowedPaths) != 2 || cfg.Sandbox.AllowedPaths[1] != "/b" {
+		t.Errorf("AllowedPaths = %v", cfg.Sandbox.AllowedPaths)
+	}
+
+	want := map[string]string{
+		"approval.mode":         project,
+		"approval.timeout":      "env:AGSH_APPROVAL_TIMEOUT",
+		"log_level":             "flag:--set log_level",
+		"sandbox.allowed_paths": "env:AGSH_SANDBOX_ALLOWED_PATHS",
+	}
+	for key, source := range want {
+		if r.Sources[key] != source {
+			t.Errorf("source of %s = %q, want %q", key, r.Sources[key], source)
+		}
+	}
+}
+
+func TestLoadBadGlobalLayer(t *testing.T) {
+	dir := t.TempDir()
+	global := filepath.Join(dir, "global.yaml")
+	project := filepath.Join(dir, "project.yaml")
+	os.WriteFile(global, []byte("log_level: debug\napprovel:\n  mode: never\n"), 0644)
+	os.WriteFile(project, []byte("approval:\n  mode: always\nsandbox:\n  allowed_paths: [/project]\n"), 0644)
+
+	r, err := Load(LoadOptions{
+		GlobalPath:  global,
+		ProjectPath: project,
+		Flags:       []Override{{Key: "approval.timeout", Value: "soon", Source: "flag"}, {Key: "history.max_entries", Value: "5", Source: "flag"}},
+	})
+	// The error is what makes callers refuse to run: the result lacks the
+	// bad file's settings.
+	if err == nil || !strings.Contains(err.Error(), global) || !strings.Contains(err.Error(), "approval.timeout") {
+		t.Fatalf("expected errors naming the global file and the bad flag, got %v", err)
+	}
+	if cfg := r.Config; cfg.LogLevel != DefaultConfig().LogLevel || r.Sources["log_level"] != "" {
+		t.Errorf("log_level = %q from %q, want the bad file left out", cfg.LogLevel, r.Sources["log_level"])
+	}
+	// The other layers still resolve, for config show to report.
+	cfg := r.Config
+	if cfg.Approval.Mode != "always" || len(cfg.Sandbox.AllowedPaths) != 1 || cfg.Sandbox.AllowedPaths[0] != "/project" {
+		t.Errorf("project layer not reported: approval %q, allowed_paths %v", cfg.Approval.Mode, cfg.Sandbox.AllowedPaths)
+	}
+	if cfg.History.MaxEntries != 5 {
+		t.Errorf("flag after the bad one not reported: %d", cfg.History.MaxEntries)
+	}
+}
+
+func TestLoadBadProjectLayer(t *testing.T) {
+	// A typo in the project file fails the load, rather than leaving its
+	// allowlist out and running every command.
+	project := filepath.Join(t.TempDir(), "project.yaml")
+	os.WriteFile(project, []byte("approvel:\n  mode: never\nexecutor:\n  allowed_commands: [\"fs:*\"]\n"), 0644)
+	r, err := Load(LoadOptions{ProjectPath: project})
+	if err == nil || !strings.Contains(err.Error(), project) {
+		t.Fatalf("err = %v, want one naming %s", err, project)
+	}
+	if len(r.Config.Executor.AllowedCommands) != 0 {
+		t.Errorf("allowed_commands = %v, want the bad file left out", r.Config.Executor.AllowedCommands)
+	}
+}
+
+func TestLoadUnknownOverride(t *testing.T) {
+	_, err := Load(LoadOptions{Flags: []Override{{Key: "approvel.mode", Value: "x", Source: "flag"}}})
+	if err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+func TestLoadInvalidOverride(t *testing.T) {
+	_, err := Load(LoadOptions{Flags: []Override{{Key: "approval.timeout", Value: "soon", Source: "flag"}}})
+	if err == nil {
+		t.Error("expected error for non-integer timeout")
+	}
+}
+
+func TestGlobalConfigPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if got := GlobalConfigPath(); got != filepath.Join("/xdg", "agsh", "config.yaml") {
+		t.Errorf("GlobalConfigPath = %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// StateDirs are the directories a project without a .agsh directory
+// keeps its state in.
+type StateDirs struct {
+	Data  string // context store and checkpoints
+	State string // run records
+	Cache string // embedding index
+}
+
+// ProjectStateDirs returns the state directories of the project in dir.
+// With base, the configured state_dir, they are all one subdirectory of
+// base. Otherwise they follow the XDG base directories:
+// $XDG_DATA_HOME/agsh (~/.local/share/agsh), $XDG_STATE_HOME/agsh
+// (~/.local/state/agsh) and $XDG_CACHE_HOME/agsh (~/.cache/agsh), or a
+// ~/.agsh directory if there is one. On Windows and macOS, without XDG
+// variables, data and state go to %LocalAppData%\agsh or
+// ~/Library/Application Support/agsh and the cache to the OS's cache
+// directory.
+//
+// Each project gets a subdirectory under projects/ named after its
+// directory and keyed by a hash of its absolute path, so projects with
+// the same name do not share state and the state survives reboots, unlike
+// the temp directory.
+func ProjectStateDirs(base, dir string) StateDirs {
+	home, _ := os.UserHomeDir()
+	return projectStateDirs(base, dir, runtime.GOOS, os.Getenv, home)
+}
+
+func projectStateDirs(base, dir, goos string, getenv func(string) string, home string) StateDirs {
+	key := projectKey(dir)
+	if base != "" {
+		d := filepath.Join(base, "projects", key)
+		return StateDirs{Data: d, State: d, Cache: d}
+	}
+	if home != "" {
+		if info, err := os.Stat(filepath.Join(home, ".agsh")); err == nil && info.IsDir() {
+			d := filepath.Join(home, ".agsh", "projects", key)
+			return StateDirs{Data: d, State: d, Cache: d}
+		}
+	}
+
+	var data, state, cache string
+	switch {
+	case home == "":
+		data = filepath.Join(os.TempDir(), "agsh")
+		state, cache = data, data
+	case goos == "windows":
+		local := getenv("LocalAppData")
+		if local == "" {
+			local = filepath.Join(home, "AppData", "Local")
+		}
+		data = filepath.Join(local, "agsh")
+		state, cache = data, filepath.Join(local, "agsh", "cache")
+	case goos == "darwin":
+		data = filepath.Join(home, "Library", "Application Support", "agsh")
+		state, cache = data, filepath.Join(home, "Library", "Caches", "agsh")
+	default:
+		data = filepath.Join(home, ".local", "share", "agsh")
+		state = filepath.Join(home, ".local", "state", "agsh")
+		cache = filepath.Join(home, ".cache", "agsh")
+	}
+	if d := getenv("XDG_DATA_HOME"); d != "" {
+		data = filepath.Join(d, "agsh")
+	}
+	if d := getenv("XDG_STATE_HOME"); d != "" {
+		state = filepath.Join(d, "agsh")
+	}
+	if d := getenv("XDG_CACHE_HOME"); d != "" {
+		cache = filepath.Join(d, "agsh")
+	}
+	return StateDirs{
+		Data:  filepath.Join(data, "projects", key),
+		State: filepath.Join(state, "projects", key),
+		Cache: filepath.Join(cache, "projects", key),
+	}
+}
+
+// projectKey names the state subdirectory of the project in dir: its base
+// name and the first 8 bytes of the SHA-256 of its absolute path.
+func projectKey(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	sum := sha256.Sum256([]byte(dir))
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, filepath.Base(dir))
+	return name + "-" + hex.EncodeToString(sum[:8])
+}
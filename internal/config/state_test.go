@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectStateDirs(t *testing.T) {
+	home := t.TempDir()
+	key := projectKey("/work/site")
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	got := projectStateDirs("", "/work/site", "linux", getenv, home)
+	want := StateDirs{
+		Data:  filepath.Join(home, ".local", "share", "agsh", "projects", key),
+		State: filepath.Join(home, ".local", "state", "agsh", "projects", key),
+		Cache: filepath.Join(home, ".cache", "agsh", "projects", key),
+	}
+	if got != want {
+		t.Errorf("default dirs = %+v, want %+v", got, want)
+	}
+
+	env["XDG_DATA_HOME"] = "/xdg/data"
+	if got := projectStateDirs("", "/work/site", "linux", getenv, home); got.Data != filepath.Join("/xdg/data", "agsh", "projects", key) {
+		t.Errorf("Data with XDG_DATA_HOME = %q", got.Data)
+	}
+
+	got = projectStateDirs("/state", "/work/site", "linux", getenv, home)
+	if d := filepath.Join("/state", "projects", key); got.Data != d || got.State != d || got.Cache != d {
+		t.Errorf("dirs with state_dir = %+v", got)
+	}
+
+	os.Mkdir(filepath.Join(home, ".agsh"), 0755)
+	got = projectStateDirs("", "/work/site", "linux", getenv, home)
+	if d := filepath.Join(home, ".agsh", "projects", key); got.Data != d || got.State != d || got.Cache != d {
+		t.Errorf("dirs with ~/.agsh = %+v", got)
+	}
+}
+
+func TestProjectKey(t *testing.T) {
+	a, b := projectKey("/work/site"), projectKey("/other/site")
+	if !strings.HasPrefix(a, "site-") || len(a) != len("site-")+16 {
+		t.Errorf("projectKey = %q, want site-<16 hex digits>", a)
+	}
+	if a == b {
+		t.Errorf("projects with the same name share the key %q", a)
+	}
+}
+
+func TestLoadStateDirFromEnv(t *testing.T) {
+	r, err := Load(LoadOptions{LookupEnv: func(key string) (string, bool) {
+		return "/state", key == "AGSH_STATE_DIR"
+	}})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if r.Config.StateDir != "/state" {
+		t.Errorf("StateDir = %q, want env value", r.Config.StateDir)
+	}
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cgast/agsh/internal/glob"
+	"github.com/cgast/agsh/internal/notify"
+	"github.com/cgast/agsh/internal/policy"
+	"github.com/cgast/agsh/internal/sandbox"
+)
+
+// FieldError is a single invalid config value.
+type FieldError struct {
+	Field   string // dotted path, e.g. "approval.mode"
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError collects every invalid value found in a config.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks enum values, ranges, durations and size strings. It
+// returns a *ValidationError listing every problem, or nil.
+func (c Config) Validate() error {
+	v := &ValidationError{}
+
+	oneOf(v, "mode", c.Mode, "interactive", "agent")
+	oneOf(v, "log_level", c.LogLevel, "debug", "info", "warn", "error")
+
+	size(v, "sandbox.max_file_size", c.Sandbox.MaxFileSize)
+	oneOf(v, "sandbox.path_case", c.Sandbox.PathCase, "auto", "sensitive", "insensitive")
+
+	oneOf(v, "approval.mode", c.Approval.Mode, "always", "plan", "destructive", "never")
+	nonNegative(v, "approval.timeout", c.Approval.Timeout)
+
+	nonNegative(v, "history.max_entries", c.History.MaxEntries)
+
+	if c.Inspector.Port < 0 || c.Inspector.Port > 65535 {
+		v.add("inspector.port", "%d is not a valid port (0-65535)", c.Inspector.Port)
+	}
+
+	nonNegative(v, "agent.idempotency_window", c.Agent.IdempotencyWindow)
+	size(v, "agent.max_result_size", c.Agent.MaxResultSize)
+
+	oneOf(v, "checkpoint.backend", c.Checkpoint.Backend, "bolt", "file")
+	nonNegative(v, "checkpoint.max_count", c.Checkpoint.MaxCount)
+	if c.Checkpoint.MaxAge != "" {
+		if d, err := time.ParseDuration(c.Checkpoint.MaxAge); err != nil || d < 0 {
+			v.add("checkpoint.max_age", "invalid duration %q", c.Checkpoint.MaxAge)
+		}
+	}
+	size(v, "checkpoint.max_total_size", c.Checkpoint.MaxTotalSize)
+	nonNegative(v, "checkpoint.delta_limit", c.Checkpoint.DeltaLimit)
+
+	seen := make(map[string]bool)
+	for _, name := range c.Executor.Middleware {
+		oneOf(v, "executor.middleware", name, MiddlewareNames...)
+		if seen[name] {
+			v.add("executor.middleware", "%q listed more than once", name)
+		}
+		seen[name] = true
+	}
+	nonNegative(v, "executor.retry_attempts", c.Executor.RetryAttempts)
+	if c.Executor.RetryBackoff != "" {
+		if d, err := time.ParseDuration(c.Executor.RetryBackoff); err != nil || d < 0 {
+			v.add("executor.retry_backoff", "invalid duration %q", c.Executor.RetryBackoff)
+		}
+	}
+	if c.Executor.MaxRunDuration != "" {
+		if d, err := time.ParseDuration(c.Executor.MaxRunDuration); err != nil || d <= 0 {
+			v.add("executor.max_run_duration", "invalid duration %q", c.Executor.MaxRunDuration)
+		}
+	}
+	oneOf(v, "executor.on_run_timeout", c.Executor.OnRunTimeout, "stop", "rollback")
+
+	if _, err := policy.New(c.PolicyRules()); err != nil {
+		v.add("policy", "%v", err)
+	} else if len(c.Policy) > 0 && !slices.Contains(c.Executor.Middleware, "policy") {
+		v.add("policy", "rules are set but executor.middleware does not include policy")
+	}
+
+	for _, p := range c.Taint.Sources {
+		if err := glob.Validate(p); err != nil {
+			v.add("taint.sources", "%v", err)
+		}
+	}
+	for _, s := range c.Taint.Sinks {
+		if err := glob.Validate(s.Command); err != nil {
+			v.add("taint.sinks", "%v", err)
+		}
+	}
+
+	oneOf(v, "notify.method", c.Notify.Method, notify.Methods...)
+	if c.Notify.Method == notify.MethodCommand && len(c.Notify.Command) == 0 {
+		v.add("notify.command", "is required when notify.method is command")
+	}
+	for _, on := range c.Notify.On {
+		oneOf(v, "notify.on", on, notify.Occasions...)
+	}
+
+	if len(v.Errors) > 0 {
+		return v
+	}
+	return nil
+}
+
+// Validate checks the platform settings that can be checked without
+// contacting the platforms.
+func (p PlatformConfig) Validate() error {
+	v := &ValidationError{}
+
+	absURL(v, "github.base_url", p.GitHub.BaseURL)
+	absURL(v, "github.upload_url", p.GitHub.UploadURL)
+	if p.GitHub.UploadURL != "" && p.GitHub.BaseURL == "" {
+		v.add("github.upload_url", "requires github.base_url")
+	}
+	absURL(v, "gitlab.base_url", p.GitLab.BaseURL)
+	absURL(v, "jira.base_url", p.Jira.BaseURL)
+	if p.Jira.Token != "" && p.Jira.BaseURL == "" {
+		v.add("jira.base_url", "is required when jira.token is set")
+	}
+
+	absURL(v, "llm.base_url", p.LLM.BaseURL)
+	if p.LLM.BaseURL != "" && p.LLM.Model == "" {
+		v.add("llm.model", "is required when llm.base_url is set")
+	}
+	if p.LLM.EmbeddingModel != "" && p.LLM.BaseURL == "" {
+		v.add("llm.embedding_model", "requires llm.base_url")
+	}
+	if p.LLM.TokenBudget < 0 {
+		v.add("llm.token_budget", "must not be negative")
+	}
+
+	if p.Mail.Port < 0 || p.Mail.Port > 65535 {
+		v.add("mail.port", "%d is not a valid port (0-65535)", p.Mail.Port)
+	}
+	if p.Mail.Host != "" {
+		if _, err := netmail.ParseAddress(p.Mail.From); err != nil {
+			v.add("mail.from", "invalid address %q", p.Mail.From)
+		}
+	}
+
+	if p.HTTP.Proxy != "" {
+		u, err := url.Parse(p.HTTP.Proxy)
+		if err != nil || u.Host == "" || !slices.Contains([]string{"http", "https", "socks5"}, u.Scheme) {
+			v.add("http.proxy", "invalid proxy URL %q (expected http://, https:// or socks5://)", p.HTTP.Proxy)
+		}
+	}
+	profiles := make([]string, 0, len(p.HTTP.Auth))
+	for name := range p.HTTP.Auth {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		a := p.HTTP.Auth[name]
+		field := "http.auth." + name
+		kinds := 0
+		for _, set := range []bool{a.Bearer != "", a.Username != "", a.OAuth2 != nil} {
+			if set {
+				kinds++
+			}
+		}
+		switch {
+		case kinds > 1:
+			v.add(field, "set only one of bearer, username and oauth2")
+		case kinds == 0 && len(a.Headers) == 0:
+			v.add(field, "needs bearer, username, oauth2 or headers")
+		}
+		if a.Password != "" && a.Username == "" {
+			v.add(field+".password", "requires username")
+		}
+		if o := a.OAuth2; o != nil {
+			if o.TokenURL == "" {
+				v.add(field+".oauth2.token_url", "is required")
+			}
+			absURL(v, field+".oauth2.token_url", o.TokenURL)
+			if o.ClientID == "" {
+				v.add(field+".oauth2.client_id", "is required")
+			}
+		}
+	}
+
+	names := make([]string, 0, len(p.GitHub.Accounts))
+	for name := range p.GitHub.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := p.GitHub.Accounts[name]
+		field := "github.accounts." + name
+		if a.Token == "" {
+			v.add(field+".token", "is required")
+		}
+		if len(a.Owners) == 0 {
+			v.add(field+".owners", "must list at least one owner")
+		}
+		for _, o := range a.Owners {
+			if _, err := path.Match(o, ""); err != nil || o == "" {
+				v.add(field+".owners", "invalid owner pattern %q", o)
+			}
+		}
+		absURL(v, field+".base_url", a.BaseURL)
+		absURL(v, field+".upload_url", a.UploadURL)
+	}
+
+	if len(v.Errors) > 0 {
+		return v
+	}
+	return nil
+}
+
+// oneOf checks an enum field; empty values fall back to defaults and are
+// accepted.
+func oneOf(v *ValidationError, field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(field, "unknown value %q (expected %s)", value, strings.Join(allowed, ", "))
+}
+
+func nonNegative(v *ValidationError, field string, n int) {
+	if n < 0 {
+		v.add(field, "must not be negative (got %d)", n)
+	}
+}
+
+func size(v *ValidationError, field, value string) {
+	if value == "" {
+		return
+	}
+	if n, err := sandbox.ParseFileSize(value); err != nil || n < 0 {
+		v.add(field, "invalid size %q (e.g. 10MB)", value)
+	}
+}
+
+func absURL(v *ValidationError, field, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, "invalid URL %q (expected http:// or https://)", value)
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("default config invalid: %v", err)
+	}
+}
+
+func TestValidateInvalidValues(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Approval.Mode = "sometimes"
+	cfg.Inspector.Port = 70000
+	cfg.Sandbox.MaxFileSize = "ten megs"
+	cfg.Sandbox.PathCase = "mixed"
+	cfg.Checkpoint.MaxAge = "a week"
+	cfg.Executor.Middleware = []string{"timing", "cache"}
+	cfg.Executor.MaxRunDuration = "-5m"
+	cfg.Executor.OnRunTimeout = "retry"
+
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+
+	want := []string{"approval.mode", "sandbox.max_file_size", "sandbox.path_case", "inspector.port", "checkpoint.max_age", "executor.middleware",
+		"executor.max_run_duration", "executor.on_run_timeout"}
+	if len(verr.Errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(verr.Errors), len(want), err)
+	}
+	for _, field := range want {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+}
+
+func TestLoadConfigUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("approvel:\n  mode: never\n"), 0644)
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "approvel") {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestLoadConfigEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, nil, 0644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Approval.Mode != "plan" {
+		t.Errorf("Approval.Mode = %q, want default", cfg.Approval.Mode)
+	}
+}
+
+func TestResolveSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("approval:\n  mode: never\n"), 0644)
+
+	r, err := Resolve(path)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	settings, err := r.Settings()
+	if err != nil {
+		t.Fatalf("Settings: %v", err)
+	}
+
+	sources := make(map[string]string)
+	for _, s := range settings {
+		sources[s.Key] = s.Source
+	}
+	if sources["approval.mode"] != path {
+		t.Errorf("approval.mode source = %q, want %q", sources["approval.mode"], path)
+	}
+	if sources["approval.timeout"] != SourceDefault {
+		t.Errorf("approval.timeout source = %q, want default", sources["approval.timeout"])
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Policy = []PolicyRule{{Command: "github:issue:create", When: "repo != cgast/*", Effect: "deny"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid policy rejected: %v", err)
+	}
+
+	cfg.Executor.Middleware = []string{"timing"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "does not include policy") {
+		t.Errorf("expected missing middleware error, got %v", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Policy = []PolicyRule{{Name: "big-writes", Command: "fs:write", When: "len(content) > huge", Effect: "deny"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "big-writes") {
+		t.Errorf("expected invalid rule error, got %v", err)
+	}
+}
+
+func TestValidateTaint(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Taint.Sinks = append(cfg.Taint.Sinks, TaintSink{Command: "shell"})
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "taint.sinks") {
+		t.Errorf("expected invalid sink error, got %v", err)
+	}
+}
+
+func TestValidateNotify(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Notify = NotifyConfig{Method: "osc", On: []string{"approval", "run_finished"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid notify config: %v", err)
+	}
+
+	cfg.Notify = NotifyConfig{Method: "command", On: []string{"always"}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "notify.command") || !strings.Contains(err.Error(), "notify.on") {
+		t.Errorf("expected notify.command and notify.on errors, got %v", err)
+	}
+}
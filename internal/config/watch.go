@@ -0,0 +1,85 @@
+package config
+
+import (
+	gocontext "context"
+	"os"
+	"time"
+)
+
+// Reload is delivered by Watch after a changed config has loaded and
+// validated successfully.
+type Reload struct {
+	Config   Resolved
+	Platform PlatformConfig
+	Changed  []string // files whose contents changed
+}
+
+// Watch polls the config files named by opts and platformPath every
+// interval until ctx is done. When any of them changes, the whole layered
+// configuration is reloaded and validated; onReload receives it only if it
+// is valid, otherwise onError is called and the previous configuration
+// stays in effect.
+func Watch(ctx gocontext.Context, opts LoadOptions, platformPath string, interval time.Duration, onReload func(Reload), onError func(error)) {
+	var paths []string
+	for _, p := range []string{opts.GlobalPath, opts.ProjectPath, platformPath} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	last := make(map[string]fileStamp, len(paths))
+	for _, p := range paths {
+		last[p] = stampFile(p)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var changed []string
+		for _, p := range paths {
+			if s := stampFile(p); s != last[p] {
+				last[p] = s
+				changed = append(changed, p)
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		resolved, err := Load(opts)
+		if err != nil {
+			onError(err)
+			continue
+		}
+		var plat PlatformConfig
+		if platformPath != "" {
+			plat, err = LoadPlatformConfig(platformPath)
+			if err != nil {
+				onError(err)
+				continue
+			}
+		}
+		onReload(Reload{Config: resolved, Platform: plat, Changed: changed})
+	}
+}
+
+// fileStamp identifies a version of a file well enough to notice edits.
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func stampFile(path string) fileStamp {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, size: fi.Size(), modTime: fi.ModTime()}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchReloads(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte("log_level: info\n"), 0644)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+
+	reloads := make(chan Reload, 1)
+	errs := make(chan error, 1)
+	go Watch(ctx, LoadOptions{ProjectPath: path}, "", 10*time.Millisecond,
+		func(r Reload) { reloads <- r },
+		func(err error) { errs <- err },
+	)
+
+	// Invalid edits are reported and not applied.
+	time.Sleep(30 * time.Millisecond)
+	os.WriteFile(path, []byte("log_level: loud\n"), 0644)
+	select {
+	case <-errs:
+	case r := <-reloads:
+		t.Fatalf("invalid config was reloaded: %+v", r.Config.Config.LogLevel)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for validation error")
+	}
+
+	os.WriteFile(path, []byte("log_level: debug\n"), 0644)
+	select {
+	case r := <-reloads:
+		if r.Config.Config.LogLevel != "debug" {
+			t.Errorf("LogLevel = %q, want debug", r.Config.Config.LogLevel)
+		}
+		if len(r.Changed) != 1 || r.Changed[0] != path {
+			t.Errorf("Changed = %v", r.Changed)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+}
@@ -0,0 +1,65 @@
+// Package digest computes and compares content digests written as
+// "algorithm:hex", e.g. "sha256:9f86d0...". A bare hex string is taken to
+// be SHA-256.
+package digest
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Default is the algorithm used when none is given.
+const Default = "sha256"
+
+var algorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Supported reports whether algorithm is known.
+func Supported(algorithm string) bool {
+	_, ok := algorithms[algorithm]
+	return ok
+}
+
+// Sum reads r to the end and returns its hex digest and length.
+func Sum(algorithm string, r io.Reader) (string, int64, error) {
+	newHash, ok := algorithms[algorithm]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown hash algorithm %q (expected md5, sha1, sha256 or sha512)", algorithm)
+	}
+	h := newHash()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Parse splits an expected digest into algorithm and lower-case hex value.
+func Parse(s string) (algorithm, value string, err error) {
+	algorithm, value = Default, strings.TrimSpace(s)
+	if i := strings.IndexByte(value, ':'); i >= 0 {
+		algorithm, value = strings.ToLower(value[:i]), value[i+1:]
+	}
+	if !Supported(algorithm) {
+		return "", "", fmt.Errorf("unknown hash algorithm %q (expected md5, sha1, sha256 or sha512)", algorithm)
+	}
+	value = strings.ToLower(value)
+	if want := algorithms[algorithm]().Size() * 2; len(value) != want {
+		return "", "", fmt.Errorf("%s digest must be %d hex characters, got %d", algorithm, want, len(value))
+	}
+	if _, err := hex.DecodeString(value); err != nil {
+		return "", "", fmt.Errorf("digest is not hex: %q", value)
+	}
+	return algorithm, value, nil
+}
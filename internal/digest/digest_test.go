@@ -0,0 +1,55 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		want      string
+	}{
+		{"md5", "5d41402abc4b2a76b9719d911017c592"},
+		{"sha1", "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+		{"sha256", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	}
+	for _, tt := range tests {
+		got, n, err := Sum(tt.algorithm, strings.NewReader("hello"))
+		if err != nil {
+			t.Fatalf("Sum(%s): %v", tt.algorithm, err)
+		}
+		if got != tt.want || n != 5 {
+			t.Errorf("Sum(%s) = %s, %d; want %s, 5", tt.algorithm, got, n, tt.want)
+		}
+	}
+	if _, _, err := Sum("crc32", strings.NewReader("hello")); err == nil {
+		t.Error("expected error for unknown algorithm")
+	}
+}
+
+func TestParse(t *testing.T) {
+	sha := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	tests := []struct {
+		in        string
+		algorithm string
+		wantErr   bool
+	}{
+		{sha, "sha256", false},
+		{"sha256:" + strings.ToUpper(sha), "sha256", false},
+		{"md5:5d41402abc4b2a76b9719d911017c592", "md5", false},
+		{"md5:" + sha, "", true},
+		{"crc32:1234", "", true},
+		{"sha256:" + strings.Repeat("z", 64), "", true},
+	}
+	for _, tt := range tests {
+		algorithm, value, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (algorithm != tt.algorithm || value != strings.ToLower(value)) {
+			t.Errorf("Parse(%q) = %s, %s", tt.in, algorithm, value)
+		}
+	}
+}
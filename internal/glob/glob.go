@@ -0,0 +1,81 @@
+// Package glob matches colon-separated command names such as
+// "github:pr:list" against patterns like "fs:*", "github:*:list" or
+// "*:write".
+//
+// Patterns are matched segment by segment. Within a segment, '*', '?' and
+// '[...]' work as in path.Match and never cross a ':'. A "**" segment
+// matches any number of segments, and a '*' in the last pattern segment
+// also matches the rest of the name, so "github:*" covers "github:pr:list".
+package glob
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// IsPattern reports whether s contains glob metacharacters.
+func IsPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// Match reports whether name matches pattern. Malformed patterns match
+// nothing; use Validate to report them.
+func Match(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, ":"), strings.Split(name, ":"))
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		seg := pat[0]
+		switch {
+		case seg == "**":
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		case len(name) == 0:
+			return false
+		case len(pat) == 1 && strings.Contains(seg, "*"):
+			// A trailing wildcard absorbs the remaining segments.
+			ok, _ := path.Match(seg, strings.Join(name, ":"))
+			return ok
+		}
+		if ok, _ := path.Match(seg, name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// Validate checks that pattern is a well-formed command pattern: "*", "**",
+// or namespace:command segments, none of them empty.
+func Validate(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty command pattern")
+	}
+	if pattern == "*" || pattern == "**" {
+		return nil
+	}
+	if !strings.Contains(pattern, ":") {
+		return fmt.Errorf("invalid pattern %q (expected namespace:command format)", pattern)
+	}
+	for i, seg := range strings.Split(pattern, ":") {
+		if seg == "" {
+			if i == 0 {
+				return fmt.Errorf("invalid pattern %q (empty namespace)", pattern)
+			}
+			return fmt.Errorf("invalid pattern %q (empty segment)", pattern)
+		}
+		if strings.Contains(seg, "**") && seg != "**" {
+			return fmt.Errorf("invalid pattern %q (** must be a whole segment)", pattern)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*", "github:pr:list", true},
+		{"fs:*", "fs:list", true},
+		{"fs:*", "fsx:list", false},
+		{"github:*", "github:pr:list", true},
+		{"github:pr*", "github:pr:list", true},
+		{"github:*:list", "github:pr:list", true},
+		{"github:*:list", "github:issue:list", true},
+		{"github:*:list", "github:pr:create", false},
+		{"github:*:list", "github:list", false},
+		{"*:read", "fs:read", true},
+		{"*:read", "github:file:read", false},
+		{"**:read", "github:file:read", true},
+		{"**:read", "fs:read", true},
+		{"fs:?ead", "fs:read", true},
+		{"fs:[rw]*", "fs:write", true},
+		{"fs:list", "fs:list", true},
+		{"fs:list", "fs:list:all", false},
+		{"fs:[", "fs:[", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{"*", "**", "fs:*", "github:*:list", "*:write", "**:read", "fs:[rw]*"}
+	for _, p := range valid {
+		if err := Validate(p); err != nil {
+			t.Errorf("Validate(%q): %v", p, err)
+		}
+	}
+	invalid := []string{"", "fs", ":list", "fs::list", "fs:[", "fs:a**"}
+	for _, p := range invalid {
+		if err := Validate(p); err == nil {
+			t.Errorf("Validate(%q) should fail", p)
+		}
+	}
+}
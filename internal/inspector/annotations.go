@@ -0,0 +1,151 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cgast/agsh/internal/runlog"
+	"github.com/cgast/agsh/pkg/events"
+)
+
+// handleAnnotations lists the session's annotations (GET) or annotates one
+// of its events (POST). An annotation of an event of a recorded run is also
+// stored in the run directory, for review with `agsh runs show`.
+func (s *Server) handleAnnotations(w http.ResponseWriter, r *http.Request, sess *session) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, sess.annotationList())
+	case http.MethodPost:
+		var body struct {
+			EventType      string    `json:"event_type"`
+			EventTimestamp time.Time `json:"event_timestamp"`
+			Note           string    `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(body.Note) == "" {
+			http.Error(w, "note is empty", http.StatusBadRequest)
+			return
+		}
+		a, err := sess.annotate(body.EventType, body.EventTimestamp, body.Note)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, a)
+	default:
+		http.Error(w, "GET or POST required", http.StatusMethodNotAllowed)
+	}
+}
+
+// annotate adds a note to the event of the given type and timestamp.
+func (sess *session) annotate(eventType string, at time.Time, note string) (runlog.Annotation, error) {
+	history := sess.Bus.History(time.Time{})
+	index := -1
+	for i, ev := range history {
+		if string(ev.Type) == eventType && ev.Timestamp.Equal(at) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return runlog.Annotation{}, fmt.Errorf("no %s event at %s", eventType, at.Format(time.RFC3339Nano))
+	}
+	ev := history[index]
+	a := runlog.Annotation{
+		Event:          index,
+		EventType:      string(ev.Type),
+		EventTimestamp: ev.Timestamp,
+		StepIndex:      ev.StepIndex,
+		Note:           note,
+		Created:        time.Now().UTC(),
+		Run:            runOf(history, index),
+	}
+	if a.Run != "" && sess.RunsDir != "" {
+		run, err := runlog.Open(sess.RunsDir, a.Run)
+		if err == nil {
+			err = run.Annotate(a)
+		}
+		if err != nil {
+			return a, fmt.Errorf("annotation not saved: %w", err)
+		}
+	}
+
+	sess.annMu.Lock()
+	sess.annotations = append(sess.annotations, a)
+	sess.annMu.Unlock()
+	return a, nil
+}
+
+func (sess *session) annotationList() []runlog.Annotation {
+	sess.annMu.Lock()
+	defer sess.annMu.Unlock()
+	return append([]runlog.Annotation{}, sess.annotations...)
+}
+
+// runOf returns the run the event at index belongs to: the one whose
+// pipeline.start last precedes it, unless that run ended before it.
+func runOf(history []events.Event, index int) string {
+	for i := index; i >= 0; i-- {
+		switch history[i].Type {
+		case events.EventPipelineEnd:
+			if i < index {
+				return ""
+			}
+		case events.EventPipelineStart:
+			data, _ := history[i].Data.(map[string]any)
+			id, _ := data["run_id"].(string)
+			return id
+		}
+	}
+	return ""
+}
+
+// annotatedEvent is an event as exported, with the notes on it.
+type annotatedEvent struct {
+	events.Event
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// handleExport downloads the session's events with their annotations, as
+// NDJSON (one event per line, the default) or, with format=archive, as
+// one JSON document in the manner of a HAR file.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request, sess *session) {
+	history := sess.Bus.History(time.Time{})
+	notes := make(map[int][]string)
+	for _, a := range sess.annotationList() {
+		notes[a.Event] = append(notes[a.Event], a.Note)
+	}
+	entries := make([]annotatedEvent, len(history))
+	for i, ev := range history {
+		entries[i] = annotatedEvent{Event: ev, Annotations: notes[i]}
+	}
+
+	name := fmt.Sprintf("agsh-session-%s-%s", sess.ID, time.Now().UTC().Format("20060102-150405"))
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.ndjson"`)
+		enc := json.NewEncoder(w)
+		for _, e := range entries {
+			enc.Encode(e)
+		}
+	case "archive":
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		writeJSON(w, map[string]any{"log": map[string]any{
+			"version":     "1.0",
+			"creator":     map[string]string{"name": "agsh inspector"},
+			"session":     sess.SessionInfo,
+			"exported":    time.Now().UTC(),
+			"entries":     entries,
+			"annotations": sess.annotationList(),
+		}})
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q: expected ndjson or archive", format), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,29 @@
+package inspector
+
+import (
+	"cmp"
+	"net/http"
+
+	"github.com/cgast/agsh/internal/runlog"
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+// handleIntent serves the intent trace of a recorded run, by the run
+// query parameter (an id, a unique prefix or "latest", the default).
+func (s *Server) handleIntent(w http.ResponseWriter, r *http.Request, sess *session) {
+	if sess.RunsDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	run, err := runlog.Open(sess.RunsDir, cmp.Or(r.URL.Query().Get("run"), "latest"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	var trace spec.IntentTrace
+	if err := run.ReadJSON("intent.json", &trace); err != nil {
+		http.Error(w, "run "+run.ID+" has no intent trace", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, trace)
+}
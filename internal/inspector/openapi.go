@@ -0,0 +1,426 @@
+package inspector
+
+import (
+	"net/http"
+
+	"github.com/cgast/agsh/pkg/spec"
+)
+
+// handleOpenAPI serves an OpenAPI 3.1 description of the REST API and of
+// the events streamed at /ws, so dashboards and tests can be generated
+// against it.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, openAPIDocument())
+}
+
+// handleSpecSchema serves the JSON Schema of spec files, for editors to
+// check .agsh.yaml files against.
+func (s *Server) handleSpecSchema(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, spec.JSONSchema())
+}
+
+// schema is a JSON Schema or OpenAPI object; the helpers below keep the
+// document readable.
+type schema = map[string]any
+
+func ref(name string) schema { return schema{"$ref": "#/components/schemas/" + name} }
+
+func typed(typ, desc string) schema {
+	out := schema{"type": typ}
+	if desc != "" {
+		out["description"] = desc
+	}
+	return out
+}
+
+func stringProp(desc string) schema { return typed("string", desc) }
+func intProp(desc string) schema    { return typed("integer", desc) }
+func boolProp(desc string) schema   { return typed("boolean", desc) }
+
+func listProp(desc string) schema {
+	out := typed("array", desc)
+	out["items"] = schema{"type": "string"}
+	return out
+}
+
+func objectSchema(props schema, required ...string) schema {
+	out := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+func arrayOf(items schema) schema { return schema{"type": "array", "items": items} }
+
+// jsonResponse describes a 200 response with a JSON body.
+func jsonResponse(desc string, body schema) schema {
+	return schema{"200": schema{
+		"description": desc,
+		"content":     schema{"application/json": schema{"schema": body}},
+	}}
+}
+
+func getOp(summary string, responses schema) schema {
+	return schema{"get": schema{"summary": summary, "responses": responses}}
+}
+
+func postOp(summary string, body schema, responses schema) schema {
+	op := schema{"summary": summary, "responses": responses}
+	if body != nil {
+		op["requestBody"] = schema{"content": schema{"application/json": schema{"schema": body}}}
+	}
+	return schema{"post": op}
+}
+
+// withErrors adds error responses to responses.
+func withErrors(responses, errors schema) schema {
+	for code, r := range errors {
+		responses[code] = r
+	}
+	return responses
+}
+
+func statusResponse(statuses ...string) schema {
+	return jsonResponse("The outcome", objectSchema(schema{"status": schema{"type": "string", "enum": statuses}}, "status"))
+}
+
+// sessionParam selects the session an endpoint shows; see /api/sessions.
+var sessionParam = schema{
+	"name": "session", "in": "query", "schema": schema{"type": "string"},
+	"description": "ID of the session to show; the first session when left out",
+}
+
+// withSession adds the session parameter to the operations of each path
+// but those listed.
+func withSession(paths schema, except ...string) schema {
+	skip := make(map[string]bool, len(except))
+	for _, p := range except {
+		skip[p] = true
+	}
+	for path, item := range paths {
+		if skip[path] {
+			continue
+		}
+		for _, op := range item.(schema) {
+			op := op.(schema)
+			params, _ := op["parameters"].([]any)
+			op["parameters"] = append([]any{schema{"$ref": "#/components/parameters/session"}}, params...)
+		}
+	}
+	return paths
+}
+
+func openAPIDocument() schema {
+	return schema{
+		"openapi": "3.1.0",
+		"info": schema{
+			"title":       "agsh inspector",
+			"version":     "1",
+			"description": "REST API of the agsh inspector. Live events are streamed as server-sent events at /ws; their payloads are described by the Event schema and the EventData.* schemas.",
+		},
+		"paths": withSession(schema{
+			"/api/sessions": schema{
+				"get": schema{
+					"summary":   "Sessions shown: this process's first, then those of agsh processes that joined",
+					"responses": jsonResponse("Sessions", arrayOf(ref("Session"))),
+				},
+				"post": schema{
+					"summary": "Join: show a session served by the inspector of another agsh process on this machine",
+					"requestBody": schema{"content": schema{"application/json": schema{"schema": objectSchema(schema{
+						"id":      stringProp("ID of the session in the other process"),
+						"name":    stringProp(""),
+						"pid":     intProp(""),
+						"started": schema{"type": "string", "format": "date-time"},
+						"url":     stringProp("Loopback URL of the other process's inspector"),
+					}, "url")}}},
+					"responses": withErrors(jsonResponse("ID of the session here", objectSchema(schema{"id": stringProp("")}, "id")), schema{
+						"400": schema{"description": "The URL is not an http URL on a loopback address"},
+					}),
+				},
+				"delete": schema{
+					"summary":    "Leave: stop showing a session of another process",
+					"parameters": []any{schema{"name": "session", "in": "query", "required": true, "schema": schema{"type": "string"}}},
+					"responses": withErrors(statusResponse("removed"), schema{
+						"404": schema{"description": "No such session of another process"},
+					}),
+				},
+			},
+			"/api/status": getOp("Counters of the current session", jsonResponse("Status", objectSchema(schema{
+				"uptime":         stringProp("Time since the inspector started, e.g. 1m30s"),
+				"events":         intProp("Events in the bus history"),
+				"commands_run":   intProp("Commands that finished"),
+				"errors":         intProp("Commands that failed"),
+				"commands_total": intProp("Registered commands"),
+			}))),
+			"/api/context": getOp("Project, session and step context values", jsonResponse("Values by scope, then key; empty scopes are left out",
+				schema{"type": "object", "additionalProperties": schema{"type": "object"}})),
+			"/api/history":     getOp("Events in the bus history, oldest first", jsonResponse("Events", arrayOf(ref("Event")))),
+			"/api/checkpoints": getOp("Saved checkpoints", jsonResponse("Checkpoints", arrayOf(ref("Checkpoint")))),
+			"/api/commands":    getOp("Registered commands", jsonResponse("Commands", arrayOf(ref("Command")))),
+			"/api/health":      getOp("Run the backend health checks", jsonResponse("Results by name", arrayOf(ref("HealthResult")))),
+			"/api/artifact": schema{"get": schema{
+				"summary": "A step artifact, by the path recorded in its command.end or command.error event",
+				"parameters": []any{schema{
+					"name": "path", "in": "query", "required": true, "schema": schema{"type": "string"},
+				}},
+				"responses": schema{
+					"200": schema{"description": "The artifact's contents"},
+					"403": schema{"description": "The path is not below the runs directory"},
+					"404": schema{"description": "Artifacts are not available"},
+				},
+			}},
+			"/api/annotations": schema{
+				"get": schema{
+					"summary":   "Notes added to the session's events",
+					"responses": jsonResponse("Annotations, oldest first", arrayOf(ref("Annotation"))),
+				},
+				"post": schema{
+					"summary": "Annotate an event; notes on events of a recorded run are also saved in its run directory",
+					"requestBody": schema{"content": schema{"application/json": schema{"schema": objectSchema(schema{
+						"event_type":      stringProp("Type of the event"),
+						"event_timestamp": schema{"type": "string", "format": "date-time", "description": "Timestamp of the event, as sent"},
+						"note":            stringProp(""),
+					}, "event_type", "event_timestamp", "note")}}},
+					"responses": withErrors(jsonResponse("The annotation", ref("Annotation")), schema{
+						"400": schema{"description": "The body is not JSON or the note is empty"},
+						"404": schema{"description": "No such event, or the annotation could not be saved"},
+					}),
+				},
+			},
+			"/api/intent": schema{"get": schema{
+				"summary": "Intent trace of a recorded run: its goal, the steps taken for it and the success criteria checked",
+				"parameters": []any{schema{
+					"name": "run", "in": "query", "schema": schema{"type": "string", "default": "latest"},
+					"description": "Run id, unique prefix or latest",
+				}},
+				"responses": withErrors(jsonResponse("The trace", ref("IntentTrace")), schema{
+					"404": schema{"description": "No such run, the run has no trace, or runs are not available"},
+				}),
+			}},
+			"/api/export": schema{"get": schema{
+				"summary": "Download the session's events with the notes on them",
+				"parameters": []any{schema{
+					"name": "format", "in": "query",
+					"schema": schema{"type": "string", "enum": []string{"ndjson", "archive"}, "default": "ndjson"},
+				}},
+				"responses": schema{
+					"200": schema{
+						"description": "ndjson: one Event per line, with an annotations array of notes; archive: one JSON document, {log: {version, creator, session, exported, entries, annotations}}",
+						"content": schema{
+							"application/x-ndjson": schema{"schema": ref("Event")},
+							"application/json":     schema{"schema": schema{"type": "object"}},
+						},
+					},
+					"400": schema{"description": "Unknown format"},
+				},
+			}},
+			"/api/openapi.json":     getOp("This document", jsonResponse("OpenAPI document", schema{"type": "object"})),
+			"/api/spec-schema.json": getOp("JSON Schema of spec files in the latest version", jsonResponse("JSON Schema", schema{"type": "object"})),
+			"/api/approve": postOp("Approve the plan awaiting approval",
+				objectSchema(schema{"approver": stringProp("Name recorded in the audit log; not verified, the approver is inspector@<client host>")}),
+				statusResponse("approved", "no_pending_approval")),
+			"/api/reject": postOp("Reject the plan awaiting approval",
+				objectSchema(schema{
+					"feedback": stringProp("Why, for the agent to revise the plan"),
+					"approver": stringProp("Name recorded in the audit log; not verified, the rejecter is inspector@<client host>"),
+				}),
+				statusResponse("rejected", "no_pending_approval")),
+			"/api/answer": postOp("Answer the question of an ask step",
+				objectSchema(schema{
+					"id":     stringProp("ID of the input.requested event"),
+					"answer": stringProp("The answer; an empty answer takes the question's default"),
+				}, "id", "answer"),
+				withErrors(statusResponse("answered"), schema{
+					"400": schema{"description": "The body is not JSON"},
+					"409": schema{"description": "No question with that id is waiting, or the answer is not one of its choices"},
+					"501": schema{"description": "Answering is not available"},
+				})),
+			"/ws": schema{"get": schema{
+				"summary": "Live events as server-sent events: the bus history first, then new events. Each message's data is an Event",
+				"responses": schema{"200": schema{
+					"description": "Event stream",
+					"content":     schema{"text/event-stream": schema{"schema": ref("Event")}},
+				}},
+			}},
+		}, "/api/sessions", "/api/openapi.json", "/api/spec-schema.json"),
+		"components": schema{
+			"schemas":    componentSchemas(),
+			"parameters": schema{"session": sessionParam},
+		},
+	}
+}
+
+func componentSchemas() schema {
+	out := schema{
+		"Event": objectSchema(schema{
+			"type":       stringProp("Event type, e.g. command.end; the matching EventData.<type> schema describes data"),
+			"timestamp":  schema{"type": "string", "format": "date-time"},
+			"data":       schema{"description": "Depends on the type"},
+			"step_index": intProp("Pipeline step the event belongs to"),
+			"duration":   intProp("Duration in nanoseconds, for events that end something"),
+		}, "type", "timestamp"),
+		"Checkpoint": objectSchema(schema{
+			"name":      stringProp("Checkpoint name"),
+			"timestamp": schema{"type": "string", "format": "date-time"},
+			"size":      intProp("Bytes on disk"),
+		}, "name", "timestamp"),
+		"Command": objectSchema(schema{
+			"name":        stringProp("Full name, e.g. github:pr:list"),
+			"description": stringProp(""),
+			"namespace":   stringProp("e.g. github"),
+			"examples": arrayOf(objectSchema(schema{
+				"description": stringProp(""),
+				"input":       schema{"description": "Sample input payload"},
+				"output":      schema{"description": "The output it produces"},
+			})),
+		}, "name", "description", "namespace"),
+		"Session": objectSchema(schema{
+			"id":      stringProp("Pass as the session parameter of the other endpoints"),
+			"name":    stringProp("e.g. agent or run report.agsh.yaml"),
+			"pid":     intProp("Process running the session"),
+			"started": schema{"type": "string", "format": "date-time"},
+			"url":     stringProp("Inspector of the other process serving the session; empty for this process's sessions"),
+		}, "id", "name", "started"),
+		"Annotation": objectSchema(schema{
+			"event":           intProp("Index of the event in the session's history"),
+			"event_type":      stringProp(""),
+			"event_timestamp": schema{"type": "string", "format": "date-time"},
+			"step_index":      intProp(""),
+			"note":            stringProp(""),
+			"created":         schema{"type": "string", "format": "date-time"},
+			"run":             stringProp("Run the event belongs to, whose directory holds the note"),
+		}, "event", "event_type", "event_timestamp", "note", "created"),
+		"AssertionResult": objectSchema(schema{
+			"type":     stringProp("e.g. contains"),
+			"target":   stringProp("e.g. output.lines"),
+			"expected": schema{"description": "Expected value or pattern"},
+			"actual":   schema{"description": "What was found; text is cut to 200 characters"},
+			"passed":   boolProp(""),
+			"message":  stringProp("Why the assertion failed"),
+		}, "type", "passed"),
+		"IntentTrace": objectSchema(schema{
+			"spec":    stringProp(""),
+			"run_id":  stringProp(""),
+			"goal":    stringProp(""),
+			"created": schema{"type": "string", "format": "date-time"},
+			"steps": arrayOf(objectSchema(schema{
+				"step":     stringProp("Step ID, or <n> <command>"),
+				"command":  stringProp(""),
+				"intent":   stringProp("What the step was for"),
+				"risk":     stringProp("read-only, write or destructive"),
+				"status":   schema{"type": "string", "enum": []string{"ok", "error", "skipped", "verify_failed", "not_run"}},
+				"error":    stringProp(""),
+				"drift":    listProp("Where the step strayed from its declaration"),
+				"writes":   listProp("Files the step was seen writing"),
+				"verified": boolProp("Outcome of the step's own verification"),
+			}, "step", "command", "intent", "status")),
+			"criteria": arrayOf(objectSchema(schema{
+				"type":    stringProp(""),
+				"target":  stringProp(""),
+				"passed":  boolProp(""),
+				"message": stringProp(""),
+				"steps":   listProp("Steps whose result the criterion checked"),
+			}, "type", "passed")),
+			"achieved": boolProp("Every step succeeded and every criterion passed"),
+		}, "spec", "goal", "steps", "criteria", "achieved"),
+		"HealthResult": objectSchema(schema{
+			"name":     stringProp("Backend name"),
+			"ok":       boolProp(""),
+			"error":    stringProp("Why the check failed"),
+			"duration": intProp("Nanoseconds"),
+		}, "name", "ok"),
+	}
+	for typ, data := range eventDataSchemas() {
+		out["EventData."+typ] = data
+	}
+	return out
+}
+
+// eventDataSchemas describes the data of the events the runtime publishes
+// most; other events carry free-form objects.
+func eventDataSchemas() map[string]schema {
+	artifacts := listProp("Paths of the artifacts the step attached")
+	return map[string]schema{
+		"command.start": objectSchema(schema{
+			"command": stringProp(""),
+			"args":    listProp(""),
+			"intent":  stringProp(""),
+		}, "command"),
+		"command.end": objectSchema(schema{
+			"command":   stringProp(""),
+			"status":    schema{"type": "string", "enum": []string{"ok"}},
+			"artifacts": artifacts,
+		}, "command", "status"),
+		"command.error": objectSchema(schema{
+			"command":   stringProp(""),
+			"error":     stringProp(""),
+			"artifacts": artifacts,
+		}, "command", "error"),
+		"pipeline.start": objectSchema(schema{
+			"step_count": intProp(""),
+			"run_id":     stringProp("Run directory of a recorded run"),
+			"resumed_at": intProp("First step run when resuming"),
+		}, "step_count"),
+		"pipeline.end": objectSchema(schema{
+			"success":        boolProp(""),
+			"step_count":     intProp("Set on success"),
+			"error":          stringProp("Why the pipeline stopped"),
+			"verify_failure": stringProp("Set instead of error when verification stopped it"),
+			"step":           intProp("Step it stopped at"),
+		}, "success"),
+		"pipeline.timeout": objectSchema(schema{
+			"timeout":        stringProp("e.g. 30m0s"),
+			"step":           intProp(""),
+			"rolled_back_to": stringProp("Checkpoint restored"),
+		}, "timeout", "step"),
+		"verify.result": objectSchema(schema{
+			"step":       intProp("Set for a pipeline step's assertions"),
+			"command":    stringProp("Set for a single command's assertions"),
+			"type":       schema{"type": "string", "enum": []string{"success_criteria"}},
+			"source":     schema{"type": "string", "enum": []string{"repl"}},
+			"passed":     boolProp(""),
+			"summary":    stringProp("e.g. 2/3 assertions passed"),
+			"assertions": arrayOf(ref("AssertionResult")),
+		}, "passed"),
+		"verify.drift": objectSchema(schema{
+			"step":     intProp(""),
+			"command":  stringProp(""),
+			"intent":   stringProp(""),
+			"findings": listProp("What the step did that its declaration rules out"),
+			"blocked":  boolProp("Whether the step was failed"),
+		}, "step", "findings", "blocked"),
+		"verify.output_schema": objectSchema(schema{
+			"step":     intProp(""),
+			"command":  stringProp(""),
+			"passed":   boolProp(""),
+			"problems": listProp("e.g. count: expected integer, got string"),
+			"error":    stringProp("Why the check could not be made"),
+		}, "step", "command", "passed"),
+		"context.change": objectSchema(schema{
+			"scope":   stringProp(""),
+			"key":     stringProp(""),
+			"op":      schema{"type": "string", "enum": []string{"set", "delete"}},
+			"source":  stringProp("Command or client that made the change"),
+			"deleted": boolProp(""),
+		}, "scope", "key", "op"),
+		"input.requested": objectSchema(schema{
+			"id":      stringProp("Answer with this id at /api/answer"),
+			"kind":    schema{"type": "string", "enum": []string{"input"}},
+			"step":    stringProp("ID of the asking step"),
+			"prompt":  stringProp(""),
+			"choices": listProp("Allowed answers; any answer when empty"),
+			"default": stringProp(""),
+			"key":     stringProp("Session context key the answer is stored under"),
+		}, "id", "prompt"),
+		"input.answered": objectSchema(schema{
+			"id":     stringProp(""),
+			"answer": stringProp(""),
+			"source": schema{"type": "string", "enum": []string{"terminal", "inspector", "agent"}},
+		}, "id", "answer"),
+		"plan.approval_requested": objectSchema(schema{
+			"plan_id": stringProp("Approve at /api/approve or reject at /api/reject"),
+			"message": stringProp(""),
+		}, "plan_id"),
+	}
+}
@@ -1,11 +1,15 @@
 package inspector
 
 import (
+	gocontext "context"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"io/fs"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,111 +22,132 @@ import (
 //go:embed ui/*
 var embeddedUI embed.FS
 
-// Server is the inspector HTTP + WebSocket server.
+// Server is the inspector HTTP + WebSocket server. It shows one or more
+// sessions: the runtime of this process, and those of other agsh
+// processes that joined it (see StartShared).
 type Server struct {
-	bus          events.EventBus
-	store        agshctx.ContextStore
-	checkpoints  verify.CheckpointManager
-	registry     *platform.Registry
-	mux          *http.ServeMux
-	wsClients    map[*wsClient]bool
-	wsMu         sync.Mutex
-	startTime    time.Time
-
-	// Approval channel for plan approval/rejection via the UI.
-	approvalCh   chan ApprovalAction
+	registry *platform.Registry
+	mux      *http.ServeMux
+
+	mu       sync.Mutex
+	sessions []*session // this process's first
+	nextID   int
 }
 
 // ApprovalAction represents an approve/reject action from the inspector UI.
 type ApprovalAction struct {
 	Action   string `json:"action"` // "approve" or "reject"
 	Feedback string `json:"feedback,omitempty"`
+
+	// Approver is "inspector@<host>" of the client. The inspector has no
+	// login, so it is as trustworthy as the network the inspector listens
+	// on. Name is the name the request gave, which is not verified.
+	Approver string `json:"approver"`
+	Name     string `json:"name,omitempty"`
+}
+
+// approverOf names who made an approval request: its client's host, not
+// whatever name the request gave.
+func approverOf(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "inspector@" + host
 }
 
+// AnswerFunc answers the pending question of an ask step, identified by
+// the id of its input.requested event.
+type AnswerFunc func(id, answer string) error
+
 // wsClient represents a connected WebSocket client.
 type wsClient struct {
 	send chan []byte
 	done chan struct{}
 }
 
-// New creates a new inspector server.
+// New creates a new inspector server showing the runtime of this process
+// as its first session.
 func New(bus events.EventBus, store agshctx.ContextStore, registry *platform.Registry, checkpoints verify.CheckpointManager) *Server {
 	s := &Server{
-		bus:         bus,
-		store:       store,
-		checkpoints: checkpoints,
-		registry:    registry,
-		mux:         http.NewServeMux(),
-		wsClients:   make(map[*wsClient]bool),
-		startTime:   time.Now(),
-		approvalCh:  make(chan ApprovalAction, 1),
+		registry: registry,
+		mux:      http.NewServeMux(),
 	}
+	s.AddSession(SessionOptions{Name: "agsh", Bus: bus, Store: store, Checkpoints: checkpoints})
 
 	// Serve embedded UI.
 	uiFS, _ := fs.Sub(embeddedUI, "ui")
 	s.mux.Handle("/", http.FileServer(http.FS(uiFS)))
 
 	// WebSocket for live events.
-	s.mux.HandleFunc("/ws", s.handleWebSocket)
-
-	// REST API endpoints.
-	s.mux.HandleFunc("/api/status", s.handleStatus)
-	s.mux.HandleFunc("/api/context", s.handleContext)
-	s.mux.HandleFunc("/api/history", s.handleHistory)
-	s.mux.HandleFunc("/api/checkpoints", s.handleCheckpoints)
-	s.mux.HandleFunc("/api/commands", s.handleCommands)
+	s.mux.HandleFunc("/ws", s.perSession(s.handleWebSocket))
+
+	// REST API endpoints. All but sessions, openapi.json and
+	// spec-schema.json take a session query parameter; without one, they
+	// show the first session.
+	s.mux.HandleFunc("/api/sessions", s.handleSessions)
+	s.mux.HandleFunc("/api/status", s.perSession(s.handleStatus))
+	s.mux.HandleFunc("/api/context", s.perSession(s.handleContext))
+	s.mux.HandleFunc("/api/history", s.perSession(s.handleHistory))
+	s.mux.HandleFunc("/api/checkpoints", s.perSession(s.handleCheckpoints))
+	s.mux.HandleFunc("/api/commands", s.perSession(s.handleCommands))
+	s.mux.HandleFunc("/api/health", s.perSession(s.handleHealth))
+	s.mux.HandleFunc("/api/artifact", s.perSession(s.handleArtifact))
+	s.mux.HandleFunc("/api/annotations", s.perSession(s.handleAnnotations))
+	s.mux.HandleFunc("/api/intent", s.perSession(s.handleIntent))
+	s.mux.HandleFunc("/api/export", s.perSession(s.handleExport))
+	s.mux.HandleFunc("/api/openapi.json", s.handleOpenAPI)
+	s.mux.HandleFunc("/api/spec-schema.json", s.handleSpecSchema)
 
 	// Intervention endpoints.
-	s.mux.HandleFunc("/api/approve", s.handleApprove)
-	s.mux.HandleFunc("/api/reject", s.handleReject)
+	s.mux.HandleFunc("/api/approve", s.perSession(s.handleApprove))
+	s.mux.HandleFunc("/api/reject", s.perSession(s.handleReject))
+	s.mux.HandleFunc("/api/answer", s.perSession(s.handleAnswer))
 
 	return s
 }
 
+// first returns the session of this process New created.
+func (s *Server) first() *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[0]
+}
+
+// SetSessionName names the first session, e.g. after the mode agsh runs in.
+func (s *Server) SetSessionName(name string) {
+	s.first().SessionInfo.Name = name
+}
+
+// SetRunsDir sets the directory pipeline runs of the first session store
+// step artifacts in. /api/artifact serves files below it.
+func (s *Server) SetRunsDir(dir string) {
+	s.first().RunsDir = dir
+}
+
+// SetAnswerFunc sets how /api/answer answers ask steps of the first
+// session. Without one, the inspector only shows their questions.
+func (s *Server) SetAnswerFunc(fn AnswerFunc) {
+	s.first().Answer = fn
+}
+
 // Start begins serving the inspector on the given port.
 func (s *Server) Start(port int) error {
-	// Subscribe to all events and broadcast to WebSocket clients.
-	ch := s.bus.Subscribe()
-	go s.broadcastEvents(ch)
-
 	addr := fmt.Sprintf(":%d", port)
 	return http.ListenAndServe(addr, s.mux)
 }
 
 // StartAsync starts the server in a goroutine and returns immediately.
 func (s *Server) StartAsync(port int) {
-	// Subscribe to all events and broadcast to WebSocket clients.
-	ch := s.bus.Subscribe()
-	go s.broadcastEvents(ch)
-
 	go func() {
 		addr := fmt.Sprintf(":%d", port)
 		http.ListenAndServe(addr, s.mux)
 	}()
 }
 
-func (s *Server) broadcastEvents(ch <-chan events.Event) {
-	for ev := range ch {
-		data, err := json.Marshal(ev)
-		if err != nil {
-			continue
-		}
-
-		s.wsMu.Lock()
-		for client := range s.wsClients {
-			select {
-			case client.send <- data:
-			default:
-				// Client is slow, drop the event.
-			}
-		}
-		s.wsMu.Unlock()
-	}
-}
-
 // handleWebSocket upgrades an HTTP connection to a WebSocket.
 // Uses a simple polling-based approach without external deps.
-func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request, sess *session) {
 	// Since we don't want to add gorilla/websocket as a dependency,
 	// we use Server-Sent Events (SSE) instead — works in all browsers
 	// and doesn't require external deps.
@@ -142,19 +167,19 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		done: make(chan struct{}),
 	}
 
-	s.wsMu.Lock()
-	s.wsClients[client] = true
-	s.wsMu.Unlock()
+	sess.wsMu.Lock()
+	sess.wsClients[client] = true
+	sess.wsMu.Unlock()
 
 	defer func() {
-		s.wsMu.Lock()
-		delete(s.wsClients, client)
-		s.wsMu.Unlock()
+		sess.wsMu.Lock()
+		delete(sess.wsClients, client)
+		sess.wsMu.Unlock()
 		close(client.done)
 	}()
 
 	// Send existing history as initial state.
-	history := s.bus.History(time.Time{})
+	history := sess.Bus.History(time.Time{})
 	for _, ev := range history {
 		data, err := json.Marshal(ev)
 		if err != nil {
@@ -180,8 +205,8 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
-	history := s.bus.History(time.Time{})
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, sess *session) {
+	history := sess.Bus.History(time.Time{})
 	commandCount := 0
 	errorCount := 0
 	for _, ev := range history {
@@ -194,7 +219,7 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, map[string]any{
-		"uptime":        time.Since(s.startTime).String(),
+		"uptime":        time.Since(sess.Started).String(),
 		"events":        len(history),
 		"commands_run":  commandCount,
 		"errors":        errorCount,
@@ -202,12 +227,12 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *Server) handleContext(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleContext(w http.ResponseWriter, r *http.Request, sess *session) {
 	scopes := []string{agshctx.ScopeProject, agshctx.ScopeSession, agshctx.ScopeStep}
 	result := make(map[string]map[string]any)
 
 	for _, scope := range scopes {
-		items, err := s.store.List(scope)
+		items, err := sess.Store.List(scope)
 		if err == nil && len(items) > 0 {
 			result[scope] = items
 		}
@@ -216,18 +241,18 @@ func (s *Server) handleContext(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, result)
 }
 
-func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
-	history := s.bus.History(time.Time{})
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, sess *session) {
+	history := sess.Bus.History(time.Time{})
 	writeJSON(w, history)
 }
 
-func (s *Server) handleCheckpoints(w http.ResponseWriter, r *http.Request) {
-	if s.checkpoints == nil {
+func (s *Server) handleCheckpoints(w http.ResponseWriter, r *http.Request, sess *session) {
+	if sess.Checkpoints == nil {
 		writeJSON(w, []any{})
 		return
 	}
 
-	infos, err := s.checkpoints.List()
+	infos, err := sess.Checkpoints.List()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -236,7 +261,7 @@ func (s *Server) handleCheckpoints(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, infos)
 }
 
-func (s *Server) handleCommands(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleCommands(w http.ResponseWriter, r *http.Request, sess *session) {
 	cmds := s.registry.List("")
 	infos := make([]map[string]any, len(cmds))
 	for i, cmd := range cmds {
@@ -245,25 +270,63 @@ func (s *Server) handleCommands(w http.ResponseWriter, r *http.Request) {
 			"description": cmd.Description(),
 			"namespace":   cmd.Namespace(),
 		}
+		if examples := platform.Examples(cmd); len(examples) > 0 {
+			infos[i]["examples"] = examples
+		}
 	}
 	writeJSON(w, infos)
 }
 
-func (s *Server) handleApprove(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request, sess *session) {
+	ctx, cancel := gocontext.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	writeJSON(w, s.registry.CheckHealth(ctx))
+}
+
+// handleArtifact serves a step artifact by the path recorded in the
+// command.end or command.error event.
+func (s *Server) handleArtifact(w http.ResponseWriter, r *http.Request, sess *session) {
+	if sess.RunsDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	root, err := filepath.Abs(sess.RunsDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	path, err := filepath.Abs(r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if rel, err := filepath.Rel(root, path); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		http.Error(w, "not an artifact path", http.StatusForbidden)
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+func (s *Server) handleApprove(w http.ResponseWriter, r *http.Request, sess *session) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "POST required", http.StatusMethodNotAllowed)
 		return
 	}
 
+	var body struct {
+		Approver string `json:"approver"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
 	select {
-	case s.approvalCh <- ApprovalAction{Action: "approve"}:
+	case sess.approvalCh <- ApprovalAction{Action: "approve", Approver: approverOf(r), Name: body.Approver}:
 		writeJSON(w, map[string]string{"status": "approved"})
 	default:
 		writeJSON(w, map[string]string{"status": "no_pending_approval"})
 	}
 }
 
-func (s *Server) handleReject(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleReject(w http.ResponseWriter, r *http.Request, sess *session) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "POST required", http.StatusMethodNotAllowed)
 		return
@@ -271,17 +334,43 @@ func (s *Server) handleReject(w http.ResponseWriter, r *http.Request) {
 
 	var body struct {
 		Feedback string `json:"feedback"`
+		Approver string `json:"approver"`
 	}
 	json.NewDecoder(r.Body).Decode(&body)
 
 	select {
-	case s.approvalCh <- ApprovalAction{Action: "reject", Feedback: body.Feedback}:
+	case sess.approvalCh <- ApprovalAction{Action: "reject", Feedback: body.Feedback, Approver: approverOf(r), Name: body.Approver}:
 		writeJSON(w, map[string]string{"status": "rejected"})
 	default:
 		writeJSON(w, map[string]string{"status": "no_pending_approval"})
 	}
 }
 
+func (s *Server) handleAnswer(w http.ResponseWriter, r *http.Request, sess *session) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	if sess.Answer == nil {
+		http.Error(w, "answering is not available", http.StatusNotImplemented)
+		return
+	}
+
+	var body struct {
+		ID     string `json:"id"`
+		Answer string `json:"answer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := sess.Answer(body.ID, body.Answer); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "answered"})
+}
+
 func writeJSON(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
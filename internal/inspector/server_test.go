@@ -0,0 +1,31 @@
+package inspector
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleApproveApprover(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		body       string
+		want       ApprovalAction
+	}{
+		{"192.0.2.1:1234", `{}`, ApprovalAction{Action: "approve", Approver: "inspector@192.0.2.1"}},
+		{"[::1]:8080", `{"approver":"alice"}`, ApprovalAction{Action: "approve", Approver: "inspector@::1", Name: "alice"}},
+		{"pipe", `{"approver":"bob"}`, ApprovalAction{Action: "approve", Approver: "inspector@pipe", Name: "bob"}},
+	}
+	s := &Server{}
+	for _, tt := range tests {
+		sess := &session{approvalCh: make(chan ApprovalAction, 1)}
+		r := httptest.NewRequest("POST", "/api/approve", strings.NewReader(tt.body))
+		r.RemoteAddr = tt.remoteAddr
+		s.handleApprove(httptest.NewRecorder(), r, sess)
+
+		// The name in the body is recorded, but the client's host approves.
+		if got := <-sess.approvalCh; got != tt.want {
+			t.Errorf("%s %s: action = %+v, want %+v", tt.remoteAddr, tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cgast/agsh/internal/runlog"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+// SessionInfo describes a session for /api/sessions.
+type SessionInfo struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"` // e.g. "agent" or "run report.agsh.yaml"
+	PID     int       `json:"pid,omitempty"`
+	Started time.Time `json:"started"`
+	URL     string    `json:"url,omitempty"` // inspector of another process serving the session
+}
+
+// SessionOptions describes a session of this process: where its events,
+// context and checkpoints are, and how its questions are answered.
+type SessionOptions struct {
+	Name        string
+	Bus         events.EventBus
+	Store       agshctx.ContextStore
+	Checkpoints verify.CheckpointManager
+	RunsDir     string     // see SetRunsDir
+	Answer      AnswerFunc // see SetAnswerFunc
+}
+
+// session is one agsh runtime the inspector shows: one of this process,
+// or one another process's inspector serves, which requests are proxied to.
+type session struct {
+	SessionInfo
+	SessionOptions
+
+	events    <-chan events.Event
+	wsClients map[*wsClient]bool
+	wsMu      sync.Mutex
+
+	// Approval channel for plan approval/rejection via the UI.
+	approvalCh chan ApprovalAction
+
+	annMu       sync.Mutex
+	annotations []runlog.Annotation
+
+	proxy *httputil.ReverseProxy
+}
+
+// AddSession adds a session of this process, such as one per agent
+// client, and returns its ID. Its events are streamed from then on.
+func (s *Server) AddSession(opts SessionOptions) string {
+	sess := &session{
+		SessionInfo:    SessionInfo{Name: opts.Name, PID: os.Getpid(), Started: time.Now()},
+		SessionOptions: opts,
+		events:         opts.Bus.Subscribe(),
+		wsClients:      make(map[*wsClient]bool),
+		approvalCh:     make(chan ApprovalAction, 1),
+	}
+	go sess.broadcastEvents()
+	return s.addSession(sess)
+}
+
+// RemoveSession stops showing a session.
+func (s *Server) RemoveSession(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sess := range s.sessions {
+		if sess.ID == id {
+			s.sessions = append(s.sessions[:i], s.sessions[i+1:]...)
+			if sess.Bus != nil {
+				sess.Bus.Unsubscribe(sess.events)
+			}
+			return
+		}
+	}
+}
+
+// Sessions lists the sessions shown, this process's first.
+func (s *Server) Sessions() []SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]SessionInfo, len(s.sessions))
+	for i, sess := range s.sessions {
+		infos[i] = sess.SessionInfo
+	}
+	return infos
+}
+
+func (s *Server) addSession(sess *session) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	sess.ID = strconv.Itoa(s.nextID)
+	s.sessions = append(s.sessions, sess)
+	return sess.ID
+}
+
+// session returns the session a request selects with its session query
+// parameter, or the first when it selects none.
+func (s *Server) session(r *http.Request) *session {
+	id := r.URL.Query().Get("session")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.sessions {
+		if id == "" || sess.ID == id {
+			return sess
+		}
+	}
+	return nil
+}
+
+// perSession routes a request to the session it selects: handled here for
+// a session of this process, else proxied to the inspector serving it.
+func (s *Server) perSession(h func(http.ResponseWriter, *http.Request, *session)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sess := s.session(r)
+		switch {
+		case sess == nil:
+			http.Error(w, "no such session", http.StatusNotFound)
+		case sess.proxy != nil:
+			sess.proxy.ServeHTTP(w, r)
+		default:
+			h(w, r, sess)
+		}
+	}
+}
+
+// handleSessions lists sessions (GET), registers a session served by
+// another process's inspector (POST), or removes one (DELETE).
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.Sessions())
+	case http.MethodPost:
+		var info SessionInfo
+		if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, err := s.addRemote(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]string{"id": id})
+	case http.MethodDelete:
+		sess := s.session(r)
+		if sess == nil || sess.proxy == nil || r.URL.Query().Get("session") == "" {
+			http.Error(w, "no such session of another process", http.StatusNotFound)
+			return
+		}
+		s.RemoveSession(sess.ID)
+		writeJSON(w, map[string]string{"status": "removed"})
+	default:
+		http.Error(w, "GET, POST or DELETE required", http.StatusMethodNotAllowed)
+	}
+}
+
+// addRemote adds a session served by the inspector at info.URL, where it
+// has ID info.ID. Only inspectors on this machine are proxied to.
+func (s *Server) addRemote(info SessionInfo) (string, error) {
+	target, err := url.Parse(info.URL)
+	if err != nil || target.Scheme != "http" {
+		return "", fmt.Errorf("url %q: expected an http URL", info.URL)
+	}
+	if ip := net.ParseIP(target.Hostname()); ip == nil || !ip.IsLoopback() {
+		return "", fmt.Errorf("url %q: expected a loopback address", info.URL)
+	}
+	// The ID a session is registered with is its ID in the other process.
+	remoteID := info.ID
+	sess := &session{SessionInfo: info}
+	if sess.Started.IsZero() {
+		sess.Started = time.Now()
+	}
+	sess.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			q := pr.Out.URL.Query()
+			q.Set("session", remoteID)
+			pr.Out.URL.RawQuery = q.Encode()
+		},
+		// A process that exited without leaving is dropped.
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			s.RemoveSession(sess.ID)
+			http.Error(w, "session ended: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+	return s.addSession(sess), nil
+}
+
+// StartShared serves the inspector on port. When another agsh process's
+// inspector already serves it, this process's sessions are served on a
+// free loopback port instead and registered there, so one inspector shows
+// every process. It returns the inspector's URL and a function that
+// deregisters from the other inspector on exit.
+func (s *Server) StartShared(port int) (string, func(), error) {
+	primary := fmt.Sprintf("http://localhost:%d", port)
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err == nil {
+		go http.Serve(ln, s.mux)
+		return primary, func() {}, nil
+	}
+	if !isInspector(primary) {
+		return "", nil, err
+	}
+
+	ln, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	go http.Serve(ln, s.mux)
+	self := "http://" + ln.Addr().String()
+
+	var ids []string
+	for _, info := range s.Sessions() {
+		info.URL = self
+		id, err := register(primary, info)
+		if err != nil {
+			return "", nil, err
+		}
+		ids = append(ids, id)
+	}
+	leave := func() {
+		for _, id := range ids {
+			req, _ := http.NewRequest(http.MethodDelete, primary+"/api/sessions?session="+id, nil)
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	return primary, leave, nil
+}
+
+// isInspector reports whether an agsh inspector serves base.
+func isInspector(base string) bool {
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(base + "/api/sessions")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// register adds info as a session of the inspector at base.
+func register(base string, info SessionInfo) (string, error) {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.Post(base+"/api/sessions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("join inspector at %s: %w", base, err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		ID string `json:"id"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("join inspector at %s: %s", base, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("join inspector at %s: %w", base, err)
+	}
+	return out.ID, nil
+}
+
+func (sess *session) broadcastEvents() {
+	for ev := range sess.events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			continue
+		}
+
+		sess.wsMu.Lock()
+		for client := range sess.wsClients {
+			select {
+			case client.send <- data:
+			default:
+				// Client is slow, drop the event.
+			}
+		}
+		sess.wsMu.Unlock()
+	}
+}
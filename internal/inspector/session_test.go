@@ -0,0 +1,108 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/platform"
+	"github.com/cgast/agsh/pkg/platform/fs"
+)
+
+// getJSON fetches url and decodes its JSON body into v, returning the
+// status code.
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestSessionProxy(t *testing.T) {
+	primary := New(events.NewMemoryBus(), nil, platform.NewRegistry(), nil)
+	primarySrv := httptest.NewServer(primary.mux)
+	defer primarySrv.Close()
+
+	// Another process's inspector, with its own commands.
+	registry := platform.NewRegistry()
+	registry.Register(&fs.ListCommand{})
+	other := New(events.NewMemoryBus(), nil, registry, nil)
+	other.SetSessionName("run report.agsh.yaml")
+	otherSrv := httptest.NewServer(other.mux)
+
+	info := other.Sessions()[0]
+	info.URL = otherSrv.URL
+	id, err := register(primarySrv.URL, info)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	var sessions []SessionInfo
+	getJSON(t, primarySrv.URL+"/api/sessions", &sessions)
+	if len(sessions) != 2 || sessions[1].ID != id || sessions[1].Name != "run report.agsh.yaml" {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+
+	// Requests for the other session are answered by its inspector.
+	var commands []map[string]any
+	getJSON(t, primarySrv.URL+"/api/commands?session="+id, &commands)
+	if len(commands) != 1 || commands[0]["name"] != "fs:list" {
+		t.Errorf("proxied commands = %v", commands)
+	}
+	commands = nil
+	getJSON(t, primarySrv.URL+"/api/commands", &commands)
+	if len(commands) != 0 {
+		t.Errorf("local commands = %v", commands)
+	}
+
+	// Only sessions of other processes can be removed.
+	req, _ := http.NewRequest(http.MethodDelete, primarySrv.URL+"/api/sessions?session=1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("DELETE of a local session: status %d", resp.StatusCode)
+	}
+
+	// A session whose process went away is dropped.
+	otherSrv.Close()
+	if code := getJSON(t, primarySrv.URL+"/api/commands?session="+id, nil); code != http.StatusBadGateway {
+		t.Errorf("request to an ended session: status %d", code)
+	}
+	if got := primary.Sessions(); len(got) != 1 {
+		t.Errorf("sessions after the other process ended = %+v", got)
+	}
+}
+
+func TestAddRemoteRejectsNonLocal(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "http://127.0.0.1:4000"},
+		{url: "http://[::1]:4000"},
+		{url: "https://127.0.0.1:4000", wantErr: true},
+		{url: "http://192.0.2.1:4000", wantErr: true},
+		{url: "http://localhost:4000", wantErr: true}, // names are not resolved
+		{url: "127.0.0.1:4000", wantErr: true},
+	}
+	for _, tt := range tests {
+		s := New(events.NewMemoryBus(), nil, platform.NewRegistry(), nil)
+		_, err := s.addRemote(SessionInfo{ID: "1", URL: tt.url})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("addRemote(%q): err = %v, want error %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
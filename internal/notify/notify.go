@@ -0,0 +1,228 @@
+// Package notify tells the user, away from the screen, when a run needs
+// them or is done: a plan awaits approval or an ask step an answer,
+// verification fails, or a pipeline finishes. As in .agsh/config.yaml:
+//
+//	notify:
+//	  method: command            # bell, osc or command
+//	  command: ["notify-send", "{title}", "{message}"]
+//	  on: [approval, verify_failed, run_finished]
+//
+// "bell" rings the terminal bell. "osc" sends an OSC 9 escape, which
+// terminals such as iTerm2, Windows Terminal and kitty show as a desktop
+// notification. "command" runs a program, replacing {title}, {message} and
+// {event} in its arguments.
+package notify
+
+import (
+	gocontext "context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cgast/agsh/pkg/events"
+)
+
+// Occasions a notification is sent on.
+const (
+	OnApproval     = "approval"      // a plan awaits approval or an ask step an answer
+	OnVerifyFailed = "verify_failed" // an assertion, drift check or output schema check failed
+	OnRunFinished  = "run_finished"  // a pipeline succeeded or failed
+)
+
+// Occasions lists every occasion.
+var Occasions = []string{OnApproval, OnVerifyFailed, OnRunFinished}
+
+// Methods of notifying.
+const (
+	MethodBell    = "bell"
+	MethodOSC     = "osc"
+	MethodCommand = "command"
+)
+
+// Methods lists every method.
+var Methods = []string{MethodBell, MethodOSC, MethodCommand}
+
+// EventTypes are the events notifications are made from.
+var EventTypes = []events.EventType{
+	events.EventPlanApproval,
+	events.EventInputRequested,
+	events.EventVerifyResult,
+	events.EventVerifyDrift,
+	events.EventVerifyOutputSchema,
+	events.EventPipelineEnd,
+}
+
+// commandTimeout bounds how long a notify command may run.
+const commandTimeout = 10 * time.Second
+
+// Options configures a Notifier.
+type Options struct {
+	Method  string
+	Command []string // argv, for MethodCommand
+	On      []string // occasions; empty means all
+}
+
+// Notification is what an event is announced as.
+type Notification struct {
+	Occasion string
+	Title    string
+	Message  string
+}
+
+// Notifier sends notifications for events.
+type Notifier struct {
+	opts Options
+	out  io.Writer // the terminal, for the bell and OSC escapes
+
+	// run executes a notify command; replaced in tests.
+	run func(ctx gocontext.Context, argv []string) error
+}
+
+// New creates a notifier writing bells and escapes to out.
+func New(opts Options, out io.Writer) *Notifier {
+	return &Notifier{opts: opts, out: out, run: runCommand}
+}
+
+// For returns the notification an event calls for, if any.
+func For(ev events.Event) (Notification, bool) {
+	data, _ := ev.Data.(map[string]any)
+	str := func(key string) string {
+		if v, ok := data[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	passed := func(key string) bool {
+		b, ok := data[key].(bool)
+		return !ok || b
+	}
+
+	switch ev.Type {
+	case events.EventPlanApproval:
+		return Notification{OnApproval, "agsh: approval required", firstOf(str("message"), "A plan awaits approval")}, true
+	case events.EventInputRequested:
+		return Notification{OnApproval, "agsh: input required", firstOf(str("prompt"), "A step awaits an answer")}, true
+	case events.EventVerifyResult:
+		if passed("passed") {
+			return Notification{}, false
+		}
+		return Notification{OnVerifyFailed, "agsh: verification failed", firstOf(str("summary"), stepText(data, "A verification failed"))}, true
+	case events.EventVerifyDrift:
+		if blocked, _ := data["blocked"].(bool); !blocked {
+			return Notification{}, false
+		}
+		return Notification{OnVerifyFailed, "agsh: step blocked", stepText(data, str("command")+" did something its declaration rules out")}, true
+	case events.EventVerifyOutputSchema:
+		if passed("passed") {
+			return Notification{}, false
+		}
+		return Notification{OnVerifyFailed, "agsh: output schema mismatch", stepText(data, str("command")+" returned output its schema rules out")}, true
+	case events.EventPipelineEnd:
+		if passed("success") {
+			return Notification{OnRunFinished, "agsh: run succeeded", fmt.Sprintf("%s steps completed", firstOf(str("step_count"), "All"))}, true
+		}
+		return Notification{OnRunFinished, "agsh: run failed", stepText(data, firstOf(str("error"), str("verify_failure"), "The pipeline failed"))}, true
+	}
+	return Notification{}, false
+}
+
+// Notify sends the notification ev calls for, if its occasion is enabled.
+func (n *Notifier) Notify(ev events.Event) error {
+	note, ok := For(ev)
+	if !ok || !n.enabled(note.Occasion) {
+		return nil
+	}
+	switch n.opts.Method {
+	case MethodBell:
+		_, err := io.WriteString(n.out, "\a")
+		return err
+	case MethodOSC:
+		_, err := fmt.Fprintf(n.out, "\x1b]9;%s: %s\a", clean(note.Title), clean(note.Message))
+		return err
+	case MethodCommand:
+		if len(n.opts.Command) == 0 {
+			return fmt.Errorf("notify: no command configured")
+		}
+		r := strings.NewReplacer("{title}", note.Title, "{message}", note.Message, "{event}", string(ev.Type))
+		argv := make([]string, len(n.opts.Command))
+		for i, arg := range n.opts.Command {
+			argv[i] = r.Replace(arg)
+		}
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), commandTimeout)
+		defer cancel()
+		if err := n.run(ctx, argv); err != nil {
+			return fmt.Errorf("notify: %s: %w", argv[0], err)
+		}
+	}
+	return nil
+}
+
+// Watch notifies of the events published on bus until stop is called.
+// stop returns once the events published before it are notified of, so a
+// process can announce the end of a run just before it exits. Failures
+// are passed to warn.
+func (n *Notifier) Watch(bus events.EventBus, warn func(error)) (stop func()) {
+	ch := bus.Subscribe(EventTypes...)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ev := range ch {
+			if err := n.Notify(ev); err != nil && warn != nil {
+				warn(err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { bus.Unsubscribe(ch) })
+		<-done
+	}
+}
+
+func (n *Notifier) enabled(occasion string) bool {
+	if len(n.opts.On) == 0 {
+		return true
+	}
+	for _, on := range n.opts.On {
+		if on == occasion {
+			return true
+		}
+	}
+	return false
+}
+
+func runCommand(ctx gocontext.Context, argv []string) error {
+	return exec.CommandContext(ctx, argv[0], argv[1:]...).Run()
+}
+
+// stepText prefixes text with the step it concerns, when the event names
+// one.
+func stepText(data map[string]any, text string) string {
+	if step, ok := data["step"]; ok && step != nil {
+		return fmt.Sprintf("Step %v: %s", step, text)
+	}
+	return text
+}
+
+// clean strips control characters, which would end or corrupt an escape
+// sequence.
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"bytes"
+	gocontext "context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cgast/agsh/pkg/events"
+)
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		name string
+		ev   events.Event
+		want Notification
+		ok   bool
+	}{
+		{"approval", events.NewEvent(events.EventPlanApproval, map[string]any{"plan_id": "p1", "message": "plan awaiting approval"}),
+			Notification{OnApproval, "agsh: approval required", "plan awaiting approval"}, true},
+		{"ask step", events.NewEvent(events.EventInputRequested, map[string]any{"prompt": "Which repo?"}),
+			Notification{OnApproval, "agsh: input required", "Which repo?"}, true},
+		{"verify passed", events.NewEvent(events.EventVerifyResult, map[string]any{"step": 1, "passed": true}),
+			Notification{}, false},
+		{"verify failed", events.NewEvent(events.EventVerifyResult, map[string]any{"step": 1, "passed": false, "summary": "output is empty"}),
+			Notification{OnVerifyFailed, "agsh: verification failed", "output is empty"}, true},
+		{"drift not blocked", events.NewEvent(events.EventVerifyDrift, map[string]any{"step": 2, "blocked": false}),
+			Notification{}, false},
+		{"drift blocked", events.NewEvent(events.EventVerifyDrift, map[string]any{"step": 2, "command": "fs:write", "blocked": true}),
+			Notification{OnVerifyFailed, "agsh: step blocked", "Step 2: fs:write did something its declaration rules out"}, true},
+		{"schema mismatch", events.NewEvent(events.EventVerifyOutputSchema, map[string]any{"step": 0, "command": "fs:list", "passed": false}),
+			Notification{OnVerifyFailed, "agsh: output schema mismatch", "Step 0: fs:list returned output its schema rules out"}, true},
+		{"succeeded", events.NewEvent(events.EventPipelineEnd, map[string]any{"success": true, "step_count": 3}),
+			Notification{OnRunFinished, "agsh: run succeeded", "3 steps completed"}, true},
+		{"failed", events.NewEvent(events.EventPipelineEnd, map[string]any{"success": false, "error": "boom", "step": 1}),
+			Notification{OnRunFinished, "agsh: run failed", "Step 1: boom"}, true},
+		{"other", events.NewEvent(events.EventCommandEnd, map[string]any{"command": "fs:list"}),
+			Notification{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := For(tt.ev)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("For = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	failed := events.NewEvent(events.EventPipelineEnd, map[string]any{"success": false, "error": "line1\nline2"})
+	approval := events.NewEvent(events.EventPlanApproval, map[string]any{"message": "plan awaiting approval"})
+
+	var out bytes.Buffer
+	if err := New(Options{Method: MethodBell}, &out).Notify(failed); err != nil || out.String() != "\a" {
+		t.Errorf("bell wrote %q, %v", out.String(), err)
+	}
+
+	out.Reset()
+	if err := New(Options{Method: MethodOSC}, &out).Notify(failed); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x1b]9;agsh: run failed: line1 line2\a"; out.String() != want {
+		t.Errorf("osc wrote %q, want %q", out.String(), want)
+	}
+
+	// Only the configured occasions are notified.
+	out.Reset()
+	n := New(Options{Method: MethodBell, On: []string{OnApproval}}, &out)
+	n.Notify(failed)
+	n.Notify(approval)
+	if out.String() != "\a" {
+		t.Errorf("with on: [approval], wrote %q", out.String())
+	}
+
+	var argv []string
+	n = New(Options{Method: MethodCommand, Command: []string{"notify-send", "{title}", "{message} ({event})"}}, &out)
+	n.run = func(_ gocontext.Context, a []string) error { argv = a; return nil }
+	if err := n.Notify(approval); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"notify-send", "agsh: approval required", "plan awaiting approval (plan.approval_requested)"}; !reflect.DeepEqual(argv, want) {
+		t.Errorf("command = %q, want %q", argv, want)
+	}
+
+	n.run = func(gocontext.Context, []string) error { return errors.New("exit status 1") }
+	if err := n.Notify(approval); err == nil {
+		t.Error("expected the command's failure")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	bus := events.NewMemoryBus()
+	var out bytes.Buffer
+	stop := New(Options{Method: MethodBell}, &out).Watch(bus, nil)
+	bus.Publish(events.NewEvent(events.EventCommandEnd, map[string]any{"command": "fs:list"}))
+	bus.Publish(events.NewEvent(events.EventPipelineEnd, map[string]any{"success": true, "step_count": 1}))
+	stop()
+	if out.String() != "\a" {
+		t.Errorf("wrote %q before stop returned, want one bell", out.String())
+	}
+	stop() // a second stop is harmless
+}
@@ -0,0 +1,162 @@
+// Package paths compares and names files the way the operating system
+// does, so sandbox checks and the files agsh stores behave the same on
+// Windows as on Unix. A Style describes one OS; its methods take the OS's
+// paths as plain strings rather than going through path/filepath, so the
+// Windows rules can be tested on any OS.
+package paths
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Style describes how an operating system spells and compares paths.
+type Style struct {
+	// Separator separates path elements. Windows also accepts '/'.
+	Separator byte
+
+	// CaseInsensitive compares paths without regard to case, as the
+	// default file systems of Windows and macOS do.
+	CaseInsensitive bool
+
+	// Volumes recognizes drive letters ("C:") and UNC shares
+	// ("\\server\share") at the start of a path.
+	Volumes bool
+
+	// Reserved are the characters a file name may not contain.
+	Reserved string
+}
+
+// The styles of the operating systems agsh runs on.
+var (
+	POSIX   = Style{Separator: '/', Reserved: "/"}
+	Darwin  = Style{Separator: '/', CaseInsensitive: true, Reserved: "/"}
+	Windows = Style{Separator: '\\', CaseInsensitive: true, Volumes: true, Reserved: `<>:"/\|?*`}
+)
+
+// Native is the style of the operating system agsh is running on.
+var Native = ForOS(runtime.GOOS)
+
+// ForOS returns the style of the operating system goos names, as in
+// runtime.GOOS.
+func ForOS(goos string) Style {
+	switch goos {
+	case "windows":
+		return Windows
+	case "darwin", "ios":
+		return Darwin
+	default:
+		return POSIX
+	}
+}
+
+// WithCase returns s comparing paths case-insensitively or not, for file
+// systems that differ from their OS's default.
+func (s Style) WithCase(insensitive bool) Style {
+	s.CaseInsensitive = insensitive
+	return s
+}
+
+// Key returns path in a form that is equal for two absolute paths exactly
+// when the OS takes them for the same path: cleaned, with one separator,
+// without Windows' \\?\ prefix, and lower case if the style is case
+// insensitive. It does not resolve relative paths or symlinks.
+func (s Style) Key(p string) string {
+	sep := string(s.Separator)
+	vol := ""
+	if s.Volumes {
+		p = strings.ReplaceAll(p, "/", sep)
+		if rest, ok := strings.CutPrefix(p, `\\?\UNC\`); ok {
+			p = `\\` + rest
+		} else {
+			p = strings.TrimPrefix(p, `\\?\`)
+		}
+		vol = s.VolumeName(p)
+		p = p[len(vol):]
+		if len(vol) == 2 {
+			vol = strings.ToUpper(vol)
+		}
+	}
+	slash := strings.ReplaceAll(p, sep, "/")
+	if slash != "" {
+		slash = path.Clean(slash)
+	}
+	key := vol + strings.ReplaceAll(slash, "/", sep)
+	if s.CaseInsensitive {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// Within reports whether path is dir or lies below it. Both should be
+// absolute.
+func (s Style) Within(p, dir string) bool {
+	pk, dk := s.Key(p), s.Key(dir)
+	if pk == dk {
+		return true
+	}
+	return strings.HasPrefix(pk, strings.TrimSuffix(dk, string(s.Separator))+string(s.Separator))
+}
+
+// VolumeName returns the drive ("C:") or UNC share ("\\server\share") p
+// starts with, or "" if it has none or the style has no volumes. p must
+// use the style's separator.
+func (s Style) VolumeName(p string) string {
+	if !s.Volumes {
+		return ""
+	}
+	if len(p) >= 2 && p[1] == ':' && isLetter(p[0]) {
+		return p[:2]
+	}
+	sep := string(s.Separator)
+	rest, ok := strings.CutPrefix(p, sep+sep)
+	if !ok || rest == "" || strings.HasPrefix(rest, sep) {
+		return ""
+	}
+	server, share, ok := strings.Cut(rest, sep)
+	if !ok || share == "" {
+		return ""
+	}
+	share, _, _ = strings.Cut(share, sep)
+	return sep + sep + server + sep + share
+}
+
+// FileName escapes the characters of name the OS does not allow in a file
+// name, and '%', as %XX, so a name such as a checkpoint's
+// "run-step-1-fs:write" can be used for a file. ParseFileName reverses it.
+func (s Style) FileName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '%' || c < 0x20 || strings.IndexByte(s.Reserved, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// ParseFileName returns the name FileName escaped as file. Text that is
+// not an escape is kept as it is.
+func (s Style) ParseFileName(file string) string {
+	var b strings.Builder
+	for i := 0; i < len(file); i++ {
+		if file[i] == '%' && i+2 < len(file) {
+			if c, err := strconv.ParseUint(file[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(file[i])
+	}
+	return b.String()
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,91 @@
+package paths
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		style Style
+		path  string
+		want  string
+	}{
+		{POSIX, "/work/a/../b/", "/work/b"},
+		{POSIX, "/Work/B", "/Work/B"},
+		{Darwin, "/Work/B", "/work/b"},
+		{Windows, `C:\Work\Reports\`, `c:\work\reports`},
+		{Windows, "c:/work/./reports", `c:\work\reports`},
+		{Windows, `C:\`, `c:\`},
+		{Windows, `\\?\C:\Work`, `c:\work`},
+		{Windows, `\\Server\Share\Dir\..\x`, `\\server\share\x`},
+		{Windows, `//server/share/x`, `\\server\share\x`},
+		{Windows, `\\?\UNC\server\share\x`, `\\server\share\x`},
+		{Windows.WithCase(false), `c:\Work`, `C:\Work`},
+	}
+	for _, tt := range tests {
+		if got := tt.style.Key(tt.path); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		style     Style
+		path, dir string
+		want      bool
+	}{
+		{POSIX, "/work/a.txt", "/work", true},
+		{POSIX, "/work", "/work/", true},
+		{POSIX, "/workspace/a.txt", "/work", false},
+		{POSIX, "/Work/a.txt", "/work", false},
+		{POSIX, "/etc/passwd", "/", true},
+		{Darwin, "/Work/a.txt", "/work", true},
+		{Windows, `C:\Work\a.txt`, `c:/work`, true},
+		{Windows, `C:\Workspace\a.txt`, `C:\Work`, false},
+		{Windows, `D:\Work\a.txt`, `C:\Work`, false},
+		{Windows, `C:\Windows\System32`, `C:\`, true},
+		{Windows, `\\server\share\dir\a.txt`, `\\SERVER\share`, true},
+		{Windows, `\\server\other\a.txt`, `\\server\share`, false},
+	}
+	for _, tt := range tests {
+		if got := tt.style.Within(tt.path, tt.dir); got != tt.want {
+			t.Errorf("Within(%q, %q) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestVolumeName(t *testing.T) {
+	for path, want := range map[string]string{
+		`C:\Work`:             "C:",
+		`c:`:                  "c:",
+		`\\server\share\x`:    `\\server\share`,
+		`\\server\share`:      `\\server\share`,
+		`\\server`:            "",
+		`\Work`:               "",
+		`1:\Work`:             "",
+		`\\\server\share\dir`: "",
+	} {
+		if got := Windows.VolumeName(path); got != want {
+			t.Errorf("VolumeName(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if got := POSIX.VolumeName("C:/x"); got != "" {
+		t.Errorf("POSIX VolumeName = %q", got)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	name := `run-step-1-fs:write <100%>`
+	file := Windows.FileName(name)
+	if file != "run-step-1-fs%3Awrite %3C100%25%3E" {
+		t.Errorf("FileName = %q", file)
+	}
+	if got := Windows.ParseFileName(file); got != name {
+		t.Errorf("ParseFileName = %q, want %q", got, name)
+	}
+	if got := POSIX.FileName("run-step-1-fs:write"); got != "run-step-1-fs:write" {
+		t.Errorf("POSIX FileName = %q, want it unchanged", got)
+	}
+	if got := POSIX.ParseFileName("a%2"); got != "a%2" {
+		t.Errorf("ParseFileName of a short escape = %q", got)
+	}
+}
@@ -0,0 +1,330 @@
+// Package policy decides, before a command runs, whether its input may
+// be acted on. Rules pair a command pattern with a condition on the input
+// and an effect, as in .agsh/config.yaml:
+//
+//	policy:
+//	  - name: foreign-issues
+//	    command: "github:issue:create"
+//	    when: "repo != cgast/*"
+//	    effect: deny
+//	  - command: "fs:write"
+//	    when: "len(content) > 1MB"
+//	    effect: require_approval
+//
+// A condition compares input fields with values: field op value, joined by
+// && and ||, with && binding tighter. A field is a dotted path into the
+// input payload, "input" for the whole payload, or len(field) for the
+// length of a string, list or map. == and != match wildcard patterns when
+// the value contains '*' (any text, including '/') or '?' (one
+// character); >, >=, < and <= compare numbers, and accept sizes such as
+// 1MB. Values may be quoted. A missing field compares as the empty string
+// and as no number.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cgast/agsh/internal/glob"
+	"github.com/cgast/agsh/internal/sandbox"
+)
+
+// Effects of a rule.
+const (
+	EffectAllow   = "allow"
+	EffectDeny    = "deny"
+	EffectApprove = "require_approval"
+)
+
+// Rule is a policy rule.
+type Rule struct {
+	Name    string // defaults to "rule <n>"
+	Command string // glob pattern of the commands it covers
+	When    string // condition on the input; empty always holds
+	Effect  string // EffectAllow, EffectDeny or EffectApprove
+	Message string // why, shown to the operator and the agent
+}
+
+// Decision is what the policy says about one command execution.
+type Decision struct {
+	Effect  string `json:"effect"` // empty when no rule matched
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Engine evaluates rules in order; the first rule whose command pattern
+// and condition match decides. An allow rule therefore exempts what it
+// matches from the rules after it.
+type Engine struct {
+	rules []compiled
+}
+
+type compiled struct {
+	Rule
+	cond expr // nil when the rule has no condition
+}
+
+// New compiles rules, reporting the first one that is malformed.
+func New(rules []Rule) (*Engine, error) {
+	e := &Engine{}
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		switch r.Effect {
+		case EffectAllow, EffectDeny, EffectApprove:
+		default:
+			return nil, fmt.Errorf("%s: unknown effect %q (expected allow, deny or require_approval)", r.Name, r.Effect)
+		}
+		if r.Command == "" {
+			return nil, fmt.Errorf("%s: command pattern is required", r.Name)
+		}
+		if err := glob.Validate(r.Command); err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		c := compiled{Rule: r}
+		if strings.TrimSpace(r.When) != "" {
+			cond, err := parse(r.When)
+			if err != nil {
+				return nil, fmt.Errorf("%s: when: %w", r.Name, err)
+			}
+			c.cond = cond
+		}
+		e.rules = append(e.rules, c)
+	}
+	return e, nil
+}
+
+// Len returns the number of rules.
+func (e *Engine) Len() int {
+	return len(e.rules)
+}
+
+// Evaluate returns the decision of the first rule matching command and
+// payload, or a zero Decision when none does.
+func (e *Engine) Evaluate(command string, payload any) Decision {
+	for _, r := range e.rules {
+		if !glob.Match(r.Command, command) {
+			continue
+		}
+		if r.cond != nil && !r.cond.eval(payload) {
+			continue
+		}
+		return Decision{Effect: r.Effect, Rule: r.Name, Message: r.Message}
+	}
+	return Decision{}
+}
+
+// expr is a parsed condition.
+type expr interface {
+	eval(payload any) bool
+}
+
+type orExpr []expr
+
+func (o orExpr) eval(payload any) bool {
+	for _, e := range o {
+		if e.eval(payload) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []expr
+
+func (a andExpr) eval(payload any) bool {
+	for _, e := range a {
+		if !e.eval(payload) {
+			return false
+		}
+	}
+	return true
+}
+
+// comparison is "field op value".
+type comparison struct {
+	field  []string // path into the payload; nil for the whole payload
+	length bool     // compare len(field)
+	op     string
+	value  string
+	number float64        // value as a number or size, for ordering operators
+	match  *regexp.Regexp // value as a wildcard pattern, for == and !=
+}
+
+var operators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+func parse(s string) (expr, error) {
+	var or orExpr
+	for _, alt := range strings.Split(s, "||") {
+		var and andExpr
+		for _, term := range strings.Split(alt, "&&") {
+			c, err := parseComparison(strings.TrimSpace(term))
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, c)
+		}
+		or = append(or, and)
+	}
+	return or, nil
+}
+
+func parseComparison(s string) (comparison, error) {
+	var c comparison
+	if s == "" {
+		return c, fmt.Errorf("empty comparison")
+	}
+	at := -1
+	for _, op := range operators {
+		if i := strings.Index(s, op); i > 0 && (at < 0 || i < at) {
+			at, c.op = i, op
+		}
+	}
+	if at < 0 {
+		return c, fmt.Errorf("%q: expected field, operator (%s) and value", s, strings.Join(operators, " "))
+	}
+	field := strings.TrimSpace(s[:at])
+	c.value = unquote(strings.TrimSpace(s[at+len(c.op):]))
+
+	if inner, ok := strings.CutPrefix(field, "len("); ok {
+		inner, ok = strings.CutSuffix(inner, ")")
+		if !ok {
+			return c, fmt.Errorf("%q: unclosed len(", s)
+		}
+		field, c.length = strings.TrimSpace(inner), true
+	}
+	if field == "" || strings.ContainsAny(field, " ()") {
+		return c, fmt.Errorf("%q: invalid field %q", s, field)
+	}
+	if field != "input" {
+		c.field = strings.Split(field, ".")
+	}
+
+	switch c.op {
+	case ">", ">=", "<", "<=":
+		n, ok := parseNumber(c.value)
+		if !ok {
+			return c, fmt.Errorf("%q: %s needs a number or size, got %q", s, c.op, c.value)
+		}
+		c.number = n
+	case "==", "!=":
+		if strings.ContainsAny(c.value, "*?") {
+			c.match = wildcard(c.value)
+		}
+	}
+	return c, nil
+}
+
+// wildcard compiles a pattern in which '*' matches any text and '?' one
+// character.
+func wildcard(pattern string) *regexp.Regexp {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.ReplaceAll(re, `\*`, ".*")
+	re = strings.ReplaceAll(re, `\?`, ".")
+	return regexp.MustCompile("^" + re + "$")
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseNumber parses a number or a size such as "1MB".
+func parseNumber(s string) (float64, bool) {
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, true
+	}
+	if n, err := sandbox.ParseFileSize(s); err == nil {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func (c comparison) eval(payload any) bool {
+	v, found := lookup(payload, c.field)
+	if c.length {
+		v, found = length(v), true
+	}
+	switch c.op {
+	case "==":
+		return c.equal(v, found)
+	case "!=":
+		return !c.equal(v, found)
+	}
+
+	n, ok := number(v)
+	if !found || !ok {
+		return false
+	}
+	switch c.op {
+	case ">":
+		return n > c.number
+	case ">=":
+		return n >= c.number
+	case "<":
+		return n < c.number
+	default:
+		return n <= c.number
+	}
+}
+
+func (c comparison) equal(v any, found bool) bool {
+	s := ""
+	if found && v != nil {
+		s = fmt.Sprint(v)
+	}
+	if c.match != nil {
+		return c.match.MatchString(s)
+	}
+	return s == c.value
+}
+
+// lookup follows path into payload.
+func lookup(payload any, path []string) (any, bool) {
+	v := payload
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func length(v any) int {
+	switch v := v.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case []any:
+		return len(v)
+	case []string:
+		return len(v)
+	case map[string]any:
+		return len(v)
+	}
+	return 0
+}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		return parseNumber(v)
+	}
+	return 0, false
+}
@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	e, err := New([]Rule{
+		{Name: "own-repos", Command: "github:issue:create", When: "repo == cgast/agsh", Effect: EffectAllow},
+		{Name: "foreign-issues", Command: "github:issue:create", When: "repo != cgast/*", Effect: EffectDeny, Message: "only our repos"},
+		{Command: "fs:write", When: "len(content) > 1MB || mode == create", Effect: EffectApprove},
+		{Command: "http:*", When: `url == "https://internal.*" && retries >= 3`, Effect: EffectDeny},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	big := strings.Repeat("x", 1<<20+1)
+	tests := []struct {
+		command string
+		payload any
+		want    Decision
+	}{
+		{"github:issue:create", map[string]any{"repo": "cgast/agsh"}, Decision{Effect: EffectAllow, Rule: "own-repos"}},
+		{"github:issue:create", map[string]any{"repo": "cgast/other"}, Decision{}},
+		{"github:issue:create", map[string]any{"repo": "someone/else"}, Decision{Effect: EffectDeny, Rule: "foreign-issues", Message: "only our repos"}},
+		{"github:issue:create", map[string]any{}, Decision{Effect: EffectDeny, Rule: "foreign-issues", Message: "only our repos"}},
+		{"fs:write", map[string]any{"content": big}, Decision{Effect: EffectApprove, Rule: "rule 3"}},
+		{"fs:write", map[string]any{"content": "small"}, Decision{}},
+		{"fs:write", map[string]any{"content": "small", "mode": "create"}, Decision{Effect: EffectApprove, Rule: "rule 3"}},
+		{"http:get", map[string]any{"url": "https://internal.example.com", "retries": 3}, Decision{Effect: EffectDeny, Rule: "rule 4"}},
+		{"http:get", map[string]any{"url": "https://internal.example.com", "retries": "2"}, Decision{}},
+		{"http:get", "https://internal.example.com", Decision{}},
+		{"fs:read", map[string]any{"repo": "someone/else"}, Decision{}},
+	}
+	for _, tt := range tests {
+		if got := e.Evaluate(tt.command, tt.payload); got != tt.want {
+			t.Errorf("Evaluate(%s, %v) = %+v, want %+v", tt.command, tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateWholeInput(t *testing.T) {
+	e, err := New([]Rule{{Command: "web:*", When: "input == *.onion*", Effect: EffectDeny}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := e.Evaluate("web:extract", "http://abc.onion/page"); got.Effect != EffectDeny {
+		t.Errorf("Evaluate = %+v, want deny", got)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{Command: "fs:*", Effect: "block"}, "unknown effect"},
+		{Rule{Effect: EffectDeny}, "command pattern is required"},
+		{Rule{Command: "fs:*", When: "size", Effect: EffectDeny}, "expected field, operator"},
+		{Rule{Command: "fs:*", When: "len(content > 1", Effect: EffectDeny}, "unclosed len("},
+		{Rule{Command: "fs:*", When: "bytes > lots", Effect: EffectDeny}, "needs a number or size"},
+		{Rule{Name: "x", Command: "fs:*", When: "a == 1 && ", Effect: EffectDeny}, "x: when: empty comparison"},
+	}
+	for _, tt := range tests {
+		_, err := New([]Rule{tt.rule})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("New(%+v) = %v, want error containing %q", tt.rule, err, tt.want)
+		}
+	}
+}
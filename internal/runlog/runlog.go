@@ -0,0 +1,296 @@
+// Package runlog keeps a durable record of each spec run in its own
+// directory, <root>/<id>/, where id is a sortable timestamp such as
+// "20261015-143012-3f9a". A run directory holds:
+//
+//	meta.json          id, spec, status and timing (see Meta)
+//	spec.yaml          the spec with parameters resolved
+//	plan.json          the generated execution plan
+//	events.jsonl       runtime events, one JSON object per line
+//	steps.json         step results, including outputs
+//	verification.json  the success criteria report, if the spec has any
+//	summary.json       the run summary printed by `agsh run --output`
+//	annotations.jsonl  notes added to the run's events in the inspector
+//	artifacts/         files steps attached, by step
+package runlog
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Run statuses.
+const (
+	StatusRunning   = "running" // also left behind by an interrupted run
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// MetaFile is the file every run directory is recognized by.
+const MetaFile = "meta.json"
+
+// ErrNotFound is returned by Open when no run matches.
+var ErrNotFound = errors.New("run not found")
+
+// Meta describes a run.
+type Meta struct {
+	ID         string     `json:"id"`
+	Spec       string     `json:"spec"`
+	SpecPath   string     `json:"spec_path,omitempty"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Steps      int        `json:"steps"`
+	Error      string     `json:"error,omitempty"`
+	Resumes    int        `json:"resumes,omitempty"` // times the run was resumed
+}
+
+// Duration is how long the run took, or has been running.
+func (m Meta) Duration() time.Duration {
+	if m.FinishedAt != nil {
+		return m.FinishedAt.Sub(m.StartedAt)
+	}
+	return time.Since(m.StartedAt)
+}
+
+// Run is an open run directory.
+type Run struct {
+	Meta
+	Dir string
+}
+
+// Create makes a new run directory under root and records the run as
+// running.
+func Create(root, specName, specPath string, steps int) (*Run, error) {
+	started := time.Now().UTC()
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	id := started.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+
+	dir := filepath.Join(root, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create run dir: %w", err)
+	}
+	r := &Run{
+		Meta: Meta{
+			ID:        id,
+			Spec:      specName,
+			SpecPath:  specPath,
+			Status:    StatusRunning,
+			StartedAt: started,
+			Steps:     steps,
+		},
+		Dir: dir,
+	}
+	if err := r.WriteJSON(MetaFile, r.Meta); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// WriteFile writes a file in the run directory.
+func (r *Run) WriteFile(name string, data []byte) error {
+	if err := os.WriteFile(filepath.Join(r.Dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("run %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// WriteJSON writes v as indented JSON to a file in the run directory.
+func (r *Run) WriteJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("run %s: encode %s: %w", r.ID, name, err)
+	}
+	return r.WriteFile(name, append(data, '\n'))
+}
+
+// AppendJSONLines appends each item as one line of JSON to a file in the
+// run directory, creating it if needed.
+func AppendJSONLines[T any](r *Run, name string, items []T) error {
+	f, err := os.OpenFile(filepath.Join(r.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("run %s: %w", r.ID, err)
+	}
+	enc := json.NewEncoder(f)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			f.Close()
+			return fmt.Errorf("run %s: encode %s: %w", r.ID, name, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("run %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// Restart marks a finished or interrupted run as running again, for a
+// resume.
+func (r *Run) Restart() error {
+	r.Status = StatusRunning
+	r.FinishedAt = nil
+	r.Error = ""
+	r.Resumes++
+	return r.WriteJSON(MetaFile, r.Meta)
+}
+
+// Finish records the run's outcome. runErr is the error the run failed
+// with, if any.
+func (r *Run) Finish(success bool, runErr error) error {
+	now := time.Now().UTC()
+	r.FinishedAt = &now
+	r.Status = StatusSucceeded
+	if !success || runErr != nil {
+		r.Status = StatusFailed
+	}
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	return r.WriteJSON(MetaFile, r.Meta)
+}
+
+// List returns the runs under root, newest first. Directories without a
+// readable meta.json are skipped; a missing root has no runs.
+func List(root string) ([]Meta, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []Meta
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		m, err := readMeta(filepath.Join(root, e.Name()))
+		if err != nil {
+			continue
+		}
+		runs = append(runs, m)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	return runs, nil
+}
+
+// Open finds a run by id, a unique id prefix, or "latest".
+func Open(root, id string) (*Run, error) {
+	runs, err := List(root)
+	if err != nil {
+		return nil, err
+	}
+	if id == "latest" {
+		if len(runs) == 0 {
+			return nil, fmt.Errorf("%w: no runs in %s", ErrNotFound, root)
+		}
+		return &Run{Meta: runs[0], Dir: filepath.Join(root, runs[0].ID)}, nil
+	}
+	var matches []Meta
+	for _, m := range runs {
+		if m.ID == id {
+			return &Run{Meta: m, Dir: filepath.Join(root, m.ID)}, nil
+		}
+		if strings.HasPrefix(m.ID, id) {
+			matches = append(matches, m)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+		return &Run{Meta: matches[0], Dir: filepath.Join(root, matches[0].ID)}, nil
+	}
+	return nil, fmt.Errorf("run id %q is ambiguous (%d runs match)", id, len(matches))
+}
+
+// Files lists the files in the run directory, relative to it, in lexical
+// order.
+func (r *Run) Files() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(r.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(r.Dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files, err
+}
+
+// AnnotationsFile holds the notes added to a run's events.
+const AnnotationsFile = "annotations.jsonl"
+
+// Annotation is a note on one event of a run, such as "this is where the
+// agent went wrong". The event's type and timestamp find it in
+// events.jsonl.
+type Annotation struct {
+	Event          int       `json:"event"` // index in the inspector session's event history
+	EventType      string    `json:"event_type"`
+	EventTimestamp time.Time `json:"event_timestamp"`
+	StepIndex      int       `json:"step_index,omitempty"`
+	Note           string    `json:"note"`
+	Created        time.Time `json:"created"`
+	Run            string    `json:"run,omitempty"` // the run the event belongs to
+}
+
+// Annotate appends an annotation to the run's annotations.jsonl.
+func (r *Run) Annotate(a Annotation) error {
+	return AppendJSONLines(r, AnnotationsFile, []Annotation{a})
+}
+
+// Annotations returns the run's annotations, oldest first.
+func (r *Run) Annotations() ([]Annotation, error) {
+	f, err := os.Open(filepath.Join(r.Dir, AnnotationsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Annotation
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var a Annotation
+		if err := json.Unmarshal(sc.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("run %s: decode %s: %w", r.ID, AnnotationsFile, err)
+		}
+		out = append(out, a)
+	}
+	return out, sc.Err()
+}
+
+// ReadJSON decodes a JSON file from the run directory into v.
+func (r *Run) ReadJSON(name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(r.Dir, name))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("run %s: decode %s: %w", r.ID, name, err)
+	}
+	return nil
+}
+
+func readMeta(dir string) (Meta, error) {
+	var m Meta
+	data, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
@@ -0,0 +1,156 @@
+package runlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunLifecycle(t *testing.T) {
+	root := t.TempDir()
+	r, err := Create(root, "weekly-report", "specs/weekly.yaml", 3)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if r.Status != StatusRunning {
+		t.Errorf("status = %q, want running", r.Status)
+	}
+	if err := r.WriteJSON("plan.json", map[string]any{"steps": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendJSONLines(r, "events.jsonl", []map[string]string{{"type": "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendJSONLines(r, "events.jsonl", []map[string]string{{"type": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(r.Dir, "events.jsonl")); string(data) != "{\"type\":\"a\"}\n{\"type\":\"b\"}\n" {
+		t.Errorf("events.jsonl = %q", data)
+	}
+
+	// An unfinished run is listed as running.
+	runs, err := List(root)
+	if err != nil || len(runs) != 1 || runs[0].Status != StatusRunning {
+		t.Fatalf("List = %+v, %v", runs, err)
+	}
+
+	if err := r.Finish(false, errors.New("step 2 failed")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Open(root, r.ID)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if got.Status != StatusFailed || got.Error != "step 2 failed" || got.FinishedAt == nil {
+		t.Errorf("finished meta = %+v", got.Meta)
+	}
+	if got.Spec != "weekly-report" || got.SpecPath != "specs/weekly.yaml" || got.Steps != 3 {
+		t.Errorf("meta = %+v", got.Meta)
+	}
+
+	files, err := got.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"events.jsonl", "meta.json", "plan.json"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Files = %v, want %v", files, want)
+	}
+	var plan map[string]any
+	if err := got.ReadJSON("plan.json", &plan); err != nil || plan["steps"] != float64(3) {
+		t.Errorf("ReadJSON = %v, %v", plan, err)
+	}
+
+	if err := got.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := Open(root, r.ID)
+	if again.Status != StatusRunning || again.FinishedAt != nil || again.Error != "" || again.Resumes != 1 {
+		t.Errorf("restarted meta = %+v", again.Meta)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	r, err := Create(t.TempDir(), "weekly-report", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.Annotations(); err != nil || len(got) != 0 {
+		t.Fatalf("Annotations before any = %v, %v", got, err)
+	}
+	at := time.Date(2026, 10, 15, 14, 30, 12, 5, time.UTC)
+	want := []Annotation{
+		{Event: 4, EventType: "command.error", EventTimestamp: at, Note: "wrong repo", Run: r.ID},
+		{Event: 7, EventType: "verify.result", EventTimestamp: at.Add(time.Second), StepIndex: 2, Note: "too lenient", Run: r.ID},
+	}
+	for _, a := range want {
+		if err := r.Annotate(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := r.Annotations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Annotations = %+v, want %+v", got, want)
+	}
+}
+
+func TestListAndOpen(t *testing.T) {
+	root := t.TempDir()
+	for _, id := range []string{"20261001-090000-aaaa", "20261002-090000-bbbb", "20261002-100000-cccc"} {
+		r := &Run{Meta: Meta{ID: id, Status: StatusSucceeded}, Dir: filepath.Join(root, id)}
+		os.MkdirAll(r.Dir, 0o755)
+		if err := r.WriteJSON(MetaFile, r.Meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.MkdirAll(filepath.Join(root, "not-a-run"), 0o755)
+
+	runs, err := List(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range runs {
+		ids = append(ids, m.ID)
+	}
+	if fmt.Sprint(ids) != "[20261002-100000-cccc 20261002-090000-bbbb 20261001-090000-aaaa]" {
+		t.Errorf("List order = %v", ids)
+	}
+
+	tests := []struct {
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{"latest", "20261002-100000-cccc", false},
+		{"20261001", "20261001-090000-aaaa", false},
+		{"20261002-090000-bbbb", "20261002-090000-bbbb", false},
+		{"20261002", "", true}, // ambiguous
+		{"2025", "", true},
+	}
+	for _, tt := range tests {
+		r, err := Open(root, tt.id)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Open(%s) = %s, want error", tt.id, r.ID)
+			}
+			continue
+		}
+		if err != nil || r.ID != tt.want {
+			t.Errorf("Open(%s) = %v, %v; want %s", tt.id, r, err, tt.want)
+		}
+	}
+	if _, err := Open(root, "2025"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if runs, err := List(filepath.Join(root, "missing")); err != nil || len(runs) != 0 {
+		t.Errorf("List(missing) = %v, %v", runs, err)
+	}
+}
@@ -1,19 +1,41 @@
 package sandbox
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/cgast/agsh/internal/paths"
 )
 
+// ErrViolation matches, with errors.Is, every error returned when the
+// sandbox refuses a path or size.
+var ErrViolation = errors.New("sandbox violation")
+
+// Violationf formats an error reporting a sandbox refusal. Commands that
+// enforce limits themselves use it so the refusal can still be told apart
+// from other failures.
+func Violationf(format string, args ...any) error {
+	return &violationError{msg: "sandbox: " + fmt.Sprintf(format, args...)}
+}
+
+type violationError struct{ msg string }
+
+func (e *violationError) Error() string { return e.msg }
+func (e *violationError) Unwrap() error { return ErrViolation }
+
 // Sandbox enforces filesystem restrictions based on allowed/denied paths
 // and file size limits. It is used by platform commands to validate
 // operations before executing them.
 type Sandbox struct {
 	allowedPaths []string
 	deniedPaths  []string
-	maxFileSize  int64 // bytes, 0 means unlimited
+	maxFileSize  int64       // bytes, 0 means unlimited
+	style        paths.Style // how paths are compared
+	within       *Sandbox    // an outer sandbox whose restrictions also apply
 }
 
 // Config holds the sandbox configuration.
@@ -21,12 +43,26 @@ type Config struct {
 	AllowedPaths []string
 	DeniedPaths  []string
 	MaxFileSize  string // e.g. "10MB", "1GB", "500KB"
+
+	// PathCase is how paths are compared: "auto" or "" (case-insensitive
+	// on Windows and macOS, as their default file systems are),
+	// "sensitive" or "insensitive".
+	PathCase string
 }
 
 // New creates a Sandbox from the given configuration.
 // Allowed and denied paths are resolved to absolute paths.
 func New(cfg Config) (*Sandbox, error) {
-	s := &Sandbox{}
+	s := &Sandbox{style: paths.Native}
+	switch cfg.PathCase {
+	case "", "auto":
+	case "sensitive":
+		s.style = s.style.WithCase(false)
+	case "insensitive":
+		s.style = s.style.WithCase(true)
+	default:
+		return nil, fmt.Errorf("sandbox: unknown path_case %q (expected auto, sensitive or insensitive)", cfg.PathCase)
+	}
 
 	for _, p := range cfg.AllowedPaths {
 		abs, err := filepath.Abs(p)
@@ -56,9 +92,16 @@ func New(cfg Config) (*Sandbox, error) {
 }
 
 // CheckPath validates that the given path is allowed by the sandbox.
-// The path is resolved to an absolute path before checking.
+// The path is resolved to an absolute path before checking, and compared
+// as the OS compares paths: on Windows, "C:/Work" and "c:\work" are the
+// same directory.
 // Returns nil if the path is allowed, or an error describing why it's denied.
 func (s *Sandbox) CheckPath(path string) error {
+	if s.within != nil {
+		if err := s.within.CheckPath(path); err != nil {
+			return err
+		}
+	}
 	abs, err := filepath.Abs(path)
 	if err != nil {
 		return fmt.Errorf("sandbox: resolve path %q: %w", path, err)
@@ -66,8 +109,8 @@ func (s *Sandbox) CheckPath(path string) error {
 
 	// Check denied paths first (deny takes precedence).
 	for _, denied := range s.deniedPaths {
-		if abs == denied || strings.HasPrefix(abs, denied+string(filepath.Separator)) {
-			return fmt.Errorf("sandbox: path %q is under denied path %q", abs, denied)
+		if s.style.Within(abs, denied) {
+			return Violationf("path %q is under denied path %q", abs, denied)
 		}
 	}
 
@@ -78,32 +121,81 @@ func (s *Sandbox) CheckPath(path string) error {
 
 	// Check if path is under an allowed path.
 	for _, allowed := range s.allowedPaths {
-		if abs == allowed || strings.HasPrefix(abs, allowed+string(filepath.Separator)) {
+		if s.style.Within(abs, allowed) {
 			return nil
 		}
 	}
 
-	return fmt.Errorf("sandbox: path %q is not under any allowed path %v", abs, s.allowedPaths)
+	return Violationf("path %q is not under any allowed path %v", abs, s.allowedPaths)
 }
 
 // CheckFileSize validates that the given size in bytes does not exceed
 // the sandbox's maximum file size. Returns nil if the size is within limits
 // or if no limit is configured.
 func (s *Sandbox) CheckFileSize(size int64) error {
+	if s.within != nil {
+		if err := s.within.CheckFileSize(size); err != nil {
+			return err
+		}
+	}
 	if s.maxFileSize <= 0 {
 		return nil
 	}
 	if size > s.maxFileSize {
-		return fmt.Errorf("sandbox: file size %d bytes exceeds maximum %d bytes (%s)",
+		return Violationf("file size %d bytes exceeds maximum %d bytes (%s)",
 			size, s.maxFileSize, formatFileSize(s.maxFileSize))
 	}
 	return nil
 }
 
-// MaxFileSize returns the configured maximum file size in bytes.
-// Returns 0 if no limit is configured.
+// MaxFileSize returns the configured maximum file size in bytes, the
+// smallest of the outer sandboxes' included. Returns 0 if no limit is
+// configured.
 func (s *Sandbox) MaxFileSize() int64 {
-	return s.maxFileSize
+	size := s.maxFileSize
+	if s.within != nil {
+		if outer := s.within.MaxFileSize(); outer > 0 && (size == 0 || outer < size) {
+			size = outer
+		}
+	}
+	return size
+}
+
+type runKey struct{}
+
+// WithRun returns a context whose commands are held to the sandbox cfg
+// describes as well as their own (see ForRun), and to any the context
+// already carries: a run, such as one of a spec, can narrow the sandbox
+// but never widen it.
+func WithRun(ctx context.Context, cfg Config) (context.Context, error) {
+	run, err := New(cfg)
+	if err != nil {
+		return ctx, err
+	}
+	run.within, _ = ctx.Value(runKey{}).(*Sandbox)
+	return context.WithValue(ctx, runKey{}, run), nil
+}
+
+// ForRun returns the sandbox a command running with ctx is held to: s,
+// narrowed by the sandboxes of the runs ctx belongs to. Either may be
+// nil; nil is returned when neither restricts anything.
+func ForRun(ctx context.Context, s *Sandbox) *Sandbox {
+	run, _ := ctx.Value(runKey{}).(*Sandbox)
+	return nest(run, s)
+}
+
+// nest returns a copy of inner, whose outermost sandbox is held within
+// outer.
+func nest(inner, outer *Sandbox) *Sandbox {
+	if inner == nil {
+		return outer
+	}
+	if outer == nil {
+		return inner
+	}
+	n := *inner
+	n.within = nest(inner.within, outer)
+	return &n
 }
 
 // AllowedPaths returns the list of allowed absolute paths.
@@ -116,6 +208,11 @@ func (s *Sandbox) DeniedPaths() []string {
 	return s.deniedPaths
 }
 
+// ParseFileSize parses a human-readable size such as "10MB" into bytes.
+func ParseFileSize(s string) (int64, error) {
+	return parseFileSize(s)
+}
+
 // parseFileSize parses a human-readable file size string into bytes.
 // Supported suffixes: B, KB, MB, GB, TB (case-insensitive).
 func parseFileSize(s string) (int64, error) {
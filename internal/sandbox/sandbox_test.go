@@ -1,6 +1,8 @@
 package sandbox
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -75,6 +77,9 @@ func TestCheckPath(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CheckPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
 			}
+			if err != nil && !errors.Is(err, ErrViolation) {
+				t.Errorf("CheckPath(%q) error %v is not ErrViolation", tt.path, err)
+			}
 		})
 	}
 }
@@ -140,6 +145,85 @@ func TestCheckFileSize_NoLimit(t *testing.T) {
 	}
 }
 
+func TestCheckPath_PathCase(t *testing.T) {
+	tmpDir := t.TempDir()
+	allowed := filepath.Join(tmpDir, "Work")
+	upper := filepath.Join(tmpDir, "WORK", "a.txt")
+
+	s, err := New(Config{AllowedPaths: []string{allowed}, PathCase: "insensitive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckPath(upper); err != nil {
+		t.Errorf("insensitive: %v", err)
+	}
+
+	s, err = New(Config{AllowedPaths: []string{allowed}, PathCase: "sensitive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckPath(upper); err == nil {
+		t.Error("sensitive: a path differing in case should be rejected")
+	}
+
+	if _, err := New(Config{PathCase: "mixed"}); err == nil {
+		t.Error("expected error for unknown path_case")
+	}
+}
+
+func TestWithRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	workspace := filepath.Join(tmpDir, "workspace")
+	other := filepath.Join(tmpDir, "other")
+
+	configured, err := New(Config{AllowedPaths: []string{tmpDir}, MaxFileSize: "1KB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := WithRun(context.Background(), Config{AllowedPaths: []string{workspace, "/elsewhere"}, MaxFileSize: "1MB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ForRun(ctx, configured)
+
+	if err := s.CheckPath(filepath.Join(workspace, "a.txt")); err != nil {
+		t.Errorf("path in both sandboxes: %v", err)
+	}
+	if err := s.CheckPath(filepath.Join(other, "a.txt")); !errors.Is(err, ErrViolation) {
+		t.Errorf("path outside the run's sandbox: err = %v, want a violation", err)
+	}
+	// The run cannot widen the configured sandbox.
+	if err := s.CheckPath("/elsewhere/a.txt"); !errors.Is(err, ErrViolation) {
+		t.Errorf("path outside the configured sandbox: err = %v, want a violation", err)
+	}
+	if got := s.MaxFileSize(); got != 1024 {
+		t.Errorf("MaxFileSize = %d, want the smaller 1024", got)
+	}
+	if err := s.CheckFileSize(2048); err == nil {
+		t.Error("2KB should exceed the configured 1KB")
+	}
+
+	// A nested run narrows further.
+	nested, err := WithRun(ctx, Config{DeniedPaths: []string{filepath.Join(workspace, "secret")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = ForRun(nested, nil)
+	if err := s.CheckPath(filepath.Join(workspace, "secret", "key")); err == nil {
+		t.Error("path denied by the nested run should be rejected")
+	}
+	if err := s.CheckPath(filepath.Join(other, "a.txt")); err == nil {
+		t.Error("path outside the outer run's sandbox should be rejected")
+	}
+
+	if ForRun(context.Background(), nil) != nil {
+		t.Error("ForRun without sandboxes should return nil")
+	}
+	if ForRun(context.Background(), configured) != configured {
+		t.Error("ForRun without a run should return the configured sandbox")
+	}
+}
+
 func TestParseFileSize(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -0,0 +1,183 @@
+package storeserver
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/cgast/agsh/pkg/protocol"
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+// The methods the owner of a checkpoint database answers. Unlike the agent
+// protocol's checkpoint methods, they carry the snapshots themselves.
+const (
+	methodCheckpointSave    = "checkpoints.save"
+	methodCheckpointRestore = "checkpoints.restore"
+	methodCheckpointList    = "checkpoints.list"
+	methodCheckpointDelete  = "checkpoints.delete"
+	methodCheckpointDiff    = "checkpoints.diff"
+)
+
+type checkpointParams struct {
+	Name     string                  `json:"name,omitempty"`
+	Snapshot *verify.SessionSnapshot `json:"snapshot,omitempty"`
+	A        string                  `json:"a,omitempty"` // checkpoints.diff
+	B        string                  `json:"b,omitempty"`
+}
+
+// checkpointManager is a verify.CheckpointManager that can be closed.
+type checkpointManager interface {
+	verify.CheckpointManager
+	Close() error
+}
+
+// CheckpointStore is a bbolt checkpoint manager that several agsh processes
+// can open on the same database, like ContextStore. Retention and deltas
+// follow the options of the process that owns the file.
+type CheckpointStore struct {
+	sh *shared[checkpointManager] // *verify.BoltCheckpointManager when this process owns the file, else *remoteCheckpoints
+}
+
+// OpenCheckpointStore opens the checkpoint database at path with opts, or
+// connects to the agsh process that has it open.
+func OpenCheckpointStore(path string, opts ...verify.CheckpointOption) (*CheckpointStore, error) {
+	s := &CheckpointStore{sh: &shared[checkpointManager]{
+		path: path,
+		sock: socketPath(path),
+		open: func(wait time.Duration) (checkpointManager, error) {
+			m, err := verify.NewBoltCheckpointManager(path, append(slices.Clip(opts), verify.WithLockTimeout(wait))...)
+			if err != nil {
+				return nil, err
+			}
+			return m, nil
+		},
+		serve: func(m checkpointManager) (*protocol.Handler, streamFunc) {
+			return checkpointHandler(m), nil
+		},
+		remote: func(c *client) checkpointManager {
+			return &remoteCheckpoints{c}
+		},
+	}}
+	if err := s.sh.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Owner reports whether this process holds the file.
+func (s *CheckpointStore) Owner() bool {
+	return s.sh.isOwner()
+}
+
+func (s *CheckpointStore) Save(name string, state verify.SessionSnapshot) error {
+	return s.sh.with(func(m checkpointManager) error { return m.Save(name, state) })
+}
+
+func (s *CheckpointStore) Restore(name string) (verify.SessionSnapshot, error) {
+	var snap verify.SessionSnapshot
+	err := s.sh.with(func(m checkpointManager) (err error) {
+		snap, err = m.Restore(name)
+		return err
+	})
+	return snap, err
+}
+
+func (s *CheckpointStore) List() ([]verify.CheckpointInfo, error) {
+	var infos []verify.CheckpointInfo
+	err := s.sh.with(func(m checkpointManager) (err error) {
+		infos, err = m.List()
+		return err
+	})
+	return infos, err
+}
+
+func (s *CheckpointStore) Delete(name string) error {
+	return s.sh.with(func(m checkpointManager) error { return m.Delete(name) })
+}
+
+func (s *CheckpointStore) Diff(a, b string) ([]verify.Change, error) {
+	var changes []verify.Change
+	err := s.sh.with(func(m checkpointManager) (err error) {
+		changes, err = m.Diff(a, b)
+		return err
+	})
+	return changes, err
+}
+
+// Close stops serving other processes and releases the file.
+func (s *CheckpointStore) Close() error {
+	return s.sh.close()
+}
+
+// checkpointHandler answers the checkpoints.* methods from m.
+func checkpointHandler(m verify.CheckpointManager) *protocol.Handler {
+	h := protocol.NewHandler()
+	fail := func(err error) *protocol.Error {
+		return &protocol.Error{Code: protocol.CodeInternalError, Message: err.Error()}
+	}
+	register := func(method string, fn func(p checkpointParams) (any, error)) {
+		h.Register(method, func(params json.RawMessage) (any, *protocol.Error) {
+			p, rpcErr := protocol.ParseParams[checkpointParams](params)
+			if rpcErr != nil {
+				return nil, rpcErr
+			}
+			result, err := fn(p)
+			if err != nil {
+				return nil, fail(err)
+			}
+			return result, nil
+		})
+	}
+	register(methodCheckpointSave, func(p checkpointParams) (any, error) {
+		if p.Snapshot == nil {
+			p.Snapshot = &verify.SessionSnapshot{}
+		}
+		return "ok", m.Save(p.Name, *p.Snapshot)
+	})
+	register(methodCheckpointRestore, func(p checkpointParams) (any, error) {
+		return m.Restore(p.Name)
+	})
+	register(methodCheckpointList, func(checkpointParams) (any, error) {
+		return m.List()
+	})
+	register(methodCheckpointDelete, func(p checkpointParams) (any, error) {
+		return "ok", m.Delete(p.Name)
+	})
+	register(methodCheckpointDiff, func(p checkpointParams) (any, error) {
+		return m.Diff(p.A, p.B)
+	})
+	return h
+}
+
+// remoteCheckpoints is a checkpoint manager served by the process owning
+// the file.
+type remoteCheckpoints struct {
+	*client
+}
+
+func (r *remoteCheckpoints) Save(name string, state verify.SessionSnapshot) error {
+	return r.call(methodCheckpointSave, checkpointParams{Name: name, Snapshot: &state}, nil)
+}
+
+func (r *remoteCheckpoints) Restore(name string) (verify.SessionSnapshot, error) {
+	var snap verify.SessionSnapshot
+	err := r.call(methodCheckpointRestore, checkpointParams{Name: name}, &snap)
+	return snap, err
+}
+
+func (r *remoteCheckpoints) List() ([]verify.CheckpointInfo, error) {
+	var infos []verify.CheckpointInfo
+	err := r.call(methodCheckpointList, checkpointParams{}, &infos)
+	return infos, err
+}
+
+func (r *remoteCheckpoints) Delete(name string) error {
+	return r.call(methodCheckpointDelete, checkpointParams{Name: name}, nil)
+}
+
+func (r *remoteCheckpoints) Diff(a, b string) ([]verify.Change, error) {
+	var changes []verify.Change
+	err := r.call(methodCheckpointDiff, checkpointParams{A: a, B: b}, &changes)
+	return changes, err
+}
@@ -0,0 +1,69 @@
+package storeserver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+func TestCheckpointStore(t *testing.T) {
+	// bbolt locks the file per open, so two opens in one process behave
+	// like two processes.
+	path := filepath.Join(t.TempDir(), "checkpoints.db")
+	owner, err := OpenCheckpointStore(path, verify.WithRetention(verify.RetentionPolicy{MaxCount: 2}))
+	if err != nil {
+		t.Fatalf("open owner: %v", err)
+	}
+	defer owner.Close()
+	other, err := OpenCheckpointStore(path)
+	if err != nil {
+		t.Fatalf("open second store: %v", err)
+	}
+	defer other.Close()
+	if !owner.Owner() || other.Owner() {
+		t.Fatalf("owner = %v, other = %v", owner.Owner(), other.Owner())
+	}
+
+	snap := func(v string) verify.SessionSnapshot {
+		return verify.SessionSnapshot{ContextState: map[string]map[string]any{"session": {"k": v}}}
+	}
+	if err := other.Save("one", snap("a")); err != nil {
+		t.Fatalf("remote Save: %v", err)
+	}
+	if err := owner.Save("two", snap("b")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := other.Restore("one")
+	if err != nil || got.ContextState["session"]["k"] != "a" {
+		t.Errorf("remote Restore = %+v, %v", got, err)
+	}
+	if changes, err := other.Diff("one", "two"); err != nil || len(changes) != 1 || changes[0].Type != "modified" {
+		t.Errorf("remote Diff = %+v, %v", changes, err)
+	}
+	if _, err := other.Restore("missing"); err == nil {
+		t.Error("remote Restore of a missing checkpoint succeeded")
+	}
+
+	// The owner's retention applies to remote saves.
+	if err := other.Save("three", snap("c")); err != nil {
+		t.Fatalf("remote Save: %v", err)
+	}
+	if infos, err := other.List(); err != nil || len(infos) != 2 {
+		t.Errorf("remote List = %+v, %v", infos, err)
+	}
+	if err := other.Delete("two"); err != nil {
+		t.Errorf("remote Delete: %v", err)
+	}
+
+	// When the owner closes, the other store takes the file over.
+	if err := owner.Close(); err != nil {
+		t.Fatalf("close owner: %v", err)
+	}
+	if infos, err := other.List(); err != nil || len(infos) != 1 || infos[0].Name != "three" {
+		t.Errorf("List after takeover = %+v, %v", infos, err)
+	}
+	if !other.Owner() {
+		t.Error("other store did not take over")
+	}
+}
@@ -0,0 +1,410 @@
+package storeserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+// ContextStore is a context store that several agsh processes, such as a
+// REPL and a run in another terminal, can open on the same file. The owner
+// answers the context.get, context.set, context.delete and context.list
+// methods of the agent protocol for the others, and streams every change to
+// processes that watch it.
+type ContextStore struct {
+	sh *shared[agshctx.ContextStore] // *BoltStore when this process owns the file, else *remoteContext
+
+	// Guarded by sh.mu.
+	historyLimit int  // applied to the file whenever this process owns it
+	watching     bool // changes are relayed to feed
+
+	feed agshctx.ChangeFeed
+}
+
+// OpenContextStore opens the context store at path, or connects to the
+// agsh process that has it open.
+func OpenContextStore(path string) (*ContextStore, error) {
+	s := &ContextStore{}
+	s.sh = &shared[agshctx.ContextStore]{
+		path: path,
+		sock: socketPath(path),
+		open: func(wait time.Duration) (agshctx.ContextStore, error) {
+			bs, err := agshctx.OpenBoltStore(path, wait)
+			if err != nil {
+				return nil, err
+			}
+			return bs, nil
+		},
+		serve: func(st agshctx.ContextStore) (*protocol.Handler, streamFunc) {
+			bs := st.(*agshctx.BoltStore)
+			return contextHandler(bs), watchStream(bs)
+		},
+		remote: func(c *client) agshctx.ContextStore {
+			return &remoteContext{client: c}
+		},
+		attached: func(st agshctx.ContextStore, owner bool) {
+			if owner {
+				st.(*agshctx.BoltStore).SetHistoryLimit(s.historyLimit)
+			}
+			if s.watching {
+				s.relay(st)
+			}
+		},
+	}
+	if err := s.sh.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Owner reports whether this process holds the file.
+func (s *ContextStore) Owner() bool {
+	return s.sh.isOwner()
+}
+
+func (s *ContextStore) Get(scope, key string) (any, error) {
+	var v any
+	err := s.sh.with(func(st agshctx.ContextStore) (err error) {
+		v, err = st.Get(scope, key)
+		return err
+	})
+	return v, err
+}
+
+func (s *ContextStore) Set(scope, key string, value any) error {
+	return s.SetFrom("", scope, key, value)
+}
+
+func (s *ContextStore) Delete(scope, key string) error {
+	return s.DeleteFrom("", scope, key)
+}
+
+func (s *ContextStore) SetFrom(source, scope, key string, value any) error {
+	return s.sh.with(func(st agshctx.ContextStore) error {
+		return agshctx.WithSource(st, source).Set(scope, key, value)
+	})
+}
+
+func (s *ContextStore) GetVersioned(scope, key string) (any, string, error) {
+	var (
+		v       any
+		version string
+	)
+	err := s.sh.with(func(st agshctx.ContextStore) (err error) {
+		v, version, err = st.(agshctx.VersionedStore).GetVersioned(scope, key)
+		return err
+	})
+	return v, version, err
+}
+
+func (s *ContextStore) CompareAndSet(source, scope, key string, value any, version string) (string, error) {
+	var next string
+	err := s.sh.with(func(st agshctx.ContextStore) (err error) {
+		next, err = st.(agshctx.VersionedStore).CompareAndSet(source, scope, key, value, version)
+		return err
+	})
+	return next, err
+}
+
+func (s *ContextStore) DeleteFrom(source, scope, key string) error {
+	return s.sh.with(func(st agshctx.ContextStore) error {
+		return agshctx.WithSource(st, source).Delete(scope, key)
+	})
+}
+
+// SetHistoryLimit keeps only the latest n history entries while this
+// process owns the file; 0 keeps all.
+func (s *ContextStore) SetHistoryLimit(n int) {
+	s.sh.mu.Lock()
+	defer s.sh.mu.Unlock()
+	s.historyLimit = n
+	if s.sh.ready && s.sh.owner {
+		s.sh.cur.(*agshctx.BoltStore).SetHistoryLimit(n)
+	}
+}
+
+func (s *ContextStore) List(scope string) (map[string]any, error) {
+	var m map[string]any
+	err := s.sh.with(func(st agshctx.ContextStore) (err error) {
+		m, err = st.List(scope)
+		return err
+	})
+	return m, err
+}
+
+// Watch returns a channel receiving the changes f selects, whichever
+// process makes them.
+func (s *ContextStore) Watch(f agshctx.WatchFilter) <-chan agshctx.Change {
+	ch := s.feed.Watch(f)
+	s.sh.mu.Lock()
+	defer s.sh.mu.Unlock()
+	if !s.watching && s.sh.ready {
+		s.watching = true
+		s.relay(s.sh.cur)
+	}
+	return ch
+}
+
+// Unwatch stops a watch and closes its channel.
+func (s *ContextStore) Unwatch(ch <-chan agshctx.Change) {
+	s.feed.Unwatch(ch)
+}
+
+// Close stops serving other processes and releases the file.
+func (s *ContextStore) Close() error {
+	err := s.sh.close()
+	s.feed.CloseAll()
+	return err
+}
+
+// relay forwards the changes of st to the store's watchers: straight from
+// the file when this process owns it, else as streamed by the owner. When
+// the owner goes away, the store reconnects, which relays from the new
+// one. s.sh.mu must be held.
+func (s *ContextStore) relay(st agshctx.ContextStore) {
+	var changes <-chan agshctx.Change
+	switch st := st.(type) {
+	case *agshctx.BoltStore:
+		changes = st.Watch(agshctx.WatchFilter{})
+	case *remoteContext:
+		ch, err := st.watch()
+		if err != nil {
+			return
+		}
+		changes = ch
+	}
+	go func() {
+		for c := range changes {
+			s.feed.Notify(c)
+		}
+		if _, ok := st.(*remoteContext); ok {
+			s.sh.reconnect(st)
+		}
+	}()
+}
+
+// watchStream answers a context.watch request from another process by
+// sending it every change to bs as a notification, until either side
+// closes the connection.
+func watchStream(bs *agshctx.BoltStore) streamFunc {
+	return func(conn net.Conn, r *bufio.Reader, req protocol.Request) bool {
+		if req.Method != protocol.MethodContextWatch {
+			return false
+		}
+		changes := bs.Watch(agshctx.WatchFilter{})
+		defer bs.Unwatch(changes)
+		go func() {
+			// The watcher sends nothing more, so a read only ends when the
+			// connection does.
+			for {
+				if _, err := r.ReadBytes('\n'); err != nil {
+					bs.Unwatch(changes)
+					return
+				}
+			}
+		}()
+		if writeLine(conn, protocol.NewResponse(req.ID, "ok")) != nil {
+			return true
+		}
+		for c := range changes {
+			if writeLine(conn, protocol.NewNotification(protocol.NotifyContextChanged, c)) != nil {
+				return true
+			}
+		}
+		return true
+	}
+}
+
+// contextHandler answers the context.* methods from store.
+func contextHandler(store *agshctx.BoltStore) *protocol.Handler {
+	h := protocol.NewHandler()
+	fail := func(err error) *protocol.Error {
+		return &protocol.Error{Code: protocol.CodeInternalError, Message: err.Error()}
+	}
+	h.Register(protocol.MethodContextGet, func(params json.RawMessage) (any, *protocol.Error) {
+		p, rpcErr := protocol.ParseParams[protocol.ContextGetParams](params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		v, version, err := store.GetVersioned(p.Scope, p.Key)
+		if err != nil {
+			return nil, fail(err)
+		}
+		if p.WithVersion {
+			return protocol.ContextValue{Value: v, Version: version}, nil
+		}
+		return v, nil
+	})
+	h.Register(protocol.MethodContextSet, func(params json.RawMessage) (any, *protocol.Error) {
+		p, rpcErr := protocol.ParseParams[protocol.ContextSetParams](params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		if p.ExpectedVersion != nil {
+			version, err := store.CompareAndSet(p.Source, p.Scope, p.Key, p.Value, *p.ExpectedVersion)
+			var conflict *agshctx.ConflictError
+			if errors.As(err, &conflict) {
+				return nil, encodeConflict(conflict)
+			}
+			if err != nil {
+				return nil, fail(err)
+			}
+			return protocol.ContextValue{Version: version}, nil
+		}
+		if err := store.SetFrom(p.Source, p.Scope, p.Key, p.Value); err != nil {
+			return nil, fail(err)
+		}
+		return "ok", nil
+	})
+	h.Register(protocol.MethodContextDelete, func(params json.RawMessage) (any, *protocol.Error) {
+		p, rpcErr := protocol.ParseParams[protocol.ContextDeleteParams](params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		if err := agshctx.WithSource(store, p.Source).Delete(p.Scope, p.Key); err != nil {
+			return nil, fail(err)
+		}
+		return "ok", nil
+	})
+	h.Register(protocol.MethodContextList, func(params json.RawMessage) (any, *protocol.Error) {
+		p, rpcErr := protocol.ParseParams[protocol.ContextListParams](params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		m, err := store.List(p.Scope)
+		if err != nil {
+			return nil, fail(err)
+		}
+		return m, nil
+	})
+	return h
+}
+
+// remoteContext is a context store served by the process owning the file.
+type remoteContext struct {
+	*client
+
+	watchMu   sync.Mutex
+	watchConn net.Conn // streams changes once watch is called
+}
+
+func (r *remoteContext) Get(scope, key string) (any, error) {
+	var v any
+	err := r.call(protocol.MethodContextGet, protocol.ContextGetParams{Scope: scope, Key: key}, &v)
+	return v, err
+}
+
+func (r *remoteContext) Set(scope, key string, value any) error {
+	return r.SetFrom("", scope, key, value)
+}
+
+func (r *remoteContext) Delete(scope, key string) error {
+	return r.DeleteFrom("", scope, key)
+}
+
+func (r *remoteContext) SetFrom(source, scope, key string, value any) error {
+	return r.call(protocol.MethodContextSet, protocol.ContextSetParams{Scope: scope, Key: key, Value: value, Source: source}, nil)
+}
+
+func (r *remoteContext) GetVersioned(scope, key string) (any, string, error) {
+	var v protocol.ContextValue
+	err := r.call(protocol.MethodContextGet, protocol.ContextGetParams{Scope: scope, Key: key, WithVersion: true}, &v)
+	return v.Value, v.Version, err
+}
+
+func (r *remoteContext) CompareAndSet(source, scope, key string, value any, version string) (string, error) {
+	var v protocol.ContextValue
+	err := r.call(protocol.MethodContextSet, protocol.ContextSetParams{Scope: scope, Key: key, Value: value, Source: source, ExpectedVersion: &version}, &v)
+	return v.Version, decodeConflict(err)
+}
+
+func (r *remoteContext) DeleteFrom(source, scope, key string) error {
+	return r.call(protocol.MethodContextDelete, protocol.ContextDeleteParams{Scope: scope, Key: key, Source: source}, nil)
+}
+
+func (r *remoteContext) List(scope string) (map[string]any, error) {
+	m := make(map[string]any)
+	err := r.call(protocol.MethodContextList, protocol.ContextListParams{Scope: scope}, &m)
+	return m, err
+}
+
+func (r *remoteContext) Close() error {
+	r.watchMu.Lock()
+	if r.watchConn != nil {
+		r.watchConn.Close()
+	}
+	r.watchMu.Unlock()
+	return r.client.Close()
+}
+
+// watch opens a second connection to the owner, on which it streams every
+// change. The channel is closed when the connection ends.
+func (r *remoteContext) watch() (<-chan agshctx.Change, error) {
+	c, err := dial(r.sock)
+	if err != nil {
+		return nil, err
+	}
+	c.conn.SetDeadline(time.Now().Add(callTimeout))
+	if err := writeLine(c.conn, protocol.Request{JSONRPC: "2.0", ID: 1, Method: protocol.MethodContextWatch}); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("%w: %v", errOwnerGone, err)
+	}
+	if _, err := c.reader.ReadBytes('\n'); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("%w: %v", errOwnerGone, err)
+	}
+	c.conn.SetDeadline(time.Time{})
+
+	r.watchMu.Lock()
+	r.watchConn = c.conn
+	r.watchMu.Unlock()
+
+	ch := make(chan agshctx.Change, 64)
+	go func() {
+		defer close(ch)
+		for {
+			line, err := c.reader.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			var n struct {
+				Params agshctx.Change `json:"params"`
+			}
+			if json.Unmarshal(line, &n) == nil {
+				ch <- n.Params
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// encodeConflict reports a conflict to the process that made the request.
+func encodeConflict(e *agshctx.ConflictError) *protocol.Error {
+	return &protocol.Error{
+		Code:    protocol.CodeContextConflict,
+		Message: e.Error(),
+		Data:    protocol.ContextConflictData{Scope: e.Scope, Key: e.Key, ExpectedVersion: e.Expected, Version: e.Actual},
+	}
+}
+
+// decodeConflict turns a conflict reported by the owner back into a
+// *ConflictError.
+func decodeConflict(err error) error {
+	var e *protocol.Error
+	if !errors.As(err, &e) || e.Code != protocol.CodeContextConflict {
+		return err
+	}
+	var d protocol.ContextConflictData
+	raw, mErr := json.Marshal(e.Data)
+	if mErr != nil || json.Unmarshal(raw, &d) != nil {
+		return err
+	}
+	return &agshctx.ConflictError{Scope: d.Scope, Key: d.Key, Expected: d.ExpectedVersion, Actual: d.Version}
+}
@@ -0,0 +1,138 @@
+package storeserver
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func TestContextStore(t *testing.T) {
+	// bbolt locks the file per open, so two opens in one process behave
+	// like two processes.
+	path := filepath.Join(t.TempDir(), "context.db")
+	owner, err := OpenContextStore(path)
+	if err != nil {
+		t.Fatalf("open owner: %v", err)
+	}
+	defer owner.Close()
+	other, err := OpenContextStore(path)
+	if err != nil {
+		t.Fatalf("open second store: %v", err)
+	}
+	defer other.Close()
+	if !owner.Owner() || other.Owner() {
+		t.Fatalf("owner = %v, other = %v", owner.Owner(), other.Owner())
+	}
+
+	// Changes made in either process reach watchers in both.
+	ownerWatch := owner.Watch(agshctx.WatchFilter{Keys: []string{"k1"}})
+	otherWatch := other.Watch(agshctx.WatchFilter{Keys: []string{"goal"}})
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := other.Set(agshctx.ScopeSession, fmt.Sprintf("k%d", i), map[string]any{"n": i}); err != nil {
+				t.Errorf("remote Set: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, err := owner.Get(agshctx.ScopeSession, "k3"); err != nil || !reflect.DeepEqual(v, map[string]any{"n": float64(3)}) {
+		t.Errorf("owner Get = %v, %v", v, err)
+	}
+	owner.Set(agshctx.ScopeProject, "goal", "ship")
+	if v, err := other.Get(agshctx.ScopeProject, "goal"); err != nil || v != "ship" {
+		t.Errorf("remote Get = %v, %v", v, err)
+	}
+	if err := other.Delete(agshctx.ScopeSession, "k0"); err != nil {
+		t.Errorf("remote Delete: %v", err)
+	}
+	if m, err := other.List(agshctx.ScopeSession); err != nil || len(m) != 7 {
+		t.Errorf("remote List = %v, %v", m, err)
+	}
+	if _, err := other.Get(agshctx.ScopeSession, "k0"); err == nil || !strings.Contains(err.Error(), "key not found") {
+		t.Errorf("remote Get of a deleted key: %v", err)
+	}
+
+	// Remote changes are recorded with their source.
+	agshctx.WithSource(other, "agent").Set(agshctx.ScopeProject, "goal", "review")
+	if h, err := agshctx.KeyHistory(owner, agshctx.ScopeProject, "goal"); err != nil || len(h) != 2 || h[1].Source != "agent" {
+		t.Errorf("history of a remote change = %+v, %v", h, err)
+	}
+
+	if c := nextChange(t, ownerWatch); c.Key != "k1" || c.Op != "set" {
+		t.Errorf("owner saw %+v", c)
+	}
+	for _, want := range []string{"ship", "review"} {
+		if c := nextChange(t, otherWatch); c.Value != want {
+			t.Errorf("other saw %+v, want value %s", c, want)
+		}
+	}
+
+	// When the owner closes, the other store takes the file over.
+	if err := owner.Close(); err != nil {
+		t.Fatalf("close owner: %v", err)
+	}
+	if v, err := other.Get(agshctx.ScopeProject, "goal"); err != nil || v != "review" {
+		t.Errorf("Get after takeover = %v, %v", v, err)
+	}
+	if !other.Owner() {
+		t.Error("other store did not take over")
+	}
+	other.Set(agshctx.ScopeProject, "goal", "done")
+	if c := nextChange(t, otherWatch); c.Value != "done" {
+		t.Errorf("watch after takeover saw %+v", c)
+	}
+}
+
+func TestContextStoreCompareAndSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "context.db")
+	owner, err := OpenContextStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer owner.Close()
+	other, err := OpenContextStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	owner.Set(agshctx.ScopeSession, "k", "a")
+	_, version, err := other.GetVersioned(agshctx.ScopeSession, "k")
+	if err != nil || version != agshctx.ValueVersion("a") {
+		t.Fatalf("remote GetVersioned = %q, %v", version, err)
+	}
+	owner.Set(agshctx.ScopeSession, "k", "b")
+	_, err = other.CompareAndSet("", agshctx.ScopeSession, "k", "c", version)
+	var conflict *agshctx.ConflictError
+	if !errors.As(err, &conflict) || conflict.Actual != agshctx.ValueVersion("b") {
+		t.Fatalf("remote stale update: %v", err)
+	}
+	if _, err := other.CompareAndSet("", agshctx.ScopeSession, "k", "c", agshctx.ValueVersion("b")); err != nil {
+		t.Errorf("remote update: %v", err)
+	}
+}
+
+func nextChange(t *testing.T, ch <-chan agshctx.Change) agshctx.Change {
+	t.Helper()
+	select {
+	case c, ok := <-ch:
+		if !ok {
+			t.Fatal("watch closed")
+		}
+		return c
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change received")
+	}
+	return agshctx.Change{}
+}
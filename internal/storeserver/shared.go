@@ -0,0 +1,372 @@
+// Package storeserver shares agsh's bbolt files between processes. bbolt
+// lets only one process hold a file, so the first agsh process to open one
+// owns it and serves the others over a Unix socket beside it, one JSON-RPC
+// message per line. When the owner exits, the next operation in another
+// process takes the file over.
+package storeserver
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	berrors "go.etcd.io/bbolt/errors"
+
+	"github.com/cgast/agsh/pkg/protocol"
+)
+
+const (
+	// lockWait is how long an open waits for the file lock before looking
+	// for the process that holds it.
+	lockWait = 100 * time.Millisecond
+	// openTimeout bounds the search for a process to connect to.
+	openTimeout = 5 * time.Second
+	// callTimeout bounds one request to the owning process.
+	callTimeout = 30 * time.Second
+)
+
+// errOwnerGone is wrapped by errors talking to an owner that went away.
+var errOwnerGone = errors.New("store owner went away")
+
+// resource is what a shared file is used through: the open file in the
+// process that owns it, or a client of the owner elsewhere.
+type resource interface {
+	comparable
+	Close() error
+}
+
+// streamFunc may take over a connection for a request, such as a watch,
+// that is not answered with a single response. It reports whether it did.
+type streamFunc func(conn net.Conn, r *bufio.Reader, req protocol.Request) bool
+
+// shared keeps a T open on one file, owning it or connected to its owner,
+// and moves to the new owner when the old one goes away.
+type shared[T resource] struct {
+	path string
+	sock string
+
+	// open opens the file, waiting up to wait for its lock.
+	open func(wait time.Duration) (T, error)
+	// serve returns what answers other processes for an opened file; the
+	// streamFunc may be nil.
+	serve func(T) (*protocol.Handler, streamFunc)
+	// remote returns a T that forwards to the owner over c.
+	remote func(c *client) T
+	// attached, if set, is called with mu held for each newly connected T.
+	attached func(st T, owner bool)
+
+	mu    sync.RWMutex
+	cur   T
+	ready bool // cur is open
+	owner bool // cur is the file itself
+	srv   *server
+}
+
+// start connects for the first time.
+func (s *shared[T]) start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connect()
+}
+
+// isOwner reports whether this process holds the file.
+func (s *shared[T]) isOwner() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ready && s.owner
+}
+
+// with runs op against the current T. If the owner went away, it takes
+// over the file or connects to the new owner and runs op once more.
+func (s *shared[T]) with(op func(T) error) error {
+	for retried := false; ; retried = true {
+		s.mu.RLock()
+		if !s.ready {
+			s.mu.RUnlock()
+			return fmt.Errorf("%s is closed", s.path)
+		}
+		st := s.cur
+		err := op(st)
+		s.mu.RUnlock()
+		if retried || !errors.Is(err, errOwnerGone) {
+			return err
+		}
+		if err := s.reconnect(st); err != nil {
+			return err
+		}
+	}
+}
+
+// reconnect replaces a T whose owner went away, unless another operation
+// already has.
+func (s *shared[T]) reconnect(gone T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ready || s.cur != gone {
+		return nil
+	}
+	gone.Close()
+	s.ready = false
+	return s.connect()
+}
+
+// connect opens the file, or connects to the process that holds it. The
+// owner may still be starting to serve, so connecting is retried for a
+// while. s.mu must be held.
+func (s *shared[T]) connect() error {
+	deadline := time.Now().Add(openTimeout)
+	for {
+		st, err := s.open(lockWait)
+		if err == nil {
+			s.use(st, true)
+			h, stream := s.serve(st)
+			s.srv = listen(s.sock, h, stream)
+			return nil
+		}
+		if !errors.Is(err, berrors.ErrTimeout) {
+			return err
+		}
+		c, err := dial(s.sock)
+		if err == nil {
+			s.use(s.remote(c), false)
+			return nil
+		}
+		if !errors.Is(err, errOwnerGone) {
+			return fmt.Errorf("open %s: %w", s.path, err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("open %s: held by another process that does not share it", s.path)
+		}
+	}
+}
+
+func (s *shared[T]) use(st T, owner bool) {
+	s.cur, s.ready, s.owner = st, true, owner
+	if s.attached != nil {
+		s.attached(st, owner)
+	}
+}
+
+// close stops serving other processes and releases the file.
+func (s *shared[T]) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ready {
+		return nil
+	}
+	s.srv.stop()
+	s.srv = nil
+	s.ready = false
+	return s.cur.Close()
+}
+
+// socketPath returns where the owner of the file at path listens: beside
+// the file, or in the user's socket directory (see socketDir) when that
+// path is too long for a socket. If there is no socket directory it
+// returns the long path, and the file is not shared.
+func socketPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sock := path + ".sock"
+	if len(sock) < 100 {
+		return sock
+	}
+	dir, err := socketDir()
+	if err != nil {
+		return sock
+	}
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".sock")
+}
+
+// socketDir returns a directory only the current user can use, so no one
+// else can plant a socket there: agsh under $XDG_RUNTIME_DIR, or under the
+// user cache directory. It is created if missing.
+func socketDir() (string, error) {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		var err error
+		if base, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	dir := filepath.Join(base, "agsh")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// MkdirAll leaves an existing directory's mode alone.
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// server answers other processes' requests on a Unix socket.
+type server struct {
+	ln     net.Listener
+	h      *protocol.Handler
+	stream streamFunc
+
+	mu      sync.Mutex
+	conns   map[net.Conn]bool // connections being served
+	closing bool              // no more connections are accepted
+	served  sync.WaitGroup
+}
+
+// listen starts serving h on sock. A socket left by an owner that crashed
+// is replaced. If the socket cannot be created it returns nil: the file is
+// still usable in this process, just not shared.
+func listen(sock string, h *protocol.Handler, stream streamFunc) *server {
+	os.Remove(sock)
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil
+	}
+	srv := &server{ln: ln, h: h, stream: stream, conns: make(map[net.Conn]bool)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			if srv.closing {
+				srv.mu.Unlock()
+				conn.Close()
+				return
+			}
+			srv.conns[conn] = true
+			srv.served.Add(1)
+			srv.mu.Unlock()
+			go srv.serveConn(conn)
+		}
+	}()
+	return srv
+}
+
+// serveConn answers one connection's requests, one JSON message per line.
+func (srv *server) serveConn(conn net.Conn) {
+	defer srv.served.Done()
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.conns, conn)
+		srv.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req protocol.Request
+		if srv.stream != nil && json.Unmarshal(line, &req) == nil && srv.stream(conn, r, req) {
+			return
+		}
+		if writeLine(conn, srv.h.HandleMessage(line)) != nil {
+			return
+		}
+	}
+}
+
+// stop closes the socket and every served connection, and waits for
+// requests in progress to finish. A nil server does nothing.
+func (srv *server) stop() {
+	if srv == nil {
+		return
+	}
+	srv.ln.Close()
+	srv.mu.Lock()
+	srv.closing = true
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.mu.Unlock()
+	srv.served.Wait()
+}
+
+// writeLine sends msg as one line of JSON.
+func writeLine(conn net.Conn, msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+// client sends requests to the owner of a file.
+type client struct {
+	sock   string
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int
+}
+
+// dial connects to the owner listening on sock. A socket another user
+// created is refused rather than trusted with the store's data.
+func dial(sock string) (*client, error) {
+	fi, err := os.Lstat(sock)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errOwnerGone, err)
+	}
+	if !ownedByCurrentUser(fi) {
+		return nil, fmt.Errorf("socket %s belongs to another user", sock)
+	}
+	conn, err := net.DialTimeout("unix", sock, time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errOwnerGone, err)
+	}
+	return &client{sock: sock, conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// call sends one request and decodes its result into result. Failures to
+// reach the owner wrap errOwnerGone; errors the owner reports are returned
+// as *protocol.Error.
+func (c *client) call(method string, params, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal params: %w", err)
+	}
+	c.nextID++
+	c.conn.SetDeadline(time.Now().Add(callTimeout))
+	if err := writeLine(c.conn, protocol.Request{JSONRPC: "2.0", ID: c.nextID, Method: method, Params: raw}); err != nil {
+		return fmt.Errorf("%w: %v", errOwnerGone, err)
+	}
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("%w: %v", errOwnerGone, err)
+	}
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *protocol.Error `json:"error"`
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return fmt.Errorf("%w: %v", errOwnerGone, err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil && len(resp.Result) > 0 {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+func (c *client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,56 @@
+package storeserver
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSocketPath(t *testing.T) {
+	runtime := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtime)
+
+	short := filepath.Join(t.TempDir(), "context.db")
+	if got := socketPath(short); got != short+".sock" {
+		t.Errorf("short path: socket = %s, want it beside the file", got)
+	}
+
+	long := filepath.Join(t.TempDir(), strings.Repeat("d", 100), "context.db")
+	got := socketPath(long)
+	if filepath.Dir(got) != filepath.Join(runtime, "agsh") {
+		t.Fatalf("long path: socket = %s, want it in the runtime dir", got)
+	}
+	if got != socketPath(long) || got == socketPath(long+"2") {
+		t.Error("long path: socket name is not stable per file")
+	}
+	if fi, err := os.Stat(filepath.Dir(got)); err != nil || fi.Mode().Perm() != 0700 {
+		t.Errorf("socket dir = %v, %v; want mode 0700", fi, err)
+	}
+}
+
+func TestDialRefusesOtherUsersSocket(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("needs root to give the socket to another user")
+	}
+	sock := filepath.Join(t.TempDir(), "s.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+
+	c, err := dial(sock)
+	if err != nil {
+		t.Fatalf("own socket: %v", err)
+	}
+	c.Close()
+
+	if err := os.Lchown(sock, 65534, 65534); err != nil {
+		t.Skipf("chown: %v", err)
+	}
+	if _, err := dial(sock); err == nil || !strings.Contains(err.Error(), "another user") {
+		t.Errorf("other user's socket: err = %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+// Package agsh embeds agsh in other Go programs. A Runtime holds what the
+// agsh command wires together — the command registry with its middleware,
+// the context store, the event bus and the checkpoint manager — so a
+// service can execute commands and run verified specs without the CLI.
+//
+//	rt, err := agsh.New(agsh.WithStorePath("agsh.db"))
+//	if err != nil { ... }
+//	defer rt.Close()
+//	rt.RegisterCommand(myCommand)
+//	result, err := rt.RunSpec(ctx, "report.agsh.yaml", map[string]string{"repo": "cgast/agsh"})
+package agsh
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/platform"
+	dataplatform "github.com/cgast/agsh/pkg/platform/data"
+	"github.com/cgast/agsh/pkg/platform/fs"
+	"github.com/cgast/agsh/pkg/platform/workspace"
+	"github.com/cgast/agsh/pkg/spec"
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+// Runtime executes commands and runs specs. It is safe for concurrent use
+// as far as its store and commands are.
+type Runtime struct {
+	registry    *platform.Registry
+	store       agshctx.ContextStore
+	bus         *events.MemoryBus
+	checkpoints verify.CheckpointManager
+	asker       agshctx.Asker
+	runsDir     string
+	loadOpts    []spec.LoadOption
+	closers     []func() error
+}
+
+// SandboxConfig restricts the files the built-in commands and specs may
+// touch, as the sandbox section of .agsh/config.yaml does.
+type SandboxConfig struct {
+	AllowedPaths []string
+	DeniedPaths  []string
+	MaxFileSize  string // e.g. "10MB"; empty means no limit
+}
+
+type options struct {
+	store       agshctx.ContextStore
+	storePath   string
+	sandbox     *SandboxConfig
+	checkpoints verify.CheckpointManager
+	asker       agshctx.Asker
+	runsDir     string
+	middleware  []platform.Middleware
+	noBuiltins  bool
+}
+
+// Option configures a Runtime.
+type Option func(*options)
+
+// WithStore sets the context store. The caller keeps ownership: Close
+// does not close it.
+func WithStore(store agshctx.ContextStore) Option {
+	return func(o *options) { o.store = store }
+}
+
+// WithStorePath opens the context store at path, creating it if needed.
+// Without WithStore or WithStorePath the Runtime uses a store in a
+// temporary directory that Close removes.
+func WithStorePath(path string) Option {
+	return func(o *options) { o.storePath = path }
+}
+
+// WithSandbox confines the built-in commands to the files cfg allows.
+func WithSandbox(cfg SandboxConfig) Option {
+	return func(o *options) { o.sandbox = &cfg }
+}
+
+// WithCheckpoints saves checkpoints before risky steps with mgr, so runs
+// can roll back. Without it, runs are not checkpointed.
+func WithCheckpoints(mgr verify.CheckpointManager) Option {
+	return func(o *options) { o.checkpoints = mgr }
+}
+
+// WithAsker answers the ask steps of specs. Without it, ask steps take
+// their default or fail.
+func WithAsker(asker agshctx.Asker) Option {
+	return func(o *options) { o.asker = asker }
+}
+
+// WithRunsDir keeps the artifacts of steps under dir/<run-id>.
+func WithRunsDir(dir string) Option {
+	return func(o *options) { o.runsDir = dir }
+}
+
+// WithMiddleware adds command middleware after the built-in timing and
+// sandbox middleware.
+func WithMiddleware(mws ...platform.Middleware) Option {
+	return func(o *options) { o.middleware = append(o.middleware, mws...) }
+}
+
+// WithoutBuiltins leaves out the built-in fs and data commands, so only
+// the commands registered with RegisterCommand are available.
+func WithoutBuiltins() Option {
+	return func(o *options) { o.noBuiltins = true }
+}
+
+// New creates a Runtime.
+func New(opts ...Option) (*Runtime, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rt := &Runtime{
+		registry:    platform.NewRegistry(),
+		store:       o.store,
+		bus:         events.NewMemoryBus(),
+		checkpoints: o.checkpoints,
+		asker:       o.asker,
+		runsDir:     o.runsDir,
+	}
+
+	var sb *sandbox.Sandbox
+	if o.sandbox != nil {
+		var err error
+		sb, err = sandbox.New(sandbox.Config{
+			AllowedPaths: o.sandbox.AllowedPaths,
+			DeniedPaths:  o.sandbox.DeniedPaths,
+			MaxFileSize:  o.sandbox.MaxFileSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("sandbox: %w", err)
+		}
+		rt.loadOpts = []spec.LoadOption{spec.WithPathCheck(sb.CheckPath)}
+	}
+
+	if rt.store == nil {
+		path := o.storePath
+		if path == "" {
+			dir, err := os.MkdirTemp("", "agsh-")
+			if err != nil {
+				return nil, fmt.Errorf("context store: %w", err)
+			}
+			rt.closers = append(rt.closers, func() error { return os.RemoveAll(dir) })
+			path = filepath.Join(dir, "context.db")
+		}
+		store, err := agshctx.NewBoltStore(path)
+		if err != nil {
+			rt.Close()
+			return nil, fmt.Errorf("context store: %w", err)
+		}
+		rt.store = store
+		// Closed before the temporary directory is removed.
+		rt.closers = append([]func() error{store.Close}, rt.closers...)
+	}
+
+	if !o.noBuiltins {
+		registerBuiltins(rt.registry, sb)
+	}
+	var checker platform.PathChecker
+	if sb != nil {
+		checker = sb
+	}
+	rt.registry.SetMiddleware(append([]platform.Middleware{platform.Timing(), platform.Sandbox(checker)}, o.middleware...)...)
+	return rt, nil
+}
+
+// registerBuiltins registers the commands that need no platform
+// configuration.
+func registerBuiltins(registry *platform.Registry, sb *sandbox.Sandbox) {
+	registry.RegisterNamespace(fs.Namespace)
+	registry.Register(&fs.ListCommand{Sandbox: sb})
+	registry.Register(&fs.ReadCommand{Sandbox: sb})
+	registry.Register(&fs.StatCommand{Sandbox: sb})
+	registry.Register(&fs.GrepCommand{Sandbox: sb})
+	registry.Register(&fs.WriteCommand{Sandbox: sb})
+	registry.Register(&fs.PatchCommand{Sandbox: sb})
+	registry.Register(&fs.WatchCommand{Sandbox: sb})
+	registry.Register(&fs.ZipCommand{Sandbox: sb})
+	registry.Register(&fs.UnzipCommand{Sandbox: sb})
+
+	registry.RegisterNamespace(dataplatform.Namespace)
+	registry.Register(&dataplatform.HashCommand{Sandbox: sb})
+
+	registry.RegisterNamespace(workspace.Namespace)
+	registry.Register(&workspace.MapCommand{Sandbox: sb})
+}
+
+// Close closes the context store if the Runtime opened it.
+func (rt *Runtime) Close() error {
+	var errs []error
+	for _, c := range rt.closers {
+		errs = append(errs, c())
+	}
+	rt.closers = nil
+	return errors.Join(errs...)
+}
+
+// Registry returns the command registry, for registering namespaces,
+// aliases and health checks.
+func (rt *Runtime) Registry() *platform.Registry { return rt.registry }
+
+// Store returns the context store.
+func (rt *Runtime) Store() agshctx.ContextStore { return rt.store }
+
+// Bus returns the event bus runs publish to.
+func (rt *Runtime) Bus() events.EventBus { return rt.bus }
+
+// RegisterCommand adds a command. Its namespace, if new, is registered
+// with it.
+func (rt *Runtime) RegisterCommand(cmd platform.PlatformCommand) error {
+	return rt.registry.Register(cmd)
+}
+
+// Execute runs one command through the middleware chain.
+func (rt *Runtime) Execute(ctx gocontext.Context, name string, input agshctx.Envelope) (agshctx.Envelope, error) {
+	return rt.registry.Execute(ctx, name, input, rt.store)
+}
+
+// Subscribe returns a channel of the events of the given types, or of all
+// events without filter. Unsubscribe it when done.
+func (rt *Runtime) Subscribe(filter ...events.EventType) <-chan events.Event {
+	return rt.bus.Subscribe(filter...)
+}
+
+// Unsubscribe stops and closes a channel Subscribe returned.
+func (rt *Runtime) Unsubscribe(ch <-chan events.Event) {
+	rt.bus.Unsubscribe(ch)
+}
+
+// RunResult is the outcome of a spec run.
+type RunResult struct {
+	Plan     spec.ExecutionPlan
+	Pipeline agshctx.PipelineResult
+
+	// Verification is the check of the spec's success criteria; nil when
+	// it has none or the run failed before they were checked.
+	Verification *verify.VerificationResult
+}
+
+// Passed reports whether every step succeeded and every success criterion
+// held.
+func (r RunResult) Passed() bool {
+	return r.Pipeline.Success && (r.Verification == nil || r.Verification.Passed)
+}
+
+// Plan loads and validates the spec at path with params and plans it,
+// including the specs it uses, without running it.
+func (rt *Runtime) Plan(path string, params map[string]string) (spec.ExecutionPlan, error) {
+	projSpec, err := LoadUsedSpec(path, params, rt.loadOpts...)
+	if err != nil {
+		return spec.ExecutionPlan{}, err
+	}
+	return PlanSpec(projSpec, path, rt.registry, rt.loadOpts...)
+}
+
+// RunSpec plans the spec at path with params and runs it; see RunPlan.
+// There is no approval step: embedding programs that want one call Plan,
+// inspect the plan and then RunPlan.
+func (rt *Runtime) RunSpec(ctx gocontext.Context, path string, params map[string]string) (RunResult, error) {
+	plan, err := rt.Plan(path, params)
+	if err != nil {
+		return RunResult{Plan: plan}, err
+	}
+	return rt.RunPlan(ctx, plan)
+}
+
+// RunPlan runs plan and checks its success criteria. Failed criteria are
+// an error wrapping context.ErrVerificationFailed, after rolling back when
+// the spec asks for it and checkpoints are configured.
+func (rt *Runtime) RunPlan(ctx gocontext.Context, plan spec.ExecutionPlan) (RunResult, error) {
+	res := RunResult{Plan: plan}
+	pipeline := &agshctx.Pipeline{
+		Steps:       PipelineSteps(plan),
+		Context:     rt.store,
+		Executor:    &Executor{Registry: rt.registry},
+		Events:      &Publisher{Bus: rt.bus},
+		Specs:       &SpecRunner{Registry: rt.registry, Store: rt.store, Bus: rt.bus, Checkpoints: rt.checkpoints, Asker: rt.asker},
+		Asker:       rt.asker,
+		Timeout:     PlanTimeout(plan),
+		OnTimeout:   plan.Limits.OnTimeout,
+		IntentDrift: plan.IntentDrift,
+	}
+	if rt.runsDir != "" {
+		pipeline.Artifacts = agshctx.DirArtifactStore{Root: rt.runsDir}
+	}
+	if rt.checkpoints != nil {
+		pipeline.Checkpointer = &Checkpointer{Manager: rt.checkpoints, Store: rt.store}
+	}
+
+	ctx, err := PlanContext(ctx, plan)
+	if err != nil {
+		return res, err
+	}
+	res.Pipeline, err = pipeline.Run(ctx, agshctx.NewEnvelope(nil, "text/plain", "run"))
+	if err != nil || len(plan.SuccessCriteria) == 0 {
+		return res, err
+	}
+
+	rt.bus.Publish(events.NewEvent(events.EventVerifyStart, map[string]any{
+		"type":       "success_criteria",
+		"assertions": len(plan.SuccessCriteria),
+	}))
+	vr, err := VerifyCriteria(ctx, plan, res.Pipeline, rt.store)
+	if err != nil {
+		return res, fmt.Errorf("verification error: %w", err)
+	}
+	res.Verification = &vr
+	rt.bus.Publish(events.NewEvent(events.EventVerifyResult, map[string]any{
+		"type":       "success_criteria",
+		"passed":     vr.Passed,
+		"summary":    fmt.Sprintf("%d/%d assertions passed", CountPassed(vr.Results), len(vr.Results)),
+		"assertions": vr.Details(),
+	}))
+	if vr.Passed {
+		return res, nil
+	}
+	err = fmt.Errorf("%w: %d/%d assertions passed", agshctx.ErrVerificationFailed, CountPassed(vr.Results), len(vr.Results))
+	if plan.OnVerifyFailure == "rollback" {
+		if name, rbErr := Rollback(pipeline.Checkpointer, res.Pipeline); rbErr != nil {
+			err = fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		} else {
+			err = fmt.Errorf("%w (rolled back to checkpoint %s)", err, name)
+		}
+	}
+	return res, err
+}
@@ -0,0 +1,149 @@
+package agsh
+
+import (
+	gocontext "context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+const reportSpec = `
+apiVersion: agsh/v2
+kind: ProjectSpec
+metadata:
+  name: report
+goal: Write a report
+allowed_commands: ["fs:write"]
+params:
+  - name: out
+    type: dir
+  - name: text
+steps:
+  - command: fs:write
+    input:
+      path: "{{out}}/report.md"
+      content: "{{text}}"
+output:
+  path: "{{out}}/report.md"
+  format: markdown
+success_criteria:
+  - type: contains
+    target: artifact.output
+    expected: hello
+`
+
+// upperCommand is a command an embedding program registers.
+type upperCommand struct{}
+
+func (upperCommand) Name() string        { return "text:upper" }
+func (upperCommand) Description() string { return "Uppercase the input" }
+func (upperCommand) Namespace() string   { return "text" }
+func (upperCommand) InputSchema() platform.Schema {
+	return platform.Schema{Type: "object", Properties: map[string]platform.SchemaField{}}
+}
+func (upperCommand) OutputSchema() platform.Schema {
+	return platform.Schema{Type: "object", Properties: map[string]platform.SchemaField{}}
+}
+func (upperCommand) Execute(_ gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	return agshctx.NewEnvelope(strings.ToUpper(input.PayloadString()), "text/plain", "text:upper"), nil
+}
+func (upperCommand) RequiredCredentials() []string { return nil }
+
+func newRuntime(t *testing.T, opts ...Option) *Runtime {
+	t.Helper()
+	rt, err := New(append([]Option{WithStorePath(filepath.Join(t.TempDir(), "context.db"))}, opts...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { rt.Close() })
+	return rt
+}
+
+func writeSpec(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report.agsh.yaml")
+	if err := os.WriteFile(path, []byte(reportSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRuntimeExecute(t *testing.T) {
+	rt := newRuntime(t, WithoutBuiltins())
+	if err := rt.RegisterCommand(upperCommand{}); err != nil {
+		t.Fatalf("RegisterCommand: %v", err)
+	}
+	out, err := rt.Execute(gocontext.Background(), "text:upper", agshctx.NewEnvelope("hi", "text/plain", "test"))
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if out.PayloadString() != "HI" {
+		t.Errorf("payload = %q, want HI", out.PayloadString())
+	}
+	if _, err := rt.Execute(gocontext.Background(), "fs:read", agshctx.NewEnvelope(nil, "", "test")); err == nil {
+		t.Error("fs:read ran without builtins")
+	}
+}
+
+func TestRuntimeRunSpec(t *testing.T) {
+	rt := newRuntime(t)
+	ch := rt.Subscribe(events.EventVerifyResult)
+	defer rt.Unsubscribe(ch)
+
+	out := t.TempDir()
+	result, err := rt.RunSpec(gocontext.Background(), writeSpec(t), map[string]string{"out": out, "text": "hello world"})
+	if err != nil {
+		t.Fatalf("RunSpec: %v", err)
+	}
+	if !result.Passed() || result.Verification == nil {
+		t.Errorf("result = %+v, want verified", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(out, "report.md")); string(data) != "hello world" {
+		t.Errorf("report.md = %q", data)
+	}
+	select {
+	case e := <-ch:
+		if e.Data.(map[string]any)["passed"] != true {
+			t.Errorf("verify.result = %v", e.Data)
+		}
+	default:
+		t.Error("no verify.result event")
+	}
+}
+
+func TestRuntimeRunSpecVerificationFailed(t *testing.T) {
+	rt := newRuntime(t)
+	result, err := rt.RunSpec(gocontext.Background(), writeSpec(t), map[string]string{"out": t.TempDir(), "text": "goodbye"})
+	if !errors.Is(err, agshctx.ErrVerificationFailed) {
+		t.Fatalf("err = %v, want ErrVerificationFailed", err)
+	}
+	if result.Passed() {
+		t.Error("result passed")
+	}
+}
+
+func TestRuntimeSandbox(t *testing.T) {
+	rt := newRuntime(t, WithSandbox(SandboxConfig{AllowedPaths: []string{t.TempDir()}}))
+	if _, err := rt.RunSpec(gocontext.Background(), writeSpec(t), map[string]string{"out": t.TempDir(), "text": "hello"}); err == nil {
+		t.Error("spec wrote outside the sandbox")
+	}
+}
+
+func TestRuntimeTemporaryStore(t *testing.T) {
+	rt, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := rt.Store().Set(agshctx.ScopeSession, "k", "v"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := rt.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package agsh
+
+import (
+	gocontext "context"
+	"fmt"
+	"time"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/platform"
+	"github.com/cgast/agsh/pkg/spec"
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+// Executor adapts a platform.Registry into a context.CommandExecutor.
+type Executor struct {
+	Registry *platform.Registry
+}
+
+func (e *Executor) Execute(ctx gocontext.Context, name string, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+	return e.Registry.Execute(ctx, name, input, store)
+}
+
+// CheckOutput implements agshctx.OutputChecker.
+func (e *Executor) CheckOutput(name string, output agshctx.Envelope) ([]string, error) {
+	problems, err := e.Registry.CheckOutput(name, output)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(problems))
+	for i, p := range problems {
+		out[i] = p.Problem
+		if p.Field != "" {
+			out[i] = p.Field + ": " + p.Problem
+		}
+	}
+	return out, nil
+}
+
+// Publisher adapts events.EventBus into a context.EventPublisher. With
+// Spec set, as for the pipeline of a used spec, the spec's name is added
+// to the event data so its events can be told from the parent's.
+type Publisher struct {
+	Bus  events.EventBus
+	Spec string
+}
+
+func (p *Publisher) PublishPipelineEvent(eventType string, data any, stepIndex int, duration time.Duration) {
+	if m, ok := data.(map[string]any); ok && p.Spec != "" {
+		m["spec"] = p.Spec
+	}
+	p.Bus.Publish(events.Event{
+		Type:      events.EventType(eventType),
+		Timestamp: time.Now(),
+		Data:      data,
+		StepIndex: stepIndex,
+		Duration:  duration,
+	})
+}
+
+// Lister adapts platform.Registry to spec.CommandLister.
+type Lister struct {
+	Registry *platform.Registry
+}
+
+func (l *Lister) Names() []string {
+	return l.Registry.Names()
+}
+
+// CommandRisk implements spec.RiskClassifier with the risk the command
+// gives its payload.
+func (l *Lister) CommandRisk(name string, payload any) string {
+	return l.Registry.CommandRisk(name, payload)
+}
+
+func (l *Lister) MatchGlob(pattern string) []string {
+	cmds := l.Registry.MatchGlob(pattern)
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	return names
+}
+
+// Checkpointer bridges verify.CheckpointManager + verify.CaptureSnapshot to
+// context.Checkpointer.
+type Checkpointer struct {
+	Manager verify.CheckpointManager
+	Store   agshctx.ContextStore
+	Workdir string
+}
+
+func (c *Checkpointer) SaveCheckpoint(name string) error {
+	snap, err := verify.CaptureSnapshot(c.Store, c.Workdir)
+	if err != nil {
+		return fmt.Errorf("capture snapshot: %w", err)
+	}
+	return c.Manager.Save(name, snap)
+}
+
+func (c *Checkpointer) RestoreCheckpoint(name string) error {
+	snap, err := c.Manager.Restore(name)
+	if err != nil {
+		return err
+	}
+	return verify.RestoreSnapshot(c.Store, snap)
+}
+
+// PipelineSteps converts plan steps to pipeline steps.
+func PipelineSteps(plan spec.ExecutionPlan) []agshctx.PipelineStep {
+	steps := make([]agshctx.PipelineStep, len(plan.Steps))
+	for i, step := range plan.Steps {
+		steps[i] = agshctx.PipelineStep{
+			ID:               step.ID,
+			Command:          step.Command,
+			Args:             step.Args,
+			Intent:           step.Intent,
+			Risk:             step.Risk,
+			OnError:          step.OnError,
+			CheckpointBefore: step.CheckpointBefore,
+			OnVerifyFailure:  step.OnVerifyFailure,
+			Params:           step.Params,
+			Needs:            step.Needs,
+			Inputs:           step.Inputs,
+			Uses:             step.Uses,
+			With:             step.With,
+			Ask:              planQuestion(step.Ask),
+
+			VerifyOutputSchema: step.VerifyOutputSchema,
+		}
+	}
+	return steps
+}
+
+// planQuestion converts the question of an ask plan step.
+func planQuestion(ask *spec.AskDef) *agshctx.Question {
+	if ask == nil {
+		return nil
+	}
+	return &agshctx.Question{Prompt: ask.Prompt, Choices: ask.Choices, Default: ask.Default, Key: ask.Key}
+}
+
+// PlanTimeout returns the deadline of a plan's run; zero means none.
+func PlanTimeout(plan spec.ExecutionPlan) time.Duration {
+	d, _ := time.ParseDuration(plan.Limits.MaxDuration) // validated with the spec or config
+	return d
+}
+
+// PlanHasWrites reports whether any plan step is classified as write or
+// destructive.
+func PlanHasWrites(plan spec.ExecutionPlan) bool {
+	for _, step := range plan.Steps {
+		if step.Risk != "read-only" {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputPaths returns the paths of the outputs out declares, by name, for
+// "artifact." success criteria.
+func OutputPaths(out spec.OutputSpec) map[string]string {
+	paths := make(map[string]string)
+	for _, a := range out.Declared() {
+		paths[a.Name] = a.Path
+	}
+	return paths
+}
+
+// PlanContext returns ctx confined to the sandbox and network a plan's
+// spec narrows its run to. Both add to the configured restrictions and
+// those of the specs the run is nested in, so they never widen them.
+func PlanContext(ctx gocontext.Context, plan spec.ExecutionPlan) (gocontext.Context, error) {
+	if !plan.Sandbox.IsZero() {
+		var err error
+		ctx, err = sandbox.WithRun(ctx, sandbox.Config{
+			AllowedPaths: plan.Sandbox.AllowedPaths,
+			DeniedPaths:  plan.Sandbox.DeniedPaths,
+			MaxFileSize:  plan.Sandbox.MaxFileSize,
+		})
+		if err != nil {
+			return ctx, fmt.Errorf("spec %s: %w", plan.Spec, err)
+		}
+	}
+	switch {
+	case plan.Limits.NoNetwork:
+		ctx = agshctx.WithAllowedDomains(ctx, nil)
+	case len(plan.Limits.AllowedDomains) > 0:
+		ctx = agshctx.WithAllowedDomains(ctx, plan.Limits.AllowedDomains)
+	}
+	return ctx, nil
+}
+
+// CriteriaIntent converts spec assertions to a verify.Intent.
+func CriteriaIntent(criteria []spec.Assertion) verify.Intent {
+	assertions := make([]verify.Assertion, len(criteria))
+	for i, c := range criteria {
+		assertions[i] = verify.Assertion{
+			Type:     c.Type,
+			Target:   c.Target,
+			Expected: c.Expected,
+			Message:  c.Message,
+		}
+	}
+	return verify.Intent{
+		Description: "success criteria",
+		Assertions:  assertions,
+	}
+}
+
+// VerifyCriteria checks a plan's success criteria against the result of
+// its run: its output, the files it wrote and its declared outputs.
+func VerifyCriteria(ctx gocontext.Context, plan spec.ExecutionPlan, result agshctx.PipelineResult, store agshctx.ContextStore) (verify.VerificationResult, error) {
+	engine := verify.NewEngine(
+		verify.WithContextStore(store),
+		verify.WithRunEffects(result.Effects()),
+		verify.WithArtifacts(OutputPaths(plan.Output)),
+	)
+	return engine.VerifyContext(ctx, result.Output, CriteriaIntent(plan.SuccessCriteria))
+}
+
+// CountPassed counts the number of passed assertion results.
+func CountPassed(results []verify.AssertionResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+// Rollback restores the first checkpoint saved during a run, undoing
+// every checkpointed step, and returns its name.
+func Rollback(cp agshctx.Checkpointer, result agshctx.PipelineResult) (string, error) {
+	if cp == nil {
+		return "", fmt.Errorf("no checkpoint manager configured")
+	}
+	for _, sr := range result.Steps {
+		if sr.CheckpointSaved != "" {
+			return sr.CheckpointSaved, cp.RestoreCheckpoint(sr.CheckpointSaved)
+		}
+	}
+	return "", fmt.Errorf("no checkpoint was saved during the run")
+}
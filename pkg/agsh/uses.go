@@ -0,0 +1,155 @@
+package agsh
+
+import (
+	gocontext "context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/events"
+	"github.com/cgast/agsh/pkg/platform"
+	"github.com/cgast/agsh/pkg/spec"
+	"github.com/cgast/agsh/pkg/verify"
+)
+
+// MaxUsesDepth bounds how deeply specs may use other specs.
+const MaxUsesDepth = 8
+
+// PlanSpec generates the plan of a validated spec loaded from specPath and
+// plans the specs its steps use, so their risk is known before approval.
+// The used specs are loaded with opts.
+func PlanSpec(projSpec spec.ProjectSpec, specPath string, registry *platform.Registry, opts ...spec.LoadOption) (spec.ExecutionPlan, error) {
+	plan, err := spec.GeneratePlan(projSpec, &Lister{Registry: registry})
+	if err != nil {
+		return plan, err
+	}
+	if err := resolveUses(&plan, registry, []string{absPath(specPath)}, opts...); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// resolveUses plans each spec a plan step uses. A step whose spec only
+// reads is marked read-only and needs no checkpoint. stack holds the specs
+// being planned, to reject cycles.
+func resolveUses(plan *spec.ExecutionPlan, registry *platform.Registry, stack []string, opts ...spec.LoadOption) error {
+	changed := false
+	for i, step := range plan.Steps {
+		if step.Uses == "" {
+			continue
+		}
+		path := absPath(step.Uses)
+		if slices.Contains(stack, path) {
+			return fmt.Errorf("step %d: %s uses itself", i+1, step.Uses)
+		}
+		if len(stack) > MaxUsesDepth {
+			return fmt.Errorf("step %d: specs nested more than %d deep", i+1, MaxUsesDepth)
+		}
+		sub, err := LoadUsedSpec(step.Uses, step.With, opts...)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		subPlan, err := spec.GeneratePlan(sub, &Lister{Registry: registry})
+		if err != nil {
+			return fmt.Errorf("step %d: %s: %w", i+1, step.Uses, err)
+		}
+		if err := resolveUses(&subPlan, registry, append(stack, path), opts...); err != nil {
+			return fmt.Errorf("%s: %w", step.Uses, err)
+		}
+		if !PlanHasWrites(subPlan) {
+			plan.Steps[i].Risk = "read-only"
+			plan.Steps[i].CheckpointBefore = false
+			changed = true
+		}
+	}
+	if changed {
+		plan.EstimatedRisk = spec.RiskSummary(plan.Steps) + spec.Confinement(plan.Limits, plan.Sandbox)
+	}
+	return nil
+}
+
+// LoadUsedSpec loads and validates a spec a step uses.
+func LoadUsedSpec(path string, params map[string]string, opts ...spec.LoadOption) (spec.ProjectSpec, error) {
+	sub, err := spec.LoadSpec(path, params, opts...)
+	if err != nil {
+		return sub, err
+	}
+	if vr := spec.ValidateSpec(sub); !vr.Valid() {
+		return sub, fmt.Errorf("%s: %s", path, vr.Error())
+	}
+	return sub, nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// SpecRunner implements agshctx.SpecRunner: it runs a used spec as a
+// nested pipeline sharing the parent's context store, then checks the
+// spec's success criteria against its output. Checkpoints and Asker may
+// be nil.
+type SpecRunner struct {
+	Registry    *platform.Registry
+	Store       agshctx.ContextStore
+	Bus         events.EventBus
+	Checkpoints verify.CheckpointManager
+	Asker       agshctx.Asker
+
+	depth int
+}
+
+func (r *SpecRunner) RunSpec(ctx gocontext.Context, path string, params map[string]string, input agshctx.Envelope) (agshctx.PipelineResult, error) {
+	if r.depth >= MaxUsesDepth {
+		return agshctx.PipelineResult{}, fmt.Errorf("specs nested more than %d deep", MaxUsesDepth)
+	}
+	sub, err := LoadUsedSpec(path, params)
+	if err != nil {
+		return agshctx.PipelineResult{}, err
+	}
+	plan, err := spec.GeneratePlan(sub, &Lister{Registry: r.Registry})
+	if err != nil {
+		return agshctx.PipelineResult{}, err
+	}
+
+	nested := *r
+	nested.depth++
+	pipeline := &agshctx.Pipeline{
+		Steps:       PipelineSteps(plan),
+		Context:     r.Store,
+		Executor:    &Executor{Registry: r.Registry},
+		Events:      &Publisher{Bus: r.Bus, Spec: plan.Spec},
+		Specs:       &nested,
+		Asker:       r.Asker,
+		Timeout:     PlanTimeout(plan),
+		OnTimeout:   plan.Limits.OnTimeout,
+		IntentDrift: plan.IntentDrift,
+	}
+	if r.Checkpoints != nil {
+		pipeline.Checkpointer = &Checkpointer{Manager: r.Checkpoints, Store: r.Store}
+	}
+
+	ctx, err = PlanContext(ctx, plan)
+	if err != nil {
+		return agshctx.PipelineResult{}, err
+	}
+	result, err := pipeline.Run(ctx, input)
+	if err != nil {
+		return result, err
+	}
+	if len(plan.SuccessCriteria) > 0 {
+		vr, err := VerifyCriteria(ctx, plan, result, r.Store)
+		if err != nil {
+			return result, fmt.Errorf("verification error: %w", err)
+		}
+		if !vr.Passed {
+			result.Success = false
+			return result, fmt.Errorf("%w: %s: %d/%d assertions passed", agshctx.ErrVerificationFailed,
+				plan.Spec, CountPassed(vr.Results), len(vr.Results))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,145 @@
+package context
+
+import (
+	"bytes"
+	gocontext "context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Artifact is auxiliary output of a step, such as a log, a raw API response
+// or an intermediate file. It is kept with the step result instead of being
+// passed to the next step.
+type Artifact struct {
+	Name        string // file name, e.g. "response.json"
+	ContentType string
+	Data        []byte
+}
+
+// ArtifactRef points at a stored artifact.
+type ArtifactRef struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int    `json:"size"`
+}
+
+// ArtifactStore persists the artifacts of pipeline steps.
+type ArtifactStore interface {
+	SaveArtifact(runID, stepID string, a Artifact) (ArtifactRef, error)
+}
+
+// DirArtifactStore stores artifacts as files under
+// Root/<run id>/artifacts/<step id>/.
+type DirArtifactStore struct {
+	Root string
+}
+
+// SaveArtifact writes a to disk, replacing an artifact of the same name.
+func (d DirArtifactStore) SaveArtifact(runID, stepID string, a Artifact) (ArtifactRef, error) {
+	name := safeName(a.Name)
+	dir := filepath.Join(d.Root, safeName(runID), "artifacts", safeName(stepID))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ArtifactRef{}, fmt.Errorf("artifact %s: %w", name, err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, a.Data, 0o644); err != nil {
+		return ArtifactRef{}, fmt.Errorf("artifact %s: %w", name, err)
+	}
+	return ArtifactRef{Name: name, Path: path, ContentType: a.ContentType, Size: len(a.Data)}, nil
+}
+
+// safeName turns s into a single path element.
+func safeName(s string) string {
+	s = strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(s)
+	if s == "" || s == "." || s == ".." {
+		return "artifact"
+	}
+	return s
+}
+
+// artifactCollector gathers what a step attaches while it runs.
+type artifactCollector struct {
+	mu        sync.Mutex
+	artifacts []Artifact
+	stdout    bytes.Buffer
+	stderr    bytes.Buffer
+}
+
+type artifactKey struct{}
+
+func withArtifactCollector(ctx gocontext.Context) (gocontext.Context, *artifactCollector) {
+	c := &artifactCollector{}
+	return gocontext.WithValue(ctx, artifactKey{}, c), c
+}
+
+func collectorFrom(ctx gocontext.Context) *artifactCollector {
+	c, _ := ctx.Value(artifactKey{}).(*artifactCollector)
+	return c
+}
+
+// Attach adds an artifact to the running pipeline step, replacing one
+// attached earlier under the same name. It is discarded outside a step, or
+// when the pipeline has no ArtifactStore.
+func Attach(ctx gocontext.Context, a Artifact) {
+	c := collectorFrom(ctx)
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.artifacts {
+		if c.artifacts[i].Name == a.Name {
+			c.artifacts[i] = a
+			return
+		}
+	}
+	c.artifacts = append(c.artifacts, a)
+}
+
+// Stdout returns a writer whose output becomes the running step's
+// stdout.log artifact. Outside a step it discards everything.
+func Stdout(ctx gocontext.Context) io.Writer {
+	if c := collectorFrom(ctx); c != nil {
+		return &collectorWriter{c: c, buf: &c.stdout}
+	}
+	return io.Discard
+}
+
+// Stderr is like Stdout for the step's stderr.log artifact.
+func Stderr(ctx gocontext.Context) io.Writer {
+	if c := collectorFrom(ctx); c != nil {
+		return &collectorWriter{c: c, buf: &c.stderr}
+	}
+	return io.Discard
+}
+
+type collectorWriter struct {
+	c   *artifactCollector
+	buf *bytes.Buffer
+}
+
+func (w *collectorWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+// collected returns the attached artifacts followed by the captured
+// output streams, if any were written.
+func (c *artifactCollector) collected() []Artifact {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := append([]Artifact(nil), c.artifacts...)
+	if c.stdout.Len() > 0 {
+		out = append(out, Artifact{Name: "stdout.log", ContentType: "text/plain", Data: bytes.Clone(c.stdout.Bytes())})
+	}
+	if c.stderr.Len() > 0 {
+		out = append(out, Artifact{Name: "stderr.log", ContentType: "text/plain", Data: bytes.Clone(c.stderr.Bytes())})
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+package context
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// Content types the payload accessors understand.
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeText = "text/plain"
+	ContentTypeCSV  = "text/csv"
+)
+
+// mediaType returns the content type without parameters, in lower case.
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+func isJSONType(contentType string) bool {
+	mt := mediaType(contentType)
+	return mt == ContentTypeJSON || strings.HasSuffix(mt, "+json")
+}
+
+// payloadText returns a string or byte payload as text.
+func payloadText(payload any) (string, bool) {
+	switch v := payload.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case json.RawMessage:
+		return string(v), true
+	}
+	return "", false
+}
+
+// AsText returns the payload as text. Strings and bytes are returned as
+// they are, whatever the content type, so JSON text is not encoded again;
+// other payloads are rendered as JSON.
+func (e *Envelope) AsText() (string, error) {
+	if s, ok := payloadText(e.Payload); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(e.Payload)
+	if err != nil {
+		return "", fmt.Errorf("render %T payload as text: %w", e.Payload, err)
+	}
+	return string(data), nil
+}
+
+// AsJSON returns the payload as a JSON value: maps, slices, strings,
+// float64 numbers, booleans or nil. Text declared as JSON is decoded, as is
+// text that holds a JSON object or array; CSV text becomes an array of row
+// objects; other text is a JSON string.
+func (e *Envelope) AsJSON() (any, error) {
+	s, isText := payloadText(e.Payload)
+	if !isText {
+		data, err := json.Marshal(e.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("%T payload is not JSON: %w", e.Payload, err)
+		}
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	switch {
+	case isJSONType(e.Meta.ContentType):
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, fmt.Errorf("payload is declared %s but is not valid JSON: %w", e.Meta.ContentType, err)
+		}
+		return v, nil
+	case mediaType(e.Meta.ContentType) == ContentTypeCSV:
+		rows, err := parseCSV(s)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, len(rows))
+		for i, row := range rows {
+			out[i] = row
+		}
+		return out, nil
+	}
+	if t := strings.TrimSpace(s); strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[") {
+		var v any
+		if json.Unmarshal([]byte(t), &v) == nil {
+			return v, nil
+		}
+	}
+	return s, nil
+}
+
+// AsTable returns the payload as rows: an array of objects, an object with
+// exactly one field holding such an array (as list commands return), or
+// CSV text with a header row.
+func (e *Envelope) AsTable() ([]map[string]any, error) {
+	v, err := e.AsJSON()
+	if err != nil {
+		return nil, err
+	}
+	if obj, ok := v.(map[string]any); ok {
+		var arrays []any
+		for _, field := range obj {
+			if arr, ok := field.([]any); ok {
+				arrays = append(arrays, arr)
+			}
+		}
+		if len(arrays) == 1 {
+			v = arrays[0]
+		}
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s payload is not a table: expected an array of objects or CSV, got %s", e.describe(), jsonType(v))
+	}
+	rows := make([]map[string]any, len(arr))
+	for i, item := range arr {
+		row, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s payload is not a table: row %d is %s, not an object", e.describe(), i, jsonType(item))
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// ConvertTo returns the envelope with its payload converted to the JSON
+// type typ ("object", "array" or "string") that a command's input schema
+// expects: JSON or CSV text is decoded, and structured payloads are
+// rendered as JSON text. Payloads that already fit, and plain text given
+// where an object is expected (commands take text as their main input),
+// are returned unchanged; a payload that cannot be converted is an error.
+func (e Envelope) ConvertTo(typ string) (Envelope, error) {
+	_, isText := payloadText(e.Payload)
+	switch typ {
+	case "object", "array":
+		if !isText || e.Payload == nil {
+			return e, nil
+		}
+		if !isJSONType(e.Meta.ContentType) && mediaType(e.Meta.ContentType) != ContentTypeCSV {
+			if typ == "object" {
+				return e, nil
+			}
+			return e, fmt.Errorf("expected an array, got %s text", e.describe())
+		}
+		v, err := e.AsJSON()
+		if err != nil {
+			return e, err
+		}
+		if got := jsonType(v); got != typ {
+			return e, fmt.Errorf("expected an %s, got a JSON %s", typ, got)
+		}
+		e.Payload, e.Meta.ContentType = v, ContentTypeJSON
+	case "string":
+		if isText || e.Payload == nil {
+			return e, nil
+		}
+		s, err := e.AsText()
+		if err != nil {
+			return e, err
+		}
+		e.Payload, e.Meta.ContentType = s, ContentTypeJSON
+	}
+	return e, nil
+}
+
+func (e *Envelope) describe() string {
+	if e.Meta.ContentType != "" {
+		return e.Meta.ContentType
+	}
+	return fmt.Sprintf("%T", e.Payload)
+}
+
+// parseCSV reads CSV text with a header row into row objects.
+func parseCSV(text string) ([]map[string]any, error) {
+	records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("payload is declared text/csv but is not valid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]map[string]any, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			if i < len(rec) {
+				row[name] = rec[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
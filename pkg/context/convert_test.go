@@ -0,0 +1,69 @@
+package context
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPayloadAccessors(t *testing.T) {
+	jsonText := NewEnvelope(`{"items": [{"n": 1}, {"n": 2}]}`, "application/json; charset=utf-8", "http:get")
+	if s, _ := jsonText.AsText(); s != `{"items": [{"n": 1}, {"n": 2}]}` {
+		t.Errorf("AsText of JSON text = %q", s)
+	}
+	v, err := jsonText.AsJSON()
+	if err != nil {
+		t.Fatalf("AsJSON: %v", err)
+	}
+	if m, ok := v.(map[string]any); !ok || len(m["items"].([]any)) != 2 {
+		t.Errorf("AsJSON = %#v", v)
+	}
+	rows, err := jsonText.AsTable()
+	if err != nil || len(rows) != 2 || rows[1]["n"] != float64(2) {
+		t.Errorf("AsTable of a list result = %v, %v", rows, err)
+	}
+
+	csv := NewEnvelope("name,role\nada,dev\nbob,ops\n", "text/csv", "fs:read")
+	rows, err = csv.AsTable()
+	want := []map[string]any{{"name": "ada", "role": "dev"}, {"name": "bob", "role": "ops"}}
+	if err != nil || !reflect.DeepEqual(rows, want) {
+		t.Errorf("AsTable of CSV = %v, %v", rows, err)
+	}
+
+	raw := NewEnvelope(json.RawMessage(`[1,2]`), "application/json", "agent")
+	if s, _ := raw.AsText(); s != "[1,2]" {
+		t.Errorf("AsText of raw JSON = %q", s)
+	}
+
+	text := NewEnvelope("hello", "text/plain", "repl")
+	if v, err := text.AsJSON(); err != nil || v != "hello" {
+		t.Errorf("AsJSON of text = %v, %v", v, err)
+	}
+	if _, err := text.AsTable(); err == nil || !strings.Contains(err.Error(), "not a table") {
+		t.Errorf("AsTable of text: %v", err)
+	}
+	bad := NewEnvelope("{oops", "application/json", "http:get")
+	if _, err := bad.AsJSON(); err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Errorf("AsJSON of invalid JSON: %v", err)
+	}
+}
+
+func TestConvertTo(t *testing.T) {
+	obj := NewEnvelope(map[string]any{"a": 1}, "application/json", "x")
+	got, err := obj.ConvertTo("string")
+	if err != nil || got.Payload != `{"a":1}` {
+		t.Errorf("object to string = %#v, %v", got.Payload, err)
+	}
+	csv := NewEnvelope("a\n1\n", "text/csv", "x")
+	got, err = csv.ConvertTo("array")
+	if err != nil || !reflect.DeepEqual(got.Payload, []any{map[string]any{"a": "1"}}) || got.Meta.ContentType != ContentTypeJSON {
+		t.Errorf("CSV to array = %#v, %v", got, err)
+	}
+	if _, err := NewEnvelope("plain", "text/plain", "x").ConvertTo("array"); err == nil {
+		t.Error("plain text converted to an array")
+	}
+	if _, err := NewEnvelope(`"s"`, "application/json", "x").ConvertTo("object"); err == nil || !strings.Contains(err.Error(), "got a JSON string") {
+		t.Errorf("JSON string to object: %v", err)
+	}
+}
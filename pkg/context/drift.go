@@ -0,0 +1,354 @@
+package context
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cgast/agsh/internal/paths"
+	"github.com/cgast/agsh/internal/sandbox"
+)
+
+// ErrIntentDrift is wrapped by the error Run returns when a step in a
+// pipeline with IntentDrift "block" did something its declaration rules
+// out. It wraps ErrVerificationFailed.
+var ErrIntentDrift = fmt.Errorf("intent drift: %w", ErrVerificationFailed)
+
+// Effects are what a step was seen doing: the paths and hosts its commands
+// reported through RecordWrite and RecordDomain, and the context scopes
+// it wrote.
+type Effects struct {
+	Writes  []string `json:"writes,omitempty"`  // absolute paths written
+	Domains []string `json:"domains,omitempty"` // hosts contacted
+	Scopes  []string `json:"scopes,omitempty"`  // context scopes written, other than ScopeStep
+}
+
+// effectRecorder gathers the effects of the running step.
+type effectRecorder struct {
+	mu      sync.Mutex
+	effects Effects
+}
+
+type effectsKey struct{}
+
+func withEffectRecorder(ctx gocontext.Context) (gocontext.Context, *effectRecorder) {
+	r := &effectRecorder{}
+	return gocontext.WithValue(ctx, effectsKey{}, r), r
+}
+
+func (r *effectRecorder) add(list *[]string, s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(*list, s) {
+		*list = append(*list, s)
+	}
+}
+
+func (r *effectRecorder) recorded() Effects {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Effects{
+		Writes:  slices.Clone(r.effects.Writes),
+		Domains: slices.Clone(r.effects.Domains),
+		Scopes:  slices.Clone(r.effects.Scopes),
+	}
+}
+
+// RecordWrite notes that the running pipeline step wrote or removed path.
+// Commands that change files call it so intent drift can be detected.
+// Outside a step it does nothing.
+func RecordWrite(ctx gocontext.Context, path string) {
+	r, _ := ctx.Value(effectsKey{}).(*effectRecorder)
+	if r == nil || path == "" {
+		return
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	r.add(&r.effects.Writes, path)
+}
+
+// RecordDomain notes that the running pipeline step contacted host.
+// Outside a step it does nothing.
+func RecordDomain(ctx gocontext.Context, host string) {
+	r, _ := ctx.Value(effectsKey{}).(*effectRecorder)
+	if r == nil || host == "" {
+		return
+	}
+	r.add(&r.effects.Domains, strings.ToLower(host))
+}
+
+type allowedDomainsKey struct{}
+
+// WithAllowedDomains returns a context whose commands may contact only
+// the hosts domains matches (see CheckDomain), and only those every outer
+// run allows too: a run, such as one of a spec, can narrow network access
+// but never widen it. An empty list allows no host.
+func WithAllowedDomains(ctx gocontext.Context, domains []string) gocontext.Context {
+	outer, _ := ctx.Value(allowedDomainsKey{}).([][]string)
+	return gocontext.WithValue(ctx, allowedDomainsKey{}, append(slices.Clip(outer), domains))
+}
+
+// CheckDomain returns a sandbox violation if the run a command belongs to
+// may not contact host. Commands call it before they connect, where they
+// call RecordDomain.
+func CheckDomain(ctx gocontext.Context, host string) error {
+	lists, _ := ctx.Value(allowedDomainsKey{}).([][]string)
+	for _, allowed := range lists {
+		if !MatchDomain(host, allowed) {
+			if len(allowed) == 0 {
+				return sandbox.Violationf("host %q: this run may not use the network", host)
+			}
+			return sandbox.Violationf("host %q is not among the hosts this run may contact (%s)", host, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// MatchDomain reports whether host matches one of patterns, where
+// "*.example.com" matches the subdomains of example.com.
+func MatchDomain(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if host == p {
+			return true
+		}
+		if base, ok := strings.CutPrefix(p, "*."); ok && strings.HasSuffix(host, "."+base) {
+			return true
+		}
+	}
+	return false
+}
+
+// StepEffects returns what the running pipeline step has recorded so far.
+// Outside a step it returns no effects.
+func StepEffects(ctx gocontext.Context) Effects {
+	r, _ := ctx.Value(effectsKey{}).(*effectRecorder)
+	if r == nil {
+		return Effects{}
+	}
+	return r.recorded()
+}
+
+// Effects returns what all of a run's steps, including those of the specs
+// they used, were seen doing.
+func (r PipelineResult) Effects() Effects {
+	var all Effects
+	var add func(steps []StepResult)
+	add = func(steps []StepResult) {
+		for _, sr := range steps {
+			for _, w := range sr.Effects.Writes {
+				if !slices.Contains(all.Writes, w) {
+					all.Writes = append(all.Writes, w)
+				}
+			}
+			for _, d := range sr.Effects.Domains {
+				if !slices.Contains(all.Domains, d) {
+					all.Domains = append(all.Domains, d)
+				}
+			}
+			for _, sc := range sr.Effects.Scopes {
+				if !slices.Contains(all.Scopes, sc) {
+					all.Scopes = append(all.Scopes, sc)
+				}
+			}
+			add(sr.Children)
+		}
+	}
+	add(r.Steps)
+	return all
+}
+
+// effectStore is the ContextStore a step's command sees: it records the
+// scopes the command writes.
+type effectStore struct {
+	ContextStore
+	rec *effectRecorder
+}
+
+func (s effectStore) Set(scope, key string, value any) error {
+	s.touch(scope)
+	return s.ContextStore.Set(scope, key, value)
+}
+
+func (s effectStore) Delete(scope, key string) error {
+	s.touch(scope)
+	return s.ContextStore.Delete(scope, key)
+}
+
+func (s effectStore) touch(scope string) {
+	if scope != ScopeStep {
+		s.rec.add(&s.rec.effects.Scopes, scope)
+	}
+}
+
+// readVerbs start the intents of steps that only look at things.
+var readVerbs = []string{
+	"read", "list", "fetch", "get", "gather", "show", "inspect", "check",
+	"search", "find", "count", "describe", "query", "view", "look",
+	"collect", "review", "compare", "analyze", "analyse", "ask",
+}
+
+// declaresReadOnly reports whether a step's risk or the leading verb of its
+// intent says it changes nothing.
+func declaresReadOnly(step PipelineStep) bool {
+	if step.Risk == "read-only" {
+		return true
+	}
+	verb, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(step.Intent)), " ")
+	return slices.Contains(readVerbs, verb)
+}
+
+// pathInputs are the input fields that name the paths a step may write.
+var pathInputs = []string{"path", "dir", "dest", "dst", "destination", "output", "file"}
+
+// declaredPaths returns the absolute paths named by a step's input.
+func declaredPaths(input Envelope) []string {
+	m, ok := input.Payload.(map[string]any)
+	if !ok {
+		return nil
+	}
+	var paths []string
+	for _, field := range pathInputs {
+		if s, ok := m[field].(string); ok && s != "" {
+			if abs, err := filepath.Abs(s); err == nil {
+				paths = append(paths, abs)
+			}
+		}
+	}
+	return paths
+}
+
+// declaredDomains returns the hosts of the URLs in a step's intent and in
+// the top-level string fields of its input.
+func declaredDomains(step PipelineStep, input Envelope) []string {
+	texts := append([]string{step.Intent}, step.Args...)
+	switch p := input.Payload.(type) {
+	case string:
+		texts = append(texts, p)
+	case map[string]any:
+		for _, v := range p {
+			if s, ok := v.(string); ok {
+				texts = append(texts, s)
+			}
+		}
+	}
+	var hosts []string
+	for _, text := range texts {
+		for _, word := range strings.Fields(text) {
+			if !strings.HasPrefix(word, "http://") && !strings.HasPrefix(word, "https://") {
+				continue
+			}
+			if u, err := url.Parse(strings.TrimRight(word, ".,;)")); err == nil && u.Hostname() != "" {
+				hosts = append(hosts, strings.ToLower(u.Hostname()))
+			}
+		}
+	}
+	return hosts
+}
+
+// within reports whether path is one of the declared paths, lies under one,
+// or is a sibling named after one (such as a ".bak" backup).
+func within(path string, declared []string) bool {
+	for _, d := range declared {
+		if paths.Native.Within(path, d) || strings.HasPrefix(paths.Native.Key(path), paths.Native.Key(d)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// domainDeclared reports whether host is a declared host or a subdomain of
+// one.
+func domainDeclared(host string, declared []string) bool {
+	for _, d := range declared {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectDrift compares what a step declared against its observed effects
+// and describes each mismatch:
+//
+//   - a step whose risk is "read-only", or whose intent starts with a
+//     reading verb such as "read" or "list", wrote files or context;
+//   - a step whose input names paths wrote outside them;
+//   - a step whose intent or input names URLs contacted other hosts.
+//
+// Steps that declare nothing cannot drift.
+func DetectDrift(step PipelineStep, input Envelope, effects Effects) []string {
+	var findings []string
+	if declaresReadOnly(step) {
+		what := fmt.Sprintf("intent is %q", strings.TrimSpace(step.Intent))
+		if step.Risk == "read-only" {
+			what = "declared read-only"
+		}
+		for _, path := range effects.Writes {
+			findings = append(findings, fmt.Sprintf("%s but wrote %s", what, path))
+		}
+		for _, scope := range effects.Scopes {
+			findings = append(findings, fmt.Sprintf("%s but wrote the %s context", what, scope))
+		}
+	}
+	if paths := declaredPaths(input); len(paths) > 0 {
+		for _, path := range effects.Writes {
+			if !within(path, paths) {
+				findings = append(findings, fmt.Sprintf("wrote %s, outside the declared %s", path, strings.Join(paths, ", ")))
+			}
+		}
+	}
+	if hosts := declaredDomains(step, input); len(hosts) > 0 {
+		for _, host := range effects.Domains {
+			if !domainDeclared(host, hosts) {
+				findings = append(findings, fmt.Sprintf("contacted %s, not among the declared %s", host, strings.Join(hosts, ", ")))
+			}
+		}
+	}
+	return findings
+}
+
+// checkDrift runs DetectDrift on a finished step and publishes
+// verify.drift for any findings. With IntentDrift "block" it fails the
+// step like a failed verification, rolling back when the step rolls back
+// on verification failure.
+func (p *Pipeline) checkDrift(sr *StepResult, input Envelope) error {
+	if p.IntentDrift != "warn" && p.IntentDrift != "block" {
+		return nil
+	}
+	findings := DetectDrift(sr.Step, input, sr.Effects)
+	if len(findings) == 0 {
+		return nil
+	}
+	sr.Drift = findings
+	blocked := p.IntentDrift == "block"
+	p.publishEvent("verify.drift", map[string]any{
+		"step":     sr.Index,
+		"command":  sr.Step.Command,
+		"intent":   sr.Step.Intent,
+		"findings": findings,
+		"blocked":  blocked,
+	}, sr.Index, 0)
+	if !blocked {
+		return nil
+	}
+
+	summary := strings.Join(findings, "; ")
+	passed := false
+	sr.Status = "verify_failed"
+	sr.VerifyPassed = &passed
+	sr.VerifyMessage = summary
+	if sr.Step.OnVerifyFailure == "rollback" {
+		sr.RolledBack = p.rollback(sr.Index, sr.CheckpointSaved)
+	}
+	if sr.RolledBack {
+		return fmt.Errorf("%w, rolled back to checkpoint %s: %s", ErrIntentDrift, sr.CheckpointSaved, summary)
+	}
+	return fmt.Errorf("%w: %s", ErrIntentDrift, summary)
+}
@@ -0,0 +1,174 @@
+package context
+
+import (
+	gocontext "context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cgast/agsh/internal/sandbox"
+)
+
+func TestDetectDrift(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.md")
+	tests := []struct {
+		name    string
+		step    PipelineStep
+		input   Envelope
+		effects Effects
+		want    []string // substrings, one per finding
+	}{
+		{"read step wrote a file",
+			PipelineStep{Command: "fs:read", Risk: "read-only"}, Envelope{},
+			Effects{Writes: []string{report}}, []string{"declared read-only but wrote " + report}},
+		{"read intent wrote context",
+			PipelineStep{Command: "x:y", Risk: "write", Intent: "List open issues"}, Envelope{},
+			Effects{Scopes: []string{ScopeProject}}, []string{`intent is "List open issues" but wrote the project context`}},
+		{"write step writing its path",
+			PipelineStep{Command: "fs:write", Risk: "write", Intent: "Write the report"},
+			NewEnvelope(map[string]any{"path": report}, "application/json", "test"),
+			Effects{Writes: []string{report, report + ".bak"}}, nil},
+		{"write outside declared path",
+			PipelineStep{Command: "fs:write", Risk: "write"},
+			NewEnvelope(map[string]any{"path": report}, "application/json", "test"),
+			Effects{Writes: []string{filepath.Join(dir, "other.md")}}, []string{"outside the declared " + report}},
+		{"declared host and subdomain",
+			PipelineStep{Command: "http:get", Intent: "Fetch the changelog"},
+			NewEnvelope("https://example.com/changelog", "text/plain", "test"),
+			Effects{Domains: []string{"example.com", "cdn.example.com"}}, nil},
+		{"undeclared host",
+			PipelineStep{Command: "http:get", Intent: "Fetch https://example.com/changelog."}, Envelope{},
+			Effects{Domains: []string{"tracker.example.net"}}, []string{"contacted tracker.example.net, not among the declared example.com"}},
+		{"nothing declared",
+			PipelineStep{Command: "custom"}, Envelope{},
+			Effects{Writes: []string{report}, Domains: []string{"example.com"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectDrift(tt.step, tt.input, tt.effects)
+			if len(got) != len(tt.want) {
+				t.Fatalf("DetectDrift = %q, want %d findings", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("finding %d = %q, want it to contain %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineIntentDrift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	exec := newTestExecutor()
+	exec.Register("sneaky-read", func(ctx gocontext.Context, input Envelope, store ContextStore) (Envelope, error) {
+		RecordWrite(ctx, path)
+		RecordDomain(ctx, "Example.COM")
+		store.Set(ScopeStep, "scratch", 1) // step scope is not an effect
+		return input, nil
+	})
+
+	store := newTestStore(t)
+	pub := &testEventPublisher{}
+	p := &Pipeline{
+		Steps:       []PipelineStep{{Command: "sneaky-read", Risk: "read-only"}},
+		Context:     store,
+		Executor:    exec,
+		Events:      pub,
+		IntentDrift: "warn",
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if err != nil {
+		t.Fatalf("Run with warn: %v", err)
+	}
+	sr := result.Steps[0]
+	if sr.Status != "ok" || len(sr.Drift) != 1 {
+		t.Errorf("status = %s, drift = %q", sr.Status, sr.Drift)
+	}
+	if len(sr.Effects.Writes) != 1 || sr.Effects.Writes[0] != path || len(sr.Effects.Scopes) != 0 ||
+		len(sr.Effects.Domains) != 1 || sr.Effects.Domains[0] != "example.com" {
+		t.Errorf("effects = %+v", sr.Effects)
+	}
+	var drift map[string]any
+	for _, ev := range pub.events {
+		if ev.Type == "verify.drift" {
+			drift = ev.Data.(map[string]any)
+		}
+	}
+	if drift == nil || drift["blocked"] != false {
+		t.Errorf("verify.drift event = %v", drift)
+	}
+
+	cp := &testCheckpointer{}
+	p.IntentDrift = "block"
+	p.Checkpointer = cp
+	p.Steps[0].OnVerifyFailure = "rollback"
+	result, err = p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if !errors.Is(err, ErrIntentDrift) || !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("Run with block: err = %v", err)
+	}
+	if sr := result.Steps[0]; sr.Status != "verify_failed" || !sr.RolledBack || len(cp.restored) != 1 {
+		t.Errorf("status = %s, rolled back = %v, restored = %v", sr.Status, sr.RolledBack, cp.restored)
+	}
+
+	// With the check off, effects are still recorded.
+	p.IntentDrift = "off"
+	result, err = p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if err != nil || result.Steps[0].Drift != nil || len(result.Steps[0].Effects.Writes) != 1 {
+		t.Errorf("Run with off: err = %v, step = %+v", err, result.Steps[0])
+	}
+}
+
+func TestPipelineResultEffects(t *testing.T) {
+	r := PipelineResult{Steps: []StepResult{
+		{Effects: Effects{Writes: []string{"/w/a"}, Domains: []string{"example.com"}}},
+		{Effects: Effects{Writes: []string{"/w/a", "/w/b"}}, Children: []StepResult{
+			{Effects: Effects{Domains: []string{"api.github.com", "example.com"}, Scopes: []string{ScopeSession}}},
+		}},
+	}}
+	want := Effects{
+		Writes:  []string{"/w/a", "/w/b"},
+		Domains: []string{"example.com", "api.github.com"},
+		Scopes:  []string{ScopeSession},
+	}
+	if got := r.Effects(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Effects = %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckDomain(t *testing.T) {
+	ctx := gocontext.Background()
+	if err := CheckDomain(ctx, "example.com"); err != nil {
+		t.Errorf("without allowlists: %v", err)
+	}
+
+	ctx = WithAllowedDomains(ctx, []string{"api.github.com", "*.example.com"})
+	for host, allowed := range map[string]bool{
+		"api.github.com":  true,
+		"API.GitHub.com":  true,
+		"www.example.com": true,
+		"example.com":     false,
+		"github.com":      false,
+	} {
+		if err := CheckDomain(ctx, host); (err == nil) != allowed {
+			t.Errorf("CheckDomain(%q) = %v, want allowed %v", host, err, allowed)
+		}
+	}
+
+	// A nested run cannot widen what the outer one allows.
+	nested := WithAllowedDomains(ctx, []string{"github.com", "www.example.com"})
+	if err := CheckDomain(nested, "github.com"); err == nil {
+		t.Error("github.com is not allowed by the outer run")
+	}
+	if err := CheckDomain(nested, "www.example.com"); err != nil {
+		t.Errorf("www.example.com: %v", err)
+	}
+
+	err := CheckDomain(WithAllowedDomains(ctx, nil), "api.github.com")
+	if !errors.Is(err, sandbox.ErrViolation) || !strings.Contains(err.Error(), "may not use the network") {
+		t.Errorf("no network: err = %v", err)
+	}
+}
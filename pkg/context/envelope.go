@@ -20,6 +20,13 @@ type Metadata struct {
 	Tags        map[string]string `json:"tags"`
 	CreatedAt   time.Time         `json:"created_at"`
 	Source      string            `json:"source"`
+
+	// Taint lists the untrusted sources the payload derives from, such as
+	// "llm:complete" or "http:get example.net". Empty means trusted.
+	Taint []string `json:"taint,omitempty"`
+	// TaintedFields narrows a taint to these top-level payload fields;
+	// when empty, a tainted payload is tainted throughout.
+	TaintedFields []string `json:"tainted_fields,omitempty"`
 }
 
 // Step records a single operation in the provenance chain.
@@ -29,6 +36,10 @@ type Step struct {
 	Timestamp time.Time     `json:"timestamp"`
 	Duration  time.Duration `json:"duration"`
 	Status    string        `json:"status"` // "ok", "error", "skipped"
+
+	// SchemaCheck is set when the step's output was checked against its
+	// command's output schema.
+	SchemaCheck *SchemaCheck `json:"schema_check,omitempty"`
 }
 
 // NewEnvelope creates a new Envelope with the given payload, content type, and source.
@@ -63,18 +74,8 @@ func (e Envelope) MarshalJSON() ([]byte, error) {
 }
 
 // PayloadString returns the payload as a string if possible.
-// Returns the JSON representation for non-string payloads.
+// Returns the JSON representation for non-string payloads; see AsText.
 func (e *Envelope) PayloadString() string {
-	switch v := e.Payload.(type) {
-	case string:
-		return v
-	case []byte:
-		return string(v)
-	default:
-		b, err := json.Marshal(v)
-		if err != nil {
-			return ""
-		}
-		return string(b)
-	}
+	s, _ := e.AsText()
+	return s
 }
@@ -0,0 +1,149 @@
+package context
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ExportVersion is the format version written by ExportStore.
+const ExportVersion = 1
+
+// Scopes lists every context scope.
+var Scopes = []string{ScopeProject, ScopeSession, ScopeStep, ScopeHistory}
+
+// ParseContextRef splits a reference to a stored value, such as the
+// assertion target "context.session.total_headings", into its scope and
+// key. Keys may contain dots.
+func ParseContextRef(ref string) (scope, key string, err error) {
+	rest, ok := strings.CutPrefix(ref, "context.")
+	scope, key, _ = strings.Cut(rest, ".")
+	if !ok || key == "" || !slices.Contains(Scopes, scope) {
+		return "", "", fmt.Errorf("invalid context reference %q (expected context.<scope>.<key>, scope one of %s)", ref, strings.Join(Scopes, ", "))
+	}
+	return scope, key, nil
+}
+
+// Export is a portable copy of context store entries, as written by
+// `agsh context export` and read back by `agsh context import`.
+type Export struct {
+	Version    int                       `json:"version"`
+	ExportedAt time.Time                 `json:"exported_at"`
+	Scopes     map[string]map[string]any `json:"scopes"`
+}
+
+// ExportFilter selects the entries to export or import. The zero value
+// selects every key in every scope.
+type ExportFilter struct {
+	Scopes  []string `json:"scopes,omitempty"`  // empty = all
+	Include []string `json:"include,omitempty"` // key patterns (path.Match); empty = all
+	Exclude []string `json:"exclude,omitempty"` // key patterns to skip
+}
+
+func (f ExportFilter) scopes() ([]string, error) {
+	for _, scope := range f.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q (expected one of %v)", scope, Scopes)
+		}
+	}
+	if len(f.Scopes) == 0 {
+		return Scopes, nil
+	}
+	return f.Scopes, nil
+}
+
+func (f ExportFilter) covers(key string) bool {
+	if len(f.Include) > 0 && !matchKey(f.Include, key) {
+		return false
+	}
+	return !matchKey(f.Exclude, key)
+}
+
+func matchKey(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportStore copies the entries of store that f selects.
+func ExportStore(store ContextStore, f ExportFilter) (Export, error) {
+	scopes, err := f.scopes()
+	if err != nil {
+		return Export{}, err
+	}
+	e := Export{Version: ExportVersion, ExportedAt: time.Now().UTC(), Scopes: make(map[string]map[string]any)}
+	for _, scope := range scopes {
+		items, err := store.List(scope)
+		if err != nil {
+			return Export{}, fmt.Errorf("list scope %s: %w", scope, err)
+		}
+		for key := range items {
+			if !f.covers(key) {
+				delete(items, key)
+			}
+		}
+		if len(items) > 0 {
+			e.Scopes[scope] = items
+		}
+	}
+	return e, nil
+}
+
+// ImportStore writes the entries of e that f selects into store and
+// returns how many it wrote. Existing keys are overwritten; with replace,
+// the selected keys of each selected scope that e lacks are deleted too,
+// so the selection ends up exactly as exported. The history scope is
+// append-only: its entries are added when missing and never replaced.
+func ImportStore(store ContextStore, e Export, f ExportFilter, replace bool) (int, error) {
+	if e.Version > ExportVersion {
+		return 0, fmt.Errorf("export version %d is newer than this agsh supports (%d)", e.Version, ExportVersion)
+	}
+	for scope := range e.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return 0, fmt.Errorf("export has unknown scope %q", scope)
+		}
+	}
+	scopes, err := f.scopes()
+	if err != nil {
+		return 0, err
+	}
+	store = WithSource(store, "context.import")
+
+	n := 0
+	for _, scope := range scopes {
+		items := e.Scopes[scope]
+		if replace && scope != ScopeHistory {
+			current, err := store.List(scope)
+			if err != nil {
+				return n, fmt.Errorf("list scope %s: %w", scope, err)
+			}
+			for key := range current {
+				if _, ok := items[key]; !ok && f.covers(key) {
+					if err := store.Delete(scope, key); err != nil {
+						return n, fmt.Errorf("delete %s/%s: %w", scope, key, err)
+					}
+				}
+			}
+		}
+		for key, val := range items {
+			if !f.covers(key) {
+				continue
+			}
+			if scope == ScopeHistory {
+				if _, err := store.Get(scope, key); err == nil {
+					continue
+				}
+			}
+			if err := store.Set(scope, key, val); err != nil {
+				return n, fmt.Errorf("set %s/%s: %w", scope, key, err)
+			}
+			n++
+		}
+	}
+	return n, nil
+}
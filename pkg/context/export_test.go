@@ -0,0 +1,67 @@
+package context
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	src := newTestStore(t)
+	src.Set(ScopeProject, "goal", "ship")
+	src.Set(ScopeSession, "report.title", "weekly")
+	src.Set(ScopeSession, "report.draft", map[string]any{"lines": float64(3)})
+	src.Set(ScopeSession, "token", "secret")
+
+	export, err := ExportStore(src, ExportFilter{Scopes: []string{ScopeSession}, Exclude: []string{"token"}})
+	if err != nil {
+		t.Fatalf("ExportStore: %v", err)
+	}
+	want := map[string]map[string]any{ScopeSession: {
+		"report.title": "weekly",
+		"report.draft": map[string]any{"lines": float64(3)},
+	}}
+	if export.Version != ExportVersion || !reflect.DeepEqual(export.Scopes, want) {
+		t.Fatalf("export = %+v", export)
+	}
+
+	// Exports survive a round trip through JSON.
+	data, _ := json.Marshal(export)
+	var decoded Export
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestStore(t)
+	dst.Set(ScopeSession, "report.old", "stale")
+	dst.Set(ScopeSession, "keep", "me")
+	n, err := ImportStore(dst, decoded, ExportFilter{Include: []string{"report.*"}}, true)
+	if err != nil || n != 2 {
+		t.Fatalf("ImportStore = %d, %v", n, err)
+	}
+	got, _ := dst.List(ScopeSession)
+	want[ScopeSession]["keep"] = "me"
+	if !reflect.DeepEqual(got, want[ScopeSession]) {
+		t.Errorf("session after import = %v", got)
+	}
+
+	if _, err := ExportStore(src, ExportFilter{Scopes: []string{"global"}}); err == nil || !strings.Contains(err.Error(), "unknown scope") {
+		t.Errorf("unknown scope: %v", err)
+	}
+	if _, err := ImportStore(dst, Export{Version: ExportVersion + 1}, ExportFilter{}, false); err == nil {
+		t.Error("newer export version accepted")
+	}
+}
+
+func TestParseContextRef(t *testing.T) {
+	scope, key, err := ParseContextRef("context.session.heading_count_README.md")
+	if err != nil || scope != ScopeSession || key != "heading_count_README.md" {
+		t.Errorf("ParseContextRef = %q, %q, %v", scope, key, err)
+	}
+	for _, ref := range []string{"context.session", "context.global.x", "session.x", "context..x"} {
+		if _, _, err := ParseContextRef(ref); err == nil {
+			t.Errorf("%q accepted", ref)
+		}
+	}
+}
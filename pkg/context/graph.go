@@ -0,0 +1,327 @@
+package context
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// isGraph reports whether any step declares dependencies, making the
+// pipeline a graph of steps rather than a sequence.
+func (p *Pipeline) isGraph() bool {
+	for _, step := range p.Steps {
+		if len(step.Needs) > 0 || len(step.Inputs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// stepGraph indexes the steps of a graph pipeline.
+type stepGraph struct {
+	ids  map[string]int // step ID -> index
+	deps [][]int        // indexes each step waits for
+}
+
+// newStepGraph resolves Needs and Inputs references and rejects unknown
+// steps and dependency cycles.
+func newStepGraph(steps []PipelineStep) (*stepGraph, error) {
+	g := &stepGraph{ids: make(map[string]int, len(steps)), deps: make([][]int, len(steps))}
+	for i, step := range steps {
+		id := stepID(i, step)
+		if _, dup := g.ids[id]; dup {
+			return nil, fmt.Errorf("duplicate step id %q", id)
+		}
+		g.ids[id] = i
+	}
+
+	for i, step := range steps {
+		seen := make(map[int]bool)
+		add := func(id string) error {
+			j, ok := g.ids[id]
+			if !ok {
+				return fmt.Errorf("step %q depends on unknown step %q", stepID(i, step), id)
+			}
+			if !seen[j] {
+				seen[j] = true
+				g.deps[i] = append(g.deps[i], j)
+			}
+			return nil
+		}
+		for _, id := range step.Needs {
+			if err := add(id); err != nil {
+				return nil, err
+			}
+		}
+		for name, ref := range step.Inputs {
+			id, _, err := ParseOutputRef(ref)
+			if err != nil {
+				return nil, fmt.Errorf("step %q input %q: %w", stepID(i, step), name, err)
+			}
+			if err := add(id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Kahn's algorithm: steps left unvisited are on a cycle.
+	waiting := make([]int, len(steps))
+	dependents := make([][]int, len(steps))
+	var ready []int
+	for i, deps := range g.deps {
+		waiting[i] = len(deps)
+		for _, j := range deps {
+			dependents[j] = append(dependents[j], i)
+		}
+		if len(deps) == 0 {
+			ready = append(ready, i)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, k := range dependents[i] {
+			if waiting[k]--; waiting[k] == 0 {
+				ready = append(ready, k)
+			}
+		}
+	}
+	if visited < len(steps) {
+		var cycle []string
+		for i, n := range waiting {
+			if n > 0 {
+				cycle = append(cycle, stepID(i, steps[i]))
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle among steps %s", strings.Join(cycle, ", "))
+	}
+	return g, nil
+}
+
+// ValidateGraph checks that step IDs are unique and that every Needs and
+// Inputs reference names an existing step, without cycles.
+func ValidateGraph(steps []PipelineStep) error {
+	_, err := newStepGraph(steps)
+	return err
+}
+
+// ParseOutputRef splits an input reference of the form
+// "<step id>.output[.field...]" into the step ID and the field path.
+func ParseOutputRef(ref string) (stepID string, path []string, err error) {
+	id, rest, _ := strings.Cut(ref, ".")
+	if id == "" || (rest != "output" && !strings.HasPrefix(rest, "output.")) {
+		return "", nil, fmt.Errorf("invalid output reference %q (expected <step>.output[.field])", ref)
+	}
+	if rest != "output" {
+		path = strings.Split(strings.TrimPrefix(rest, "output."), ".")
+	}
+	return id, path, nil
+}
+
+// stepDone is a finished step of a graph pipeline.
+type stepDone struct {
+	index  int
+	result StepResult
+	err    error
+}
+
+// runGraph runs the steps of a graph pipeline. A step starts once the
+// steps it needs have finished, so independent branches run concurrently,
+// up to MaxParallel at a time. A step's input is built from its Inputs and
+// Params; without either it is the output of its single dependency, a map
+// of dependency ID to output payload when it has several, or the pipeline
+// input when it has none. The pipeline output is that of the last step, in
+// declaration order, that succeeded.
+//
+// A failing step that does not skip stops new steps from starting; steps
+// already running finish. Resumed results are matched to steps by ID.
+func (p *Pipeline) runGraph(ctx gocontext.Context, input Envelope) (PipelineResult, error) {
+	g, err := newStepGraph(p.Steps)
+	if err != nil {
+		return PipelineResult{}, fmt.Errorf("pipeline: %w", err)
+	}
+
+	runID := p.runID()
+	finished := make([]*StepResult, len(p.Steps))
+	started := make([]bool, len(p.Steps))
+	for _, sr := range p.Resume {
+		i, ok := g.ids[sr.Step.ID]
+		if !ok || started[i] {
+			continue
+		}
+		sr := sr
+		finished[i], started[i] = &sr, true
+		p.notifyStep(i, sr)
+	}
+
+	startData := map[string]any{"step_count": len(p.Steps)}
+	if p.ID != "" {
+		startData["run_id"] = p.ID
+	}
+	if len(p.Resume) > 0 {
+		startData["resumed"] = len(p.Resume)
+	}
+	p.publishEvent("pipeline.start", startData, 0, 0)
+
+	done := make(chan stepDone)
+	running := 0
+	var stopErr *StepError
+	stop := func(i int, err error, data map[string]any) {
+		stopErr = &StepError{Step: i, Command: p.Steps[i].Command, Err: err}
+		p.publishEvent("pipeline.end", data, i, 0)
+	}
+
+	for {
+		for i := range p.Steps {
+			if stopErr != nil || started[i] || !g.ready(i, finished) {
+				continue
+			}
+			if p.MaxParallel > 0 && running >= p.MaxParallel {
+				break
+			}
+			if ctx.Err() != nil {
+				err := gocontext.Cause(ctx)
+				stop(i, err, map[string]any{"success": false, "error": err.Error(), "step": i})
+				break
+			}
+			started[i] = true
+			running++
+			in, inErr := p.graphInput(g, i, input, finished)
+			go func(i int, step PipelineStep) {
+				sr, err := StepResult{Index: i, Step: step, Status: "error"}, inErr
+				if err != nil {
+					sr.Error = err.Error()
+				} else {
+					sr, err = p.runStep(ctx, runID, i, step, in)
+				}
+				sr.Step.ID = stepID(i, step)
+				done <- stepDone{index: i, result: sr, err: err}
+			}(i, p.Steps[i])
+		}
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+		finished[d.index] = &d.result
+		p.notifyStep(d.index, d.result)
+		if d.err != nil && p.Steps[d.index].OnError != "skip" && stopErr == nil {
+			stop(d.index, d.err, stopEventData(d.result, d.err, d.index))
+		}
+	}
+
+	result := PipelineResult{Success: stopErr == nil}
+	for _, sr := range finished {
+		if sr == nil {
+			continue
+		}
+		result.Steps = append(result.Steps, *sr)
+		if sr.Status == "ok" {
+			result.Output = sr.Output
+		}
+	}
+	if stopErr != nil {
+		return result, stopErr
+	}
+
+	p.publishEvent("pipeline.end", map[string]any{
+		"success":    true,
+		"step_count": len(p.Steps),
+	}, len(p.Steps)-1, 0)
+	return result, nil
+}
+
+// ready reports whether the steps step i depends on have all finished.
+// Failed dependencies only finish when they skip; otherwise the pipeline
+// stops before their dependents start.
+func (g *stepGraph) ready(i int, finished []*StepResult) bool {
+	for _, j := range g.deps[i] {
+		if finished[j] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// graphInput builds the input envelope of step i. Outputs of skipped
+// dependencies are nil. Fields taken from tainted outputs are tainted;
+// params are the spec's own and stay trusted.
+func (p *Pipeline) graphInput(g *stepGraph, i int, input Envelope, finished []*StepResult) (Envelope, error) {
+	step := p.Steps[i]
+	output := func(j int) any {
+		if finished[j].Status != "ok" {
+			return nil
+		}
+		return finished[j].Output.Payload
+	}
+
+	if len(step.Inputs) > 0 {
+		payload := make(map[string]any, len(step.Params)+len(step.Inputs))
+		for k, v := range step.Params {
+			payload[k] = v
+		}
+		env := NewEnvelope(payload, "application/json", "pipeline")
+		for name, ref := range step.Inputs {
+			id, path, _ := ParseOutputRef(ref)
+			v, err := fieldPath(output(g.ids[id]), path)
+			if err != nil {
+				return Envelope{}, fmt.Errorf("input %q (%s): %w", name, ref, err)
+			}
+			payload[name] = v
+			if dep := finished[g.ids[id]]; dep.Status == "ok" && (len(path) == 0 || dep.Output.FieldTainted(path[0])) {
+				env.taintField(name, dep.Output)
+			}
+		}
+		return env, nil
+	}
+	if step.Params != nil {
+		return NewEnvelope(step.Params, "application/json", "pipeline"), nil
+	}
+
+	switch deps := g.deps[i]; len(deps) {
+	case 0:
+		return input, nil
+	case 1:
+		if finished[deps[0]].Status != "ok" {
+			return NewEnvelope(nil, "text/plain", "pipeline"), nil
+		}
+		return finished[deps[0]].Output, nil
+	default:
+		payload := make(map[string]any, len(deps))
+		env := NewEnvelope(payload, "application/json", "pipeline")
+		for _, j := range deps {
+			id := stepID(j, p.Steps[j])
+			payload[id] = output(j)
+			if finished[j].Status == "ok" {
+				env.taintField(id, finished[j].Output)
+			}
+		}
+		return env, nil
+	}
+}
+
+// fieldPath looks up a dotted field path in a payload. Payloads other than
+// maps are converted through JSON first, so struct outputs are addressed by
+// their JSON field names.
+func fieldPath(v any, path []string) (any, error) {
+	for n, field := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			if json.Unmarshal(data, &m) != nil || m == nil {
+				return nil, fmt.Errorf("cannot look up %q in a non-object value", strings.Join(path[:n+1], "."))
+			}
+		}
+		if v, ok = m[field]; !ok {
+			return nil, fmt.Errorf("no field %q", strings.Join(path[:n+1], "."))
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,249 @@
+package context
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPipelineGraphJoin(t *testing.T) {
+	// fetch_prs and fetch_issues each wait until both have started, so the
+	// test only finishes if they run concurrently.
+	var started sync.WaitGroup
+	started.Add(2)
+	exec := newTestExecutor()
+	exec.Register("fetch_prs", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		started.Done()
+		started.Wait()
+		return NewEnvelope(map[string]any{"items": []any{"pr-1", "pr-2"}}, "application/json", "fetch_prs"), nil
+	})
+	exec.Register("fetch_issues", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		started.Done()
+		started.Wait()
+		return NewEnvelope([]string{"issue-1"}, "application/json", "fetch_issues"), nil
+	})
+	var reportInput any
+	exec.Register("report", func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+		reportInput = input.Payload
+		return NewEnvelope("report", "text/plain", "report"), nil
+	})
+
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{ID: "prs", Command: "fetch_prs"},
+			{ID: "issues", Command: "fetch_issues"},
+			{ID: "report", Command: "report", Params: map[string]any{"title": "weekly"},
+				Inputs: map[string]string{"prs": "prs.output.items", "issues": "issues.output"}},
+		},
+		Executor: exec,
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := map[string]any{"title": "weekly", "prs": []any{"pr-1", "pr-2"}, "issues": []string{"issue-1"}}
+	if !reflect.DeepEqual(reportInput, want) {
+		t.Errorf("report input = %#v, want %#v", reportInput, want)
+	}
+	if len(result.Steps) != 3 || result.Steps[2].Step.ID != "report" || result.Output.PayloadString() != "report" {
+		t.Errorf("result = %d steps, output %q", len(result.Steps), result.Output.PayloadString())
+	}
+}
+
+func TestPipelineGraphDefaultInputs(t *testing.T) {
+	exec := newTestExecutor()
+	echo := func(name string) func(gocontext.Context, Envelope, ContextStore) (Envelope, error) {
+		return func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+			return NewEnvelope(fmt.Sprintf("%s(%v)", name, input.Payload), "text/plain", name), nil
+		}
+	}
+	exec.Register("a", echo("a"))
+	exec.Register("b", echo("b"))
+	exec.Register("c", echo("c"))
+
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{ID: "a", Command: "a"},
+			{ID: "b", Command: "b", Needs: []string{"a"}},
+			{ID: "c", Command: "c", Needs: []string{"a", "b"}},
+		},
+		Executor:    exec,
+		MaxParallel: 1,
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope("in", "text/plain", "test"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// One dependency passes its output through; several are keyed by ID.
+	if got := result.Output.PayloadString(); got != "c(map[a:a(in) b:b(a(in))])" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestPipelineGraphTaint(t *testing.T) {
+	exec := newTestExecutor()
+	exec.Register("fetch", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		env := NewEnvelope(map[string]any{"title": "x", "body": "y"}, "application/json", "fetch")
+		env.AddTaint("http:get example.net")
+		env.Meta.TaintedFields = []string{"body"}
+		return env, nil
+	})
+	exec.Register("local", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		return NewEnvelope("notes", "text/plain", "local"), nil
+	})
+	var mu sync.Mutex
+	inputs := map[string]Envelope{} // by step: joined inputs are keyed by step ID
+	exec.Register("use", func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, joined := input.Payload.(map[string]any)["fetch"]; joined {
+			inputs["joined"] = input
+		} else {
+			inputs["mapped"] = input
+		}
+		return input, nil
+	})
+
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{ID: "fetch", Command: "fetch"},
+			{ID: "local", Command: "local"},
+			{ID: "mapped", Command: "use", Params: map[string]any{"path": "out.md"},
+				Inputs: map[string]string{"title": "fetch.output.title", "content": "fetch.output.body", "notes": "local.output"}},
+			{ID: "joined", Command: "use", Needs: []string{"fetch", "local"}},
+		},
+		Executor: exec,
+	}
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", "")); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for name, input := range inputs {
+		if !reflect.DeepEqual(input.Meta.Taint, []string{"http:get example.net"}) {
+			t.Errorf("%s: taint = %v", name, input.Meta.Taint)
+		}
+		want := []string{"content"}
+		if name == "joined" {
+			want = []string{"fetch"}
+		}
+		if !reflect.DeepEqual(input.Meta.TaintedFields, want) {
+			t.Errorf("%s: tainted fields = %v, want %v", name, input.Meta.TaintedFields, want)
+		}
+	}
+	if len(inputs) != 2 {
+		t.Errorf("inputs = %v", inputs)
+	}
+}
+
+func TestPipelineGraphStopsOnFailure(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	exec := newTestExecutor()
+	for _, name := range []string{"ok", "fail", "after"} {
+		name := name
+		exec.Register(name, func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+			if name == "fail" {
+				return Envelope{}, errors.New("boom")
+			}
+			return input, nil
+		})
+	}
+
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{ID: "fail", Command: "fail"},
+			{ID: "after", Command: "after", Needs: []string{"fail"}},
+		},
+		Executor: exec,
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	var se *StepError
+	if !errors.As(err, &se) || se.Step != 0 || result.Success {
+		t.Fatalf("err = %v, success = %v", err, result.Success)
+	}
+	if fmt.Sprint(ran) != "[fail]" {
+		t.Errorf("ran %v, want only the failing step", ran)
+	}
+
+	// A skipped failure lets dependents run with a nil input.
+	ran = nil
+	p.Steps[0].OnError = "skip"
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", "")); err != nil {
+		t.Fatalf("Run with skip: %v", err)
+	}
+	if fmt.Sprint(ran) != "[fail after]" {
+		t.Errorf("ran %v, want [fail after]", ran)
+	}
+}
+
+func TestPipelineGraphErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []PipelineStep
+		want  string
+	}{
+		{"unknown need", []PipelineStep{{ID: "a", Needs: []string{"x"}}}, `unknown step "x"`},
+		{"bad reference", []PipelineStep{{ID: "a"}, {ID: "b", Inputs: map[string]string{"in": "a.result"}}}, "invalid output reference"},
+		{"cycle", []PipelineStep{{ID: "a", Needs: []string{"b"}}, {ID: "b", Needs: []string{"a"}}, {ID: "c"}}, "cycle among steps a, b"},
+		{"duplicate id", []PipelineStep{{ID: "a"}, {ID: "a", Needs: []string{"a"}}}, `duplicate step id "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Pipeline{Steps: tt.steps, Executor: newTestExecutor()}
+			_, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineGraphResume(t *testing.T) {
+	var ran []string
+	exec := newTestExecutor()
+	for _, name := range []string{"a", "b"} {
+		name := name
+		exec.Register(name, func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+			ran = append(ran, name)
+			return NewEnvelope(input.PayloadString()+">"+name, "text/plain", name), nil
+		})
+	}
+	steps := []PipelineStep{{ID: "a", Command: "a"}, {ID: "b", Command: "b", Needs: []string{"a"}}}
+	done := []StepResult{{Step: PipelineStep{ID: "a", Command: "a"}, Status: "ok", Output: NewEnvelope("in>a", "text/plain", "a")}}
+
+	p := &Pipeline{Steps: steps, Executor: exec, Resume: done}
+	result, err := p.Run(gocontext.Background(), NewEnvelope("in", "text/plain", "test"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if fmt.Sprint(ran) != "[b]" || result.Output.PayloadString() != "in>a>b" {
+		t.Errorf("ran %v, output %q", ran, result.Output.PayloadString())
+	}
+}
+
+func TestParseOutputRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		id   string
+		path []string
+		ok   bool
+	}{
+		{"fetch_prs.output", "fetch_prs", nil, true},
+		{"fetch_prs.output.items.0", "fetch_prs", []string{"items", "0"}, true},
+		{"fetch_prs", "", nil, false},
+		{".output", "", nil, false},
+		{"fetch_prs.outputs", "", nil, false},
+	}
+	for _, tt := range tests {
+		id, path, err := ParseOutputRef(tt.ref)
+		if (err == nil) != tt.ok || id != tt.id || !reflect.DeepEqual(path, tt.path) {
+			t.Errorf("ParseOutputRef(%q) = %q, %v, %v", tt.ref, id, path, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package context
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// HistoryEntry records one change to the context store. Entries are kept in
+// ScopeHistory under keys that sort in the order the changes were made.
+// Values are identified by hash only, so the history does not copy them.
+type HistoryEntry struct {
+	Seq     uint64    `json:"seq"`
+	Scope   string    `json:"scope"`
+	Key     string    `json:"key"`
+	Op      string    `json:"op"`                 // "set" or "delete"
+	OldHash string    `json:"old_hash,omitempty"` // empty when the key did not exist
+	NewHash string    `json:"new_hash,omitempty"` // empty for deletes
+	Source  string    `json:"source,omitempty"`   // command or client that made the change
+	Time    time.Time `json:"time"`
+}
+
+// historyScopes are the scopes whose changes are recorded. The step scope
+// only lives as long as a step and is left out.
+var historyScopes = []string{ScopeProject, ScopeSession}
+
+// SourcedStore is implemented by stores that record who changed a key in
+// the history scope. Set and Delete record no source.
+type SourcedStore interface {
+	SetFrom(source, scope, key string, value any) error
+	DeleteFrom(source, scope, key string) error
+}
+
+// WithSource returns a view of store whose changes are recorded as made by
+// source, such as the command a pipeline step runs.
+func WithSource(store ContextStore, source string) ContextStore {
+	return sourcedView{ContextStore: store, source: source}
+}
+
+type sourcedView struct {
+	ContextStore
+	source string
+}
+
+func (v sourcedView) Set(scope, key string, value any) error {
+	if s, ok := v.ContextStore.(SourcedStore); ok {
+		return s.SetFrom(v.source, scope, key, value)
+	}
+	return v.ContextStore.Set(scope, key, value)
+}
+
+func (v sourcedView) Delete(scope, key string) error {
+	if s, ok := v.ContextStore.(SourcedStore); ok {
+		return s.DeleteFrom(v.source, scope, key)
+	}
+	return v.ContextStore.Delete(scope, key)
+}
+
+// hashValue returns a short hash of a stored JSON value, or "" for none.
+func hashValue(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// historyKey formats a sequence number so keys sort numerically.
+func historyKey(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// recordChange appends an entry for a change to scope/key within tx, and
+// drops the entry that falls out of the store's history limit.
+func (s *BoltStore) recordChange(tx *bolt.Tx, e HistoryEntry) error {
+	if !slices.Contains(historyScopes, e.Scope) {
+		return nil
+	}
+	b := tx.Bucket([]byte(ScopeHistory))
+	for {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		// Imported entries may already use the key.
+		if b.Get([]byte(historyKey(seq))) != nil {
+			continue
+		}
+		e.Seq, e.Time = seq, time.Now().UTC()
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(historyKey(seq)), data); err != nil {
+			return err
+		}
+		if limit := uint64(s.historyLimit); limit > 0 && seq > limit {
+			return b.Delete([]byte(historyKey(seq - limit)))
+		}
+		return nil
+	}
+}
+
+// SetHistoryLimit keeps only the latest n history entries; 0 keeps all.
+// Call it before the store is used.
+func (s *BoltStore) SetHistoryLimit(n int) {
+	s.historyLimit = n
+}
+
+// KeyHistory returns the recorded changes to key in scope, oldest first.
+// An empty key selects every key in the scope, and an empty scope every
+// scope.
+func KeyHistory(store ContextStore, scope, key string) ([]HistoryEntry, error) {
+	items, err := store.List(ScopeHistory)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var entries []HistoryEntry
+	for _, k := range keys {
+		data, err := json.Marshal(items[k])
+		if err != nil {
+			return nil, err
+		}
+		var e HistoryEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("history entry %s: %w", k, err)
+		}
+		if (scope == "" || e.Scope == scope) && (key == "" || e.Key == key) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,66 @@
+package context
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyHistory(t *testing.T) {
+	store := newTestStore(t)
+	store.Set(ScopeSession, "title", "draft")
+	WithSource(store, "fs:read").Set(ScopeSession, "title", "final")
+	store.Set(ScopeSession, "other", 1)
+	store.Set(ScopeStep, "run/1/tmp", "x") // step scope is not recorded
+	WithSource(store, "repl").Delete(ScopeSession, "title")
+	store.Delete(ScopeSession, "missing") // nothing to record
+
+	entries, err := KeyHistory(store, ScopeSession, "title")
+	if err != nil {
+		t.Fatalf("KeyHistory: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	draft, final := hashValue([]byte(`"draft"`)), hashValue([]byte(`"final"`))
+	want := []HistoryEntry{
+		{Op: "set", NewHash: draft},
+		{Op: "set", OldHash: draft, NewHash: final, Source: "fs:read"},
+		{Op: "delete", OldHash: final, Source: "repl"},
+	}
+	for i, e := range entries {
+		w := want[i]
+		if e.Op != w.Op || e.OldHash != w.OldHash || e.NewHash != w.NewHash || e.Source != w.Source || e.Key != "title" {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+		}
+		if i > 0 && e.Seq <= entries[i-1].Seq {
+			t.Errorf("entry %d seq %d not after %d", i, e.Seq, entries[i-1].Seq)
+		}
+	}
+	if all, _ := KeyHistory(store, "", ""); len(all) != 4 {
+		t.Errorf("all entries = %d, want 4", len(all))
+	}
+
+	// History is append-only.
+	key := historyKey(entries[0].Seq)
+	if err := store.Set(ScopeHistory, key, "forged"); err == nil || !strings.Contains(err.Error(), "append-only") {
+		t.Errorf("overwrite history: %v", err)
+	}
+	if err := store.Delete(ScopeHistory, key); err == nil {
+		t.Error("history entry deleted")
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	store := newTestStore(t)
+	store.SetHistoryLimit(2)
+	for i := range 5 {
+		store.Set(ScopeProject, "n", i)
+	}
+	entries, err := KeyHistory(store, ScopeProject, "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Seq != 4 || entries[1].Seq != 5 {
+		t.Errorf("entries = %+v", entries)
+	}
+}
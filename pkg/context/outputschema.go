@@ -0,0 +1,75 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrOutputSchema is wrapped by the error a step fails with when its output
+// does not match its command's output schema.
+var ErrOutputSchema = fmt.Errorf("output schema mismatch: %w", ErrVerificationFailed)
+
+// OutputChecker checks a command's output against the command's declared
+// output schema and returns the problems found, such as "count: expected
+// integer, got string". Executors that implement it support
+// PipelineStep.VerifyOutputSchema. This avoids a direct dependency on
+// pkg/platform.
+type OutputChecker interface {
+	CheckOutput(command string, output Envelope) ([]string, error)
+}
+
+// SchemaCheck records the result of checking a step's output against its
+// command's output schema.
+type SchemaCheck struct {
+	Passed   bool     `json:"passed"`
+	Problems []string `json:"problems,omitempty"`
+	Error    string   `json:"error,omitempty"` // the check itself failed
+}
+
+// checkOutputSchema checks the output of a step that sets
+// VerifyOutputSchema and attaches the result to the output's latest
+// provenance entry. A mismatch fails the step as a failed verification
+// does, honouring OnVerifyFailure; so does a check that cannot be made.
+func (p *Pipeline) checkOutputSchema(sr *StepResult) error {
+	step := sr.Step
+	if !step.VerifyOutputSchema || step.Uses != "" || step.Ask != nil {
+		return nil
+	}
+	var check SchemaCheck
+	if checker, ok := p.Executor.(OutputChecker); !ok {
+		check.Error = "the executor cannot check output schemas"
+	} else if problems, err := checker.CheckOutput(step.Command, sr.Output); err != nil {
+		check.Error = err.Error()
+	} else {
+		check.Passed, check.Problems = len(problems) == 0, problems
+	}
+	if n := len(sr.Output.Provenance); n > 0 {
+		sr.Output.Provenance[n-1].SchemaCheck = &check
+	}
+	p.publishEvent("verify.output_schema", map[string]any{
+		"step":     sr.Index,
+		"command":  step.Command,
+		"passed":   check.Passed,
+		"problems": check.Problems,
+		"error":    check.Error,
+	}, sr.Index, 0)
+	if check.Passed {
+		return nil
+	}
+
+	summary := check.Error
+	if summary == "" {
+		summary = fmt.Sprintf("%s: %s", step.Command, strings.Join(check.Problems, "; "))
+	}
+	passed := false
+	sr.Status = "verify_failed"
+	sr.VerifyPassed = &passed
+	sr.VerifyMessage = summary
+	if step.OnVerifyFailure == "rollback" {
+		sr.RolledBack = p.rollback(sr.Index, sr.CheckpointSaved)
+	}
+	if sr.RolledBack {
+		return fmt.Errorf("%w, rolled back to checkpoint %s: %s", ErrOutputSchema, sr.CheckpointSaved, summary)
+	}
+	return fmt.Errorf("%w: %s", ErrOutputSchema, summary)
+}
@@ -0,0 +1,75 @@
+package context
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+)
+
+// checkingExecutor reports a problem for outputs whose payload is not an
+// object with a "count" field.
+type checkingExecutor struct {
+	*testExecutor
+}
+
+func (e checkingExecutor) CheckOutput(command string, output Envelope) ([]string, error) {
+	if m, ok := output.Payload.(map[string]any); ok && m["count"] != nil {
+		return nil, nil
+	}
+	return []string{"count: missing"}, nil
+}
+
+func TestPipelineVerifyOutputSchema(t *testing.T) {
+	exec := newTestExecutor()
+	exec.Register("list", func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+		return NewEnvelope(input.Payload, "application/json", "list"), nil
+	})
+
+	p := &Pipeline{
+		Steps:    []PipelineStep{{Command: "list", VerifyOutputSchema: true}},
+		Executor: checkingExecutor{exec},
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope(map[string]any{"count": 2}, "application/json", "test"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	prov := result.Output.Provenance
+	if len(prov) != 1 || prov[0].SchemaCheck == nil || !prov[0].SchemaCheck.Passed {
+		t.Fatalf("provenance = %+v", prov)
+	}
+
+	pub := &testEventPublisher{}
+	p.Events = pub
+	result, err = p.Run(gocontext.Background(), NewEnvelope(map[string]any{"total": 2}, "application/json", "test"))
+	if !errors.Is(err, ErrOutputSchema) || !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("mismatch: err = %v", err)
+	}
+	sr := result.Steps[0]
+	check := sr.Output.Provenance[0].SchemaCheck
+	if sr.Status != "verify_failed" || check == nil || check.Passed || len(check.Problems) != 1 {
+		t.Errorf("status = %s, check = %+v", sr.Status, check)
+	}
+	var event map[string]any
+	for _, ev := range pub.events {
+		if ev.Type == "verify.output_schema" {
+			event = ev.Data.(map[string]any)
+		}
+	}
+	if event == nil || event["passed"] != false {
+		t.Errorf("verify.output_schema event = %v", event)
+	}
+
+	// Without a checker the step cannot be verified.
+	p.Executor = exec
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(map[string]any{"count": 2}, "application/json", "test")); !errors.Is(err, ErrOutputSchema) {
+		t.Errorf("no checker: err = %v", err)
+	}
+
+	// Steps that do not ask are not checked.
+	p.Executor = checkingExecutor{exec}
+	p.Steps[0].VerifyOutputSchema = false
+	result, err = p.Run(gocontext.Background(), NewEnvelope("text", "text/plain", "test"))
+	if err != nil || result.Output.Provenance[0].SchemaCheck != nil {
+		t.Errorf("unchecked step: err = %v, provenance = %+v", err, result.Output.Provenance)
+	}
+}
@@ -1,11 +1,39 @@
 package context
 
 import (
+	"cmp"
 	gocontext "context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// ErrVerificationFailed is wrapped by the error Run returns when a step's
+// verification fails and the step does not skip.
+var ErrVerificationFailed = errors.New("verification failed")
+
+// ErrTimeout is wrapped by the error Run returns when the run exceeds
+// Pipeline.Timeout.
+var ErrTimeout = errors.New("run timed out")
+
+// StepError is returned by Run when a step stops the pipeline. It names
+// the offending step and command.
+type StepError struct {
+	Step    int
+	Command string
+	Err     error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("pipeline stopped at step %d (%s): %v", e.Step, e.Command, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
 // CommandExecutor is the interface that pipeline uses to execute commands.
 // This avoids a direct dependency on pkg/platform.
 type CommandExecutor interface {
@@ -24,6 +52,20 @@ type StepVerifier interface {
 	VerifyStep(stepIndex int, envelope Envelope) (passed bool, summary string, err error)
 }
 
+// ContextStepVerifier is implemented by a StepVerifier whose checks can be
+// cancelled. The pipeline calls VerifyStepContext instead of VerifyStep,
+// with the run's context, so aborting the run stops a step's verification.
+type ContextStepVerifier interface {
+	VerifyStepContext(ctx gocontext.Context, stepIndex int, envelope Envelope) (passed bool, summary string, err error)
+}
+
+// StepAssertionReporter is implemented by a StepVerifier that can report
+// the outcome of each of a step's assertions after VerifyStep; they are
+// added to the verify.result event as "assertions".
+type StepAssertionReporter interface {
+	StepAssertions(stepIndex int) any
+}
+
 // Checkpointer saves state snapshots before risky steps.
 // This avoids a direct dependency on pkg/verify.
 type Checkpointer interface {
@@ -31,23 +73,133 @@ type Checkpointer interface {
 	RestoreCheckpoint(name string) error
 }
 
+// SpecRunner runs the spec a step uses as a nested pipeline, with its own
+// plan and verification, and returns that pipeline's result. An error
+// wrapping ErrVerificationFailed means the spec's success criteria failed.
+// This avoids a direct dependency on pkg/spec.
+type SpecRunner interface {
+	RunSpec(ctx gocontext.Context, path string, params map[string]string, input Envelope) (PipelineResult, error)
+}
+
+// Asker puts an ask step's question to a human and waits for the answer.
+// This avoids a direct dependency on the shell and its front ends.
+type Asker interface {
+	Ask(ctx gocontext.Context, q Question) (string, error)
+}
+
+type askerKey struct{}
+
+// AskerFrom returns the Asker of the pipeline running the current step, so
+// commands and middleware can put questions to the operator too. It
+// returns nil outside a step or when the pipeline has no Asker.
+func AskerFrom(ctx gocontext.Context) Asker {
+	a, _ := ctx.Value(askerKey{}).(Asker)
+	return a
+}
+
+// Question is what an ask step asks.
+type Question struct {
+	Step    string   `json:"step,omitempty"` // ID of the asking step, set by the pipeline
+	Prompt  string   `json:"prompt"`
+	Choices []string `json:"choices,omitempty"` // allowed answers; any answer when empty
+	Default string   `json:"default,omitempty"` // used for an empty answer
+
+	// Key is the ScopeSession key the answer is stored under. Defaults to
+	// "ask.<step id>".
+	Key string `json:"key,omitempty"`
+}
+
+// StepObserver is notified as each step finishes, whatever its status.
+// Used to stream partial results before the pipeline completes.
+type StepObserver interface {
+	StepCompleted(stepIndex int, result StepResult)
+}
+
 // Pipeline defines a sequence of commands to execute.
 type Pipeline struct {
 	Steps        []PipelineStep
 	Context      ContextStore
 	Executor     CommandExecutor
 	Events       EventPublisher
-	Verifier     StepVerifier // optional: verify step outputs
-	Checkpointer Checkpointer // optional: checkpoint before risky steps
+	Verifier     StepVerifier  // optional: verify step outputs
+	Checkpointer Checkpointer  // optional: checkpoint before risky steps
+	Observer     StepObserver  // optional: notified after each step
+	Artifacts    ArtifactStore // optional: persists what steps Attach
+	Specs        SpecRunner    // optional: runs steps that use another spec
+	Asker        Asker         // optional: answers ask steps
+
+	// ID namespaces this run's keys in ScopeStep and its checkpoint names.
+	// Generated when empty.
+	ID string
+
+	// Resume holds the results of steps an earlier attempt of this run
+	// completed (see CompletedSteps). Run reports them unchanged and starts
+	// at the step after them, with the last one's output as input.
+	Resume []StepResult
+
+	// MaxParallel limits how many independent steps of a graph pipeline
+	// run at once. Zero means no limit.
+	MaxParallel int
+
+	// Timeout bounds the whole run; zero means no limit. When it passes,
+	// the running step's context is cancelled and the run waits up to
+	// TimeoutGrace (DefaultTimeoutGrace when zero) for the step to stop.
+	// A step that ignores the cancellation longer is abandoned and marked
+	// so in its result: it may still change state after Run returns, even
+	// after a rollback. OnTimeout "rollback" restores the latest checkpoint
+	// the run saved; "stop" (the default) leaves state as it is.
+	Timeout      time.Duration
+	TimeoutGrace time.Duration
+	OnTimeout    string
+
+	// IntentDrift checks each step's effects against its declared risk,
+	// intent and input (see DetectDrift): "warn" publishes verify.drift,
+	// "block" also fails the step as if its verification failed. Empty or
+	// "off" disables the check.
+	IntentDrift string
 }
 
 // PipelineStep defines a single step within a pipeline.
 type PipelineStep struct {
+	ID               string   `json:"id,omitempty"` // defaults to the step index
 	Command          string   `json:"command"`
 	Args             []string `json:"args"`
 	Intent           string   `json:"intent"`
-	OnError          string   `json:"on_error"`          // "stop", "skip", "retry"
+	Risk             string   `json:"risk,omitempty"` // "read-only", "write", "destructive"
+	OnError          string   `json:"on_error"`       // "stop", "skip", "retry"
 	CheckpointBefore bool     `json:"checkpoint_before,omitempty"`
+
+	// OnVerifyFailure is "stop" (the default) or "rollback". With
+	// "rollback", a checkpoint is saved before the step and restored if the
+	// step's output fails verification.
+	OnVerifyFailure string `json:"on_verify_failure,omitempty"`
+
+	// VerifyOutputSchema checks the step's output against its command's
+	// output schema; a mismatch fails the step's verification. The result
+	// is recorded in the output's provenance. Needs an OutputChecker
+	// executor.
+	VerifyOutputSchema bool `json:"verify_output_schema,omitempty"`
+
+	// Params, when set, become the step's input payload in place of the
+	// previous step's output.
+	Params map[string]any `json:"params,omitempty"`
+
+	// Needs lists the IDs of steps that must finish before this one.
+	Needs []string `json:"needs,omitempty"`
+
+	// Inputs maps input field names to earlier step outputs, as
+	// "<step id>.output" optionally followed by ".<field>" path elements.
+	// The fields are added to Params to form the step's input payload.
+	Inputs map[string]string `json:"inputs,omitempty"`
+
+	// Uses, when set, runs the spec at this path through Pipeline.Specs
+	// instead of Command, with With as its params.
+	Uses string            `json:"uses,omitempty"`
+	With map[string]string `json:"with,omitempty"`
+
+	// Ask, when set, asks a human through Pipeline.Asker instead of
+	// running Command. The answer is the step's output.
+	Ask *Question `json:"ask,omitempty"`
 }
 
 // PipelineResult holds the outcome of a pipeline execution.
@@ -59,6 +211,7 @@ type PipelineResult struct {
 
 // StepResult records the outcome of a single pipeline step.
 type StepResult struct {
+	Index           int           `json:"index"` // position in Pipeline.Steps
 	Step            PipelineStep  `json:"step"`
 	Output          Envelope      `json:"output"`
 	Error           string        `json:"error,omitempty"`
@@ -67,13 +220,55 @@ type StepResult struct {
 	VerifyPassed    *bool         `json:"verify_passed,omitempty"`
 	VerifyMessage   string        `json:"verify_message,omitempty"`
 	CheckpointSaved string        `json:"checkpoint_saved,omitempty"`
+	RolledBack      bool          `json:"rolled_back,omitempty"`
+	Artifacts       []ArtifactRef `json:"artifacts,omitempty"`
+	Children        []StepResult  `json:"children,omitempty"`  // steps of the spec a step uses
+	Effects         Effects       `json:"effects,omitzero"`    // what the step was seen doing
+	Drift           []string      `json:"drift,omitempty"`     // mismatches between Effects and the step's declaration
+	Abandoned       bool          `json:"abandoned,omitempty"` // still running when the run timed out
 }
 
-// Run executes the pipeline, passing envelopes between steps.
+// Run executes the pipeline, passing envelopes between steps. When any
+// step declares Needs or Inputs, the steps form a graph instead; see
+// runGraph.
 func (p *Pipeline) Run(ctx gocontext.Context, input Envelope) (PipelineResult, error) {
 	if p.Executor == nil {
 		return PipelineResult{}, fmt.Errorf("pipeline: no executor configured")
 	}
+	started := time.Now()
+	if p.Timeout > 0 {
+		var cancel gocontext.CancelFunc
+		ctx, cancel = gocontext.WithTimeoutCause(ctx, p.Timeout, fmt.Errorf("%w after %s", ErrTimeout, p.Timeout))
+		defer cancel()
+	}
+	result, err := p.run(ctx, input)
+	if p.Timeout > 0 && err != nil && errors.Is(gocontext.Cause(ctx), ErrTimeout) {
+		p.timedOut(&result, err)
+	}
+	p.recordDuration(&result, time.Since(started))
+	return result, err
+}
+
+// PipelineDurationTag is the tag of a pipeline's final output that holds
+// how long the pipeline ran, in milliseconds.
+const PipelineDurationTag = "pipeline_duration_ms"
+
+// recordDuration tags the output with the pipeline's run time, counting
+// the steps of an earlier attempt it resumed.
+func (p *Pipeline) recordDuration(result *PipelineResult, elapsed time.Duration) {
+	for _, sr := range p.Resume {
+		elapsed += sr.Duration
+	}
+	tags := make(map[string]string, len(result.Output.Meta.Tags)+1)
+	maps.Copy(tags, result.Output.Meta.Tags)
+	tags[PipelineDurationTag] = strconv.FormatInt(elapsed.Milliseconds(), 10)
+	result.Output.Meta.Tags = tags
+}
+
+func (p *Pipeline) run(ctx gocontext.Context, input Envelope) (PipelineResult, error) {
+	if p.isGraph() {
+		return p.runGraph(ctx, input)
+	}
 
 	result := PipelineResult{
 		Steps:   make([]StepResult, 0, len(p.Steps)),
@@ -81,164 +276,502 @@ func (p *Pipeline) Run(ctx gocontext.Context, input Envelope) (PipelineResult, e
 	}
 
 	current := input
+	start := len(p.Resume)
+	if start > len(p.Steps) {
+		return PipelineResult{}, fmt.Errorf("pipeline: %d steps to resume, but only %d steps", start, len(p.Steps))
+	}
+	for i, sr := range p.Resume {
+		result.Steps = append(result.Steps, sr)
+		p.notifyStep(i, sr)
+		if sr.Status == "ok" {
+			current = sr.Output
+		}
+	}
 
-	p.publishEvent("pipeline.start", map[string]any{
-		"step_count": len(p.Steps),
-	}, 0, 0)
-
-	for i, step := range p.Steps {
-		// Save checkpoint before risky steps.
-		if step.CheckpointBefore && p.Checkpointer != nil {
-			cpName := fmt.Sprintf("step-%d-%s", i, step.Command)
-			if err := p.Checkpointer.SaveCheckpoint(cpName); err != nil {
-				p.publishEvent("checkpoint.error", map[string]any{
-					"step": i, "error": err.Error(),
-				}, i, 0)
-			} else {
-				p.publishEvent("checkpoint.saved", map[string]any{
-					"step": i, "name": cpName,
-				}, i, 0)
-			}
+	runID := p.runID()
+
+	startData := map[string]any{"step_count": len(p.Steps)}
+	if p.ID != "" {
+		startData["run_id"] = p.ID
+	}
+	if start > 0 {
+		startData["resumed_at"] = start
+	}
+	p.publishEvent("pipeline.start", startData, start, 0)
+	if start > 0 && start < len(p.Steps) {
+		p.restoreForResume(start)
+	}
+
+	for i := start; i < len(p.Steps); i++ {
+		step := p.Steps[i]
+
+		// An interrupted run stops between steps, so it can be resumed here.
+		if ctx.Err() != nil {
+			err := gocontext.Cause(ctx)
+			result.Success = false
+			p.publishEvent("pipeline.end", map[string]any{
+				"success": false,
+				"error":   err.Error(),
+				"step":    i,
+			}, i, 0)
+			return result, &StepError{Step: i, Command: step.Command, Err: err}
+		}
+
+		stepInput := current
+		if step.Params != nil {
+			stepInput = NewEnvelope(step.Params, "application/json", "pipeline")
 		}
 
-		// Set step context if store is available.
-		if p.Context != nil {
-			p.Context.Set(ScopeStep, "command", step.Command)
-			p.Context.Set(ScopeStep, "index", i)
-			if step.Intent != "" {
-				p.Context.Set(ScopeStep, "intent", step.Intent)
+		sr, err := p.runStep(ctx, runID, i, step, stepInput)
+		result.Steps = append(result.Steps, sr)
+		p.notifyStep(i, sr)
+		if err != nil {
+			if step.OnError == "skip" {
+				continue
 			}
+			result.Success = false
+			p.publishEvent("pipeline.end", stopEventData(sr, err, i), i, 0)
+			return result, &StepError{Step: i, Command: step.Command, Err: err}
 		}
 
-		p.publishEvent("command.start", map[string]any{
-			"command": step.Command,
-			"args":    step.Args,
-			"intent":  step.Intent,
-		}, i, 0)
+		// Pass output as input to the next step.
+		current = sr.Output
+	}
 
-		start := time.Now()
-		output, err := p.Executor.Execute(ctx, step.Command, current, p.Context)
-		duration := time.Since(start)
+	result.Output = current
 
-		sr := StepResult{
-			Step:     step,
-			Duration: duration,
+	p.publishEvent("pipeline.end", map[string]any{
+		"success":    true,
+		"step_count": len(p.Steps),
+	}, len(p.Steps)-1, 0)
+
+	return result, nil
+}
+
+// runStep executes one step with the given input: it saves the step's
+// checkpoint, exposes the step in ScopeStep while it runs, stores its
+// artifacts, records provenance and verifies the output. The error is the
+// command's, or wraps ErrVerificationFailed; the caller applies OnError.
+func (p *Pipeline) runStep(ctx gocontext.Context, runID string, i int, step PipelineStep, input Envelope) (StepResult, error) {
+	// Save checkpoint before risky steps.
+	cpSaved := ""
+	if (step.CheckpointBefore || step.OnVerifyFailure == "rollback") && p.Checkpointer != nil {
+		cpName := StepCheckpointName(p.ID, i, step.Command)
+		if err := p.Checkpointer.SaveCheckpoint(cpName); err != nil {
+			p.publishEvent("checkpoint.error", map[string]any{
+				"step": i, "error": err.Error(),
+			}, i, 0)
+		} else {
+			cpSaved = cpName
+			p.publishEvent("checkpoint.saved", map[string]any{
+				"step": i, "name": cpName,
+			}, i, 0)
 		}
+	}
 
-		if step.CheckpointBefore && p.Checkpointer != nil {
-			cpName := fmt.Sprintf("step-%d-%s", i, step.Command)
-			sr.CheckpointSaved = cpName
+	// Set step context if store is available; it is removed again when
+	// the step finishes.
+	sid := stepID(i, step)
+	if p.Context != nil {
+		p.Context.Set(ScopeStep, StepKey(runID, sid, "command"), step.Command)
+		p.Context.Set(ScopeStep, StepKey(runID, sid, "index"), i)
+		if step.Intent != "" {
+			p.Context.Set(ScopeStep, StepKey(runID, sid, "intent"), step.Intent)
 		}
+	}
+	defer p.clearStep(runID, sid)
 
-		if err != nil {
+	p.publishEvent("command.start", map[string]any{
+		"command": step.Command,
+		"args":    step.Args,
+		"intent":  step.Intent,
+	}, i, 0)
+
+	stepCtx, collector := ctx, (*artifactCollector)(nil)
+	if p.Artifacts != nil {
+		stepCtx, collector = withArtifactCollector(ctx)
+	}
+	stepCtx, recorder := withEffectRecorder(stepCtx)
+	if p.Asker != nil {
+		stepCtx = gocontext.WithValue(stepCtx, askerKey{}, p.Asker)
+	}
+	store := p.Context
+	if store != nil {
+		store = effectStore{ContextStore: WithSource(store, step.Command), rec: recorder}
+	}
+
+	var (
+		output    Envelope
+		children  []StepResult
+		abandoned bool
+		err       error
+	)
+	start := time.Now()
+	output, children, abandoned, err = p.watch(stepCtx, func() (Envelope, []StepResult, error) {
+		switch {
+		case step.Uses != "":
+			return p.runUses(stepCtx, step, input)
+		case step.Ask != nil:
+			output, err := p.runAsk(stepCtx, sid, *step.Ask)
+			return output, nil, err
+		}
+		output, err := p.Executor.Execute(stepCtx, step.Command, input, store)
+		return output, nil, err
+	})
+	duration := time.Since(start)
+
+	sr := StepResult{
+		Index:           i,
+		Step:            step,
+		Duration:        duration,
+		CheckpointSaved: cpSaved,
+		Children:        children,
+		Abandoned:       abandoned,
+	}
+	if collector != nil {
+		sr.Artifacts = p.saveArtifacts(runID, sid, i, collector)
+	}
+	sr.Effects = recorder.recorded()
+
+	if err != nil {
+		sr.Status = "error"
+		sr.Error = err.Error()
+		p.publishEvent("command.error", withArtifacts(map[string]any{
+			"command": step.Command,
+			"error":   err.Error(),
+		}, sr.Artifacts), i, duration)
+		return sr, err
+	}
+
+	// Record provenance.
+	output.AddStep(Step{
+		Command:   step.Command,
+		Args:      step.Args,
+		Timestamp: start,
+		Duration:  duration,
+		Status:    "ok",
+	})
+
+	sr.Status = "ok"
+	sr.Output = output
+
+	if err := p.checkDrift(&sr, input); err != nil {
+		return sr, err
+	}
+	if err := p.checkOutputSchema(&sr); err != nil {
+		return sr, err
+	}
+
+	// Verify step output if verifier is configured.
+	if p.Verifier != nil {
+		passed, summary, verifyErr := p.verifyStep(ctx, i, output)
+		if verifyErr != nil && ctx.Err() != nil {
+			err := gocontext.Cause(ctx)
 			sr.Status = "error"
 			sr.Error = err.Error()
-			result.Steps = append(result.Steps, sr)
-
-			p.publishEvent("command.error", map[string]any{
+			p.publishEvent("command.error", withArtifacts(map[string]any{
 				"command": step.Command,
 				"error":   err.Error(),
-			}, i, duration)
+			}, sr.Artifacts), i, duration)
+			return sr, err
+		}
+		boolVal := passed
+		sr.VerifyPassed = &boolVal
+		sr.VerifyMessage = summary
+
+		if verifyErr != nil {
+			sr.VerifyMessage = fmt.Sprintf("verification error: %v", verifyErr)
+		}
 
-			onError := step.OnError
-			if onError == "" {
-				onError = "stop"
+		data := map[string]any{
+			"step":    i,
+			"passed":  passed,
+			"summary": summary,
+		}
+		if r, ok := p.Verifier.(StepAssertionReporter); ok {
+			if assertions := r.StepAssertions(i); assertions != nil {
+				data["assertions"] = assertions
 			}
+		}
+		p.publishEvent("verify.result", data, i, 0)
 
-			switch onError {
-			case "skip":
-				sr.Status = "skipped"
-				continue
-			case "stop":
-				result.Success = false
-				p.publishEvent("pipeline.end", map[string]any{
-					"success": false,
-					"error":   err.Error(),
-					"step":    i,
-				}, i, 0)
-				return result, fmt.Errorf("pipeline stopped at step %d (%s): %w", i, step.Command, err)
-			default:
-				result.Success = false
-				p.publishEvent("pipeline.end", map[string]any{
-					"success": false,
-					"error":   err.Error(),
-					"step":    i,
-				}, i, 0)
-				return result, fmt.Errorf("pipeline stopped at step %d (%s): %w", i, step.Command, err)
+		if !passed {
+			sr.Status = "verify_failed"
+			if step.OnVerifyFailure == "rollback" {
+				sr.RolledBack = p.rollback(i, cpSaved)
+			}
+			if sr.RolledBack {
+				return sr, fmt.Errorf("%w, rolled back to checkpoint %s: %s", ErrVerificationFailed, cpSaved, summary)
 			}
+			return sr, fmt.Errorf("%w: %s", ErrVerificationFailed, summary)
 		}
+	}
 
-		// Record provenance.
-		output.AddStep(Step{
-			Command:   step.Command,
-			Args:      step.Args,
-			Timestamp: start,
-			Duration:  duration,
-			Status:    "ok",
-		})
+	p.publishEvent("command.end", withArtifacts(map[string]any{
+		"command": step.Command,
+		"status":  "ok",
+	}, sr.Artifacts), i, duration)
+
+	return sr, nil
+}
 
-		sr.Status = "ok"
-		sr.Output = output
+// verifyStep verifies a step's output with ctx, if the verifier takes one.
+func (p *Pipeline) verifyStep(ctx gocontext.Context, i int, output Envelope) (bool, string, error) {
+	if v, ok := p.Verifier.(ContextStepVerifier); ok {
+		return v.VerifyStepContext(ctx, i, output)
+	}
+	return p.Verifier.VerifyStep(i, output)
+}
 
-		// Verify step output if verifier is configured.
-		if p.Verifier != nil {
-			passed, summary, verifyErr := p.Verifier.VerifyStep(i, output)
-			boolVal := passed
-			sr.VerifyPassed = &boolVal
-			sr.VerifyMessage = summary
+// DefaultTimeoutGrace is how long a timed-out run waits for the running
+// step to stop when Pipeline.TimeoutGrace is zero.
+const DefaultTimeoutGrace = 5 * time.Second
+
+// watch runs a step's work. With a Timeout, once the step's context is
+// done it waits for the step to stop for at most the grace period, then
+// abandons it and reports so.
+func (p *Pipeline) watch(ctx gocontext.Context, work func() (Envelope, []StepResult, error)) (Envelope, []StepResult, bool, error) {
+	if p.Timeout <= 0 {
+		output, children, err := work()
+		return output, children, false, err
+	}
+	type outcome struct {
+		output   Envelope
+		children []StepResult
+		err      error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		output, children, err := work()
+		done <- outcome{output, children, err}
+	}()
+	select {
+	case o := <-done:
+		if o.err != nil && errors.Is(gocontext.Cause(ctx), ErrTimeout) {
+			o.err = gocontext.Cause(ctx)
+		}
+		return o.output, o.children, false, o.err
+	case <-ctx.Done():
+	}
+	grace := cmp.Or(p.TimeoutGrace, DefaultTimeoutGrace)
+	select {
+	case o := <-done:
+		return Envelope{}, o.children, false, gocontext.Cause(ctx)
+	case <-time.After(grace):
+		return Envelope{}, nil, true, gocontext.Cause(ctx)
+	}
+}
 
-			if verifyErr != nil {
-				sr.VerifyMessage = fmt.Sprintf("verification error: %v", verifyErr)
+// timedOut handles a run stopped by Timeout: it publishes pipeline.timeout,
+// listing the steps it abandoned, and, with OnTimeout "rollback", restores
+// the latest checkpoint the run saved, marking the step that saved it as
+// rolled back.
+func (p *Pipeline) timedOut(result *PipelineResult, stopErr error) {
+	step := len(p.Steps) - 1
+	var se *StepError
+	if errors.As(stopErr, &se) {
+		step = se.Step
+	}
+	data := map[string]any{"timeout": p.Timeout.String(), "step": step}
+	var abandoned []int
+	for _, sr := range result.Steps {
+		if sr.Abandoned {
+			abandoned = append(abandoned, sr.Index)
+		}
+	}
+	if abandoned != nil {
+		data["abandoned"] = abandoned
+	}
+	if p.OnTimeout == "rollback" {
+		latest := -1
+		for j, sr := range result.Steps {
+			if sr.CheckpointSaved != "" {
+				latest = j
 			}
+		}
+		switch {
+		case latest < 0:
+			p.rollback(step, "")
+		case p.rollback(result.Steps[latest].Index, result.Steps[latest].CheckpointSaved):
+			result.Steps[latest].RolledBack = true
+			data["rolled_back_to"] = result.Steps[latest].CheckpointSaved
+		}
+	}
+	p.publishEvent("pipeline.timeout", data, step, 0)
+}
 
-			p.publishEvent("verify.result", map[string]any{
-				"step":    i,
-				"passed":  passed,
-				"summary": summary,
-			}, i, 0)
+// runUses runs the spec a step uses. The nested steps' results become the
+// step's children and its output the step's output.
+func (p *Pipeline) runUses(ctx gocontext.Context, step PipelineStep, input Envelope) (Envelope, []StepResult, error) {
+	if p.Specs == nil {
+		return Envelope{}, nil, fmt.Errorf("step uses %s, but the pipeline cannot run specs", step.Uses)
+	}
+	res, err := p.Specs.RunSpec(ctx, step.Uses, step.With, input)
+	if err != nil {
+		return Envelope{}, res.Steps, fmt.Errorf("%s: %w", step.Uses, err)
+	}
+	return res.Output, res.Steps, nil
+}
 
-			if !passed {
-				sr.Status = "verify_failed"
-				result.Steps = append(result.Steps, sr)
-
-				onError := step.OnError
-				if onError == "" {
-					onError = "stop"
-				}
-				switch onError {
-				case "skip":
-					continue
-				default:
-					result.Success = false
-					p.publishEvent("pipeline.end", map[string]any{
-						"success":        false,
-						"verify_failure": summary,
-						"step":           i,
-					}, i, 0)
-					return result, fmt.Errorf("verification failed at step %d (%s): %s", i, step.Command, summary)
-				}
-			}
+// runAsk asks a step's question and stores the answer in ScopeSession.
+// An empty answer takes the default; an answer outside Choices fails the
+// step.
+func (p *Pipeline) runAsk(ctx gocontext.Context, sid string, q Question) (Envelope, error) {
+	if p.Asker == nil {
+		return Envelope{}, fmt.Errorf("step asks %q, but no one can answer", q.Prompt)
+	}
+	q.Step = sid
+	if q.Key == "" {
+		q.Key = "ask." + sid
+	}
+	answer, err := p.Asker.Ask(ctx, q)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("ask: %w", err)
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		answer = q.Default
+	}
+	if len(q.Choices) > 0 && !slices.Contains(q.Choices, answer) {
+		return Envelope{}, fmt.Errorf("answer %q is not one of %s", answer, strings.Join(q.Choices, ", "))
+	}
+	if p.Context != nil {
+		if err := p.Context.Set(ScopeSession, q.Key, answer); err != nil {
+			return Envelope{}, fmt.Errorf("store answer: %w", err)
 		}
+	}
+	env := NewEnvelope(answer, "text/plain", "ask")
+	env.Meta.Tags["key"] = q.Key
+	return env, nil
+}
 
-		result.Steps = append(result.Steps, sr)
+// stopEventData is the data of the pipeline.end event for a run that a
+// failed step stopped.
+func stopEventData(sr StepResult, err error, i int) map[string]any {
+	if sr.Status == "verify_failed" {
+		return map[string]any{
+			"success":        false,
+			"verify_failure": sr.VerifyMessage,
+			"step":           i,
+		}
+	}
+	return map[string]any{
+		"success": false,
+		"error":   err.Error(),
+		"step":    i,
+	}
+}
 
-		p.publishEvent("command.end", map[string]any{
-			"command": step.Command,
-			"status":  "ok",
-		}, i, duration)
+func (p *Pipeline) runID() string {
+	if p.ID != "" {
+		return p.ID
+	}
+	return fmt.Sprintf("run-%d", time.Now().UnixNano())
+}
 
-		// Pass output as input to the next step.
-		current = output
+// saveArtifacts stores what a step attached. A failed save is reported as
+// an event and does not fail the step.
+func (p *Pipeline) saveArtifacts(runID, step string, index int, c *artifactCollector) []ArtifactRef {
+	var refs []ArtifactRef
+	for _, a := range c.collected() {
+		ref, err := p.Artifacts.SaveArtifact(runID, step, a)
+		if err != nil {
+			p.publishEvent("artifact.error", map[string]any{
+				"step": index, "name": a.Name, "error": err.Error(),
+			}, index, 0)
+			continue
+		}
+		refs = append(refs, ref)
 	}
+	return refs
+}
 
-	result.Output = current
+// withArtifacts adds the paths of a step's artifacts to event data.
+func withArtifacts(data map[string]any, refs []ArtifactRef) map[string]any {
+	if len(refs) == 0 {
+		return data
+	}
+	paths := make([]string, len(refs))
+	for i, r := range refs {
+		paths[i] = r.Path
+	}
+	data["artifacts"] = paths
+	return data
+}
 
-	p.publishEvent("pipeline.end", map[string]any{
-		"success":    true,
-		"step_count": len(p.Steps),
-	}, len(p.Steps)-1, 0)
+// StepKey returns the ScopeStep key under which a pipeline run stores a
+// per-step value, so steps of concurrent runs never collide.
+func StepKey(runID, stepID, key string) string {
+	return runID + "/" + stepID + "/" + key
+}
 
-	return result, nil
+func stepID(index int, step PipelineStep) string {
+	if step.ID != "" {
+		return step.ID
+	}
+	return strconv.Itoa(index)
+}
+
+// clearStep removes a finished step's keys from ScopeStep, including any
+// the command wrote under the step's prefix.
+func (p *Pipeline) clearStep(runID, step string) {
+	if p.Context == nil || step == "" {
+		return
+	}
+	items, err := p.Context.List(ScopeStep)
+	if err != nil {
+		return
+	}
+	prefix := StepKey(runID, step, "")
+	for key := range items {
+		if strings.HasPrefix(key, prefix) {
+			p.Context.Delete(ScopeStep, key)
+		}
+	}
+}
+
+// StepCheckpointName is the name of the checkpoint Run saves before step
+// index of the run with the given ID. Pipelines without an ID use names
+// without the prefix.
+func StepCheckpointName(runID string, index int, command string) string {
+	name := fmt.Sprintf("step-%d-%s", index, command)
+	if runID != "" {
+		name = runID + "-" + name
+	}
+	return name
+}
+
+// CompletedSteps returns the leading results of a run that a resume can
+// keep: steps that succeeded, or failed but were skipped by on_error.
+func CompletedSteps(results []StepResult) []StepResult {
+	for i, sr := range results {
+		if sr.Status == "ok" || (sr.Step.OnError == "skip" && (sr.Status == "error" || sr.Status == "verify_failed")) {
+			continue
+		}
+		return results[:i]
+	}
+	return results
+}
+
+// restoreForResume restores the checkpoint saved before the step a resume
+// starts at, undoing whatever the interrupted attempt changed. Without an
+// ID, checkpoint names are not unique to the run and nothing is restored.
+func (p *Pipeline) restoreForResume(start int) {
+	if p.Checkpointer == nil || p.ID == "" {
+		return
+	}
+	name := StepCheckpointName(p.ID, start, p.Steps[start].Command)
+	if err := p.Checkpointer.RestoreCheckpoint(name); err != nil {
+		return // the step had no checkpoint
+	}
+	p.publishEvent("checkpoint.restored", map[string]any{
+		"step": start, "name": name, "reason": "resume",
+	}, start, 0)
+}
+
+func (p *Pipeline) notifyStep(stepIndex int, sr StepResult) {
+	if p.Observer != nil {
+		p.Observer.StepCompleted(stepIndex, sr)
+	}
 }
 
 func (p *Pipeline) publishEvent(eventType string, data any, stepIndex int, duration time.Duration) {
@@ -246,3 +779,24 @@ func (p *Pipeline) publishEvent(eventType string, data any, stepIndex int, durat
 		p.Events.PublishPipelineEvent(eventType, data, stepIndex, duration)
 	}
 }
+
+// rollback restores the checkpoint saved before a step whose verification
+// failed. It reports whether the restore succeeded.
+func (p *Pipeline) rollback(stepIndex int, cpName string) bool {
+	if cpName == "" || p.Checkpointer == nil {
+		p.publishEvent("checkpoint.error", map[string]any{
+			"step": stepIndex, "error": "rollback requested but no checkpoint was saved",
+		}, stepIndex, 0)
+		return false
+	}
+	if err := p.Checkpointer.RestoreCheckpoint(cpName); err != nil {
+		p.publishEvent("checkpoint.error", map[string]any{
+			"step": stepIndex, "error": err.Error(),
+		}, stepIndex, 0)
+		return false
+	}
+	p.publishEvent("checkpoint.restored", map[string]any{
+		"step": stepIndex, "name": cpName,
+	}, stepIndex, 0)
+	return true
+}
@@ -2,7 +2,13 @@ package context
 
 import (
 	gocontext "context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -114,6 +120,36 @@ func TestPipelineEnvelopeFlows(t *testing.T) {
 	}
 }
 
+func TestPipelineStepParams(t *testing.T) {
+	exec := newTestExecutor()
+	exec.Register("emit", func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+		return NewEnvelope("first", "text/plain", "emit"), nil
+	})
+	exec.Register("echo", func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+		m, ok := input.Payload.(map[string]any)
+		if !ok {
+			return Envelope{}, fmt.Errorf("expected map payload, got %T", input.Payload)
+		}
+		return NewEnvelope(m["path"], "text/plain", "echo"), nil
+	})
+
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{Command: "emit"},
+			{Command: "echo", Params: map[string]any{"path": "/tmp/x"}},
+		},
+		Executor: exec,
+	}
+
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "text/plain", "input"))
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if result.Output.Payload != "/tmp/x" {
+		t.Errorf("expected params to replace input payload, got %v", result.Output.Payload)
+	}
+}
+
 func TestPipelineProvenance(t *testing.T) {
 	exec := newTestExecutor()
 	exec.Register("cmd1", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
@@ -172,6 +208,10 @@ func TestPipelineErrorStops(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error")
 	}
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != 0 || stepErr.Command != "fail" {
+		t.Errorf("expected StepError for step 0 (fail), got %#v", err)
+	}
 
 	if result.Success {
 		t.Error("expected failure")
@@ -230,7 +270,7 @@ func TestPipelineEvents(t *testing.T) {
 		Events:   pub,
 	}
 
-	_, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
 	if err != nil {
 		t.Fatalf("Run error: %v", err)
 	}
@@ -246,6 +286,22 @@ func TestPipelineEvents(t *testing.T) {
 			t.Errorf("event %d: expected %s, got %s", i, expected, pub.events[i].Type)
 		}
 	}
+	if _, ok := result.Output.Meta.Tags[PipelineDurationTag]; !ok {
+		t.Errorf("output tags = %v, want the pipeline's duration", result.Output.Meta.Tags)
+	}
+	if _, ok := pub.events[0].Data.(map[string]any)["run_id"]; ok {
+		t.Errorf("pipeline.start of a pipeline without an ID has a run_id: %v", pub.events[0].Data)
+	}
+
+	// A pipeline with an ID names its run.
+	pub.events = nil
+	p.ID = "20261015-143012-3f9a"
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", "")); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if got := pub.events[0].Data.(map[string]any)["run_id"]; got != p.ID {
+		t.Errorf("pipeline.start run_id = %v, want %s", got, p.ID)
+	}
 }
 
 func TestPipelineWithContextStore(t *testing.T) {
@@ -288,6 +344,49 @@ func TestPipelineWithContextStore(t *testing.T) {
 	}
 }
 
+func TestPipelineStepScopeCleanup(t *testing.T) {
+	store := newTestStore(t)
+
+	var seen []map[string]any
+	exec := newTestExecutor()
+	record := func(_ gocontext.Context, _ Envelope, s ContextStore) (Envelope, error) {
+		items, _ := s.List(ScopeStep)
+		seen = append(seen, items)
+		return NewEnvelope("ok", "text/plain", "test"), nil
+	}
+	exec.Register("first", record)
+	exec.Register("second", record)
+
+	p := &Pipeline{
+		ID: "run-a",
+		Steps: []PipelineStep{
+			{Command: "first", Intent: "one"},
+			{ID: "fetch", Command: "second"},
+		},
+		Context:  store,
+		Executor: exec,
+	}
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", "")); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(seen))
+	}
+	if seen[0][StepKey("run-a", "0", "intent")] != "one" {
+		t.Errorf("step 0 scope = %v", seen[0])
+	}
+	// The first step's keys must not leak into the second.
+	if len(seen[1]) != 2 || seen[1][StepKey("run-a", "fetch", "command")] != "second" {
+		t.Errorf("step 1 scope = %v", seen[1])
+	}
+
+	items, _ := store.List(ScopeStep)
+	if len(items) != 0 {
+		t.Errorf("step scope after run = %v, want empty", items)
+	}
+}
+
 func TestPipelineNoExecutor(t *testing.T) {
 	p := &Pipeline{
 		Steps: []PipelineStep{
@@ -390,8 +489,8 @@ func TestPipelineVerificationFails(t *testing.T) {
 	}
 
 	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
-	if err == nil {
-		t.Fatal("expected error from verification failure")
+	if !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("expected ErrVerificationFailed, got %v", err)
 	}
 	if result.Success {
 		t.Error("expected failure")
@@ -404,6 +503,81 @@ func TestPipelineVerificationFails(t *testing.T) {
 	}
 }
 
+// reportingVerifier also reports each step's assertions.
+type reportingVerifier struct {
+	testVerifier
+}
+
+func (v *reportingVerifier) StepAssertions(stepIndex int) any {
+	return []string{fmt.Sprintf("assertion of step %d", stepIndex)}
+}
+
+func TestPipelineVerificationAssertions(t *testing.T) {
+	exec := newTestExecutor()
+	exec.Register("step1", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		return NewEnvelope("bad", "text/plain", "step1"), nil
+	})
+	pub := &testEventPublisher{}
+
+	for _, verifier := range []StepVerifier{
+		&testVerifier{results: map[int]bool{0: false}},
+		&reportingVerifier{testVerifier{results: map[int]bool{0: false}}},
+	} {
+		pub.events = nil
+		p := &Pipeline{Steps: []PipelineStep{{Command: "step1"}}, Executor: exec, Verifier: verifier, Events: pub}
+		p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+
+		var data map[string]any
+		for _, ev := range pub.events {
+			if ev.Type == "verify.result" {
+				data = ev.Data.(map[string]any)
+			}
+		}
+		if data == nil {
+			t.Fatalf("%T: no verify.result event", verifier)
+		}
+		assertions, ok := data["assertions"]
+		if _, reports := verifier.(StepAssertionReporter); reports != ok {
+			t.Errorf("%T: verify.result assertions = %v", verifier, assertions)
+		}
+		if ok && !reflect.DeepEqual(assertions, []string{"assertion of step 0"}) {
+			t.Errorf("assertions = %v", assertions)
+		}
+	}
+}
+
+// blockingVerifier verifies with the run's context, waiting until it is
+// done.
+type blockingVerifier struct {
+	testVerifier
+}
+
+func (v *blockingVerifier) VerifyStepContext(ctx gocontext.Context, _ int, _ Envelope) (bool, string, error) {
+	<-ctx.Done()
+	return false, "", ctx.Err()
+}
+
+func TestPipelineVerificationCancelled(t *testing.T) {
+	exec := newTestExecutor()
+	exec.Register("step1", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		return NewEnvelope("ok", "text/plain", "step1"), nil
+	})
+	p := &Pipeline{
+		Steps:    []PipelineStep{{Command: "step1"}},
+		Executor: exec,
+		Verifier: &blockingVerifier{},
+		Timeout:  20 * time.Millisecond,
+	}
+
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if len(result.Steps) != 1 || result.Steps[0].Status != "error" {
+		t.Errorf("steps = %+v", result.Steps)
+	}
+}
+
 func TestPipelineVerificationSkip(t *testing.T) {
 	exec := newTestExecutor()
 	exec.Register("step1", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
@@ -472,6 +646,125 @@ func TestPipelineCheckpointing(t *testing.T) {
 	}
 }
 
+func TestPipelineRollbackOnVerifyFailure(t *testing.T) {
+	exec := newTestExecutor()
+	exec.Register("write-cmd", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		return NewEnvelope("bad", "text/plain", "write"), nil
+	})
+
+	cp := &testCheckpointer{}
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{Command: "write-cmd", OnVerifyFailure: "rollback"},
+		},
+		Executor:     exec,
+		Verifier:     &testVerifier{results: map[int]bool{0: false}},
+		Checkpointer: cp,
+	}
+
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if err == nil {
+		t.Fatal("expected verification error")
+	}
+	if !strings.Contains(err.Error(), "rolled back") {
+		t.Errorf("error = %q, want rollback mention", err)
+	}
+	// Rollback implies a checkpoint even without CheckpointBefore.
+	if len(cp.saved) != 1 || len(cp.restored) != 1 || cp.restored[0] != cp.saved[0] {
+		t.Errorf("saved = %v, restored = %v", cp.saved, cp.restored)
+	}
+	if !result.Steps[0].RolledBack {
+		t.Error("expected step to be marked rolled back")
+	}
+}
+
+func TestPipelineTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	exec := newTestExecutor()
+	exec.Register("write-cmd", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		return NewEnvelope("written", "text/plain", "write"), nil
+	})
+	// stuck ignores cancellation; the watchdog must stop waiting for it
+	// after the grace period.
+	exec.Register("stuck", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		<-release
+		return Envelope{}, nil
+	})
+
+	cp := &testCheckpointer{}
+	pub := &testEventPublisher{}
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{Command: "write-cmd", CheckpointBefore: true},
+			{Command: "stuck"},
+			{Command: "write-cmd"},
+		},
+		Executor:     exec,
+		Events:       pub,
+		Checkpointer: cp,
+		Timeout:      50 * time.Millisecond,
+		TimeoutGrace: 20 * time.Millisecond,
+		OnTimeout:    "rollback",
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	var se *StepError
+	if !errors.Is(err, ErrTimeout) || !errors.As(err, &se) || se.Step != 1 {
+		t.Fatalf("err = %v, want a timeout at step 1", err)
+	}
+	if len(result.Steps) != 2 || !result.Steps[0].RolledBack || !result.Steps[1].Abandoned {
+		t.Errorf("steps = %+v, want step 0 rolled back and step 1 abandoned", result.Steps)
+	}
+	if len(cp.restored) != 1 || cp.restored[0] != "step-0-write-cmd" {
+		t.Errorf("restored = %v", cp.restored)
+	}
+	last := pub.events[len(pub.events)-1]
+	data, _ := last.Data.(map[string]any)
+	if last.Type != "pipeline.timeout" || data["rolled_back_to"] != "step-0-write-cmd" || !reflect.DeepEqual(data["abandoned"], []int{1}) {
+		t.Errorf("last event = %+v", last)
+	}
+
+	// A run within its deadline is unaffected.
+	p.Steps = p.Steps[:1]
+	p.Timeout = time.Minute
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", "")); err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+func TestPipelineTimeoutGrace(t *testing.T) {
+	// slow stops a little after its context is cancelled, within the grace
+	// period, so the run waits for it before rolling back.
+	var stopped atomic.Bool
+	exec := newTestExecutor()
+	exec.Register("slow", func(ctx gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		stopped.Store(true)
+		return Envelope{}, ctx.Err()
+	})
+
+	cp := &testCheckpointer{}
+	p := &Pipeline{
+		Steps:        []PipelineStep{{Command: "slow", CheckpointBefore: true}},
+		Executor:     exec,
+		Checkpointer: cp,
+		Timeout:      20 * time.Millisecond,
+		TimeoutGrace: time.Second,
+		OnTimeout:    "rollback",
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+	if !stopped.Load() {
+		t.Error("Run returned before the step stopped")
+	}
+	if len(result.Steps) != 1 || result.Steps[0].Abandoned || !result.Steps[0].RolledBack {
+		t.Errorf("steps = %+v, want the step rolled back, not abandoned", result.Steps)
+	}
+}
+
 func TestPipelineEmptySteps(t *testing.T) {
 	exec := newTestExecutor()
 
@@ -493,3 +786,289 @@ func TestPipelineEmptySteps(t *testing.T) {
 		t.Errorf("expected input passthrough, got %q", result.Output.PayloadString())
 	}
 }
+
+// testObserver records completed steps for testing.
+type testObserver struct {
+	statuses []string
+}
+
+func (o *testObserver) StepCompleted(stepIndex int, result StepResult) {
+	o.statuses = append(o.statuses, fmt.Sprintf("%d:%s", stepIndex, result.Status))
+}
+
+func TestPipelineObserver(t *testing.T) {
+	exec := newTestExecutor()
+	exec.Register("ok", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		return NewEnvelope("fine", "text/plain", "ok"), nil
+	})
+	exec.Register("fail", func(_ gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		return Envelope{}, fmt.Errorf("boom")
+	})
+
+	obs := &testObserver{}
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{Command: "ok"},
+			{Command: "fail", OnError: "skip"},
+			{Command: "ok"},
+		},
+		Executor: exec,
+		Observer: obs,
+	}
+
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(nil, "text/plain", "test")); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	want := []string{"0:ok", "1:error", "2:ok"}
+	if fmt.Sprint(obs.statuses) != fmt.Sprint(want) {
+		t.Errorf("observed %v, want %v", obs.statuses, want)
+	}
+}
+
+func TestPipelineArtifacts(t *testing.T) {
+	exec := newTestExecutor()
+	exec.Register("fetch", func(ctx gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		Attach(ctx, Artifact{Name: "response.json", ContentType: "application/json", Data: []byte(`{"raw":true}`)})
+		fmt.Fprintln(Stderr(ctx), "retrying once")
+		return NewEnvelope("parsed", "text/plain", "fetch"), nil
+	})
+	exec.Register("fail", func(ctx gocontext.Context, _ Envelope, _ ContextStore) (Envelope, error) {
+		fmt.Fprintln(Stdout(ctx), "partial output")
+		return Envelope{}, fmt.Errorf("boom")
+	})
+
+	root := t.TempDir()
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{Command: "fetch", ID: "get/page"},
+			{Command: "fail"},
+		},
+		Executor:  exec,
+		Artifacts: DirArtifactStore{Root: root},
+		ID:        "run-1",
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "text/plain", "test"))
+	if err == nil {
+		t.Fatal("expected error from fail step")
+	}
+
+	fetched := result.Steps[0].Artifacts
+	if len(fetched) != 2 || fetched[0].Name != "response.json" || fetched[1].Name != "stderr.log" {
+		t.Fatalf("step 0 artifacts = %+v", fetched)
+	}
+	wantDir := filepath.Join(root, "run-1", "artifacts", "get_page")
+	if filepath.Dir(fetched[0].Path) != wantDir {
+		t.Errorf("artifact path = %s, want under %s", fetched[0].Path, wantDir)
+	}
+	if data, _ := os.ReadFile(fetched[1].Path); string(data) != "retrying once\n" {
+		t.Errorf("stderr.log = %q", data)
+	}
+
+	// A failed step keeps what it captured.
+	failed := result.Steps[1].Artifacts
+	if len(failed) != 1 || failed[0].Name != "stdout.log" {
+		t.Errorf("step 1 artifacts = %+v", failed)
+	}
+
+	// Without an ArtifactStore, attaching is a no-op.
+	p.Artifacts = nil
+	p.Steps = p.Steps[:1]
+	result, err = p.Run(gocontext.Background(), NewEnvelope(nil, "text/plain", "test"))
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if len(result.Steps[0].Artifacts) != 0 {
+		t.Errorf("artifacts without a store: %+v", result.Steps[0].Artifacts)
+	}
+}
+
+func TestPipelineResume(t *testing.T) {
+	var calls []string
+	flakyFails := true
+	exec := newTestExecutor()
+	for _, name := range []string{"fetch", "flaky", "write"} {
+		name := name
+		exec.Register(name, func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+			calls = append(calls, name)
+			if name == "flaky" && flakyFails {
+				return Envelope{}, fmt.Errorf("connection reset")
+			}
+			return NewEnvelope(input.PayloadString()+">"+name, "text/plain", name), nil
+		})
+	}
+	steps := []PipelineStep{
+		{Command: "fetch"},
+		{Command: "flaky", CheckpointBefore: true},
+		{Command: "write"},
+	}
+
+	cp := &testCheckpointer{}
+	first := &Pipeline{Steps: steps, Executor: exec, Checkpointer: cp, ID: "run-7"}
+	failed, err := first.Run(gocontext.Background(), NewEnvelope("in", "text/plain", "test"))
+	if err == nil {
+		t.Fatal("expected the first attempt to fail")
+	}
+	if cp.saved[0] != "run-7-step-1-flaky" {
+		t.Errorf("checkpoint name = %q, want run-7-step-1-flaky", cp.saved[0])
+	}
+
+	done := CompletedSteps(failed.Steps)
+	if len(done) != 1 || done[0].Step.Command != "fetch" {
+		t.Fatalf("CompletedSteps = %+v", done)
+	}
+
+	calls, flakyFails = nil, false
+	resumed := &Pipeline{Steps: steps, Executor: exec, Checkpointer: cp, ID: "run-7", Resume: done}
+	result, err := resumed.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if fmt.Sprint(calls) != "[flaky write]" {
+		t.Errorf("resume ran %v, want [flaky write]", calls)
+	}
+	if len(result.Steps) != 3 || result.Output.PayloadString() != "in>fetch>flaky>write" {
+		t.Errorf("resumed result: %d steps, output %q", len(result.Steps), result.Output.PayloadString())
+	}
+	if len(cp.restored) != 1 || cp.restored[0] != "run-7-step-1-flaky" {
+		t.Errorf("restored = %v, want the checkpoint before the resumed step", cp.restored)
+	}
+}
+
+func TestPipelineStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	exec := newTestExecutor()
+	exec.Register("first", func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+		cancel()
+		return input, nil
+	})
+	exec.Register("second", func(_ gocontext.Context, input Envelope, _ ContextStore) (Envelope, error) {
+		t.Error("second step ran after cancellation")
+		return input, nil
+	})
+	p := &Pipeline{Steps: []PipelineStep{{Command: "first"}, {Command: "second"}}, Executor: exec}
+	result, err := p.Run(ctx, NewEnvelope("in", "text/plain", "test"))
+	var se *StepError
+	if !errors.As(err, &se) || se.Step != 1 || !errors.Is(err, gocontext.Canceled) {
+		t.Fatalf("err = %v, want a StepError for step 1 wrapping context.Canceled", err)
+	}
+	if len(CompletedSteps(result.Steps)) != 1 {
+		t.Errorf("completed steps = %d, want 1", len(CompletedSteps(result.Steps)))
+	}
+}
+
+// testSpecRunner runs every used spec as a single "inner" step.
+type testSpecRunner struct {
+	fail   bool
+	params map[string]string
+}
+
+func (r *testSpecRunner) RunSpec(_ gocontext.Context, path string, params map[string]string, input Envelope) (PipelineResult, error) {
+	r.params = params
+	inner := StepResult{Step: PipelineStep{Command: "inner"}, Status: "ok", Output: NewEnvelope(path+"("+input.PayloadString()+")", "text/plain", "inner")}
+	if r.fail {
+		return PipelineResult{Steps: []StepResult{inner}}, fmt.Errorf("%w: 0/1 assertions passed", ErrVerificationFailed)
+	}
+	return PipelineResult{Steps: []StepResult{inner}, Success: true, Output: inner.Output}, nil
+}
+
+func TestPipelineUses(t *testing.T) {
+	runner := &testSpecRunner{}
+	p := &Pipeline{
+		Steps:    []PipelineStep{{Command: "spec:run", Uses: "summarize.yaml", With: map[string]string{"style": "short"}}},
+		Executor: newTestExecutor(),
+		Specs:    runner,
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope("in", "text/plain", "test"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Output.PayloadString() != "summarize.yaml(in)" || runner.params["style"] != "short" {
+		t.Errorf("output = %q, params = %v", result.Output.PayloadString(), runner.params)
+	}
+	sr := result.Steps[0]
+	if len(sr.Children) != 1 || sr.Children[0].Step.Command != "inner" {
+		t.Errorf("children = %+v", sr.Children)
+	}
+	if n := len(result.Output.Provenance); n == 0 || result.Output.Provenance[n-1].Command != "spec:run" {
+		t.Errorf("provenance = %+v, want the step folded in last", result.Output.Provenance)
+	}
+
+	runner.fail = true
+	result, err = p.Run(gocontext.Background(), NewEnvelope("in", "text/plain", "test"))
+	if !errors.Is(err, ErrVerificationFailed) || len(result.Steps[0].Children) != 1 {
+		t.Errorf("err = %v, children = %d; want a verification failure with the nested steps", err, len(result.Steps[0].Children))
+	}
+
+	p.Specs = nil
+	if _, err := p.Run(gocontext.Background(), NewEnvelope("in", "text/plain", "test")); err == nil {
+		t.Error("expected an error without a SpecRunner")
+	}
+}
+
+// testAsker answers every question with answer and records the questions.
+type testAsker struct {
+	answer string
+	asked  []Question
+}
+
+func (a *testAsker) Ask(_ gocontext.Context, q Question) (string, error) {
+	a.asked = append(a.asked, q)
+	return a.answer, nil
+}
+
+func TestPipelineAsk(t *testing.T) {
+	store := newTestStore(t)
+	asker := &testAsker{answer: " "}
+	p := &Pipeline{
+		Steps: []PipelineStep{
+			{ID: "confirm", Command: "human:ask", Ask: &Question{Prompt: "Ship it?", Choices: []string{"yes", "no"}, Default: "no"}},
+			{ID: "budget", Command: "human:ask", Ask: &Question{Prompt: "Budget?", Key: "budget"}},
+		},
+		Context:  store,
+		Executor: newTestExecutor(),
+		Asker:    asker,
+	}
+	result, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", ""))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(asker.asked) != 2 || asker.asked[0].Step != "confirm" || asker.asked[0].Key != "ask.confirm" {
+		t.Errorf("asked %+v", asker.asked)
+	}
+	// An empty answer takes the default.
+	if got := result.Steps[0].Output.PayloadString(); got != "no" {
+		t.Errorf("confirm output = %q, want the default", got)
+	}
+	if v, _ := store.Get(ScopeSession, "ask.confirm"); v != "no" {
+		t.Errorf("ask.confirm = %v", v)
+	}
+	if v, _ := store.Get(ScopeSession, "budget"); v != "" {
+		t.Errorf("budget = %v, want the empty answer", v)
+	}
+
+	asker.answer = "maybe"
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", "")); err == nil || !strings.Contains(err.Error(), "not one of yes, no") {
+		t.Errorf("err = %v, want an invalid choice", err)
+	}
+
+	p.Asker = nil
+	if _, err := p.Run(gocontext.Background(), NewEnvelope(nil, "", "")); err == nil || !strings.Contains(err.Error(), "no one can answer") {
+		t.Errorf("err = %v, want no asker", err)
+	}
+}
+
+func TestCompletedSteps(t *testing.T) {
+	results := []StepResult{
+		{Status: "ok"},
+		{Status: "error", Step: PipelineStep{OnError: "skip"}},
+		{Status: "verify_failed"},
+		{Status: "ok"},
+	}
+	if got := len(CompletedSteps(results)); got != 2 {
+		t.Errorf("CompletedSteps kept %d steps, want 2", got)
+	}
+	if got := len(CompletedSteps(results[:2])); got != 2 {
+		t.Errorf("CompletedSteps of a finished prefix kept %d steps, want 2", got)
+	}
+}
@@ -0,0 +1,231 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Well-known keys of the project scope.
+const (
+	KeySpecName   = "spec_name"
+	KeyGoal       = "goal"
+	KeyOutputPath = "output_path"
+	KeyBudgets    = "budgets"
+)
+
+// KeySchema declares a well-known context key and the JSON type of its
+// value: "string", "number", "integer", "boolean", "array" or "object".
+type KeySchema struct {
+	Scope       string               `json:"scope,omitempty"`
+	Key         string               `json:"key,omitempty"`
+	Type        string               `json:"type"`
+	Description string               `json:"description,omitempty"`
+	Fields      map[string]KeySchema `json:"fields,omitempty"` // of an object
+}
+
+// Schema lists the well-known keys. Writes to them are checked against
+// their type; other keys hold anything.
+var Schema = []KeySchema{
+	{Scope: ScopeProject, Key: KeySpecName, Type: "string", Description: "Name of the spec being run"},
+	{Scope: ScopeProject, Key: KeyGoal, Type: "string", Description: "Goal of the spec being run"},
+	{Scope: ScopeProject, Key: KeyOutputPath, Type: "string", Description: "Where the spec writes its output"},
+	{Scope: ScopeProject, Key: KeyBudgets, Type: "object", Description: "Limits of the current run", Fields: map[string]KeySchema{
+		"max_duration": {Type: "string", Description: "Deadline for the whole run, e.g. 30m"},
+	}},
+}
+
+// LookupKey returns the schema of a well-known key.
+func LookupKey(scope, key string) (KeySchema, bool) {
+	for _, ks := range Schema {
+		if ks.Scope == scope && ks.Key == key {
+			return ks, true
+		}
+	}
+	return KeySchema{}, false
+}
+
+// checkValue reports whether data, a stored JSON value, has the type the
+// schema declares for scope/key.
+func checkValue(scope, key string, data []byte) error {
+	ks, ok := LookupKey(scope, key)
+	if !ok {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := ks.check(v); err != nil {
+		return fmt.Errorf("%s/%s: %w", scope, key, err)
+	}
+	return nil
+}
+
+func (ks KeySchema) check(v any) error {
+	if !hasType(v, ks.Type) {
+		return fmt.Errorf("expected %s, got %s", ks.Type, jsonType(v))
+	}
+	obj, _ := v.(map[string]any)
+	for name, field := range ks.Fields {
+		fv, ok := obj[name]
+		if !ok || fv == nil {
+			continue
+		}
+		if err := field.check(fv); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func hasType(v any, typ string) bool {
+	switch typ {
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == float64(int64(n))
+	case "number", "string", "boolean", "array", "object":
+		return jsonType(v) == typ
+	}
+	return true
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// KeyInfo describes a key of the store for context.describe.
+type KeyInfo struct {
+	KeySchema
+	WellKnown bool `json:"well_known"` // declared in Schema
+	Set       bool `json:"set"`        // currently has a value
+}
+
+// Describe lists the well-known keys and the keys currently set in scope,
+// or in the project and session scopes when scope is empty. Keys outside
+// the schema are described by the type of their current value.
+func Describe(store ContextStore, scope string) ([]KeyInfo, error) {
+	scopes := historyScopes
+	if scope != "" {
+		scopes = []string{scope}
+	}
+	var infos []KeyInfo
+	for _, sc := range scopes {
+		items, err := store.List(sc)
+		if err != nil {
+			return nil, fmt.Errorf("list scope %s: %w", sc, err)
+		}
+		for _, ks := range Schema {
+			if ks.Scope == sc {
+				_, set := items[ks.Key]
+				infos = append(infos, KeyInfo{KeySchema: ks, WellKnown: true, Set: set})
+			}
+		}
+		for key, v := range items {
+			if _, ok := LookupKey(sc, key); !ok {
+				infos = append(infos, KeyInfo{KeySchema: KeySchema{Scope: sc, Key: key, Type: jsonType(v)}, Set: true})
+			}
+		}
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].Scope != infos[j].Scope {
+			return infos[i].Scope < infos[j].Scope
+		}
+		return infos[i].Key < infos[j].Key
+	})
+	return infos, nil
+}
+
+// GetAs reads a key into a value of type T.
+func GetAs[T any](store ContextStore, scope, key string) (T, error) {
+	var out T
+	v, err := store.Get(scope, key)
+	if err != nil {
+		return out, err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("%s/%s: %w", scope, key, err)
+	}
+	return out, nil
+}
+
+// Project holds the well-known keys of the project scope.
+type Project struct {
+	SpecName   string   `json:"spec_name,omitempty"`
+	Goal       string   `json:"goal,omitempty"`
+	OutputPath string   `json:"output_path,omitempty"`
+	Budgets    *Budgets `json:"budgets,omitempty"`
+}
+
+// Budgets are the limits of the current run.
+type Budgets struct {
+	MaxDuration string `json:"max_duration,omitempty"`
+}
+
+// LoadProject reads the well-known project keys; unset keys are left
+// empty.
+func LoadProject(store ContextStore) (Project, error) {
+	var p Project
+	items, err := store.List(ScopeProject)
+	if err != nil {
+		return p, err
+	}
+	for key := range items {
+		if _, ok := LookupKey(ScopeProject, key); !ok {
+			delete(items, key)
+		}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("project context: %w", err)
+	}
+	return p, nil
+}
+
+// SaveProject writes the well-known project keys of p, replacing those
+// of an earlier project; empty fields are deleted.
+func SaveProject(store ContextStore, p Project) error {
+	values := []struct {
+		key   string
+		value any
+		set   bool
+	}{
+		{KeySpecName, p.SpecName, p.SpecName != ""},
+		{KeyGoal, p.Goal, p.Goal != ""},
+		{KeyOutputPath, p.OutputPath, p.OutputPath != ""},
+		{KeyBudgets, p.Budgets, p.Budgets != nil},
+	}
+	for _, v := range values {
+		var err error
+		if v.set {
+			err = store.Set(ScopeProject, v.key, v.value)
+		} else {
+			err = store.Delete(ScopeProject, v.key)
+		}
+		if err != nil {
+			return fmt.Errorf("save project %s: %w", v.key, err)
+		}
+	}
+	return nil
+}
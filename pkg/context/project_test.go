@@ -0,0 +1,65 @@
+package context
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProjectKeys(t *testing.T) {
+	store := newTestStore(t)
+
+	p := Project{SpecName: "weekly", Goal: "report", OutputPath: "out.md", Budgets: &Budgets{MaxDuration: "30m"}}
+	if err := SaveProject(store, p); err != nil {
+		t.Fatalf("SaveProject: %v", err)
+	}
+	store.Set(ScopeProject, "team", []string{"a", "b"})
+	got, err := LoadProject(store)
+	if err != nil || !reflect.DeepEqual(got, p) {
+		t.Fatalf("LoadProject = %+v, %v", got, err)
+	}
+	if b, err := GetAs[Budgets](store, ScopeProject, KeyBudgets); err != nil || b.MaxDuration != "30m" {
+		t.Errorf("GetAs = %+v, %v", b, err)
+	}
+
+	// A later project without budgets drops the old ones.
+	SaveProject(store, Project{SpecName: "daily"})
+	if got, _ := LoadProject(store); got.Budgets != nil || got.Goal != "" {
+		t.Errorf("project after resave = %+v", got)
+	}
+
+	for _, tc := range []struct {
+		key   string
+		value any
+		want  string
+	}{
+		{KeyGoal, 3, "expected string, got number"},
+		{KeyBudgets, "30m", "expected object, got string"},
+		{KeyBudgets, map[string]any{"max_duration": 30}, "max_duration: expected string"},
+	} {
+		err := store.Set(ScopeProject, tc.key, tc.value)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Set %s = %v: %v, want %q", tc.key, tc.value, err, tc.want)
+		}
+	}
+	// Other scopes are not checked.
+	if err := store.Set(ScopeSession, KeyGoal, 3); err != nil {
+		t.Errorf("session goal: %v", err)
+	}
+
+	infos, err := Describe(store, ScopeProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, info := range infos {
+		keys = append(keys, info.Key+":"+info.Type)
+		if info.Key == KeyGoal && info.Set {
+			t.Error("unset goal described as set")
+		}
+	}
+	want := []string{"budgets:object", "goal:string", "output_path:string", "spec_name:string", "team:array"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("Describe keys = %v, want %v", keys, want)
+	}
+}
@@ -3,7 +3,6 @@ package context
 import (
 	"encoding/json"
 	"fmt"
-	"sync"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -14,7 +13,7 @@ const (
 	ScopeProject = "project"  // goals, constraints, guidelines (loaded from config)
 	ScopeSession = "session"  // current session state, working memory
 	ScopeStep    = "step"     // current pipeline step context (ephemeral)
-	ScopeHistory = "history"  // append-only log of all operations
+	ScopeHistory = "history"  // append-only log of context changes
 )
 
 // ContextStore provides scoped key-value storage for pipeline state.
@@ -26,22 +25,33 @@ type ContextStore interface {
 	Close() error
 }
 
-// BoltStore is a bbolt-backed implementation of ContextStore.
+// BoltStore is a bbolt-backed implementation of ContextStore. It is safe
+// for concurrent use: bbolt serializes writers and lets readers proceed.
+// Only one process can open the file; internal/storeserver shares it
+// between processes.
 type BoltStore struct {
-	db *bolt.DB
-	mu sync.RWMutex
+	db           *bolt.DB
+	historyLimit int // latest history entries kept; 0 keeps all
+	feed         ChangeFeed
 }
 
 // NewBoltStore creates a new bbolt-backed context store at the given path.
 func NewBoltStore(path string) (*BoltStore, error) {
-	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	return OpenBoltStore(path, 1*time.Second)
+}
+
+// OpenBoltStore opens the store, waiting up to timeout for another process
+// to release the file. A file still held then fails with bbolt's
+// ErrTimeout.
+func OpenBoltStore(path string, timeout time.Duration) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: timeout})
 	if err != nil {
 		return nil, fmt.Errorf("open bolt db: %w", err)
 	}
 
 	// Pre-create scope buckets.
 	err = db.Update(func(tx *bolt.Tx) error {
-		for _, scope := range []string{ScopeProject, ScopeSession, ScopeStep, ScopeHistory} {
+		for _, scope := range Scopes {
 			if _, err := tx.CreateBucketIfNotExists([]byte(scope)); err != nil {
 				return fmt.Errorf("create bucket %s: %w", scope, err)
 			}
@@ -57,9 +67,6 @@ func NewBoltStore(path string) (*BoltStore, error) {
 }
 
 func (s *BoltStore) Get(scope, key string) (any, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
 	var result any
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(scope))
@@ -79,39 +86,86 @@ func (s *BoltStore) Get(scope, key string) (any, error) {
 }
 
 func (s *BoltStore) Set(scope, key string, value any) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.SetFrom("", scope, key, value)
+}
+
+// SetFrom sets a key, recording source as the changer in the history. The
+// history scope itself is append-only: its keys cannot be overwritten.
+func (s *BoltStore) SetFrom(source, scope, key string, value any) error {
+	_, err := s.set(source, scope, key, value, nil)
+	return err
+}
 
-	return s.db.Update(func(tx *bolt.Tx) error {
+// set stores value and returns its version. With expected, the key's
+// current version must match it.
+func (s *BoltStore) set(source, scope, key string, value any, expected *string) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("marshal value: %w", err)
+	}
+	if err := checkValue(scope, key, data); err != nil {
+		return "", err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(scope))
 		if b == nil {
 			return fmt.Errorf("scope not found: %s", scope)
 		}
-		data, err := json.Marshal(value)
-		if err != nil {
-			return fmt.Errorf("marshal value: %w", err)
+		old := b.Get([]byte(key))
+		if scope == ScopeHistory && old != nil {
+			return fmt.Errorf("history is append-only: %s exists", key)
+		}
+		if expected != nil && *expected != hashValue(old) {
+			return &ConflictError{Scope: scope, Key: key, Expected: *expected, Actual: hashValue(old)}
 		}
-		return b.Put([]byte(key), data)
+		entry := HistoryEntry{Scope: scope, Key: key, Op: "set", OldHash: hashValue(old), NewHash: hashValue(data), Source: source}
+		if err := b.Put([]byte(key), data); err != nil {
+			return err
+		}
+		return s.recordChange(tx, entry)
 	})
+	if err != nil {
+		return "", err
+	}
+	s.notify(source, scope, key, "set", data)
+	return hashValue(data), nil
 }
 
 func (s *BoltStore) Delete(scope, key string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.DeleteFrom("", scope, key)
+}
 
-	return s.db.Update(func(tx *bolt.Tx) error {
+// DeleteFrom deletes a key, recording source as the changer in the
+// history. History entries cannot be deleted.
+func (s *BoltStore) DeleteFrom(source, scope, key string) error {
+	if scope == ScopeHistory {
+		return fmt.Errorf("history is append-only: cannot delete %s", key)
+	}
+	deleted := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(scope))
 		if b == nil {
 			return fmt.Errorf("scope not found: %s", scope)
 		}
-		return b.Delete([]byte(key))
+		old := b.Get([]byte(key))
+		if old == nil {
+			return nil
+		}
+		deleted = true
+		entry := HistoryEntry{Scope: scope, Key: key, Op: "delete", OldHash: hashValue(old), Source: source}
+		if err := b.Delete([]byte(key)); err != nil {
+			return err
+		}
+		return s.recordChange(tx, entry)
 	})
+	if err != nil || !deleted {
+		return err
+	}
+	s.notify(source, scope, key, "delete", nil)
+	return nil
 }
 
 func (s *BoltStore) List(scope string) (map[string]any, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
 	result := make(map[string]any)
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(scope))
@@ -134,5 +188,6 @@ func (s *BoltStore) List(scope string) (map[string]any, error) {
 }
 
 func (s *BoltStore) Close() error {
+	s.feed.CloseAll()
 	return s.db.Close()
 }
@@ -0,0 +1,50 @@
+package context
+
+import "slices"
+
+// Tainted reports whether the payload derives from an untrusted source.
+func (e *Envelope) Tainted() bool {
+	return len(e.Meta.Taint) > 0
+}
+
+// FieldTainted reports whether the top-level payload field may carry
+// untrusted data.
+func (e *Envelope) FieldTainted(field string) bool {
+	if !e.Tainted() {
+		return false
+	}
+	return len(e.Meta.TaintedFields) == 0 || slices.Contains(e.Meta.TaintedFields, field)
+}
+
+// AddTaint marks the whole payload as deriving from the untrusted sources.
+func (e *Envelope) AddTaint(sources ...string) {
+	e.Meta.Taint = addTaint(e.Meta.Taint, sources)
+	if len(e.Meta.Taint) > 0 {
+		e.Meta.TaintedFields = nil
+	}
+}
+
+// taintField marks one top-level field of the payload as carrying the taint
+// of from. Fields of a payload that is already tainted throughout stay so.
+func (e *Envelope) taintField(field string, from Envelope) {
+	if !from.Tainted() {
+		return
+	}
+	whole := e.Tainted() && len(e.Meta.TaintedFields) == 0
+	e.Meta.Taint = addTaint(e.Meta.Taint, from.Meta.Taint)
+	if !whole && !slices.Contains(e.Meta.TaintedFields, field) {
+		e.Meta.TaintedFields = append(e.Meta.TaintedFields, field)
+	}
+}
+
+// addTaint returns list with the sources it lacks appended. The list is
+// copied first, since envelopes share it when they are copied.
+func addTaint(list, sources []string) []string {
+	list = slices.Clone(list)
+	for _, s := range sources {
+		if s != "" && !slices.Contains(list, s) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
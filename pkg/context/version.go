@@ -0,0 +1,73 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// VersionedStore is implemented by stores that support optimistic
+// concurrency. A value's version is the hash of its stored JSON, the same
+// hash the history records; a missing key has version "". Two writes of an
+// equal value therefore share a version.
+type VersionedStore interface {
+	// GetVersioned returns a key's value and version.
+	GetVersioned(scope, key string) (any, string, error)
+	// CompareAndSet sets a key only if its version is still version, and
+	// returns the new version. A mismatch fails with a *ConflictError.
+	CompareAndSet(source, scope, key string, value any, version string) (string, error)
+}
+
+// ConflictError reports a CompareAndSet whose key changed since the caller
+// read it.
+type ConflictError struct {
+	Scope    string
+	Key      string
+	Expected string // version the caller read
+	Actual   string // version found; "" if the key no longer exists
+}
+
+func (e *ConflictError) Error() string {
+	actual := e.Actual
+	if actual == "" {
+		actual = "no value"
+	}
+	return fmt.Sprintf("version conflict on %s/%s: expected %q, found %s", e.Scope, e.Key, e.Expected, actual)
+}
+
+// ValueVersion returns the version value has once stored.
+func ValueVersion(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return hashValue(data)
+}
+
+func (s *BoltStore) GetVersioned(scope, key string) (any, string, error) {
+	var (
+		result  any
+		version string
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(scope))
+		if b == nil {
+			return fmt.Errorf("scope not found: %s", scope)
+		}
+		data := b.Get([]byte(key))
+		if data == nil {
+			return fmt.Errorf("key not found: %s/%s", scope, key)
+		}
+		version = hashValue(data)
+		return json.Unmarshal(data, &result)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return result, version, nil
+}
+
+func (s *BoltStore) CompareAndSet(source, scope, key string, value any, version string) (string, error) {
+	return s.set(source, scope, key, value, &version)
+}
@@ -0,0 +1,38 @@
+package context
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompareAndSet(t *testing.T) {
+	store := newTestStore(t)
+
+	// "" creates a key only if it does not exist.
+	v1, err := store.CompareAndSet("", ScopeSession, "count", 1, "")
+	if err != nil || v1 != ValueVersion(1) {
+		t.Fatalf("create = %q, %v", v1, err)
+	}
+	if _, err := store.CompareAndSet("", ScopeSession, "count", 1, ""); err == nil {
+		t.Error("second create succeeded")
+	}
+
+	val, version, err := store.GetVersioned(ScopeSession, "count")
+	if err != nil || val != float64(1) || version != v1 {
+		t.Fatalf("GetVersioned = %v, %q, %v", val, version, err)
+	}
+	v2, err := store.CompareAndSet("agent", ScopeSession, "count", 2, version)
+	if err != nil || v2 == v1 {
+		t.Fatalf("update = %q, %v", v2, err)
+	}
+
+	// A writer still holding v1 loses.
+	_, err = store.CompareAndSet("agent", ScopeSession, "count", 3, v1)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != v1 || conflict.Actual != v2 {
+		t.Fatalf("stale update: %v", err)
+	}
+	if v, _ := store.Get(ScopeSession, "count"); v != float64(2) {
+		t.Errorf("value after conflict = %v", v)
+	}
+}
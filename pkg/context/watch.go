@@ -0,0 +1,132 @@
+package context
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Change describes one change to a context key, as delivered to watchers.
+type Change struct {
+	Scope  string    `json:"scope"`
+	Key    string    `json:"key"`
+	Op     string    `json:"op"`              // "set" or "delete"
+	Value  any       `json:"value,omitempty"` // the new value of a set
+	Source string    `json:"source,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// WatchFilter selects the changes a watcher receives. The zero value
+// selects every change.
+type WatchFilter struct {
+	Scopes []string `json:"scopes,omitempty"` // empty = all
+	Keys   []string `json:"keys,omitempty"`   // key patterns (path.Match); empty = all
+}
+
+// Matches reports whether c is selected by f.
+func (f WatchFilter) Matches(c Change) bool {
+	if len(f.Scopes) > 0 && !slices.Contains(f.Scopes, c.Scope) {
+		return false
+	}
+	return len(f.Keys) == 0 || matchKey(f.Keys, c.Key)
+}
+
+// Watchable is implemented by stores that report their changes. Changes
+// are sent after they are committed; a watcher that falls behind misses
+// changes rather than blocking writers.
+type Watchable interface {
+	Watch(f WatchFilter) <-chan Change
+	Unwatch(ch <-chan Change)
+}
+
+type watcher struct {
+	ch     chan Change
+	filter WatchFilter
+}
+
+// ChangeFeed fans changes out to watchers. The zero value is ready to use.
+type ChangeFeed struct {
+	mu       sync.Mutex
+	watchers []watcher
+}
+
+// Watch adds a watcher of the changes filter selects.
+func (f *ChangeFeed) Watch(filter WatchFilter) <-chan Change {
+	ch := make(chan Change, 64)
+	f.mu.Lock()
+	f.watchers = append(f.watchers, watcher{ch: ch, filter: filter})
+	f.mu.Unlock()
+	return ch
+}
+
+// Unwatch removes a watcher and closes its channel.
+func (f *ChangeFeed) Unwatch(ch <-chan Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, w := range f.watchers {
+		if w.ch == ch {
+			f.watchers = append(f.watchers[:i], f.watchers[i+1:]...)
+			close(w.ch)
+			return
+		}
+	}
+}
+
+// Active reports whether anyone is watching, so callers can skip building
+// changes nobody receives.
+func (f *ChangeFeed) Active() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.watchers) > 0
+}
+
+// Notify sends c to the watchers it matches.
+func (f *ChangeFeed) Notify(c Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, w := range f.watchers {
+		if !w.filter.Matches(c) {
+			continue
+		}
+		select {
+		case w.ch <- c:
+		default:
+			// Drop the change if the watcher is slow; avoids blocking writers.
+		}
+	}
+}
+
+// CloseAll ends every watch.
+func (f *ChangeFeed) CloseAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, w := range f.watchers {
+		close(w.ch)
+	}
+	f.watchers = nil
+}
+
+// Watch returns a channel receiving the changes f selects, until Unwatch
+// or Close.
+func (s *BoltStore) Watch(f WatchFilter) <-chan Change {
+	return s.feed.Watch(f)
+}
+
+// Unwatch stops a watch and closes its channel.
+func (s *BoltStore) Unwatch(ch <-chan Change) {
+	s.feed.Unwatch(ch)
+}
+
+// notify reports a committed change to the store's watchers. data is the
+// stored JSON of a set's new value.
+func (s *BoltStore) notify(source, scope, key, op string, data []byte) {
+	if !s.feed.Active() {
+		return
+	}
+	c := Change{Scope: scope, Key: key, Op: op, Source: source, Time: time.Now().UTC()}
+	if data != nil {
+		json.Unmarshal(data, &c.Value)
+	}
+	s.feed.Notify(c)
+}
@@ -0,0 +1,58 @@
+package context
+
+import (
+	"testing"
+	"time"
+)
+
+func nextChange(t *testing.T, ch <-chan Change) Change {
+	t.Helper()
+	select {
+	case c, ok := <-ch:
+		if !ok {
+			t.Fatal("watch closed")
+		}
+		return c
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change received")
+	}
+	return Change{}
+}
+
+func TestWatch(t *testing.T) {
+	store := newTestStore(t)
+	all := store.Watch(WatchFilter{})
+	reports := store.Watch(WatchFilter{Scopes: []string{ScopeSession}, Keys: []string{"report.*"}})
+
+	WithSource(store, "fs:write").Set(ScopeSession, "report.title", "weekly")
+	store.Set(ScopeProject, "goal", "ship")
+	store.Delete(ScopeSession, "report.title")
+	store.Delete(ScopeSession, "missing") // not a change
+
+	c := nextChange(t, reports)
+	if c.Scope != ScopeSession || c.Key != "report.title" || c.Op != "set" || c.Value != "weekly" || c.Source != "fs:write" {
+		t.Errorf("first change = %+v", c)
+	}
+	if c := nextChange(t, reports); c.Op != "delete" || c.Value != nil {
+		t.Errorf("second change = %+v", c)
+	}
+	for _, key := range []string{"report.title", "goal", "report.title"} {
+		if c := nextChange(t, all); c.Key != key {
+			t.Errorf("change to %s, want %s", c.Key, key)
+		}
+	}
+	select {
+	case c := <-all:
+		t.Errorf("unexpected change %+v", c)
+	default:
+	}
+
+	store.Unwatch(reports)
+	if _, ok := <-reports; ok {
+		t.Error("watch not closed by Unwatch")
+	}
+	store.Close()
+	if _, ok := <-all; ok {
+		t.Error("watch not closed by Close")
+	}
+}
@@ -128,3 +128,24 @@ func TestNewEvent(t *testing.T) {
 		t.Error("expected timestamp to be set")
 	}
 }
+
+func TestMatchType(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		typ      EventType
+		want     bool
+	}{
+		{nil, EventCommandStart, true},
+		{[]string{"command.start"}, EventCommandStart, true},
+		{[]string{"command.start"}, EventCommandEnd, false},
+		{[]string{"verify.*"}, EventVerifyResult, true},
+		{[]string{"verify.*"}, EventCommandEnd, false},
+		{[]string{"plan.*", "*"}, EventAgentMessage, true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchType(tt.patterns, tt.typ); got != tt.want {
+			t.Errorf("MatchType(%v, %q) = %v, want %v", tt.patterns, tt.typ, got, tt.want)
+		}
+	}
+}
@@ -1,28 +1,41 @@
 package events
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // EventType identifies the kind of event emitted by the runtime.
 type EventType string
 
 const (
-	EventCommandStart      EventType = "command.start"
-	EventCommandEnd        EventType = "command.end"
-	EventCommandError      EventType = "command.error"
-	EventPipelineStart     EventType = "pipeline.start"
-	EventPipelineEnd       EventType = "pipeline.end"
-	EventPipelineStep      EventType = "pipeline.step"
-	EventVerifyStart       EventType = "verify.start"
-	EventVerifyResult      EventType = "verify.result"
-	EventCheckpointSave    EventType = "checkpoint.save"
-	EventCheckpointRestore EventType = "checkpoint.restore"
-	EventContextChange     EventType = "context.change"
-	EventPlanGenerated     EventType = "plan.generated"
-	EventPlanApproval      EventType = "plan.approval_requested"
-	EventPlanApproved      EventType = "plan.approved"
-	EventPlanRejected      EventType = "plan.rejected"
-	EventSpecLoaded        EventType = "spec.loaded"
-	EventAgentMessage      EventType = "agent.message"
+	EventCommandStart       EventType = "command.start"
+	EventCommandEnd         EventType = "command.end"
+	EventCommandError       EventType = "command.error"
+	EventPipelineStart      EventType = "pipeline.start"
+	EventPipelineEnd        EventType = "pipeline.end"
+	EventPipelineStep       EventType = "pipeline.step"
+	EventPipelineTimeout    EventType = "pipeline.timeout"
+	EventVerifyStart        EventType = "verify.start"
+	EventVerifyResult       EventType = "verify.result"
+	EventVerifyDrift        EventType = "verify.drift"         // a step did something its declaration rules out
+	EventVerifyOutputSchema EventType = "verify.output_schema" // a step's output was checked against its schema
+	EventCheckpointSave     EventType = "checkpoint.save"
+	EventCheckpointRestore  EventType = "checkpoint.restore"
+	EventContextChange      EventType = "context.change"
+	EventPlanGenerated      EventType = "plan.generated"
+	EventPlanApproval       EventType = "plan.approval_requested"
+	EventPlanApproved       EventType = "plan.approved"
+	EventPlanRejected       EventType = "plan.rejected"
+	EventSpecLoaded         EventType = "spec.loaded"
+	EventAgentMessage       EventType = "agent.message"
+	EventConfigReloaded     EventType = "config.reloaded"
+	EventCommandDeprecated  EventType = "command.deprecated"
+	EventFSChanged          EventType = "fs.changed"
+	EventInputRequested     EventType = "input.requested" // an ask step waits for an answer
+	EventInputAnswered      EventType = "input.answered"
+	EventPolicyDenied       EventType = "policy.denied" // a policy rule stopped a command
+	EventTaintBlocked       EventType = "taint.blocked" // untrusted data reached a taint sink
 )
 
 // Event represents a single runtime event.
@@ -42,3 +55,21 @@ func NewEvent(typ EventType, data any) Event {
 		Data:      data,
 	}
 }
+
+// MatchType reports whether an event type matches any of the given patterns.
+// A pattern is an exact type or a prefix ending in "*" (e.g. "verify.*").
+// An empty pattern list matches every event.
+func MatchType(patterns []string, typ EventType) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if p == "*" || p == string(typ) {
+			return true
+		}
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(string(typ), strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
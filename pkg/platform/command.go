@@ -2,6 +2,8 @@ package platform
 
 import (
 	gocontext "context"
+	"fmt"
+	"strings"
 
 	agshctx "github.com/cgast/agsh/pkg/context"
 )
@@ -33,6 +35,109 @@ type Schema struct {
 
 // SchemaField describes a single field within a schema.
 type SchemaField struct {
-	Type        string `json:"type"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Aliases     []string `json:"aliases,omitempty"` // other fields accepted in its place
+}
+
+// JSONSchema renders the schema as a standard JSON Schema object.
+func (s Schema) JSONSchema() map[string]any {
+	typ := s.Type
+	if typ == "" {
+		typ = "object"
+	}
+	out := map[string]any{"type": typ}
+	if typ == "object" {
+		props := make(map[string]any, len(s.Properties))
+		for name, field := range s.Properties {
+			prop := map[string]any{}
+			if field.Type != "" {
+				prop["type"] = field.Type
+			}
+			if field.Description != "" {
+				prop["description"] = field.Description
+			}
+			props[name] = prop
+		}
+		out["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	return out
+}
+
+// Example is a sample invocation of a command: an input payload and the
+// output it produces.
+type Example struct {
 	Description string `json:"description"`
+	Input       any    `json:"input"`
+	Output      any    `json:"output,omitempty"`
+}
+
+// Exampler is implemented by commands that document sample invocations, so
+// agents can see the shape of their arguments before calling them.
+type Exampler interface {
+	Examples() []Example
+}
+
+// Examples returns the examples of cmd, or nil if it has none.
+func Examples(cmd PlatformCommand) []Example {
+	if e, ok := cmd.(Exampler); ok {
+		return e.Examples()
+	}
+	return nil
+}
+
+// RiskClassifier is implemented by commands whose risk depends on their
+// input, such as github:graphql, whose mutations are destructive. Risk
+// returns "read-only", "write" or "destructive" for payload, or "" when the
+// payload does not decide it.
+type RiskClassifier interface {
+	Risk(payload any) string
+}
+
+// CheckExamples reports the first example of cmd whose input does not
+// satisfy the command's input schema or whose output does not match its
+// output schema. Command packages test their examples with it.
+func CheckExamples(cmd PlatformCommand) error {
+	for i, ex := range Examples(cmd) {
+		in := agshctx.NewEnvelope(ex.Input, "application/json", "example")
+		if _, ok := ex.Input.(string); ok {
+			in.Meta.ContentType = "text/plain"
+		}
+		in, err := in.ConvertTo(cmd.InputSchema().Type)
+		if err == nil {
+			err = validateInput(cmd, in)
+		}
+		if err != nil {
+			return fmt.Errorf("%s example %d (%s): %w", cmd.Name(), i, ex.Description, err)
+		}
+		if ex.Output == nil {
+			continue
+		}
+		out := agshctx.NewEnvelope(ex.Output, "application/json", "example")
+		if _, ok := ex.Output.(string); ok {
+			out.Meta.ContentType = "text/plain"
+		}
+		problems, err := CheckOutput(cmd, out)
+		if err == nil && len(problems) > 0 {
+			err = fmt.Errorf("output: %+v", problems)
+		}
+		if err != nil {
+			return fmt.Errorf("%s example %d (%s): %w", cmd.Name(), i, ex.Description, err)
+		}
+	}
+	return nil
+}
+
+// ToolName converts a command name into a form accepted by LLM function-call
+// APIs, which disallow ':' (e.g. "github:pr:list" becomes "github__pr__list").
+func ToolName(commandName string) string {
+	return strings.ReplaceAll(commandName, ":", "__")
+}
+
+// CommandName reverses ToolName.
+func CommandName(toolName string) string {
+	return strings.ReplaceAll(toolName, "__", ":")
 }
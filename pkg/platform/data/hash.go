@@ -0,0 +1,112 @@
+package data
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cgast/agsh/internal/digest"
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Namespace describes the data commands.
+var Namespace = platform.Namespace{
+	Name:        "data",
+	Description: "Inspect and check data flowing through a pipeline",
+	Risk:        "read-only",
+}
+
+// HashCommand implements data:hash — computes a digest of a file or of the
+// input payload, so pipelines can verify artifacts and spot changes
+// between steps.
+type HashCommand struct {
+	Sandbox *sandbox.Sandbox
+}
+
+func (c *HashCommand) Name() string { return "data:hash" }
+func (c *HashCommand) Description() string {
+	return "Compute the sha256 (or md5, sha1, sha512) of a file or the payload"
+}
+func (c *HashCommand) Namespace() string { return "data" }
+
+func (c *HashCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":      {Type: "string", Description: "File to hash; without it the payload is hashed"},
+			"content":   {Type: "string", Description: "Text to hash instead of the payload"},
+			"algorithm": {Type: "string", Description: "md5, sha1, sha256 (default) or sha512"},
+		},
+	}
+}
+
+func (c *HashCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"algorithm": {Type: "string", Description: "Hash algorithm"},
+			"hash":      {Type: "string", Description: "Hex digest"},
+			"size":      {Type: "integer", Description: "Bytes hashed"},
+			"path":      {Type: "string", Description: "File hashed, if any"},
+		},
+	}
+}
+
+func (c *HashCommand) RequiredCredentials() []string { return nil }
+
+func (c *HashCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	args, _ := input.Payload.(map[string]any)
+	algorithm, _ := args["algorithm"].(string)
+	if algorithm == "" {
+		algorithm = digest.Default
+	}
+	algorithm = strings.ToLower(algorithm)
+
+	result := map[string]any{"algorithm": algorithm}
+	var (
+		sum  string
+		size int64
+		err  error
+	)
+	path, _ := args["path"].(string)
+	content, hasContent := args["content"].(string)
+	switch {
+	case path != "":
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("data:hash: resolve path: %w", err)
+		}
+		if sb := sandbox.ForRun(ctx, c.Sandbox); sb != nil {
+			if err := sb.CheckPath(path); err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("data:hash: %w", err)
+			}
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("data:hash: %w", err)
+		}
+		defer f.Close()
+		sum, size, err = digest.Sum(algorithm, f)
+		result["path"] = path
+	case hasContent:
+		sum, size, err = digest.Sum(algorithm, strings.NewReader(content))
+	default:
+		sum, size, err = digest.Sum(algorithm, strings.NewReader(input.PayloadString()))
+	}
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("data:hash: %w", err)
+	}
+	result["hash"] = sum
+	result["size"] = size
+
+	env := agshctx.NewEnvelope(result, "application/json", "data:hash")
+	env.Meta.Tags["hash"] = algorithm + ":" + sum
+	if path != "" {
+		env.Meta.Tags["path"] = path
+	}
+	return env, nil
+}
@@ -0,0 +1,75 @@
+package data
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestHashCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact.txt")
+	os.WriteFile(path, []byte("hello"), 0644)
+
+	tests := []struct {
+		name    string
+		payload any
+		want    string
+	}{
+		{"file", map[string]any{"path": path}, "sha256:" + helloSHA256},
+		{"content", map[string]any{"content": "hello", "algorithm": "md5"}, "md5:5d41402abc4b2a76b9719d911017c592"},
+		{"payload", "hello", "sha256:" + helloSHA256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := (&HashCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(tt.payload, "text/plain", "test"), nil)
+			if err != nil {
+				t.Fatalf("Execute error: %v", err)
+			}
+			if got := env.Meta.Tags["hash"]; got != tt.want {
+				t.Errorf("hash tag = %s, want %s", got, tt.want)
+			}
+			if size := env.Payload.(map[string]any)["size"]; size != int64(5) {
+				t.Errorf("size = %v", size)
+			}
+		})
+	}
+}
+
+func TestHashCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	sb, _ := sandbox.New(sandbox.Config{AllowedPaths: []string{dir}})
+	cmd := &HashCommand{Sandbox: sb}
+
+	payloads := []map[string]any{
+		{"path": "/etc/hostname"},
+		{"path": filepath.Join(dir, "missing")},
+		{"content": "x", "algorithm": "crc32"},
+	}
+	for _, p := range payloads {
+		if _, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(p, "application/json", "test"), nil); err == nil {
+			t.Errorf("expected error for %v", p)
+		}
+	}
+}
+
+func TestHashCommandRunSandbox(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact.txt")
+	os.WriteFile(path, []byte("hello"), 0644)
+
+	// A run's sandbox narrows an unrestricted command.
+	ctx, err := sandbox.WithRun(gocontext.Background(), sandbox.Config{AllowedPaths: []string{t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&HashCommand{}).Execute(ctx, agshctx.NewEnvelope(map[string]any{"path": path}, "application/json", "test"), nil); err == nil {
+		t.Error("expected the run's sandbox to refuse the path")
+	}
+}
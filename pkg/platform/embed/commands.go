@@ -0,0 +1,456 @@
+package embed
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cgast/agsh/internal/digest"
+	"github.com/cgast/agsh/internal/glob"
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+const (
+	chunkSize        = 1500    // bytes of text per indexed chunk
+	maxIndexFileSize = 1 << 20 // larger files are skipped
+	embedBatch       = 64      // texts per Embed call
+	defaultResults   = 5
+	maxResults       = 50
+)
+
+// indexedScopes are the context scopes embed:index reads; step values are
+// ephemeral and history is a log of everything else.
+var indexedScopes = []string{agshctx.ScopeProject, agshctx.ScopeSession}
+
+// skipDirs are never descended into.
+var skipDirs = map[string]bool{"node_modules": true, "vendor": true}
+
+// IndexCommand implements embed:index — embeds workspace files and context
+// values into the index at Path. Items are re-embedded only when their
+// content changed.
+type IndexCommand struct {
+	Sandbox  *sandbox.Sandbox
+	Path     string   // index file, e.g. .agsh/embed.json
+	Embedder Embedder // nil means HashEmbedder
+}
+
+func (c *IndexCommand) Name() string { return "embed:index" }
+func (c *IndexCommand) Description() string {
+	return "Build or update the search index over files and context values"
+}
+func (c *IndexCommand) Namespace() string { return "embed" }
+
+func (c *IndexCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"paths":   {Type: "array", Description: "Files and directories to index (default: the working directory)"},
+			"include": {Type: "string", Description: "Glob that file paths must match, e.g. **/*.md"},
+			"context": {Type: "boolean", Description: "Index project and session context values (default true)"},
+		},
+	}
+}
+
+func (c *IndexCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"index":    {Type: "string", Description: "Index file"},
+			"embedder": {Type: "string", Description: "Embedding model"},
+			"files":    {Type: "integer", Description: "Files in the index"},
+			"values":   {Type: "integer", Description: "Context values in the index"},
+			"chunks":   {Type: "integer", Description: "Chunks in the index"},
+			"embedded": {Type: "integer", Description: "Chunks embedded by this run"},
+			"removed":  {Type: "integer", Description: "Items dropped because they no longer exist"},
+			"skipped":  {Type: "integer", Description: "Files skipped as binary, too large or outside the sandbox"},
+		},
+	}
+}
+
+func (c *IndexCommand) RequiredCredentials() []string { return nil }
+
+// item is a file or context value to index.
+type item struct {
+	source, ref string
+	text        string // text to chunk and embed
+	offsetLines bool   // chunks carry line numbers
+}
+
+func (c *IndexCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+	paths := []string{"."}
+	var include string
+	withContext := true
+	switch v := input.Payload.(type) {
+	case string:
+		if v != "" {
+			paths = []string{v}
+		}
+	case map[string]any:
+		if p := stringList(v["paths"]); len(p) > 0 {
+			paths = p
+		} else if p, _ := v["path"].(string); p != "" {
+			paths = []string{p}
+		}
+		include, _ = v["include"].(string)
+		if b, ok := v["context"].(bool); ok {
+			withContext = b
+		}
+	}
+	if include != "" {
+		if err := glob.Validate(include); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("embed:index: include: %w", err)
+		}
+	}
+
+	embedder := c.embedder()
+	idx, err := LoadIndex(c.Path)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("embed:index: %w", err)
+	}
+	if idx.Embedder != embedder.Name() {
+		idx = &Index{Embedder: embedder.Name(), Hashes: make(map[string]string)}
+	}
+
+	var items []item
+	skipped := 0
+	for _, p := range paths {
+		found, n, err := c.collectFiles(sandbox.ForRun(ctx, c.Sandbox), p, include)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("embed:index: %w", err)
+		}
+		items = append(items, found...)
+		skipped += n
+	}
+	if withContext && store != nil {
+		found, err := contextItems(store)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("embed:index: %w", err)
+		}
+		items = append(items, found...)
+	}
+
+	// Drop items that are gone: files that no longer exist, and context
+	// values no longer set (only when context was indexed this run).
+	present := make(map[string]bool, len(items))
+	for _, it := range items {
+		present[itemKey(it.source, it.ref)] = true
+	}
+	removed := 0
+	for key := range idx.Hashes {
+		if present[key] {
+			continue
+		}
+		source, ref, _ := strings.Cut(key, ":")
+		gone := source == "context" && withContext
+		if source == "file" {
+			_, err := os.Stat(filepath.FromSlash(ref))
+			gone = err != nil
+		}
+		if gone {
+			idx.remove(key)
+			removed++
+		}
+	}
+
+	var pending []Entry
+	for _, it := range items {
+		key := itemKey(it.source, it.ref)
+		sum, _, err := digest.Sum(digest.Default, strings.NewReader(it.text))
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("embed:index: %w", err)
+		}
+		if idx.Hashes[key] == sum {
+			continue
+		}
+		idx.remove(key)
+		idx.Hashes[key] = sum
+		for _, ch := range chunkText(it.text, chunkSize) {
+			e := Entry{Source: it.source, Ref: it.ref, Text: ch.text}
+			if it.offsetLines {
+				e.StartLine, e.EndLine = ch.start, ch.end
+			}
+			pending = append(pending, e)
+		}
+	}
+	for start := 0; start < len(pending); start += embedBatch {
+		batch := pending[start:min(start+embedBatch, len(pending))]
+		texts := make([]string, len(batch))
+		for i, e := range batch {
+			texts[i] = e.Ref + "\n" + e.Text
+		}
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("embed:index: embed: %w", err)
+		}
+		for i := range batch {
+			batch[i].Vector = vectors[i]
+		}
+	}
+	idx.Entries = append(idx.Entries, pending...)
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		a, b := idx.Entries[i], idx.Entries[j]
+		if a.Source != b.Source {
+			return a.Source > b.Source // files first
+		}
+		if a.Ref != b.Ref {
+			return a.Ref < b.Ref
+		}
+		return a.StartLine < b.StartLine
+	})
+	if err := idx.Save(c.Path); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("embed:index: %w", err)
+	}
+
+	files, values := 0, 0
+	for key := range idx.Hashes {
+		if strings.HasPrefix(key, "file:") {
+			files++
+		} else {
+			values++
+		}
+	}
+	result := map[string]any{
+		"index":    c.Path,
+		"embedder": idx.Embedder,
+		"files":    files,
+		"values":   values,
+		"chunks":   len(idx.Entries),
+		"embedded": len(pending),
+		"removed":  removed,
+		"skipped":  skipped,
+	}
+	return agshctx.NewEnvelope(result, "application/json", "embed:index"), nil
+}
+
+func (c *IndexCommand) embedder() Embedder {
+	if c.Embedder == nil {
+		return HashEmbedder{}
+	}
+	return c.Embedder
+}
+
+// collectFiles walks root and returns the text files to index within sb,
+// and how many files it skipped.
+func (c *IndexCommand) collectFiles(sb *sandbox.Sandbox, root, include string) ([]item, int, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, 0, fmt.Errorf("resolve path: %w", err)
+	}
+	if sb != nil {
+		if err := sb.CheckPath(abs); err != nil {
+			return nil, 0, err
+		}
+	}
+	indexAbs, _ := filepath.Abs(c.Path)
+	limit := int64(maxIndexFileSize)
+	if sb != nil && sb.MaxFileSize() > 0 && sb.MaxFileSize() < limit {
+		limit = sb.MaxFileSize()
+	}
+
+	var items []item
+	skipped := 0
+	err = filepath.WalkDir(abs, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == abs {
+				return err
+			}
+			skipped++
+			return nil
+		}
+		if d.IsDir() {
+			if path != abs && (strings.HasPrefix(d.Name(), ".") || skipDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || path == indexAbs {
+			return nil
+		}
+		ref := displayPath(path)
+		if include != "" && !glob.Match(include, ref) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > limit {
+			skipped++
+			return nil
+		}
+		if sb != nil && sb.CheckPath(path) != nil {
+			skipped++
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+			skipped++
+			return nil
+		}
+		items = append(items, item{source: "file", ref: ref, text: string(data), offsetLines: true})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("walk %s: %w", root, err)
+	}
+	return items, skipped, nil
+}
+
+// contextItems returns the values in the indexed context scopes.
+func contextItems(store agshctx.ContextStore) ([]item, error) {
+	var items []item
+	for _, scope := range indexedScopes {
+		values, err := store.List(scope)
+		if err != nil {
+			return nil, fmt.Errorf("list %s context: %w", scope, err)
+		}
+		for key, value := range values {
+			text, ok := value.(string)
+			if !ok {
+				data, err := json.MarshalIndent(value, "", "  ")
+				if err != nil {
+					continue
+				}
+				text = string(data)
+			}
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			items = append(items, item{source: "context", ref: scope + "/" + key, text: text})
+		}
+	}
+	return items, nil
+}
+
+// displayPath returns path relative to the working directory when it is
+// inside it, in slash form.
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// SearchCommand implements embed:search — returns the indexed chunks most
+// similar to a query.
+type SearchCommand struct {
+	Path     string   // index file written by embed:index
+	Embedder Embedder // must match the embedder that built the index
+}
+
+func (c *SearchCommand) Name() string { return "embed:search" }
+func (c *SearchCommand) Description() string {
+	return "Find indexed files and context values similar to a query"
+}
+func (c *SearchCommand) Namespace() string { return "embed" }
+
+func (c *SearchCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"query":     {Type: "string", Description: "What to look for"},
+			"k":         {Type: "integer", Description: "Number of results (default 5, max 50)"},
+			"source":    {Type: "string", Description: "Only file or only context results"},
+			"min_score": {Type: "number", Description: "Minimum cosine similarity (0-1)"},
+		},
+		Required: []string{"query"},
+	}
+}
+
+func (c *SearchCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"results": {Type: "array", Description: "Hits with source, ref, line range, score and text, best first"},
+		},
+	}
+}
+
+func (c *SearchCommand) RequiredCredentials() []string { return nil }
+
+func (c *SearchCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	var query, source string
+	k := defaultResults
+	var minScore float64
+	switch v := input.Payload.(type) {
+	case string:
+		query = v
+	case map[string]any:
+		query, _ = v["query"].(string)
+		source, _ = v["source"].(string)
+		if n, ok := v["k"].(float64); ok {
+			k = int(n)
+		} else if n, ok := v["k"].(int); ok {
+			k = n
+		}
+		if s, ok := v["min_score"].(float64); ok {
+			minScore = s
+		}
+	}
+	if strings.TrimSpace(query) == "" {
+		return agshctx.Envelope{}, fmt.Errorf("embed:search: missing 'query'")
+	}
+	if k <= 0 || k > maxResults {
+		return agshctx.Envelope{}, fmt.Errorf("embed:search: 'k' must be between 1 and %d", maxResults)
+	}
+	if source != "" && source != "file" && source != "context" {
+		return agshctx.Envelope{}, fmt.Errorf("embed:search: unknown source %q (expected file or context)", source)
+	}
+
+	embedder := c.Embedder
+	if embedder == nil {
+		embedder = HashEmbedder{}
+	}
+	idx, err := LoadIndex(c.Path)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("embed:search: %w", err)
+	}
+	if len(idx.Entries) == 0 {
+		return agshctx.Envelope{}, fmt.Errorf("embed:search: index %s is empty; run embed:index first", c.Path)
+	}
+	if idx.Embedder != embedder.Name() {
+		return agshctx.Envelope{}, fmt.Errorf("embed:search: index was built with %s, not %s; run embed:index again", idx.Embedder, embedder.Name())
+	}
+
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("embed:search: embed query: %w", err)
+	}
+	hits := idx.Search(vectors[0], k, source, minScore)
+	if hits == nil {
+		hits = []Hit{}
+	}
+
+	env := agshctx.NewEnvelope(map[string]any{"results": hits}, "application/json", "embed:search")
+	env.Meta.Tags["embedder"] = idx.Embedder
+	env.Meta.Tags["hits"] = fmt.Sprintf("%d", len(hits))
+	return env, nil
+}
+
+// stringList accepts a []any of strings or a single string.
+func stringList(v any) []string {
+	switch x := v.(type) {
+	case string:
+		if x != "" {
+			return []string{x}
+		}
+	case []string:
+		return x
+	case []any:
+		var out []string
+		for _, e := range x {
+			if s, ok := e.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
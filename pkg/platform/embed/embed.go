@@ -0,0 +1,128 @@
+package embed
+
+import (
+	gocontext "context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Namespace describes the embed commands.
+var Namespace = platform.Namespace{
+	Name:        "embed",
+	Description: "Index workspace files and context values and search them by meaning",
+	Risk:        "read-only",
+}
+
+// Embedder turns texts into vectors. Name identifies the model; an index
+// is only searched with the embedder that built it.
+type Embedder interface {
+	Name() string
+	Embed(ctx gocontext.Context, texts []string) ([][]float32, error)
+}
+
+// hashDims is the vector size of HashEmbedder.
+const hashDims = 512
+
+// HashEmbedder is a local embedder that needs no model: words and word
+// pairs are hashed into a fixed-size vector. It finds texts that share
+// vocabulary rather than meaning, which is often enough to retrieve prior
+// outputs; configure an embedding model for real semantic search.
+type HashEmbedder struct{}
+
+func (HashEmbedder) Name() string { return "hash-512" }
+
+func (HashEmbedder) Embed(_ gocontext.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = hashVector(text)
+	}
+	return vectors, nil
+}
+
+func hashVector(text string) []float32 {
+	counts := make(map[string]int)
+	words := tokenize(text)
+	for i, w := range words {
+		counts[w]++
+		if i > 0 {
+			counts[words[i-1]+" "+w]++
+		}
+	}
+	v := make([]float32, hashDims)
+	for term, n := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(term))
+		sum := h.Sum64()
+		weight := float32(1 + math.Log(float64(n)))
+		if sum&(1<<63) != 0 {
+			weight = -weight
+		}
+		v[sum%hashDims] += weight
+	}
+	normalize(v)
+	return v
+}
+
+// tokenize splits text into lower-case words of two or more letters or
+// digits; camelCase and snake_case identifiers are split into words.
+func tokenize(text string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) >= 2 {
+			words = append(words, strings.ToLower(string(cur)))
+		}
+		cur = cur[:0]
+	}
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			cur = append(cur, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			cur = append(cur, r)
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return words
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// cosine returns the cosine similarity of a and b, or 0 if their sizes
+// differ.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
@@ -0,0 +1,98 @@
+package embed
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func TestTokenize(t *testing.T) {
+	got := strings.Join(tokenize("parseHTTPConfig reads db_url; a 42"), " ")
+	if got != "parse httpconfig reads db url 42" {
+		t.Errorf("tokenize = %q", got)
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("0123456789\n", 30) // 330 bytes
+	chunks := chunkText(text, 100)
+	if len(chunks) != 4 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+	if chunks[0].start != 1 || chunks[0].end != 9 || chunks[3].end != 30 {
+		t.Errorf("line ranges = %+v", chunks)
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].start != chunks[i-1].end+1 {
+			t.Errorf("chunk %d starts at %d after %d", i, chunks[i].start, chunks[i-1].end)
+		}
+	}
+}
+
+func TestIndexAndSearch(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	os.MkdirAll("docs", 0755)
+	os.MkdirAll(".git", 0755)
+	os.WriteFile("docs/db.md", []byte("# Database\n\nThe postgres connection pool is configured with max_connections.\n"), 0644)
+	os.WriteFile("docs/deploy.md", []byte("# Deploy\n\nReleases are shipped by the kubernetes rollout job.\n"), 0644)
+	os.WriteFile("logo.bin", []byte{0x89, 'P', 'N', 'G', 0, 0, 1}, 0644)
+	os.WriteFile(".git/config", []byte("postgres postgres postgres"), 0644)
+
+	store, err := agshctx.NewBoltStore(filepath.Join(t.TempDir(), "ctx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Set(agshctx.ScopeSession, "last_incident", "Kubernetes rollout stalled on a failing readiness probe")
+
+	indexPath := filepath.Join(".agsh", "embed.json")
+	index := &IndexCommand{Path: indexPath}
+	search := &SearchCommand{Path: indexPath}
+	run := func(cmd interface {
+		Execute(gocontext.Context, agshctx.Envelope, agshctx.ContextStore) (agshctx.Envelope, error)
+	}, payload any) map[string]any {
+		t.Helper()
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(payload, "application/json", "test"), store)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return env.Payload.(map[string]any)
+	}
+
+	res := run(index, map[string]any{})
+	if res["files"] != 2 || res["values"] != 1 || res["skipped"] != 1 || res["embedded"] != 3 {
+		t.Fatalf("first index = %v", res)
+	}
+
+	hits := run(search, "postgres connection pool")["results"].([]Hit)
+	if len(hits) == 0 || hits[0].Ref != "docs/db.md" || hits[0].StartLine != 1 {
+		t.Fatalf("file hits = %+v", hits)
+	}
+	hits = run(search, map[string]any{"query": "kubernetes rollout", "source": "context"})["results"].([]Hit)
+	if len(hits) != 1 || hits[0].Ref != "session/last_incident" {
+		t.Fatalf("context hits = %+v", hits)
+	}
+
+	// Unchanged items are not embedded again; deleted ones are dropped.
+	os.Remove("docs/deploy.md")
+	res = run(index, map[string]any{})
+	if res["embedded"] != 0 || res["removed"] != 1 || res["files"] != 1 {
+		t.Errorf("second index = %v", res)
+	}
+
+	if _, err := (&SearchCommand{Path: indexPath, Embedder: fakeEmbedder{}}).Execute(gocontext.Background(), agshctx.NewEnvelope("x", "text/plain", "test"), nil); err == nil {
+		t.Error("expected error for an index built with another embedder")
+	}
+}
+
+type fakeEmbedder struct{ HashEmbedder }
+
+func (fakeEmbedder) Name() string { return "fake" }
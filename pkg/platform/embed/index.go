@@ -0,0 +1,152 @@
+package embed
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Entry is one indexed chunk of a file or context value.
+type Entry struct {
+	Source    string    `json:"source"` // "file" or "context"
+	Ref       string    `json:"ref"`    // file path, or scope/key
+	StartLine int       `json:"start_line,omitempty"`
+	EndLine   int       `json:"end_line,omitempty"`
+	Text      string    `json:"text"`
+	Vector    []float32 `json:"vector"`
+}
+
+// Index is the on-disk vector index. Hashes records the content hash of
+// every indexed item, keyed by source and ref, so unchanged items are not
+// embedded again.
+type Index struct {
+	Embedder string            `json:"embedder"`
+	Hashes   map[string]string `json:"hashes"`
+	Entries  []Entry           `json:"entries"`
+}
+
+// Hit is a search result.
+type Hit struct {
+	Source    string  `json:"source"`
+	Ref       string  `json:"ref"`
+	StartLine int     `json:"start_line,omitempty"`
+	EndLine   int     `json:"end_line,omitempty"`
+	Score     float64 `json:"score"`
+	Text      string  `json:"text"`
+}
+
+// LoadIndex reads the index at path. A missing file yields an empty index.
+func LoadIndex(path string) (*Index, error) {
+	idx := &Index{Hashes: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("parse index %s: %w", path, err)
+	}
+	if idx.Hashes == nil {
+		idx.Hashes = make(map[string]string)
+	}
+	return idx, nil
+}
+
+// Save writes the index to path, replacing it atomically.
+func (idx *Index) Save(path string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("encode index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create index dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write index: %w", err)
+	}
+	return nil
+}
+
+// remove drops every entry and hash for the item key.
+func (idx *Index) remove(key string) {
+	delete(idx.Hashes, key)
+	kept := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if itemKey(e.Source, e.Ref) != key {
+			kept = append(kept, e)
+		}
+	}
+	idx.Entries = kept
+}
+
+// Search returns the k entries most similar to query, best first.
+// source restricts results to "file" or "context" when set.
+func (idx *Index) Search(query []float32, k int, source string, minScore float64) []Hit {
+	var hits []Hit
+	for _, e := range idx.Entries {
+		if source != "" && e.Source != source {
+			continue
+		}
+		score := cosine(query, e.Vector)
+		if score < minScore || score <= 0 {
+			continue
+		}
+		hits = append(hits, Hit{
+			Source: e.Source, Ref: e.Ref, StartLine: e.StartLine, EndLine: e.EndLine,
+			Score: score, Text: e.Text,
+		})
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+func itemKey(source, ref string) string { return source + ":" + ref }
+
+// chunk is a piece of text with its line range (1-based, inclusive).
+type chunk struct {
+	text       string
+	start, end int
+}
+
+// chunkText splits text into chunks of about size bytes at line breaks.
+// A single longer line becomes a chunk of its own.
+func chunkText(text string, size int) []chunk {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var chunks []chunk
+	var b strings.Builder
+	start := 1
+	for i, line := range lines {
+		if b.Len() > 0 && b.Len()+len(line) > size {
+			chunks = append(chunks, chunk{text: b.String(), start: start, end: i})
+			b.Reset()
+			start = i + 1
+		}
+		b.WriteString(line)
+	}
+	if strings.TrimSpace(b.String()) != "" {
+		chunks = append(chunks, chunk{text: b.String(), start: start, end: len(lines)})
+	}
+	kept := chunks[:0]
+	for _, c := range chunks {
+		if strings.TrimSpace(c.text) != "" {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,168 @@
+package platform
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// Error categories. Agents branch on the category: fix the input, supply
+// credentials, wait and retry, or give up.
+const (
+	CategoryInvalidInput = "invalid_input"
+	CategoryNotFound     = "not_found"
+	CategoryAuth         = "auth"
+	CategoryPermission   = "permission"
+	CategoryConflict     = "conflict"
+	CategoryRateLimited  = "rate_limited"
+	CategoryUnavailable  = "unavailable"
+	CategoryTimeout      = "timeout"
+	CategoryCancelled    = "cancelled"
+	CategoryVerification = "verification"
+	CategoryInternal     = "internal"
+)
+
+// ErrCommandNotFound is wrapped by the error Resolve returns for unknown
+// commands.
+var ErrCommandNotFound = errors.New("command not found")
+
+// Error is a classified failure. Commands return one (see NewError and
+// StatusError) when they know what went wrong; Classify infers one for
+// every other error.
+type Error struct {
+	Code      string // stable identifier, e.g. "sandbox.violation", "http.429"
+	Category  string
+	Message   string
+	Retriable bool
+	Hint      string // what to do about it
+	Command   string // offending command, if known
+	Step      int    // offending pipeline step, or -1
+	Err       error  // underlying error, if any
+
+	Fields []FieldError // invalid input fields, for "input.invalid"
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil && e.Message == "" {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// NewError creates an error of category. Rate-limited, unavailable and
+// timed-out errors are retriable.
+func NewError(code, category, message string) *Error {
+	return &Error{
+		Code:      code,
+		Category:  category,
+		Message:   message,
+		Retriable: retriableCategory(category),
+		Step:      -1,
+	}
+}
+
+// WithHint sets a remediation hint and returns e.
+func (e *Error) WithHint(hint string) *Error {
+	e.Hint = hint
+	return e
+}
+
+// StatusError classifies a failed HTTP response by its status code.
+// message should name the request and include the server's explanation.
+func StatusError(status int, message string) *Error {
+	category := CategoryInvalidInput
+	hint := ""
+	switch {
+	case status == http.StatusUnauthorized:
+		category, hint = CategoryAuth, "check the credential configured in .agsh/platforms.yaml"
+	case status == http.StatusForbidden:
+		category, hint = CategoryPermission, "the credential lacks access to this resource"
+	case status == http.StatusNotFound:
+		category = CategoryNotFound
+	case status == http.StatusConflict || status == http.StatusPreconditionFailed:
+		category = CategoryConflict
+	case status == http.StatusTooManyRequests:
+		category, hint = CategoryRateLimited, "wait for the rate limit to reset, then retry"
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		category = CategoryTimeout
+	case status >= 500:
+		category = CategoryUnavailable
+	}
+	return NewError(fmt.Sprintf("http.%d", status), category, message).WithHint(hint)
+}
+
+func retriableCategory(category string) bool {
+	switch category {
+	case CategoryRateLimited, CategoryUnavailable, CategoryTimeout:
+		return true
+	}
+	return false
+}
+
+// Classify returns a classified copy of err: the first *Error in its chain,
+// with its message replaced by err's full message, or else one inferred
+// from well-known errors. The offending command and step are filled in from
+// an agshctx.StepError in the chain when the error does not name them.
+// Classify returns nil for a nil error.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var out Error
+	var typed *Error
+	if errors.As(err, &typed) {
+		out = *typed
+	} else {
+		out = *infer(err)
+	}
+	out.Message = err.Error()
+	out.Err = err
+
+	var stepErr *agshctx.StepError
+	if errors.As(err, &stepErr) {
+		if out.Command == "" {
+			out.Command = stepErr.Command
+		}
+		if out.Step < 0 {
+			out.Step = stepErr.Step
+		}
+	}
+	return &out
+}
+
+// infer classifies errors that do not carry an *Error.
+func infer(err error) *Error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, sandbox.ErrViolation):
+		return NewError("sandbox.violation", CategoryPermission, "").
+			WithHint("the path or size is outside the sandbox; adjust sandbox settings in .agsh/config.yaml if it should be allowed")
+	case errors.Is(err, agshctx.ErrVerificationFailed):
+		return NewError("verify.failed", CategoryVerification, "")
+	case errors.Is(err, ErrCommandNotFound):
+		return NewError("command.not_found", CategoryNotFound, "").
+			WithHint("list available commands with commands.list")
+	case errors.Is(err, gocontext.DeadlineExceeded):
+		return NewError("timeout", CategoryTimeout, "")
+	case errors.Is(err, gocontext.Canceled):
+		return NewError("cancelled", CategoryCancelled, "")
+	case errors.Is(err, os.ErrNotExist):
+		return NewError("fs.not_found", CategoryNotFound, "")
+	case errors.Is(err, os.ErrPermission):
+		return NewError("fs.permission", CategoryPermission, "")
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return NewError("net.timeout", CategoryTimeout, "")
+		}
+		return NewError("net.unavailable", CategoryUnavailable, "")
+	}
+	return NewError("internal", CategoryInternal, "")
+}
@@ -0,0 +1,84 @@
+package platform
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status    int
+		category  string
+		retriable bool
+	}{
+		{400, CategoryInvalidInput, false},
+		{401, CategoryAuth, false},
+		{403, CategoryPermission, false},
+		{404, CategoryNotFound, false},
+		{409, CategoryConflict, false},
+		{429, CategoryRateLimited, true},
+		{502, CategoryUnavailable, true},
+		{504, CategoryTimeout, true},
+	}
+	for _, tt := range tests {
+		e := StatusError(tt.status, "GET /x")
+		if e.Category != tt.category || e.Retriable != tt.retriable {
+			t.Errorf("StatusError(%d) = %s retriable=%v, want %s retriable=%v", tt.status, e.Category, e.Retriable, tt.category, tt.retriable)
+		}
+		if e.Code != fmt.Sprintf("http.%d", tt.status) {
+			t.Errorf("StatusError(%d).Code = %q", tt.status, e.Code)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	if Classify(nil) != nil {
+		t.Error("Classify(nil) should be nil")
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		code     string
+		category string
+	}{
+		{"typed", fmt.Errorf("gitlab:mr:list: %w", StatusError(429, "GET /mr: 429")), "http.429", CategoryRateLimited},
+		{"sandbox", fmt.Errorf("fs:write: %w", sandbox.Violationf("path escapes sandbox")), "sandbox.violation", CategoryPermission},
+		{"verification", fmt.Errorf("%w: 1 failed", agshctx.ErrVerificationFailed), "verify.failed", CategoryVerification},
+		{"not found", fmt.Errorf("%w: fs:nope", ErrCommandNotFound), "command.not_found", CategoryNotFound},
+		{"deadline", fmt.Errorf("run: %w", gocontext.DeadlineExceeded), "timeout", CategoryTimeout},
+		{"missing file", fmt.Errorf("fs:read: %w", os.ErrNotExist), "fs.not_found", CategoryNotFound},
+		{"plain", errors.New("boom"), "internal", CategoryInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ce := Classify(tt.err)
+			if ce.Code != tt.code || ce.Category != tt.category {
+				t.Errorf("Classify = %s/%s, want %s/%s", ce.Code, ce.Category, tt.code, tt.category)
+			}
+			if ce.Message != tt.err.Error() {
+				t.Errorf("Message = %q, want %q", ce.Message, tt.err.Error())
+			}
+			if !errors.Is(ce, tt.err) {
+				t.Error("classified error should wrap the original")
+			}
+		})
+	}
+}
+
+func TestClassifyStepError(t *testing.T) {
+	err := &agshctx.StepError{Step: 2, Command: "github:pr:list", Err: StatusError(401, "bad credentials")}
+	ce := Classify(err)
+	if ce.Command != "github:pr:list" || ce.Step != 2 {
+		t.Errorf("Command/Step = %q/%d, want github:pr:list/2", ce.Command, ce.Step)
+	}
+	if ce.Category != CategoryAuth || ce.Hint == "" {
+		t.Errorf("Category = %q, Hint = %q", ce.Category, ce.Hint)
+	}
+}
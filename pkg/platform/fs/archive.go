@@ -0,0 +1,560 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Archive formats.
+const (
+	formatZip   = "zip"
+	formatTar   = "tar"
+	formatTarGz = "tar.gz"
+)
+
+// ArchiveMember records where an archive member came from or went to.
+type ArchiveMember struct {
+	Name   string `json:"name"`             // name inside the archive
+	Path   string `json:"path"`             // source file (fs:zip) or extracted file (fs:unzip)
+	Size   int64  `json:"size"`             // uncompressed bytes
+	SHA256 string `json:"sha256"`           // of the uncompressed contents
+	Reason string `json:"reason,omitempty"` // why a member was skipped
+}
+
+// archiveFormat picks the format from an explicit value or the file name.
+func archiveFormat(explicit, name string) (string, error) {
+	switch explicit {
+	case formatZip, formatTar, formatTarGz:
+		return explicit, nil
+	case "tgz":
+		return formatTarGz, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown format %q (expected zip, tar or tar.gz)", explicit)
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return formatZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return formatTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return formatTar, nil
+	}
+	return "", fmt.Errorf("cannot tell archive format from %q; pass format", name)
+}
+
+// ZipCommand implements fs:zip — bundles files and directories into a zip
+// or tar archive.
+type ZipCommand struct {
+	Sandbox *sandbox.Sandbox
+}
+
+func (c *ZipCommand) Name() string { return "fs:zip" }
+func (c *ZipCommand) Description() string {
+	return "Create a zip or tar archive from files and directories"
+}
+func (c *ZipCommand) Namespace() string { return "fs" }
+
+func (c *ZipCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":    {Type: "string", Description: "Archive file to create"},
+			"sources": {Type: "array", Description: "Files and directories to add; directories are added recursively"},
+			"format":  {Type: "string", Description: "zip, tar or tar.gz (default: from the archive extension)"},
+		},
+		Required: []string{"path", "sources"},
+	}
+}
+
+func (c *ZipCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":    {Type: "string", Description: "Archive file written"},
+			"format":  {Type: "string", Description: "Archive format"},
+			"size":    {Type: "integer", Description: "Archive size in bytes"},
+			"members": {Type: "array", Description: "Members with their source path, size and sha256"},
+		},
+	}
+}
+
+func (c *ZipCommand) RequiredCredentials() []string { return nil }
+
+func (c *ZipCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	args, ok := input.Payload.(map[string]any)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: requires map payload with 'path' and 'sources', got %T", input.Payload)
+	}
+	archivePath, _ := args["path"].(string)
+	if archivePath == "" {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: missing 'path'")
+	}
+	sources, err := stringList(args["sources"])
+	if err != nil || len(sources) == 0 {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: 'sources' must be a non-empty list of paths")
+	}
+	explicit, _ := args["format"].(string)
+	format, err := archiveFormat(explicit, archivePath)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: %w", err)
+	}
+
+	archivePath, err = filepath.Abs(archivePath)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: resolve path: %w", err)
+	}
+	if err := c.checkPath(ctx, archivePath); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: %w", err)
+	}
+
+	// Collect members first so nothing is written if a source is rejected.
+	var members []ArchiveMember
+	for _, src := range sources {
+		src, err = filepath.Abs(src)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:zip: resolve path: %w", err)
+		}
+		if err := c.checkPath(ctx, src); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:zip: %w", err)
+		}
+		base := filepath.Dir(src)
+		err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil // directories are implied; links and devices are not archived
+			}
+			if p == archivePath {
+				return nil
+			}
+			if err := c.checkPath(ctx, p); err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(base, p)
+			if err != nil {
+				return err
+			}
+			members = append(members, ArchiveMember{Name: filepath.ToSlash(rel), Path: p})
+			return nil
+		})
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:zip: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: create dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), "."+filepath.Base(archivePath)+".*.tmp")
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	out := &limitedWriter{w: tmp, limit: c.maxSize(ctx)}
+	if err := writeArchive(out, format, members); err != nil {
+		tmp.Close()
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), archivePath); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:zip: %w", err)
+	}
+	agshctx.RecordWrite(ctx, archivePath)
+
+	result := map[string]any{
+		"path":    archivePath,
+		"format":  format,
+		"size":    out.n,
+		"members": members,
+	}
+	env := agshctx.NewEnvelope(result, "application/json", "fs:zip")
+	env.Meta.Tags["path"] = archivePath
+	env.Meta.Tags["members"] = fmt.Sprintf("%d", len(members))
+	return env, nil
+}
+
+func (c *ZipCommand) checkPath(ctx gocontext.Context, p string) error {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
+	if sb == nil {
+		return nil
+	}
+	return sb.CheckPath(p)
+}
+
+func (c *ZipCommand) maxSize(ctx gocontext.Context) int64 {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
+	if sb == nil {
+		return 0
+	}
+	return sb.MaxFileSize()
+}
+
+// writeArchive writes members to w, filling in their size and hash.
+func writeArchive(w io.Writer, format string, members []ArchiveMember) error {
+	switch format {
+	case formatZip:
+		zw := zip.NewWriter(w)
+		for i := range members {
+			m := &members[i]
+			info, err := os.Stat(m.Path)
+			if err != nil {
+				return err
+			}
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = m.Name
+			hdr.Method = zip.Deflate
+			dst, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			if err := copyMember(dst, m); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+
+	case formatTar, formatTarGz:
+		var gz *gzip.Writer
+		if format == formatTarGz {
+			gz = gzip.NewWriter(w)
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		for i := range members {
+			m := &members[i]
+			info, err := os.Stat(m.Path)
+			if err != nil {
+				return err
+			}
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = m.Name
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if err := copyMember(tw, m); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		if gz != nil {
+			return gz.Close()
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// copyMember copies a source file into an archive, recording size and hash.
+func copyMember(dst io.Writer, m *ArchiveMember) error {
+	f, err := os.Open(m.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(dst, h), f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.Name, err)
+	}
+	m.Size = n
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// limitedWriter fails once more than limit bytes are written; 0 is
+// unlimited.
+type limitedWriter struct {
+	w     io.Writer
+	limit int64
+	n     int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.limit > 0 && l.n+int64(len(p)) > l.limit {
+		return 0, sandbox.Violationf("archive exceeds maximum file size %d bytes", l.limit)
+	}
+	n, err := l.w.Write(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// UnzipCommand implements fs:unzip — extracts a zip or tar archive into a
+// directory, rejecting members that would land outside it or the sandbox.
+type UnzipCommand struct {
+	Sandbox *sandbox.Sandbox
+}
+
+func (c *UnzipCommand) Name() string        { return "fs:unzip" }
+func (c *UnzipCommand) Description() string { return "Extract a zip or tar archive into a directory" }
+func (c *UnzipCommand) Namespace() string   { return "fs" }
+
+func (c *UnzipCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":      {Type: "string", Description: "Archive file to extract"},
+			"dest":      {Type: "string", Description: "Directory to extract into"},
+			"format":    {Type: "string", Description: "zip, tar or tar.gz (default: from the archive extension)"},
+			"overwrite": {Type: "boolean", Description: "Replace existing files (default: fail)"},
+		},
+		Required: []string{"path", "dest"},
+	}
+}
+
+func (c *UnzipCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"dest":    {Type: "string", Description: "Extraction directory"},
+			"members": {Type: "array", Description: "Extracted members with their path, size and sha256"},
+			"skipped": {Type: "array", Description: "Members not extracted (links, devices) with the reason"},
+		},
+	}
+}
+
+func (c *UnzipCommand) RequiredCredentials() []string { return nil }
+
+func (c *UnzipCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
+	args, ok := input.Payload.(map[string]any)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("fs:unzip: requires map payload with 'path' and 'dest', got %T", input.Payload)
+	}
+	archivePath, _ := args["path"].(string)
+	dest, _ := args["dest"].(string)
+	if archivePath == "" || dest == "" {
+		return agshctx.Envelope{}, fmt.Errorf("fs:unzip: 'path' and 'dest' are required")
+	}
+	explicit, _ := args["format"].(string)
+	format, err := archiveFormat(explicit, archivePath)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:unzip: %w", err)
+	}
+	overwrite, _ := args["overwrite"].(bool)
+
+	archivePath, err = filepath.Abs(archivePath)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:unzip: resolve path: %w", err)
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:unzip: resolve path: %w", err)
+	}
+	for _, p := range []string{archivePath, dest} {
+		if sb != nil {
+			if err := sb.CheckPath(p); err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("fs:unzip: %w", err)
+			}
+		}
+	}
+
+	x := &extractor{dest: dest, sandbox: sb, overwrite: overwrite}
+	switch format {
+	case formatZip:
+		err = x.zip(archivePath)
+	default:
+		err = x.tar(archivePath, format == formatTarGz)
+	}
+	agshctx.RecordWrite(ctx, dest)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:unzip: %w", err)
+	}
+
+	result := map[string]any{
+		"path":    archivePath,
+		"dest":    dest,
+		"members": x.members,
+	}
+	if len(x.skipped) > 0 {
+		result["skipped"] = x.skipped
+	}
+	env := agshctx.NewEnvelope(result, "application/json", "fs:unzip")
+	env.Meta.Tags["path"] = dest
+	env.Meta.Tags["members"] = fmt.Sprintf("%d", len(x.members))
+	return env, nil
+}
+
+// extractor writes archive members below dest.
+type extractor struct {
+	dest      string
+	sandbox   *sandbox.Sandbox
+	overwrite bool
+	members   []ArchiveMember
+	skipped   []ArchiveMember
+}
+
+func (x *extractor) zip(archivePath string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			continue
+		case !mode.IsRegular():
+			x.skipped = append(x.skipped, ArchiveMember{Name: f.Name, Reason: "not a regular file"})
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		err = x.extract(f.Name, rc, mode.Perm())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tar(archivePath string, gzipped bool) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			x.skipped = append(x.skipped, ArchiveMember{Name: hdr.Name, Reason: "not a regular file"})
+			continue
+		}
+		if err := x.extract(hdr.Name, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+			return err
+		}
+	}
+}
+
+// extract writes one member. The name must stay inside dest (no absolute
+// paths or ".." components), the target must pass the sandbox, and the
+// uncompressed size must fit the sandbox's file size limit; sizes declared
+// in headers are not trusted.
+func (x *extractor) extract(name string, r io.Reader, perm fs.FileMode) error {
+	clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return fmt.Errorf("member %q would be extracted outside %s", name, x.dest)
+	}
+	target := filepath.Join(x.dest, filepath.FromSlash(clean))
+	if x.sandbox != nil {
+		if err := x.sandbox.CheckPath(target); err != nil {
+			return fmt.Errorf("member %q: %w", name, err)
+		}
+	}
+	if !x.overwrite {
+		if _, err := os.Lstat(target); err == nil {
+			return fmt.Errorf("member %q: %s already exists (pass overwrite: true)", name, target)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	var limit int64
+	if x.sandbox != nil {
+		limit = x.sandbox.MaxFileSize()
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	if perm == 0 {
+		perm = 0644
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && limit > 0 && n > limit {
+		err = sandbox.Violationf("member exceeds maximum file size %d bytes", limit)
+	}
+	if err != nil {
+		os.Remove(target)
+		return fmt.Errorf("member %q: %w", name, err)
+	}
+
+	x.members = append(x.members, ArchiveMember{
+		Name:   name,
+		Path:   target,
+		Size:   n,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	})
+	return nil
+}
+
+// stringList accepts a single string or a list of strings.
+func stringList(v any) ([]string, error) {
+	switch x := v.(type) {
+	case string:
+		return []string{x}, nil
+	case []string:
+		return x, nil
+	case []any:
+		out := make([]string, len(x))
+		for i, item := range x {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("item %d is %T, not a string", i, item)
+			}
+			out[i] = s
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("expected a list of strings, got %T", v)
+}
@@ -0,0 +1,110 @@
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgast/agsh/internal/sandbox"
+)
+
+// intArg reads an optional non-negative integer argument. Values may arrive
+// as JSON numbers (float64), YAML integers, or strings from the REPL.
+func intArg(args map[string]any, key string) (int64, error) {
+	v, ok := args[key]
+	if !ok || v == nil {
+		return 0, nil
+	}
+	var n int64
+	switch x := v.(type) {
+	case int:
+		n = int64(x)
+	case int64:
+		n = x
+	case float64:
+		if x != float64(int64(x)) {
+			return 0, fmt.Errorf("'%s' must be an integer, got %v", key, x)
+		}
+		n = int64(x)
+	case string:
+		parsed, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("'%s' must be an integer, got %q", key, x)
+		}
+		n = parsed
+	default:
+		return 0, fmt.Errorf("'%s' must be an integer, got %T", key, v)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("'%s' must not be negative", key)
+	}
+	return n, nil
+}
+
+// sizeArg reads an optional size argument: a number of bytes, or a string
+// such as "10KB" or "1MB".
+func sizeArg(args map[string]any, key string) (int64, error) {
+	s, ok := args[key].(string)
+	if !ok {
+		return intArg(args, key)
+	}
+	n, err := sandbox.ParseFileSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("'%s': %w", key, err)
+	}
+	return n, nil
+}
+
+// timeArg reads an optional point in time: an RFC 3339 time, a date
+// (2006-01-02, UTC) or a duration such as "24h", taken back from now.
+func timeArg(args map[string]any, key string, now time.Time) (time.Time, error) {
+	v, ok := args[key]
+	if !ok || v == nil {
+		return time.Time{}, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("'%s' must be a time, date or duration, got %T", key, v)
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("'%s' must be an RFC 3339 time, a date or a duration, got %q", key, s)
+}
+
+// stringsArg reads an optional list of strings, given as an array or as a
+// comma-separated string.
+func stringsArg(args map[string]any, key string) ([]string, error) {
+	switch v := args[key].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		var out []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	case []string:
+		return v, nil
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("'%s' must be a list of strings, got %T", key, item)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("'%s' must be a list of strings, got %T", key, args[key])
+}
@@ -0,0 +1,105 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings reported by detectEncoding.
+const (
+	encUTF8    = "utf-8"
+	encUTF8BOM = "utf-8-bom"
+	encUTF16LE = "utf-16le"
+	encUTF16BE = "utf-16be"
+	encLatin1  = "iso-8859-1"
+	encBinary  = "binary"
+)
+
+// sniffLen is how much of a file detectEncoding looks at.
+const sniffLen = 8192
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// detectEncoding guesses the encoding of a file from its first bytes. BOMs
+// identify UTF-8 and UTF-16; otherwise NUL bytes or a high share of control
+// characters mean binary, and text that is not valid UTF-8 is taken to be
+// Latin-1.
+func detectEncoding(sniff []byte) string {
+	switch {
+	case bytes.HasPrefix(sniff, bomUTF8):
+		return encUTF8BOM
+	case bytes.HasPrefix(sniff, bomUTF16LE):
+		return encUTF16LE
+	case bytes.HasPrefix(sniff, bomUTF16BE):
+		return encUTF16BE
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return encBinary
+	}
+
+	control := 0
+	for _, b := range sniff {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\b' && b != 0x1b {
+			control++
+		}
+	}
+	if control*10 > len(sniff) {
+		return encBinary
+	}
+
+	if len(sniff) == sniffLen {
+		// The sniff may end mid-rune.
+		sniff = trimPartialRune(sniff)
+	}
+	if utf8.Valid(sniff) {
+		return encUTF8
+	}
+	return encLatin1
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of b, which
+// appears when b is a prefix of a longer text.
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// decodeText converts raw file bytes in the given encoding to UTF-8,
+// dropping any byte order mark.
+func decodeText(raw []byte, enc string) string {
+	switch enc {
+	case encUTF8BOM:
+		return string(bytes.TrimPrefix(raw, bomUTF8))
+	case encUTF16LE, encUTF16BE:
+		raw = raw[2:]
+		var order binary.ByteOrder = binary.LittleEndian
+		if enc == encUTF16BE {
+			order = binary.BigEndian
+		}
+		units := make([]uint16, len(raw)/2)
+		for i := range units {
+			units[i] = order.Uint16(raw[2*i:])
+		}
+		return string(utf16.Decode(units))
+	case encLatin1:
+		runes := make([]rune, len(raw))
+		for i, b := range raw {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(raw)
+}
@@ -1,12 +1,19 @@
 package fs
 
 import (
+	"archive/zip"
 	gocontext "context"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/cgast/agsh/internal/sandbox"
 	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
 )
 
 func TestListCommand(t *testing.T) {
@@ -93,6 +100,92 @@ func TestListCommandNonexistentDir(t *testing.T) {
 	}
 }
 
+func TestListCommandRecursive(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("a"), 0644)
+	os.MkdirAll(filepath.Join(dir, "sub", "deep"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "b.md"), []byte("bbbb"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "c.txt"), []byte("c"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "deep", "d.MD"), []byte("dddddddd"), 0644)
+
+	list := func(args map[string]any) ([]string, agshctx.Envelope) {
+		t.Helper()
+		args["path"] = dir
+		env, err := (&ListCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+		if err != nil {
+			t.Fatalf("Execute(%v): %v", args, err)
+		}
+		var rels []string
+		for _, f := range env.Payload.([]FileEntry) {
+			rel, _ := filepath.Rel(dir, f.Path)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		return rels, env
+	}
+
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"recursive": true}, "a.md sub sub/b.md sub/c.txt sub/deep sub/deep/d.MD"},
+		{map[string]any{"max_depth": 1}, "a.md sub"},
+		{map[string]any{"max_depth": 2}, "a.md sub sub/b.md sub/c.txt sub/deep"},
+		{map[string]any{"recursive": true, "extensions": []any{".md"}}, "a.md sub/b.md sub/deep/d.MD"},
+		{map[string]any{"recursive": true, "pattern": "*.txt"}, "sub/c.txt"},
+		{map[string]any{"recursive": true, "min_size": "2B", "max_size": 4}, "sub/b.md"},
+		{map[string]any{"recursive": true, "modified_after": "1h", "extensions": "txt"}, "sub/c.txt"},
+		{map[string]any{"recursive": true, "modified_before": "2000-01-01"}, ""},
+	}
+	for _, tt := range tests {
+		got, _ := list(tt.args)
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, strings.Join(got, " "), tt.want)
+		}
+	}
+
+	got, env := list(map[string]any{"recursive": true, "offset": 2, "limit": 2})
+	if strings.Join(got, " ") != "sub/b.md sub/c.txt" {
+		t.Errorf("page = %v", got)
+	}
+	if env.Meta.Tags["total"] != "6" || env.Meta.Tags["next_offset"] != "4" {
+		t.Errorf("tags = %v", env.Meta.Tags)
+	}
+	_, env = list(map[string]any{"recursive": true, "offset": 4, "limit": 2})
+	if _, ok := env.Meta.Tags["next_offset"]; ok {
+		t.Errorf("last page has next_offset %q", env.Meta.Tags["next_offset"])
+	}
+
+	for _, bad := range []map[string]any{
+		{"pattern": "["},
+		{"min_size": "lots"},
+		{"modified_after": "yesterday"},
+		{"limit": -1},
+	} {
+		bad["path"] = dir
+		if _, err := (&ListCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(bad, "application/json", "test"), nil); err == nil {
+			t.Errorf("%v: expected error", bad)
+		}
+	}
+}
+
+func TestListCommandRecursiveSandbox(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "secret"), 0755)
+	os.WriteFile(filepath.Join(dir, "secret", "key"), []byte("k"), 0644)
+	os.WriteFile(filepath.Join(dir, "ok.txt"), []byte("ok"), 0644)
+
+	sb, _ := sandbox.New(sandbox.Config{AllowedPaths: []string{dir}, DeniedPaths: []string{filepath.Join(dir, "secret")}})
+	input := agshctx.NewEnvelope(map[string]any{"path": dir, "recursive": true}, "application/json", "test")
+	env, err := (&ListCommand{Sandbox: sb}).Execute(gocontext.Background(), input, nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	files := env.Payload.([]FileEntry)
+	if len(files) != 1 || files[0].Name != "ok.txt" {
+		t.Errorf("files = %+v, want only ok.txt", files)
+	}
+}
+
 func TestReadCommand(t *testing.T) {
 	dir := t.TempDir()
 	content := "hello, agsh!"
@@ -147,6 +240,214 @@ func TestReadCommandNonexistentFile(t *testing.T) {
 	}
 }
 
+func TestReadCommandLineRanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.txt")
+	os.WriteFile(path, []byte("one\ntwo\nthree\nfour\nfive\n"), 0644)
+
+	tests := []struct {
+		args  map[string]any
+		want  string
+		lines string
+	}{
+		{map[string]any{"head": 2}, "one\ntwo\n", "1-2"},
+		{map[string]any{"tail": float64(2)}, "four\nfive\n", "4-5"},
+		{map[string]any{"offset": 2, "limit": 2}, "two\nthree\n", "2-3"},
+		{map[string]any{"offset": "4"}, "four\nfive\n", "4-5"},
+		{map[string]any{"offset": 9}, "", "0-0"},
+	}
+
+	cmd := &ReadCommand{}
+	for _, tt := range tests {
+		tt.args["path"] = path
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(tt.args, "application/json", "test"), nil)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if env.Payload != tt.want || env.Meta.Tags["lines"] != tt.lines {
+			t.Errorf("%v: got %q (lines %s), want %q (lines %s)", tt.args, env.Payload, env.Meta.Tags["lines"], tt.want, tt.lines)
+		}
+	}
+
+	args := map[string]any{"path": path, "head": 1, "tail": 1}
+	if _, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil); err == nil {
+		t.Error("head and tail together should fail")
+	}
+}
+
+func TestReadCommandMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.txt")
+	os.WriteFile(path, []byte(strings.Repeat("x", 2000)), 0644)
+
+	sb, _ := sandbox.New(sandbox.Config{MaxFileSize: "1KB"})
+	cmd := &ReadCommand{Sandbox: sb}
+
+	if _, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(path, "text/plain", "test"), nil); err == nil {
+		t.Error("whole read over the sandbox limit should fail")
+	}
+
+	args := map[string]any{"path": path, "max_bytes": 100}
+	env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+	if err != nil {
+		t.Fatalf("max_bytes read: %v", err)
+	}
+	if len(env.Payload.(string)) != 100 || env.Meta.Tags["truncated"] != "true" {
+		t.Errorf("got %d bytes, truncated=%s", len(env.Payload.(string)), env.Meta.Tags["truncated"])
+	}
+
+	args = map[string]any{"path": path, "head": 1}
+	env, err = cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+	if err != nil {
+		t.Fatalf("head read: %v", err)
+	}
+	if len(env.Payload.(string)) != 1024 {
+		t.Errorf("partial read should be capped at the sandbox limit, got %d bytes", len(env.Payload.(string)))
+	}
+}
+
+func TestReadCommandEncodings(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"bin":     {0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0, 0, 0, 0x0d},
+		"bom":     append([]byte{0xEF, 0xBB, 0xBF}, "héllo"...),
+		"utf16le": {0xFF, 0xFE, 'h', 0, 'i', 0},
+		"latin1":  {'c', 'a', 'f', 0xE9},
+	}
+	for name, data := range files {
+		os.WriteFile(filepath.Join(dir, name), data, 0644)
+	}
+
+	cmd := &ReadCommand{}
+	read := func(name string) agshctx.Envelope {
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(filepath.Join(dir, name), "text/plain", "test"), nil)
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return env
+	}
+
+	bin, ok := read("bin").Payload.(map[string]any)
+	if !ok || bin["binary"] != true || bin["mime_type"] != "image/png" {
+		t.Errorf("binary file payload = %v", read("bin").Payload)
+	}
+	for name, want := range map[string]string{"bom": "héllo", "utf16le": "hi", "latin1": "café"} {
+		env := read(name)
+		if env.Payload != want {
+			t.Errorf("%s: got %q, want %q (encoding %s)", name, env.Payload, want, env.Meta.Tags["encoding"])
+		}
+	}
+}
+
+func TestStatCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	os.WriteFile(path, []byte("hello"), 0640)
+	os.Symlink("a.txt", filepath.Join(dir, "link"))
+	os.Symlink("missing", filepath.Join(dir, "dangling"))
+
+	stat := func(payload any) map[string]any {
+		t.Helper()
+		env, err := (&StatCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(payload, "application/json", "test"), nil)
+		if err != nil {
+			t.Fatalf("Execute(%v): %v", payload, err)
+		}
+		return env.Payload.(map[string]any)
+	}
+
+	got := stat(path)
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got["exists"] != true || got["type"] != "file" || got["size"] != int64(5) || got["hash"] != want {
+		t.Errorf("file = %v", got)
+	}
+	if runtime.GOOS != "windows" && got["mode"] != "0640" {
+		t.Errorf("mode = %v", got["mode"])
+	}
+
+	if got := stat(map[string]any{"path": path, "hash": false}); got["hash"] != nil {
+		t.Errorf("hash: false still hashed: %v", got)
+	}
+	if got := stat(map[string]any{"path": path, "algorithm": "md5"}); got["hash"] != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("md5 = %v", got["hash"])
+	}
+	if got := stat(dir); got["type"] != "dir" || got["hash"] != nil {
+		t.Errorf("dir = %v", got)
+	}
+	if got := stat(filepath.Join(dir, "nope")); got["exists"] != false {
+		t.Errorf("missing = %v", got)
+	}
+
+	if runtime.GOOS != "windows" {
+		got := stat(filepath.Join(dir, "link"))
+		if got["type"] != "symlink" || got["symlink_target"] != "a.txt" || got["hash"] != want {
+			t.Errorf("link = %v", got)
+		}
+		got = stat(filepath.Join(dir, "dangling"))
+		if got["type"] != "symlink" || got["symlink_target"] != "missing" || got["size"] != nil {
+			t.Errorf("dangling = %v", got)
+		}
+	}
+
+	if _, err := (&StatCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(map[string]any{"path": path, "algorithm": "crc"}, "application/json", "test"), nil); err == nil {
+		t.Error("unknown algorithm should fail")
+	}
+}
+
+func TestGrepCommand(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\n// TODO: one\nfunc A() {}\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "b.go"), []byte("// todo: two\r\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "c.txt"), []byte("TODO: three\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "bin"), []byte("TODO\x00\x01"), 0644)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("TODO\n"), 0644)
+
+	grep := func(args map[string]any) map[string]any {
+		t.Helper()
+		args["path"] = dir
+		env, err := (&GrepCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+		if err != nil {
+			t.Fatalf("Execute(%v): %v", args, err)
+		}
+		return env.Payload.(map[string]any)
+	}
+	texts := func(res map[string]any) string {
+		var out []string
+		for _, m := range res["matches"].([]GrepMatch) {
+			out = append(out, m.Text)
+		}
+		return strings.Join(out, "|")
+	}
+
+	res := grep(map[string]any{"pattern": "TODO"})
+	if got := texts(res); got != "// TODO: one|TODO: three" {
+		t.Errorf("matches = %q", got)
+	}
+	if res["files_searched"] != 3 {
+		t.Errorf("files_searched = %v", res["files_searched"])
+	}
+	if got := texts(grep(map[string]any{"pattern": "todo", "ignore_case": true, "include": "*.go"})); got != "// TODO: one|// todo: two" {
+		t.Errorf("ignore_case = %q", got)
+	}
+	if got := texts(grep(map[string]any{"pattern": "A()", "literal": true})); got != "func A() {}" {
+		t.Errorf("literal = %q", got)
+	}
+
+	res = grep(map[string]any{"pattern": "TODO", "context": 1, "max_matches": 1})
+	m := res["matches"].([]GrepMatch)
+	if len(m) != 1 || res["truncated"] != true {
+		t.Fatalf("max_matches: %v", res)
+	}
+	if m[0].Line != 3 || m[0].Column != 4 || strings.Join(m[0].Before, "|") != "" || strings.Join(m[0].After, "|") != "func A() {}" {
+		t.Errorf("match = %+v", m[0])
+	}
+
+	if _, err := (&GrepCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(map[string]any{"pattern": "("}, "application/json", "test"), nil); err == nil {
+		t.Error("invalid pattern should fail")
+	}
+}
+
 func TestWriteCommand(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "output.md")
@@ -182,6 +483,109 @@ func TestWriteCommand(t *testing.T) {
 	}
 }
 
+func TestWriteCommandModes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.txt")
+	cmd := &WriteCommand{}
+	write := func(args map[string]any) (agshctx.Envelope, error) {
+		args["path"] = path
+		return cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+	}
+
+	if _, err := write(map[string]any{"content": "one\n", "mode": "create_new"}); err != nil {
+		t.Fatalf("create_new: %v", err)
+	}
+	if _, err := write(map[string]any{"content": "again\n", "mode": "create_new"}); err == nil {
+		t.Error("create_new on an existing file should fail")
+	}
+	if _, err := write(map[string]any{"content": "two\n", "mode": "append"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\ntwo\n" {
+		t.Errorf("after append: %q", data)
+	}
+	if _, err := write(map[string]any{"content": "x", "mode": "truncate"}); err == nil {
+		t.Error("unknown mode should fail")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
+
+func TestWriteCommandAppendInPlace(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.log")
+	link := filepath.Join(dir, "link.log")
+	os.WriteFile(target, []byte("start\n"), 0644)
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	cmd := &WriteCommand{}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			args := map[string]any{"path": link, "content": strings.Repeat("x", i) + "\n", "mode": "append"}
+			if _, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil); err != nil {
+				t.Errorf("append %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("append replaced the symlink: %v, %v", info, err)
+	}
+	data, _ := os.ReadFile(target)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 21 || lines[0] != "start" {
+		t.Errorf("concurrent appends lost data: %d lines", len(lines))
+	}
+
+	// The symlink's target must be inside the sandbox.
+	outside := t.TempDir()
+	escape := filepath.Join(dir, "escape.log")
+	os.WriteFile(filepath.Join(outside, "secret.log"), nil, 0644)
+	os.Symlink(filepath.Join(outside, "secret.log"), escape)
+	sb, _ := sandbox.New(sandbox.Config{AllowedPaths: []string{dir}})
+	sandboxed := &WriteCommand{Sandbox: sb}
+	args := map[string]any{"path": escape, "content": "x", "mode": "append"}
+	if _, err := sandboxed.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil); err == nil {
+		t.Error("appending through a symlink out of the sandbox should fail")
+	}
+}
+
+func TestWriteCommandBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte("original"), 0600)
+
+	cmd := &WriteCommand{}
+	args := map[string]any{"path": path, "content": "replaced", "backup": true}
+	env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+
+	backup := env.Payload.(map[string]any)["backup"]
+	if backup != path+".bak" {
+		t.Fatalf("backup = %v", backup)
+	}
+	if data, _ := os.ReadFile(path + ".bak"); string(data) != "original" {
+		t.Errorf("backup content = %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "replaced" {
+		t.Errorf("file content = %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("permissions not preserved: %v", info.Mode().Perm())
+	}
+}
+
 func TestWriteCommandCreatesSubdirs(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "sub", "dir", "output.txt")
@@ -230,6 +634,95 @@ func TestWriteCommandMissingContent(t *testing.T) {
 	}
 }
 
+func TestPatchCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	original := "package main\n\n// TODO\nfunc main() {}\n"
+	os.WriteFile(path, []byte(original), 0644)
+
+	patch := func(args map[string]any) (map[string]any, error) {
+		args["path"] = path
+		env, err := (&PatchCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+		if err != nil {
+			return nil, err
+		}
+		return env.Payload.(map[string]any), nil
+	}
+
+	diff := "--- a/main.go\n+++ b/main.go\n@@ -2,2 +2,3 @@\n \n-// TODO\n+// main does nothing\n+// yet.\n"
+	res, err := patch(map[string]any{"diff": diff, "dry_run": true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := "package main\n\n// main does nothing\n// yet.\nfunc main() {}\n"
+	if res["content"] != want || res["added"] != 2 || res["removed"] != 1 {
+		t.Errorf("dry run = %v", res)
+	}
+	if data, _ := os.ReadFile(path); string(data) != original {
+		t.Fatalf("dry run wrote the file: %q", data)
+	}
+
+	// The file shifted down a line since the diff was made.
+	os.WriteFile(path, []byte("// Copyright\n"+original), 0644)
+	res, err = patch(map[string]any{"diff": diff})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "// Copyright\n"+want {
+		t.Errorf("patched = %q", data)
+	}
+	if h := res["hunks"].([]AppliedHunk); h[0].Offset != 1 || h[0].AppliedAt != 3 {
+		t.Errorf("hunks = %+v", h)
+	}
+	if data, _ := os.ReadFile(path + ".bak"); string(data) != "// Copyright\n"+original {
+		t.Errorf("backup = %q", data)
+	}
+
+	// A diff that no longer matches changes nothing.
+	if _, err := patch(map[string]any{"diff": diff}); err == nil || !strings.Contains(err.Error(), "does not apply") {
+		t.Errorf("stale diff: err = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "// Copyright\n"+want {
+		t.Errorf("failed patch changed the file: %q", data)
+	}
+
+	// Missing trailing newline on both sides.
+	os.WriteFile(path, []byte("a\nb"), 0644)
+	if _, err := patch(map[string]any{"diff": "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n", "backup": false}); err != nil {
+		t.Fatalf("no newline: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a\nc" {
+		t.Errorf("no newline = %q", data)
+	}
+
+	for _, bad := range []string{
+		"",
+		"not a diff",
+		"@@ -1,2 +1,2 @@\n a\n",
+		"--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n--- a/y\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n",
+	} {
+		if _, err := patch(map[string]any{"diff": bad}); err == nil {
+			t.Errorf("diff %q: expected error", bad)
+		}
+	}
+}
+
+func TestPatchCommandCreatesFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	diff := "--- /dev/null\n+++ b/notes/new.txt\n@@ -0,0 +1,2 @@\n+one\n+two\n"
+	env, err := (&PatchCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(map[string]any{"diff": diff}, "application/json", "test"), nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if _, ok := env.Payload.(map[string]any)["backup"]; ok {
+		t.Error("new file has a backup")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes", "new.txt")); string(data) != "one\ntwo\n" {
+		t.Errorf("created = %q", data)
+	}
+}
+
 func TestCommandIdentity(t *testing.T) {
 	commands := []struct {
 		cmd       interface{ Name() string; Namespace() string; Description() string }
@@ -238,7 +731,13 @@ func TestCommandIdentity(t *testing.T) {
 	}{
 		{&ListCommand{}, "fs:list", "fs"},
 		{&ReadCommand{}, "fs:read", "fs"},
+		{&StatCommand{}, "fs:stat", "fs"},
+		{&GrepCommand{}, "fs:grep", "fs"},
 		{&WriteCommand{}, "fs:write", "fs"},
+		{&PatchCommand{}, "fs:patch", "fs"},
+		{&WatchCommand{}, "fs:watch", "fs"},
+		{&ZipCommand{}, "fs:zip", "fs"},
+		{&UnzipCommand{}, "fs:unzip", "fs"},
 	}
 
 	for _, tt := range commands {
@@ -255,3 +754,146 @@ func TestCommandIdentity(t *testing.T) {
 		})
 	}
 }
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "old.csv"), []byte("a"), 0644)
+
+	changes := make(chan FileChange, 10)
+	w := NewWatcher(10*time.Millisecond, func(c FileChange) { changes <- c })
+	defer w.Close()
+
+	cmd := &WatchCommand{Watcher: w}
+	args := map[string]any{"path": dir, "pattern": "*.csv"}
+	env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	id := env.Payload.(map[string]any)["id"].(string)
+
+	os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.csv"), []byte("b"), 0644)
+	select {
+	case c := <-changes:
+		if c.Op != OpCreated || c.Path != filepath.Join(dir, "new.csv") || c.WatchID != id {
+			t.Errorf("unexpected change %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	os.Remove(filepath.Join(dir, "old.csv"))
+	select {
+	case c := <-changes:
+		if c.Op != OpRemoved || c.Path != filepath.Join(dir, "old.csv") {
+			t.Errorf("unexpected change %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no removal reported")
+	}
+
+	if !w.Remove(id) || len(w.List()) != 0 {
+		t.Error("watch should be removed")
+	}
+}
+
+func TestWatchCommandWithoutWatcher(t *testing.T) {
+	cmd := &WatchCommand{}
+	if _, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope("/tmp", "text/plain", "test"), nil); err == nil {
+		t.Error("expected error without a watcher")
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, name := range []string{"bundle.zip", "bundle.tar.gz", "bundle.tar"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "report")
+			os.MkdirAll(filepath.Join(src, "data"), 0755)
+			os.WriteFile(filepath.Join(src, "summary.md"), []byte("# Summary"), 0644)
+			os.WriteFile(filepath.Join(src, "data", "rows.csv"), []byte("a,b\n1,2\n"), 0644)
+
+			archive := filepath.Join(dir, name)
+			zipArgs := map[string]any{"path": archive, "sources": []any{src}}
+			env, err := (&ZipCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(zipArgs, "application/json", "test"), nil)
+			if err != nil {
+				t.Fatalf("fs:zip: %v", err)
+			}
+			if members := env.Payload.(map[string]any)["members"].([]ArchiveMember); len(members) != 2 {
+				t.Fatalf("expected 2 members, got %+v", members)
+			}
+
+			dest := filepath.Join(dir, "out")
+			unzipArgs := map[string]any{"path": archive, "dest": dest}
+			env, err = (&UnzipCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(unzipArgs, "application/json", "test"), nil)
+			if err != nil {
+				t.Fatalf("fs:unzip: %v", err)
+			}
+			members := env.Payload.(map[string]any)["members"].([]ArchiveMember)
+			if len(members) != 2 || members[0].SHA256 == "" {
+				t.Fatalf("unexpected members: %+v", members)
+			}
+			if data, _ := os.ReadFile(filepath.Join(dest, "report", "data", "rows.csv")); string(data) != "a,b\n1,2\n" {
+				t.Errorf("extracted content = %q", data)
+			}
+
+			if _, err := (&UnzipCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(unzipArgs, "application/json", "test"), nil); err == nil {
+				t.Error("extracting over existing files without overwrite should fail")
+			}
+		})
+	}
+}
+
+func TestUnzipRejectsUnsafeMembers(t *testing.T) {
+	dir := t.TempDir()
+	writeZip := func(name string, files map[string]string) string {
+		path := filepath.Join(dir, name)
+		f, _ := os.Create(path)
+		zw := zip.NewWriter(f)
+		for n, content := range files {
+			w, _ := zw.Create(n)
+			w.Write([]byte(content))
+		}
+		zw.Close()
+		f.Close()
+		return path
+	}
+	dest := filepath.Join(dir, "out")
+	sb, _ := sandbox.New(sandbox.Config{AllowedPaths: []string{dir}, MaxFileSize: "1KB"})
+	cmd := &UnzipCommand{Sandbox: sb}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"slip.zip", map[string]string{"../escape.txt": "x"}},
+		{"nested.zip", map[string]string{"a/../../escape.txt": "x"}},
+		{"abs.zip", map[string]string{"/etc/escape.txt": "x"}},
+		{"big.zip", map[string]string{"big.txt": strings.Repeat("x", 2048)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string]any{"path": writeZip(tt.name, tt.files), "dest": dest}
+			if _, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil); err == nil {
+				t.Error("expected extraction to fail")
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); err == nil {
+		t.Error("member escaped the destination")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "big.txt")); err == nil {
+		t.Error("oversized member left behind")
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, cmd := range []platform.PlatformCommand{&ListCommand{}, &ReadCommand{}, &WriteCommand{}} {
+		if len(platform.Examples(cmd)) == 0 {
+			t.Errorf("%s has no examples", cmd.Name())
+		}
+		if err := platform.CheckExamples(cmd); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package fs
+
+import (
+	gocontext "context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// defaultMaxMatches bounds fs:grep's result unless max_matches is given.
+const defaultMaxMatches = 100
+
+// GrepCommand implements fs:grep — searches files for a regular expression.
+type GrepCommand struct {
+	Sandbox *sandbox.Sandbox
+}
+
+func (c *GrepCommand) Name() string        { return "fs:grep" }
+func (c *GrepCommand) Description() string { return "Search files for a pattern" }
+func (c *GrepCommand) Namespace() string   { return "fs" }
+
+func (c *GrepCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"pattern":     {Type: "string", Description: "Regular expression (RE2 syntax) to search for"},
+			"path":        {Type: "string", Description: "File or directory to search; directories are searched recursively (default: .)"},
+			"include":     {Type: "string", Description: "Only search file names matching this glob, e.g. *.go"},
+			"context":     {Type: "integer", Description: "Lines of context to return before and after each match"},
+			"max_matches": {Type: "integer", Description: "Stop after this many matches (default: 100)"},
+			"ignore_case": {Type: "boolean", Description: "Match case-insensitively"},
+			"literal":     {Type: "boolean", Description: "Treat pattern as plain text rather than a regular expression"},
+		},
+		Required: []string{"pattern"},
+	}
+}
+
+func (c *GrepCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"matches":        {Type: "array", Description: "Matches with their path, line, column, text and context"},
+			"count":          {Type: "integer", Description: "Number of matches returned"},
+			"files_searched": {Type: "integer", Description: "Text files searched"},
+			"truncated":      {Type: "boolean", Description: "Set when max_matches stopped the search"},
+		},
+	}
+}
+
+func (c *GrepCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Find TODOs in Go files, with a line of context",
+			Input:       map[string]any{"pattern": "TODO", "path": "src", "include": "*.go", "context": 1},
+			Output: map[string]any{
+				"matches": []any{map[string]any{
+					"path": "/work/src/main.go", "line": 12, "column": 4, "text": "// TODO: flags",
+					"before": []any{"func main() {"}, "after": []any{"\tflag.Parse()"},
+				}},
+				"count": 1, "files_searched": 8,
+			},
+		},
+		{Description: "A string payload is the pattern, searched for under .", Input: "func main"},
+	}
+}
+
+func (c *GrepCommand) RequiredCredentials() []string { return nil }
+
+// GrepMatch is one matching line. Line and Column are 1-based; Column
+// counts bytes.
+type GrepMatch struct {
+	Path   string   `json:"path"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// grepOptions are the parsed arguments of fs:grep.
+type grepOptions struct {
+	re         *regexp.Regexp
+	path       string
+	include    string
+	context    int
+	maxMatches int
+}
+
+// Execute searches text files in path order. Binary files, files over the
+// sandbox's max_file_size, paths the sandbox denies and .git directories
+// are skipped.
+func (c *GrepCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
+	opts, err := extractGrepOptions(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:grep: %w", err)
+	}
+	root, err := filepath.Abs(opts.path)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:grep: resolve path: %w", err)
+	}
+	if sb != nil {
+		if err := sb.CheckPath(root); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:grep: %w", err)
+		}
+	}
+	if _, err := os.Stat(root); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:grep: %w", err)
+	}
+
+	var files []string
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // unreadable subdirectory
+		}
+		if sb != nil && sb.CheckPath(path) != nil {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if opts.include != "" && path != root {
+			if ok, _ := filepath.Match(opts.include, entry.Name()); !ok {
+				return nil
+			}
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:grep: %w", err)
+	}
+	sort.Strings(files)
+
+	matches := []GrepMatch{}
+	searched := 0
+	truncated := false
+	for _, path := range files {
+		lines, ok := readTextLines(path, sb)
+		if !ok {
+			continue
+		}
+		searched++
+		for i, line := range lines {
+			loc := opts.re.FindStringIndex(line)
+			if loc == nil {
+				continue
+			}
+			if len(matches) == opts.maxMatches {
+				truncated = true
+				break
+			}
+			m := GrepMatch{Path: path, Line: i + 1, Column: loc[0] + 1, Text: line}
+			if opts.context > 0 {
+				m.Before = lines[max(i-opts.context, 0):i]
+				m.After = lines[i+1 : min(i+1+opts.context, len(lines))]
+			}
+			matches = append(matches, m)
+		}
+		if truncated {
+			break
+		}
+	}
+
+	result := map[string]any{
+		"matches":        matches,
+		"count":          len(matches),
+		"files_searched": searched,
+	}
+	if truncated {
+		result["truncated"] = true
+	}
+	env := agshctx.NewEnvelope(result, "application/json", "fs:grep")
+	env.Meta.Tags["path"] = root
+	env.Meta.Tags["count"] = fmt.Sprintf("%d", len(matches))
+	return env, nil
+}
+
+// readTextLines returns the decoded lines of a text file. It reports false
+// for binary files, files over the sandbox's size limit and files that
+// cannot be read.
+func readTextLines(path string, sb *sandbox.Sandbox) ([]string, bool) {
+	if sb != nil {
+		if info, err := os.Stat(path); err != nil || sb.CheckFileSize(info.Size()) != nil {
+			return nil, false
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	enc := detectEncoding(raw[:min(len(raw), sniffLen)])
+	if enc == encBinary {
+		return nil, false
+	}
+	text := strings.ReplaceAll(decodeText(raw, enc), "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n"), true
+}
+
+// extractGrepOptions reads fs:grep's arguments. A string payload is the
+// pattern.
+func extractGrepOptions(input agshctx.Envelope) (grepOptions, error) {
+	opts := grepOptions{path: ".", maxMatches: defaultMaxMatches}
+	var pattern string
+	var ignoreCase, literal bool
+	switch v := input.Payload.(type) {
+	case string:
+		pattern = v
+	case map[string]any:
+		pattern, _ = v["pattern"].(string)
+		if p, _ := v["path"].(string); p != "" {
+			opts.path = p
+		}
+		opts.include, _ = v["include"].(string)
+		if opts.include != "" {
+			if _, err := filepath.Match(opts.include, ""); err != nil {
+				return opts, fmt.Errorf("invalid include %q: %w", opts.include, err)
+			}
+		}
+		ignoreCase, _ = v["ignore_case"].(bool)
+		literal, _ = v["literal"].(bool)
+		n, err := intArg(v, "context")
+		if err != nil {
+			return opts, err
+		}
+		opts.context = int(n)
+		if n, err = intArg(v, "max_matches"); err != nil {
+			return opts, err
+		} else if n > 0 {
+			opts.maxMatches = int(n)
+		}
+	default:
+		return opts, fmt.Errorf("requires a pattern string or map payload, got %T", input.Payload)
+	}
+	if pattern == "" {
+		return opts, fmt.Errorf("'pattern' is required")
+	}
+	if literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return opts, fmt.Errorf("invalid pattern: %w", err)
+	}
+	opts.re = re
+	return opts, nil
+}
@@ -3,16 +3,27 @@ package fs
 import (
 	gocontext "context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/cgast/agsh/internal/sandbox"
 	agshctx "github.com/cgast/agsh/pkg/context"
 	"github.com/cgast/agsh/pkg/platform"
 )
 
-// ListCommand implements fs:list — lists files in a directory.
+// Namespace describes the fs commands.
+var Namespace = platform.Namespace{
+	Name:        "fs",
+	Description: "Read, write and list files inside the sandbox's allowed paths",
+	Risk:        "write",
+}
+
+// ListCommand implements fs:list — lists files in a directory, optionally
+// recursively, filtered and a page at a time.
 type ListCommand struct {
 	Sandbox *sandbox.Sandbox
 }
@@ -25,18 +36,42 @@ func (c *ListCommand) InputSchema() platform.Schema {
 	return platform.Schema{
 		Type: "object",
 		Properties: map[string]platform.SchemaField{
-			"path": {Type: "string", Description: "Directory path to list"},
+			"path":            {Type: "string", Description: "Directory path to list"},
+			"recursive":       {Type: "boolean", Description: "Include subdirectories"},
+			"max_depth":       {Type: "integer", Description: "Levels of subdirectories to descend when recursive (default: all)"},
+			"pattern":         {Type: "string", Description: "Only list file names matching this glob, e.g. *.go"},
+			"extensions":      {Type: "array", Description: "Only list files with these extensions, e.g. [\"md\", \"txt\"]"},
+			"min_size":        {Type: "string", Description: "Only list files of at least this size, in bytes or as 10KB, 1MB"},
+			"max_size":        {Type: "string", Description: "Only list files of at most this size"},
+			"modified_after":  {Type: "string", Description: "Only list entries modified after this RFC 3339 time or date, or within this duration, e.g. 24h"},
+			"modified_before": {Type: "string", Description: "Only list entries modified before this time, date or duration ago"},
+			"offset":          {Type: "integer", Description: "Entries to skip, for the next page"},
+			"limit":           {Type: "integer", Description: "Maximum entries to return (default: all)"},
 		},
 		Required: []string{"path"},
 	}
 }
 
 func (c *ListCommand) OutputSchema() platform.Schema {
-	return platform.Schema{
-		Type: "object",
-		Properties: map[string]platform.SchemaField{
-			"files": {Type: "array", Description: "List of file entries"},
+	// An array of FileEntry objects.
+	return platform.Schema{Type: "array"}
+}
+
+func (c *ListCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "List a directory",
+			Input:       map[string]any{"path": "docs"},
+			Output: []any{
+				map[string]any{"name": "architecture.md", "path": "docs/architecture.md", "size": 48210, "is_dir": false},
+				map[string]any{"name": "images", "path": "docs/images", "size": 4096, "is_dir": true},
+			},
+		},
+		{
+			Description: "Markdown files under docs changed in the last day, 50 at a time",
+			Input:       map[string]any{"path": "docs", "recursive": true, "extensions": []any{"md"}, "modified_after": "24h", "limit": 50},
 		},
+		{Description: "A string payload is the path", Input: "."},
 	}
 }
 
@@ -44,58 +79,234 @@ func (c *ListCommand) RequiredCredentials() []string { return nil }
 
 // FileEntry represents a single file in a directory listing.
 type FileEntry struct {
-	Name  string `json:"name"`
-	Path  string `json:"path"`
-	Size  int64  `json:"size"`
-	IsDir bool   `json:"is_dir"`
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// listOptions select and page the entries of a listing. Name, extension
+// and size filters match files only, so directories are listed only when
+// none is set; the modification time filters match both.
+type listOptions struct {
+	recursive     bool
+	maxDepth      int64 // 0 = unlimited
+	pattern       string
+	extensions    []string // lower case, without the dot
+	minSize       int64
+	maxSize       int64 // 0 = unlimited
+	after, before time.Time
+	offset, limit int64 // limit 0 = all
+}
+
+// fileFilters reports whether a filter that only files can match is set.
+func (o listOptions) fileFilters() bool {
+	return o.pattern != "" || len(o.extensions) > 0 || o.minSize > 0 || o.maxSize > 0
+}
+
+// match reports whether an entry passes the filters.
+func (o listOptions) match(e FileEntry) bool {
+	if e.IsDir && o.fileFilters() {
+		return false
+	}
+	if o.pattern != "" {
+		if ok, _ := filepath.Match(o.pattern, e.Name); !ok {
+			return false
+		}
+	}
+	if len(o.extensions) > 0 {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(e.Name), "."))
+		found := false
+		for _, want := range o.extensions {
+			if ext == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if e.Size < o.minSize || (o.maxSize > 0 && e.Size > o.maxSize) {
+		return false
+	}
+	if !o.after.IsZero() && !e.ModTime.After(o.after) {
+		return false
+	}
+	if !o.before.IsZero() && !e.ModTime.Before(o.before) {
+		return false
+	}
+	return true
 }
 
-func (c *ListCommand) Execute(_ gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+func (c *ListCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
 	dir, err := extractPath(input)
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("fs:list: %w", err)
 	}
+	opts, err := extractListOptions(input, time.Now())
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:list: %w", err)
+	}
 
 	dir, err = filepath.Abs(dir)
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("fs:list: resolve path: %w", err)
 	}
 
-	if c.Sandbox != nil {
-		if err := c.Sandbox.CheckPath(dir); err != nil {
+	if sb != nil {
+		if err := sb.CheckPath(dir); err != nil {
 			return agshctx.Envelope{}, fmt.Errorf("fs:list: %w", err)
 		}
 	}
 
-	entries, err := os.ReadDir(dir)
+	files, err := listDir(dir, opts, sb)
 	if err != nil {
-		return agshctx.Envelope{}, fmt.Errorf("fs:list: read dir: %w", err)
-	}
-
-	files := make([]FileEntry, 0, len(entries))
-	for _, entry := range entries {
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-		files = append(files, FileEntry{
-			Name:  entry.Name(),
-			Path:  filepath.Join(dir, entry.Name()),
-			Size:  info.Size(),
-			IsDir: entry.IsDir(),
-		})
+		return agshctx.Envelope{}, fmt.Errorf("fs:list: %w", err)
 	}
 
+	// Paths sort each directory's entries by name, ahead of the entries
+	// of its subdirectories, so pages are stable.
 	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name < files[j].Name
+		return files[i].Path < files[j].Path
 	})
 
+	total := int64(len(files))
+	start := min(opts.offset, total)
+	end := total
+	if opts.limit > 0 {
+		end = min(start+opts.limit, total)
+	}
+	files = files[start:end]
+
 	env := agshctx.NewEnvelope(files, "application/json", "fs:list")
 	env.Meta.Tags["dir"] = dir
 	env.Meta.Tags["count"] = fmt.Sprintf("%d", len(files))
+	env.Meta.Tags["total"] = fmt.Sprintf("%d", total)
+	if end < total {
+		env.Meta.Tags["next_offset"] = fmt.Sprintf("%d", end)
+	}
 	return env, nil
 }
 
+// listDir returns the entries under dir that pass opts, descending into
+// subdirectories when opts.recursive is set. Entries the sandbox denies
+// are left out, and subdirectories that cannot be read are skipped.
+func listDir(dir string, opts listOptions, sb *sandbox.Sandbox) ([]FileEntry, error) {
+	if !opts.recursive {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("read dir: %w", err)
+		}
+		files := make([]FileEntry, 0, len(entries))
+		for _, entry := range entries {
+			if e, ok := fileEntry(filepath.Join(dir, entry.Name()), entry); ok && opts.match(e) {
+				files = append(files, e)
+			}
+		}
+		return files, nil
+	}
+
+	if _, err := os.ReadDir(dir); err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+	files := []FileEntry{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if path == dir {
+			return nil
+		}
+		if err != nil {
+			return nil // unreadable subdirectory
+		}
+		if sb != nil && sb.CheckPath(path) != nil {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if e, ok := fileEntry(path, entry); ok && opts.match(e) {
+			files = append(files, e)
+		}
+		if entry.IsDir() && opts.maxDepth > 0 {
+			rel, _ := filepath.Rel(dir, path)
+			if int64(strings.Count(rel, string(filepath.Separator))+1) >= opts.maxDepth {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+func fileEntry(path string, entry fs.DirEntry) (FileEntry, bool) {
+	info, err := entry.Info()
+	if err != nil {
+		return FileEntry{}, false
+	}
+	return FileEntry{
+		Name:    entry.Name(),
+		Path:    path,
+		Size:    info.Size(),
+		IsDir:   entry.IsDir(),
+		ModTime: info.ModTime().UTC(),
+	}, true
+}
+
+// extractListOptions reads the filters and page of a map payload. Times
+// given as durations are taken back from now.
+func extractListOptions(input agshctx.Envelope, now time.Time) (listOptions, error) {
+	var opts listOptions
+	args, ok := input.Payload.(map[string]any)
+	if !ok {
+		return opts, nil
+	}
+	opts.recursive, _ = args["recursive"].(bool)
+	for key, dst := range map[string]*int64{
+		"max_depth": &opts.maxDepth,
+		"offset":    &opts.offset,
+		"limit":     &opts.limit,
+	} {
+		n, err := intArg(args, key)
+		if err != nil {
+			return opts, err
+		}
+		*dst = n
+	}
+	if opts.maxDepth > 0 {
+		opts.recursive = true
+	}
+
+	opts.pattern, _ = args["pattern"].(string)
+	if opts.pattern != "" {
+		if _, err := filepath.Match(opts.pattern, ""); err != nil {
+			return opts, fmt.Errorf("invalid pattern %q: %w", opts.pattern, err)
+		}
+	}
+	exts, err := stringsArg(args, "extensions")
+	if err != nil {
+		return opts, err
+	}
+	for _, ext := range exts {
+		opts.extensions = append(opts.extensions, strings.ToLower(strings.TrimPrefix(ext, ".")))
+	}
+
+	if opts.minSize, err = sizeArg(args, "min_size"); err != nil {
+		return opts, err
+	}
+	if opts.maxSize, err = sizeArg(args, "max_size"); err != nil {
+		return opts, err
+	}
+	if opts.after, err = timeArg(args, "modified_after", now); err != nil {
+		return opts, err
+	}
+	if opts.before, err = timeArg(args, "modified_before", now); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
 // extractPath gets the directory path from the input envelope.
 // Supports string payload (path directly), or map with "path" key,
 // or falls back to args-style.
@@ -0,0 +1,421 @@
+package fs
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// PatchCommand implements fs:patch — applies a unified diff to a file.
+type PatchCommand struct {
+	Sandbox *sandbox.Sandbox
+}
+
+func (c *PatchCommand) Name() string        { return "fs:patch" }
+func (c *PatchCommand) Description() string { return "Apply a unified diff to a file" }
+func (c *PatchCommand) Namespace() string   { return "fs" }
+
+func (c *PatchCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"diff":    {Type: "string", Description: "Unified diff of one file, as from diff -u or git diff"},
+			"path":    {Type: "string", Description: "File to patch (default: the diff's +++ path, without a b/ prefix)"},
+			"dry_run": {Type: "boolean", Description: "Check that the diff applies and return the patched content without writing"},
+			"backup":  {Type: "boolean", Description: "Keep the pre-patch contents in <path>.bak (default: true)"},
+		},
+		Required: []string{"diff"},
+	}
+}
+
+func (c *PatchCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":    {Type: "string", Description: "Patched file path"},
+			"hunks":   {Type: "array", Description: "Each hunk with the line it applied at and its offset from the diff's line"},
+			"added":   {Type: "integer", Description: "Lines added"},
+			"removed": {Type: "integer", Description: "Lines removed"},
+			"dry_run": {Type: "boolean", Description: "Set when nothing was written"},
+			"content": {Type: "string", Description: "The patched content, for dry runs"},
+			"backup":  {Type: "string", Description: "Path of the pre-patch contents, if the file existed"},
+		},
+	}
+}
+
+func (c *PatchCommand) Examples() []platform.Example {
+	diff := "--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n package main\n \n-// TODO\n+// Entry point.\n"
+	return []platform.Example{
+		{
+			Description: "Preview a diff without writing",
+			Input:       map[string]any{"diff": diff, "dry_run": true},
+		},
+		{
+			Description: "Apply a diff; the previous contents are kept in main.go.bak",
+			Input:       map[string]any{"diff": diff},
+			Output: map[string]any{
+				"path": "/work/main.go", "added": 1, "removed": 1, "backup": "/work/main.go.bak",
+				"hunks": []any{map[string]any{"old_start": 1, "applied_at": 1, "offset": 0}},
+			},
+		},
+	}
+}
+
+func (c *PatchCommand) RequiredCredentials() []string { return nil }
+
+// Execute applies every hunk or none: the file is only replaced, atomically,
+// once all hunks have matched.
+func (c *PatchCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
+	args, ok := input.Payload.(map[string]any)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: requires map payload with a 'diff' key, got %T", input.Payload)
+	}
+	text, _ := args["diff"].(string)
+	if text == "" {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: 'diff' is required")
+	}
+	dryRun, _ := args["dry_run"].(bool)
+	backup := true
+	if v, ok := args["backup"].(bool); ok {
+		backup = v
+	}
+
+	fp, err := parseUnifiedDiff(text)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: %w", err)
+	}
+	filePath, _ := args["path"].(string)
+	if filePath == "" {
+		filePath = fp.target()
+	}
+	if filePath == "" {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: the diff names no file; pass path")
+	}
+	filePath, err = filepath.Abs(filePath)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: resolve path: %w", err)
+	}
+	if sb != nil {
+		if err := sb.CheckPath(filePath); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:patch: %w", err)
+		}
+	}
+
+	previous, err := os.ReadFile(filePath)
+	exists := err == nil
+	if err != nil && !(os.IsNotExist(err) && fp.creates()) {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: %w", err)
+	}
+	patched, applied, err := fp.apply(string(previous))
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: %s: %w", filePath, err)
+	}
+
+	added, removed := fp.counts()
+	result := map[string]any{
+		"path":    filePath,
+		"hunks":   applied,
+		"added":   added,
+		"removed": removed,
+	}
+	env := agshctx.NewEnvelope(result, "application/json", "fs:patch")
+	env.Meta.Tags["path"] = filePath
+	if dryRun {
+		result["dry_run"] = true
+		result["content"] = patched
+		return env, nil
+	}
+
+	if sb != nil {
+		if err := sb.CheckFileSize(int64(len(patched))); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:patch: %w", err)
+		}
+	}
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if backup && exists {
+		backupPath := filePath + backupSuffix
+		if sb != nil {
+			if err := sb.CheckPath(backupPath); err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("fs:patch: backup: %w", err)
+			}
+		}
+		if err := atomicWrite(backupPath, previous, perm, false); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:patch: backup: %w", err)
+		}
+		agshctx.RecordWrite(ctx, backupPath)
+		result["backup"] = backupPath
+		env.Meta.Tags["backup"] = backupPath
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: create dir: %w", err)
+	}
+	if err := atomicWrite(filePath, []byte(patched), perm, false); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:patch: %w", err)
+	}
+	agshctx.RecordWrite(ctx, filePath)
+	return env, nil
+}
+
+// filePatch is the parsed diff of one file.
+type filePatch struct {
+	oldName, newName string
+	hunks            []hunk
+}
+
+// hunk is one @@ section. Lines keep their ' ', '-' or '+' prefix.
+type hunk struct {
+	oldStart int
+	lines    []string
+	// oldNoEOL and newNoEOL mark that the last old or new line of the hunk
+	// has no trailing newline ("\ No newline at end of file").
+	oldNoEOL, newNoEOL bool
+}
+
+// AppliedHunk reports where a hunk applied: the 1-based line it matched at
+// and how far that is from the line the diff named.
+type AppliedHunk struct {
+	OldStart  int `json:"old_start"`
+	AppliedAt int `json:"applied_at"`
+	Offset    int `json:"offset"`
+}
+
+// parseUnifiedDiff parses a unified diff of a single file. Text before the
+// first --- line, such as git's diff and index lines, is ignored.
+func parseUnifiedDiff(text string) (filePatch, error) {
+	var fp filePatch
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var cur *hunk
+	oldLeft, newLeft := 0, 0
+	for i, line := range lines {
+		switch {
+		case cur != nil && (oldLeft > 0 || newLeft > 0) && line != "" && strings.ContainsRune(" -+", rune(line[0])):
+			switch line[0] {
+			case ' ':
+				oldLeft--
+				newLeft--
+			case '-':
+				oldLeft--
+			case '+':
+				newLeft--
+			}
+			cur.lines = append(cur.lines, line)
+		case cur != nil && (oldLeft > 0 || newLeft > 0) && line == "":
+			// Some tools drop the space of empty context lines.
+			oldLeft--
+			newLeft--
+			cur.lines = append(cur.lines, " ")
+		case strings.HasPrefix(line, `\`):
+			if cur == nil || len(cur.lines) == 0 {
+				return fp, fmt.Errorf("line %d: stray %q", i+1, line)
+			}
+			switch cur.lines[len(cur.lines)-1][0] {
+			case ' ':
+				cur.oldNoEOL, cur.newNoEOL = true, true
+			case '-':
+				cur.oldNoEOL = true
+			case '+':
+				cur.newNoEOL = true
+			}
+		case strings.HasPrefix(line, "--- "):
+			if fp.oldName != "" || len(fp.hunks) > 0 {
+				return fp, fmt.Errorf("the diff touches more than one file; fs:patch applies one at a time")
+			}
+			fp.oldName = diffName(line[4:])
+		case strings.HasPrefix(line, "+++ "):
+			fp.newName = diffName(line[4:])
+		case strings.HasPrefix(line, "@@"):
+			h, oldLen, newLen, err := parseHunkHeader(line)
+			if err != nil {
+				return fp, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			fp.hunks = append(fp.hunks, h)
+			cur = &fp.hunks[len(fp.hunks)-1]
+			oldLeft, newLeft = oldLen, newLen
+		case cur != nil && (oldLeft > 0 || newLeft > 0):
+			return fp, fmt.Errorf("line %d: unexpected %q inside a hunk", i+1, line)
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return fp, fmt.Errorf("line %d: hunk is longer than its header says", i+1)
+		}
+	}
+	if len(fp.hunks) == 0 {
+		return fp, fmt.Errorf("no hunks found in diff")
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return fp, fmt.Errorf("last hunk is shorter than its header says")
+	}
+	if fp.newName == "/dev/null" {
+		return fp, fmt.Errorf("deleting files is not supported")
+	}
+	return fp, nil
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@", where a missing count is 1.
+func parseHunkHeader(line string) (hunk, int, int, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" ||
+		!strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return hunk{}, 0, 0, fmt.Errorf("malformed hunk header %q", line)
+	}
+	oldStart, oldLen, err1 := parseRange(fields[1][1:])
+	_, newLen, err2 := parseRange(fields[2][1:])
+	if err1 != nil || err2 != nil {
+		return hunk{}, 0, 0, fmt.Errorf("malformed hunk header %q", line)
+	}
+	return hunk{oldStart: oldStart}, oldLen, newLen, nil
+}
+
+func parseRange(s string) (int, int, error) {
+	start, count, found := strings.Cut(s, ",")
+	l, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	n := 1
+	if found {
+		if n, err = strconv.Atoi(count); err != nil {
+			return 0, 0, err
+		}
+	}
+	return l, n, nil
+}
+
+// diffName returns the path of a ---/+++ line, without a timestamp or a
+// git a/ or b/ prefix.
+func diffName(s string) string {
+	name, _, _ := strings.Cut(s, "\t")
+	name = strings.TrimSpace(name)
+	if name == "/dev/null" {
+		return name
+	}
+	if rest, ok := strings.CutPrefix(name, "a/"); ok {
+		return rest
+	}
+	if rest, ok := strings.CutPrefix(name, "b/"); ok {
+		return rest
+	}
+	return name
+}
+
+// target is the file the diff patches, if it names one.
+func (fp filePatch) target() string {
+	if fp.newName != "" && fp.newName != "/dev/null" {
+		return fp.newName
+	}
+	if fp.oldName != "/dev/null" {
+		return fp.oldName
+	}
+	return ""
+}
+
+// creates reports whether the diff creates its file.
+func (fp filePatch) creates() bool {
+	return fp.oldName == "/dev/null" || (len(fp.hunks) == 1 && fp.hunks[0].oldStart == 0)
+}
+
+func (fp filePatch) counts() (added, removed int) {
+	for _, h := range fp.hunks {
+		for _, l := range h.lines {
+			switch l[0] {
+			case '+':
+				added++
+			case '-':
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// apply patches content. Each hunk must match its old lines exactly, at the
+// line the diff names or, if the file has shifted, at the nearest line
+// after the previous hunk. Line endings follow the file's.
+func (fp filePatch) apply(content string) (string, []AppliedHunk, error) {
+	eol := "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+	}
+	finalNewline := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n"), "\n")
+	}
+
+	var out []string
+	applied := make([]AppliedHunk, 0, len(fp.hunks))
+	pos := 0 // first line of lines not yet copied to out
+	for n, h := range fp.hunks {
+		var old, repl []string
+		for _, l := range h.lines {
+			if l[0] != '+' {
+				old = append(old, l[1:])
+			}
+			if l[0] != '-' {
+				repl = append(repl, l[1:])
+			}
+		}
+		want := max(h.oldStart-1, 0)
+		if len(old) == 0 {
+			want = h.oldStart // pure insertions name the line before them
+		}
+		at := findLines(lines, old, pos, want)
+		if at < 0 {
+			return "", nil, fmt.Errorf("hunk %d (at line %d) does not apply: its context does not match the file", n+1, h.oldStart)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, repl...)
+		pos = at + len(old)
+		if pos == len(lines) {
+			finalNewline = len(repl) == 0 || !h.newNoEOL
+		}
+		applied = append(applied, AppliedHunk{OldStart: h.oldStart, AppliedAt: at + 1, Offset: at - want})
+	}
+	out = append(out, lines[pos:]...)
+	if len(out) == 0 {
+		return "", applied, nil
+	}
+	patched := strings.Join(out, eol)
+	if finalNewline {
+		patched += eol
+	}
+	return patched, applied, nil
+}
+
+// findLines returns the index at or after from where lines holds old,
+// nearest to want, or -1.
+func findLines(lines, old []string, from, want int) int {
+	matches := func(at int) bool {
+		if at < from || at+len(old) > len(lines) {
+			return false
+		}
+		for i, l := range old {
+			if lines[at+i] != l {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; want-d >= from || want+d <= len(lines); d++ {
+		if matches(want - d) {
+			return want - d
+		}
+		if matches(want + d) {
+			return want + d
+		}
+	}
+	return -1
+}
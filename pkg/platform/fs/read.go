@@ -1,10 +1,14 @@
 package fs
 
 import (
+	"bufio"
 	gocontext "context"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cgast/agsh/internal/sandbox"
 	agshctx "github.com/cgast/agsh/pkg/context"
@@ -24,7 +28,12 @@ func (c *ReadCommand) InputSchema() platform.Schema {
 	return platform.Schema{
 		Type: "object",
 		Properties: map[string]platform.SchemaField{
-			"path": {Type: "string", Description: "File path to read"},
+			"path":      {Type: "string", Description: "File path to read"},
+			"offset":    {Type: "integer", Description: "First line to return, 1-based (default: 1)"},
+			"limit":     {Type: "integer", Description: "Number of lines to return from offset (default: all)"},
+			"head":      {Type: "integer", Description: "Return only the first N lines"},
+			"tail":      {Type: "integer", Description: "Return only the last N lines"},
+			"max_bytes": {Type: "integer", Description: "Truncate the returned content to this many bytes"},
 		},
 		Required: []string{"path"},
 	}
@@ -34,41 +43,253 @@ func (c *ReadCommand) OutputSchema() platform.Schema {
 	return platform.Schema{
 		Type: "object",
 		Properties: map[string]platform.SchemaField{
-			"content": {Type: "string", Description: "File contents"},
+			"content": {Type: "string", Description: "File contents, decoded to UTF-8"},
+			"binary":  {Type: "boolean", Description: "Set instead of content for binary files, with path, size and mime_type"},
 		},
 	}
 }
 
+func (c *ReadCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{Description: "Read a file; the output is its text", Input: map[string]any{"path": "README.md"}, Output: "# agsh\n..."},
+		{Description: "Read lines 10 to 29", Input: map[string]any{"path": "main.go", "offset": 10, "limit": 20}},
+		{Description: "A string payload is the path", Input: "notes.txt"},
+	}
+}
+
 func (c *ReadCommand) RequiredCredentials() []string { return nil }
 
-func (c *ReadCommand) Execute(_ gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+// readOptions selects part of a file. Lines are counted after decoding.
+type readOptions struct {
+	offset, limit int64 // 1-based first line and line count; 0 = unset
+	head, tail    int64
+	maxBytes      int64
+}
+
+// partial reports whether the options ask for less than the whole file.
+func (o readOptions) partial() bool {
+	return o.offset > 0 || o.limit > 0 || o.head > 0 || o.tail > 0 || o.maxBytes > 0
+}
+
+func (o readOptions) lineRange() bool {
+	return o.offset > 0 || o.limit > 0 || o.head > 0 || o.tail > 0
+}
+
+func (c *ReadCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
 	filePath, err := extractFilePath(input)
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("fs:read: %w", err)
 	}
+	opts, err := extractReadOptions(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:read: %w", err)
+	}
 
 	filePath, err = filepath.Abs(filePath)
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("fs:read: resolve path: %w", err)
 	}
 
-	if c.Sandbox != nil {
-		if err := c.Sandbox.CheckPath(filePath); err != nil {
+	if sb != nil {
+		if err := sb.CheckPath(filePath); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:read: %w", err)
+		}
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:read: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:read: %w", err)
+	}
+	if info.IsDir() {
+		return agshctx.Envelope{}, fmt.Errorf("fs:read: %s is a directory", filePath)
+	}
+
+	// Whole-file reads must fit the sandbox limit; partial reads are capped
+	// at it instead.
+	maxBytes := opts.maxBytes
+	if sb != nil && sb.MaxFileSize() > 0 {
+		limit := sb.MaxFileSize()
+		if !opts.partial() {
+			if err := sb.CheckFileSize(info.Size()); err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("fs:read: %w; use max_bytes, head, tail or offset/limit to read part of it", err)
+			}
+		}
+		if maxBytes == 0 || maxBytes > limit {
+			maxBytes = limit
+		}
+	}
+
+	br := bufio.NewReaderSize(f, sniffLen)
+	sniff, _ := br.Peek(sniffLen)
+	enc := detectEncoding(sniff)
+
+	if enc == encBinary {
+		result := map[string]any{
+			"path":      filePath,
+			"binary":    true,
+			"size":      info.Size(),
+			"mime_type": http.DetectContentType(sniff),
+		}
+		env := agshctx.NewEnvelope(result, "application/json", "fs:read")
+		env.Meta.Tags["path"] = filePath
+		env.Meta.Tags["size"] = fmt.Sprintf("%d", info.Size())
+		env.Meta.Tags["encoding"] = enc
+		return env, nil
+	}
+
+	// text yields the file decoded to UTF-8.
+	var text io.Reader = br
+	switch enc {
+	case encUTF8:
+	case encUTF8BOM:
+		br.Discard(len(bomUTF8))
+	default:
+		var raw []byte
+		if !opts.lineRange() && maxBytes > 0 {
+			// Decoding never shrinks text by more than 4x.
+			raw, err = io.ReadAll(io.LimitReader(br, 4*maxBytes+2))
+		} else {
+			raw, err = io.ReadAll(br)
+		}
+		if err != nil {
 			return agshctx.Envelope{}, fmt.Errorf("fs:read: %w", err)
 		}
+		text = strings.NewReader(decodeText(raw, enc))
 	}
 
-	data, err := os.ReadFile(filePath)
+	var content string
+	var first, last int64
+	if opts.lineRange() {
+		content, first, last, err = selectLines(text, opts)
+	} else {
+		var data []byte
+		if maxBytes > 0 {
+			data, err = io.ReadAll(io.LimitReader(text, maxBytes+1))
+		} else {
+			data, err = io.ReadAll(text)
+		}
+		content = string(data)
+	}
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("fs:read: %w", err)
 	}
 
-	env := agshctx.NewEnvelope(string(data), "text/plain", "fs:read")
+	truncated := false
+	if maxBytes > 0 && int64(len(content)) > maxBytes {
+		content = string(trimPartialRune([]byte(content[:maxBytes])))
+		truncated = true
+	}
+
+	env := agshctx.NewEnvelope(content, "text/plain", "fs:read")
 	env.Meta.Tags["path"] = filePath
-	env.Meta.Tags["size"] = fmt.Sprintf("%d", len(data))
+	env.Meta.Tags["size"] = fmt.Sprintf("%d", info.Size())
+	env.Meta.Tags["encoding"] = enc
+	if opts.lineRange() {
+		env.Meta.Tags["lines"] = fmt.Sprintf("%d-%d", first, last)
+	}
+	if truncated {
+		env.Meta.Tags["truncated"] = "true"
+	}
 	return env, nil
 }
 
+// extractReadOptions reads the optional range arguments of a map payload.
+func extractReadOptions(input agshctx.Envelope) (readOptions, error) {
+	var opts readOptions
+	args, ok := input.Payload.(map[string]any)
+	if !ok {
+		return opts, nil
+	}
+	for key, dst := range map[string]*int64{
+		"offset":    &opts.offset,
+		"limit":     &opts.limit,
+		"head":      &opts.head,
+		"tail":      &opts.tail,
+		"max_bytes": &opts.maxBytes,
+	} {
+		n, err := intArg(args, key)
+		if err != nil {
+			return opts, err
+		}
+		*dst = n
+	}
+
+	ranges := 0
+	if opts.offset > 0 || opts.limit > 0 {
+		ranges++
+	}
+	if opts.head > 0 {
+		ranges++
+	}
+	if opts.tail > 0 {
+		ranges++
+	}
+	if ranges > 1 {
+		return opts, fmt.Errorf("offset/limit, head and tail cannot be combined")
+	}
+	return opts, nil
+}
+
+// selectLines returns the lines of r chosen by opts, keeping line endings,
+// and the 1-based numbers of the first and last line returned (0-0 when
+// none are). Reading stops as soon as the range is complete, except for
+// tail, which has to see the whole input.
+func selectLines(r io.Reader, opts readOptions) (string, int64, int64, error) {
+	start, count := int64(1), int64(0)
+	switch {
+	case opts.head > 0:
+		count = opts.head
+	case opts.offset > 0 || opts.limit > 0:
+		if opts.offset > 0 {
+			start = opts.offset
+		}
+		count = opts.limit
+	}
+
+	br := bufio.NewReader(r)
+	var selected []string
+	var n int64
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			n++
+			switch {
+			case opts.tail > 0:
+				selected = append(selected, line)
+				if int64(len(selected)) > opts.tail {
+					selected = selected[1:]
+				}
+			case n >= start:
+				selected = append(selected, line)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, 0, err
+		}
+		if opts.tail == 0 && count > 0 && n >= start+count-1 {
+			break
+		}
+	}
+
+	if len(selected) == 0 {
+		return "", 0, 0, nil
+	}
+	last := n
+	if opts.tail == 0 {
+		last = start + int64(len(selected)) - 1
+	}
+	return strings.Join(selected, ""), last - int64(len(selected)) + 1, last, nil
+}
+
 // extractFilePath gets a file path from the input envelope.
 // Supports string payload, map with "path" key, or FileEntry from fs:list.
 func extractFilePath(input agshctx.Envelope) (string, error) {
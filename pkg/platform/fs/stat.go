@@ -0,0 +1,169 @@
+package fs
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cgast/agsh/internal/digest"
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// StatCommand implements fs:stat — reports a path's metadata and a digest
+// of its contents, so steps can check a file without reading it.
+type StatCommand struct {
+	Sandbox *sandbox.Sandbox
+}
+
+func (c *StatCommand) Name() string { return "fs:stat" }
+func (c *StatCommand) Description() string {
+	return "Report a file's size, mode, modification time, symlink target and content hash"
+}
+func (c *StatCommand) Namespace() string { return "fs" }
+
+func (c *StatCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":      {Type: "string", Description: "File or directory to describe"},
+			"hash":      {Type: "boolean", Description: "Hash a regular file's contents (default: true)"},
+			"algorithm": {Type: "string", Description: "md5, sha1, sha256 (default) or sha512"},
+		},
+		Required: []string{"path"},
+	}
+}
+
+func (c *StatCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":           {Type: "string", Description: "Absolute path"},
+			"exists":         {Type: "boolean", Description: "Whether the path exists; the other fields are only set when it does"},
+			"type":           {Type: "string", Description: "file, dir, symlink or other"},
+			"size":           {Type: "integer", Description: "Size in bytes"},
+			"mode":           {Type: "string", Description: "Permission bits in octal, e.g. 0644"},
+			"mod_time":       {Type: "string", Description: "Last modification time (RFC 3339)"},
+			"symlink_target": {Type: "string", Description: "Where a symlink points"},
+			"algorithm":      {Type: "string", Description: "Hash algorithm"},
+			"hash":           {Type: "string", Description: "Hex digest of a regular file's contents"},
+		},
+	}
+}
+
+func (c *StatCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Describe a file",
+			Input:       map[string]any{"path": "reports/weekly.md"},
+			Output: map[string]any{
+				"path": "/work/reports/weekly.md", "exists": true, "type": "file", "size": 2048,
+				"mode": "0644", "mod_time": "2025-02-03T09:15:00Z", "algorithm": "sha256", "hash": "9f86d0...",
+			},
+		},
+		{Description: "Skip hashing a large file", Input: map[string]any{"path": "data/dump.sql", "hash": false}},
+		{Description: "A string payload is the path", Input: "README.md"},
+	}
+}
+
+func (c *StatCommand) RequiredCredentials() []string { return nil }
+
+// Execute describes the path itself, so a symlink is reported as one with
+// its target; its size and hash are those of the file it points to, if that
+// is inside the sandbox. A missing path is not an error: it is reported
+// with exists false.
+func (c *StatCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
+	path, err := extractFilePath(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:stat: %w", err)
+	}
+	args, _ := input.Payload.(map[string]any)
+	hash := true
+	if v, ok := args["hash"].(bool); ok {
+		hash = v
+	}
+	algorithm, _ := args["algorithm"].(string)
+	algorithm = strings.ToLower(algorithm)
+	if algorithm == "" {
+		algorithm = digest.Default
+	}
+	if !digest.Supported(algorithm) {
+		return agshctx.Envelope{}, fmt.Errorf("fs:stat: unknown algorithm %q", algorithm)
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:stat: resolve path: %w", err)
+	}
+	if sb != nil {
+		if err := sb.CheckPath(path); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:stat: %w", err)
+		}
+	}
+
+	result := map[string]any{"path": path}
+	env := agshctx.NewEnvelope(result, "application/json", "fs:stat")
+	env.Meta.Tags["path"] = path
+
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		result["exists"] = false
+		return env, nil
+	}
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:stat: %w", err)
+	}
+	result["exists"] = true
+	result["mode"] = fmt.Sprintf("%04o", info.Mode().Perm())
+	result["mod_time"] = info.ModTime().UTC()
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		result["type"] = "symlink"
+		target, err := os.Readlink(path)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:stat: %w", err)
+		}
+		result["symlink_target"] = target
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil || (sb != nil && sb.CheckPath(resolved) != nil) {
+			return env, nil // dangling, or pointing outside the sandbox
+		}
+		if info, err = os.Stat(resolved); err != nil {
+			return env, nil
+		}
+		path = resolved
+	} else {
+		result["type"] = fileType(info.Mode())
+	}
+	result["size"] = info.Size()
+
+	if hash && info.Mode().IsRegular() {
+		f, err := os.Open(path)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:stat: %w", err)
+		}
+		defer f.Close()
+		sum, _, err := digest.Sum(algorithm, f)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:stat: %w", err)
+		}
+		result["algorithm"] = algorithm
+		result["hash"] = sum
+		env.Meta.Tags["hash"] = algorithm + ":" + sum
+	}
+	return env, nil
+}
+
+func fileType(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "dir"
+	}
+	return "other"
+}
@@ -0,0 +1,294 @@
+package fs
+
+import (
+	gocontext "context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// File change operations reported by Watcher.
+const (
+	OpCreated  = "created"
+	OpModified = "modified"
+	OpRemoved  = "removed"
+)
+
+// FileChange describes one change seen by a watch.
+type FileChange struct {
+	WatchID string    `json:"watch_id"`
+	Path    string    `json:"path"`
+	Op      string    `json:"op"`
+	Time    time.Time `json:"time"`
+}
+
+// WatchInfo describes an active watch.
+type WatchInfo struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	Pattern   string `json:"pattern,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+// Watcher polls watched paths and reports changes to a callback until it is
+// closed. Polling keeps it dependency-free and portable; changes are seen
+// within one interval.
+type Watcher struct {
+	interval time.Duration
+	notify   func(FileChange)
+
+	mu      sync.Mutex
+	watches map[string]*watch
+	nextID  int
+	wg      sync.WaitGroup
+}
+
+type watch struct {
+	info   WatchInfo
+	cancel gocontext.CancelFunc
+}
+
+// NewWatcher creates a Watcher that scans every interval and calls notify
+// for each change.
+func NewWatcher(interval time.Duration, notify func(FileChange)) *Watcher {
+	return &Watcher{
+		interval: interval,
+		notify:   notify,
+		watches:  make(map[string]*watch),
+	}
+}
+
+// Add starts watching path, a file or directory. For directories, pattern
+// (e.g. "*.csv") restricts which file names are reported and recursive
+// includes subdirectories. It returns the watch id.
+func (w *Watcher) Add(path, pattern string, recursive bool) (string, error) {
+	if pattern != "" {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return "", fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	info := WatchInfo{Path: path, Pattern: pattern, Recursive: recursive}
+	initial := w.scan(info)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextID++
+	info.ID = fmt.Sprintf("watch-%d", w.nextID)
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	w.watches[info.ID] = &watch{info: info, cancel: cancel}
+	w.wg.Add(1)
+	go w.run(ctx, info, initial)
+	return info.ID, nil
+}
+
+// Remove stops a watch. It reports whether the watch existed.
+func (w *Watcher) Remove(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	wt, ok := w.watches[id]
+	if ok {
+		wt.cancel()
+		delete(w.watches, id)
+	}
+	return ok
+}
+
+// List returns the active watches sorted by id.
+func (w *Watcher) List() []WatchInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	infos := make([]WatchInfo, 0, len(w.watches))
+	for _, wt := range w.watches {
+		infos = append(infos, wt.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Close stops all watches and waits for them to finish.
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	for id, wt := range w.watches {
+		wt.cancel()
+		delete(w.watches, id)
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+func (w *Watcher) run(ctx gocontext.Context, info WatchInfo, last map[string]fileStamp) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := w.scan(info)
+		now := time.Now()
+		for _, p := range sortedPaths(current) {
+			old, seen := last[p]
+			switch {
+			case !seen:
+				w.notify(FileChange{WatchID: info.ID, Path: p, Op: OpCreated, Time: now})
+			case old != current[p]:
+				w.notify(FileChange{WatchID: info.ID, Path: p, Op: OpModified, Time: now})
+			}
+		}
+		for _, p := range sortedPaths(last) {
+			if _, ok := current[p]; !ok {
+				w.notify(FileChange{WatchID: info.ID, Path: p, Op: OpRemoved, Time: now})
+			}
+		}
+		last = current
+	}
+}
+
+// fileStamp identifies a version of a file well enough to notice edits.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// scan records the files currently matched by a watch.
+func (w *Watcher) scan(info WatchInfo) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	root, err := os.Stat(info.Path)
+	if err != nil {
+		return files
+	}
+	if !root.IsDir() {
+		files[info.Path] = fileStamp{size: root.Size(), modTime: root.ModTime()}
+		return files
+	}
+
+	filepath.WalkDir(info.Path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != info.Path && !info.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Pattern != "" {
+			if ok, _ := filepath.Match(info.Pattern, d.Name()); !ok {
+				return nil
+			}
+		}
+		if fi, err := d.Info(); err == nil {
+			files[p] = fileStamp{size: fi.Size(), modTime: fi.ModTime()}
+		}
+		return nil
+	})
+	return files
+}
+
+func sortedPaths(m map[string]fileStamp) []string {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// WatchCommand implements fs:watch — starts, stops and lists watches that
+// report file changes as fs.changed events for the rest of the session.
+type WatchCommand struct {
+	Sandbox *sandbox.Sandbox
+	Watcher *Watcher // nil when the session cannot deliver events
+}
+
+func (c *WatchCommand) Name() string { return "fs:watch" }
+func (c *WatchCommand) Description() string {
+	return "Watch a file or directory and emit fs.changed events"
+}
+func (c *WatchCommand) Namespace() string { return "fs" }
+
+func (c *WatchCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":      {Type: "string", Description: "File or directory to watch"},
+			"pattern":   {Type: "string", Description: "Only report file names matching this glob, e.g. *.csv"},
+			"recursive": {Type: "boolean", Description: "Include subdirectories"},
+			"action":    {Type: "string", Description: "add (default), remove, or list"},
+			"id":        {Type: "string", Description: "Watch id, for remove"},
+		},
+	}
+}
+
+func (c *WatchCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"id":      {Type: "string", Description: "Id of the new watch"},
+			"path":    {Type: "string", Description: "Watched path"},
+			"watches": {Type: "array", Description: "Active watches, for list"},
+		},
+	}
+}
+
+func (c *WatchCommand) RequiredCredentials() []string { return nil }
+
+func (c *WatchCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
+	if c.Watcher == nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:watch: only available in interactive and agent sessions")
+	}
+
+	args, _ := input.Payload.(map[string]any)
+	action, _ := args["action"].(string)
+	switch action {
+	case "list":
+		return agshctx.NewEnvelope(map[string]any{"watches": c.Watcher.List()}, "application/json", "fs:watch"), nil
+	case "remove":
+		id, _ := args["id"].(string)
+		if !c.Watcher.Remove(id) {
+			return agshctx.Envelope{}, fmt.Errorf("fs:watch: no watch %q", id)
+		}
+		return agshctx.NewEnvelope(map[string]any{"id": id, "removed": true}, "application/json", "fs:watch"), nil
+	case "", "add":
+	default:
+		return agshctx.Envelope{}, fmt.Errorf("fs:watch: unknown action %q (expected add, remove or list)", action)
+	}
+
+	path, err := extractFilePath(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:watch: %w", err)
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:watch: resolve path: %w", err)
+	}
+	if sb != nil {
+		if err := sb.CheckPath(path); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:watch: %w", err)
+		}
+	}
+
+	pattern, _ := args["pattern"].(string)
+	recursive, _ := args["recursive"].(bool)
+	id, err := c.Watcher.Add(path, pattern, recursive)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:watch: %w", err)
+	}
+
+	env := agshctx.NewEnvelope(map[string]any{"id": id, "path": path}, "application/json", "fs:watch")
+	env.Meta.Tags["path"] = path
+	return env, nil
+}
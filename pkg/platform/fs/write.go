@@ -26,6 +26,8 @@ func (c *WriteCommand) InputSchema() platform.Schema {
 		Properties: map[string]platform.SchemaField{
 			"path":    {Type: "string", Description: "File path to write"},
 			"content": {Type: "string", Description: "Content to write"},
+			"mode":    {Type: "string", Description: "overwrite (default), append, or create_new (fail if the file exists)"},
+			"backup":  {Type: "boolean", Description: "Keep the previous contents in <path>.bak when changing an existing file"},
 		},
 		Required: []string{"path", "content"},
 	}
@@ -37,28 +39,96 @@ func (c *WriteCommand) OutputSchema() platform.Schema {
 		Properties: map[string]platform.SchemaField{
 			"path":          {Type: "string", Description: "Written file path"},
 			"bytes_written": {Type: "integer", Description: "Number of bytes written"},
+			"mode":          {Type: "string", Description: "Write mode used"},
+			"backup":        {Type: "string", Description: "Path of the backup of the previous contents, if one was made"},
+		},
+	}
+}
+
+func (c *WriteCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Write a file; the payload must be a map with path and content",
+			Input:       map[string]any{"path": "reports/weekly.md", "content": "# Weekly report\n"},
+			Output:      map[string]any{"path": "/work/reports/weekly.md", "bytes_written": 16, "mode": "overwrite"},
+		},
+		{
+			Description: "Append to a log, keeping a backup",
+			Input:       map[string]any{"path": "notes.log", "content": "done\n", "mode": "append", "backup": true},
 		},
 	}
 }
 
 func (c *WriteCommand) RequiredCredentials() []string { return nil }
 
-func (c *WriteCommand) Execute(_ gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+// Write modes.
+const (
+	modeOverwrite = "overwrite"
+	modeAppend    = "append"
+	modeCreateNew = "create_new"
+)
+
+// backupSuffix is appended to a file's path to name its backup.
+const backupSuffix = ".bak"
+
+// Execute writes the file atomically: the new contents go to a temporary
+// file in the same directory, which then replaces the target, so readers
+// never see a partial write. Append mode instead writes to the end of the
+// file in place, so concurrent appends are kept and a symlink stays one.
+func (c *WriteCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
 	filePath, content, err := extractWriteParams(input)
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("fs:write: %w", err)
 	}
+	mode, backup, err := extractWriteOptions(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("fs:write: %w", err)
+	}
 
 	filePath, err = filepath.Abs(filePath)
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("fs:write: resolve path: %w", err)
 	}
 
-	if c.Sandbox != nil {
-		if err := c.Sandbox.CheckPath(filePath); err != nil {
+	if sb != nil {
+		if err := sb.CheckPath(filePath); err != nil {
 			return agshctx.Envelope{}, fmt.Errorf("fs:write: %w", err)
 		}
-		if err := c.Sandbox.CheckFileSize(int64(len(content))); err != nil {
+		if backup {
+			if err := sb.CheckPath(filePath + backupSuffix); err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("fs:write: backup: %w", err)
+			}
+		}
+	}
+
+	info, err := os.Stat(filePath)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return agshctx.Envelope{}, fmt.Errorf("fs:write: %w", err)
+	}
+	if exists && mode == modeCreateNew {
+		return agshctx.Envelope{}, fmt.Errorf("fs:write: %s already exists", filePath)
+	}
+
+	data := []byte(content)
+	size := int64(len(data))
+	if mode == modeAppend && exists {
+		size += info.Size()
+		if sb != nil {
+			// Appending writes through a symlink, so its target must be
+			// allowed too.
+			resolved, err := filepath.EvalSymlinks(filePath)
+			if err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("fs:write: %w", err)
+			}
+			if err := sb.CheckPath(resolved); err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("fs:write: %w", err)
+			}
+		}
+	}
+	if sb != nil {
+		if err := sb.CheckFileSize(size); err != nil {
 			return agshctx.Envelope{}, fmt.Errorf("fs:write: %w", err)
 		}
 	}
@@ -69,19 +139,141 @@ func (c *WriteCommand) Execute(_ gocontext.Context, input agshctx.Envelope, _ ag
 		return agshctx.Envelope{}, fmt.Errorf("fs:write: create dir: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+	perm := os.FileMode(0644)
+	if exists {
+		perm = info.Mode().Perm()
+	}
+
+	backupPath := ""
+	if backup && exists {
+		previous, err := os.ReadFile(filePath)
+		if err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:write: backup: %w", err)
+		}
+		backupPath = filePath + backupSuffix
+		if err := atomicWrite(backupPath, previous, perm, false); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("fs:write: backup: %w", err)
+		}
+		agshctx.RecordWrite(ctx, backupPath)
+	}
+
+	if mode == modeAppend {
+		err = appendFile(filePath, data, perm)
+	} else {
+		err = atomicWrite(filePath, data, perm, mode == modeCreateNew)
+	}
+	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("fs:write: %w", err)
 	}
+	agshctx.RecordWrite(ctx, filePath)
 
 	result := map[string]any{
 		"path":          filePath,
 		"bytes_written": len(content),
+		"mode":          mode,
+	}
+	if backupPath != "" {
+		result["backup"] = backupPath
 	}
 	env := agshctx.NewEnvelope(result, "application/json", "fs:write")
 	env.Meta.Tags["path"] = filePath
+	if backupPath != "" {
+		env.Meta.Tags["backup"] = backupPath
+	}
 	return env, nil
 }
 
+// atomicWrite writes data to a temporary file next to path and moves it into
+// place. With exclusive, it fails instead of replacing an existing file.
+func atomicWrite(path string, data []byte, perm os.FileMode, exclusive bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed or linked
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+
+	if exclusive {
+		// Link fails if path exists, unlike Rename.
+		if err := os.Link(tmpName, path); err != nil {
+			if os.IsExist(err) {
+				return fmt.Errorf("%s already exists", path)
+			}
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmpName, path)
+}
+
+// appendFile appends data to path, creating it with perm if needed. The
+// write uses O_APPEND, so concurrent appenders do not overwrite each other.
+func appendFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// extractWriteOptions reads the optional mode and backup arguments.
+func extractWriteOptions(input agshctx.Envelope) (string, bool, error) {
+	mode := modeOverwrite
+	args, ok := input.Payload.(map[string]any)
+	if !ok {
+		return mode, false, nil
+	}
+
+	if v, ok := args["mode"]; ok && v != nil {
+		s, ok := v.(string)
+		if !ok {
+			return "", false, fmt.Errorf("'mode' must be a string")
+		}
+		switch s {
+		case "":
+		case modeOverwrite, modeAppend, modeCreateNew:
+			mode = s
+		default:
+			return "", false, fmt.Errorf("unknown mode %q (expected overwrite, append or create_new)", s)
+		}
+	}
+
+	backup := false
+	switch v := args["backup"].(type) {
+	case nil:
+	case bool:
+		backup = v
+	case string:
+		backup = v == "true"
+	default:
+		return "", false, fmt.Errorf("'backup' must be a boolean")
+	}
+	return mode, backup, nil
+}
+
 // extractWriteParams gets the file path and content from the input envelope.
 func extractWriteParams(input agshctx.Envelope) (string, string, error) {
 	switch v := input.Payload.(type) {
@@ -0,0 +1,57 @@
+package github
+
+import (
+	"fmt"
+	"path"
+)
+
+// route sends one account's owners to its client.
+type route struct {
+	owners []string
+	client *Client
+}
+
+// Route sends requests for repositories whose owner matches one of owners
+// through account, which is reported under name. Owners are user or
+// organization names or path.Match patterns such as "acme-*". Exact names
+// win over patterns; otherwise routes are tried in the order added.
+func (c *Client) Route(name string, account *Client, owners []string) error {
+	for _, o := range owners {
+		if _, err := path.Match(o, ""); err != nil {
+			return fmt.Errorf("github account %s: invalid owner pattern %q", name, o)
+		}
+	}
+	account.account = name
+	c.routes = append(c.routes, route{owners: owners, client: account})
+	return nil
+}
+
+// For returns the client for repositories owned by owner: a routed account
+// if one matches, otherwise c. The zero Client has no default account and
+// only serves routed owners.
+func (c *Client) For(owner string) (*Client, error) {
+	for _, r := range c.routes {
+		for _, o := range r.owners {
+			if o == owner {
+				return r.client, nil
+			}
+		}
+	}
+	for _, r := range c.routes {
+		for _, o := range r.owners {
+			if ok, _ := path.Match(o, owner); ok {
+				return r.client, nil
+			}
+		}
+	}
+	if c.inner == nil {
+		return nil, fmt.Errorf("no github account configured for owner %q", owner)
+	}
+	return c, nil
+}
+
+// Account returns the name of the account the client was routed under, or
+// "" for the default account.
+func (c *Client) Account() string {
+	return c.account
+}
@@ -1,16 +1,31 @@
 package github
 
 import (
+	gocontext "context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
 	gh "github.com/google/go-github/v60/github"
 )
 
+// Namespace describes the github commands.
+var Namespace = platform.Namespace{
+	Name:        "github",
+	Description: "Query repositories and pull requests and create issues via the GitHub API",
+	Credentials: []string{"GITHUB_TOKEN"},
+	Risk:        "read-only",
+}
+
 // Client wraps the GitHub API client with token authentication.
 type Client struct {
-	inner *gh.Client
-	token string
+	inner   *gh.Client
+	token   string
+	account string  // set by Route
+	routes  []route // accounts for specific owners
 }
 
 // NewClient creates a GitHub API client with the given token.
@@ -25,6 +40,33 @@ func NewClient(token string) (*Client, error) {
 	return &Client{inner: client, token: token}, nil
 }
 
+// NewEnterpriseClient creates a client for a GitHub Enterprise Server
+// instance. "/api/v3/" is appended to baseURL when missing; an empty
+// uploadURL uses the same host as baseURL.
+func NewEnterpriseClient(token, baseURL, uploadURL string) (*Client, error) {
+	c, err := NewClient(token)
+	if err != nil {
+		return nil, err
+	}
+	if uploadURL == "" {
+		uploadURL = strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/api/v3")
+	}
+	c.inner, err = c.inner.WithEnterpriseURLs(baseURL, uploadURL)
+	if err != nil {
+		return nil, fmt.Errorf("github enterprise URL: %w", err)
+	}
+	return c, nil
+}
+
+// HealthCheck verifies that the token is accepted by fetching the
+// authenticated user.
+func (c *Client) HealthCheck(ctx gocontext.Context) error {
+	if _, _, err := c.inner.Users.Get(ctx, ""); err != nil {
+		return fmt.Errorf("github token check: %w", err)
+	}
+	return nil
+}
+
 // tokenTransport adds Bearer token auth to HTTP requests.
 type tokenTransport struct {
 	token string
@@ -32,5 +74,30 @@ type tokenTransport struct {
 
 func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	req.Header.Set("Authorization", "Bearer "+t.token)
+	if err := agshctx.CheckDomain(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	agshctx.RecordDomain(req.Context(), req.URL.Hostname())
 	return http.DefaultTransport.RoundTrip(req)
 }
+
+// apiError classifies a go-github error by the response status so that
+// rate limits and auth failures reach the caller as typed errors.
+func apiError(err error) error {
+	var (
+		rateErr  *gh.RateLimitError
+		abuseErr *gh.AbuseRateLimitError
+		respErr  *gh.ErrorResponse
+	)
+	var typed *platform.Error
+	switch {
+	case errors.As(err, &rateErr), errors.As(err, &abuseErr):
+		typed = platform.StatusError(http.StatusTooManyRequests, err.Error())
+	case errors.As(err, &respErr) && respErr.Response != nil:
+		typed = platform.StatusError(respErr.Response.StatusCode, err.Error())
+	default:
+		return err
+	}
+	typed.Err = err
+	return typed
+}
@@ -1,9 +1,15 @@
 package github
 
 import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
 )
 
 func TestExtractRepo(t *testing.T) {
@@ -96,3 +102,169 @@ func TestCommandIdentity(t *testing.T) {
 		t.Errorf("IssueCreateCommand.Name() = %q", issueCreate.Name())
 	}
 }
+
+func TestEnterpriseClient(t *testing.T) {
+	var gotPath, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		w.Write([]byte(`{"login":"octocat"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewEnterpriseClient("ghe_token", srv.URL, "")
+	if err != nil {
+		t.Fatalf("NewEnterpriseClient: %v", err)
+	}
+	if err := client.HealthCheck(gocontext.Background()); err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+	if gotPath != "/api/v3/user" {
+		t.Errorf("request path = %q, want /api/v3/user", gotPath)
+	}
+	if gotAuth != "Bearer ghe_token" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if got := client.inner.UploadURL.String(); got != srv.URL+"/api/uploads/" {
+		t.Errorf("upload URL = %q", got)
+	}
+}
+
+func TestClientRouting(t *testing.T) {
+	root, _ := NewClient("default")
+	work, _ := NewClient("work")
+	oss, _ := NewClient("oss")
+	if err := root.Route("work", work, []string{"acme-*"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Route("oss", oss, []string{"acme-oss", "golang"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Route("bad", oss, []string{"[acme"}); err == nil {
+		t.Error("expected error for invalid owner pattern")
+	}
+
+	tests := []struct {
+		owner   string
+		account string
+	}{
+		{"acme-internal", "work"},
+		{"acme-oss", "oss"}, // exact name beats an earlier pattern
+		{"golang", "oss"},
+		{"cgast", ""},
+	}
+	for _, tt := range tests {
+		client, err := root.For(tt.owner)
+		if err != nil {
+			t.Fatalf("For(%s): %v", tt.owner, err)
+		}
+		if client.Account() != tt.account {
+			t.Errorf("For(%s) = account %q, want %q", tt.owner, client.Account(), tt.account)
+		}
+	}
+
+	noDefault := &Client{}
+	noDefault.Route("work", work, []string{"acme"})
+	if _, err := noDefault.For("cgast"); err == nil {
+		t.Error("expected error for an owner without an account")
+	}
+}
+
+func TestOperationKinds(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want []string
+	}{
+		{`{ viewer { login } }`, []string{"query"}},
+		{`query Repo($owner: String!) { repository(owner: $owner, name: "x") { id } }`, []string{"query"}},
+		{`mutation { addStar(input: {starrableId: "1"}) { clientMutationId } }`, []string{"mutation"}},
+		{"# mutation in a comment\n{ viewer { login } }", []string{"query"}},
+		{`{ search(query: "mutation {") { issueCount } }`, []string{"query"}},
+		{`fragment F on User { login } query { viewer { ...F } }`, []string{"fragment", "query"}},
+		{`query A { a } mutation B { b }`, []string{"query", "mutation"}},
+	}
+	for _, tt := range tests {
+		if got := operationKinds(tt.doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("operationKinds(%q) = %v, want %v", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestGraphQLCommand(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"data":{"viewer":{"login":"octocat"}}}`))
+	}))
+	defer srv.Close()
+
+	client, _ := NewEnterpriseClient("token", srv.URL, "")
+	cmd := NewGraphQLCommand(client)
+	run := func(args map[string]any) (agshctx.Envelope, error) {
+		return cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+	}
+
+	env, err := run(map[string]any{"query": "{ viewer { login } }"})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if gotPath != "/api/graphql" {
+		t.Errorf("request path = %q, want /api/graphql", gotPath)
+	}
+	data := env.Payload.(map[string]any)["data"].(map[string]any)
+	if data["viewer"].(map[string]any)["login"] != "octocat" {
+		t.Errorf("unexpected data: %v", data)
+	}
+
+	mutation := "mutation { addStar(input: {starrableId: \"1\"}) { clientMutationId } }"
+	gotPath = ""
+	if _, err := run(map[string]any{"query": mutation}); err == nil {
+		t.Error("mutation without allow_mutation should fail")
+	}
+	if gotPath != "" {
+		t.Error("refused mutation reached the server")
+	}
+	env, err = run(map[string]any{"query": mutation, "allow_mutation": true})
+	if err != nil {
+		t.Fatalf("allowed mutation: %v", err)
+	}
+	if env.Meta.Tags["risk"] != "destructive" {
+		t.Errorf("mutation risk tag = %q", env.Meta.Tags["risk"])
+	}
+
+	if _, err := run(map[string]any{"query": "{ " + strings.Repeat("a ", maxGraphQLQuery) + "}"}); err == nil {
+		t.Error("oversized query should fail")
+	}
+}
+
+func TestGraphQLRisk(t *testing.T) {
+	mutation := "mutation { addStar(input: {starrableId: \"1\"}) { clientMutationId } }"
+	tests := []struct {
+		payload any
+		want    string
+	}{
+		{"{ viewer { login } }", ""},
+		{map[string]any{"query": "query { viewer { login } }"}, ""},
+		{map[string]any{"query": "# mutation\n{ viewer { login } }"}, ""},
+		{mutation, "destructive"},
+		{map[string]any{"query": mutation}, "destructive"},
+		{map[string]any{"query": "{ viewer { login } }", "allow_mutation": true}, "destructive"},
+		{nil, ""},
+	}
+	cmd := NewGraphQLCommand(nil)
+	for _, tt := range tests {
+		if got := cmd.Risk(tt.payload); got != tt.want {
+			t.Errorf("Risk(%v) = %q, want %q", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, cmd := range []platform.PlatformCommand{NewRepoInfoCommand(nil), NewPRListCommand(nil), NewIssueCreateCommand(nil)} {
+		if len(platform.Examples(cmd)) == 0 {
+			t.Errorf("%s has no examples", cmd.Name())
+		}
+		if err := platform.CheckExamples(cmd); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package github
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Limits on github:graphql requests.
+const (
+	maxGraphQLQuery     = 16 * 1024
+	maxGraphQLVariables = 64 * 1024
+)
+
+// GraphQLCommand implements github:graphql — runs a GraphQL query against
+// the GitHub API. Mutations are destructive and refused unless the caller
+// passes allow_mutation: true.
+type GraphQLCommand struct {
+	client *Client
+}
+
+// NewGraphQLCommand creates a new github:graphql command.
+func NewGraphQLCommand(client *Client) *GraphQLCommand {
+	return &GraphQLCommand{client: client}
+}
+
+func (c *GraphQLCommand) Name() string        { return "github:graphql" }
+func (c *GraphQLCommand) Description() string { return "Run a GraphQL query against the GitHub API" }
+func (c *GraphQLCommand) Namespace() string   { return "github" }
+
+func (c *GraphQLCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"query":          {Type: "string", Description: "GraphQL document"},
+			"variables":      {Type: "object", Description: "Query variables"},
+			"owner":          {Type: "string", Description: "Owner whose account to use (default account if omitted)"},
+			"allow_mutation": {Type: "boolean", Description: "Permit a mutation (destructive)"},
+		},
+		Required: []string{"query"},
+	}
+}
+
+func (c *GraphQLCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"data":   {Type: "object", Description: "Query result"},
+			"errors": {Type: "array", Description: "Errors returned alongside partial data"},
+		},
+	}
+}
+
+func (c *GraphQLCommand) RequiredCredentials() []string {
+	return []string{"GITHUB_TOKEN"}
+}
+
+// Risk classifies a payload with a mutation, or one that allows a
+// mutation, as destructive, so a plan running it is approved as such.
+func (c *GraphQLCommand) Risk(payload any) string {
+	args, _ := graphQLArgs(payload)
+	query, _ := args["query"].(string)
+	if allow, _ := args["allow_mutation"].(bool); allow || slices.Contains(operationKinds(query), "mutation") {
+		return "destructive"
+	}
+	return ""
+}
+
+// graphQLArgs returns the arguments of a query string or map payload.
+func graphQLArgs(payload any) (map[string]any, bool) {
+	switch v := payload.(type) {
+	case string:
+		return map[string]any{"query": v}, true
+	case map[string]any:
+		return v, true
+	}
+	return nil, false
+}
+
+func (c *GraphQLCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	args, ok := graphQLArgs(input.Payload)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: expected a query string or map payload, got %T", input.Payload)
+	}
+
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: missing 'query'")
+	}
+	if len(query) > maxGraphQLQuery {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: query is %d bytes (limit %d)", len(query), maxGraphQLQuery)
+	}
+	variables, _ := args["variables"].(map[string]any)
+	if raw, err := json.Marshal(variables); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: encode variables: %w", err)
+	} else if len(raw) > maxGraphQLVariables {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: variables are %d bytes (limit %d)", len(raw), maxGraphQLVariables)
+	}
+
+	operation := "query"
+	for _, kind := range operationKinds(query) {
+		switch kind {
+		case "subscription":
+			return agshctx.Envelope{}, fmt.Errorf("github:graphql: subscriptions are not supported")
+		case "mutation":
+			operation = kind
+		}
+	}
+	if allow, _ := args["allow_mutation"].(bool); operation == "mutation" && !allow {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: mutations are destructive; pass allow_mutation: true to run one")
+	}
+
+	owner, _ := args["owner"].(string)
+	client, err := c.client.For(owner)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: %w", err)
+	}
+
+	req, err := client.inner.NewRequest("POST", graphQLURL(client), map[string]any{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: create request: %w", err)
+	}
+	var resp struct {
+		Data   any              `json:"data"`
+		Errors []map[string]any `json:"errors"`
+	}
+	if _, err := client.inner.Do(ctx, req, &resp); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: API error: %w", apiError(err))
+	}
+	if resp.Data == nil && len(resp.Errors) > 0 {
+		msg, _ := resp.Errors[0]["message"].(string)
+		return agshctx.Envelope{}, fmt.Errorf("github:graphql: %s", msg)
+	}
+
+	result := map[string]any{"data": resp.Data}
+	if len(resp.Errors) > 0 {
+		result["errors"] = resp.Errors
+	}
+	env := agshctx.NewEnvelope(result, "application/json", "github:graphql")
+	env.Meta.Tags["operation"] = operation
+	if operation == "mutation" {
+		env.Meta.Tags["risk"] = "destructive"
+	}
+	if client.Account() != "" {
+		env.Meta.Tags["account"] = client.Account()
+	}
+	return env, nil
+}
+
+// graphQLURL returns the GraphQL endpoint for a client: /graphql on
+// api.github.com, /api/graphql on GitHub Enterprise Server.
+func graphQLURL(c *Client) string {
+	base := c.inner.BaseURL
+	if strings.HasSuffix(base.Path, "/api/v3/") {
+		u := *base
+		u.Path = strings.TrimSuffix(u.Path, "v3/") + "graphql"
+		return u.String()
+	}
+	return base.String() + "graphql"
+}
+
+// operationKinds returns the kind of each top-level definition in a GraphQL
+// document: "query", "mutation", "subscription" or "fragment". A bare
+// selection set counts as a query. Comments and strings are skipped.
+func operationKinds(doc string) []string {
+	var kinds []string
+	depth := 0
+	named := false // the current top-level definition started with a keyword
+	for i := 0; i < len(doc); i++ {
+		ch := doc[i]
+		switch {
+		case ch == '#':
+			for i < len(doc) && doc[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(doc[i:], `"""`):
+			end := strings.Index(doc[i+3:], `"""`)
+			if end < 0 {
+				return kinds
+			}
+			i += end + 5
+		case ch == '"':
+			for i++; i < len(doc) && doc[i] != '"'; i++ {
+				if doc[i] == '\\' {
+					i++
+				}
+			}
+		case ch == '{':
+			if depth == 0 {
+				if !named {
+					kinds = append(kinds, "query")
+				}
+				named = false
+			}
+			depth++
+		case ch == '}':
+			depth--
+		case isNameStart(ch):
+			j := i
+			for j < len(doc) && isNameChar(doc[j]) {
+				j++
+			}
+			word := doc[i:j]
+			if depth == 0 && (i == 0 || doc[i-1] != '$') {
+				switch word {
+				case "query", "mutation", "subscription", "fragment":
+					kinds = append(kinds, word)
+					named = true
+				}
+			}
+			i = j - 1
+		}
+	}
+	return kinds
+}
+
+func isNameStart(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isNameChar(b byte) bool {
+	return isNameStart(b) || (b >= '0' && b <= '9')
+}
@@ -28,7 +28,7 @@ func (c *IssueCreateCommand) InputSchema() platform.Schema {
 	return platform.Schema{
 		Type: "object",
 		Properties: map[string]platform.SchemaField{
-			"repo":   {Type: "string", Description: "Repository in owner/name format"},
+			"repo":   {Type: "string", Description: "Repository in owner/name format", Aliases: []string{"owner"}},
 			"title":  {Type: "string", Description: "Issue title"},
 			"body":   {Type: "string", Description: "Issue body (markdown)"},
 			"labels": {Type: "array", Description: "Labels to apply"},
@@ -47,6 +47,16 @@ func (c *IssueCreateCommand) OutputSchema() platform.Schema {
 	}
 }
 
+func (c *IssueCreateCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Open an issue with labels",
+			Input:       map[string]any{"repo": "cgast/agsh", "title": "fs:list misses dotfiles", "body": "Steps to reproduce: ...", "labels": []any{"bug"}},
+			Output:      map[string]any{"number": 57, "html_url": "https://github.com/cgast/agsh/issues/57"},
+		},
+	}
+}
+
 func (c *IssueCreateCommand) RequiredCredentials() []string {
 	return []string{"GITHUB_TOKEN"}
 }
@@ -91,9 +101,14 @@ func (c *IssueCreateCommand) Execute(ctx gocontext.Context, input agshctx.Envelo
 		}
 	}
 
-	issue, _, err := c.client.inner.Issues.Create(ctx, owner, name, issueReq)
+	client, err := c.client.For(owner)
 	if err != nil {
-		return agshctx.Envelope{}, fmt.Errorf("github:issue:create: API error: %w", err)
+		return agshctx.Envelope{}, fmt.Errorf("github:issue:create: %w", err)
+	}
+
+	issue, _, err := client.inner.Issues.Create(ctx, owner, name, issueReq)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("github:issue:create: API error: %w", apiError(err))
 	}
 
 	result := map[string]any{
@@ -106,6 +121,9 @@ func (c *IssueCreateCommand) Execute(ctx gocontext.Context, input agshctx.Envelo
 
 	env := agshctx.NewEnvelope(result, "application/json", "github:issue:create")
 	env.Meta.Tags["repo"] = owner + "/" + name
+	if client.Account() != "" {
+		env.Meta.Tags["account"] = client.Account()
+	}
 	env.Meta.Tags["issue_number"] = fmt.Sprintf("%d", issue.GetNumber())
 	return env, nil
 }
@@ -28,7 +28,7 @@ func (c *PRListCommand) InputSchema() platform.Schema {
 	return platform.Schema{
 		Type: "object",
 		Properties: map[string]platform.SchemaField{
-			"repo":  {Type: "string", Description: "Repository in owner/name format"},
+			"repo":  {Type: "string", Description: "Repository in owner/name format", Aliases: []string{"owner"}},
 			"state": {Type: "string", Description: "Filter by state: open, closed, all (default: open)"},
 		},
 		Required: []string{"repo"},
@@ -45,6 +45,20 @@ func (c *PRListCommand) OutputSchema() platform.Schema {
 	}
 }
 
+func (c *PRListCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "List open pull requests",
+			Input:       map[string]any{"repo": "cgast/agsh"},
+			Output: map[string]any{"count": 1, "pull_requests": []any{map[string]any{
+				"number": 42, "title": "Add fs:grep", "state": "open", "author": "octocat", "draft": false,
+				"html_url": "https://github.com/cgast/agsh/pull/42",
+			}}},
+		},
+		{Description: "Include merged and closed ones", Input: map[string]any{"repo": "cgast/agsh", "state": "all"}},
+	}
+}
+
 func (c *PRListCommand) RequiredCredentials() []string {
 	return []string{"GITHUB_TOKEN"}
 }
@@ -67,9 +81,14 @@ func (c *PRListCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _
 		ListOptions: gh.ListOptions{PerPage: 100},
 	}
 
-	prs, _, err := c.client.inner.PullRequests.List(ctx, owner, name, opts)
+	client, err := c.client.For(owner)
 	if err != nil {
-		return agshctx.Envelope{}, fmt.Errorf("github:pr:list: API error: %w", err)
+		return agshctx.Envelope{}, fmt.Errorf("github:pr:list: %w", err)
+	}
+
+	prs, _, err := client.inner.PullRequests.List(ctx, owner, name, opts)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("github:pr:list: API error: %w", apiError(err))
 	}
 
 	items := make([]map[string]any, 0, len(prs))
@@ -93,6 +112,9 @@ func (c *PRListCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _
 
 	env := agshctx.NewEnvelope(result, "application/json", "github:pr:list")
 	env.Meta.Tags["repo"] = owner + "/" + name
+	if client.Account() != "" {
+		env.Meta.Tags["account"] = client.Account()
+	}
 	env.Meta.Tags["state"] = state
 	env.Meta.Tags["count"] = fmt.Sprintf("%d", len(items))
 	return env, nil
@@ -27,7 +27,7 @@ func (c *RepoInfoCommand) InputSchema() platform.Schema {
 	return platform.Schema{
 		Type: "object",
 		Properties: map[string]platform.SchemaField{
-			"repo": {Type: "string", Description: "Repository in owner/name format"},
+			"repo": {Type: "string", Description: "Repository in owner/name format", Aliases: []string{"owner"}},
 		},
 		Required: []string{"repo"},
 	}
@@ -49,6 +49,19 @@ func (c *RepoInfoCommand) OutputSchema() platform.Schema {
 	}
 }
 
+func (c *RepoInfoCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Repositories are given as owner/name",
+			Input:       map[string]any{"repo": "cgast/agsh"},
+			Output: map[string]any{
+				"name": "agsh", "full_name": "cgast/agsh", "description": "Agentic shell",
+				"stars": 120, "forks": 8, "open_issues": 5, "language": "Go", "default_branch": "main",
+			},
+		},
+	}
+}
+
 func (c *RepoInfoCommand) RequiredCredentials() []string {
 	return []string{"GITHUB_TOKEN"}
 }
@@ -59,9 +72,14 @@ func (c *RepoInfoCommand) Execute(ctx gocontext.Context, input agshctx.Envelope,
 		return agshctx.Envelope{}, fmt.Errorf("github:repo:info: %w", err)
 	}
 
-	repo, _, err := c.client.inner.Repositories.Get(ctx, owner, name)
+	client, err := c.client.For(owner)
 	if err != nil {
-		return agshctx.Envelope{}, fmt.Errorf("github:repo:info: API error: %w", err)
+		return agshctx.Envelope{}, fmt.Errorf("github:repo:info: %w", err)
+	}
+
+	repo, _, err := client.inner.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("github:repo:info: API error: %w", apiError(err))
 	}
 
 	result := map[string]any{
@@ -80,6 +98,9 @@ func (c *RepoInfoCommand) Execute(ctx gocontext.Context, input agshctx.Envelope,
 
 	env := agshctx.NewEnvelope(result, "application/json", "github:repo:info")
 	env.Meta.Tags["repo"] = owner + "/" + name
+	if client.Account() != "" {
+		env.Meta.Tags["account"] = client.Account()
+	}
 	return env, nil
 }
 
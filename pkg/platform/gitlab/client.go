@@ -0,0 +1,149 @@
+package gitlab
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Namespace describes the gitlab commands.
+var Namespace = platform.Namespace{
+	Name:        "gitlab",
+	Description: "Query projects and merge requests and create issues via the GitLab API",
+	Credentials: []string{"GITLAB_TOKEN"},
+	Risk:        "read-only",
+}
+
+// DefaultBaseURL is used when no base URL is configured.
+const DefaultBaseURL = "https://gitlab.com"
+
+// Client is a minimal GitLab REST (v4) client with token authentication.
+type Client struct {
+	baseURL    string // e.g. https://gitlab.com/api/v4
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a GitLab API client. baseURL is the instance root, e.g.
+// https://gitlab.example.com; empty means gitlab.com.
+func NewClient(token, baseURL string) (*Client, error) {
+	if token == "" {
+		return nil, fmt.Errorf("gitlab token is required")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	baseURL = strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/api/v4")
+	return &Client{
+		baseURL:    baseURL + "/api/v4",
+		token:      token,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// HealthCheck verifies that the token is accepted by fetching the
+// authenticated user.
+func (c *Client) HealthCheck(ctx gocontext.Context) error {
+	if err := c.do(ctx, http.MethodGet, "/user", nil, nil, nil); err != nil {
+		return fmt.Errorf("gitlab token check: %w", err)
+	}
+	return nil
+}
+
+// do sends a request to the API and decodes the JSON response into out.
+func (c *Client) do(ctx gocontext.Context, method, path string, query url.Values, body, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if err := agshctx.CheckDomain(ctx, req.URL.Hostname()); err != nil {
+		return err
+	}
+	agshctx.RecordDomain(ctx, req.URL.Hostname())
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024)) // 10MB limit
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+	agshctx.Attach(ctx, agshctx.Artifact{Name: "response.json", ContentType: "application/json", Data: data})
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message any    `json:"message"`
+			Error   string `json:"error"`
+		}
+		json.Unmarshal(data, &apiErr)
+		msg := apiErr.Error
+		if apiErr.Message != nil {
+			msg = fmt.Sprintf("%v", apiErr.Message)
+		}
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return platform.StatusError(resp.StatusCode, fmt.Sprintf("%s %s: %d %s", method, path, resp.StatusCode, msg))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// projectPath returns the API path for a project, which GitLab addresses by
+// its URL-encoded full path.
+func projectPath(project string) string {
+	return "/projects/" + url.PathEscape(project)
+}
+
+// extractProject gets the project path ("group/subgroup/name") from the
+// input envelope.
+func extractProject(input agshctx.Envelope) (string, error) {
+	var project string
+	switch v := input.Payload.(type) {
+	case string:
+		project = v
+	case map[string]any:
+		project, _ = v["project"].(string)
+		if project == "" {
+			project, _ = v["repo"].(string)
+		}
+	}
+	project = strings.Trim(project, "/")
+	if project == "" {
+		return "", fmt.Errorf("missing project (expected 'group/name' format)")
+	}
+	if !strings.Contains(project, "/") {
+		return "", fmt.Errorf("invalid project %q (expected 'group/name')", project)
+	}
+	return project, nil
+}
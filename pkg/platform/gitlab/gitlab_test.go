@@ -0,0 +1,143 @@
+package gitlab
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+func TestExtractProject(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload any
+		want    string
+		wantErr bool
+	}{
+		{"string", "group/project", "group/project", false},
+		{"subgroup", map[string]any{"project": "group/sub/project"}, "group/sub/project", false},
+		{"repo key", map[string]any{"repo": "group/project"}, "group/project", false},
+		{"no group", "project", "", true},
+		{"empty", "", "", true},
+		{"nil", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractProject(agshctx.NewEnvelope(tt.payload, "text/plain", "test"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("project = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	var gotPath, gotQuery, gotToken string
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery, gotToken = r.URL.EscapedPath(), r.URL.RawQuery, r.Header.Get("PRIVATE-TOKEN")
+		gotBody = nil
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		switch {
+		case strings.HasSuffix(gotPath, "/merge_requests"):
+			w.Write([]byte(`[{"iid":7,"title":"Fix","state":"opened","author":{"username":"dev"}}]`))
+		case strings.HasSuffix(gotPath, "/issues"):
+			w.Write([]byte(`{"iid":3,"title":"Bug","web_url":"https://gitlab.example.com/g/p/-/issues/3"}`))
+		case strings.Contains(gotPath, "missing"):
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"404 Project Not Found"}`))
+		default:
+			w.Write([]byte(`{"name":"p","path_with_namespace":"g/sub/p","star_count":5}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("glpat", srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(cmd interface {
+		Execute(gocontext.Context, agshctx.Envelope, agshctx.ContextStore) (agshctx.Envelope, error)
+	}, payload map[string]any) (map[string]any, error) {
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(payload, "application/json", "test"), nil)
+		if err != nil {
+			return nil, err
+		}
+		return env.Payload.(map[string]any), nil
+	}
+
+	info, err := run(NewProjectInfoCommand(client), map[string]any{"project": "g/sub/p"})
+	if err != nil {
+		t.Fatalf("project info: %v", err)
+	}
+	if gotPath != "/api/v4/projects/g%2Fsub%2Fp" || gotToken != "glpat" {
+		t.Errorf("request path %q, token %q", gotPath, gotToken)
+	}
+	if info["full_name"] != "g/sub/p" || info["stars"] != 5 {
+		t.Errorf("unexpected info: %v", info)
+	}
+
+	mrs, err := run(NewMRListCommand(client), map[string]any{"project": "g/p"})
+	if err != nil {
+		t.Fatalf("mr list: %v", err)
+	}
+	if !strings.Contains(gotQuery, "state=opened") {
+		t.Errorf("query = %q, want state=opened", gotQuery)
+	}
+	if mrs["count"] != 1 {
+		t.Errorf("unexpected merge requests: %v", mrs)
+	}
+	if _, err := run(NewMRListCommand(client), map[string]any{"project": "g/p", "state": "draft"}); err == nil {
+		t.Error("expected error for unknown state")
+	}
+
+	issue, err := run(NewIssueCreateCommand(client), map[string]any{"project": "g/p", "title": "Bug", "labels": []any{"a", "b"}})
+	if err != nil {
+		t.Fatalf("issue create: %v", err)
+	}
+	if gotBody["labels"] != "a,b" || issue["number"] != 3 {
+		t.Errorf("body %v, result %v", gotBody, issue)
+	}
+
+	if _, err := run(NewProjectInfoCommand(client), map[string]any{"project": "g/missing"}); err == nil || !strings.Contains(err.Error(), "404 Project Not Found") {
+		t.Errorf("expected API error message, got %v", err)
+	}
+}
+
+func TestCommandIdentity(t *testing.T) {
+	commands := []struct {
+		cmd interface {
+			Name() string
+			Namespace() string
+		}
+		name string
+	}{
+		{&ProjectInfoCommand{}, "gitlab:project:info"},
+		{&MRListCommand{}, "gitlab:mr:list"},
+		{&IssueCreateCommand{}, "gitlab:issue:create"},
+	}
+	for _, tt := range commands {
+		if tt.cmd.Name() != tt.name || tt.cmd.Namespace() != "gitlab" {
+			t.Errorf("got %s in %s, want %s in gitlab", tt.cmd.Name(), tt.cmd.Namespace(), tt.name)
+		}
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, cmd := range []platform.PlatformCommand{NewIssueCreateCommand(nil)} {
+		if len(platform.Examples(cmd)) == 0 {
+			t.Errorf("%s has no examples", cmd.Name())
+		}
+		if err := platform.CheckExamples(cmd); err != nil {
+			t.Error(err)
+		}
+	}
+}
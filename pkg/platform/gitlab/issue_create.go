@@ -0,0 +1,119 @@
+package gitlab
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// IssueCreateCommand implements gitlab:issue:create — creates a new issue.
+type IssueCreateCommand struct {
+	client *Client
+}
+
+// NewIssueCreateCommand creates a new gitlab:issue:create command.
+func NewIssueCreateCommand(client *Client) *IssueCreateCommand {
+	return &IssueCreateCommand{client: client}
+}
+
+func (c *IssueCreateCommand) Name() string        { return "gitlab:issue:create" }
+func (c *IssueCreateCommand) Description() string { return "Create a new issue in a project" }
+func (c *IssueCreateCommand) Namespace() string   { return "gitlab" }
+
+func (c *IssueCreateCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"project": {Type: "string", Description: "Project in group/name format", Aliases: []string{"repo"}},
+			"title":   {Type: "string", Description: "Issue title"},
+			"body":    {Type: "string", Description: "Issue description (markdown)"},
+			"labels":  {Type: "array", Description: "Labels to apply"},
+		},
+		Required: []string{"project", "title"},
+	}
+}
+
+func (c *IssueCreateCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"number":   {Type: "integer", Description: "Issue number (iid)"},
+			"html_url": {Type: "string", Description: "URL of the created issue"},
+		},
+	}
+}
+
+func (c *IssueCreateCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Projects are given as group/name, including subgroups",
+			Input:       map[string]any{"project": "acme/platform/api", "title": "Rotate the deploy token"},
+			Output:      map[string]any{"number": 12, "html_url": "https://gitlab.com/acme/platform/api/-/issues/12"},
+		},
+	}
+}
+
+func (c *IssueCreateCommand) RequiredCredentials() []string {
+	return []string{"GITLAB_TOKEN"}
+}
+
+func (c *IssueCreateCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	project, err := extractProject(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:issue:create: %w", err)
+	}
+
+	m, ok := input.Payload.(map[string]any)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:issue:create: expected map payload with 'title'")
+	}
+
+	title, _ := m["title"].(string)
+	if title == "" {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:issue:create: missing 'title'")
+	}
+
+	req := map[string]any{"title": title}
+	if body, _ := m["body"].(string); body != "" {
+		req["description"] = body
+	}
+	if labels, ok := m["labels"].([]any); ok {
+		names := make([]string, 0, len(labels))
+		for _, l := range labels {
+			if s, ok := l.(string); ok {
+				names = append(names, s)
+			}
+		}
+		if len(names) > 0 {
+			req["labels"] = strings.Join(names, ",")
+		}
+	}
+
+	var issue struct {
+		IID       int    `json:"iid"`
+		Title     string `json:"title"`
+		WebURL    string `json:"web_url"`
+		State     string `json:"state"`
+		CreatedAt string `json:"created_at"`
+	}
+	if err := c.client.do(ctx, http.MethodPost, projectPath(project)+"/issues", nil, req, &issue); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:issue:create: API error: %w", err)
+	}
+
+	result := map[string]any{
+		"number":     issue.IID,
+		"title":      issue.Title,
+		"html_url":   issue.WebURL,
+		"state":      issue.State,
+		"created_at": issue.CreatedAt,
+	}
+
+	env := agshctx.NewEnvelope(result, "application/json", "gitlab:issue:create")
+	env.Meta.Tags["project"] = project
+	env.Meta.Tags["issue_number"] = fmt.Sprintf("%d", issue.IID)
+	return env, nil
+}
@@ -0,0 +1,120 @@
+package gitlab
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// MRListCommand implements gitlab:mr:list — lists merge requests for a
+// project.
+type MRListCommand struct {
+	client *Client
+}
+
+// NewMRListCommand creates a new gitlab:mr:list command.
+func NewMRListCommand(client *Client) *MRListCommand {
+	return &MRListCommand{client: client}
+}
+
+func (c *MRListCommand) Name() string        { return "gitlab:mr:list" }
+func (c *MRListCommand) Description() string { return "List merge requests for a project" }
+func (c *MRListCommand) Namespace() string   { return "gitlab" }
+
+func (c *MRListCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"project": {Type: "string", Description: "Project in group/name format", Aliases: []string{"repo"}},
+			"state":   {Type: "string", Description: "Filter by state: open, closed, merged, all (default: open)"},
+		},
+		Required: []string{"project"},
+	}
+}
+
+func (c *MRListCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"merge_requests": {Type: "array", Description: "List of merge requests"},
+			"count":          {Type: "integer", Description: "Number of merge requests"},
+		},
+	}
+}
+
+func (c *MRListCommand) RequiredCredentials() []string {
+	return []string{"GITLAB_TOKEN"}
+}
+
+// mrStates maps the github-style states accepted here to GitLab's.
+var mrStates = map[string]string{
+	"open":   "opened",
+	"opened": "opened",
+	"closed": "closed",
+	"merged": "merged",
+	"all":    "all",
+}
+
+func (c *MRListCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	project, err := extractProject(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:mr:list: %w", err)
+	}
+
+	state := "open"
+	if m, ok := input.Payload.(map[string]any); ok {
+		if s, ok := m["state"].(string); ok && s != "" {
+			state = s
+		}
+	}
+	apiState, ok := mrStates[state]
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:mr:list: unknown state %q (expected open, closed, merged or all)", state)
+	}
+
+	var mrs []struct {
+		IID    int    `json:"iid"`
+		Title  string `json:"title"`
+		State  string `json:"state"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		WebURL    string `json:"web_url"`
+		Draft     bool   `json:"draft"`
+	}
+	query := url.Values{"state": {apiState}, "per_page": {"100"}}
+	if err := c.client.do(ctx, http.MethodGet, projectPath(project)+"/merge_requests", query, nil, &mrs); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:mr:list: API error: %w", err)
+	}
+
+	items := make([]map[string]any, 0, len(mrs))
+	for _, mr := range mrs {
+		items = append(items, map[string]any{
+			"number":     mr.IID,
+			"title":      mr.Title,
+			"state":      mr.State,
+			"author":     mr.Author.Username,
+			"created_at": mr.CreatedAt,
+			"updated_at": mr.UpdatedAt,
+			"html_url":   mr.WebURL,
+			"draft":      mr.Draft,
+		})
+	}
+
+	result := map[string]any{
+		"merge_requests": items,
+		"count":          len(items),
+	}
+
+	env := agshctx.NewEnvelope(result, "application/json", "gitlab:mr:list")
+	env.Meta.Tags["project"] = project
+	env.Meta.Tags["state"] = state
+	env.Meta.Tags["count"] = fmt.Sprintf("%d", len(items))
+	return env, nil
+}
@@ -0,0 +1,94 @@
+package gitlab
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// ProjectInfoCommand implements gitlab:project:info — fetches project
+// information.
+type ProjectInfoCommand struct {
+	client *Client
+}
+
+// NewProjectInfoCommand creates a new gitlab:project:info command.
+func NewProjectInfoCommand(client *Client) *ProjectInfoCommand {
+	return &ProjectInfoCommand{client: client}
+}
+
+func (c *ProjectInfoCommand) Name() string        { return "gitlab:project:info" }
+func (c *ProjectInfoCommand) Description() string { return "Get project information" }
+func (c *ProjectInfoCommand) Namespace() string   { return "gitlab" }
+
+func (c *ProjectInfoCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"project": {Type: "string", Description: "Project in group/name format", Aliases: []string{"repo"}},
+		},
+		Required: []string{"project"},
+	}
+}
+
+func (c *ProjectInfoCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"name":           {Type: "string", Description: "Project name"},
+			"full_name":      {Type: "string", Description: "Full project path (group/name)"},
+			"description":    {Type: "string", Description: "Project description"},
+			"stars":          {Type: "integer", Description: "Star count"},
+			"forks":          {Type: "integer", Description: "Fork count"},
+			"open_issues":    {Type: "integer", Description: "Open issue count"},
+			"default_branch": {Type: "string", Description: "Default branch name"},
+		},
+	}
+}
+
+func (c *ProjectInfoCommand) RequiredCredentials() []string {
+	return []string{"GITLAB_TOKEN"}
+}
+
+func (c *ProjectInfoCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	project, err := extractProject(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:project:info: %w", err)
+	}
+
+	var p struct {
+		Name              string `json:"name"`
+		PathWithNamespace string `json:"path_with_namespace"`
+		Description       string `json:"description"`
+		StarCount         int    `json:"star_count"`
+		ForksCount        int    `json:"forks_count"`
+		OpenIssuesCount   int    `json:"open_issues_count"`
+		DefaultBranch     string `json:"default_branch"`
+		WebURL            string `json:"web_url"`
+		CreatedAt         string `json:"created_at"`
+		LastActivityAt    string `json:"last_activity_at"`
+	}
+	if err := c.client.do(ctx, http.MethodGet, projectPath(project), nil, nil, &p); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("gitlab:project:info: API error: %w", err)
+	}
+
+	result := map[string]any{
+		"name":           p.Name,
+		"full_name":      p.PathWithNamespace,
+		"description":    p.Description,
+		"stars":          p.StarCount,
+		"forks":          p.ForksCount,
+		"open_issues":    p.OpenIssuesCount,
+		"default_branch": p.DefaultBranch,
+		"html_url":       p.WebURL,
+		"created_at":     p.CreatedAt,
+		"updated_at":     p.LastActivityAt,
+	}
+
+	env := agshctx.NewEnvelope(result, "application/json", "gitlab:project:info")
+	env.Meta.Tags["project"] = project
+	return env, nil
+}
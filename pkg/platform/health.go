@@ -0,0 +1,65 @@
+package platform
+
+import (
+	gocontext "context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthChecker is implemented by platform backends that can check their
+// connectivity and credentials without side effects, so that problems show
+// up before a plan depends on them.
+type HealthChecker interface {
+	HealthCheck(ctx gocontext.Context) error
+}
+
+// HealthResult reports the outcome of one health check.
+type HealthResult struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// RegisterHealthCheck adds a backend health check under name (usually the
+// namespace it serves), replacing any previous check of that name.
+func (r *Registry) RegisterHealthCheck(name string, hc HealthChecker) {
+	r.mu.Lock()
+	r.health[name] = hc
+	r.mu.Unlock()
+}
+
+// CheckHealth runs every registered health check concurrently and returns
+// the results sorted by name.
+func (r *Registry) CheckHealth(ctx gocontext.Context) []HealthResult {
+	r.mu.RLock()
+	checks := make(map[string]HealthChecker, len(r.health))
+	for name, hc := range r.health {
+		checks[name] = hc
+	}
+	r.mu.RUnlock()
+
+	results := make([]HealthResult, 0, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, hc := range checks {
+		wg.Add(1)
+		go func(name string, hc HealthChecker) {
+			defer wg.Done()
+			start := time.Now()
+			err := hc.HealthCheck(ctx)
+			res := HealthResult{Name: name, OK: err == nil, Duration: time.Since(start)}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			results = append(results, res)
+			mu.Unlock()
+		}(name, hc)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
@@ -0,0 +1,229 @@
+package http
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// Settings configures the http commands.
+type Settings struct {
+	// AllowedDomains are the hosts requests may go to; empty allows all.
+	AllowedDomains []string
+	// Headers are sent with every request; a request's own headers and its
+	// auth profile override them.
+	Headers map[string]string
+	// Proxy is the proxy URL requests go through; empty uses the
+	// HTTPS_PROXY and HTTP_PROXY environment variables.
+	Proxy string
+	// Auth holds the named credentials a request can select with auth.
+	Auth map[string]AuthProfile
+}
+
+// AuthProfile is a named credential: a bearer token, basic auth, or custom
+// headers such as an API key.
+type AuthProfile struct {
+	Bearer   string
+	Username string
+	Password string
+	Headers  map[string]string
+	// OAuth2, if set, obtains the bearer token with the client
+	// credentials grant instead.
+	OAuth2 *OAuth2
+	// Domains are the hosts the profile may be sent to; empty allows any
+	// host the commands may reach. An OAuth2 profile is also used for
+	// requests to its domains that name no profile.
+	Domains []string
+}
+
+// client sends the requests of the http commands, applying the default
+// headers, auth profiles and proxy of its settings. The commands check the
+// allowlist of the URL they request themselves; the client checks every
+// redirect.
+type client struct {
+	settings   Settings
+	httpClient *http.Client
+	proxyErr   error // set when settings.Proxy does not parse
+}
+
+// maxRedirects is how many redirects a request follows.
+const maxRedirects = 10
+
+// credentialsKey keys the sentCredentials of a request in its context.
+type credentialsKey struct{}
+
+// sentCredentials records the auth profile do applied to a request and the
+// headers it set, for checkRedirect.
+type sentCredentials struct {
+	profile string
+	headers []string
+}
+
+func newClient(settings Settings) *client {
+	c := &client{settings: settings}
+	c.httpClient = &http.Client{CheckRedirect: c.checkRedirect}
+	if settings.Proxy != "" {
+		proxy, err := url.Parse(settings.Proxy)
+		if err != nil || proxy.Host == "" {
+			c.proxyErr = fmt.Errorf("invalid proxy %q", settings.Proxy)
+			return c
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxy)
+		c.httpClient.Transport = transport
+	}
+	return c
+}
+
+// do sends req after adding the default headers it does not already set,
+// then the credentials of the auth profile named auth (or the OAuth2
+// profile covering req's host), then headers. A 401 to an OAuth2 token is
+// retried once with a fresh token.
+func (c *client) do(req *http.Request, auth string, headers map[string]string) (*http.Response, error) {
+	if c.proxyErr != nil {
+		return nil, c.proxyErr
+	}
+	for k, v := range c.settings.Headers {
+		if req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+	if auth == "" {
+		auth = c.oauth2Profile(req.URL.Hostname())
+	}
+	var ts *tokenSource
+	var token string
+	if auth != "" {
+		var err error
+		if ts, token, err = c.authorize(req, auth); err != nil {
+			return nil, err
+		}
+		creds := &sentCredentials{profile: auth, headers: []string{"Authorization"}}
+		for k := range c.settings.Auth[auth].Headers {
+			creds.headers = append(creds.headers, k)
+		}
+		req = req.WithContext(gocontext.WithValue(req.Context(), credentialsKey{}, creds))
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil || ts == nil || resp.StatusCode != http.StatusUnauthorized ||
+		req.Header.Get("Authorization") != "Bearer "+token || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+	// The token may have been revoked before it expired.
+	resp.Body.Close()
+	ts.invalidate(token)
+	retry := req.Clone(req.Context())
+	if req.Body != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if token, err = ts.Token(req.Context(), c.httpClient); err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", "Bearer "+token)
+	return c.httpClient.Do(retry)
+}
+
+// checkRedirect vets each redirect as the first request was vetted: its
+// host must be allowed, by the settings and by the run, and the auth
+// profile's domains must include it. Each host it reaches is recorded.
+// The profile's credentials are not sent on to another host.
+func (c *client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if err := CheckAllowedDomain(req.URL.String(), c.settings.AllowedDomains); err != nil {
+		return fmt.Errorf("redirect: %w", err)
+	}
+	host := req.URL.Hostname()
+	if err := agshctx.CheckDomain(req.Context(), host); err != nil {
+		return fmt.Errorf("redirect: %w", err)
+	}
+	agshctx.RecordDomain(req.Context(), host)
+	creds, _ := req.Context().Value(credentialsKey{}).(*sentCredentials)
+	if creds == nil {
+		return nil
+	}
+	if p := c.settings.Auth[creds.profile]; len(p.Domains) > 0 && !slices.Contains(p.Domains, host) {
+		return fmt.Errorf("redirect: auth profile %q is not allowed for %s", creds.profile, host)
+	}
+	if req.URL.Host != via[0].URL.Host {
+		for _, h := range creds.headers {
+			req.Header.Del(h)
+		}
+	}
+	return nil
+}
+
+// oauth2Profile returns the name of the OAuth2 profile whose domains
+// include host, or "" if there is none or more than one.
+func (c *client) oauth2Profile(host string) string {
+	found := ""
+	for name, p := range c.settings.Auth {
+		if p.OAuth2 != nil && slices.Contains(p.Domains, host) {
+			if found != "" {
+				return ""
+			}
+			found = name
+		}
+	}
+	return found
+}
+
+// authorize adds the credentials of the named profile to req, refusing
+// hosts outside the profile's domains. For an OAuth2 profile it also
+// returns the token source and the token it used.
+func (c *client) authorize(req *http.Request, name string) (*tokenSource, string, error) {
+	p, ok := c.settings.Auth[name]
+	if !ok {
+		known := make([]string, 0, len(c.settings.Auth))
+		for n := range c.settings.Auth {
+			known = append(known, n)
+		}
+		sort.Strings(known)
+		if len(known) == 0 {
+			return nil, "", fmt.Errorf("unknown auth profile %q (none configured)", name)
+		}
+		return nil, "", fmt.Errorf("unknown auth profile %q (configured: %s)", name, strings.Join(known, ", "))
+	}
+	if host := req.URL.Hostname(); len(p.Domains) > 0 && !slices.Contains(p.Domains, host) {
+		return nil, "", fmt.Errorf("auth profile %q is not allowed for %s", name, host)
+	}
+	var ts *tokenSource
+	var token string
+	switch {
+	case p.OAuth2 != nil:
+		ts = sharedTokenSource(*p.OAuth2)
+		var err error
+		if token, err = ts.Token(req.Context(), c.httpClient); err != nil {
+			return nil, "", fmt.Errorf("auth profile %q: %w", name, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case p.Bearer != "":
+		req.Header.Set("Authorization", "Bearer "+p.Bearer)
+	case p.Username != "":
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	return ts, token, nil
+}
+
+// authArg returns the auth profile a map payload names.
+func authArg(payload any) string {
+	args, _ := payload.(map[string]any)
+	auth, _ := args["auth"].(string)
+	return auth
+}
@@ -0,0 +1,207 @@
+package http
+
+import (
+	gocontext "context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/cgast/agsh/internal/digest"
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// DownloadCommand implements http:download — streams a response body to a
+// file, so binary artifacts never pass through the pipeline as text.
+type DownloadCommand struct {
+	client  *client
+	sandbox *sandbox.Sandbox
+}
+
+// NewDownloadCommand creates a new http:download command with the given
+// settings, writing only where sb allows.
+func NewDownloadCommand(settings Settings, sb *sandbox.Sandbox) *DownloadCommand {
+	return &DownloadCommand{client: newClient(settings), sandbox: sb}
+}
+
+func (c *DownloadCommand) Name() string { return "http:download" }
+func (c *DownloadCommand) Description() string {
+	return "Download a URL to a file, verifying its checksum"
+}
+func (c *DownloadCommand) Namespace() string { return "http" }
+
+func (c *DownloadCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"url":             {Type: "string", Description: "URL to download"},
+			"path":            {Type: "string", Description: "File to write"},
+			"expected_sha256": {Type: "string", Description: "Expected digest; the file is only written if it matches. Other algorithms as md5:<hex>, sha1:<hex> or sha512:<hex>"},
+			"headers":         {Type: "object", Description: "Optional HTTP headers"},
+			"auth":            {Type: "string", Description: "Name of an auth profile from platforms.yaml http.auth"},
+		},
+		Required: []string{"url", "path"},
+	}
+}
+
+func (c *DownloadCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":         {Type: "string", Description: "Written file path"},
+			"size":         {Type: "integer", Description: "Bytes written"},
+			"sha256":       {Type: "string", Description: "Hex sha256 of the file"},
+			"status_code":  {Type: "integer", Description: "HTTP status code"},
+			"content_type": {Type: "string", Description: "Response content type"},
+			"verified":     {Type: "boolean", Description: "Set when expected_sha256 was given and matched"},
+		},
+	}
+}
+
+func (c *DownloadCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Download a release archive and check its digest",
+			Input: map[string]any{
+				"url":             "https://releases.example.com/tool-1.2.tar.gz",
+				"path":            "downloads/tool-1.2.tar.gz",
+				"expected_sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			},
+			Output: map[string]any{
+				"path": "/work/downloads/tool-1.2.tar.gz", "size": 4, "status_code": 200,
+				"sha256":       "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+				"content_type": "application/gzip", "verified": true,
+			},
+		},
+	}
+}
+
+func (c *DownloadCommand) RequiredCredentials() []string { return nil }
+
+// Execute streams the body to a temporary file next to path, hashing it on
+// the way, and moves it into place only once the status, size and digest
+// check out.
+func (c *DownloadCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.sandbox)
+	rawURL, headers, err := extractHTTPParams(input)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+	}
+	args, _ := input.Payload.(map[string]any)
+	path, _ := args["path"].(string)
+	if path == "" {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: missing 'path' in payload")
+	}
+	algorithm, want := "", ""
+	if s, _ := args["expected_sha256"].(string); s != "" {
+		if algorithm, want, err = digest.Parse(s); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("http:download: expected_sha256: %w", err)
+		}
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: resolve path: %w", err)
+	}
+	if sb != nil {
+		if err := sb.CheckPath(path); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+		}
+	}
+	if err := CheckAllowedDomain(rawURL, c.client.settings.AllowedDomains); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: create request: %w", err)
+	}
+	if err := agshctx.CheckDomain(ctx, req.URL.Hostname()); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+	}
+	agshctx.RecordDomain(ctx, req.URL.Hostname())
+
+	resp, err := c.client.do(req, authArg(input.Payload), headers)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %s returned %s", rawURL, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: create dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	body := io.Reader(resp.Body)
+	maxSize := int64(-1)
+	if sb != nil && sb.MaxFileSize() > 0 {
+		maxSize = sb.MaxFileSize()
+		body = io.LimitReader(body, maxSize+1)
+	}
+	sum, size, err := digest.Sum(digest.Default, io.TeeReader(body, tmp))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+	}
+	if maxSize >= 0 && size > maxSize {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %w", sb.CheckFileSize(size))
+	}
+
+	if want != "" {
+		got := sum
+		if algorithm != digest.Default {
+			f, err := os.Open(tmp.Name())
+			if err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+			}
+			got, _, err = digest.Sum(algorithm, f)
+			f.Close()
+			if err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+			}
+		}
+		if got != want {
+			return agshctx.Envelope{}, fmt.Errorf("http:download: checksum mismatch for %s: got %s:%s, want %s:%s", rawURL, algorithm, got, algorithm, want)
+		}
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:download: %w", err)
+	}
+	agshctx.RecordWrite(ctx, path)
+
+	contentType := resp.Header.Get("Content-Type")
+	result := map[string]any{
+		"path":         path,
+		"size":         size,
+		"sha256":       sum,
+		"status_code":  resp.StatusCode,
+		"content_type": contentType,
+	}
+	if want != "" {
+		result["verified"] = true
+	}
+	env := agshctx.NewEnvelope(result, "application/json", "http:download")
+	env.Meta.Tags["url"] = rawURL
+	env.Meta.Tags["path"] = path
+	env.Meta.Tags["hash"] = digest.Default + ":" + sum
+	return env, nil
+}
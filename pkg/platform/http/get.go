@@ -11,18 +11,21 @@ import (
 	"github.com/cgast/agsh/pkg/platform"
 )
 
+// Namespace describes the http commands.
+var Namespace = platform.Namespace{
+	Name:        "http",
+	Description: "Make HTTP requests to domains on the configured allowlist",
+	Risk:        "read-only",
+}
+
 // GetCommand implements http:get — performs an HTTP GET request with domain allowlisting.
 type GetCommand struct {
-	allowedDomains []string
-	httpClient     *http.Client
+	client *client
 }
 
-// NewGetCommand creates a new http:get command with domain restrictions.
-func NewGetCommand(allowedDomains []string) *GetCommand {
-	return &GetCommand{
-		allowedDomains: allowedDomains,
-		httpClient:     &http.Client{},
-	}
+// NewGetCommand creates a new http:get command with the given settings.
+func NewGetCommand(settings Settings) *GetCommand {
+	return &GetCommand{client: newClient(settings)}
 }
 
 func (c *GetCommand) Name() string        { return "http:get" }
@@ -35,6 +38,7 @@ func (c *GetCommand) InputSchema() platform.Schema {
 		Properties: map[string]platform.SchemaField{
 			"url":     {Type: "string", Description: "URL to fetch"},
 			"headers": {Type: "object", Description: "Optional HTTP headers"},
+			"auth":    {Type: "string", Description: "Name of an auth profile from platforms.yaml http.auth"},
 		},
 		Required: []string{"url"},
 	}
@@ -51,6 +55,18 @@ func (c *GetCommand) OutputSchema() platform.Schema {
 	}
 }
 
+func (c *GetCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Fetch a URL from an allowed domain",
+			Input:       map[string]any{"url": "https://api.example.com/status", "headers": map[string]any{"Accept": "application/json"}},
+			Output:      map[string]any{"status_code": 200, "body": `{"ok":true}`, "headers": map[string]any{"Content-Type": "application/json"}},
+		},
+		{Description: "Call an API with a configured auth profile", Input: map[string]any{"url": "https://api.example.com/me", "auth": "my-api"}},
+		{Description: "A string payload is the URL", Input: "https://example.com/changelog"},
+	}
+}
+
 func (c *GetCommand) RequiredCredentials() []string { return nil }
 
 func (c *GetCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
@@ -59,7 +75,7 @@ func (c *GetCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ ag
 		return agshctx.Envelope{}, fmt.Errorf("http:get: %w", err)
 	}
 
-	if err := checkAllowedDomain(rawURL, c.allowedDomains); err != nil {
+	if err := CheckAllowedDomain(rawURL, c.client.settings.AllowedDomains); err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("http:get: %w", err)
 	}
 
@@ -67,11 +83,12 @@ func (c *GetCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ ag
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("http:get: create request: %w", err)
 	}
-	for k, v := range headers {
-		req.Header.Set(k, v)
+	if err := agshctx.CheckDomain(ctx, req.URL.Hostname()); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:get: %w", err)
 	}
+	agshctx.RecordDomain(ctx, req.URL.Hostname())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.client.do(req, authArg(input.Payload), headers)
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("http:get: request failed: %w", err)
 	}
@@ -131,9 +148,9 @@ func extractHTTPParams(input agshctx.Envelope) (string, map[string]string, error
 	return "", nil, fmt.Errorf("cannot extract URL from payload type %T", input.Payload)
 }
 
-// checkAllowedDomain verifies the URL's domain is in the allowlist.
+// CheckAllowedDomain verifies the URL's domain is in the allowlist.
 // If no allowed domains are configured, all domains are permitted.
-func checkAllowedDomain(rawURL string, allowedDomains []string) error {
+func CheckAllowedDomain(rawURL string, allowedDomains []string) error {
 	if len(allowedDomains) == 0 {
 		return nil
 	}
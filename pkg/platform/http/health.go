@@ -0,0 +1,57 @@
+package http
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// EgressCheck is a health check that verifies the allowed domains can be
+// reached. Any HTTP response counts as reachable; only connection failures
+// (DNS, TLS, timeouts, proxies) fail the check.
+type EgressCheck struct {
+	domains    []string
+	httpClient *http.Client
+}
+
+// NewEgressCheck creates a health check for the given allowed domains. With
+// no domains configured there is nothing specific to probe and the check
+// always passes.
+func NewEgressCheck(allowedDomains []string) *EgressCheck {
+	return &EgressCheck{
+		domains:    allowedDomains,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// HealthCheck sends a HEAD request to each allowed domain.
+func (c *EgressCheck) HealthCheck(ctx gocontext.Context) error {
+	var errs []error
+	for _, domain := range c.domains {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://"+domain+"/", nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", domain, err))
+			continue
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s unreachable: %w", domain, unwrapURLError(err)))
+			continue
+		}
+		resp.Body.Close()
+	}
+	return errors.Join(errs...)
+}
+
+// unwrapURLError drops the "Head \"https://...\":" prefix that *url.Error
+// adds, since the domain is already named.
+func unwrapURLError(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err
+	}
+	return err
+}
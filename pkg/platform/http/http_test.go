@@ -1,9 +1,19 @@
 package http
 
 import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/cgast/agsh/internal/sandbox"
 	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
 )
 
 func TestCheckAllowedDomain(t *testing.T) {
@@ -41,7 +51,7 @@ func TestCheckAllowedDomain(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := checkAllowedDomain(tt.url, tt.allowedDomains)
+			err := CheckAllowedDomain(tt.url, tt.allowedDomains)
 			if tt.wantErr && err == nil {
 				t.Error("expected error, got nil")
 			}
@@ -107,7 +117,7 @@ func TestExtractHTTPParams(t *testing.T) {
 }
 
 func TestCommandIdentity(t *testing.T) {
-	get := NewGetCommand(nil)
+	get := NewGetCommand(Settings{})
 	if get.Name() != "http:get" {
 		t.Errorf("GetCommand.Name() = %q", get.Name())
 	}
@@ -118,12 +128,338 @@ func TestCommandIdentity(t *testing.T) {
 		t.Errorf("GetCommand.RequiredCredentials() = %v", get.RequiredCredentials())
 	}
 
-	post := NewPostCommand(nil)
+	post := NewPostCommand(Settings{})
 	if post.Name() != "http:post" {
 		t.Errorf("PostCommand.Name() = %q", post.Name())
 	}
 }
 
+func TestAuthProfilesAndDefaultHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Api-Key") + "|" + r.Header.Get("User-Agent")))
+	}))
+	defer srv.Close()
+
+	settings := Settings{
+		Headers: map[string]string{"User-Agent": "agsh-test"},
+		Auth: map[string]AuthProfile{
+			"token":  {Bearer: "tok123"},
+			"basic":  {Username: "alice", Password: "s3cret"},
+			"key":    {Headers: map[string]string{"X-Api-Key": "k1"}},
+			"remote": {Bearer: "x", Domains: []string{"api.example.com"}},
+		},
+	}
+	get := NewGetCommand(settings)
+	run := func(args map[string]any) (string, error) {
+		args["url"] = srv.URL
+		env, err := get.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+		if err != nil {
+			return "", err
+		}
+		return env.Payload.(map[string]any)["body"].(string), nil
+	}
+
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{}, "||agsh-test"},
+		{map[string]any{"auth": "token"}, "Bearer tok123||agsh-test"},
+		{map[string]any{"auth": "basic"}, "Basic YWxpY2U6czNjcmV0||agsh-test"},
+		{map[string]any{"auth": "key", "headers": map[string]any{"User-Agent": "custom"}}, "|k1|custom"},
+	}
+	for _, tt := range tests {
+		got, err := run(tt.args)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	if _, err := run(map[string]any{"auth": "nope"}); err == nil || !strings.Contains(err.Error(), "basic, key, remote, token") {
+		t.Errorf("unknown profile: %v", err)
+	}
+	if _, err := run(map[string]any{"auth": "remote"}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("profile outside its domains: %v", err)
+	}
+
+	post := NewPostCommand(settings)
+	env, err := post.Execute(gocontext.Background(), agshctx.NewEnvelope(map[string]any{"url": srv.URL, "auth": "token"}, "application/json", "test"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := env.Payload.(map[string]any)["body"]; got != "Bearer tok123||agsh-test" {
+		t.Errorf("post: got %q", got)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	// target echoes the credentials that reached it.
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Api-Key") + "|" + r.Header.Get("User-Agent")))
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		to := target.URL
+		if r.URL.Query().Get("to") == "localhost" {
+			to = strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+		}
+		http.Redirect(w, r, to+"/landed", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	settings := Settings{
+		AllowedDomains: []string{"127.0.0.1"},
+		Headers:        map[string]string{"User-Agent": "agsh-test"},
+		Auth: map[string]AuthProfile{
+			"key":   {Headers: map[string]string{"X-Api-Key": "k1"}},
+			"token": {Bearer: "tok123", Domains: []string{"127.0.0.1"}},
+			"local": {Bearer: "tok123", Domains: []string{"127.0.0.1", "localhost"}},
+		},
+	}
+	get := NewGetCommand(settings)
+
+	tests := []struct {
+		name    string
+		to      string
+		auth    string
+		allowed []string // overrides settings.AllowedDomains when set
+		want    string
+		wantErr string
+	}{
+		{name: "profile headers stay with the host", auth: "key", want: "||agsh-test"},
+		{name: "bearer stays with the host", auth: "token", want: "||agsh-test"},
+		{name: "host outside the allowlist", to: "localhost", wantErr: "not in the allowed list"},
+		{name: "host outside the profile's domains", to: "localhost", auth: "token", allowed: []string{"127.0.0.1", "localhost"}, wantErr: `auth profile "token" is not allowed`},
+		{name: "host within the profile's domains", to: "localhost", auth: "local", allowed: []string{"127.0.0.1", "localhost"}, want: "||agsh-test"},
+	}
+	for _, tt := range tests {
+		cmd := get
+		if tt.allowed != nil {
+			s := settings
+			s.AllowedDomains = tt.allowed
+			cmd = NewGetCommand(s)
+		}
+		args := map[string]any{"url": origin.URL + "/?to=" + tt.to}
+		if tt.auth != "" {
+			args["auth"] = tt.auth
+		}
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := env.Payload.(map[string]any)["body"]; got != tt.want {
+			t.Errorf("%s: target saw %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var mu sync.Mutex
+	issued, valid := 0, ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/token" {
+			id, secret, _ := r.BasicAuth()
+			r.ParseForm()
+			if id != "agsh" || secret != "s3cret" || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+				http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			issued++
+			valid = fmt.Sprintf("t%d", issued)
+			fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":3600}`, valid)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok " + valid))
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	host = host[:strings.LastIndex(host, ":")]
+	settings := Settings{Auth: map[string]AuthProfile{"service": {
+		OAuth2:  &OAuth2{TokenURL: srv.URL + "/token", ClientID: "agsh", ClientSecret: "s3cret", Scopes: []string{"read", "write"}},
+		Domains: []string{host},
+	}}}
+	body := func(cmd platform.PlatformCommand, args map[string]any) string {
+		t.Helper()
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", cmd.Name(), err)
+		}
+		return env.Payload.(map[string]any)["body"].(string)
+	}
+
+	if got := body(NewGetCommand(settings), map[string]any{"url": srv.URL + "/api", "auth": "service"}); got != "ok t1" {
+		t.Errorf("named profile: %q", got)
+	}
+	// The token is cached across commands, and the profile applies to its
+	// domains without being named.
+	if got := body(NewPostCommand(settings), map[string]any{"url": srv.URL + "/api", "body": "{}"}); got != "ok t1" {
+		t.Errorf("implicit profile: %q", got)
+	}
+	if issued != 1 {
+		t.Errorf("issued %d tokens, want 1", issued)
+	}
+
+	// A revoked token is replaced and the request retried, body included.
+	mu.Lock()
+	valid = "revoked"
+	mu.Unlock()
+	if got := body(NewPostCommand(settings), map[string]any{"url": srv.URL + "/api", "body": "{}"}); got != "ok t2" {
+		t.Errorf("after revocation: %q", got)
+	}
+
+	bad := Settings{Auth: map[string]AuthProfile{"bad": {OAuth2: &OAuth2{TokenURL: srv.URL + "/token", ClientID: "other"}}}}
+	_, err := NewGetCommand(bad).Execute(gocontext.Background(), agshctx.NewEnvelope(map[string]any{"url": srv.URL + "/api", "auth": "bad"}, "application/json", "test"), nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("rejected client: %v", err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	for _, cmd := range []platform.PlatformCommand{NewGetCommand(Settings{Proxy: proxy.URL}), NewPostCommand(Settings{Proxy: proxy.URL})} {
+		proxied = ""
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(map[string]any{"url": "http://upstream.invalid/x"}, "application/json", "test"), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", cmd.Name(), err)
+		}
+		if proxied != "http://upstream.invalid/x" || env.Payload.(map[string]any)["body"] != "via proxy" {
+			t.Errorf("%s: proxied %q, payload %v", cmd.Name(), proxied, env.Payload)
+		}
+	}
+
+	if _, err := NewGetCommand(Settings{Proxy: "::bad"}).Execute(gocontext.Background(), agshctx.NewEnvelope("http://example.com", "text/plain", "test"), nil); err == nil {
+		t.Error("expected error for invalid proxy")
+	}
+}
+
+func TestDownloadCommand(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("test"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sb, _ := sandbox.New(sandbox.Config{AllowedPaths: []string{dir}, MaxFileSize: "1KB"})
+	cmd := NewDownloadCommand(Settings{}, sb)
+	download := func(args map[string]any) (map[string]any, error) {
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+		if err != nil {
+			return nil, err
+		}
+		return env.Payload.(map[string]any), nil
+	}
+
+	const testSHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	path := filepath.Join(dir, "sub", "a.bin")
+	res, err := download(map[string]any{"url": srv.URL + "/a.bin", "path": path, "expected_sha256": testSHA256})
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if res["size"] != int64(4) || res["sha256"] != testSHA256 || res["verified"] != true {
+		t.Errorf("result = %v", res)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "test" {
+		t.Errorf("file = %q", data)
+	}
+	if _, err := download(map[string]any{"url": srv.URL, "path": path, "expected_sha256": "md5:098f6bcd4621d373cade4e832627b4f6"}); err != nil {
+		t.Errorf("md5 digest: %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad.bin")
+	for name, args := range map[string]map[string]any{
+		"checksum mismatch": {"url": srv.URL, "path": bad, "expected_sha256": strings.Repeat("0", 64)},
+		"bad digest":        {"url": srv.URL, "path": bad, "expected_sha256": "abc"},
+		"http error":        {"url": srv.URL + "/missing", "path": bad},
+		"outside sandbox":   {"url": srv.URL, "path": filepath.Join(t.TempDir(), "x")},
+		"missing path":      {"url": srv.URL},
+	} {
+		if _, err := download(args); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Errorf("failed download left %s behind", bad)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+
+	small, _ := sandbox.New(sandbox.Config{MaxFileSize: "2B"})
+	if _, err := NewDownloadCommand(Settings{}, small).Execute(gocontext.Background(), agshctx.NewEnvelope(map[string]any{"url": srv.URL, "path": bad}, "application/json", "test"), nil); err == nil {
+		t.Error("download over max_file_size should fail")
+	}
+}
+
+func TestDownloadRedirect(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test"))
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1)+"/a.bin", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	dir := t.TempDir()
+	sb, _ := sandbox.New(sandbox.Config{AllowedPaths: []string{dir}})
+	cmd := NewDownloadCommand(Settings{}, sb)
+	tests := []struct {
+		name    string
+		allowed []string // the hosts the run may contact
+		wantErr bool
+	}{
+		{name: "run allows the redirect", allowed: []string{"127.0.0.1", "localhost"}},
+		{name: "run does not allow the redirect", allowed: []string{"127.0.0.1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-"))
+		ctx := agshctx.WithAllowedDomains(gocontext.Background(), tt.allowed)
+		_, err := cmd.Execute(ctx, agshctx.NewEnvelope(map[string]any{"url": origin.URL, "path": path}, "application/json", "test"), nil)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), `host "localhost"`) {
+				t.Errorf("%s: err = %v, want the redirect refused", tt.name, err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("%s: refused download wrote %s", tt.name, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "test" {
+			t.Errorf("%s: file = %q", tt.name, data)
+		}
+	}
+}
+
 func TestExtractPostParams(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -184,3 +520,37 @@ func TestExtractPostParams(t *testing.T) {
 		})
 	}
 }
+
+func TestEgressCheck(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden) // any response means reachable
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	check := NewEgressCheck([]string{host})
+	check.httpClient = srv.Client()
+	if err := check.HealthCheck(gocontext.Background()); err != nil {
+		t.Errorf("reachable domain: %v", err)
+	}
+
+	srv.Close()
+	if err := check.HealthCheck(gocontext.Background()); err == nil || !strings.Contains(err.Error(), host) {
+		t.Errorf("expected unreachable error naming %s, got %v", host, err)
+	}
+
+	if err := NewEgressCheck(nil).HealthCheck(gocontext.Background()); err != nil {
+		t.Errorf("no domains should pass: %v", err)
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, cmd := range []platform.PlatformCommand{NewGetCommand(Settings{})} {
+		if len(platform.Examples(cmd)) == 0 {
+			t.Errorf("%s has no examples", cmd.Name())
+		}
+		if err := platform.CheckExamples(cmd); err != nil {
+			t.Error(err)
+		}
+	}
+}
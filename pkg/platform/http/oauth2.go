@@ -0,0 +1,124 @@
+package http
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 configures the client credentials grant (RFC 6749 §4.4): a token
+// is requested from TokenURL with the client's id and secret and sent as a
+// bearer token until shortly before it expires.
+type OAuth2 struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// tokenExpiryMargin is how long before its expiry a token is replaced, so
+// it does not lapse in flight.
+const tokenExpiryMargin = 30 * time.Second
+
+// tokenSource caches the access token of one OAuth2 client.
+type tokenSource struct {
+	cfg OAuth2
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time // zero when the server gave no lifetime
+}
+
+var (
+	tokenSourcesMu sync.Mutex
+	tokenSources   = map[string]*tokenSource{}
+)
+
+// sharedTokenSource returns the token source for cfg, shared by every
+// command configured with the same client so a token is fetched once.
+func sharedTokenSource(cfg OAuth2) *tokenSource {
+	key := strings.Join([]string{cfg.TokenURL, cfg.ClientID, cfg.ClientSecret, strings.Join(cfg.Scopes, " ")}, "\x00")
+	tokenSourcesMu.Lock()
+	defer tokenSourcesMu.Unlock()
+	ts, ok := tokenSources[key]
+	if !ok {
+		ts = &tokenSource{cfg: cfg}
+		tokenSources[key] = ts
+	}
+	return ts
+}
+
+// Token returns the cached token, fetching a new one with hc when there is
+// none or it is about to expire.
+func (ts *tokenSource) Token(ctx gocontext.Context, hc *http.Client) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != "" && (ts.expires.IsZero() || time.Now().Add(tokenExpiryMargin).Before(ts.expires)) {
+		return ts.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(ts.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.cfg.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("oauth2: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(ts.cfg.ClientID), url.QueryEscape(ts.cfg.ClientSecret))
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth2: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("oauth2: read token response: %w", err)
+	}
+	var tok struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &tok)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || tok.Error != "" {
+		if tok.Error != "" {
+			return "", fmt.Errorf("oauth2: token endpoint returned %s: %s %s", resp.Status, tok.Error, tok.ErrorDescription)
+		}
+		return "", fmt.Errorf("oauth2: token endpoint returned %s", resp.Status)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("oauth2: token response has no access_token")
+	}
+	if tok.TokenType != "" && !strings.EqualFold(tok.TokenType, "bearer") {
+		return "", fmt.Errorf("oauth2: unsupported token type %q", tok.TokenType)
+	}
+
+	ts.token = tok.AccessToken
+	ts.expires = time.Time{}
+	if tok.ExpiresIn > 0 {
+		ts.expires = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	return ts.token, nil
+}
+
+// invalidate drops token if it is still the cached one, so the next call
+// to Token fetches a new one.
+func (ts *tokenSource) invalidate(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token == token {
+		ts.token = ""
+	}
+}
@@ -13,16 +13,12 @@ import (
 
 // PostCommand implements http:post — performs an HTTP POST request with domain allowlisting.
 type PostCommand struct {
-	allowedDomains []string
-	httpClient     *http.Client
+	client *client
 }
 
-// NewPostCommand creates a new http:post command with domain restrictions.
-func NewPostCommand(allowedDomains []string) *PostCommand {
-	return &PostCommand{
-		allowedDomains: allowedDomains,
-		httpClient:     &http.Client{},
-	}
+// NewPostCommand creates a new http:post command with the given settings.
+func NewPostCommand(settings Settings) *PostCommand {
+	return &PostCommand{client: newClient(settings)}
 }
 
 func (c *PostCommand) Name() string        { return "http:post" }
@@ -37,6 +33,7 @@ func (c *PostCommand) InputSchema() platform.Schema {
 			"body":         {Type: "string", Description: "Request body"},
 			"content_type": {Type: "string", Description: "Content-Type header (default: application/json)"},
 			"headers":      {Type: "object", Description: "Optional HTTP headers"},
+			"auth":         {Type: "string", Description: "Name of an auth profile from platforms.yaml http.auth"},
 		},
 		Required: []string{"url"},
 	}
@@ -61,7 +58,7 @@ func (c *PostCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ a
 		return agshctx.Envelope{}, fmt.Errorf("http:post: %w", err)
 	}
 
-	if err := checkAllowedDomain(rawURL, c.allowedDomains); err != nil {
+	if err := CheckAllowedDomain(rawURL, c.client.settings.AllowedDomains); err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("http:post: %w", err)
 	}
 
@@ -69,12 +66,13 @@ func (c *PostCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ a
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("http:post: create request: %w", err)
 	}
-	req.Header.Set("Content-Type", contentType)
-	for k, v := range headers {
-		req.Header.Set(k, v)
+	if err := agshctx.CheckDomain(ctx, req.URL.Hostname()); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("http:post: %w", err)
 	}
+	agshctx.RecordDomain(ctx, req.URL.Hostname())
+	req.Header.Set("Content-Type", contentType)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.client.do(req, authArg(input.Payload), headers)
 	if err != nil {
 		return agshctx.Envelope{}, fmt.Errorf("http:post: request failed: %w", err)
 	}
@@ -0,0 +1,134 @@
+package jira
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Namespace describes the jira commands.
+var Namespace = platform.Namespace{
+	Name:        "jira",
+	Description: "Search, create and comment on Jira issues",
+	Credentials: []string{"JIRA_TOKEN"},
+	Risk:        "read-only",
+}
+
+// Client is a minimal Jira REST (v2) client. With an email it uses Jira
+// Cloud basic auth (email + API token); without one the token is sent as a
+// bearer personal access token, as Jira Server and Data Center expect.
+type Client struct {
+	baseURL    string // site root, e.g. https://acme.atlassian.net
+	email      string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a Jira API client for the site at baseURL.
+func NewClient(baseURL, email, token string) (*Client, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("jira base URL is required")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("jira token is required")
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		email:      email,
+		token:      token,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// HealthCheck verifies that the credentials are accepted by fetching the
+// current user.
+func (c *Client) HealthCheck(ctx gocontext.Context) error {
+	if err := c.do(ctx, http.MethodGet, "/myself", nil, nil, nil); err != nil {
+		return fmt.Errorf("jira credentials check: %w", err)
+	}
+	return nil
+}
+
+// browseURL returns the web URL of an issue.
+func (c *Client) browseURL(key string) string {
+	return c.baseURL + "/browse/" + key
+}
+
+// do sends a request to the REST API and decodes the JSON response into out.
+func (c *Client) do(ctx gocontext.Context, method, path string, query url.Values, body, out any) error {
+	u := c.baseURL + "/rest/api/2" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if err := agshctx.CheckDomain(ctx, req.URL.Hostname()); err != nil {
+		return err
+	}
+	agshctx.RecordDomain(ctx, req.URL.Hostname())
+	if c.email != "" {
+		req.SetBasicAuth(c.email, c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024)) // 10MB limit
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+	agshctx.Attach(ctx, agshctx.Artifact{Name: "response.json", ContentType: "application/json", Data: data})
+	if resp.StatusCode >= 300 {
+		return platform.StatusError(resp.StatusCode, fmt.Sprintf("%s %s: %d %s", method, path, resp.StatusCode, errorMessage(data, resp.StatusCode)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// errorMessage extracts Jira's errorMessages/errors from an error response.
+func errorMessage(data []byte, status int) string {
+	var apiErr struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	json.Unmarshal(data, &apiErr)
+	msgs := apiErr.ErrorMessages
+	for field, msg := range apiErr.Errors {
+		msgs = append(msgs, field+": "+msg)
+	}
+	if len(msgs) == 0 {
+		return http.StatusText(status)
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,86 @@
+package jira
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// IssueCommentCommand implements jira:issue:comment — adds a comment to an
+// issue.
+type IssueCommentCommand struct {
+	client *Client
+}
+
+// NewIssueCommentCommand creates a new jira:issue:comment command.
+func NewIssueCommentCommand(client *Client) *IssueCommentCommand {
+	return &IssueCommentCommand{client: client}
+}
+
+func (c *IssueCommentCommand) Name() string        { return "jira:issue:comment" }
+func (c *IssueCommentCommand) Description() string { return "Add a comment to a Jira issue" }
+func (c *IssueCommentCommand) Namespace() string   { return "jira" }
+
+func (c *IssueCommentCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"issue": {Type: "string", Description: "Issue key, e.g. OPS-42"},
+			"body":  {Type: "string", Description: "Comment text"},
+		},
+		Required: []string{"issue", "body"},
+	}
+}
+
+func (c *IssueCommentCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"id":       {Type: "string", Description: "Comment id"},
+			"html_url": {Type: "string", Description: "URL of the commented issue"},
+		},
+	}
+}
+
+func (c *IssueCommentCommand) RequiredCredentials() []string {
+	return []string{"JIRA_TOKEN"}
+}
+
+func (c *IssueCommentCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	m, ok := input.Payload.(map[string]any)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:comment: expected map payload with 'issue' and 'body'")
+	}
+	key, _ := m["issue"].(string)
+	if key == "" {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:comment: missing 'issue'")
+	}
+	body, _ := m["body"].(string)
+	if body == "" {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:comment: missing 'body'")
+	}
+
+	var comment struct {
+		ID      string `json:"id"`
+		Created string `json:"created"`
+	}
+	path := "/issue/" + url.PathEscape(key) + "/comment"
+	if err := c.client.do(ctx, http.MethodPost, path, nil, map[string]any{"body": body}, &comment); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:comment: API error: %w", err)
+	}
+
+	result := map[string]any{
+		"id":       comment.ID,
+		"issue":    key,
+		"created":  comment.Created,
+		"html_url": c.client.browseURL(key),
+	}
+
+	env := agshctx.NewEnvelope(result, "application/json", "jira:issue:comment")
+	env.Meta.Tags["issue"] = key
+	return env, nil
+}
@@ -0,0 +1,124 @@
+package jira
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// IssueCreateCommand implements jira:issue:create — creates a new issue.
+type IssueCreateCommand struct {
+	client *Client
+}
+
+// NewIssueCreateCommand creates a new jira:issue:create command.
+func NewIssueCreateCommand(client *Client) *IssueCreateCommand {
+	return &IssueCreateCommand{client: client}
+}
+
+func (c *IssueCreateCommand) Name() string        { return "jira:issue:create" }
+func (c *IssueCreateCommand) Description() string { return "Create a new Jira issue" }
+func (c *IssueCreateCommand) Namespace() string   { return "jira" }
+
+func (c *IssueCreateCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"project": {Type: "string", Description: "Project key, e.g. OPS"},
+			"summary": {Type: "string", Description: "Issue summary", Aliases: []string{"title"}},
+			"body":    {Type: "string", Description: "Issue description"},
+			"type":    {Type: "string", Description: "Issue type (default: Task)"},
+			"labels":  {Type: "array", Description: "Labels to apply"},
+		},
+		Required: []string{"project", "summary"},
+	}
+}
+
+func (c *IssueCreateCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"key":      {Type: "string", Description: "Issue key, e.g. OPS-42"},
+			"html_url": {Type: "string", Description: "URL of the created issue"},
+		},
+	}
+}
+
+func (c *IssueCreateCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Create a bug in project OPS",
+			Input:       map[string]any{"project": "OPS", "summary": "Nightly backup failed", "type": "Bug"},
+			Output:      map[string]any{"key": "OPS-42", "html_url": "https://acme.atlassian.net/browse/OPS-42"},
+		},
+	}
+}
+
+func (c *IssueCreateCommand) RequiredCredentials() []string {
+	return []string{"JIRA_TOKEN"}
+}
+
+func (c *IssueCreateCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	m, ok := input.Payload.(map[string]any)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:create: expected map payload with 'project' and 'summary'")
+	}
+	project, _ := m["project"].(string)
+	if project == "" {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:create: missing 'project'")
+	}
+	summary, _ := m["summary"].(string)
+	if summary == "" {
+		summary, _ = m["title"].(string)
+	}
+	if summary == "" {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:create: missing 'summary'")
+	}
+	issueType, _ := m["type"].(string)
+	if issueType == "" {
+		issueType = "Task"
+	}
+
+	fields := map[string]any{
+		"project":   map[string]any{"key": project},
+		"summary":   summary,
+		"issuetype": map[string]any{"name": issueType},
+	}
+	if body, _ := m["body"].(string); body != "" {
+		fields["description"] = body
+	}
+	if labels, ok := m["labels"].([]any); ok {
+		names := make([]string, 0, len(labels))
+		for _, l := range labels {
+			if s, ok := l.(string); ok {
+				names = append(names, s)
+			}
+		}
+		if len(names) > 0 {
+			fields["labels"] = names
+		}
+	}
+
+	var created struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := c.client.do(ctx, http.MethodPost, "/issue", nil, map[string]any{"fields": fields}, &created); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:create: API error: %w", err)
+	}
+
+	result := map[string]any{
+		"id":       created.ID,
+		"key":      created.Key,
+		"summary":  summary,
+		"html_url": c.client.browseURL(created.Key),
+	}
+
+	env := agshctx.NewEnvelope(result, "application/json", "jira:issue:create")
+	env.Meta.Tags["project"] = project
+	env.Meta.Tags["issue"] = created.Key
+	return env, nil
+}
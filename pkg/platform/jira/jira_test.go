@@ -0,0 +1,138 @@
+package jira
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+func TestCommands(t *testing.T) {
+	var gotPath, gotQuery, gotAuth string
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery, gotAuth = r.URL.Path, r.URL.Query().Get("jql"), r.Header.Get("Authorization")
+		gotBody = nil
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		switch gotPath {
+		case "/rest/api/2/search":
+			w.Write([]byte(`{"total":7,"issues":[{"key":"OPS-1","fields":{"summary":"Deploy","status":{"name":"Done"},"assignee":null}}]}`))
+		case "/rest/api/2/issue":
+			if fields, _ := gotBody["fields"].(map[string]any); fields["summary"] == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":{"summary":"required"}}`))
+				return
+			}
+			w.Write([]byte(`{"id":"10001","key":"OPS-2"}`))
+		case "/rest/api/2/issue/OPS-2/comment":
+			w.Write([]byte(`{"id":"5","created":"2026-01-01T00:00:00.000+0000"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorMessages":["Issue does not exist"]}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL+"/", "dev@example.com", "api-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(cmd interface {
+		Execute(gocontext.Context, agshctx.Envelope, agshctx.ContextStore) (agshctx.Envelope, error)
+	}, payload any) (map[string]any, error) {
+		env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(payload, "application/json", "test"), nil)
+		if err != nil {
+			return nil, err
+		}
+		return env.Payload.(map[string]any), nil
+	}
+
+	res, err := run(NewSearchCommand(client), "project = OPS")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if gotQuery != "project = OPS" || !strings.HasPrefix(gotAuth, "Basic ") {
+		t.Errorf("jql %q, auth %q", gotQuery, gotAuth)
+	}
+	issues := res["issues"].([]map[string]any)
+	if res["total"] != 7 || len(issues) != 1 || issues[0]["status"] != "Done" || issues[0]["html_url"] != srv.URL+"/browse/OPS-1" {
+		t.Errorf("unexpected search result: %v", res)
+	}
+
+	res, err = run(NewIssueCreateCommand(client), map[string]any{"project": "OPS", "summary": "Rotate keys", "labels": []any{"security"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	fields := gotBody["fields"].(map[string]any)
+	if fields["issuetype"].(map[string]any)["name"] != "Task" || res["key"] != "OPS-2" {
+		t.Errorf("fields %v, result %v", fields, res)
+	}
+
+	if _, err := run(NewIssueCommentCommand(client), map[string]any{"issue": "OPS-2", "body": "Done in #12"}); err != nil {
+		t.Fatalf("comment: %v", err)
+	}
+	if gotBody["body"] != "Done in #12" {
+		t.Errorf("comment body = %v", gotBody)
+	}
+
+	_, err = run(NewIssueCommentCommand(client), map[string]any{"issue": "OPS-404", "body": "x"})
+	if err == nil || !strings.Contains(err.Error(), "Issue does not exist") {
+		t.Errorf("expected Jira error message, got %v", err)
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL, "", "pat")
+	if err := client.HealthCheck(gocontext.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if gotAuth != "Bearer pat" {
+		t.Errorf("Authorization = %q, want Bearer pat", gotAuth)
+	}
+}
+
+func TestCommandInputErrors(t *testing.T) {
+	client, _ := NewClient("https://jira.invalid", "", "pat")
+	tests := []struct {
+		name string
+		cmd  interface {
+			Execute(gocontext.Context, agshctx.Envelope, agshctx.ContextStore) (agshctx.Envelope, error)
+		}
+		payload any
+	}{
+		{"search without jql", NewSearchCommand(client), map[string]any{}},
+		{"create without project", NewIssueCreateCommand(client), map[string]any{"summary": "x"}},
+		{"create without summary", NewIssueCreateCommand(client), map[string]any{"project": "OPS"}},
+		{"comment without body", NewIssueCommentCommand(client), map[string]any{"issue": "OPS-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(tt.payload, "application/json", "test"), nil); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, cmd := range []platform.PlatformCommand{NewIssueCreateCommand(nil)} {
+		if len(platform.Examples(cmd)) == 0 {
+			t.Errorf("%s has no examples", cmd.Name())
+		}
+		if err := platform.CheckExamples(cmd); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package jira
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// maxSearchResults caps jira:issue:search's max_results.
+const maxSearchResults = 100
+
+// SearchCommand implements jira:issue:search — finds issues with JQL.
+type SearchCommand struct {
+	client *Client
+}
+
+// NewSearchCommand creates a new jira:issue:search command.
+func NewSearchCommand(client *Client) *SearchCommand {
+	return &SearchCommand{client: client}
+}
+
+func (c *SearchCommand) Name() string        { return "jira:issue:search" }
+func (c *SearchCommand) Description() string { return "Search issues with a JQL query" }
+func (c *SearchCommand) Namespace() string   { return "jira" }
+
+func (c *SearchCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"jql":         {Type: "string", Description: "JQL query, e.g. project = OPS AND status != Done"},
+			"max_results": {Type: "integer", Description: "Maximum issues to return (default 50, at most 100)"},
+		},
+		Required: []string{"jql"},
+	}
+}
+
+func (c *SearchCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"issues": {Type: "array", Description: "Matching issues with key, summary, status, assignee and URL"},
+			"count":  {Type: "integer", Description: "Number of issues returned"},
+			"total":  {Type: "integer", Description: "Number of issues matching the query"},
+		},
+	}
+}
+
+func (c *SearchCommand) RequiredCredentials() []string {
+	return []string{"JIRA_TOKEN"}
+}
+
+func (c *SearchCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	var jql string
+	maxResults := 50
+	switch v := input.Payload.(type) {
+	case string:
+		jql = v
+	case map[string]any:
+		jql, _ = v["jql"].(string)
+		switch n := v["max_results"].(type) {
+		case int:
+			maxResults = n
+		case float64:
+			maxResults = int(n)
+		}
+	}
+	if jql == "" {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:search: missing 'jql'")
+	}
+	if maxResults <= 0 || maxResults > maxSearchResults {
+		maxResults = maxSearchResults
+	}
+
+	var resp struct {
+		Total  int `json:"total"`
+		Issues []struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Summary string `json:"summary"`
+				Status  struct {
+					Name string `json:"name"`
+				} `json:"status"`
+				IssueType struct {
+					Name string `json:"name"`
+				} `json:"issuetype"`
+				Assignee *struct {
+					DisplayName string `json:"displayName"`
+				} `json:"assignee"`
+				Updated string `json:"updated"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	query := url.Values{
+		"jql":        {jql},
+		"maxResults": {fmt.Sprintf("%d", maxResults)},
+		"fields":     {"summary,status,issuetype,assignee,updated"},
+	}
+	if err := c.client.do(ctx, http.MethodGet, "/search", query, nil, &resp); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("jira:issue:search: API error: %w", err)
+	}
+
+	items := make([]map[string]any, 0, len(resp.Issues))
+	for _, is := range resp.Issues {
+		assignee := ""
+		if is.Fields.Assignee != nil {
+			assignee = is.Fields.Assignee.DisplayName
+		}
+		items = append(items, map[string]any{
+			"key":      is.Key,
+			"summary":  is.Fields.Summary,
+			"status":   is.Fields.Status.Name,
+			"type":     is.Fields.IssueType.Name,
+			"assignee": assignee,
+			"updated":  is.Fields.Updated,
+			"html_url": c.client.browseURL(is.Key),
+		})
+	}
+
+	result := map[string]any{
+		"issues": items,
+		"count":  len(items),
+		"total":  resp.Total,
+	}
+
+	env := agshctx.NewEnvelope(result, "application/json", "jira:issue:search")
+	env.Meta.Tags["jql"] = jql
+	env.Meta.Tags["count"] = fmt.Sprintf("%d", len(items))
+	return env, nil
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Budget caps the tokens all llm commands may use in a session. Calls
+// reserve their estimate before they are sent, so concurrent calls cannot
+// together overspend it. A nil *Budget is unlimited.
+type Budget struct {
+	mu       sync.Mutex
+	limit    int
+	used     int
+	reserved int // estimates of the calls in flight
+}
+
+// NewBudget creates a budget of limit tokens; 0 means unlimited.
+func NewBudget(limit int) *Budget {
+	return &Budget{limit: limit}
+}
+
+// SetLimit changes the limit, keeping the tokens already used; 0 means
+// unlimited.
+func (b *Budget) SetLimit(limit int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.limit = limit
+	b.mu.Unlock()
+}
+
+// Reserve sets aside estimate tokens for a call, or returns an error if
+// the budget cannot cover them on top of the tokens used and reserved by
+// calls in flight. Every successful Reserve must be followed by Settle.
+func (b *Budget) Reserve(estimate int) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit > 0 && b.used+b.reserved+estimate > b.limit {
+		return fmt.Errorf("token budget exceeded: %d of %d used, %d reserved, call needs up to %d", b.used, b.limit, b.reserved, estimate)
+	}
+	b.reserved += estimate
+	return nil
+}
+
+// Settle releases a reservation of estimate tokens and charges the tokens
+// the call used; 0 for a call that failed before using any.
+func (b *Budget) Settle(estimate, used int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.reserved -= estimate
+	b.used += used
+	b.mu.Unlock()
+}
+
+// Limit returns the limit; 0 means unlimited.
+func (b *Budget) Limit() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit
+}
+
+// Used returns the tokens charged so far.
+func (b *Budget) Used() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// EstimateTokens approximates the token count of text at four characters
+// per token, which is close for English prose and code.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
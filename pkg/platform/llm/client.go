@@ -0,0 +1,250 @@
+package llm
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Namespace describes the llm commands.
+var Namespace = platform.Namespace{
+	Name:        "llm",
+	Description: "Complete prompts and summarize text with the configured OpenAI-compatible model",
+	Credentials: []string{"LLM_API_KEY"},
+	Risk:        "read-only",
+}
+
+// Message is one chat message.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Completion is a model response with its token usage.
+type Completion struct {
+	Text             string
+	Model            string
+	FinishReason     string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Client talks to an OpenAI-compatible chat completions API and charges
+// every call to a shared token budget.
+type Client struct {
+	baseURL    string // e.g. https://api.openai.com/v1
+	apiKey     string
+	model      string
+	budget     *Budget
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the API at baseURL using model. apiKey
+// may be empty for local servers; budget may be nil for no limit.
+func NewClient(baseURL, apiKey, model string, budget *Budget) (*Client, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("llm base URL is required")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("llm model is required")
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		budget:     budget,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// HealthCheck verifies the endpoint and key by listing models.
+func (c *Client) HealthCheck(ctx gocontext.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	c.authorize(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("llm endpoint check: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("llm endpoint check: %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *Client) authorize(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+// Complete sends messages and returns the reply. maxTokens bounds the
+// reply; the prompt estimate plus maxTokens is reserved from the budget,
+// refusing the call if it cannot be, and the actual usage is charged
+// afterwards.
+func (c *Client) Complete(ctx gocontext.Context, messages []Message, maxTokens int, temperature *float64) (Completion, error) {
+	estimate := maxTokens
+	for _, m := range messages {
+		estimate += EstimateTokens(m.Content)
+	}
+	if err := c.budget.Reserve(estimate); err != nil {
+		return Completion{}, err
+	}
+	usage := 0
+	defer func() { c.budget.Settle(estimate, usage) }()
+
+	reqBody := map[string]any{
+		"model":      c.model,
+		"messages":   messages,
+		"max_tokens": maxTokens,
+	}
+	if temperature != nil {
+		reqBody["temperature"] = *temperature
+	}
+	var out struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message      Message `json:"message"`
+			FinishReason string  `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := c.post(ctx, "/chat/completions", reqBody, &out); err != nil {
+		return Completion{}, err
+	}
+	usage = out.Usage.PromptTokens + out.Usage.CompletionTokens
+	if len(out.Choices) == 0 {
+		return Completion{}, fmt.Errorf("API returned no choices")
+	}
+	if usage == 0 {
+		usage = estimate - maxTokens + EstimateTokens(out.Choices[0].Message.Content)
+	}
+
+	return Completion{
+		Text:             out.Choices[0].Message.Content,
+		Model:            out.Model,
+		FinishReason:     out.Choices[0].FinishReason,
+		PromptTokens:     out.Usage.PromptTokens,
+		CompletionTokens: out.Usage.CompletionTokens,
+	}, nil
+}
+
+// Embed returns one embedding vector per text from the /embeddings
+// endpoint using model. Usage is charged to the budget like completions.
+func (c *Client) Embed(ctx gocontext.Context, model string, texts []string) ([][]float32, error) {
+	estimate := 0
+	for _, t := range texts {
+		estimate += EstimateTokens(t)
+	}
+	if err := c.budget.Reserve(estimate); err != nil {
+		return nil, err
+	}
+	usage := 0
+	defer func() { c.budget.Settle(estimate, usage) }()
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := c.post(ctx, "/embeddings", map[string]any{"model": model, "input": texts}, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("API returned %d embeddings for %d inputs", len(out.Data), len(texts))
+	}
+	usage = estimate
+	if out.Usage.TotalTokens > 0 {
+		usage = out.Usage.TotalTokens
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("API returned embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// post sends body as JSON to path and decodes the response into out.
+func (c *Client) post(ctx gocontext.Context, path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if err := agshctx.CheckDomain(ctx, req.URL.Hostname()); err != nil {
+		return err
+	}
+	agshctx.RecordDomain(ctx, req.URL.Hostname())
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 32*1024*1024))
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != nil {
+			return platform.StatusError(resp.StatusCode, "API error: "+apiErr.Error.Message)
+		}
+		return platform.StatusError(resp.StatusCode, "API error: "+resp.Status)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// Embedder adapts the client to produce embeddings with one model.
+type Embedder struct {
+	client *Client
+	model  string
+}
+
+// NewEmbedder creates an embedder using model on client.
+func NewEmbedder(client *Client, model string) *Embedder {
+	return &Embedder{client: client, model: model}
+}
+
+// Name identifies the embedding model, so indexes built with another
+// model are not mixed with this one.
+func (e *Embedder) Name() string { return "llm:" + e.model }
+
+// Embed returns one vector per text.
+func (e *Embedder) Embed(ctx gocontext.Context, texts []string) ([][]float32, error) {
+	return e.client.Embed(ctx, e.model, texts)
+}
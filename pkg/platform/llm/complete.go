@@ -0,0 +1,110 @@
+package llm
+
+import (
+	gocontext "context"
+	"fmt"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// defaultMaxTokens bounds replies when the caller does not.
+const defaultMaxTokens = 1024
+
+// CompleteCommand implements llm:complete — sends a prompt to the model
+// and returns its reply as text.
+type CompleteCommand struct {
+	client *Client
+}
+
+// NewCompleteCommand creates a new llm:complete command.
+func NewCompleteCommand(client *Client) *CompleteCommand {
+	return &CompleteCommand{client: client}
+}
+
+func (c *CompleteCommand) Name() string        { return "llm:complete" }
+func (c *CompleteCommand) Description() string { return "Send a prompt to the configured LLM" }
+func (c *CompleteCommand) Namespace() string   { return "llm" }
+
+func (c *CompleteCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"prompt":      {Type: "string", Description: "User prompt"},
+			"system":      {Type: "string", Description: "System instructions"},
+			"max_tokens":  {Type: "integer", Description: "Maximum reply tokens (default 1024)"},
+			"temperature": {Type: "number", Description: "Sampling temperature"},
+		},
+		Required: []string{"prompt"},
+	}
+}
+
+func (c *CompleteCommand) OutputSchema() platform.Schema {
+	return platform.Schema{Type: "string"}
+}
+
+func (c *CompleteCommand) RequiredCredentials() []string {
+	return []string{"LLM_API_KEY"}
+}
+
+func (c *CompleteCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	var prompt, system string
+	maxTokens := defaultMaxTokens
+	var temperature *float64
+	switch v := input.Payload.(type) {
+	case string:
+		prompt = v
+	case map[string]any:
+		prompt, _ = v["prompt"].(string)
+		system, _ = v["system"].(string)
+		if n, ok := numberArg(v, "max_tokens"); ok {
+			maxTokens = int(n)
+		}
+		if t, ok := numberArg(v, "temperature"); ok {
+			temperature = &t
+		}
+	}
+	if prompt == "" {
+		return agshctx.Envelope{}, fmt.Errorf("llm:complete: missing 'prompt'")
+	}
+	if maxTokens <= 0 {
+		return agshctx.Envelope{}, fmt.Errorf("llm:complete: 'max_tokens' must be positive")
+	}
+
+	var messages []Message
+	if system != "" {
+		messages = append(messages, Message{Role: "system", Content: system})
+	}
+	messages = append(messages, Message{Role: "user", Content: prompt})
+
+	comp, err := c.client.Complete(ctx, messages, maxTokens, temperature)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("llm:complete: %w", err)
+	}
+	return completionEnvelope(comp, "llm:complete", comp.PromptTokens, comp.CompletionTokens), nil
+}
+
+// completionEnvelope wraps model output with usage tags.
+func completionEnvelope(comp Completion, source string, promptTokens, completionTokens int) agshctx.Envelope {
+	env := agshctx.NewEnvelope(comp.Text, "text/plain", source)
+	env.Meta.Tags["model"] = comp.Model
+	env.Meta.Tags["prompt_tokens"] = fmt.Sprintf("%d", promptTokens)
+	env.Meta.Tags["completion_tokens"] = fmt.Sprintf("%d", completionTokens)
+	if comp.FinishReason != "" {
+		env.Meta.Tags["finish_reason"] = comp.FinishReason
+	}
+	return env
+}
+
+// numberArg reads an optional numeric argument.
+func numberArg(args map[string]any, key string) (float64, bool) {
+	switch n := args[key].(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	gocontext "context"
+	"fmt"
+	"strings"
+)
+
+// Judging limits: outputs longer than judgeOutputChars are cut before
+// they are judged, and the verdict is at most judgeMaxTokens long.
+const (
+	judgeOutputChars = 24000
+	judgeMaxTokens   = 200
+)
+
+const judgeInstructions = "You check whether an output satisfies the given criteria. " +
+	"Answer PASS or FAIL on the first line, then give the reason in one sentence."
+
+// Judgment is the model's decision on whether an output satisfies the
+// criteria, with the completion that decided it.
+type Judgment struct {
+	Passed     bool
+	Reason     string
+	Completion Completion
+}
+
+// Judge asks the model whether output satisfies criteria written in prose.
+// The call is charged to the client's token budget. A reply without a
+// verdict is an error, returned with the Judgment holding its completion.
+func (c *Client) Judge(ctx gocontext.Context, criteria, output string) (Judgment, error) {
+	if len(output) > judgeOutputChars {
+		output = output[:judgeOutputChars] + "\n[output cut]"
+	}
+	zero := 0.0
+	comp, err := c.Complete(ctx, []Message{
+		{Role: "system", Content: judgeInstructions},
+		{Role: "user", Content: fmt.Sprintf("Criteria:\n%s\n\nOutput:\n%s", criteria, output)},
+	}, judgeMaxTokens, &zero)
+	if err != nil {
+		return Judgment{}, err
+	}
+	if comp.Model == "" {
+		comp.Model = c.model
+	}
+	j := Judgment{Completion: comp}
+
+	first, rest, _ := strings.Cut(strings.TrimSpace(comp.Text), "\n")
+	first = strings.TrimLeft(strings.TrimSpace(first), "*# ")
+	switch verdict := strings.ToUpper(first); {
+	case strings.HasPrefix(verdict, "PASS"):
+		j.Passed = true
+	case strings.HasPrefix(verdict, "FAIL"):
+	default:
+		return j, fmt.Errorf("judge gave no verdict: %q", comp.Text)
+	}
+	// The reason may follow the verdict on its line or on the next.
+	j.Reason = strings.TrimSpace(strings.TrimLeft(first[4:], "*.:- "))
+	if j.Reason == "" {
+		j.Reason = strings.TrimSpace(rest)
+	}
+	return j, nil
+}
+
+// Derive returns a client for another endpoint or model, charging the
+// same budget; empty arguments keep c's. The API key is kept only for
+// c's own endpoint. A nil c derives a client without key or budget.
+func (c *Client) Derive(baseURL, model string) (*Client, error) {
+	if c == nil {
+		return NewClient(baseURL, "", model, nil)
+	}
+	apiKey := c.apiKey
+	if baseURL == "" || strings.TrimSuffix(baseURL, "/") == c.baseURL {
+		baseURL = c.baseURL
+	} else {
+		apiKey = ""
+	}
+	if model == "" {
+		model = c.model
+	}
+	return NewClient(baseURL, apiKey, model, c.budget)
+}
@@ -0,0 +1,263 @@
+package llm
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// fakeServer answers chat completions with "reply N", charging 10 prompt
+// and 5 completion tokens per call.
+func fakeServer(t *testing.T) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"bad key"}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"data":[]}`))
+			return
+		case "/v1/embeddings":
+			w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}],"usage":{"total_tokens":6}}`))
+			return
+		}
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requests = append(requests, req)
+		n := len(requests)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{
+			"model":   req["model"],
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": "reply " + string(rune('0'+n))}, "finish_reason": "stop"}},
+			"usage":   map[string]any{"prompt_tokens": 10, "completion_tokens": 5},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestComplete(t *testing.T) {
+	srv, requests := fakeServer(t)
+	budget := NewBudget(0)
+	client, err := NewClient(srv.URL+"/v1/", "sk-test", "gpt-test", budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.HealthCheck(gocontext.Background()); err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+
+	args := map[string]any{"prompt": "Classify: disk full", "system": "Answer with one word.", "max_tokens": 16, "temperature": 0.0}
+	env, err := NewCompleteCommand(client).Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+	if err != nil {
+		t.Fatalf("llm:complete: %v", err)
+	}
+	if env.Payload != "reply 1" || env.Meta.ContentType != "text/plain" {
+		t.Errorf("payload %v (%s)", env.Payload, env.Meta.ContentType)
+	}
+	if env.Meta.Tags["model"] != "gpt-test" || env.Meta.Tags["completion_tokens"] != "5" {
+		t.Errorf("tags = %v", env.Meta.Tags)
+	}
+	req := (*requests)[0]
+	if req["max_tokens"] != float64(16) || req["temperature"] != float64(0) {
+		t.Errorf("request = %v", req)
+	}
+	if msgs := req["messages"].([]any); len(msgs) != 2 || msgs[0].(map[string]any)["role"] != "system" {
+		t.Errorf("messages = %v", msgs)
+	}
+	if budget.Used() != 15 {
+		t.Errorf("budget used = %d, want 15", budget.Used())
+	}
+
+	bad, _ := NewClient(srv.URL+"/v1", "wrong", "gpt-test", nil)
+	if _, err := NewCompleteCommand(bad).Execute(gocontext.Background(), agshctx.NewEnvelope("hi", "text/plain", "test"), nil); err == nil || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("expected API error, got %v", err)
+	}
+}
+
+func TestBudgetRefusesCall(t *testing.T) {
+	srv, requests := fakeServer(t)
+	client, _ := NewClient(srv.URL+"/v1", "sk-test", "gpt-test", NewBudget(100))
+
+	_, err := NewCompleteCommand(client).Execute(gocontext.Background(), agshctx.NewEnvelope("hi", "text/plain", "test"), nil)
+	if err == nil || !strings.Contains(err.Error(), "budget") {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	if len(*requests) != 0 {
+		t.Error("refused call reached the server")
+	}
+}
+
+func TestBudgetReservesInFlightCalls(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": "ok"}}},
+			"usage":   map[string]any{"prompt_tokens": 10, "completion_tokens": 5},
+		})
+	}))
+	defer srv.Close()
+	budget := NewBudget(100)
+	client, _ := NewClient(srv.URL, "", "gpt-test", budget)
+	complete := func() error {
+		_, err := client.Complete(gocontext.Background(), []Message{{Role: "user", Content: "hi"}}, 60, nil)
+		return err
+	}
+
+	// The first call holds its estimate while it runs, so a second one
+	// that would fit the unused budget is refused.
+	first := make(chan error)
+	go func() { first <- complete() }()
+	<-started
+	if err := complete(); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("concurrent call: %v, want a budget error", err)
+	}
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	// Settling frees the estimate and charges what was used.
+	if budget.Used() != 15 {
+		t.Errorf("budget used = %d, want 15", budget.Used())
+	}
+	if err := complete(); err != nil {
+		t.Errorf("call after the first settled: %v", err)
+	}
+}
+
+func TestSummarizeChunks(t *testing.T) {
+	srv, requests := fakeServer(t)
+	client, _ := NewClient(srv.URL+"/v1", "sk-test", "gpt-test", nil)
+	cmd := NewSummarizeCommand(client)
+
+	line := strings.Repeat("x", 99) + "\n"
+	log := strings.Repeat(line, 2*chunkChars/len(line)+10) // three chunks
+	env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(map[string]any{"content": log, "focus": "errors"}, "application/json", "fs:read"), nil)
+	if err != nil {
+		t.Fatalf("llm:summarize: %v", err)
+	}
+	if env.Meta.Tags["chunks"] != "3" || len(*requests) != 4 {
+		t.Fatalf("chunks tag %q, %d requests", env.Meta.Tags["chunks"], len(*requests))
+	}
+	if env.Payload != "reply 4" || env.Meta.Tags["prompt_tokens"] != "40" {
+		t.Errorf("payload %v, tags %v", env.Payload, env.Meta.Tags)
+	}
+	final := (*requests)[3]["messages"].([]any)
+	if got := final[1].(map[string]any)["content"]; got != "reply 1\n\nreply 2\n\nreply 3" {
+		t.Errorf("reduce input = %q", got)
+	}
+	if sys := final[0].(map[string]any)["content"].(string); !strings.Contains(sys, "errors") {
+		t.Errorf("system prompt missing focus: %q", sys)
+	}
+
+	chunks := splitChunks(log, chunkChars)
+	for _, chunk := range chunks[:len(chunks)-1] {
+		if len(chunk) > chunkChars || len(chunk)%len(line) != len(line)-1 {
+			t.Errorf("chunk of %d bytes does not end at a line break", len(chunk))
+		}
+	}
+	if _, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope("  ", "text/plain", "test"), nil); err == nil {
+		t.Error("expected error for empty text")
+	}
+}
+
+func TestEmbedder(t *testing.T) {
+	srv, _ := fakeServer(t)
+	budget := NewBudget(0)
+	client, _ := NewClient(srv.URL+"/v1", "sk-test", "gpt-test", budget)
+	e := NewEmbedder(client, "text-embedding-test")
+	if e.Name() != "llm:text-embedding-test" {
+		t.Errorf("Name() = %q", e.Name())
+	}
+	vectors, err := e.Embed(gocontext.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("vectors out of order: %v", vectors)
+	}
+	if budget.Used() != 6 {
+		t.Errorf("budget used = %d, want 6", budget.Used())
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, cmd := range []platform.PlatformCommand{NewSummarizeCommand(nil)} {
+		if len(platform.Examples(cmd)) == 0 {
+			t.Errorf("%s has no examples", cmd.Name())
+		}
+		if err := platform.CheckExamples(cmd); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestJudge(t *testing.T) {
+	replies := []string{"PASS\nThe report is grouped by repo.", "**FAIL**: the totals row is missing", "Maybe."}
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := replies[calls]
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{
+			"model":   "judge-test",
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": reply}}},
+			"usage":   map[string]any{"prompt_tokens": 40, "completion_tokens": 8},
+		})
+	}))
+	defer srv.Close()
+
+	budget := NewBudget(0)
+	client, _ := NewClient(srv.URL, "", "judge-test", budget)
+
+	j, err := client.Judge(gocontext.Background(), "grouped by repo", "## agsh\n...")
+	if err != nil || !j.Passed || j.Reason != "The report is grouped by repo." {
+		t.Errorf("Judge = %+v, %v", j, err)
+	}
+	if c := j.Completion; c.Model != "judge-test" || c.PromptTokens+c.CompletionTokens != 48 {
+		t.Errorf("completion = %+v", c)
+	}
+	j, err = client.Judge(gocontext.Background(), "has totals", "| a | 1 |")
+	if err != nil || j.Passed || j.Reason != "the totals row is missing" {
+		t.Errorf("Judge = %+v, %v", j, err)
+	}
+	if _, err := client.Judge(gocontext.Background(), "has totals", "| a | 1 |"); err == nil {
+		t.Error("expected an error for a reply without verdict")
+	}
+	if budget.Used() != 3*48 {
+		t.Errorf("budget used = %d, want %d", budget.Used(), 3*48)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	budget := NewBudget(0)
+	client, _ := NewClient("https://api.example.com/v1", "sk-test", "gpt-test", budget)
+
+	same, err := client.Derive("", "gpt-judge")
+	if err != nil || same.baseURL != client.baseURL || same.apiKey != "sk-test" || same.model != "gpt-judge" || same.budget != budget {
+		t.Errorf("Derive(\"\", model) = %+v, %v", same, err)
+	}
+	other, _ := client.Derive("http://localhost:11434/v1", "")
+	if other.apiKey != "" || other.model != "gpt-test" || other.budget != budget {
+		t.Errorf("another endpoint got %+v; the key must not be sent there", other)
+	}
+	if _, err := (*Client)(nil).Derive("http://localhost:11434/v1", ""); err == nil {
+		t.Error("a client of its own needs a model")
+	}
+}
@@ -0,0 +1,177 @@
+package llm
+
+import (
+	gocontext "context"
+	"fmt"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Chunking limits for llm:summarize. Text longer than chunkChars is
+// summarized chunk by chunk and the partial summaries are summarized
+// again.
+const (
+	chunkChars = 12000
+	maxChunks  = 32
+)
+
+// SummarizeCommand implements llm:summarize — condenses text, such as a
+// log or report piped from fs:read, with the model.
+type SummarizeCommand struct {
+	client *Client
+}
+
+// NewSummarizeCommand creates a new llm:summarize command.
+func NewSummarizeCommand(client *Client) *SummarizeCommand {
+	return &SummarizeCommand{client: client}
+}
+
+func (c *SummarizeCommand) Name() string        { return "llm:summarize" }
+func (c *SummarizeCommand) Description() string { return "Summarize text with the configured LLM" }
+func (c *SummarizeCommand) Namespace() string   { return "llm" }
+
+func (c *SummarizeCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"text":      {Type: "string", Description: "Text to summarize; a string payload or fs:read content is used as is", Aliases: []string{"content"}},
+			"max_words": {Type: "integer", Description: "Target summary length (default 200)"},
+			"focus":     {Type: "string", Description: "What the summary should concentrate on, e.g. errors"},
+			"format":    {Type: "string", Description: "paragraph (default) or bullets"},
+		},
+		Required: []string{"text"},
+	}
+}
+
+func (c *SummarizeCommand) OutputSchema() platform.Schema {
+	return platform.Schema{Type: "string"}
+}
+
+func (c *SummarizeCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Summarize text as bullets",
+			Input:       map[string]any{"text": "Release 1.4 adds ...", "max_words": 50, "format": "bullets"},
+			Output:      "- Adds ...",
+		},
+		{Description: "The output of fs:read can be piped in as is", Input: map[string]any{"content": "Meeting notes ...", "focus": "decisions"}},
+	}
+}
+
+func (c *SummarizeCommand) RequiredCredentials() []string {
+	return []string{"LLM_API_KEY"}
+}
+
+func (c *SummarizeCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	var text, focus, format string
+	maxWords := 200
+	switch v := input.Payload.(type) {
+	case string:
+		text = v
+	case map[string]any:
+		text, _ = v["text"].(string)
+		if text == "" {
+			text, _ = v["content"].(string) // fs:read output
+		}
+		focus, _ = v["focus"].(string)
+		format, _ = v["format"].(string)
+		if n, ok := numberArg(v, "max_words"); ok {
+			maxWords = int(n)
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		return agshctx.Envelope{}, fmt.Errorf("llm:summarize: no text to summarize")
+	}
+	if maxWords <= 0 {
+		return agshctx.Envelope{}, fmt.Errorf("llm:summarize: 'max_words' must be positive")
+	}
+	switch format {
+	case "", "paragraph", "bullets":
+	default:
+		return agshctx.Envelope{}, fmt.Errorf("llm:summarize: unknown format %q (expected paragraph or bullets)", format)
+	}
+
+	chunks := splitChunks(text, chunkChars)
+	if len(chunks) > maxChunks {
+		return agshctx.Envelope{}, fmt.Errorf("llm:summarize: text needs %d chunks (limit %d); read a smaller part, e.g. fs:read with tail or max_bytes", len(chunks), maxChunks)
+	}
+
+	var promptTokens, completionTokens int
+	summarize := func(text string, words int, partial bool) (Completion, error) {
+		messages := []Message{
+			{Role: "system", Content: summaryInstructions(words, focus, format, partial)},
+			{Role: "user", Content: text},
+		}
+		comp, err := c.client.Complete(ctx, messages, words*2+64, nil)
+		promptTokens += comp.PromptTokens
+		completionTokens += comp.CompletionTokens
+		return comp, err
+	}
+
+	var comp Completion
+	var err error
+	if len(chunks) == 1 {
+		comp, err = summarize(text, maxWords, false)
+	} else {
+		partials := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			part, err := summarize(chunk, maxWords, true)
+			if err != nil {
+				return agshctx.Envelope{}, fmt.Errorf("llm:summarize: chunk %d of %d: %w", i+1, len(chunks), err)
+			}
+			partials[i] = part.Text
+		}
+		// Keep the intermediate summaries so a poor result can be traced to
+		// the chunk that lost the detail.
+		var b strings.Builder
+		for i, p := range partials {
+			fmt.Fprintf(&b, "## Chunk %d of %d\n\n%s\n\n", i+1, len(partials), p)
+		}
+		agshctx.Attach(ctx, agshctx.Artifact{Name: "chunk-summaries.md", ContentType: "text/markdown", Data: []byte(b.String())})
+		comp, err = summarize(strings.Join(partials, "\n\n"), maxWords, false)
+	}
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("llm:summarize: %w", err)
+	}
+
+	env := completionEnvelope(comp, "llm:summarize", promptTokens, completionTokens)
+	env.Meta.Tags["chunks"] = fmt.Sprintf("%d", len(chunks))
+	return env, nil
+}
+
+// summaryInstructions builds the system prompt for one summarization call.
+func summaryInstructions(maxWords int, focus, format string, partial bool) string {
+	var b strings.Builder
+	if partial {
+		b.WriteString("The text is one part of a longer document. ")
+	}
+	fmt.Fprintf(&b, "Summarize the text in at most %d words. ", maxWords)
+	if focus != "" {
+		fmt.Fprintf(&b, "Concentrate on %s. ", focus)
+	}
+	if format == "bullets" {
+		b.WriteString("Answer with a markdown bullet list. ")
+	}
+	b.WriteString("Keep concrete names, numbers and errors; do not add information that is not in the text. Reply with the summary only.")
+	return b.String()
+}
+
+// splitChunks splits text into pieces of at most size bytes, breaking at
+// line ends where possible.
+func splitChunks(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		cut := strings.LastIndexByte(text[:size], '\n')
+		if cut <= 0 {
+			cut = size
+		}
+		chunks = append(chunks, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
@@ -0,0 +1,295 @@
+package mail
+
+import (
+	"bytes"
+	gocontext "context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Namespace describes the mail commands.
+var Namespace = platform.Namespace{
+	Name:        "mail",
+	Description: "Send email through the configured SMTP server to allowlisted recipients",
+	Credentials: []string{"SMTP_PASSWORD"},
+	Risk:        "write",
+}
+
+// Settings configures SMTP delivery.
+type Settings struct {
+	Host     string
+	Port     int // default 587; 465 uses implicit TLS
+	Username string
+	Password string
+	From     string
+	// AllowedRecipients lists addresses ("ops@acme.com") or domains
+	// ("@acme.com") mail may be sent to. Nothing is allowed when empty.
+	AllowedRecipients []string
+}
+
+// sendFunc matches smtp.SendMail.
+type sendFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+// SendCommand implements mail:send — emails a report or message.
+type SendCommand struct {
+	settings Settings
+	send     sendFunc
+}
+
+// NewSendCommand creates a new mail:send command.
+func NewSendCommand(settings Settings) *SendCommand {
+	if settings.Port == 0 {
+		settings.Port = 587
+	}
+	return &SendCommand{settings: settings, send: sendMail}
+}
+
+func (c *SendCommand) Name() string        { return "mail:send" }
+func (c *SendCommand) Description() string { return "Send an email to allowlisted recipients" }
+func (c *SendCommand) Namespace() string   { return "mail" }
+
+func (c *SendCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"to":      {Type: "array", Description: "Recipient addresses"},
+			"cc":      {Type: "array", Description: "Copy recipients"},
+			"subject": {Type: "string", Description: "Subject line"},
+			"body":    {Type: "string", Description: "Message body (plain text or markdown, or HTML)"},
+			"html":    {Type: "boolean", Description: "Send the body as HTML (default: from the input content type)"},
+		},
+		Required: []string{"to", "subject", "body"},
+	}
+}
+
+func (c *SendCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"message_id": {Type: "string", Description: "Message-ID of the sent mail"},
+			"to":         {Type: "array", Description: "Recipients"},
+			"bytes":      {Type: "integer", Description: "Size of the sent message"},
+		},
+	}
+}
+
+func (c *SendCommand) RequiredCredentials() []string {
+	return []string{"SMTP_PASSWORD"}
+}
+
+func (c *SendCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	args, ok := input.Payload.(map[string]any)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: expected map payload with 'to' and 'subject'")
+	}
+	if c.settings.Host == "" || c.settings.From == "" {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: smtp host and from address are not configured")
+	}
+
+	to, err := c.recipients(args["to"])
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: to: %w", err)
+	}
+	if len(to) == 0 {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: missing 'to'")
+	}
+	cc, err := c.recipients(args["cc"])
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: cc: %w", err)
+	}
+	subject, _ := args["subject"].(string)
+	if subject == "" {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: missing 'subject'")
+	}
+	if strings.ContainsAny(subject, "\r\n") {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: subject must be a single line")
+	}
+
+	body, ok := args["body"].(string)
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: missing 'body'")
+	}
+	html, ok := args["html"].(bool)
+	if !ok {
+		html = input.Meta.ContentType == "text/html"
+	}
+
+	messageID := newMessageID(c.settings.From)
+	msg, err := buildMessage(c.settings.From, to, cc, subject, body, html, messageID)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: %w", err)
+	}
+
+	var auth smtp.Auth
+	if c.settings.Username != "" {
+		auth = smtp.PlainAuth("", c.settings.Username, c.settings.Password, c.settings.Host)
+	}
+	addr := net.JoinHostPort(c.settings.Host, strconv.Itoa(c.settings.Port))
+	if err := agshctx.CheckDomain(ctx, c.settings.Host); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: %w", err)
+	}
+	agshctx.RecordDomain(ctx, c.settings.Host)
+	if err := c.send(addr, auth, c.settings.From, append(to, cc...), msg); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("mail:send: %w", err)
+	}
+
+	result := map[string]any{
+		"message_id": messageID,
+		"to":         to,
+		"subject":    subject,
+		"bytes":      len(msg),
+	}
+	if len(cc) > 0 {
+		result["cc"] = cc
+	}
+	env := agshctx.NewEnvelope(result, "application/json", "mail:send")
+	env.Meta.Tags["to"] = strings.Join(to, ",")
+	return env, nil
+}
+
+// recipients parses a string or list of addresses and checks each against
+// the allowlist.
+func (c *SendCommand) recipients(v any) ([]string, error) {
+	var raw []string
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		raw = strings.Split(x, ",")
+	case []any:
+		for _, item := range x {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected address strings, got %T", item)
+			}
+			raw = append(raw, s)
+		}
+	default:
+		return nil, fmt.Errorf("expected address list, got %T", v)
+	}
+
+	var out []string
+	for _, r := range raw {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		addr, err := netmail.ParseAddress(strings.TrimSpace(r))
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", r, err)
+		}
+		if !allowedRecipient(addr.Address, c.settings.AllowedRecipients) {
+			return nil, fmt.Errorf("recipient %s is not in the allowed recipients", addr.Address)
+		}
+		out = append(out, addr.Address)
+	}
+	return out, nil
+}
+
+// allowedRecipient reports whether addr matches an allowlist entry: an
+// exact address or "@domain".
+func allowedRecipient(addr string, allowed []string) bool {
+	addr = strings.ToLower(addr)
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimPrefix(a, "*"))
+		if a == addr || (strings.HasPrefix(a, "@") && strings.HasSuffix(addr, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildMessage renders an RFC 5322 message with a quoted-printable body.
+func buildMessage(from string, to, cc []string, subject, body string, html bool, messageID string) ([]byte, error) {
+	var b bytes.Buffer
+	contentType := "text/plain"
+	if html {
+		contentType = "text/html"
+	}
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	if len(cc) > 0 {
+		fmt.Fprintf(&b, "Cc: %s\r\n", strings.Join(cc, ", "))
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", messageID)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func newMessageID(from string) string {
+	domain := "agsh.local"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return "<" + hex.EncodeToString(buf) + "@" + domain + ">"
+}
+
+// sendMail delivers a message. Port 465 uses implicit TLS; other ports use
+// smtp.SendMail, which upgrades with STARTTLS when the server offers it.
+func sendMail(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	host, port, _ := net.SplitHostPort(addr)
+	if port != "465" {
+		return smtp.SendMail(addr, a, from, to, msg)
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if a != nil {
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
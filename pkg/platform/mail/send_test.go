@@ -0,0 +1,83 @@
+package mail
+
+import (
+	gocontext "context"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func TestSendCommand(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	cmd := NewSendCommand(Settings{
+		Host:              "smtp.example.com",
+		From:              "agsh@example.com",
+		AllowedRecipients: []string{"@example.com", "boss@partner.org"},
+	})
+	cmd.send = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+		return nil
+	}
+
+	args := map[string]any{
+		"to":      []any{"Team <team@example.com>"},
+		"cc":      "boss@partner.org",
+		"subject": "Weekly report – Ü",
+		"body":    "# Report\nAll green.",
+	}
+	env, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if gotAddr != "smtp.example.com:587" || gotFrom != "agsh@example.com" {
+		t.Errorf("addr %q, from %q", gotAddr, gotFrom)
+	}
+	if strings.Join(gotTo, ",") != "team@example.com,boss@partner.org" {
+		t.Errorf("envelope recipients = %v", gotTo)
+	}
+	msg := string(gotMsg)
+	for _, want := range []string{"To: team@example.com\r\n", "Cc: boss@partner.org\r\n", "Subject: =?utf-8?q?", "Content-Type: text/plain; charset=utf-8", "# Report\r\nAll green."} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q:\n%s", want, msg)
+		}
+	}
+	if env.Payload.(map[string]any)["message_id"] == "" {
+		t.Error("expected a message id")
+	}
+}
+
+func TestSendCommandRejects(t *testing.T) {
+	cmd := NewSendCommand(Settings{Host: "smtp.example.com", From: "agsh@example.com", AllowedRecipients: []string{"@example.com"}})
+	cmd.send = func(string, smtp.Auth, string, []string, []byte) error {
+		t.Error("send should not be called")
+		return nil
+	}
+
+	tests := []struct {
+		name string
+		args map[string]any
+	}{
+		{"recipient not allowed", map[string]any{"to": "someone@evil.com", "subject": "s", "body": "b"}},
+		{"lookalike domain", map[string]any{"to": "a@notexample.com", "subject": "s", "body": "b"}},
+		{"header injection", map[string]any{"to": "a@example.com", "subject": "s\r\nBcc: x@evil.com", "body": "b"}},
+		{"invalid address", map[string]any{"to": "not an address", "subject": "s", "body": "b"}},
+		{"missing body", map[string]any{"to": "a@example.com", "subject": "s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(tt.args, "application/json", "test"), nil); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	empty := NewSendCommand(Settings{Host: "smtp.example.com", From: "agsh@example.com"})
+	args := map[string]any{"to": "a@example.com", "subject": "s", "body": "b"}
+	if _, err := empty.Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil); err == nil {
+		t.Error("an empty allowlist should allow no recipients")
+	}
+}
@@ -0,0 +1,329 @@
+package platform
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgast/agsh/internal/glob"
+	"github.com/cgast/agsh/internal/policy"
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// Executor runs a resolved command. Middleware wraps executors to add
+// cross-cutting behavior around every command the registry executes.
+type Executor func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error)
+
+// Middleware wraps an Executor.
+type Middleware func(next Executor) Executor
+
+// Chain composes middlewares so that the first one listed runs outermost.
+func Chain(mws ...Middleware) Middleware {
+	return func(next Executor) Executor {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// invoke is the innermost executor: it calls the command itself.
+func invoke(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+	return cmd.Execute(ctx, input, store)
+}
+
+// Timing records how long each command took in the output's
+// "duration_ms" tag.
+func Timing() Middleware {
+	return func(next Executor) Executor {
+		return func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+			start := time.Now()
+			out, err := next(ctx, cmd, input, store)
+			if err == nil {
+				if out.Meta.Tags == nil {
+					out.Meta.Tags = make(map[string]string)
+				}
+				out.Meta.Tags["duration_ms"] = strconv.FormatInt(time.Since(start).Milliseconds(), 10)
+			}
+			return out, err
+		}
+	}
+}
+
+// Allowlist rejects commands whose names match none of the glob patterns
+// (e.g. "fs:*", "*:read"). An empty list allows everything.
+func Allowlist(patterns []string) Middleware {
+	return func(next Executor) Executor {
+		if len(patterns) == 0 {
+			return next
+		}
+		return func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+			for _, p := range patterns {
+				if glob.Match(p, cmd.Name()) {
+					return next(ctx, cmd, input, store)
+				}
+			}
+			return agshctx.Envelope{}, NewError("command.not_allowed", CategoryPermission, cmd.Name()+": command not in allowlist").
+				WithHint("add the command to executor.allowed_commands in .agsh/config.yaml")
+		}
+	}
+}
+
+// PathChecker decides whether a filesystem path may be accessed.
+// *sandbox.Sandbox implements it.
+type PathChecker interface {
+	CheckPath(path string) error
+}
+
+// pathFields are the input fields checked by the Sandbox middleware.
+var pathFields = []string{"path", "dir", "src", "dst", "source", "destination"}
+
+// Sandbox checks path-like inputs of every command against checker, and
+// against the sandbox of the run the command belongs to (see
+// sandbox.WithRun), before it runs, so commands that do not enforce the
+// sandbox themselves cannot reach outside it. checker may be nil. String
+// payloads are treated as paths for the fs namespace only.
+func Sandbox(checker PathChecker) Middleware {
+	return func(next Executor) Executor {
+		return func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+			var checkers []PathChecker
+			if checker != nil {
+				checkers = append(checkers, checker)
+			}
+			if run := sandbox.ForRun(ctx, nil); run != nil {
+				checkers = append(checkers, run)
+			}
+			var paths []string
+			switch p := input.Payload.(type) {
+			case map[string]any:
+				for _, field := range pathFields {
+					if s, ok := p[field].(string); ok && s != "" {
+						paths = append(paths, s)
+					}
+				}
+			case string:
+				if cmd.Namespace() == "fs" && p != "" {
+					paths = append(paths, p)
+				}
+			}
+			for _, path := range paths {
+				for _, c := range checkers {
+					if err := c.CheckPath(path); err != nil {
+						return agshctx.Envelope{}, fmt.Errorf("%s: %w", cmd.Name(), err)
+					}
+				}
+			}
+			return next(ctx, cmd, input, store)
+		}
+	}
+}
+
+// PolicyChecker decides what the policy says about running a command with
+// an input payload. *policy.Engine implements it.
+type PolicyChecker interface {
+	Evaluate(command string, payload any) policy.Decision
+}
+
+// Policy enforces checker's rules before every command. A denied command
+// fails with a "policy.denied" permission error naming the rule. A command
+// a rule requires approval for runs only when the operator approves it
+// through the running step's Asker (see agshctx.AskerFrom); without one it
+// fails with "policy.approval_required". denied, if set, is called with
+// every decision that stops a command.
+func Policy(checker PolicyChecker, denied func(command string, d policy.Decision)) Middleware {
+	return func(next Executor) Executor {
+		if checker == nil {
+			return next
+		}
+		return func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+			d := checker.Evaluate(cmd.Name(), input.Payload)
+			var err *Error
+			switch d.Effect {
+			case policy.EffectDeny:
+				err = NewError("policy.denied", CategoryPermission, policyMessage(cmd.Name(), "denies", d))
+			case policy.EffectApprove:
+				err = approve(ctx, cmd.Name(), d)
+			}
+			if err != nil {
+				if denied != nil {
+					denied(cmd.Name(), d)
+				}
+				return agshctx.Envelope{}, err.WithHint("policy rules are in the policy section of .agsh/config.yaml")
+			}
+			return next(ctx, cmd, input, store)
+		}
+	}
+}
+
+// PolicyInvalid refuses every command with a "policy.invalid" permission
+// error naming err. It stands in for Policy when the configured rules do
+// not compile, so a broken rule cannot switch the policy off.
+func PolicyInvalid(err error) Middleware {
+	return func(next Executor) Executor {
+		return func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+			return agshctx.Envelope{}, NewError("policy.invalid", CategoryPermission,
+				fmt.Sprintf("%s: refused because the policy rules are invalid: %v", cmd.Name(), err)).
+				WithHint("fix the policy section of .agsh/config.yaml")
+		}
+	}
+}
+
+// approve asks the operator to approve a command a policy rule requires
+// approval for. It returns nil when they do.
+func approve(ctx gocontext.Context, command string, d policy.Decision) *Error {
+	asker := agshctx.AskerFrom(ctx)
+	if asker == nil {
+		return NewError("policy.approval_required", CategoryPermission,
+			policyMessage(command, "requires approval for", d)+" (no one can approve it here)")
+	}
+	answer, err := asker.Ask(ctx, agshctx.Question{
+		Prompt:  policyMessage(command, "requires approval for", d) + ". Run it?",
+		Choices: []string{"yes", "no"},
+		Default: "no",
+	})
+	if err != nil {
+		e := NewError("policy.approval_required", CategoryPermission, policyMessage(command, "requires approval for", d)+": "+err.Error())
+		e.Err = err
+		return e
+	}
+	if answer != "yes" {
+		return NewError("policy.denied", CategoryPermission, policyMessage(command, "requires approval for", d)+": not approved")
+	}
+	return nil
+}
+
+func policyMessage(command, verb string, d policy.Decision) string {
+	msg := fmt.Sprintf("policy rule %q %s %s", d.Rule, verb, command)
+	if d.Message != "" {
+		msg += ": " + d.Message
+	}
+	return msg
+}
+
+// TaintRules configure the Taint middleware.
+type TaintRules struct {
+	// Sources are glob patterns of commands whose output is always
+	// untrusted, such as "llm:*".
+	Sources []string
+	// TrustedDomains are the hosts whose responses are trusted. A command
+	// that contacts any other host (see agshctx.RecordDomain) taints its
+	// output. "*.example.com" matches the subdomains of example.com.
+	TrustedDomains []string
+	// Sinks are the command inputs untrusted data must not reach.
+	Sinks []TaintSink
+}
+
+// TaintSink names command inputs that must not carry untrusted data.
+type TaintSink struct {
+	Command string   // glob pattern
+	Fields  []string // top-level input fields; empty means the whole input
+}
+
+// Taint tracks untrusted data through commands. Before a command runs, an
+// input whose tainted fields reach one of the sinks fails with a
+// "taint.blocked" permission error, and blocked, if set, is called with the
+// command, the fields (none for the whole input) and their sources. A string payload is the command's
+// main argument, so it reaches every sink of the command. After the command
+// runs, its output carries the input's taint, plus its own name when it is
+// a source or contacted an untrusted host ("http:get example.net").
+func Taint(rules TaintRules, blocked func(command string, fields, sources []string)) Middleware {
+	return func(next Executor) Executor {
+		return func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+			if fields, hit := taintedSink(rules.Sinks, cmd.Name(), input); hit {
+				if blocked != nil {
+					blocked(cmd.Name(), fields, input.Meta.Taint)
+				}
+				what := "input"
+				if len(fields) > 0 {
+					what = fmt.Sprintf("input %q", strings.Join(fields, `", "`))
+				}
+				return agshctx.Envelope{}, NewError("taint.blocked", CategoryPermission,
+					fmt.Sprintf("%s: %s derives from untrusted %s", cmd.Name(), what, strings.Join(input.Meta.Taint, ", "))).
+					WithHint("taint sources and sinks are in the taint section of .agsh/config.yaml")
+			}
+
+			out, err := next(ctx, cmd, input, store)
+			if err != nil {
+				return out, err
+			}
+			out.AddTaint(input.Meta.Taint...)
+			for _, p := range rules.Sources {
+				if glob.Match(p, cmd.Name()) {
+					out.AddTaint(cmd.Name())
+					break
+				}
+			}
+			for _, host := range agshctx.StepEffects(ctx).Domains {
+				if !agshctx.MatchDomain(host, rules.TrustedDomains) {
+					out.AddTaint(cmd.Name() + " " + host)
+				}
+			}
+			return out, nil
+		}
+	}
+}
+
+// taintedSink reports whether tainted input reaches a sink of command, and
+// through which fields; none when it reaches a sink as a whole.
+func taintedSink(sinks []TaintSink, command string, input agshctx.Envelope) ([]string, bool) {
+	if !input.Tainted() {
+		return nil, false
+	}
+	var fields []string
+	for _, sink := range sinks {
+		if !glob.Match(sink.Command, command) {
+			continue
+		}
+		if _, ok := input.Payload.(string); ok || len(sink.Fields) == 0 {
+			return nil, true
+		}
+		m, _ := input.Payload.(map[string]any)
+		for _, f := range sink.Fields {
+			if _, ok := m[f]; ok && input.FieldTainted(f) && !slices.Contains(fields, f) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields, len(fields) > 0
+}
+
+// Retry re-runs a failed command up to attempts-1 more times, waiting
+// backoff (doubled after each failure) in between. Cancellation of ctx stops
+// retrying, as does an *Error that is not retriable. attempts <= 1 disables
+// retries.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next Executor) Executor {
+		if attempts <= 1 {
+			return next
+		}
+		return func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+			wait := backoff
+			var out agshctx.Envelope
+			var err error
+			for i := 0; i < attempts; i++ {
+				if i > 0 {
+					select {
+					case <-ctx.Done():
+						return agshctx.Envelope{}, fmt.Errorf("%w (after %d attempts: %v)", ctx.Err(), i, err)
+					case <-time.After(wait):
+					}
+					wait *= 2
+				}
+				out, err = next(ctx, cmd, input, store)
+				if err == nil || ctx.Err() != nil {
+					return out, err
+				}
+				var typed *Error
+				if errors.As(err, &typed) && !typed.Retriable {
+					return out, err
+				}
+			}
+			return out, fmt.Errorf("%w (after %d attempts)", err, attempts)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package platform
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgast/agsh/internal/policy"
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// flakyCommand fails until it has been called failures+1 times.
+type flakyCommand struct {
+	mockCommand
+	failures int
+	calls    int
+}
+
+func (f *flakyCommand) Execute(_ gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return agshctx.Envelope{}, errors.New("transient")
+	}
+	return input, nil
+}
+
+type denyChecker struct{ prefix string }
+
+func (d denyChecker) CheckPath(path string) error {
+	if strings.HasPrefix(path, d.prefix) {
+		return errors.New("access denied: " + path)
+	}
+	return nil
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Executor) Executor {
+			return func(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+				order = append(order, name)
+				return next(ctx, cmd, input, store)
+			}
+		}
+	}
+
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:list", namespace: "fs"})
+	reg.SetMiddleware(trace("a"), trace("b"))
+	if _, err := reg.Execute(gocontext.Background(), "fs:list", agshctx.NewEnvelope("x", "text/plain", "test"), nil); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if strings.Join(order, ",") != "a,b" {
+		t.Errorf("order = %v, want [a b]", order)
+	}
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:list", namespace: "fs"})
+	reg.SetMiddleware(Timing())
+
+	out, err := reg.Execute(gocontext.Background(), "fs:list", agshctx.NewEnvelope("x", "text/plain", "test"), nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if _, ok := out.Meta.Tags["duration_ms"]; !ok {
+		t.Errorf("duration_ms tag missing: %v", out.Meta.Tags)
+	}
+}
+
+func TestAllowlistMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:list", namespace: "fs"})
+	reg.Register(&mockCommand{name: "http:get", namespace: "http"})
+	reg.SetMiddleware(Allowlist([]string{"fs:*"}))
+
+	in := agshctx.NewEnvelope("x", "text/plain", "test")
+	if _, err := reg.Execute(gocontext.Background(), "fs:list", in, nil); err != nil {
+		t.Errorf("fs:list should be allowed: %v", err)
+	}
+	_, err := reg.Execute(gocontext.Background(), "http:get", in, nil)
+	if err == nil {
+		t.Fatal("http:get should be rejected")
+	}
+	if ce := Classify(err); ce.Category != CategoryPermission || ce.Hint == "" {
+		t.Errorf("rejection classified as %s (hint %q)", ce.Category, ce.Hint)
+	}
+}
+
+func TestSandboxMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:read", namespace: "fs"})
+	reg.SetMiddleware(Sandbox(denyChecker{prefix: "/etc"}))
+
+	ctx := gocontext.Background()
+	if _, err := reg.Execute(ctx, "fs:read", agshctx.NewEnvelope("/etc/passwd", "text/plain", "test"), nil); err == nil {
+		t.Error("string path under /etc should be rejected")
+	}
+	args := map[string]any{"path": "/etc/hosts"}
+	if _, err := reg.Execute(ctx, "fs:read", agshctx.NewEnvelope(args, "application/json", "test"), nil); err == nil {
+		t.Error("path field under /etc should be rejected")
+	}
+	if _, err := reg.Execute(ctx, "fs:read", agshctx.NewEnvelope("/tmp/x", "text/plain", "test"), nil); err != nil {
+		t.Errorf("/tmp/x should be allowed: %v", err)
+	}
+}
+
+// A run's sandbox applies with or without a configured one.
+func TestSandboxMiddlewareRun(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:read", namespace: "fs"})
+	reg.SetMiddleware(Sandbox(nil))
+
+	ctx, err := sandbox.WithRun(gocontext.Background(), sandbox.Config{AllowedPaths: []string{"/tmp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reg.Execute(ctx, "fs:read", agshctx.NewEnvelope("/var/x", "text/plain", "test"), nil)
+	if !errors.Is(err, sandbox.ErrViolation) {
+		t.Errorf("/var/x outside the run's sandbox: err = %v, want a violation", err)
+	}
+	if _, err := reg.Execute(ctx, "fs:read", agshctx.NewEnvelope("/tmp/x", "text/plain", "test"), nil); err != nil {
+		t.Errorf("/tmp/x should be allowed: %v", err)
+	}
+}
+
+// answerAsker answers every question with answer.
+type answerAsker struct {
+	answer string
+	asked  []agshctx.Question
+}
+
+func (a *answerAsker) Ask(_ gocontext.Context, q agshctx.Question) (string, error) {
+	a.asked = append(a.asked, q)
+	return a.answer, nil
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:list", namespace: "fs"})
+	reg.SetMiddleware(PolicyInvalid(errors.New("rule 1: bad condition")))
+	_, err := reg.Execute(gocontext.Background(), "fs:list", agshctx.NewEnvelope(nil, "text/plain", "test"), nil)
+	var typed *Error
+	if !errors.As(err, &typed) || typed.Code != "policy.invalid" || !strings.Contains(err.Error(), "bad condition") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	engine, err := policy.New([]policy.Rule{
+		{Name: "foreign", Command: "github:issue:create", When: "repo != cgast/*", Effect: policy.EffectDeny, Message: "only our repos"},
+		{Command: "fs:write", When: "len(content) > 4", Effect: policy.EffectApprove},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var denied []string
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "github:issue:create", namespace: "github"})
+	reg.Register(&mockCommand{name: "fs:write", namespace: "fs"})
+	reg.SetMiddleware(Policy(engine, func(command string, d policy.Decision) {
+		denied = append(denied, command+"/"+d.Rule)
+	}))
+	ctx := gocontext.Background()
+	env := func(payload map[string]any) agshctx.Envelope {
+		return agshctx.NewEnvelope(payload, "application/json", "test")
+	}
+
+	_, err = reg.Execute(ctx, "github:issue:create", env(map[string]any{"repo": "evil/repo"}), nil)
+	var typed *Error
+	if !errors.As(err, &typed) || typed.Code != "policy.denied" || typed.Category != CategoryPermission ||
+		!strings.Contains(err.Error(), `policy rule "foreign" denies github:issue:create: only our repos`) {
+		t.Errorf("denied err = %v", err)
+	}
+	if _, err := reg.Execute(ctx, "github:issue:create", env(map[string]any{"repo": "cgast/agsh"}), nil); err != nil {
+		t.Errorf("allowed repo: %v", err)
+	}
+
+	// Outside a pipeline no one can approve.
+	big := env(map[string]any{"content": "too long"})
+	if _, err := reg.Execute(ctx, "fs:write", big, nil); !errors.As(err, &typed) || typed.Code != "policy.approval_required" {
+		t.Errorf("approval without asker err = %v", err)
+	}
+
+	// In a pipeline the step's asker approves or refuses.
+	for _, answer := range []string{"yes", "no"} {
+		asker := &answerAsker{answer: answer}
+		p := &agshctx.Pipeline{
+			Steps:    []agshctx.PipelineStep{{Command: "fs:write"}},
+			Executor: reg,
+			Asker:    asker,
+		}
+		_, err := p.Run(ctx, big)
+		if len(asker.asked) != 1 || (answer == "yes") != (err == nil) {
+			t.Errorf("answer %s: asked %d times, err = %v", answer, len(asker.asked), err)
+		}
+	}
+	if want := "[github:issue:create/foreign fs:write/rule 2 fs:write/rule 2]"; fmt.Sprint(denied) != want {
+		t.Errorf("denied = %v, want %s", denied, want)
+	}
+}
+
+// fetchCommand reports contacting hosts and returns a payload naming path.
+type fetchCommand struct {
+	mockCommand
+	hosts []string
+}
+
+func (f *fetchCommand) Execute(ctx gocontext.Context, _ agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	for _, h := range f.hosts {
+		agshctx.RecordDomain(ctx, h)
+	}
+	return agshctx.NewEnvelope(map[string]any{"path": "/tmp/x", "content": "x"}, "application/json", "test"), nil
+}
+
+func TestTaintMiddleware(t *testing.T) {
+	var blocked []string
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "llm:complete", namespace: "llm"})
+	reg.Register(&mockCommand{name: "fs:write", namespace: "fs"})
+	reg.Register(&fetchCommand{mockCommand: mockCommand{name: "http:get", namespace: "http"},
+		hosts: []string{"api.github.com", "evil.example.net"}})
+	reg.SetMiddleware(Taint(TaintRules{
+		Sources:        []string{"llm:*"},
+		TrustedDomains: []string{"*.github.com"},
+		Sinks:          []TaintSink{{Command: "fs:write", Fields: []string{"path"}}},
+	}, func(command string, fields, sources []string) {
+		blocked = append(blocked, fmt.Sprintf("%s %v %v", command, fields, sources))
+	}))
+	ctx := gocontext.Background()
+
+	out, err := reg.Execute(ctx, "llm:complete", agshctx.NewEnvelope("/tmp/x", "text/plain", "test"), nil)
+	if err != nil || fmt.Sprint(out.Meta.Taint) != "[llm:complete]" {
+		t.Fatalf("llm:complete: taint = %v, err = %v", out.Meta.Taint, err)
+	}
+	// A completion used as a path is blocked.
+	_, err = reg.Execute(ctx, "fs:write", out, nil)
+	var typed *Error
+	if !errors.As(err, &typed) || typed.Code != "taint.blocked" || typed.Category != CategoryPermission {
+		t.Errorf("tainted string path: err = %v", err)
+	}
+
+	// Tainted content under a trusted path is written, and stays tainted.
+	in := agshctx.NewEnvelope(map[string]any{"path": "/tmp/x", "content": "x"}, "application/json", "test")
+	in.Meta.Taint = []string{"llm:complete"}
+	in.Meta.TaintedFields = []string{"content"}
+	if out, err := reg.Execute(ctx, "fs:write", in, nil); err != nil || !out.Tainted() {
+		t.Errorf("tainted content: taint = %v, err = %v", out.Meta.Taint, err)
+	}
+
+	// Hosts a step contacts taint its output unless they are trusted.
+	p := &agshctx.Pipeline{
+		Steps:    []agshctx.PipelineStep{{Command: "http:get"}, {Command: "fs:write"}},
+		Executor: reg,
+	}
+	result, err := p.Run(ctx, agshctx.NewEnvelope(nil, "", "test"))
+	if !errors.As(err, &typed) || typed.Code != "taint.blocked" ||
+		!strings.Contains(err.Error(), `fs:write: input "path" derives from untrusted http:get evil.example.net`) {
+		t.Errorf("fetched path: err = %v", err)
+	}
+	if len(result.Steps) != 2 || fmt.Sprint(result.Steps[0].Output.Meta.Taint) != "[http:get evil.example.net]" {
+		t.Errorf("http:get taint = %v", result.Steps[0].Output.Meta.Taint)
+	}
+	if want := "[fs:write [] [llm:complete] fs:write [path] [http:get evil.example.net]]"; fmt.Sprint(blocked) != want {
+		t.Errorf("blocked = %v, want %s", blocked, want)
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	cmd := &flakyCommand{mockCommand: mockCommand{name: "http:get", namespace: "http"}, failures: 2}
+	reg := NewRegistry()
+	reg.Register(cmd)
+	reg.SetMiddleware(Retry(3, time.Millisecond))
+
+	if _, err := reg.Execute(gocontext.Background(), "http:get", agshctx.NewEnvelope(nil, "", "test"), nil); err != nil {
+		t.Fatalf("expected success on third attempt: %v", err)
+	}
+	if cmd.calls != 3 {
+		t.Errorf("calls = %d, want 3", cmd.calls)
+	}
+
+	cmd.calls, cmd.failures = 0, 5
+	_, err := reg.Execute(gocontext.Background(), "http:get", agshctx.NewEnvelope(nil, "", "test"), nil)
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("expected exhausted retries, got %v", err)
+	}
+
+	calls := 0
+	permanent := Retry(3, time.Millisecond)(func(gocontext.Context, PlatformCommand, agshctx.Envelope, agshctx.ContextStore) (agshctx.Envelope, error) {
+		calls++
+		return agshctx.Envelope{}, StatusError(404, "GET /x: 404 Not Found")
+	})
+	if _, err := permanent(gocontext.Background(), cmd, agshctx.Envelope{}, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("non-retriable error was attempted %d times, want 1", calls)
+	}
+}
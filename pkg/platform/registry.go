@@ -1,22 +1,75 @@
 package platform
 
 import (
+	gocontext "context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+
+	"github.com/cgast/agsh/internal/glob"
+	agshctx "github.com/cgast/agsh/pkg/context"
 )
 
 // Registry holds all registered platform commands, keyed by full name.
 type Registry struct {
-	mu       sync.RWMutex
-	commands map[string]PlatformCommand
+	mu         sync.RWMutex
+	commands   map[string]PlatformCommand
+	aliases    map[string]Alias
+	namespaces map[string]Namespace
+	health     map[string]HealthChecker
+	exec       Executor    // middleware chain around Execute; nil = invoke directly
+	deprecated func(Alias) // called when a deprecated alias is resolved
+}
+
+// Alias is an alternative name for a registered command, typically its old
+// name after a rename, so existing specs keep working.
+type Alias struct {
+	Name       string `json:"name"`   // e.g. "http:get"
+	Target     string `json:"target"` // canonical name, e.g. "net:http:get"
+	Deprecated bool   `json:"deprecated,omitempty"`
+	Message    string `json:"message,omitempty"` // shown when a deprecated alias is used
 }
 
 // NewRegistry creates an empty command registry.
 func NewRegistry() *Registry {
 	return &Registry{
-		commands: make(map[string]PlatformCommand),
+		commands:   make(map[string]PlatformCommand),
+		aliases:    make(map[string]Alias),
+		namespaces: make(map[string]Namespace),
+		health:     make(map[string]HealthChecker),
+	}
+}
+
+// Namespace describes a group of commands as a whole, so clients can
+// understand what a namespace offers without reading every command.
+type Namespace struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Credentials []string `json:"required_credentials,omitempty"`
+	// Risk is the default risk of the namespace's commands: "read-only",
+	// "write" or "destructive".
+	Risk string `json:"risk,omitempty"`
+}
+
+// RegisterNamespace records metadata for a namespace, replacing any
+// previously registered for the same name.
+func (r *Registry) RegisterNamespace(ns Namespace) {
+	r.mu.Lock()
+	r.namespaces[ns.Name] = ns
+	r.mu.Unlock()
+}
+
+// NamespaceInfo returns the metadata for a namespace. Namespaces without
+// registered metadata get one with only the name set.
+func (r *Registry) NamespaceInfo(name string) Namespace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if ns, ok := r.namespaces[name]; ok {
+		return ns
 	}
+	return Namespace{Name: name}
 }
 
 // Register adds a command to the registry. Returns an error if a command
@@ -29,18 +82,184 @@ func (r *Registry) Register(cmd PlatformCommand) error {
 	if _, exists := r.commands[name]; exists {
 		return fmt.Errorf("command already registered: %s", name)
 	}
+	if a, exists := r.aliases[name]; exists {
+		return fmt.Errorf("command name %s is already an alias of %s", name, a.Target)
+	}
 	r.commands[name] = cmd
 	return nil
 }
 
-// Resolve looks up a command by its full name (e.g. "fs:list").
-func (r *Registry) Resolve(name string) (PlatformCommand, error) {
+// RegisterAlias makes a.Name resolve to the command a.Target. The target
+// need not be registered yet; resolving the alias fails until it is.
+func (r *Registry) RegisterAlias(a Alias) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.commands[a.Name]; exists {
+		return fmt.Errorf("alias %s clashes with a registered command", a.Name)
+	}
+	if _, exists := r.aliases[a.Name]; exists {
+		return fmt.Errorf("alias already registered: %s", a.Name)
+	}
+	if _, isAlias := r.aliases[a.Target]; isAlias {
+		return fmt.Errorf("alias %s: target %s is itself an alias", a.Name, a.Target)
+	}
+	r.aliases[a.Name] = a
+	return nil
+}
+
+// Aliases returns the aliases of the command named target, sorted by name.
+func (r *Registry) Aliases(target string) []Alias {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []Alias
+	for _, a := range r.aliases {
+		if a.Target == target {
+			result = append(result, a)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// LookupAlias returns the alias registered under name, if any.
+func (r *Registry) LookupAlias(name string) (Alias, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	a, ok := r.aliases[name]
+	return a, ok
+}
+
+// OnDeprecated sets the function called whenever Resolve follows a
+// deprecated alias.
+func (r *Registry) OnDeprecated(fn func(Alias)) {
+	r.mu.Lock()
+	r.deprecated = fn
+	r.mu.Unlock()
+}
+
+// ReplaceAll atomically swaps this registry's commands and middleware
+// chain for those of other, so a config reload never exposes a
+// half-registered command set or runs the new commands through the old
+// middleware.
+func (r *Registry) ReplaceAll(other *Registry) {
+	other.mu.RLock()
+	exec := other.exec
+	commands := make(map[string]PlatformCommand, len(other.commands))
+	for name, cmd := range other.commands {
+		commands[name] = cmd
+	}
+	aliases := make(map[string]Alias, len(other.aliases))
+	for name, a := range other.aliases {
+		aliases[name] = a
+	}
+	namespaces := make(map[string]Namespace, len(other.namespaces))
+	for name, ns := range other.namespaces {
+		namespaces[name] = ns
+	}
+	health := make(map[string]HealthChecker, len(other.health))
+	for name, hc := range other.health {
+		health[name] = hc
+	}
+	other.mu.RUnlock()
+
+	r.mu.Lock()
+	r.commands = commands
+	r.aliases = aliases
+	r.namespaces = namespaces
+	r.health = health
+	r.exec = exec
+	r.mu.Unlock()
+}
+
+// SetMiddleware replaces the middleware chain that Execute and Invoke run
+// commands through. The first middleware runs outermost.
+func (r *Registry) SetMiddleware(mws ...Middleware) {
+	exec := Chain(mws...)(invoke)
+	r.mu.Lock()
+	r.exec = exec
+	r.mu.Unlock()
+}
+
+// Execute resolves a command by name and runs it through the middleware
+// chain.
+func (r *Registry) Execute(ctx gocontext.Context, name string, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+	cmd, err := r.Resolve(name)
+	if err != nil {
+		return agshctx.Envelope{}, err
+	}
+	return r.Invoke(ctx, cmd, input, store)
+}
+
+// Invoke runs an already resolved command through the middleware chain.
+// The input is first converted to the shape the command's input schema
+// expects, so middleware sees the fields the command will, and then
+// checked against the schema; invalid input fails with an "input.invalid"
+// error listing the offending fields.
+func (r *Registry) Invoke(ctx gocontext.Context, cmd PlatformCommand, input agshctx.Envelope, store agshctx.ContextStore) (agshctx.Envelope, error) {
+	input, err := input.ConvertTo(cmd.InputSchema().Type)
+	if err != nil {
+		return agshctx.Envelope{}, NewError("input.convert", CategoryInvalidInput, fmt.Sprintf("%s: input: %v", cmd.Name(), err))
+	}
+	if err := validateInput(cmd, input); err != nil {
+		return agshctx.Envelope{}, err
+	}
+	r.mu.RLock()
+	exec := r.exec
+	r.mu.RUnlock()
+	if exec == nil {
+		exec = invoke
+	}
+	return exec(ctx, cmd, input, store)
+}
+
+// CheckOutput checks the output of the named command against its output
+// schema (see the package function CheckOutput).
+func (r *Registry) CheckOutput(name string, output agshctx.Envelope) ([]FieldError, error) {
+	cmd, err := r.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return CheckOutput(cmd, output)
+}
+
+// CommandRisk returns the risk the named command reports for payload (see
+// RiskClassifier), or "" when it is unknown or does not classify its input.
+func (r *Registry) CommandRisk(name string, payload any) string {
+	r.mu.RLock()
+	cmd, ok := r.commands[name]
+	if a, isAlias := r.aliases[name]; !ok && isAlias {
+		cmd, ok = r.commands[a.Target]
+	}
+	r.mu.RUnlock()
+	if rc, isRC := cmd.(RiskClassifier); ok && isRC {
+		return rc.Risk(payload)
+	}
+	return ""
+}
+
+// Resolve looks up a command by its full name (e.g. "fs:list"), following
+// aliases. Resolving a deprecated alias notifies the OnDeprecated function.
+func (r *Registry) Resolve(name string) (PlatformCommand, error) {
+	r.mu.RLock()
 	cmd, ok := r.commands[name]
+	a, isAlias := r.aliases[name]
+	if !ok && isAlias {
+		cmd, ok = r.commands[a.Target]
+	}
+	notify := r.deprecated
+	r.mu.RUnlock()
+
 	if !ok {
-		return nil, fmt.Errorf("command not found: %s", name)
+		if isAlias {
+			return nil, fmt.Errorf("%w: %s (alias of %s)", ErrCommandNotFound, name, a.Target)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrCommandNotFound, name)
+	}
+	if isAlias && a.Deprecated && notify != nil {
+		notify(a)
 	}
 	return cmd, nil
 }
@@ -60,14 +279,85 @@ func (r *Registry) List(namespace string) []PlatformCommand {
 	return result
 }
 
-// Describe returns the input schema for a command.
+// Search returns the commands matching every whitespace-separated term in
+// query. A term matches a command's name, description, or input field names,
+// case-insensitively. Results are ordered by relevance, name matches first.
+func (r *Registry) Search(query string) []PlatformCommand {
+	terms := strings.Fields(strings.ToLower(query))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type hit struct {
+		cmd   PlatformCommand
+		score int
+	}
+	var hits []hit
+	for _, cmd := range r.commands {
+		if score := searchScore(cmd, terms); score > 0 {
+			hits = append(hits, hit{cmd, score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].cmd.Name() < hits[j].cmd.Name()
+	})
+
+	result := make([]PlatformCommand, len(hits))
+	for i, h := range hits {
+		result[i] = h.cmd
+	}
+	return result
+}
+
+// searchScore returns 0 if any term fails to match cmd, otherwise a score
+// weighting name matches above field-name and description matches.
+func searchScore(cmd PlatformCommand, terms []string) int {
+	if len(terms) == 0 {
+		return 1
+	}
+	name := strings.ToLower(cmd.Name())
+	desc := strings.ToLower(cmd.Description())
+
+	score := 0
+	for _, term := range terms {
+		switch {
+		case strings.Contains(name, term):
+			score += 3
+		case fieldNameContains(cmd.InputSchema(), term):
+			score += 2
+		case strings.Contains(desc, term):
+			score++
+		default:
+			return 0
+		}
+	}
+	return score
+}
+
+func fieldNameContains(s Schema, term string) bool {
+	for field := range s.Properties {
+		if strings.Contains(strings.ToLower(field), term) {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe returns the input schema for a command, following aliases.
 func (r *Registry) Describe(name string) (Schema, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	cmd, ok := r.commands[name]
+	if a, isAlias := r.aliases[name]; !ok && isAlias {
+		cmd, ok = r.commands[a.Target]
+	}
 	if !ok {
-		return Schema{}, fmt.Errorf("command not found: %s", name)
+		return Schema{}, fmt.Errorf("%w: %s", ErrCommandNotFound, name)
 	}
 	return cmd.InputSchema(), nil
 }
@@ -104,28 +394,17 @@ func (r *Registry) Namespaces() []string {
 	return result
 }
 
-// MatchGlob returns all commands matching a glob pattern like "fs:*" or "github:pr:*".
+// MatchGlob returns all commands matching a glob pattern like "fs:*",
+// "github:*:list" or "*:write". See package internal/glob for the syntax.
 func (r *Registry) MatchGlob(pattern string) []PlatformCommand {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	var result []PlatformCommand
 	for name, cmd := range r.commands {
-		if matchGlob(pattern, name) {
+		if glob.Match(pattern, name) {
 			result = append(result, cmd)
 		}
 	}
 	return result
 }
-
-// matchGlob checks if name matches a simple glob pattern (only trailing * supported).
-func matchGlob(pattern, name string) bool {
-	if pattern == "*" {
-		return true
-	}
-	if strings.HasSuffix(pattern, "*") {
-		prefix := strings.TrimSuffix(pattern, "*")
-		return strings.HasPrefix(name, prefix)
-	}
-	return pattern == name
-}
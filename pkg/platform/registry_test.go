@@ -2,6 +2,9 @@ package platform
 
 import (
 	gocontext "context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
 	agshctx "github.com/cgast/agsh/pkg/context"
@@ -58,6 +61,25 @@ func TestRegistryDuplicateRegister(t *testing.T) {
 	}
 }
 
+func TestRegistryReplaceAll(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:list", namespace: "fs"})
+	reg.SetMiddleware(Allowlist([]string{"fs:*"}))
+
+	next := NewRegistry()
+	next.Register(&mockCommand{name: "http:get", namespace: "http"})
+	next.SetMiddleware(Allowlist([]string{"http:*"}))
+	reg.ReplaceAll(next)
+
+	if _, err := reg.Resolve("fs:list"); err == nil {
+		t.Error("expected fs:list to be gone after ReplaceAll")
+	}
+	// The new commands run through the new middleware.
+	if _, err := reg.Execute(gocontext.Background(), "http:get", agshctx.NewEnvelope("x", "text/plain", "test"), nil); err != nil {
+		t.Errorf("Execute http:get: %v", err)
+	}
+}
+
 func TestRegistryResolveNotFound(t *testing.T) {
 	reg := NewRegistry()
 	_, err := reg.Resolve("nonexistent")
@@ -148,6 +170,9 @@ func TestRegistryMatchGlob(t *testing.T) {
 		{"*", 4},
 		{"fs:list", 1},
 		{"http:*", 0},
+		{"github:*:list", 1},
+		{"*:read", 1},
+		{"*:list", 1},
 	}
 
 	for _, tt := range tests {
@@ -159,3 +184,377 @@ func TestRegistryMatchGlob(t *testing.T) {
 		})
 	}
 }
+
+// schemaCommand is a mockCommand with a custom input schema.
+type schemaCommand struct {
+	mockCommand
+	input Schema
+}
+
+func (s *schemaCommand) InputSchema() Schema { return s.input }
+
+func TestRegistrySearch(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:list", namespace: "fs", desc: "List directory contents"})
+	reg.Register(&mockCommand{name: "fs:read", namespace: "fs", desc: "Read a file"})
+	reg.Register(&mockCommand{name: "github:pr:list", namespace: "github", desc: "List pull requests"})
+	reg.Register(&schemaCommand{
+		mockCommand: mockCommand{name: "http:get", namespace: "http", desc: "Fetch a URL"},
+		input: Schema{Type: "object", Properties: map[string]SchemaField{
+			"headers": {Type: "object"},
+		}},
+	})
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"list", []string{"fs:list", "github:pr:list"}},
+		{"LIST pull", []string{"github:pr:list"}},
+		{"file", []string{"fs:read"}},
+		{"headers", []string{"http:get"}},
+		{"nothing-matches", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			matches := reg.Search(tt.query)
+			var names []string
+			for _, m := range matches {
+				names = append(names, m.Name())
+			}
+			if len(names) != len(tt.expected) {
+				t.Fatalf("query %q: expected %v, got %v", tt.query, tt.expected, names)
+			}
+			for i := range names {
+				if names[i] != tt.expected[i] {
+					t.Errorf("query %q: expected %v, got %v", tt.query, tt.expected, names)
+				}
+			}
+		})
+	}
+
+	if got := len(reg.Search("")); got != 4 {
+		t.Errorf("empty query: expected 4 matches, got %d", got)
+	}
+}
+
+func TestSchemaJSONSchema(t *testing.T) {
+	s := Schema{
+		Type: "object",
+		Properties: map[string]SchemaField{
+			"path": {Type: "string", Description: "File path"},
+		},
+		Required: []string{"path"},
+	}
+	js := s.JSONSchema()
+	if js["type"] != "object" {
+		t.Errorf("expected type object, got %v", js["type"])
+	}
+	props, ok := js["properties"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected properties map, got %T", js["properties"])
+	}
+	path, _ := props["path"].(map[string]any)
+	if path["type"] != "string" || path["description"] != "File path" {
+		t.Errorf("unexpected path property: %v", path)
+	}
+	if req, _ := js["required"].([]string); len(req) != 1 || req[0] != "path" {
+		t.Errorf("unexpected required: %v", js["required"])
+	}
+
+	empty := Schema{}.JSONSchema()
+	if empty["type"] != "object" {
+		t.Errorf("expected empty schema to default to object, got %v", empty["type"])
+	}
+}
+
+func TestToolName(t *testing.T) {
+	if got := ToolName("github:pr:list"); got != "github__pr__list" {
+		t.Errorf("ToolName: got %q", got)
+	}
+	if got := CommandName("github__pr__list"); got != "github:pr:list" {
+		t.Errorf("CommandName: got %q", got)
+	}
+}
+
+// riskyCommand is a mockCommand that classifies its input.
+type riskyCommand struct {
+	mockCommand
+}
+
+func (r *riskyCommand) Risk(payload any) string {
+	if payload == "drop" {
+		return "destructive"
+	}
+	return ""
+}
+
+func TestRegistryCommandRisk(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&riskyCommand{mockCommand{name: "db:query", namespace: "db"}})
+	reg.Register(&mockCommand{name: "fs:list", namespace: "fs"})
+	reg.RegisterAlias(Alias{Name: "sql", Target: "db:query"})
+
+	tests := []struct {
+		name    string
+		payload any
+		want    string
+	}{
+		{"db:query", "drop", "destructive"},
+		{"db:query", "select", ""},
+		{"sql", "drop", "destructive"},
+		{"fs:list", "drop", ""},
+		{"missing", "drop", ""},
+	}
+	for _, tt := range tests {
+		if got := reg.CommandRisk(tt.name, tt.payload); got != tt.want {
+			t.Errorf("CommandRisk(%q, %v) = %q, want %q", tt.name, tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryAliases(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "net:http:get", namespace: "net"})
+	if err := reg.RegisterAlias(Alias{Name: "http:get", Target: "net:http:get", Deprecated: true, Message: "renamed"}); err != nil {
+		t.Fatalf("RegisterAlias: %v", err)
+	}
+
+	var notified []Alias
+	reg.OnDeprecated(func(a Alias) { notified = append(notified, a) })
+
+	cmd, err := reg.Resolve("http:get")
+	if err != nil {
+		t.Fatalf("Resolve alias: %v", err)
+	}
+	if cmd.Name() != "net:http:get" {
+		t.Errorf("alias resolved to %s", cmd.Name())
+	}
+	if len(notified) != 1 || notified[0].Name != "http:get" {
+		t.Errorf("deprecation notifications = %v", notified)
+	}
+
+	reg.Resolve("net:http:get")
+	if len(notified) != 1 {
+		t.Error("canonical name should not notify")
+	}
+
+	if aliases := reg.Aliases("net:http:get"); len(aliases) != 1 || aliases[0].Name != "http:get" {
+		t.Errorf("Aliases = %v", aliases)
+	}
+	if err := reg.RegisterAlias(Alias{Name: "net:http:get", Target: "x"}); err == nil {
+		t.Error("alias clashing with a command should fail")
+	}
+	if err := reg.Register(&mockCommand{name: "http:get", namespace: "http"}); err == nil {
+		t.Error("command clashing with an alias should fail")
+	}
+	if err := reg.RegisterAlias(Alias{Name: "get", Target: "http:get"}); err == nil {
+		t.Error("alias of an alias should fail")
+	}
+}
+
+func TestRegistryNamespaceInfo(t *testing.T) {
+	reg := NewRegistry()
+	reg.RegisterNamespace(Namespace{Name: "github", Description: "GitHub API", Credentials: []string{"GITHUB_TOKEN"}, Risk: "read-only"})
+
+	ns := reg.NamespaceInfo("github")
+	if ns.Description != "GitHub API" || ns.Risk != "read-only" || len(ns.Credentials) != 1 {
+		t.Errorf("NamespaceInfo(github) = %+v", ns)
+	}
+	if ns := reg.NamespaceInfo("fs"); ns.Name != "fs" || ns.Description != "" {
+		t.Errorf("NamespaceInfo(fs) = %+v, want name only", ns)
+	}
+
+	other := NewRegistry()
+	other.ReplaceAll(reg)
+	if other.NamespaceInfo("github").Description != "GitHub API" {
+		t.Error("ReplaceAll should copy namespace metadata")
+	}
+}
+
+type healthFunc func(gocontext.Context) error
+
+func (f healthFunc) HealthCheck(ctx gocontext.Context) error { return f(ctx) }
+
+func TestRegistryCheckHealth(t *testing.T) {
+	reg := NewRegistry()
+	reg.RegisterHealthCheck("http", healthFunc(func(gocontext.Context) error { return nil }))
+	reg.RegisterHealthCheck("github", healthFunc(func(gocontext.Context) error { return errors.New("bad credentials") }))
+
+	results := reg.CheckHealth(gocontext.Background())
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[0].Name != "github" || results[0].OK || results[0].Error != "bad credentials" {
+		t.Errorf("github result = %+v", results[0])
+	}
+	if results[1].Name != "http" || !results[1].OK {
+		t.Errorf("http result = %+v", results[1])
+	}
+}
+
+func TestRegistryInvokeConvertsInput(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&mockCommand{name: "fs:read", namespace: "fs"})
+
+	// JSON text flowing into an object-input command arrives decoded.
+	in := agshctx.NewEnvelope(`{"path": "notes.md"}`, "application/json", "http:get")
+	out, err := reg.Execute(gocontext.Background(), "fs:read", in, nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if m, ok := out.Payload.(map[string]any); !ok || m["path"] != "notes.md" {
+		t.Errorf("payload = %#v", out.Payload)
+	}
+
+	// Plain text is left for the command.
+	in = agshctx.NewEnvelope("notes.md", "text/plain", "repl")
+	if out, _ := reg.Execute(gocontext.Background(), "fs:read", in, nil); out.Payload != "notes.md" {
+		t.Errorf("text payload = %#v", out.Payload)
+	}
+
+	in = agshctx.NewEnvelope(`["a"]`, "application/json", "http:get")
+	_, err = reg.Execute(gocontext.Background(), "fs:read", in, nil)
+	var pe *Error
+	if !errors.As(err, &pe) || pe.Category != CategoryInvalidInput {
+		t.Errorf("array for an object: %v", err)
+	}
+}
+
+func TestRegistryInvokeValidatesInput(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&schemaCommand{
+		mockCommand: mockCommand{name: "fs:read", namespace: "fs"},
+		input: Schema{Type: "object", Properties: map[string]SchemaField{
+			"path":   {Type: "string", Aliases: []string{"file"}},
+			"offset": {Type: "integer"},
+			"lines":  {Type: "boolean"},
+			"paths":  {Type: "array"},
+		}, Required: []string{"path"}},
+	})
+	run := func(args map[string]any) error {
+		_, err := reg.Execute(gocontext.Background(), "fs:read", agshctx.NewEnvelope(args, "application/json", "agent"), nil)
+		return err
+	}
+
+	// Lenient types: numbers and booleans as strings, a string for a list.
+	for _, args := range []map[string]any{
+		{"path": "a.md", "offset": float64(3), "lines": true, "paths": []any{"b"}},
+		{"path": "a.md", "offset": "3", "lines": "false", "paths": "b"},
+		{"file": "a.md", "offset": nil},
+	} {
+		if err := run(args); err != nil {
+			t.Errorf("%v: %v", args, err)
+		}
+	}
+
+	err := run(map[string]any{"offset": 1.5, "lines": "maybe"})
+	var pe *Error
+	if !errors.As(err, &pe) || pe.Code != "input.invalid" || pe.Category != CategoryInvalidInput {
+		t.Fatalf("invalid input: %v", err)
+	}
+	want := []FieldError{
+		{Field: "path", Problem: "missing"},
+		{Field: "lines", Problem: "expected boolean, got string"},
+		{Field: "offset", Problem: "expected integer, got number"},
+	}
+	if !reflect.DeepEqual(pe.Fields, want) {
+		t.Errorf("fields = %+v", pe.Fields)
+	}
+	if pe.Command != "fs:read" || !strings.Contains(pe.Hint, "path (string, required)") {
+		t.Errorf("command = %q, hint = %q", pe.Command, pe.Hint)
+	}
+
+	// Text payloads are left for the command.
+	if _, err := reg.Execute(gocontext.Background(), "fs:read", agshctx.NewEnvelope("a.md", "text/plain", "repl"), nil); err != nil {
+		t.Errorf("text payload: %v", err)
+	}
+}
+
+// outputCommand declares an output schema.
+type outputCommand struct {
+	mockCommand
+	output Schema
+}
+
+func (c *outputCommand) OutputSchema() Schema { return c.output }
+
+func TestCheckOutput(t *testing.T) {
+	cmd := &outputCommand{output: Schema{Type: "object", Properties: map[string]SchemaField{
+		"prs":   {Type: "array"},
+		"count": {Type: "integer"},
+	}, Required: []string{"prs"}}}
+
+	tests := []struct {
+		name    string
+		payload any
+		ct      string
+		want    []FieldError
+	}{
+		{"conforming", map[string]any{"prs": []map[string]any{{"n": 1}}, "count": 1}, "application/json", nil},
+		{"json text", `{"prs": [], "count": 0}`, "application/json", nil},
+		{"plain text", "no pull requests", "text/plain", nil},
+		{"shape change", map[string]any{"count": "1"}, "application/json", []FieldError{
+			{Field: "prs", Problem: "missing"},
+			{Field: "count", Problem: "expected integer, got string"},
+		}},
+		{"fractional count", map[string]any{"prs": []any{}, "count": 1.5}, "application/json", []FieldError{
+			{Field: "count", Problem: "expected integer, got number"},
+		}},
+		{"wrong payload type", []any{1}, "application/json", []FieldError{
+			{Problem: "expected object, got array"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckOutput(cmd, agshctx.NewEnvelope(tt.payload, tt.ct, "test"))
+			if err != nil {
+				t.Fatalf("CheckOutput: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckOutput = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := CheckOutput(cmd, agshctx.NewEnvelope("{", "application/json", "test")); err == nil {
+		t.Error("invalid JSON output accepted")
+	}
+	text := &outputCommand{output: Schema{Type: "string"}}
+	if got, _ := CheckOutput(text, agshctx.NewEnvelope(map[string]any{}, "application/json", "test")); len(got) != 1 {
+		t.Errorf("object for a string output: %+v", got)
+	}
+}
+
+// exampleCommand documents examples.
+type exampleCommand struct {
+	schemaCommand
+	examples []Example
+}
+
+func (c *exampleCommand) Examples() []Example { return c.examples }
+
+func TestCheckExamples(t *testing.T) {
+	cmd := &exampleCommand{schemaCommand: schemaCommand{
+		mockCommand: mockCommand{name: "fs:write", namespace: "fs"},
+		input: Schema{Type: "object", Properties: map[string]SchemaField{
+			"path": {Type: "string"}, "content": {Type: "string"},
+		}, Required: []string{"path", "content"}},
+	}}
+	if Examples(&mockCommand{name: "fs:list"}) != nil || CheckExamples(cmd) != nil {
+		t.Error("commands without examples")
+	}
+
+	cmd.examples = []Example{
+		{Description: "write", Input: map[string]any{"path": "a.md", "content": "x"}, Output: map[string]any{}},
+		{Description: "text", Input: "a.md"},
+	}
+	if err := CheckExamples(cmd); err != nil {
+		t.Errorf("CheckExamples: %v", err)
+	}
+	cmd.examples = append(cmd.examples, Example{Description: "no content", Input: map[string]any{"path": "a.md"}})
+	if err := CheckExamples(cmd); err == nil || !strings.Contains(err.Error(), "example 2 (no content)") {
+		t.Errorf("bad example: %v", err)
+	}
+}
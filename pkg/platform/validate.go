@@ -0,0 +1,232 @@
+package platform
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// FieldError is one problem with a field of a command's input or output.
+type FieldError struct {
+	Field   string `json:"field"`   // empty for the payload as a whole
+	Problem string `json:"problem"` // "missing", or "expected <type>, got <type>"
+}
+
+// validateInput checks an object input against the command's input
+// schema: required fields must be present, and declared fields must have
+// their declared type. Types are checked leniently, as commands accept
+// numbers and booleans given as strings and a single string for a list.
+// Text and other non-object payloads are left to the command.
+func validateInput(cmd PlatformCommand, input agshctx.Envelope) error {
+	args, ok := input.Payload.(map[string]any)
+	if !ok {
+		return nil
+	}
+	schema := cmd.InputSchema()
+	problems := checkFields(schema, args, acceptsType)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(problems))
+	for i, p := range problems {
+		msgs[i] = p.Field + ": " + p.Problem
+	}
+	err := NewError("input.invalid", CategoryInvalidInput, fmt.Sprintf("%s: invalid input: %s", cmd.Name(), strings.Join(msgs, "; "))).
+		WithHint(fmt.Sprintf("expected fields: %s; see commands.describe %s", describeFields(schema), cmd.Name()))
+	err.Command = cmd.Name()
+	err.Fields = problems
+	return err
+}
+
+// CheckOutput checks a command's output against its output schema:
+// the payload must have the declared type, required fields must be
+// present, and declared fields must have exactly their declared JSON type.
+// Text returned where an object is declared (commands return text as their
+// main output) is not checked further. An output that is not JSON at all
+// is an error.
+func CheckOutput(cmd PlatformCommand, output agshctx.Envelope) ([]FieldError, error) {
+	schema := cmd.OutputSchema()
+	typ := schema.Type
+	if typ == "" {
+		typ = "object"
+	}
+	if typ == "string" {
+		if _, ok := output.Payload.(string); ok {
+			return nil, nil
+		}
+	}
+	v, err := output.AsJSON()
+	if err != nil {
+		return nil, err
+	}
+	if _, isText := v.(string); isText && typ == "object" {
+		return nil, nil
+	}
+	if !hasType(v, typ) {
+		return []FieldError{{Problem: fmt.Sprintf("expected %s, got %s", typ, jsonValueType(v))}}, nil
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	return checkFields(schema, obj, hasType), nil
+}
+
+// hasType reports whether v, a decoded JSON value, is of JSON type typ.
+func hasType(v any, typ string) bool {
+	switch typ {
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case "number", "string", "boolean", "array", "object":
+		return jsonValueType(v) == typ
+	}
+	return true
+}
+
+func jsonValueType(v any) string {
+	if v == nil {
+		return "null"
+	}
+	return valueType(v)
+}
+
+// checkFields reports the required fields of s missing from obj and the
+// declared fields whose value is not of their type, as judged by accepts.
+func checkFields(s Schema, obj map[string]any, accepts func(v any, typ string) bool) []FieldError {
+	var problems []FieldError
+	for _, name := range s.Required {
+		if !present(obj, name, s.Properties[name].Aliases) {
+			problems = append(problems, FieldError{Field: name, Problem: "missing"})
+		}
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v, ok := obj[name]
+		if !ok || v == nil {
+			continue
+		}
+		if typ := s.Properties[name].Type; !accepts(v, typ) {
+			problems = append(problems, FieldError{Field: name, Problem: fmt.Sprintf("expected %s, got %s", typ, valueType(v))})
+		}
+	}
+	return problems
+}
+
+// present reports whether name, or one of the fields that may stand in for
+// it, has a value.
+func present(args map[string]any, name string, aliases []string) bool {
+	for _, n := range append([]string{name}, aliases...) {
+		if v, ok := args[n]; ok && v != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsType(v any, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "integer":
+		n, ok := number(v)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := number(v)
+		return ok
+	case "boolean":
+		switch b := v.(type) {
+		case bool:
+			return true
+		case string:
+			_, err := strconv.ParseBool(b)
+			return err == nil
+		}
+		return false
+	case "array":
+		switch v.(type) {
+		case []any, []string, []map[string]any, string:
+			return true
+		}
+		return false
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+// number returns a numeric value, or a string holding one, as a float64.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func valueType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []any, []string, []map[string]any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := number(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// describeFields lists a schema's fields with their types, required ones
+// first, e.g. "path (string, required), limit (integer)".
+func describeFields(s Schema) string {
+	required := make(map[string]bool, len(s.Required))
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s (%s", name, s.Properties[name].Type)
+		if required[name] {
+			parts[i] += ", required"
+		}
+		parts[i] += ")"
+	}
+	return strings.Join(parts, ", ")
+}
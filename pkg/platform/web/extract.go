@@ -0,0 +1,173 @@
+package web
+
+import (
+	gocontext "context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+	httpplatform "github.com/cgast/agsh/pkg/platform/http"
+)
+
+// Namespace describes the web commands.
+var Namespace = platform.Namespace{
+	Name:        "web",
+	Description: "Fetch web pages from allowlisted domains as clean text",
+	Risk:        "read-only",
+}
+
+// maxPageSize bounds how much of a page is read.
+const maxPageSize = 10 * 1024 * 1024
+
+// ExtractCommand implements web:extract — fetches a page and returns its
+// article text, title, headings and links instead of raw HTML.
+type ExtractCommand struct {
+	allowedDomains []string
+	httpClient     *http.Client
+}
+
+// NewExtractCommand creates a new web:extract command restricted to the
+// HTTP allowlist. Redirects to other domains are refused.
+func NewExtractCommand(allowedDomains []string) *ExtractCommand {
+	return &ExtractCommand{
+		allowedDomains: allowedDomains,
+		httpClient: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after 10 redirects")
+				}
+				return httpplatform.CheckAllowedDomain(req.URL.String(), allowedDomains)
+			},
+		},
+	}
+}
+
+func (c *ExtractCommand) Name() string { return "web:extract" }
+func (c *ExtractCommand) Description() string {
+	return "Fetch a web page and extract its readable text, title, headings and links"
+}
+func (c *ExtractCommand) Namespace() string { return "web" }
+
+func (c *ExtractCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"url":       {Type: "string", Description: "Page to fetch"},
+			"max_chars": {Type: "integer", Description: "Truncate the text to this many characters"},
+			"links":     {Type: "boolean", Description: "Include links (default true)"},
+		},
+		Required: []string{"url"},
+	}
+}
+
+func (c *ExtractCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"url":         {Type: "string", Description: "Final URL after redirects"},
+			"title":       {Type: "string", Description: "Page title"},
+			"description": {Type: "string", Description: "Meta description"},
+			"text":        {Type: "string", Description: "Main content as plain text"},
+			"headings":    {Type: "array", Description: "Headings in the main content"},
+			"links":       {Type: "array", Description: "Links in the main content"},
+			"word_count":  {Type: "integer", Description: "Words in text"},
+		},
+	}
+}
+
+func (c *ExtractCommand) RequiredCredentials() []string { return nil }
+
+func (c *ExtractCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	var rawURL string
+	maxChars := 0
+	includeLinks := true
+	switch v := input.Payload.(type) {
+	case string:
+		rawURL = v
+	case map[string]any:
+		rawURL, _ = v["url"].(string)
+		switch n := v["max_chars"].(type) {
+		case int:
+			maxChars = n
+		case float64:
+			maxChars = int(n)
+		}
+		if b, ok := v["links"].(bool); ok {
+			includeLinks = b
+		}
+	}
+	if rawURL == "" {
+		return agshctx.Envelope{}, fmt.Errorf("web:extract: missing 'url'")
+	}
+	if err := httpplatform.CheckAllowedDomain(rawURL, c.allowedDomains); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("web:extract: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("web:extract: create request: %w", err)
+	}
+	if err := agshctx.CheckDomain(ctx, req.URL.Hostname()); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("web:extract: %w", err)
+	}
+	agshctx.RecordDomain(ctx, req.URL.Hostname())
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.8")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("web:extract: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return agshctx.Envelope{}, fmt.Errorf("web:extract: %s returned %d", rawURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("web:extract: read body: %w", err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	var page Page
+	switch {
+	case mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		page = Extract(string(body), resp.Request.URL)
+	case strings.HasPrefix(mediaType, "text/"):
+		page = Page{Text: string(body)}
+	default:
+		return agshctx.Envelope{}, fmt.Errorf("web:extract: %s is %s, not a web page", rawURL, mediaType)
+	}
+
+	text := page.Text
+	truncated := false
+	if maxChars > 0 && len([]rune(text)) > maxChars {
+		text = string([]rune(text)[:maxChars])
+		truncated = true
+	}
+	result := map[string]any{
+		"url":        resp.Request.URL.String(),
+		"title":      page.Title,
+		"text":       text,
+		"headings":   page.Headings,
+		"word_count": len(strings.Fields(page.Text)),
+	}
+	if page.Description != "" {
+		result["description"] = page.Description
+	}
+	if page.Lang != "" {
+		result["lang"] = page.Lang
+	}
+	if includeLinks {
+		result["links"] = page.Links
+	}
+	if truncated {
+		result["truncated"] = true
+	}
+
+	env := agshctx.NewEnvelope(result, "application/json", "web:extract")
+	env.Meta.Tags["url"] = rawURL
+	env.Meta.Tags["status"] = fmt.Sprintf("%d", resp.StatusCode)
+	return env, nil
+}
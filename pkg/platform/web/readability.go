@@ -0,0 +1,416 @@
+package web
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Page is the readable content extracted from an HTML document.
+type Page struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Lang        string    `json:"lang,omitempty"`
+	Text        string    `json:"text"`
+	Headings    []Heading `json:"headings"`
+	Links       []Link    `json:"links"`
+}
+
+// Heading is an h1-h6 in the main content.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+// Link is an outgoing link in the main content, resolved against the page
+// URL.
+type Link struct {
+	Text string `json:"text"`
+	Href string `json:"href"`
+}
+
+// node is an element or text node in the parsed document.
+type node struct {
+	tag      string // "" for text
+	attrs    map[string]string
+	text     string
+	children []*node
+	parent   *node
+}
+
+func (n *node) attr(key string) string { return n.attrs[key] }
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements hold content that is not markup.
+var rawTextElements = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+var attrPattern = regexp.MustCompile(`([^\s=/>"']+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+)))?`)
+
+// parse builds a tolerant element tree: unknown end tags are ignored and an
+// end tag closes any elements left open inside it.
+func parse(doc string) *node {
+	root := &node{tag: "#document"}
+	cur := root
+	appendChild := func(n *node) {
+		n.parent = cur
+		cur.children = append(cur.children, n)
+	}
+
+	for i := 0; i < len(doc); {
+		lt := strings.IndexByte(doc[i:], '<')
+		if lt < 0 {
+			appendChild(&node{text: doc[i:]})
+			break
+		}
+		if lt > 0 {
+			appendChild(&node{text: doc[i : i+lt]})
+		}
+		i += lt
+
+		switch {
+		case strings.HasPrefix(doc[i:], "<!--"):
+			end := strings.Index(doc[i+4:], "-->")
+			if end < 0 {
+				return root
+			}
+			i += 4 + end + 3
+			continue
+		case strings.HasPrefix(doc[i:], "<!"), strings.HasPrefix(doc[i:], "<?"):
+			end := strings.IndexByte(doc[i:], '>')
+			if end < 0 {
+				return root
+			}
+			i += end + 1
+			continue
+		}
+
+		end := strings.IndexByte(doc[i:], '>')
+		if end < 0 {
+			appendChild(&node{text: doc[i:]})
+			break
+		}
+		tagText := doc[i+1 : i+end]
+		i += end + 1
+
+		if strings.HasPrefix(tagText, "/") {
+			name := strings.ToLower(strings.TrimSpace(tagText[1:]))
+			for n := cur; n != root; n = n.parent {
+				if n.tag == name {
+					cur = n.parent
+					break
+				}
+			}
+			continue
+		}
+
+		name, rest, _ := strings.Cut(tagText, " ")
+		if j := strings.IndexAny(name, "\t\n\r/"); j >= 0 {
+			rest = name[j:] + " " + rest
+			name = name[:j]
+		}
+		name = strings.ToLower(name)
+		if name == "" {
+			appendChild(&node{text: "<" + tagText + ">"})
+			continue
+		}
+		el := &node{tag: name, attrs: make(map[string]string)}
+		for _, m := range attrPattern.FindAllStringSubmatch(rest, -1) {
+			el.attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+		}
+		appendChild(el)
+
+		if rawTextElements[name] {
+			closeTag := "</" + name
+			j := strings.Index(strings.ToLower(doc[i:]), closeTag)
+			if j < 0 {
+				j = len(doc) - i
+			}
+			el.children = append(el.children, &node{text: doc[i : i+j], parent: el})
+			i += j
+			if k := strings.IndexByte(doc[i:], '>'); k >= 0 {
+				i += k + 1
+			}
+			continue
+		}
+		if !voidElements[name] && !strings.HasSuffix(tagText, "/") {
+			cur = el
+		}
+	}
+	return root
+}
+
+// skipElements never contain article content.
+var skipElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "svg": true, "nav": true, "header": true,
+	"footer": true, "aside": true, "form": true, "iframe": true, "button": true, "template": true,
+	"select": true, "textarea": true, "head": true,
+}
+
+// unlikelyPattern matches class and id values of page chrome.
+var unlikelyPattern = regexp.MustCompile(`(?i)\b(comments?|sidebar|footer|menu|nav(bar)?|share|social|advert|ads?|promo|cookie|banner|breadcrumbs?|related|popup|modal)\b`)
+
+func skipped(n *node) bool {
+	if skipElements[n.tag] {
+		return true
+	}
+	return unlikelyPattern.MatchString(n.attr("class")) || unlikelyPattern.MatchString(n.attr("id"))
+}
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "li": true, "ul": true,
+	"ol": true, "pre": true, "blockquote": true, "table": true, "tr": true, "figure": true,
+	"figcaption": true, "dl": true, "dd": true, "dt": true, "br": true, "hr": true, "body": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// Extract returns the readable content of an HTML document fetched from
+// pageURL. The main content is the first <article> or <main>, or else the
+// element with the most paragraph text; navigation, headers, footers,
+// scripts and elements whose class or id looks like page chrome are
+// dropped.
+func Extract(doc string, pageURL *url.URL) Page {
+	root := parse(doc)
+	var page Page
+
+	if h := find(root, func(n *node) bool { return n.tag == "html" }); h != nil {
+		page.Lang = h.attr("lang")
+	}
+	if t := find(root, func(n *node) bool { return n.tag == "title" }); t != nil {
+		page.Title = collapse(html.UnescapeString(rawText(t)))
+	}
+	walk(root, func(n *node) bool {
+		if n.tag == "meta" {
+			key := strings.ToLower(n.attr("name") + n.attr("property"))
+			switch {
+			case page.Title == "" && key == "og:title":
+				page.Title = collapse(n.attr("content"))
+			case page.Description == "" && (key == "description" || key == "og:description"):
+				page.Description = collapse(n.attr("content"))
+			}
+		}
+		return true
+	})
+
+	content := mainContent(root)
+	page.Text = render(content)
+	walk(content, func(n *node) bool {
+		if n != content && skipped(n) {
+			return false
+		}
+		if len(n.tag) == 2 && n.tag[0] == 'h' && n.tag[1] >= '1' && n.tag[1] <= '6' {
+			if text := collapse(html.UnescapeString(textOf(n))); text != "" {
+				page.Headings = append(page.Headings, Heading{Level: int(n.tag[1] - '0'), Text: text})
+			}
+			return false
+		}
+		if n.tag == "a" {
+			if link, ok := resolveLink(n, pageURL); ok {
+				page.Links = appendLink(page.Links, link)
+			}
+		}
+		return true
+	})
+	return page
+}
+
+// mainContent picks the node holding the article.
+func mainContent(root *node) *node {
+	for _, tag := range []string{"article", "main"} {
+		if n := find(root, func(n *node) bool { return n.tag == tag }); n != nil {
+			return n
+		}
+	}
+	body := find(root, func(n *node) bool { return n.tag == "body" })
+	if body == nil {
+		body = root
+	}
+
+	// Score each container by the paragraph text directly inside it, and
+	// give half of that to its parent, as readability does.
+	scores := make(map[*node]int)
+	var order []*node // candidates in document order, so ties are stable
+	add := func(n *node, score int) {
+		if _, ok := scores[n]; !ok {
+			order = append(order, n)
+		}
+		scores[n] += score
+	}
+	walk(body, func(n *node) bool {
+		if n != body && skipped(n) {
+			return false
+		}
+		if n.tag == "p" || n.tag == "pre" {
+			length := len(collapse(textOf(n)))
+			if length < 25 {
+				return false
+			}
+			if p := n.parent; p != nil {
+				add(p, length)
+				if p.parent != nil {
+					add(p.parent, length/2)
+				}
+			}
+			return false
+		}
+		return true
+	})
+	best, bestScore := body, 0
+	for _, n := range order {
+		if scores[n] > bestScore {
+			best, bestScore = n, scores[n]
+		}
+	}
+	if bestScore < 200 {
+		return body
+	}
+	return best
+}
+
+// render converts content to plain text: paragraphs separated by blank
+// lines, headings prefixed with '#', list items with '-'.
+func render(content *node) string {
+	var b strings.Builder
+	var line strings.Builder
+	flush := func() {
+		if s := collapse(line.String()); s != "" {
+			if b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			b.WriteString(s)
+		}
+		line.Reset()
+	}
+
+	var visit func(n *node)
+	visit = func(n *node) {
+		if n.tag == "" {
+			line.WriteString(html.UnescapeString(n.text))
+			return
+		}
+		if n != content && skipped(n) {
+			return
+		}
+		switch {
+		case n.tag == "pre":
+			flush()
+			if s := strings.Trim(html.UnescapeString(textOf(n)), "\n"); s != "" {
+				if b.Len() > 0 {
+					b.WriteString("\n\n")
+				}
+				b.WriteString(s)
+			}
+			return
+		case len(n.tag) == 2 && n.tag[0] == 'h' && n.tag[1] >= '1' && n.tag[1] <= '6':
+			flush()
+			line.WriteString(strings.Repeat("#", int(n.tag[1]-'0')) + " ")
+		case n.tag == "li":
+			flush()
+			line.WriteString("- ")
+		case blockElements[n.tag]:
+			flush()
+		case n.tag == "img":
+			if alt := n.attr("alt"); alt != "" {
+				line.WriteString(" " + alt + " ")
+			}
+		case n.tag == "td" || n.tag == "th":
+			line.WriteString(" ")
+		}
+		for _, c := range n.children {
+			visit(c)
+		}
+		if blockElements[n.tag] || n.tag == "li" {
+			flush()
+		}
+	}
+	visit(content)
+	flush()
+	return b.String()
+}
+
+// resolveLink returns an absolute http(s) link for an <a> element.
+func resolveLink(n *node, base *url.URL) (Link, bool) {
+	href := strings.TrimSpace(n.attr("href"))
+	if href == "" || strings.HasPrefix(href, "#") {
+		return Link{}, false
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return Link{}, false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Link{}, false
+	}
+	u.Fragment = ""
+	return Link{Text: collapse(html.UnescapeString(textOf(n))), Href: u.String()}, true
+}
+
+func appendLink(links []Link, l Link) []Link {
+	for _, existing := range links {
+		if existing.Href == l.Href {
+			return links
+		}
+	}
+	return append(links, l)
+}
+
+// walk visits n and its descendants depth-first; fn returns false to skip
+// a node's children.
+func walk(n *node, fn func(*node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.children {
+		walk(c, fn)
+	}
+}
+
+func find(n *node, match func(*node) bool) *node {
+	var found *node
+	walk(n, func(c *node) bool {
+		if found != nil {
+			return false
+		}
+		if match(c) {
+			found = c
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// textOf concatenates the text below n, skipping non-content elements.
+func textOf(n *node) string {
+	var b strings.Builder
+	walk(n, func(c *node) bool {
+		if c.tag == "" {
+			b.WriteString(c.text)
+		} else if c != n && skipElements[c.tag] {
+			return false
+		} else if blockElements[c.tag] {
+			b.WriteByte(' ')
+		}
+		return true
+	})
+	return b.String()
+}
+
+// rawText returns the unparsed content of a raw text element.
+func rawText(n *node) string {
+	if len(n.children) == 0 {
+		return ""
+	}
+	return n.children[0].text
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
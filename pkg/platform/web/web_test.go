@@ -0,0 +1,131 @@
+package web
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <title>Release notes &amp; changes</title>
+  <meta name="description" content="What changed in 2.0">
+  <style>body { color: red }</style>
+  <script>var x = "<p>not content</p>";</script>
+</head>
+<body>
+  <header><nav><a href="/">Home</a><a href="/blog">Blog</a></nav></header>
+  <div class="sidebar"><p>Subscribe to our newsletter for weekly updates and more.</p></div>
+  <div id="content">
+    <h1>Version 2.0</h1>
+    <p>This release rewrites the planner and adds <a href="/docs/dag">DAG pipelines</a>, which
+       lets independent steps run in parallel.</p>
+    <h2>Breaking changes</h2>
+    <ul><li>Specs need <code>version: 2</code></li><li>The <b>--yes</b> flag is gone</li></ul>
+    <pre>agsh run spec.yaml
+  --approve=plan</pre>
+    <p>See the <a href="https://example.com/migrate#top">migration guide</a> for details on upgrading.</p>
+    <div class="share-buttons"><a href="https://social.example/share">Share</a></div>
+  </div>
+  <footer><p>Copyright 2026 Example Corp. All rights reserved worldwide.</p></footer>
+</body>
+</html>`
+
+func TestExtract(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/release")
+	page := Extract(articlePage, base)
+
+	if page.Title != "Release notes & changes" || page.Description != "What changed in 2.0" || page.Lang != "en" {
+		t.Errorf("metadata: %+v", page)
+	}
+	for _, want := range []string{
+		"# Version 2.0",
+		"adds DAG pipelines, which lets independent",
+		"## Breaking changes",
+		"- Specs need version: 2",
+		"agsh run spec.yaml\n  --approve=plan",
+	} {
+		if !strings.Contains(page.Text, want) {
+			t.Errorf("text missing %q:\n%s", want, page.Text)
+		}
+	}
+	for _, unwanted := range []string{"Home", "newsletter", "Copyright", "not content", "color: red", "Share"} {
+		if strings.Contains(page.Text, unwanted) {
+			t.Errorf("text contains page chrome %q:\n%s", unwanted, page.Text)
+		}
+	}
+
+	if len(page.Headings) != 2 || page.Headings[1] != (Heading{Level: 2, Text: "Breaking changes"}) {
+		t.Errorf("headings = %+v", page.Headings)
+	}
+	wantLinks := []Link{
+		{Text: "DAG pipelines", Href: "https://example.com/docs/dag"},
+		{Text: "migration guide", Href: "https://example.com/migrate"},
+	}
+	if len(page.Links) != len(wantLinks) {
+		t.Fatalf("links = %+v", page.Links)
+	}
+	for i, l := range wantLinks {
+		if page.Links[i] != l {
+			t.Errorf("link %d = %+v, want %+v", i, page.Links[i], l)
+		}
+	}
+}
+
+func TestExtractPrefersArticle(t *testing.T) {
+	doc := `<body><div><p>Short teaser text that is long enough to score.</p></div>
+<article><h1>Title</h1><p>Body</p></article></body>`
+	page := Extract(doc, nil)
+	if page.Text != "# Title\n\nBody" {
+		t.Errorf("text = %q", page.Text)
+	}
+}
+
+func TestExtractCommand(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		case "/away":
+			http.Redirect(w, r, "https://elsewhere.example/", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(articlePage))
+		}
+	}))
+	defer srv.Close()
+	host, _ := url.Parse(srv.URL)
+	cmd := NewExtractCommand([]string{host.Hostname()})
+	run := func(payload any) (agshctx.Envelope, error) {
+		return cmd.Execute(gocontext.Background(), agshctx.NewEnvelope(payload, "text/plain", "test"), nil)
+	}
+
+	env, err := run(map[string]any{"url": srv.URL + "/release", "max_chars": 20, "links": false})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	result := env.Payload.(map[string]any)
+	if result["title"] != "Release notes & changes" || len([]rune(result["text"].(string))) != 20 || result["truncated"] != true {
+		t.Errorf("unexpected result: %v", result)
+	}
+	if _, ok := result["links"]; ok {
+		t.Error("links should be omitted when links: false")
+	}
+
+	if _, err := run(srv.URL + "/image.png"); err == nil {
+		t.Error("expected error for non-text content")
+	}
+	if _, err := run(srv.URL + "/away"); err == nil {
+		t.Error("expected error for redirect off the allowlist")
+	}
+	if _, err := run("https://not-allowed.example/"); err == nil {
+		t.Error("expected error for domain off the allowlist")
+	}
+}
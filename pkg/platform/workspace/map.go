@@ -0,0 +1,349 @@
+// Package workspace provides commands that describe the workspace as a
+// whole, so an agent can orient itself before reading individual files.
+package workspace
+
+import (
+	"bufio"
+	gocontext "context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+	"github.com/cgast/agsh/pkg/platform"
+)
+
+// Namespace describes the workspace commands.
+var Namespace = platform.Namespace{
+	Name:        "workspace",
+	Description: "Summarize the workspace: its tree, languages and documents",
+	Risk:        "read-only",
+}
+
+// Bounds of a map unless the request sets them.
+const (
+	defaultMapDepth   = 3
+	defaultMapEntries = 300
+	maxHeadingFiles   = 50
+	maxHeadings       = 20       // per file
+	headingScanBytes  = 64 << 10 // of each markdown file
+)
+
+// defaultExcludes are directory names a map skips unless exclude is given.
+var defaultExcludes = []string{".git", ".agsh", "node_modules", "vendor", "__pycache__", ".venv"}
+
+// MapCommand implements workspace:map — a compact summary of a directory
+// tree, meant as the first thing an agent reads about a project.
+type MapCommand struct {
+	Sandbox *sandbox.Sandbox
+}
+
+func (c *MapCommand) Name() string { return "workspace:map" }
+func (c *MapCommand) Description() string {
+	return "Summarize a directory tree: layout, sizes, languages and markdown headings"
+}
+func (c *MapCommand) Namespace() string { return "workspace" }
+
+func (c *MapCommand) InputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"path":        {Type: "string", Description: "Directory to map (default: .)"},
+			"max_depth":   {Type: "integer", Description: "Levels of the tree to list; deeper directories are summarized (default: 3)"},
+			"max_entries": {Type: "integer", Description: "Maximum tree entries to list (default: 300)"},
+			"exclude":     {Type: "array", Description: "Directory names to skip (default: .git, .agsh, node_modules, vendor, __pycache__, .venv)"},
+		},
+	}
+}
+
+func (c *MapCommand) OutputSchema() platform.Schema {
+	return platform.Schema{
+		Type: "object",
+		Properties: map[string]platform.SchemaField{
+			"root":      {Type: "string", Description: "Absolute path of the mapped directory"},
+			"files":     {Type: "integer", Description: "Files in the tree"},
+			"dirs":      {Type: "integer", Description: "Directories in the tree"},
+			"size":      {Type: "integer", Description: "Total bytes of the files"},
+			"languages": {Type: "array", Description: "Languages by bytes, with their file counts"},
+			"tree":      {Type: "array", Description: "Entries up to max_depth, by relative path; directories carry their file count and size"},
+			"headings":  {Type: "object", Description: "Level 1 and 2 headings of markdown files, by relative path"},
+			"truncated": {Type: "boolean", Description: "Set when max_entries cut the tree"},
+		},
+	}
+}
+
+func (c *MapCommand) Examples() []platform.Example {
+	return []platform.Example{
+		{
+			Description: "Map the current directory",
+			Input:       map[string]any{},
+			Output: map[string]any{
+				"root": "/work", "files": 42, "dirs": 7, "size": 183204,
+				"languages": []any{map[string]any{"language": "Go", "files": 30, "bytes": 150112}},
+				"tree": []any{
+					map[string]any{"path": "README.md", "size": 4210, "language": "Markdown"},
+					map[string]any{"path": "cmd", "dir": true, "files": 3, "size": 20480},
+				},
+				"headings": map[string]any{"README.md": []any{"# agsh", "## Install"}},
+			},
+		},
+		{Description: "Only the top level", Input: map[string]any{"path": "src", "max_depth": 1}},
+	}
+}
+
+func (c *MapCommand) RequiredCredentials() []string { return nil }
+
+// Map is the summary produced by workspace:map.
+type Map struct {
+	Root      string              `json:"root"`
+	Files     int                 `json:"files"`
+	Dirs      int                 `json:"dirs"`
+	Size      int64               `json:"size"`
+	Languages []LanguageStat      `json:"languages"`
+	Tree      []MapEntry          `json:"tree"`
+	Headings  map[string][]string `json:"headings,omitempty"`
+	Truncated bool                `json:"truncated,omitempty"`
+}
+
+// MapEntry is a file or directory of the tree. Paths are relative to the
+// root and use forward slashes.
+type MapEntry struct {
+	Path     string `json:"path"`
+	Dir      bool   `json:"dir,omitempty"`
+	Files    int    `json:"files,omitempty"` // files below a directory
+	Size     int64  `json:"size"`            // of a file, or of the files below a directory
+	Language string `json:"language,omitempty"`
+}
+
+// LanguageStat counts the files of one language.
+type LanguageStat struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// Execute walks the whole tree for the totals, and lists it down to
+// max_depth. Paths the sandbox denies and excluded directories are left
+// out entirely.
+func (c *MapCommand) Execute(ctx gocontext.Context, input agshctx.Envelope, _ agshctx.ContextStore) (agshctx.Envelope, error) {
+	sb := sandbox.ForRun(ctx, c.Sandbox)
+	args, _ := input.Payload.(map[string]any)
+	dir := "."
+	if s, ok := input.Payload.(string); ok && s != "" {
+		dir = s
+	}
+	if s, _ := args["path"].(string); s != "" {
+		dir = s
+	}
+	maxDepth, err := intArg(args, "max_depth", defaultMapDepth)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("workspace:map: %w", err)
+	}
+	maxEntries, err := intArg(args, "max_entries", defaultMapEntries)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("workspace:map: %w", err)
+	}
+	excludes := defaultExcludes
+	if v, ok := args["exclude"].([]any); ok {
+		excludes = nil
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				excludes = append(excludes, s)
+			}
+		}
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("workspace:map: resolve path: %w", err)
+	}
+	if sb != nil {
+		if err := sb.CheckPath(root); err != nil {
+			return agshctx.Envelope{}, fmt.Errorf("workspace:map: %w", err)
+		}
+	}
+	if info, err := os.Stat(root); err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("workspace:map: %w", err)
+	} else if !info.IsDir() {
+		return agshctx.Envelope{}, fmt.Errorf("workspace:map: %s is not a directory", root)
+	}
+
+	m := Map{Root: root, Languages: []LanguageStat{}, Tree: []MapEntry{}}
+	dirs := make(map[string]int) // indexes of listed directories in m.Tree
+	langs := make(map[string]*LanguageStat)
+	var markdown []string
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if path == root {
+			return err
+		}
+		if err != nil {
+			return nil // unreadable subdirectory
+		}
+		if sb != nil && sb.CheckPath(path) != nil {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		depth := strings.Count(rel, "/") + 1
+
+		if entry.IsDir() {
+			if slices.Contains(excludes, entry.Name()) {
+				return filepath.SkipDir
+			}
+			m.Dirs++
+			if depth <= maxDepth {
+				m.Tree = append(m.Tree, MapEntry{Path: rel, Dir: true})
+				dirs[rel] = len(m.Tree) - 1
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		size := info.Size()
+		m.Files++
+		m.Size += size
+		lang := language(entry.Name())
+		if lang != "" {
+			ls := langs[lang]
+			if ls == nil {
+				ls = &LanguageStat{Language: lang}
+				langs[lang] = ls
+			}
+			ls.Files++
+			ls.Bytes += size
+		}
+		if lang == "Markdown" {
+			markdown = append(markdown, rel)
+		}
+		if depth <= maxDepth {
+			m.Tree = append(m.Tree, MapEntry{Path: rel, Size: size, Language: lang})
+		}
+		// Count the file in each listed directory above it.
+		for p := rel; strings.Contains(p, "/"); {
+			p = p[:strings.LastIndex(p, "/")]
+			if i, ok := dirs[p]; ok {
+				m.Tree[i].Files++
+				m.Tree[i].Size += size
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("workspace:map: %w", err)
+	}
+	if len(m.Tree) > maxEntries {
+		m.Tree = m.Tree[:maxEntries]
+		m.Truncated = true
+	}
+
+	for _, ls := range langs {
+		m.Languages = append(m.Languages, *ls)
+	}
+	sort.Slice(m.Languages, func(i, j int) bool {
+		a, b := m.Languages[i], m.Languages[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Language < b.Language
+	})
+
+	sort.Strings(markdown)
+	for _, rel := range markdown[:min(len(markdown), maxHeadingFiles)] {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if h := headings(path); len(h) > 0 {
+			if m.Headings == nil {
+				m.Headings = make(map[string][]string)
+			}
+			m.Headings[rel] = h
+		}
+	}
+
+	env := agshctx.NewEnvelope(m, "application/json", "workspace:map")
+	env.Meta.Tags["root"] = root
+	env.Meta.Tags["files"] = fmt.Sprintf("%d", m.Files)
+	return env, nil
+}
+
+// headings returns the level 1 and 2 headings near the start of a
+// markdown file, skipping fenced code.
+func headings(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []string
+	fenced := false
+	scanner := bufio.NewScanner(io.LimitReader(f, headingScanBytes))
+	for scanner.Scan() && len(out) < maxHeadings {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case strings.HasPrefix(line, "```"), strings.HasPrefix(line, "~~~"):
+			fenced = !fenced
+		case !fenced && (strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "## ")):
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// languages maps file extensions, and a few file names, to languages.
+var languages = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".mjs": "JavaScript", ".cjs": "JavaScript",
+	".jsx": "JavaScript", ".ts": "TypeScript", ".tsx": "TypeScript", ".rs": "Rust", ".java": "Java",
+	".kt": "Kotlin", ".swift": "Swift", ".rb": "Ruby", ".php": "PHP", ".c": "C", ".h": "C",
+	".cc": "C++", ".cpp": "C++", ".hpp": "C++", ".cs": "C#", ".scala": "Scala", ".sh": "Shell",
+	".bash": "Shell", ".ps1": "PowerShell", ".lua": "Lua", ".r": "R", ".sql": "SQL",
+	".html": "HTML", ".htm": "HTML", ".css": "CSS", ".scss": "CSS", ".vue": "Vue", ".svelte": "Svelte",
+	".md": "Markdown", ".markdown": "Markdown", ".rst": "reStructuredText", ".txt": "Text",
+	".json": "JSON", ".yaml": "YAML", ".yml": "YAML", ".toml": "TOML", ".xml": "XML",
+	".csv": "CSV", ".proto": "Protocol Buffers", ".tf": "Terraform",
+	"Dockerfile": "Dockerfile", "Makefile": "Makefile",
+}
+
+// language names the language of a file, or "" if it is not known.
+func language(name string) string {
+	if lang, ok := languages[name]; ok {
+		return lang
+	}
+	return languages[strings.ToLower(filepath.Ext(name))]
+}
+
+// intArg reads an optional positive integer argument, which JSON delivers
+// as a float64, or returns def.
+func intArg(args map[string]any, key string, def int) (int, error) {
+	v, ok := args[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	var n int
+	switch x := v.(type) {
+	case int:
+		n = x
+	case float64:
+		if x != float64(int(x)) {
+			return 0, fmt.Errorf("'%s' must be an integer, got %v", key, x)
+		}
+		n = int(x)
+	default:
+		return 0, fmt.Errorf("'%s' must be an integer, got %T", key, v)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("'%s' must be positive", key)
+	}
+	return n, nil
+}
@@ -0,0 +1,93 @@
+package workspace
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "README.md"), "# Project\n\n```\n# not a heading\n```\n## Usage\n### Details\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(dir, "cmd", "tool", "tool.go"), "package tool\n")
+	writeFile(t, filepath.Join(dir, "cmd", "tool", "deep", "x.py"), "x = 1\n")
+	writeFile(t, filepath.Join(dir, ".git", "HEAD"), "ref\n")
+
+	run := func(args map[string]any) Map {
+		t.Helper()
+		args["path"] = dir
+		env, err := (&MapCommand{}).Execute(gocontext.Background(), agshctx.NewEnvelope(args, "application/json", "test"), nil)
+		if err != nil {
+			t.Fatalf("Execute(%v): %v", args, err)
+		}
+		return env.Payload.(Map)
+	}
+
+	m := run(map[string]any{"max_depth": 2})
+	if m.Files != 4 || m.Dirs != 3 {
+		t.Errorf("files, dirs = %d, %d; want 4, 3", m.Files, m.Dirs)
+	}
+	var paths []string
+	for _, e := range m.Tree {
+		paths = append(paths, e.Path)
+	}
+	want := []string{"README.md", "cmd", "cmd/tool", "main.go"}
+	if len(paths) != len(want) {
+		t.Fatalf("tree = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("tree = %v, want %v", paths, want)
+		}
+	}
+	if cmd := m.Tree[1]; !cmd.Dir || cmd.Files != 2 || cmd.Size != int64(len("package tool\n")+len("x = 1\n")) {
+		t.Errorf("cmd = %+v", cmd)
+	}
+	if m.Languages[0].Language != "Markdown" || len(m.Languages) != 3 {
+		t.Errorf("languages = %+v", m.Languages)
+	}
+	if h := m.Headings["README.md"]; len(h) != 2 || h[0] != "# Project" || h[1] != "## Usage" {
+		t.Errorf("headings = %q", h)
+	}
+
+	if m := run(map[string]any{"max_entries": 1}); len(m.Tree) != 1 || !m.Truncated {
+		t.Errorf("max_entries: %d entries, truncated %v", len(m.Tree), m.Truncated)
+	}
+	if m := run(map[string]any{"exclude": []any{}}); m.Files != 5 {
+		t.Errorf("without excludes: %d files, want 5", m.Files)
+	}
+}
+
+func TestMapCommandSandbox(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ok.txt"), "ok")
+	writeFile(t, filepath.Join(dir, "secret", "key.txt"), "k")
+
+	sb, _ := sandbox.New(sandbox.Config{AllowedPaths: []string{dir}, DeniedPaths: []string{filepath.Join(dir, "secret")}})
+	env, err := (&MapCommand{Sandbox: sb}).Execute(gocontext.Background(), agshctx.NewEnvelope(dir, "text/plain", "test"), nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if m := env.Payload.(Map); m.Files != 1 || len(m.Tree) != 1 {
+		t.Errorf("map = %+v, want only ok.txt", m)
+	}
+
+	if _, err := (&MapCommand{Sandbox: sb}).Execute(gocontext.Background(), agshctx.NewEnvelope(t.TempDir(), "text/plain", "test"), nil); err == nil {
+		t.Error("mapping outside the sandbox should fail")
+	}
+}
@@ -1,6 +1,8 @@
 package protocol
 
 import (
+	"bytes"
+	gocontext "context"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -9,21 +11,39 @@ import (
 // HandlerFunc processes a JSON-RPC request and returns a result or error.
 type HandlerFunc func(params json.RawMessage) (any, *Error)
 
+// ContextHandlerFunc is a HandlerFunc that also receives the request's
+// context, which is cancelled by $/cancelRequest.
+type ContextHandlerFunc func(ctx gocontext.Context, params json.RawMessage) (any, *Error)
+
 // Handler routes JSON-RPC methods to registered handler functions.
 type Handler struct {
 	mu       sync.RWMutex
-	handlers map[string]HandlerFunc
+	handlers map[string]ContextHandlerFunc
+
+	// In-flight requests started by Dispatch, keyed by request id.
+	inflightMu sync.Mutex
+	inflight   map[string]gocontext.CancelFunc
+	wg         sync.WaitGroup
 }
 
 // NewHandler creates an empty method handler.
 func NewHandler() *Handler {
 	return &Handler{
-		handlers: make(map[string]HandlerFunc),
+		handlers: make(map[string]ContextHandlerFunc),
+		inflight: make(map[string]gocontext.CancelFunc),
 	}
 }
 
 // Register adds a handler for a method. Overwrites any existing handler.
 func (h *Handler) Register(method string, fn HandlerFunc) {
+	h.RegisterContext(method, func(_ gocontext.Context, params json.RawMessage) (any, *Error) {
+		return fn(params)
+	})
+}
+
+// RegisterContext adds a context-aware handler for a method. Overwrites any
+// existing handler.
+func (h *Handler) RegisterContext(method string, fn ContextHandlerFunc) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.handlers[method] = fn
@@ -31,10 +51,21 @@ func (h *Handler) Register(method string, fn HandlerFunc) {
 
 // Handle processes a single JSON-RPC request and returns a response.
 func (h *Handler) Handle(req Request) Response {
+	return h.HandleContext(gocontext.Background(), req)
+}
+
+// HandleContext processes a single JSON-RPC request under ctx. If ctx is
+// cancelled and the handler fails, the error is reported as
+// CodeRequestCancelled.
+func (h *Handler) HandleContext(ctx gocontext.Context, req Request) Response {
 	if req.JSONRPC != "2.0" {
 		return NewErrorResponse(req.ID, CodeInvalidRequest, "invalid jsonrpc version", nil)
 	}
 
+	if req.Method == MethodCancelRequest {
+		return h.handleCancel(req)
+	}
+
 	h.mu.RLock()
 	fn, ok := h.handlers[req.Method]
 	h.mu.RUnlock()
@@ -44,8 +75,11 @@ func (h *Handler) Handle(req Request) Response {
 			fmt.Sprintf("method not found: %s", req.Method), nil)
 	}
 
-	result, rpcErr := fn(req.Params)
+	result, rpcErr := fn(ctx, req.Params)
 	if rpcErr != nil {
+		if ctx.Err() != nil {
+			rpcErr = &Error{Code: CodeRequestCancelled, Message: "request cancelled: " + rpcErr.Message}
+		}
 		return Response{
 			JSONRPC: "2.0",
 			ID:      req.ID,
@@ -56,7 +90,85 @@ func (h *Handler) Handle(req Request) Response {
 	return NewResponse(req.ID, result)
 }
 
-// HandleRaw parses raw JSON bytes as a request, processes it, and returns a response.
+// handleCancel implements $/cancelRequest for requests started by Dispatch.
+func (h *Handler) handleCancel(req Request) Response {
+	p, rpcErr := ParseParams[CancelParams](req.Params)
+	if rpcErr != nil {
+		return Response{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+
+	h.inflightMu.Lock()
+	cancel, ok := h.inflight[idKey(p.ID)]
+	h.inflightMu.Unlock()
+	if ok {
+		cancel()
+	}
+	return NewResponse(req.ID, map[string]any{"cancelled": ok})
+}
+
+// Dispatch processes a raw JSON-RPC message asynchronously. Each request in
+// the message runs on its own goroutine with a cancellable context, so a slow
+// request does not block later ones. reply is called with the same values
+// HandleMessage would return, possibly from several goroutines and in any
+// order across messages; a batch is answered once all its requests finish.
+// $/cancelRequest is answered inline so it is never queued behind the request
+// it cancels.
+func (h *Handler) Dispatch(data []byte, reply func(any)) {
+	data = bytes.TrimSpace(data)
+
+	var probe struct {
+		Method string `json:"method"`
+	}
+	if len(data) > 0 && data[0] == '{' && json.Unmarshal(data, &probe) == nil && probe.Method == MethodCancelRequest {
+		if resp := h.HandleMessage(data); resp != nil {
+			reply(resp)
+		}
+		return
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if resp := h.handleMessage(data, true); resp != nil {
+			reply(resp)
+		}
+	}()
+}
+
+// Wait blocks until every request started by Dispatch has completed.
+func (h *Handler) Wait() {
+	h.wg.Wait()
+}
+
+// track registers a cancel function for an in-flight request id and returns
+// a function that removes it. It reports false, registering nothing, when a
+// request with the same id is still in flight.
+func (h *Handler) track(id any, cancel gocontext.CancelFunc) (func(), bool) {
+	key := idKey(id)
+	h.inflightMu.Lock()
+	if _, dup := h.inflight[key]; dup {
+		h.inflightMu.Unlock()
+		return nil, false
+	}
+	h.inflight[key] = cancel
+	h.inflightMu.Unlock()
+	return func() {
+		h.inflightMu.Lock()
+		delete(h.inflight, key)
+		h.inflightMu.Unlock()
+		cancel()
+	}, true
+}
+
+// idKey normalizes a request id for use as a map key. Numeric ids decode as
+// float64, so 1 and 1.0 map to the same key.
+func idKey(id any) string {
+	data, _ := json.Marshal(id)
+	return string(data)
+}
+
+// HandleRaw parses raw JSON bytes as a single request, processes it, and returns a response.
+// Use HandleMessage for batch and notification semantics.
 func (h *Handler) HandleRaw(data []byte) Response {
 	var req Request
 	if err := json.Unmarshal(data, &req); err != nil {
@@ -65,6 +177,106 @@ func (h *Handler) HandleRaw(data []byte) Response {
 	return h.Handle(req)
 }
 
+// HandleMessage processes a raw JSON-RPC message, which may be a single
+// request or a batch array. It returns a Response, a []Response for batches
+// (in request order), or nil when no reply is due because every request was
+// a notification (a request without an "id" member).
+func (h *Handler) HandleMessage(data []byte) any {
+	return h.handleMessage(data, false)
+}
+
+// handleMessage implements HandleMessage. When concurrent is set, batch
+// entries run in parallel and requests are registered for cancellation.
+func (h *Handler) handleMessage(data []byte, concurrent bool) any {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return h.handleBatch(trimmed, concurrent)
+	}
+
+	resp, notify := h.handleOne(trimmed, concurrent)
+	if notify {
+		return nil
+	}
+	return resp
+}
+
+// handleBatch processes a JSON-RPC batch. An empty batch is a single invalid
+// request error; otherwise only non-notification requests yield responses.
+func (h *Handler) handleBatch(data []byte, concurrent bool) any {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return NewErrorResponse(nil, CodeParseError, "parse error: "+err.Error(), nil)
+	}
+	if len(items) == 0 {
+		return NewErrorResponse(nil, CodeInvalidRequest, "empty batch", nil)
+	}
+
+	results := make([]Response, len(items))
+	notifies := make([]bool, len(items))
+	if concurrent {
+		var wg sync.WaitGroup
+		for i, item := range items {
+			wg.Add(1)
+			go func(i int, item json.RawMessage) {
+				defer wg.Done()
+				results[i], notifies[i] = h.handleOne(item, true)
+			}(i, item)
+		}
+		wg.Wait()
+	} else {
+		for i, item := range items {
+			results[i], notifies[i] = h.handleOne(item, false)
+		}
+	}
+
+	var responses []Response
+	for i, resp := range results {
+		if !notifies[i] {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+// handleOne processes a single request object. It reports notify=true when
+// the request carries no "id" member and so must not be answered.
+func (h *Handler) handleOne(data []byte, concurrent bool) (Response, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		if json.Valid(data) {
+			return NewErrorResponse(nil, CodeInvalidRequest, "invalid request: expected object", nil), false
+		}
+		return NewErrorResponse(nil, CodeParseError, "parse error: "+err.Error(), nil), false
+	}
+	_, hasID := fields["id"]
+
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		if !hasID {
+			return Response{}, true
+		}
+		return NewErrorResponse(nil, CodeInvalidRequest, "invalid request: "+err.Error(), nil), false
+	}
+
+	ctx := gocontext.Background()
+	if concurrent && hasID && req.ID != nil {
+		var cancel gocontext.CancelFunc
+		ctx, cancel = gocontext.WithCancel(ctx)
+		release, ok := h.track(req.ID, cancel)
+		if !ok {
+			cancel()
+			return NewErrorResponse(req.ID, CodeInvalidRequest, fmt.Sprintf("request id %s is already in flight", idKey(req.ID)), nil), false
+		}
+		defer release()
+	}
+
+	resp := h.HandleContext(ctx, req)
+	return resp, !hasID
+}
+
 // Methods returns all registered method names.
 func (h *Handler) Methods() []string {
 	h.mu.RLock()
@@ -1,8 +1,10 @@
 package protocol
 
 import (
+	gocontext "context"
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestHandlerMethodNotFound(t *testing.T) {
@@ -106,6 +108,185 @@ func TestHandleRawParseError(t *testing.T) {
 	}
 }
 
+func TestHandleMessageNotification(t *testing.T) {
+	h := NewHandler()
+	called := false
+	h.Register("notify", func(params json.RawMessage) (any, *Error) {
+		called = true
+		return "ignored", nil
+	})
+
+	resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"notify"}`))
+	if resp != nil {
+		t.Errorf("expected no response for notification, got %v", resp)
+	}
+	if !called {
+		t.Error("notification handler was not called")
+	}
+}
+
+func TestHandleMessageBatch(t *testing.T) {
+	h := NewHandler()
+	h.Register("ping", func(params json.RawMessage) (any, *Error) {
+		return "pong", nil
+	})
+
+	raw := []byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"ping"},
+		{"jsonrpc":"2.0","method":"ping"},
+		{"jsonrpc":"2.0","id":"b","method":"missing"},
+		42
+	]`)
+	resp := h.HandleMessage(raw)
+
+	responses, ok := resp.([]Response)
+	if !ok {
+		t.Fatalf("expected []Response, got %T", resp)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("len(responses) = %d, want 3", len(responses))
+	}
+	if responses[0].Result != "pong" {
+		t.Errorf("responses[0].Result = %v", responses[0].Result)
+	}
+	if responses[1].ID != "b" || responses[1].Error == nil || responses[1].Error.Code != CodeMethodNotFound {
+		t.Errorf("responses[1] = %+v", responses[1])
+	}
+	if responses[2].Error == nil || responses[2].Error.Code != CodeInvalidRequest {
+		t.Errorf("responses[2] = %+v", responses[2])
+	}
+}
+
+func TestHandleMessageBatchEdgeCases(t *testing.T) {
+	h := NewHandler()
+	h.Register("ping", func(params json.RawMessage) (any, *Error) { return "pong", nil })
+
+	resp, ok := h.HandleMessage([]byte(`[]`)).(Response)
+	if !ok || resp.Error == nil || resp.Error.Code != CodeInvalidRequest {
+		t.Errorf("empty batch: got %+v", resp)
+	}
+
+	if got := h.HandleMessage([]byte(`[{"jsonrpc":"2.0","method":"ping"}]`)); got != nil {
+		t.Errorf("all-notification batch: expected nil, got %v", got)
+	}
+
+	resp, ok = h.HandleMessage([]byte(`[{"jsonrpc":"2.0"`)).(Response)
+	if !ok || resp.Error == nil || resp.Error.Code != CodeParseError {
+		t.Errorf("malformed batch: got %+v", resp)
+	}
+}
+
+func TestDispatchConcurrent(t *testing.T) {
+	h := NewHandler()
+	release := make(chan struct{})
+	h.Register("slow", func(params json.RawMessage) (any, *Error) {
+		<-release
+		return "slow", nil
+	})
+	h.Register("fast", func(params json.RawMessage) (any, *Error) { return "fast", nil })
+
+	replies := make(chan any, 2)
+	reply := func(v any) { replies <- v }
+
+	h.Dispatch([]byte(`{"jsonrpc":"2.0","id":1,"method":"slow"}`), reply)
+	h.Dispatch([]byte(`{"jsonrpc":"2.0","id":2,"method":"fast"}`), reply)
+
+	select {
+	case v := <-replies:
+		if resp := v.(Response); resp.Result != "fast" {
+			t.Errorf("expected fast response first, got %+v", resp)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("fast request was blocked by slow request")
+	}
+
+	close(release)
+	h.Wait()
+	if resp := (<-replies).(Response); resp.Result != "slow" {
+		t.Errorf("expected slow response, got %+v", resp)
+	}
+}
+
+func TestDispatchCancel(t *testing.T) {
+	h := NewHandler()
+	started := make(chan struct{})
+	h.RegisterContext("block", func(ctx gocontext.Context, params json.RawMessage) (any, *Error) {
+		close(started)
+		<-ctx.Done()
+		return nil, &Error{Code: CodeCommandFailed, Message: ctx.Err().Error()}
+	})
+
+	replies := make(chan any, 2)
+	reply := func(v any) { replies <- v }
+
+	h.Dispatch([]byte(`{"jsonrpc":"2.0","id":"req-1","method":"block"}`), reply)
+	<-started
+
+	// Sent as a request so the cancellation itself is acknowledged.
+	h.Dispatch([]byte(`{"jsonrpc":"2.0","id":9,"method":"$/cancelRequest","params":{"id":"req-1"}}`), reply)
+	ack := (<-replies).(Response)
+	if m, _ := ack.Result.(map[string]any); m["cancelled"] != true {
+		t.Errorf("expected cancel ack, got %+v", ack)
+	}
+
+	h.Wait()
+	resp := (<-replies).(Response)
+	if resp.Error == nil || resp.Error.Code != CodeRequestCancelled {
+		t.Errorf("expected cancelled error, got %+v", resp)
+	}
+
+	// Cancelling an unknown id is harmless.
+	unknown := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":10,"method":"$/cancelRequest","params":{"id":"nope"}}`)).(Response)
+	if m, _ := unknown.Result.(map[string]any); m["cancelled"] != false {
+		t.Errorf("expected cancelled=false for unknown id, got %+v", unknown)
+	}
+}
+
+func TestDispatchDuplicateID(t *testing.T) {
+	h := NewHandler()
+	started := make(chan struct{})
+	h.RegisterContext("block", func(ctx gocontext.Context, params json.RawMessage) (any, *Error) {
+		close(started)
+		<-ctx.Done()
+		return nil, &Error{Code: CodeCommandFailed, Message: ctx.Err().Error()}
+	})
+	h.Register("fast", func(params json.RawMessage) (any, *Error) { return "fast", nil })
+
+	replies := make(chan any, 3)
+	reply := func(v any) { replies <- v }
+
+	h.Dispatch([]byte(`{"jsonrpc":"2.0","id":1,"method":"block"}`), reply)
+	<-started
+
+	// Reusing the id of a request still in flight is refused, and does not
+	// take over its cancellation.
+	h.Dispatch([]byte(`{"jsonrpc":"2.0","id":1,"method":"fast"}`), reply)
+	select {
+	case v := <-replies:
+		if resp := v.(Response); resp.Error == nil || resp.Error.Code != CodeInvalidRequest {
+			t.Errorf("duplicate id: got %+v", resp)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reply to the duplicate request")
+	}
+
+	h.Dispatch([]byte(`{"jsonrpc":"2.0","id":9,"method":"$/cancelRequest","params":{"id":1}}`), reply)
+	if m, _ := (<-replies).(Response).Result.(map[string]any); m["cancelled"] != true {
+		t.Error("first request could not be cancelled")
+	}
+	h.Wait()
+	if resp := (<-replies).(Response); resp.Error == nil || resp.Error.Code != CodeRequestCancelled {
+		t.Errorf("expected the first request cancelled, got %+v", resp)
+	}
+
+	// Once it has finished, the id can be used again.
+	h.Dispatch([]byte(`{"jsonrpc":"2.0","id":1,"method":"fast"}`), reply)
+	h.Wait()
+	if resp := (<-replies).(Response); resp.Result != "fast" {
+		t.Errorf("reused id: got %+v", resp)
+	}
+}
+
 func TestParseParams(t *testing.T) {
 	raw := json.RawMessage(`{"command":"fs:list","args":{"path":"."}}`)
 	params, err := ParseParams[ExecuteParams](raw)
@@ -20,6 +20,14 @@ type Response struct {
 	Error   *Error `json:"error,omitempty"`
 }
 
+// Notification is a server-initiated JSON-RPC 2.0 notification. It carries
+// no id and expects no response.
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
 // Error is a JSON-RPC 2.0 error object.
 type Error struct {
 	Code    int    `json:"code"`
@@ -31,6 +39,26 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+// ErrorData is the data member of command failures: a machine-readable
+// classification agents can branch on instead of parsing Message.
+type ErrorData struct {
+	Code      string `json:"code"`     // e.g. "http.429", "sandbox.violation"
+	Category  string `json:"category"` // e.g. "rate_limited", "not_found"
+	Retriable bool   `json:"retriable"`
+	Hint      string `json:"hint,omitempty"`
+	Command   string `json:"command,omitempty"`
+	Step      *int   `json:"step,omitempty"`
+
+	// Fields lists the problems with an invalid_input error's fields.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is one problem with a command's input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Problem string `json:"problem"` // "missing", or "expected <type>, got <type>"
+}
+
 // Standard JSON-RPC 2.0 error codes.
 const (
 	CodeParseError     = -32700
@@ -47,37 +75,96 @@ const (
 	CodeVerifyFailed    = -32002
 	CodeSpecInvalid     = -32003
 	CodeNoPendingPlan   = -32004
+	CodeContextConflict = -32005 // context.set expected_version did not match
+	CodeResultNotFound  = -32006 // result.fetch result_id unknown or expired
+	CodePlanMismatch    = -32007 // project.approve named a plan other than the pending one
+
+	// CodeRequestCancelled is returned for requests cancelled via
+	// $/cancelRequest (same value as LSP).
+	CodeRequestCancelled = -32800
 )
 
 // Method constants for all supported JSON-RPC methods.
 const (
+	// Request cancellation; usually sent as a notification.
+	MethodCancelRequest = "$/cancelRequest"
+
 	// Core command execution.
 	MethodExecute  = "execute"
 	MethodPipeline = "pipeline"
 
+	// Streaming variants: partial results arrive as stream.* notifications
+	// before the final response.
+	MethodExecuteStream  = "execute.stream"
+	MethodPipelineStream = "pipeline.stream"
+
 	// Command discovery.
 	MethodCommandsList    = "commands.list"
 	MethodCommandsDescribe = "commands.describe"
+	MethodCommandsSearch   = "commands.search"
+	MethodCommandsExport   = "commands.export_schema"
 
 	// Context store operations.
-	MethodContextGet = "context.get"
-	MethodContextSet = "context.set"
+	MethodContextGet      = "context.get"
+	MethodContextSet      = "context.set"
+	MethodContextDelete   = "context.delete"
+	MethodContextList     = "context.list"
+	MethodContextExport   = "context.export"
+	MethodContextImport   = "context.import"
+	MethodContextHistory  = "context.history"
+	MethodContextDescribe = "context.describe"
+	MethodContextWatch    = "context.watch"
+	MethodContextUnwatch  = "context.unwatch"
 
 	// Checkpoint operations.
 	MethodCheckpointSave    = "checkpoint.save"
 	MethodCheckpointRestore = "checkpoint.restore"
+	MethodCheckpointList    = "checkpoint.list"
+	MethodCheckpointDelete  = "checkpoint.delete"
 
 	// Execution history.
 	MethodHistory = "history"
 
+	// Event subscriptions (server-initiated notifications).
+	MethodEventsSubscribe   = "events.subscribe"
+	MethodEventsUnsubscribe = "events.unsubscribe"
+
 	// Project lifecycle (spec-driven).
 	MethodProjectLoad     = "project.load"
 	MethodProjectPlan     = "project.plan"
 	MethodProjectApprove  = "project.approve"
 	MethodProjectReject   = "project.reject"
 	MethodProjectRun      = "project.run"
+	MethodProjectResume   = "project.resume"
+	MethodProjectAnswer   = "project.answer"
 	MethodProjectInit     = "project.init"
 	MethodProjectValidate = "project.validate"
+	MethodProjectStatus   = "project.status"
+	MethodProjectIntent   = "project.intent"
+
+	// Paging of results truncated to the maximum result size.
+	MethodResultFetch = "result.fetch"
+
+	// Execution introspection.
+	MethodExecutionStatus = "execution.status"
+
+	// Backend health checks.
+	MethodDoctor = "doctor"
+)
+
+// Server-initiated notification methods. Runtime events are sent as
+// "event.<type>" (e.g. "event.verify.result"); plan approval requests and
+// the questions of ask steps (data kind "input") are sent as
+// "approval.required".
+const (
+	NotifyEventPrefix      = "event."
+	NotifyApprovalRequired = "approval.required"
+
+	NotifyStreamChunk = "stream.chunk" // a slice of a step's payload
+	NotifyStreamStep  = "stream.step"  // a pipeline step finished
+	NotifyStreamEnd   = "stream.end"   // the stream is complete
+
+	NotifyContextChanged = "context.changed" // a watched context key changed
 )
 
 // NewResponse creates a successful response.
@@ -102,6 +189,15 @@ func NewErrorResponse(id any, code int, message string, data any) Response {
 	}
 }
 
+// NewNotification creates a server-initiated notification.
+func NewNotification(method string, params any) Notification {
+	return Notification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	}
+}
+
 // Parameter types for common methods.
 
 // ExecuteParams holds parameters for the "execute" method.
@@ -110,6 +206,26 @@ type ExecuteParams struct {
 	Args    map[string]any `json:"args,omitempty"`
 	Intent  string         `json:"intent,omitempty"`
 	Verify  []AssertionDef `json:"verify,omitempty"`
+
+	// StreamID correlates stream.* notifications for execute.stream.
+	// Generated when empty.
+	StreamID string `json:"stream_id,omitempty"`
+
+	// IdempotencyKey makes retries safe: a repeated request with the same
+	// key and params returns the cached result instead of executing
+	// again; the same key with other params is rejected.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// MaxResultSize overrides agent.max_result_size for this request, in
+	// bytes of the JSON-encoded payload; -1 returns it whole.
+	MaxResultSize int `json:"max_result_size,omitempty"`
+
+	// Summarize returns a PayloadSummary instead of the payload, which is
+	// kept for result.fetch.
+	Summarize bool `json:"summarize,omitempty"`
+
+	// execute.stream ignores MaxResultSize and Summarize: its payload goes
+	// out whole in stream.chunk notifications, not in the response.
 }
 
 // AssertionDef defines an assertion in a JSON-RPC request.
@@ -122,6 +238,15 @@ type AssertionDef struct {
 // PipelineParams holds parameters for the "pipeline" method.
 type PipelineParams struct {
 	Steps []PipelineStepDef `json:"steps"`
+
+	// StreamID correlates stream.* notifications for pipeline.stream.
+	// Generated when empty.
+	StreamID string `json:"stream_id,omitempty"`
+
+	// MaxResultSize and Summarize apply to the output as for
+	// ExecuteParams, and pipeline.stream ignores them likewise.
+	MaxResultSize int  `json:"max_result_size,omitempty"`
+	Summarize     bool `json:"summarize,omitempty"`
 }
 
 // PipelineStepDef defines a step within a pipeline request.
@@ -131,30 +256,161 @@ type PipelineStepDef struct {
 	Intent  string         `json:"intent,omitempty"`
 	Verify  []AssertionDef `json:"verify,omitempty"`
 	OnError string         `json:"on_error,omitempty"`
+
+	// OnVerifyFailure "rollback" checkpoints before the step and restores
+	// the checkpoint if Verify fails.
+	OnVerifyFailure string `json:"on_verify_failure,omitempty"`
+
+	// VerifyOutputSchema fails the step when its output does not match
+	// the command's output schema (see commands.describe).
+	VerifyOutputSchema bool `json:"verify_output_schema,omitempty"`
+
+	// ID names the step for Needs and Inputs of later steps. Steps with
+	// either form a graph: a step starts once the steps it references have
+	// finished, so independent steps run concurrently.
+	ID     string            `json:"id,omitempty"`
+	Needs  []string          `json:"needs,omitempty"`
+	Inputs map[string]string `json:"inputs,omitempty"` // field -> "<step id>.output[.field]"
 }
 
 // ContextGetParams holds parameters for "context.get".
 type ContextGetParams struct {
-	Scope string `json:"scope"`
-	Key   string `json:"key"`
+	Scope       string `json:"scope"`
+	Key         string `json:"key"`
+	WithVersion bool   `json:"with_version,omitempty"` // return a ContextValue
 }
 
-// ContextSetParams holds parameters for "context.set".
+// ContextSetParams holds parameters for "context.set". With
+// ExpectedVersion, the key is only set if its current version matches ("",
+// if it must not exist yet), and the result is a ContextValue carrying the
+// new version; a mismatch fails with CodeContextConflict.
 type ContextSetParams struct {
+	Scope           string  `json:"scope"`
+	Key             string  `json:"key"`
+	Value           any     `json:"value"`
+	Source          string  `json:"source,omitempty"` // recorded in the history scope
+	ExpectedVersion *string `json:"expected_version,omitempty"`
+	WithVersion     bool    `json:"with_version,omitempty"` // return a ContextValue
+}
+
+// ContextValue is the result of "context.get" and "context.set" with
+// with_version (set leaves Value out).
+type ContextValue struct {
+	Value   any    `json:"value,omitempty"`
+	Version string `json:"version"`
+}
+
+// ContextConflictData is the Data of a CodeContextConflict error.
+type ContextConflictData struct {
+	Scope           string `json:"scope"`
+	Key             string `json:"key"`
+	ExpectedVersion string `json:"expected_version"`
+	Version         string `json:"version"` // current version; "" if the key does not exist
+}
+
+// ContextDeleteParams holds parameters for "context.delete".
+type ContextDeleteParams struct {
+	Scope  string `json:"scope"`
+	Key    string `json:"key"`
+	Source string `json:"source,omitempty"` // recorded in the history scope
+}
+
+// ContextListParams holds parameters for "context.list".
+type ContextListParams struct {
+	Scope string `json:"scope"`
+}
+
+// ContextDescribeParams holds parameters for "context.describe". An empty
+// scope describes the project and session scopes.
+type ContextDescribeParams struct {
+	Scope string `json:"scope,omitempty"`
+}
+
+// ContextHistoryParams holds parameters for "context.history". An empty
+// key selects every key in the scope.
+type ContextHistoryParams struct {
 	Scope string `json:"scope"`
-	Key   string `json:"key"`
-	Value any    `json:"value"`
+	Key   string `json:"key,omitempty"`
+}
+
+// ContextWatchParams holds parameters for "context.watch". Empty fields
+// select every change.
+type ContextWatchParams struct {
+	Scopes []string `json:"scopes,omitempty"`
+	Keys   []string `json:"keys,omitempty"` // key patterns (path.Match)
+}
+
+// ContextUnwatchParams holds parameters for "context.unwatch".
+type ContextUnwatchParams struct {
+	Watch string `json:"watch"`
+}
+
+// ContextExportParams holds parameters for "context.export". Empty fields
+// select everything.
+type ContextExportParams struct {
+	Scopes  []string `json:"scopes,omitempty"`
+	Include []string `json:"include,omitempty"` // key patterns (path.Match)
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// ContextImportParams holds parameters for "context.import". Data is an
+// export as returned by "context.export"; the filter fields select which
+// of its entries are imported.
+type ContextImportParams struct {
+	Data    json.RawMessage `json:"data"`
+	Scopes  []string        `json:"scopes,omitempty"`
+	Include []string        `json:"include,omitempty"`
+	Exclude []string        `json:"exclude,omitempty"`
+	Replace bool            `json:"replace,omitempty"` // delete selected keys the export lacks
+}
+
+// ContextImportResult is the result of "context.import".
+type ContextImportResult struct {
+	Imported int `json:"imported"`
 }
 
 // CheckpointParams holds parameters for checkpoint operations.
 type CheckpointParams struct {
 	Name string `json:"name"`
+
+	// Additive, for checkpoint.restore, keeps keys created since the
+	// checkpoint instead of deleting them.
+	Additive bool `json:"additive,omitempty"`
+
+	// Selective capture for checkpoint.save. Include and Exclude are key
+	// patterns; MaxValueSize skips values whose JSON exceeds it (bytes).
+	Scopes       []string `json:"scopes,omitempty"`
+	Include      []string `json:"include,omitempty"`
+	Exclude      []string `json:"exclude,omitempty"`
+	MaxValueSize int64    `json:"max_value_size,omitempty"`
+}
+
+// CancelParams holds parameters for "$/cancelRequest".
+type CancelParams struct {
+	ID any `json:"id"`
+}
+
+// EventsSubscribeParams holds parameters for "events.subscribe".
+type EventsSubscribeParams struct {
+	Types []string `json:"types,omitempty"` // event types or "prefix.*" patterns; empty means all
+}
+
+// EventsUnsubscribeParams holds parameters for "events.unsubscribe".
+type EventsUnsubscribeParams struct {
+	Subscription string `json:"subscription"`
 }
 
 // ProjectLoadParams holds parameters for "project.load".
 type ProjectLoadParams struct {
 	Path   string            `json:"path"`
 	Params map[string]string `json:"params,omitempty"`
+
+	// IdempotencyKey is honored by project.run; see ExecuteParams.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// Approver is the name project.run's automatic approval is recorded
+	// with; see ProjectApproveParams.
+	Approver string `json:"approver,omitempty"`
 }
 
 // ProjectPlanParams holds parameters for "project.plan" (optional overrides).
@@ -162,15 +418,74 @@ type ProjectPlanParams struct {
 	// Empty: uses the currently loaded spec.
 }
 
-// ProjectApproveParams holds parameters for "project.approve".
+// ProjectApproveParams holds parameters for "project.approve". At least
+// one of PlanID and PlanHash, as returned by "project.plan", is required
+// so an approval cannot apply to a plan the approver has not seen.
 type ProjectApproveParams struct {
-	PlanID string `json:"plan_id,omitempty"`
+	PlanID   string `json:"plan_id,omitempty"`
+	PlanHash string `json:"plan_hash,omitempty"`
+
+	// Approver is the name the approver gives, recorded in the audit log
+	// but not verified. Approvals are counted by connection, so a plan
+	// needing two approvers cannot be approved over one connection.
+	Approver string `json:"approver,omitempty"`
 }
 
 // ProjectRejectParams holds parameters for "project.reject".
 type ProjectRejectParams struct {
 	PlanID   string `json:"plan_id,omitempty"`
 	Feedback string `json:"feedback,omitempty"`
+	Approver string `json:"approver,omitempty"` // see ProjectApproveParams
+}
+
+// ProjectResumeParams holds parameters for "project.resume".
+type ProjectResumeParams struct {
+	RunID string `json:"run_id"` // a run id, unique prefix, or "latest"
+}
+
+// ProjectIntentParams holds parameters for "project.intent".
+type ProjectIntentParams struct {
+	RunID string `json:"run_id,omitempty"` // a run id, unique prefix, or "latest" (the default)
+}
+
+// ResultFetchParams holds parameters for "result.fetch".
+type ResultFetchParams struct {
+	ResultID string `json:"result_id"`
+	Offset   int    `json:"offset,omitempty"` // byte offset into the JSON-encoded payload
+	Length   int    `json:"length,omitempty"` // at most this many bytes; 0 means the maximum result size
+}
+
+// ResultFetchResult is a page of a truncated result's full payload. Data
+// is a slice of its JSON encoding, cut on a UTF-8 boundary; the payload is
+// complete when the pages from offset 0 to Next == Size are joined.
+type ResultFetchResult struct {
+	ResultID string `json:"result_id"`
+	Data     string `json:"data"`
+	Offset   int    `json:"offset"`
+	Next     int    `json:"next"`
+	Size     int    `json:"size"`
+}
+
+// Truncation records in a response's meta ("truncated") that a payload
+// exceeded the maximum result size and was cut down. The full payload can
+// be paged with result.fetch while it is held.
+type Truncation struct {
+	ResultID string `json:"result_id"`
+	Size     int    `json:"size"`     // bytes of the full JSON-encoded payload
+	Returned int    `json:"returned"` // bytes of the truncated payload
+}
+
+// ProjectAnswerParams holds parameters for "project.answer".
+type ProjectAnswerParams struct {
+	ID     string `json:"id"` // id of the input.requested event
+	Answer string `json:"answer"`
+}
+
+// CommandsListParams holds parameters for "commands.list".
+type CommandsListParams struct {
+	Namespace string `json:"namespace,omitempty"` // empty lists all namespaces
+	// Grouped returns []NamespaceInfo instead of a flat []CommandInfo.
+	Grouped bool `json:"grouped,omitempty"`
 }
 
 // CommandsDescribeParams holds parameters for "commands.describe".
@@ -178,6 +493,24 @@ type CommandsDescribeParams struct {
 	Name string `json:"name"`
 }
 
+// CommandsSearchParams holds parameters for "commands.search".
+type CommandsSearchParams struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"` // 0 means no limit
+}
+
+// CommandsExportParams holds parameters for "commands.export_schema".
+type CommandsExportParams struct {
+	Format    string `json:"format,omitempty"`    // "jsonschema" (default) or "openai"
+	Namespace string `json:"namespace,omitempty"` // empty exports all namespaces
+}
+
+// Export formats for "commands.export_schema".
+const (
+	ExportFormatJSONSchema = "jsonschema"
+	ExportFormatOpenAI     = "openai"
+)
+
 // ExecuteResult holds the result of a command execution.
 type ExecuteResult struct {
 	Payload      any                `json:"payload"`
@@ -186,6 +519,63 @@ type ExecuteResult struct {
 	Provenance   []ProvenanceStep   `json:"provenance,omitempty"`
 }
 
+// PipelineStepResult reports one step of a "pipeline" response.
+type PipelineStepResult struct {
+	Command      string            `json:"command"`
+	Status       string            `json:"status"`
+	Duration     string            `json:"duration,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	Verification *VerificationInfo `json:"verification,omitempty"`
+	RolledBack   bool              `json:"rolled_back,omitempty"`
+	Abandoned    bool              `json:"abandoned,omitempty"` // still running when the run timed out
+	Artifacts    []string          `json:"artifacts,omitempty"` // attached by the command
+	OutputSchema *SchemaCheckInfo  `json:"output_schema,omitempty"`
+}
+
+// SchemaCheckInfo is the result of checking a step's output against its
+// command's output schema.
+type SchemaCheckInfo struct {
+	Passed   bool     `json:"passed"`
+	Problems []string `json:"problems,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// RunSummary describes a finished spec run. It is printed by
+// `agsh run --output` and returned as "summary" by project.run.
+type RunSummary struct {
+	RunID        string            `json:"run_id,omitempty"` // see `agsh runs show`
+	Spec         string            `json:"spec"`
+	Success      bool              `json:"success"`
+	StartedAt    string            `json:"started_at"`
+	Duration     string            `json:"duration"`
+	Steps        []RunStep         `json:"steps"`
+	Verification *VerificationInfo `json:"verification,omitempty"`
+	Artifacts    []string          `json:"artifacts,omitempty"`   // files written by the run
+	Checkpoints  []string          `json:"checkpoints,omitempty"` // checkpoints saved, in order
+	LLMUsage     *LLMUsage         `json:"llm_usage,omitempty"`   // spend of llm steps and llm_judge assertions
+	Output       any               `json:"output,omitempty"`
+}
+
+// RunStep reports one step of a RunSummary.
+type RunStep struct {
+	ID         string    `json:"id,omitempty"`
+	Command    string    `json:"command"`
+	Args       []string  `json:"args,omitempty"`
+	Intent     string    `json:"intent,omitempty"`
+	Status     string    `json:"status"`
+	Duration   string    `json:"duration"`
+	Error      string    `json:"error,omitempty"`
+	Checkpoint string    `json:"checkpoint,omitempty"`
+	Verified   *bool     `json:"verified,omitempty"`
+	RolledBack bool      `json:"rolled_back,omitempty"`
+	Abandoned  bool      `json:"abandoned,omitempty"` // still running when the run timed out
+	Artifacts  []string  `json:"artifacts,omitempty"` // attached by the command
+	Drift      []string  `json:"drift,omitempty"`     // what the step did that its declaration rules out
+	Steps      []RunStep `json:"steps,omitempty"`     // of the spec the step used
+
+	OutputSchema *SchemaCheckInfo `json:"output_schema,omitempty"`
+}
+
 // VerificationInfo holds verification results in a response.
 type VerificationInfo struct {
 	Passed  bool              `json:"passed"`
@@ -194,12 +584,27 @@ type VerificationInfo struct {
 
 // AssertionOutput holds a single assertion result in a response.
 type AssertionOutput struct {
-	Type    string `json:"type"`
-	Passed  bool   `json:"passed"`
-	Actual  any    `json:"actual,omitempty"`
-	Message string `json:"message,omitempty"`
+	Type    string    `json:"type"`
+	Passed  bool      `json:"passed"`
+	Actual  any       `json:"actual,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Usage   *LLMUsage `json:"usage,omitempty"` // of an llm_judge assertion
 }
 
+// LLMUsage reports tokens spent on an LLM. Cached counts llm_judge
+// verdicts reused from the cache, which cost nothing.
+type LLMUsage struct {
+	Model            string `json:"model,omitempty"`
+	Calls            int    `json:"calls"`
+	Cached           int    `json:"cached,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// ProvenanceDeduplicated is the provenance status recorded when a result is
+// replayed from the idempotency cache.
+const ProvenanceDeduplicated = "deduplicated"
+
 // ProvenanceStep records a provenance entry in a response.
 type ProvenanceStep struct {
 	Command  string `json:"command"`
@@ -207,6 +612,71 @@ type ProvenanceStep struct {
 	Status   string `json:"status"`
 }
 
+// ProjectStatus is the result of "project.status".
+type ProjectStatus struct {
+	Spec            *SpecSummary    `json:"spec,omitempty"` // nil when no spec is loaded
+	PendingPlanID   string          `json:"pending_plan_id,omitempty"`
+	PendingPlanHash string          `json:"pending_plan_hash,omitempty"`
+	Execution       ExecutionStatus `json:"execution"`
+}
+
+// SpecSummary describes the spec loaded by "project.load".
+type SpecSummary struct {
+	Name            string   `json:"name"`
+	Description     string   `json:"description,omitempty"`
+	Goal            string   `json:"goal"`
+	Constraints     []string `json:"constraints,omitempty"`
+	SuccessCriteria int      `json:"success_criteria"`
+}
+
+// ExecutionStatus is the result of "execution.status". It describes the
+// plan currently executing, or the most recent one when Running is false.
+type ExecutionStatus struct {
+	Running          bool              `json:"running"`
+	PlanID           string            `json:"plan_id,omitempty"`
+	CurrentStep      int               `json:"current_step"` // index of the step in progress; -1 when idle
+	StepsCompleted   int               `json:"steps_completed"`
+	StepsTotal       int               `json:"steps_total"`
+	StartedAt        string            `json:"started_at,omitempty"`
+	Elapsed          string            `json:"elapsed,omitempty"`
+	Budgets          *BudgetStatus     `json:"budgets,omitempty"` // nil before the first execution
+	LastVerification *VerificationInfo `json:"last_verification,omitempty"`
+}
+
+// BudgetStatus reports what an execution has consumed of its limits.
+type BudgetStatus struct {
+	Tokens      int    `json:"tokens"`                 // LLM tokens used by the execution
+	TokensUsed  int    `json:"tokens_used"`            // LLM tokens used by the session, which token_limit caps
+	TokenLimit  int    `json:"token_limit"`            // 0 = unlimited
+	MaxDuration string `json:"max_duration,omitempty"` // the plan's limit on elapsed; empty = none
+}
+
+// StreamChunk is the params payload of a stream.chunk notification.
+type StreamChunk struct {
+	StreamID string `json:"stream_id"`
+	Step     int    `json:"step"`
+	Seq      int    `json:"seq"`
+	Data     string `json:"data"`
+	Final    bool   `json:"final"` // last chunk of this step's payload
+}
+
+// StreamStep is the params payload of a stream.step notification.
+type StreamStep struct {
+	StreamID string `json:"stream_id"`
+	Step     int    `json:"step"`
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// StreamEnd is the params payload of a stream.end notification.
+type StreamEnd struct {
+	StreamID string `json:"stream_id"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+}
+
 // CommandInfo describes a command in the commands.list response.
 type CommandInfo struct {
 	Name        string `json:"name"`
@@ -214,14 +684,59 @@ type CommandInfo struct {
 	Namespace   string `json:"namespace"`
 }
 
+// DoctorResult is the response of "doctor".
+type DoctorResult struct {
+	Healthy bool              `json:"healthy"`
+	Checks  []HealthCheckInfo `json:"checks"`
+}
+
+// HealthCheckInfo reports one backend health check.
+type HealthCheckInfo struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// NamespaceInfo describes a namespace and its commands in a grouped
+// commands.list response.
+type NamespaceInfo struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Credentials []string      `json:"required_credentials,omitempty"`
+	Risk        string        `json:"risk,omitempty"`
+	Commands    []CommandInfo `json:"commands"`
+}
+
 // CommandDetail describes a command with its schema in commands.describe response.
 type CommandDetail struct {
-	Name         string     `json:"name"`
-	Description  string     `json:"description"`
-	Namespace    string     `json:"namespace"`
-	InputSchema  SchemaInfo `json:"input_schema"`
-	OutputSchema SchemaInfo `json:"output_schema"`
-	Credentials  []string   `json:"required_credentials,omitempty"`
+	Name         string      `json:"name"`
+	Description  string      `json:"description"`
+	Namespace    string      `json:"namespace"`
+	InputSchema  SchemaInfo  `json:"input_schema"`
+	OutputSchema SchemaInfo  `json:"output_schema"`
+	Credentials  []string    `json:"required_credentials,omitempty"`
+	Aliases      []AliasInfo `json:"aliases,omitempty"`
+	// Deprecation is set when the command was described by a deprecated
+	// alias, and says what to use instead.
+	Deprecation string `json:"deprecation,omitempty"`
+	// Examples are sample inputs, with the outputs they produce, that show
+	// the shape of the command's arguments.
+	Examples []ExampleInfo `json:"examples,omitempty"`
+}
+
+// ExampleInfo is a sample invocation of a command.
+type ExampleInfo struct {
+	Description string `json:"description"`
+	Input       any    `json:"input"`
+	Output      any    `json:"output,omitempty"`
+}
+
+// AliasInfo describes an alternative name for a command.
+type AliasInfo struct {
+	Name       string `json:"name"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+	Message    string `json:"message,omitempty"`
 }
 
 // SchemaInfo is a simplified schema representation for JSON-RPC responses.
@@ -233,6 +748,7 @@ type SchemaInfo struct {
 
 // SchemaFieldInfo describes a field in a schema for JSON-RPC responses.
 type SchemaFieldInfo struct {
-	Type        string `json:"type"`
-	Description string `json:"description"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Aliases     []string `json:"aliases,omitempty"`
 }
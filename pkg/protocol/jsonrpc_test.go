@@ -55,6 +55,26 @@ func TestResponseError(t *testing.T) {
 	}
 }
 
+func TestNotificationMarshal(t *testing.T) {
+	n := NewNotification(NotifyApprovalRequired, map[string]string{"plan_id": "p1"})
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if _, hasID := decoded["id"]; hasID {
+		t.Error("notification must not carry an id")
+	}
+	if decoded["method"] != NotifyApprovalRequired {
+		t.Errorf("method = %v", decoded["method"])
+	}
+}
+
 func TestResponseMarshalRoundTrip(t *testing.T) {
 	resp := NewResponse("abc", map[string]string{"status": "ok"})
 
@@ -108,7 +128,8 @@ func TestMethodConstants(t *testing.T) {
 		MethodHistory,
 		MethodProjectLoad, MethodProjectPlan,
 		MethodProjectApprove, MethodProjectReject,
-		MethodProjectRun, MethodProjectInit, MethodProjectValidate,
+		MethodProjectRun, MethodProjectResume, MethodProjectAnswer, MethodProjectInit, MethodProjectValidate,
+		MethodProjectIntent, MethodResultFetch,
 	}
 
 	seen := make(map[string]bool)
@@ -122,8 +143,8 @@ func TestMethodConstants(t *testing.T) {
 		seen[m] = true
 	}
 
-	if len(methods) != 16 {
-		t.Errorf("expected 16 methods, got %d", len(methods))
+	if len(methods) != 20 {
+		t.Errorf("expected 20 methods, got %d", len(methods))
 	}
 }
 
@@ -0,0 +1,234 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultResultHold is how many bytes of full payloads a ResultStore holds
+// for result.fetch before dropping the oldest.
+const DefaultResultHold = 64 << 20
+
+// ResultStore truncates payloads that exceed a maximum result size and
+// holds their full JSON encoding so result.fetch can page it. It is safe
+// for concurrent use.
+type ResultStore struct {
+	max  int // default maximum result size in bytes; 0 means no limit
+	hold int // bytes of payloads held before the oldest are dropped
+
+	mu      sync.Mutex
+	held    int
+	order   []string // result ids, oldest first
+	results map[string][]byte
+}
+
+// NewResultStore creates a ResultStore truncating payloads over max bytes
+// of JSON (0 disables truncation) and holding up to hold bytes of full
+// payloads (0 uses DefaultResultHold).
+func NewResultStore(max, hold int) *ResultStore {
+	if hold <= 0 {
+		hold = DefaultResultHold
+	}
+	return &ResultStore{max: max, hold: hold, results: make(map[string][]byte)}
+}
+
+// Limit returns payload as is if its JSON encoding fits the maximum result
+// size — override, if non-zero, or the store's — and otherwise a truncated
+// payload and the Truncation to record in the response's meta. A negative
+// override disables truncation.
+//
+// Truncation is deterministic: strings keep their prefix, arrays their
+// leading items, and objects all keys but with their largest values
+// shrunk first, dropping values that cannot be shrunk. The same payload
+// always truncates to the same result id.
+func (s *ResultStore) Limit(payload any, override int) (any, *Truncation) {
+	max := s.max
+	if override != 0 {
+		max = override
+	}
+	if max <= 0 || payload == nil {
+		return payload, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil || len(data) <= max {
+		return payload, nil
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return payload, nil
+	}
+	truncated, ok := truncateValue(v, max)
+	if !ok {
+		truncated = nil
+	}
+	returned, _ := json.Marshal(truncated)
+
+	id := s.put(data)
+	return truncated, &Truncation{ResultID: id, Size: len(data), Returned: len(returned)}
+}
+
+// put holds data under an id derived from it, dropping the oldest
+// payloads beyond the hold, and returns the id.
+func (s *ResultStore) put(data []byte) string {
+	sum := sha256.Sum256(data)
+	id := "res-" + hex.EncodeToString(sum[:8])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.results[id]; ok {
+		// Refresh its place as the newest.
+		s.order = slices.DeleteFunc(s.order, func(o string) bool { return o == id })
+		s.order = append(s.order, id)
+		return id
+	}
+	s.results[id] = data
+	s.order = append(s.order, id)
+	s.held += len(data)
+	for s.held > s.hold && len(s.order) > 1 {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		s.held -= len(s.results[oldest])
+		delete(s.results, oldest)
+	}
+	return id
+}
+
+// Fetch returns the page of a held payload starting at p.Offset.
+func (s *ResultStore) Fetch(p ResultFetchParams) (ResultFetchResult, *Error) {
+	s.mu.Lock()
+	data, ok := s.results[p.ResultID]
+	s.mu.Unlock()
+	if !ok {
+		return ResultFetchResult{}, &Error{
+			Code:    CodeResultNotFound,
+			Message: fmt.Sprintf("result %q not found; it may have expired", p.ResultID),
+		}
+	}
+	if p.Offset < 0 || p.Offset > len(data) {
+		return ResultFetchResult{}, &Error{
+			Code:    CodeInvalidParams,
+			Message: fmt.Sprintf("offset %d out of range (size %d)", p.Offset, len(data)),
+		}
+	}
+
+	length := p.Length
+	if length <= 0 || (s.max > 0 && length > s.max) {
+		length = s.max
+	}
+	end := len(data)
+	if length > 0 && p.Offset+length < end {
+		end = p.Offset + length
+		for end > p.Offset && !utf8.RuneStart(data[end]) {
+			end--
+		}
+	}
+	return ResultFetchResult{
+		ResultID: p.ResultID,
+		Data:     string(data[p.Offset:end]),
+		Offset:   p.Offset,
+		Next:     end,
+		Size:     len(data),
+	}, nil
+}
+
+// truncateValue shrinks a decoded JSON value until its encoding fits in
+// budget bytes. It reports false when even an empty value of its kind does
+// not fit, or the value is a number, boolean or null that does not.
+func truncateValue(v any, budget int) (any, bool) {
+	if encodedLen(v) <= budget {
+		return v, true
+	}
+	switch v := v.(type) {
+	case string:
+		return truncateString(v, budget)
+	case []any:
+		return truncateArray(v, budget)
+	case map[string]any:
+		return truncateObject(v, budget)
+	}
+	return nil, false
+}
+
+func truncateString(s string, budget int) (any, bool) {
+	if budget < 2 {
+		return nil, false
+	}
+	// Escapes make the encoding longer than the string, so cut by the
+	// excess until it fits.
+	n := len(s)
+	for n > 0 {
+		for n < len(s) && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		excess := encodedLen(s[:n]) - budget
+		if excess <= 0 {
+			return s[:n], true
+		}
+		n -= excess
+	}
+	return "", true
+}
+
+func truncateArray(items []any, budget int) (any, bool) {
+	if budget < 2 {
+		return nil, false
+	}
+	used := 2 // []
+	kept := []any{}
+	for i, item := range items {
+		n := encodedLen(item)
+		if i > 0 {
+			n++ // comma
+		}
+		if used+n > budget {
+			if len(kept) == 0 {
+				if t, ok := truncateValue(item, budget-used); ok {
+					kept = append(kept, t)
+				}
+			}
+			break
+		}
+		kept = append(kept, item)
+		used += n
+	}
+	return kept, true
+}
+
+func truncateObject(obj map[string]any, budget int) (any, bool) {
+	if budget < 2 {
+		return nil, false
+	}
+	out := maps.Clone(obj)
+	for len(out) > 0 {
+		excess := encodedLen(out) - budget
+		if excess <= 0 {
+			return out, true
+		}
+		// Shrink the largest value; ties go to the first key.
+		var largest string
+		size := -1
+		for _, k := range slices.Sorted(maps.Keys(out)) {
+			if n := encodedLen(out[k]); n > size {
+				largest, size = k, n
+			}
+		}
+		if t, ok := truncateValue(out[largest], size-excess); ok && encodedLen(t) < size {
+			out[largest] = t
+		} else {
+			delete(out, largest)
+		}
+	}
+	return out, true
+}
+
+func encodedLen(v any) int {
+	data, _ := json.Marshal(v)
+	return len(data)
+}
@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResultStoreLimit(t *testing.T) {
+	s := NewResultStore(100, 0)
+
+	if got, tr := s.Limit("short", 0); got != "short" || tr != nil {
+		t.Errorf("Limit(short) = %v, %v; want it unchanged", got, tr)
+	}
+
+	long := strings.Repeat("é", 200)
+	got, tr := s.Limit(long, 0)
+	if tr == nil {
+		t.Fatal("Limit(long) not truncated")
+	}
+	text, _ := got.(string)
+	if !strings.HasPrefix(long, text) || len(text) == 0 || !json.Valid([]byte(`"`+text+`"`)) {
+		t.Errorf("truncated string = %q, want a valid prefix", text)
+	}
+	if tr.Size != len(long)+2 || tr.Returned > 100 {
+		t.Errorf("truncation = %+v", tr)
+	}
+	if _, again := s.Limit(long, 0); again.ResultID != tr.ResultID {
+		t.Errorf("result id %q, then %q; want it deterministic", tr.ResultID, again.ResultID)
+	}
+
+	if _, tr := s.Limit(long, -1); tr != nil {
+		t.Error("Limit with override -1 truncated")
+	}
+}
+
+func TestResultStoreLimitStructured(t *testing.T) {
+	s := NewResultStore(120, 0)
+
+	items := make([]map[string]any, 20)
+	for i := range items {
+		items[i] = map[string]any{"n": i}
+	}
+	got, tr := s.Limit(items, 0)
+	kept, ok := got.([]any)
+	if tr == nil || !ok || len(kept) == 0 || len(kept) >= len(items) {
+		t.Fatalf("Limit(items) = %v, %+v; want leading items", got, tr)
+	}
+	if first := kept[0].(map[string]any); first["n"] != float64(0) {
+		t.Errorf("first item = %v", first)
+	}
+
+	resp := map[string]any{"status": 200, "url": "https://example.com", "body": strings.Repeat("x", 1000)}
+	got, tr = s.Limit(resp, 0)
+	obj, ok := got.(map[string]any)
+	if tr == nil || !ok {
+		t.Fatalf("Limit(resp) = %v", got)
+	}
+	if obj["status"] != float64(200) || obj["url"] != "https://example.com" {
+		t.Errorf("small fields changed: %v", obj)
+	}
+	if body, _ := obj["body"].(string); body == "" || len(body) >= 1000 {
+		t.Errorf("body has %d bytes, want it shrunk", len(body))
+	}
+	if tr.Returned > 120 {
+		t.Errorf("returned %d bytes, over the limit", tr.Returned)
+	}
+}
+
+func TestResultStoreFetch(t *testing.T) {
+	s := NewResultStore(50, 0)
+	payload := map[string]any{"text": strings.Repeat("ü", 100)}
+	_, tr := s.Limit(payload, 0)
+	if tr == nil {
+		t.Fatal("payload not truncated")
+	}
+
+	var buf strings.Builder
+	offset := 0
+	for offset < tr.Size {
+		page, err := s.Fetch(ResultFetchParams{ResultID: tr.ResultID, Offset: offset})
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if len(page.Data) > 50 || page.Next <= offset {
+			t.Fatalf("page at %d = %d bytes, next %d", offset, len(page.Data), page.Next)
+		}
+		buf.WriteString(page.Data)
+		offset = page.Next
+	}
+	var full map[string]any
+	if err := json.Unmarshal([]byte(buf.String()), &full); err != nil || full["text"] != payload["text"] {
+		t.Errorf("reassembled payload = %q (%v)", buf.String(), err)
+	}
+
+	if _, err := s.Fetch(ResultFetchParams{ResultID: "res-unknown"}); err == nil || err.Code != CodeResultNotFound {
+		t.Errorf("Fetch(unknown) error = %v", err)
+	}
+	if _, err := s.Fetch(ResultFetchParams{ResultID: tr.ResultID, Offset: tr.Size + 1}); err == nil || err.Code != CodeInvalidParams {
+		t.Errorf("Fetch(out of range) error = %v", err)
+	}
+}
+
+func TestResultStoreHold(t *testing.T) {
+	s := NewResultStore(10, 100)
+	_, first := s.Limit(strings.Repeat("a", 60), 0)
+	_, second := s.Limit(strings.Repeat("b", 60), 0)
+	if _, err := s.Fetch(ResultFetchParams{ResultID: first.ResultID}); err == nil {
+		t.Error("oldest result still held beyond the hold")
+	}
+	if _, err := s.Fetch(ResultFetchParams{ResultID: second.ResultID}); err != nil {
+		t.Errorf("newest result: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package protocol
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bounds of a PayloadSummary.
+const (
+	summaryLines     = 5   // first and last lines of text
+	summaryLineLen   = 200 // bytes kept of each line and string field
+	summaryKeys      = 50  // keys listed
+	summaryItemBytes = 512 // bytes kept of the first and last items
+)
+
+// PayloadSummary is the compact form of a payload returned for requests
+// with summarize set: its shape, counts and a few key values. The full
+// payload can be fetched with result.fetch under ResultID.
+type PayloadSummary struct {
+	ResultID string `json:"result_id"`
+	Type     string `json:"type"` // "text", "array", "object", "number", "boolean" or "null"
+	Size     int    `json:"size"` // bytes of the JSON-encoded payload
+
+	// Text: its line count and first and last lines.
+	Lines int      `json:"lines,omitempty"`
+	Head  []string `json:"head,omitempty"`
+	Tail  []string `json:"tail,omitempty"`
+
+	// Array: its item count, the keys of its object items, and its first
+	// and last items, shrunk.
+	Items    int      `json:"items,omitempty"`
+	ItemKeys []string `json:"item_keys,omitempty"`
+	First    any      `json:"first,omitempty"`
+	Last     any      `json:"last,omitempty"`
+
+	// Object: its keys, its scalar fields (strings cut to their first
+	// line), the lengths of its array fields and the line counts of its
+	// multi-line strings. A number or boolean payload is reported here as
+	// "value".
+	Keys   []string       `json:"keys,omitempty"`
+	Fields map[string]any `json:"fields,omitempty"`
+	Counts map[string]int `json:"counts,omitempty"`
+}
+
+// Summarize holds payload for result.fetch and returns its summary.
+func (s *ResultStore) Summarize(payload any) PayloadSummary {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		data = []byte("null")
+	}
+	id := s.put(data)
+
+	var v any
+	_ = json.Unmarshal(data, &v)
+	ps := summarizeValue(v)
+	ps.ResultID = id
+	ps.Size = len(data)
+	return ps
+}
+
+func summarizeValue(v any) PayloadSummary {
+	switch v := v.(type) {
+	case string:
+		lines := strings.Split(strings.TrimRight(v, "\n"), "\n")
+		ps := PayloadSummary{Type: "text", Lines: len(lines)}
+		if v == "" {
+			ps.Lines = 0
+			return ps
+		}
+		n := min(summaryLines, len(lines))
+		ps.Head = clipLines(lines[:n])
+		if len(lines) > n {
+			ps.Tail = clipLines(lines[max(n, len(lines)-summaryLines):])
+		}
+		return ps
+	case []any:
+		ps := PayloadSummary{Type: "array", Items: len(v)}
+		keys := make(map[string]bool)
+		for _, item := range v {
+			if obj, ok := item.(map[string]any); ok {
+				for k := range obj {
+					keys[k] = true
+				}
+			}
+		}
+		ps.ItemKeys = firstKeys(keys)
+		if len(v) > 0 {
+			ps.First, _ = truncateValue(v[0], summaryItemBytes)
+		}
+		if len(v) > 1 {
+			ps.Last, _ = truncateValue(v[len(v)-1], summaryItemBytes)
+		}
+		return ps
+	case map[string]any:
+		ps := PayloadSummary{Type: "object", Fields: map[string]any{}, Counts: map[string]int{}}
+		ps.Keys = firstKeys(v)
+		for _, k := range ps.Keys {
+			switch f := v[k].(type) {
+			case string:
+				if strings.Contains(f, "\n") {
+					ps.Counts[k] = strings.Count(strings.TrimRight(f, "\n"), "\n") + 1
+				}
+				ps.Fields[k] = clip(firstLine(f))
+			case float64, bool:
+				ps.Fields[k] = f
+			case []any:
+				ps.Counts[k] = len(f)
+			}
+		}
+		return ps
+	case float64:
+		return PayloadSummary{Type: "number", Fields: map[string]any{"value": v}}
+	case bool:
+		return PayloadSummary{Type: "boolean", Fields: map[string]any{"value": v}}
+	}
+	return PayloadSummary{Type: "null"}
+}
+
+// firstKeys returns the first summaryKeys keys of m in sorted order.
+func firstKeys[V any](m map[string]V) []string {
+	keys := slices.Sorted(maps.Keys(m))
+	return keys[:min(len(keys), summaryKeys)]
+}
+
+func clipLines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = clip(l)
+	}
+	return out
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// clip cuts s to summaryLineLen bytes on a UTF-8 boundary, marking the
+// cut with an ellipsis.
+func clip(s string) string {
+	if len(s) <= summaryLineLen {
+		return s
+	}
+	n := summaryLineLen
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
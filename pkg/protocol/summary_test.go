@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeText(t *testing.T) {
+	s := NewResultStore(0, 0)
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	text := strings.Join(lines, "\n") + "\n"
+
+	encoded, _ := json.Marshal(text)
+	ps := s.Summarize(text)
+	if ps.Type != "text" || ps.Lines != 20 || ps.Size != len(encoded) {
+		t.Errorf("summary = %+v", ps)
+	}
+	if !slices.Equal(ps.Head, lines[:5]) || !slices.Equal(ps.Tail, lines[15:]) {
+		t.Errorf("head = %v, tail = %v", ps.Head, ps.Tail)
+	}
+
+	page, err := s.Fetch(ResultFetchParams{ResultID: ps.ResultID})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	var full string
+	if err := json.Unmarshal([]byte(page.Data), &full); err != nil || full != text {
+		t.Errorf("fetched %q (%v), want the full payload", page.Data, err)
+	}
+}
+
+func TestSummarizeArray(t *testing.T) {
+	items := []map[string]any{
+		{"number": 1, "title": "first"},
+		{"number": 2, "title": "second", "draft": true},
+		{"number": 3, "title": "third"},
+	}
+	ps := NewResultStore(0, 0).Summarize(items)
+	if ps.Type != "array" || ps.Items != 3 {
+		t.Fatalf("summary = %+v", ps)
+	}
+	if !slices.Equal(ps.ItemKeys, []string{"draft", "number", "title"}) {
+		t.Errorf("item keys = %v", ps.ItemKeys)
+	}
+	if first := ps.First.(map[string]any); first["title"] != "first" {
+		t.Errorf("first = %v", ps.First)
+	}
+	if last := ps.Last.(map[string]any); last["title"] != "third" {
+		t.Errorf("last = %v", ps.Last)
+	}
+}
+
+func TestSummarizeObject(t *testing.T) {
+	resp := map[string]any{
+		"status":  200,
+		"ok":      true,
+		"body":    "<html>\n" + strings.Repeat("<p>x</p>\n", 100),
+		"headers": map[string]any{"Content-Type": "text/html"},
+		"links":   []string{"a", "b"},
+	}
+	ps := NewResultStore(0, 0).Summarize(resp)
+	if ps.Type != "object" || !slices.Equal(ps.Keys, []string{"body", "headers", "links", "ok", "status"}) {
+		t.Fatalf("summary = %+v", ps)
+	}
+	if ps.Fields["status"] != float64(200) || ps.Fields["ok"] != true || ps.Fields["body"] != "<html>" {
+		t.Errorf("fields = %v", ps.Fields)
+	}
+	if _, ok := ps.Fields["headers"]; ok {
+		t.Error("nested object reported as a field")
+	}
+	if ps.Counts["links"] != 2 || ps.Counts["body"] != 101 {
+		t.Errorf("counts = %v", ps.Counts)
+	}
+}
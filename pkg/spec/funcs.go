@@ -0,0 +1,151 @@
+package spec
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now is the clock {{date}} and the other date variables read; tests
+// replace it.
+var now = time.Now
+
+// interpolationFunc computes the value of a {{name arg ...}} pattern.
+// errUnresolved leaves the pattern as it is, as for an unknown variable.
+type interpolationFunc func(args []string, vars map[string]string) (string, error)
+
+var errUnresolved = errors.New("unresolved")
+
+// interpolationFuncs are the functions specs can call in {{...}} patterns,
+// mostly to build output paths and titles. A variable of the same name
+// takes precedence when no arguments are given.
+var interpolationFuncs = map[string]interpolationFunc{
+	"date":  dateFunc,
+	"env":   envFunc,
+	"lower": transformFunc(strings.ToLower),
+	"upper": transformFunc(strings.ToUpper),
+	"slug":  transformFunc(slugify),
+}
+
+// dateFormats are the named formats of {{date}}; other formats are Go
+// time layouts.
+var dateFormats = map[string]func(time.Time) string{
+	"date":     func(t time.Time) string { return t.Format("2006-01-02") },
+	"datetime": func(t time.Time) string { return t.Format("2006-01-02T15:04:05") },
+	"year":     func(t time.Time) string { return t.Format("2006") },
+	"month":    func(t time.Time) string { return t.Format("01") },
+	"day":      func(t time.Time) string { return t.Format("02") },
+	"week": func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	},
+	"unix": func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+}
+
+var offsetPattern = regexp.MustCompile(`^([+-]\d+)(h|d|w|mo|y)$`)
+
+// dateFunc implements {{date [offset] [format]}}: the current date moved by
+// an offset such as +7d, -1w, +1mo or -1y (h, d, w, mo, y), in a named
+// format or a Go layout such as 2006/01/02.
+func dateFunc(args []string, _ map[string]string) (string, error) {
+	t, format := now(), dateFormats["date"]
+	for _, arg := range args {
+		if m := offsetPattern.FindStringSubmatch(arg); m != nil {
+			n, err := strconv.Atoi(m[1])
+			if err != nil {
+				return "", fmt.Errorf("invalid offset %q", arg)
+			}
+			switch m[2] {
+			case "h":
+				t = t.Add(time.Duration(n) * time.Hour)
+			case "d":
+				t = t.AddDate(0, 0, n)
+			case "w":
+				t = t.AddDate(0, 0, 7*n)
+			case "mo":
+				t = t.AddDate(0, n, 0)
+			case "y":
+				t = t.AddDate(n, 0, 0)
+			}
+			continue
+		}
+		if f, ok := dateFormats[arg]; ok {
+			format = f
+			continue
+		}
+		if !strings.ContainsAny(arg, "0123456789") {
+			return "", fmt.Errorf("unknown format or offset %q", arg)
+		}
+		layout := arg
+		format = func(t time.Time) string { return t.Format(layout) }
+	}
+	return format(t), nil
+}
+
+// envPrefix is the prefix of the environment variables specs may read.
+// Specs can come from agents, so they must not reach credentials such as
+// GITHUB_TOKEN.
+const envPrefix = "AGSH_"
+
+// envFunc implements {{env NAME [default]}}. Only names starting with
+// envPrefix can be read. An unset variable without a default is an error,
+// so a missing one does not go unnoticed.
+func envFunc(args []string, _ map[string]string) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", fmt.Errorf("expected a variable name and an optional default")
+	}
+	if !strings.HasPrefix(args[0], envPrefix) {
+		return "", fmt.Errorf("environment variable %s is not readable by specs (only %s* variables are)", args[0], envPrefix)
+	}
+	if value, ok := os.LookupEnv(args[0]); ok {
+		return value, nil
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set", args[0])
+}
+
+// transformFunc returns a function that applies transform to the value of
+// the variable its argument names, such as {{slug title}}.
+func transformFunc(transform func(string) string) interpolationFunc {
+	return func(args []string, vars map[string]string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("expected one variable name")
+		}
+		value, ok := vars[args[0]]
+		if !ok {
+			return "", errUnresolved
+		}
+		return transform(value), nil
+	}
+}
+
+// slugify lowercases s and joins its runs of letters and digits with
+// hyphens, for use in file names.
+func slugify(s string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	}) {
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
+
+// newUUID returns a random (version 4) UUID, for {{uuid}}.
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,81 @@
+package spec
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInterpolationFuncs(t *testing.T) {
+	defer func(clock func() time.Time) { now = clock }(now)
+	now = func() time.Time { return time.Date(2025, 1, 30, 9, 30, 0, 0, time.UTC) }
+	t.Setenv("AGSH_TEST_TEAM", "platform")
+
+	vars := buildVarMap(nil, map[string]string{"title": "Weekly Report: Q1/2025!"})
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"{{date}}", "2025-01-30"},
+		{"{{date +7d}}", "2025-02-06"},
+		{"{{date -1w}}", "2025-01-23"},
+		{"{{date +1mo}}", "2025-03-02"},
+		{"{{date -1y year}}", "2024"},
+		{"{{date +15h datetime}}", "2025-01-31T00:30:00"},
+		{"{{date week}}", "2025-W05"},
+		{"{{date 2006/01/02}}", "2025/01/30"},
+		{"{{env AGSH_TEST_TEAM}}", "platform"},
+		{"{{env AGSH_TEST_UNSET fallback}}", "fallback"},
+		{"{{lower title}}", "weekly report: q1/2025!"},
+		{"{{upper title}}", "WEEKLY REPORT: Q1/2025!"},
+		{"./reports/{{slug title}}-{{date}}.md", "./reports/weekly-report-q1-2025-2025-01-30.md"},
+		{"{{slug author}}", "{{slug author}}"}, // unresolved stays
+		{"{{nofunc x}}", "{{nofunc x}}"},
+	}
+	for _, tt := range tests {
+		got, err := interpolateVars(tt.input, vars)
+		if err != nil {
+			t.Errorf("interpolateVars(%q): %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("interpolateVars(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	t.Setenv("GITHUB_TOKEN", "ghp_secret")
+	for _, input := range []string{"{{env AGSH_TEST_UNSET}}", "{{env GITHUB_TOKEN}}", "{{env GITHUB_TOKEN fallback}}", "{{date soon}}", "{{slug}}"} {
+		if _, err := interpolateVars(input, vars); err == nil || !strings.Contains(err.Error(), input) {
+			t.Errorf("interpolateVars(%q) err = %v, want an error naming it", input, err)
+		}
+	}
+}
+
+func TestInterpolateUUID(t *testing.T) {
+	vars := buildVarMap(nil, nil)
+	got, err := interpolateVars("{{uuid}} {{uuid}}", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second, _ := strings.Cut(got, " ")
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(first) {
+		t.Errorf("uuid = %q", first)
+	}
+	if first != second {
+		t.Errorf("uuids %q and %q differ within one spec", first, second)
+	}
+}
+
+func TestParseSpecInterpolationError(t *testing.T) {
+	data := []byte(`
+apiVersion: agsh/v2
+kind: ProjectSpec
+metadata:
+  name: "{{env AGSH_TEST_UNSET}}"
+goal: g
+`)
+	_, err := ParseSpec(data, nil)
+	if err == nil || !strings.Contains(err.Error(), "AGSH_TEST_UNSET is not set") {
+		t.Errorf("err = %v, want the unset variable", err)
+	}
+}
@@ -0,0 +1,72 @@
+package spec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// PlanHash returns a canonical hash of a plan's content, "sha256:<hex>".
+// Like gofmt output, the canonical form ignores how the plan happens to be
+// represented: object keys are sorted and zero values (null, "", false, 0,
+// empty lists and objects) are dropped, so a plan hashes the same however
+// its optional fields were left unset. Approvals carry the hash to bind
+// them to the plan that was reviewed.
+func PlanHash(plan ExecutionPlan) (string, error) {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return "", fmt.Errorf("hash plan: %w", err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", fmt.Errorf("hash plan: %w", err)
+	}
+	canonical, err := json.Marshal(canonicalize(v)) // maps encode with sorted keys
+	if err != nil {
+		return "", fmt.Errorf("hash plan: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalize drops the zero values of a decoded JSON value, returning
+// nil if it is itself zero.
+func canonicalize(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			if c := canonicalize(e); c != nil {
+				out[k] = c
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		out := make([]any, len(v))
+		for i, e := range v {
+			// Keep positions: a zero item is still an item.
+			out[i] = canonicalize(e)
+		}
+		return out
+	case string:
+		if v == "" {
+			return nil
+		}
+	case bool:
+		if !v {
+			return nil
+		}
+	case float64:
+		if v == 0 {
+			return nil
+		}
+	}
+	return v
+}
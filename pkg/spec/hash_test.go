@@ -0,0 +1,46 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanHash(t *testing.T) {
+	plan := ExecutionPlan{
+		Spec: "weekly",
+		Steps: []PlanStep{
+			{Command: "fs:read", Risk: "read-only", Params: map[string]any{"path": "a.md", "lines": 10}},
+			{Command: "fs:write", Risk: "write", CheckpointBefore: true},
+		},
+	}
+	hash, err := PlanHash(plan)
+	if err != nil {
+		t.Fatalf("PlanHash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "sha256:") || len(hash) != len("sha256:")+64 {
+		t.Errorf("hash = %q", hash)
+	}
+
+	// Unset and empty optional fields hash the same.
+	same := plan
+	same.Steps = []PlanStep{plan.Steps[0], plan.Steps[1]}
+	same.Steps[1].Needs = []string{}
+	same.Steps[1].With = map[string]string{}
+	same.AllowedCommands = []string{}
+	if h, _ := PlanHash(same); h != hash {
+		t.Errorf("hash with empty fields = %q, want %q", h, hash)
+	}
+
+	changed := plan
+	changed.Steps = []PlanStep{plan.Steps[0], plan.Steps[1]}
+	changed.Steps[1].Command = "fs:delete"
+	if h, _ := PlanHash(changed); h == hash {
+		t.Error("changed command hashes the same")
+	}
+
+	swapped := plan
+	swapped.Steps = []PlanStep{plan.Steps[1], plan.Steps[0]}
+	if h, _ := PlanHash(swapped); h == hash {
+		t.Error("reordered steps hash the same")
+	}
+}
@@ -0,0 +1,141 @@
+package spec
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// IntentTrace ties a run's goal to the steps taken for it and to the
+// checks of its success criteria, so a reviewer can see why each action
+// was taken and whether it achieved what it was for. It is written to the
+// run directory as intent.json.
+type IntentTrace struct {
+	Spec     string             `json:"spec"`
+	RunID    string             `json:"run_id,omitempty"`
+	Goal     string             `json:"goal"`
+	Created  time.Time          `json:"created"`
+	Steps    []StepIntent       `json:"steps"`
+	Criteria []CriterionOutcome `json:"criteria"`
+
+	// Achieved is whether every step succeeded and every success
+	// criterion passed.
+	Achieved bool `json:"achieved"`
+}
+
+// StepIntent is a plan step in an IntentTrace: what it was meant to do
+// and what it did.
+type StepIntent struct {
+	Step    string `json:"step"` // ID, or "<n> <command>"
+	Command string `json:"command"`
+	Intent  string `json:"intent"`
+	Risk    string `json:"risk"`
+
+	// Status is the step's result status ("ok", "error", "skipped",
+	// "verify_failed"), or "not_run" if the run ended before it.
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	Drift  []string `json:"drift,omitempty"`  // where it strayed from its declaration
+	Writes []string `json:"writes,omitempty"` // files it was seen writing
+
+	// Verified is the outcome of the step's own verification; nil when it
+	// has none.
+	Verified *bool `json:"verified,omitempty"`
+}
+
+// CriterionOutcome is a success criterion in an IntentTrace, with the
+// steps whose result it checked.
+type CriterionOutcome struct {
+	Type    string   `json:"type"`
+	Target  string   `json:"target,omitempty"`
+	Passed  bool     `json:"passed"`
+	Message string   `json:"message,omitempty"`
+	Steps   []string `json:"steps,omitempty"`
+}
+
+// StepNotRun is the status of a plan step the run did not reach.
+const StepNotRun = "not_run"
+
+// BuildIntentTrace traces plan through the step results of its run.
+// Criteria are added with AddCriterion.
+func BuildIntentTrace(plan ExecutionPlan, results []agshctx.StepResult) IntentTrace {
+	t := IntentTrace{
+		Spec:     plan.Spec,
+		Goal:     strings.TrimSpace(plan.Goal),
+		Created:  time.Now().UTC(),
+		Steps:    make([]StepIntent, len(plan.Steps)),
+		Criteria: []CriterionOutcome{},
+		Achieved: true,
+	}
+	byIndex := make(map[int]agshctx.StepResult, len(results))
+	for _, sr := range results {
+		byIndex[sr.Index] = sr
+	}
+	for i, step := range plan.Steps {
+		si := StepIntent{
+			Step:    planStepLabel(i, step),
+			Command: step.Command,
+			Intent:  step.Intent,
+			Risk:    step.Risk,
+			Status:  StepNotRun,
+		}
+		if sr, ok := byIndex[i]; ok {
+			si.Status = sr.Status
+			si.Error = sr.Error
+			si.Drift = sr.Drift
+			si.Writes = stepWrites(sr)
+			si.Verified = sr.VerifyPassed
+		}
+		if si.Status != "ok" && si.Status != "skipped" {
+			t.Achieved = false
+		}
+		t.Steps[i] = si
+	}
+	return t
+}
+
+// AddCriterion records the outcome of a success criterion, linking it to
+// the steps it checked: the step that wrote the output an "artifact."
+// target names, or the last step for the run's output.
+func (t *IntentTrace) AddCriterion(plan ExecutionPlan, typ, target string, passed bool, message string) {
+	c := CriterionOutcome{Type: typ, Target: target, Passed: passed, Message: message}
+	if name, ok := strings.CutPrefix(target, ArtifactTargetPrefix); ok {
+		for _, a := range plan.Output.Declared() {
+			if a.Name != name {
+				continue
+			}
+			path, _ := filepath.Abs(a.Path)
+			for _, si := range t.Steps {
+				if slices.Contains(si.Writes, path) {
+					c.Steps = append(c.Steps, si.Step)
+				}
+			}
+		}
+	} else if (target == "" || target == "output") && len(t.Steps) > 0 {
+		c.Steps = []string{t.Steps[len(t.Steps)-1].Step}
+	}
+	t.Criteria = append(t.Criteria, c)
+	t.Achieved = t.Achieved && passed
+}
+
+// stepWrites returns the files sr, or a step of the spec it used,
+// recorded writing.
+func stepWrites(sr agshctx.StepResult) []string {
+	writes := sr.Effects.Writes
+	for _, child := range sr.Children {
+		writes = append(slices.Clip(writes), stepWrites(child)...)
+	}
+	return writes
+}
+
+// planStepLabel names a plan step as stepLabel names its result.
+func planStepLabel(i int, step PlanStep) string {
+	if step.ID != "" {
+		return step.ID
+	}
+	return fmt.Sprintf("%d %s", i+1, step.Command)
+}
@@ -0,0 +1,73 @@
+package spec
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func TestBuildIntentTrace(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report.md")
+	plan := ExecutionPlan{
+		Spec: "weekly",
+		Goal: "  Summarize the week's PRs\n",
+		Steps: []PlanStep{
+			{ID: "prs", Command: "github:pr:list", Intent: "find merged PRs", Risk: "read-only"},
+			{Command: "spec:run", Intent: "write the report", Risk: "write"},
+			{Command: "mail:send", Intent: "send the report", Risk: "write"},
+		},
+		Output: OutputSpec{Path: report},
+	}
+	passed := true
+	results := []agshctx.StepResult{
+		{Index: 0, Status: "ok", VerifyPassed: &passed},
+		{Index: 1, Status: "ok", Drift: []string{"wrote outside its paths"}, Children: []agshctx.StepResult{
+			{Status: "ok", Effects: agshctx.Effects{Writes: []string{report}}},
+		}},
+	}
+
+	trace := BuildIntentTrace(plan, results)
+	if trace.Goal != "Summarize the week's PRs" || len(trace.Steps) != 3 {
+		t.Fatalf("trace = %+v", trace)
+	}
+	if s := trace.Steps[0]; s.Step != "prs" || s.Intent != "find merged PRs" || s.Verified == nil || !*s.Verified {
+		t.Errorf("step 1 = %+v", s)
+	}
+	if s := trace.Steps[1]; s.Step != "2 spec:run" || !slices.Contains(s.Writes, report) || len(s.Drift) != 1 {
+		t.Errorf("step 2 = %+v", s)
+	}
+	if s := trace.Steps[2]; s.Status != StepNotRun {
+		t.Errorf("step 3 status = %q, want %q", s.Status, StepNotRun)
+	}
+	if trace.Achieved {
+		t.Error("trace achieved with a step not run")
+	}
+
+	trace.AddCriterion(plan, "contains", "artifact.output", true, "")
+	trace.AddCriterion(plan, "not_empty", "output", false, "output is empty")
+	trace.AddCriterion(plan, "equals", "context.session.total", true, "")
+	if c := trace.Criteria[0]; !slices.Equal(c.Steps, []string{"2 spec:run"}) {
+		t.Errorf("artifact criterion steps = %v", c.Steps)
+	}
+	if c := trace.Criteria[1]; c.Passed || !slices.Equal(c.Steps, []string{"3 mail:send"}) {
+		t.Errorf("output criterion = %+v", c)
+	}
+	if c := trace.Criteria[2]; len(c.Steps) != 0 {
+		t.Errorf("context criterion steps = %v", c.Steps)
+	}
+}
+
+func TestIntentTraceAchieved(t *testing.T) {
+	plan := ExecutionPlan{Steps: []PlanStep{{Command: "fs:read"}, {Command: "fs:write"}}}
+	trace := BuildIntentTrace(plan, []agshctx.StepResult{{Index: 0, Status: "ok"}, {Index: 1, Status: "skipped"}})
+	trace.AddCriterion(plan, "not_empty", "", true, "")
+	if !trace.Achieved {
+		t.Errorf("trace = %+v, want achieved", trace)
+	}
+	trace.AddCriterion(plan, "contains", "", false, "")
+	if trace.Achieved {
+		t.Error("trace achieved with a failed criterion")
+	}
+}
@@ -1,62 +1,110 @@
 package spec
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 // LoadSpec reads a YAML spec file and returns a parsed ProjectSpec.
 // Template variables like {{date}} and {{param_name}} are interpolated
 // using the provided params (or defaults from the spec).
-func LoadSpec(path string, params map[string]string) (ProjectSpec, error) {
+func LoadSpec(path string, params map[string]string, opts ...LoadOption) (ProjectSpec, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return ProjectSpec{}, fmt.Errorf("read spec %s: %w", path, err)
 	}
 
-	return ParseSpec(data, params)
+	spec, err := ParseSpec(data, params, opts...)
+	if err != nil {
+		return spec, err
+	}
+	for i, step := range spec.Steps {
+		if step.Uses != "" && !filepath.IsAbs(step.Uses) {
+			spec.Steps[i].Uses = filepath.Join(filepath.Dir(path), step.Uses)
+		}
+	}
+	return spec, nil
 }
 
 // ParseSpec parses YAML data into a ProjectSpec with variable interpolation.
-func ParseSpec(data []byte, params map[string]string) (ProjectSpec, error) {
+// A spec in an older version is converted to the latest format, but keeps
+// its APIVersion; fields of another version than its own are reported as
+// a ValidationResult, as are file and dir params whose paths fail their
+// checks.
+func ParseSpec(data []byte, params map[string]string, opts ...LoadOption) (ProjectSpec, error) {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// First pass: parse to get param defaults.
-	var raw ProjectSpec
-	if err := yaml.Unmarshal(data, &raw); err != nil {
+	raw, err := decodeSpec(data, nil)
+	if err != nil {
 		return ProjectSpec{}, fmt.Errorf("parse spec: %w", err)
 	}
 
 	// Build interpolation map from param defaults + overrides.
 	vars := buildVarMap(raw.Params, params)
+	contents, err := checkPathParams(raw.Params, vars, o)
+	if err != nil {
+		return ProjectSpec{}, err
+	}
 
 	// Interpolate variables in the raw YAML.
-	interpolated := interpolateVars(string(data), vars)
+	interpolated, err := interpolateVars(string(data), vars)
+	if err != nil {
+		return ProjectSpec{}, fmt.Errorf("interpolate spec: %w", err)
+	}
 
-	// Second pass: parse the interpolated YAML.
-	var spec ProjectSpec
-	if err := yaml.Unmarshal([]byte(interpolated), &spec); err != nil {
+	// Second pass: parse the interpolated YAML, injecting file contents
+	// into its values.
+	spec, err := decodeSpec([]byte(interpolated), contents)
+	if err != nil {
 		return ProjectSpec{}, fmt.Errorf("parse interpolated spec: %w", err)
 	}
 
 	return spec, nil
 }
 
+// decodeSpec decodes a spec document of any supported version, with the
+// placeholders of contents replaced in its values.
+func decodeSpec(data []byte, contents map[string]string) (ProjectSpec, error) {
+	var spec ProjectSpec
+	doc, d, err := parseDocument(data)
+	if err != nil || len(doc.Content) == 0 {
+		return spec, err
+	}
+	if errs := d.versionErrors(); len(errs) > 0 {
+		return spec, ValidationResult{Errors: errs}
+	}
+	version := d.Version()
+	d.upgrade()
+	if len(contents) > 0 {
+		interpolateNodes(doc, contents)
+	}
+	if err := doc.Decode(&spec); err != nil {
+		return ProjectSpec{}, err
+	}
+	spec.APIVersion = version
+	return spec, nil
+}
+
 // buildVarMap creates a variable map from param defaults and runtime overrides.
-// Built-in variables like {{date}} are always available.
+// Built-in variables like {{date}} are always available; {{uuid}} is the
+// same throughout the spec.
 func buildVarMap(paramDefs []ParamDef, overrides map[string]string) map[string]string {
 	vars := make(map[string]string)
 
 	// Built-in variables.
-	now := time.Now()
-	vars["date"] = now.Format("2006-01-02")
-	vars["datetime"] = now.Format("2006-01-02T15:04:05")
-	vars["year"] = now.Format("2006")
-	vars["month"] = now.Format("01")
-	vars["day"] = now.Format("02")
+	t := now()
+	for _, name := range []string{"date", "datetime", "year", "month", "day"} {
+		vars[name] = dateFormats[name](t)
+	}
+	vars["uuid"] = newUUID()
 
 	// Param defaults.
 	for _, p := range paramDefs {
@@ -73,16 +121,34 @@ func buildVarMap(paramDefs []ParamDef, overrides map[string]string) map[string]s
 	return vars
 }
 
-// templatePattern matches {{var_name}} patterns.
-var templatePattern = regexp.MustCompile(`\{\{([A-Za-z_][A-Za-z0-9_]*)\}\}`)
-
-// interpolateVars replaces {{var_name}} patterns with values from the var map.
-func interpolateVars(s string, vars map[string]string) string {
-	return templatePattern.ReplaceAllStringFunc(s, func(match string) string {
-		varName := strings.TrimPrefix(strings.TrimSuffix(match, "}}"), "{{")
-		if val, ok := vars[varName]; ok {
+// templatePattern matches {{var_name}} and {{func arg ...}} patterns.
+var templatePattern = regexp.MustCompile(`\{\{([A-Za-z_][A-Za-z0-9_]*)((?:[ \t]+[^{}\s]+)*)[ \t]*\}\}`)
+
+// interpolateVars replaces {{var_name}} patterns with values from the var
+// map, and {{func arg ...}} patterns with the result of one of the
+// interpolationFuncs. Unknown names are left unresolved; a function
+// that fails is an error.
+func interpolateVars(s string, vars map[string]string) (string, error) {
+	var errs []error
+	out := templatePattern.ReplaceAllStringFunc(s, func(match string) string {
+		m := templatePattern.FindStringSubmatch(match)
+		name, args := m[1], strings.Fields(m[2])
+		if val, ok := vars[name]; ok && len(args) == 0 {
 			return val
 		}
-		return match // Leave unresolved.
+		fn, ok := interpolationFuncs[name]
+		if !ok {
+			return match // Leave unresolved.
+		}
+		val, err := fn(args, vars)
+		if errors.Is(err, errUnresolved) {
+			return match
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", match, err))
+			return match
+		}
+		return val
 	})
+	return out, errors.Join(errs...)
 }
@@ -148,6 +148,38 @@ params:
 	}
 }
 
+func TestLoadSpecResolvesUses(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "project.agsh.yaml")
+
+	yamlData := `
+apiVersion: agsh/v1
+kind: ProjectSpec
+meta:
+  name: "parent"
+goal: "Use other specs"
+steps:
+  - id: summary
+    uses: ./specs/summarize.agsh.yaml
+  - id: shared
+    uses: /etc/agsh/shared.agsh.yaml
+`
+	if err := os.WriteFile(path, []byte(yamlData), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := LoadSpec(path, nil)
+	if err != nil {
+		t.Fatalf("LoadSpec: %v", err)
+	}
+	if want := filepath.Join(dir, "specs", "summarize.agsh.yaml"); spec.Steps[0].Uses != want {
+		t.Errorf("Steps[0].Uses = %q, want %q", spec.Steps[0].Uses, want)
+	}
+	if spec.Steps[1].Uses != "/etc/agsh/shared.agsh.yaml" {
+		t.Errorf("Steps[1].Uses = %q, want it unchanged", spec.Steps[1].Uses)
+	}
+}
+
 func TestParseSpecInvalidYAML(t *testing.T) {
 	_, err := ParseSpec([]byte("{{{{invalid yaml"), nil)
 	if err == nil {
@@ -174,7 +206,10 @@ func TestInterpolateVars(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got := interpolateVars(tt.input, vars)
+		got, err := interpolateVars(tt.input, vars)
+		if err != nil {
+			t.Errorf("interpolateVars(%q): %v", tt.input, err)
+		}
 		if got != tt.want {
 			t.Errorf("interpolateVars(%q) = %q, want %q", tt.input, got, tt.want)
 		}
@@ -0,0 +1,111 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cgast/agsh/internal/digest"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// ArtifactTargetPrefix starts the success criteria targets that check the
+// content of a declared output, as in "artifact.report".
+const ArtifactTargetPrefix = "artifact."
+
+// Manifest lists the outputs a run produced, so downstream systems can
+// pick them up and check they are the ones the run verified. It is
+// written to the run directory as manifest.json.
+type Manifest struct {
+	Spec      string             `json:"spec"`
+	RunID     string             `json:"run_id,omitempty"`
+	Created   time.Time          `json:"created"`
+	Artifacts []ManifestArtifact `json:"artifacts"`
+}
+
+// ManifestArtifact is one output in a Manifest.
+type ManifestArtifact struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"` // absolute
+	Format  string `json:"format,omitempty"`
+	Missing bool   `json:"missing,omitempty"` // the run did not produce it
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256,omitempty"`
+
+	// Step is the step that wrote the output, by ID or as "<n>
+	// <command>"; empty if none recorded writing it.
+	Step string `json:"step,omitempty"`
+
+	// Verified is whether the success criteria that target the output
+	// passed; nil when none target it.
+	Verified *bool `json:"verified,omitempty"`
+}
+
+// BuildManifest describes the outputs out declares as a run with the
+// given step results left them.
+func BuildManifest(out OutputSpec, steps []agshctx.StepResult) (Manifest, error) {
+	m := Manifest{Created: time.Now().UTC(), Artifacts: []ManifestArtifact{}}
+	for _, decl := range out.Declared() {
+		path, err := filepath.Abs(decl.Path)
+		if err != nil {
+			return m, fmt.Errorf("output %s: %w", decl.Name, err)
+		}
+		a := ManifestArtifact{Name: decl.Name, Path: path, Format: decl.Format}
+		f, err := os.Open(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			a.Missing = true
+		case err != nil:
+			return m, fmt.Errorf("output %s: %w", decl.Name, err)
+		default:
+			a.SHA256, a.Size, err = digest.Sum("sha256", f)
+			f.Close()
+			if err != nil {
+				return m, fmt.Errorf("output %s: %w", decl.Name, err)
+			}
+		}
+		for _, sr := range steps {
+			if stepWrote(sr, path) {
+				a.Step = stepLabel(sr)
+			}
+		}
+		m.Artifacts = append(m.Artifacts, a)
+	}
+	return m, nil
+}
+
+// MarkVerified records in m the outcome of a success criterion with the
+// given target; targets other than "artifact.<name>" are ignored. An
+// output is verified when every criterion targeting it passed.
+func (m *Manifest) MarkVerified(target string, passed bool) {
+	name, ok := strings.CutPrefix(target, ArtifactTargetPrefix)
+	if !ok {
+		return
+	}
+	for i := range m.Artifacts {
+		a := &m.Artifacts[i]
+		if a.Name == name {
+			verified := passed && (a.Verified == nil || *a.Verified)
+			a.Verified = &verified
+		}
+	}
+}
+
+// stepWrote reports whether sr, or a step of the spec it used, recorded
+// writing path.
+func stepWrote(sr agshctx.StepResult, path string) bool {
+	return slices.Contains(sr.Effects.Writes, path) ||
+		slices.ContainsFunc(sr.Children, func(child agshctx.StepResult) bool { return stepWrote(child, path) })
+}
+
+func stepLabel(sr agshctx.StepResult) string {
+	if sr.Step.ID != "" {
+		return sr.Step.ID
+	}
+	return fmt.Sprintf("%d %s", sr.Index+1, sr.Step.Command)
+}
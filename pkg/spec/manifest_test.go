@@ -0,0 +1,72 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func TestBuildManifest(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.md")
+	if err := os.WriteFile(report, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := OutputSpec{
+		Path:      report,
+		Format:    "markdown",
+		Artifacts: []OutputArtifact{{Name: "data", Path: filepath.Join(dir, "data.json"), Format: "json"}},
+	}
+	steps := []agshctx.StepResult{
+		{Index: 0, Step: agshctx.PipelineStep{Command: "github:pr:list"}},
+		{Index: 1, Step: agshctx.PipelineStep{Command: "spec:run"}, Children: []agshctx.StepResult{
+			{Step: agshctx.PipelineStep{Command: "fs:write"}, Effects: agshctx.Effects{Writes: []string{report}}},
+		}},
+	}
+
+	m, err := BuildManifest(out, steps)
+	if err != nil {
+		t.Fatalf("BuildManifest: %v", err)
+	}
+	if len(m.Artifacts) != 2 {
+		t.Fatalf("artifacts = %+v", m.Artifacts)
+	}
+	primary := m.Artifacts[0]
+	if primary.Name != MainOutput || primary.Path != report || primary.Size != 4 || primary.Missing || primary.Step != "2 spec:run" {
+		t.Errorf("main output = %+v", primary)
+	}
+	if want := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"; primary.SHA256 != want {
+		t.Errorf("sha256 = %s, want %s", primary.SHA256, want)
+	}
+	if data := m.Artifacts[1]; data.Name != "data" || !data.Missing || data.SHA256 != "" {
+		t.Errorf("missing output = %+v", data)
+	}
+
+	m.MarkVerified("artifact.output", true)
+	m.MarkVerified("artifact.output", false)
+	m.MarkVerified("context.session.x", true)
+	if v := m.Artifacts[0].Verified; v == nil || *v {
+		t.Errorf("verified = %v, want false after a failed criterion", v)
+	}
+	if m.Artifacts[1].Verified != nil {
+		t.Error("output no criterion targets should not be marked")
+	}
+}
+
+func TestValidateSpecOutputs(t *testing.T) {
+	spec := validSpec()
+	spec.Output = OutputSpec{Path: "report.md", Artifacts: []OutputArtifact{{Name: "data", Path: "data.json"}}}
+	spec.SuccessCriteria = []Assertion{{Type: "not_empty", Target: "artifact.data"}}
+	if result := ValidateSpec(spec); !result.Valid() {
+		t.Errorf("expected outputs to be valid, got: %s", result.Error())
+	}
+
+	spec.Output.Artifacts = []OutputArtifact{{Name: "output", Path: "other.md"}, {Path: ""}}
+	result := ValidateSpec(spec)
+	assertHasFieldError(t, result, "output.artifacts[0].name")
+	assertHasFieldError(t, result, "output.artifacts[1].name")
+	assertHasFieldError(t, result, "output.artifacts[1].path")
+	assertHasFieldError(t, result, "success_criteria[0].target")
+}
@@ -0,0 +1,124 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Param types whose values are paths. They are checked as the spec is
+// loaded, so a wrong input path fails the run before it starts rather
+// than halfway through. Relative paths are relative to the working
+// directory, as they are for the commands the run executes.
+const (
+	ParamTypeFile = "file"
+	ParamTypeDir  = "dir"
+)
+
+// maxParamContent bounds the size of a file a content param injects.
+const maxParamContent = 1 << 20
+
+// LoadOption configures how a spec is loaded.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	checkPath func(path string) error
+}
+
+// WithPathCheck has the values of file and dir params checked with check,
+// such as a sandbox's CheckPath, besides being checked to exist.
+func WithPathCheck(check func(path string) error) LoadOption {
+	return func(o *loadOptions) {
+		o.checkPath = check
+	}
+}
+
+// checkPathParams checks the values of the file and dir params in vars;
+// params without a value are left to fail where they are used. The
+// params that inject a file's content get a placeholder in vars, and the
+// contents are returned by placeholder, for interpolateNodes.
+func checkPathParams(defs []ParamDef, vars map[string]string, o loadOptions) (map[string]string, error) {
+	var errs []ValidationError
+	contents := make(map[string]string)
+	for i, p := range defs {
+		path := vars[p.Name]
+		if (p.Type != ParamTypeFile && p.Type != ParamTypeDir) || path == "" {
+			continue
+		}
+		content, err := checkPathParam(p, path, o)
+		if err != nil {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("params[%d]", i),
+				Message: fmt.Sprintf("%s: %v", p.Name, err),
+			})
+			continue
+		}
+		if p.Content {
+			placeholder := "__agsh_content_" + p.Name + "__"
+			contents[placeholder] = content
+			vars[p.Name] = placeholder
+		}
+	}
+	if len(errs) > 0 {
+		return nil, ValidationResult{Errors: errs}
+	}
+	return contents, nil
+}
+
+// checkPathParam checks the path a param names and, for a content param,
+// reads the file.
+func checkPathParam(p ParamDef, path string, o loadOptions) (string, error) {
+	if o.checkPath != nil {
+		if err := o.checkPath(path); err != nil {
+			return "", err
+		}
+	}
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("%s does not exist", path)
+	case err != nil:
+		return "", err
+	case p.Type == ParamTypeDir && !info.IsDir():
+		return "", fmt.Errorf("%s is not a directory", path)
+	case p.Type == ParamTypeFile && info.IsDir():
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	if !p.Content {
+		return "", nil
+	}
+	if info.Size() > maxParamContent {
+		return "", fmt.Errorf("%s is too large to inject (%d bytes, at most %d)", path, info.Size(), maxParamContent)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// interpolateNodes replaces the placeholders in the scalars of a parsed
+// document with their contents. Unlike interpolation of the text, this
+// keeps contents with newlines or quotes from changing the document's
+// structure.
+func interpolateNodes(n *yaml.Node, contents map[string]string) {
+	pairs := make([]string, 0, 2*len(contents))
+	for placeholder, content := range contents {
+		pairs = append(pairs, placeholder, content)
+	}
+	replaceScalars(n, strings.NewReplacer(pairs...))
+}
+
+func replaceScalars(n *yaml.Node, r *strings.Replacer) {
+	if n.Kind == yaml.ScalarNode {
+		n.Value = r.Replace(n.Value)
+		return
+	}
+	for _, c := range n.Content {
+		replaceScalars(c, r)
+	}
+}
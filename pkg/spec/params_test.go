@@ -0,0 +1,113 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const pathParamsSpec = `
+apiVersion: agsh/v2
+kind: ProjectSpec
+metadata:
+  name: paths
+goal: Summarize {{notes}}
+allowed_commands: ["fs:write"]
+params:
+  - name: notes
+    type: file
+  - name: body
+    type: file
+    content: true
+  - name: out
+    type: dir
+steps:
+  - command: fs:write
+    input:
+      path: "{{out}}/summary.md"
+      content: "{{body}}"
+      header: "Notes: {{body}}"
+`
+
+func TestParseSpecPathParams(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.md")
+	content := "# Notes\n\nkey: \"value\", {braces}\n"
+	if err := os.WriteFile(notes, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]string{"notes": notes, "body": notes, "out": dir}
+
+	spec, err := ParseSpec([]byte(pathParamsSpec), params)
+	if err != nil {
+		t.Fatalf("ParseSpec: %v", err)
+	}
+	if want := "Summarize " + notes; spec.Goal != want {
+		t.Errorf("Goal = %q, want %q", spec.Goal, want)
+	}
+	input := spec.Steps[0].Params
+	if input["path"] != dir+"/summary.md" {
+		t.Errorf("path = %v", input["path"])
+	}
+	if input["content"] != content {
+		t.Errorf("content = %q, want the file's content", input["content"])
+	}
+	if input["header"] != "Notes: "+content {
+		t.Errorf("header = %q", input["header"])
+	}
+}
+
+func TestParseSpecPathParamsChecked(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(file, []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.md")
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		opts   []LoadOption
+		want   string // in the error; "" for none
+	}{
+		{"unset", nil, nil, ""},
+		{"missing file", map[string]string{"notes": missing}, nil, "notes: " + missing + " does not exist"},
+		{"dir for file", map[string]string{"notes": dir}, nil, "is a directory"},
+		{"file for dir", map[string]string{"out": file}, nil, "is not a directory"},
+		{"content of missing file", map[string]string{"body": missing}, nil, "params[1]: body:"},
+		{"path check", map[string]string{"notes": file}, []LoadOption{WithPathCheck(func(path string) error {
+			return fmt.Errorf("%s is outside the sandbox", path)
+		})}, "outside the sandbox"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpec([]byte(pathParamsSpec), tt.params, tt.opts...)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("ParseSpec: %v", err)
+				}
+				return
+			}
+			var vr ValidationResult
+			if !errors.As(err, &vr) {
+				t.Fatalf("err = %v, want a ValidationResult", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSpecParamContent(t *testing.T) {
+	spec := validSpec()
+	spec.Params = []ParamDef{{Name: "topic", Type: "string", Content: true}}
+	vr := ValidateSpec(spec)
+	if len(vr.Errors) != 1 || vr.Errors[0].Field != "params[0].content" {
+		t.Errorf("errors = %v, want params[0].content", vr.Errors)
+	}
+}
@@ -1,8 +1,11 @@
 package spec
 
 import (
+	"cmp"
 	"fmt"
 	"strings"
+
+	"github.com/cgast/agsh/internal/glob"
 )
 
 // CommandLister provides the list of available commands for plan validation.
@@ -15,23 +18,45 @@ type CommandLister interface {
 // ExecutionPlan is the concrete plan generated from a ProjectSpec.
 type ExecutionPlan struct {
 	Spec            string        `json:"spec"`
+	Goal            string        `json:"goal,omitempty"`
 	Steps           []PlanStep    `json:"steps"`
 	EstimatedRisk   string        `json:"risk_summary"`
 	AllowedCommands []string      `json:"allowed_commands"`
 	SuccessCriteria []Assertion   `json:"success_criteria,omitempty"`
 	Output          OutputSpec    `json:"output"`
+	OnVerifyFailure string        `json:"on_verify_failure,omitempty"` // "stop", "rollback"
+	IntentDrift     string        `json:"intent_drift"`                // "off", "warn", "block"
+	Limits          Limits        `json:"limits"`
+	Sandbox         SpecSandbox   `json:"sandbox"`
 }
 
 // PlanStep is a single step in an execution plan.
 type PlanStep struct {
+	ID               string   `json:"id,omitempty"`
 	Command          string   `json:"command"`
 	Args             []string `json:"args,omitempty"`
 	Intent           string   `json:"intent"`
 	Risk             string   `json:"risk"`                        // "read-only", "write", "destructive"
 	CheckpointBefore bool     `json:"checkpoint_before,omitempty"`
 	OnError          string   `json:"on_error"`                    // "stop", "skip", "retry"
+	OnVerifyFailure  string   `json:"on_verify_failure,omitempty"` // "stop", "rollback"
+
+	// Set for steps declared in the spec; see StepDef.
+	VerifyOutputSchema bool              `json:"verify_output_schema,omitempty"`
+	Params             map[string]any    `json:"params,omitempty"`
+	Needs              []string          `json:"needs,omitempty"`
+	Inputs             map[string]string `json:"inputs,omitempty"`
+	Uses               string            `json:"uses,omitempty"`
+	With               map[string]string `json:"with,omitempty"`
+	Ask                *AskDef           `json:"ask,omitempty"`
 }
 
+// Command names of plan steps that run no platform command.
+const (
+	UsesCommand = "spec:run"  // steps that use another spec
+	AskCommand  = "human:ask" // steps that ask the operator
+)
+
 // GeneratePlan produces an ExecutionPlan from a validated ProjectSpec.
 // The plan is a structured preview of what will be executed, suitable for
 // human review before execution.
@@ -49,17 +74,32 @@ func GeneratePlan(spec ProjectSpec, lister CommandLister) (ExecutionPlan, error)
 	reads, writes := classifyCommands(available)
 
 	// Build plan steps.
-	steps := buildSteps(spec, reads, writes)
-
+	var steps []PlanStep
 	riskSummary := fmt.Sprintf("%d read-only, %d write operations", len(reads), len(writes))
+	if len(spec.Steps) > 0 {
+		rc, _ := lister.(RiskClassifier)
+		steps = declaredSteps(spec, rc)
+		riskSummary = RiskSummary(steps)
+	} else {
+		steps = buildSteps(spec, reads, writes)
+	}
+	if n := spec.Limits.MaxSteps; n > 0 && len(steps) > n {
+		return ExecutionPlan{}, fmt.Errorf("plan has %d steps, more than limits.max_steps (%d)", len(steps), n)
+	}
+	riskSummary += Confinement(spec.Limits, spec.Sandbox)
 
 	return ExecutionPlan{
 		Spec:            spec.Meta.Name,
+		Goal:            spec.Goal,
 		Steps:           steps,
 		EstimatedRisk:   riskSummary,
 		AllowedCommands: available,
 		SuccessCriteria: spec.SuccessCriteria,
 		Output:          spec.Output,
+		OnVerifyFailure: spec.OnVerifyFailure,
+		IntentDrift:     cmp.Or(spec.IntentDrift, "warn"),
+		Limits:          spec.Limits,
+		Sandbox:         spec.Sandbox,
 	}, nil
 }
 
@@ -75,7 +115,7 @@ func resolveAllowedCommands(patterns []string, lister CommandLister) []string {
 	var result []string
 
 	for _, pattern := range patterns {
-		if strings.Contains(pattern, "*") {
+		if glob.IsPattern(pattern) {
 			for _, name := range lister.MatchGlob(pattern) {
 				if !seen[name] {
 					seen[name] = true
@@ -106,7 +146,7 @@ func classifyCommands(commands []string) (reads, writes []string) {
 }
 
 // isWriteCommand determines if a command is a write operation based on naming.
-var writeVerbs = []string{"write", "create", "delete", "update", "post", "put", "patch"}
+var writeVerbs = []string{"write", "create", "delete", "update", "post", "put", "patch", "comment", "send", "zip", "download"}
 
 func isWriteCommand(name string) bool {
 	lower := strings.ToLower(name)
@@ -118,6 +158,29 @@ func isWriteCommand(name string) bool {
 	return false
 }
 
+// RiskClassifier is implemented by command listers that know the risk of
+// a command's input, such as a github:graphql mutation, beyond its name.
+type RiskClassifier interface {
+	// CommandRisk returns the risk of running name on payload, or "" when
+	// the payload does not decide it.
+	CommandRisk(name string, payload any) string
+}
+
+// CommandRisk classifies a command as the planner does: the risk rc gives
+// its payload, if any, else "write" when the name has a write verb,
+// otherwise "read-only". rc may be nil.
+func CommandRisk(name string, payload any, rc RiskClassifier) string {
+	if rc != nil {
+		if risk := rc.CommandRisk(name, payload); risk != "" {
+			return risk
+		}
+	}
+	if isWriteCommand(name) {
+		return "write"
+	}
+	return "read-only"
+}
+
 // buildSteps creates plan steps from the spec's goal and allowed commands.
 // The planner uses heuristics based on the spec structure to produce a
 // reasonable execution plan.
@@ -142,6 +205,7 @@ func buildSteps(spec ProjectSpec, reads, writes []string) []PlanStep {
 			Risk:             "write",
 			CheckpointBefore: true,
 			OnError:          "stop",
+			OnVerifyFailure:  spec.OnVerifyFailure,
 		}
 
 		// If output path is specified, add it as an arg for fs:write.
@@ -155,3 +219,100 @@ func buildSteps(spec ProjectSpec, reads, writes []string) []PlanStep {
 
 	return steps
 }
+
+// RiskSummary counts the read-only and write steps of a plan.
+func RiskSummary(steps []PlanStep) string {
+	reads := 0
+	for _, step := range steps {
+		if step.Risk == "read-only" {
+			reads++
+		}
+	}
+	return fmt.Sprintf("%d read-only, %d write operations", reads, len(steps)-reads)
+}
+
+// Confinement describes the sandbox and network a spec narrows its run
+// to, for the end of its plan's risk summary, such as "; sandbox:
+// ./workspace (max 1MB); network: none". It is empty when the spec sets
+// neither.
+func Confinement(limits Limits, sb SpecSandbox) string {
+	var b strings.Builder
+	if !sb.IsZero() {
+		var parts []string
+		if len(sb.AllowedPaths) > 0 {
+			parts = append(parts, strings.Join(sb.AllowedPaths, ", "))
+		}
+		if len(sb.DeniedPaths) > 0 {
+			parts = append(parts, "not "+strings.Join(sb.DeniedPaths, ", "))
+		}
+		if sb.MaxFileSize != "" {
+			parts = append(parts, "(max "+sb.MaxFileSize+")")
+		}
+		b.WriteString("; sandbox: " + strings.Join(parts, " "))
+	}
+	switch {
+	case limits.NoNetwork:
+		b.WriteString("; network: none")
+	case len(limits.AllowedDomains) > 0:
+		b.WriteString("; network: " + strings.Join(limits.AllowedDomains, ", "))
+	}
+	return b.String()
+}
+
+// declaredSteps creates plan steps from the spec's explicit steps, with
+// the risk CommandRisk gives their command and params. Write and
+// destructive steps are checkpointed, as in buildSteps. A step that uses
+// another spec is treated as a write until the caller plans that spec.
+func declaredSteps(spec ProjectSpec, rc RiskClassifier) []PlanStep {
+	steps := make([]PlanStep, len(spec.Steps))
+	for i, def := range spec.Steps {
+		step := PlanStep{
+			ID:      def.ID,
+			Command: def.Command,
+			Args:    def.Args,
+			Intent:  def.Intent,
+			Risk:    "read-only",
+			OnError: def.OnError,
+			Params:  def.Params,
+			Needs:   def.Needs,
+			Inputs:  def.Inputs,
+			Uses:    def.Uses,
+			With:    def.With,
+			Ask:     def.Ask,
+
+			VerifyOutputSchema: def.VerifyOutputSchema,
+		}
+		switch {
+		case def.Uses != "":
+			step.Command = UsesCommand
+			step.Args = []string{def.Uses}
+		case def.Ask != nil:
+			step.Command = AskCommand
+		}
+		if step.Intent == "" {
+			switch {
+			case def.Uses != "":
+				step.Intent = fmt.Sprintf("Run the spec %s", def.Uses)
+			case def.Ask != nil:
+				step.Intent = fmt.Sprintf("Ask the operator: %s", def.Ask.Prompt)
+			default:
+				step.Intent = fmt.Sprintf("Run %s", step.Command)
+			}
+		}
+		if step.OnError == "" {
+			step.OnError = "stop"
+		}
+		switch {
+		case def.Uses != "":
+			step.Risk = "write"
+		case def.Ask == nil:
+			step.Risk = CommandRisk(def.Command, def.Params, rc)
+		}
+		if step.Risk != "read-only" {
+			step.CheckpointBefore = true
+			step.OnVerifyFailure = spec.OnVerifyFailure
+		}
+		steps[i] = step
+	}
+	return steps
+}
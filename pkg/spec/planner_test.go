@@ -3,6 +3,8 @@ package spec
 import (
 	"strings"
 	"testing"
+
+	"github.com/cgast/agsh/internal/glob"
 )
 
 // mockLister implements CommandLister for testing.
@@ -13,13 +15,9 @@ type mockLister struct {
 func (m *mockLister) Names() []string { return m.names }
 
 func (m *mockLister) MatchGlob(pattern string) []string {
-	if pattern == "*" {
-		return m.names
-	}
-	prefix := strings.TrimSuffix(pattern, "*")
 	var result []string
 	for _, name := range m.names {
-		if strings.HasPrefix(name, prefix) {
+		if glob.Match(pattern, name) {
 			result = append(result, name)
 		}
 	}
@@ -54,6 +52,9 @@ func TestGeneratePlan(t *testing.T) {
 	if plan.Spec != "test-plan" {
 		t.Errorf("Spec = %q", plan.Spec)
 	}
+	if plan.IntentDrift != "warn" {
+		t.Errorf("IntentDrift = %q, want the warn default", plan.IntentDrift)
+	}
 
 	if len(plan.Steps) == 0 {
 		t.Fatal("expected at least one step")
@@ -125,6 +126,96 @@ func TestGeneratePlanNilLister(t *testing.T) {
 	}
 }
 
+func TestGeneratePlanDeclaredSteps(t *testing.T) {
+	spec := ProjectSpec{
+		APIVersion:      "agsh/v1",
+		Kind:            "ProjectSpec",
+		Meta:            SpecMeta{Name: "join"},
+		Goal:            "Report PRs and issues",
+		AllowedCommands: []string{"github:*", "fs:write"},
+		Steps: []StepDef{
+			{ID: "prs", Command: "github:pr:list"},
+			{ID: "issues", Command: "github:issue:list"},
+			{ID: "write", Command: "fs:write", Args: []string{"report.md"},
+				Inputs: map[string]string{"prs": "prs.output", "issues": "issues.output"}},
+		},
+	}
+	plan, err := GeneratePlan(spec, &mockLister{names: []string{"github:pr:list", "github:issue:list", "github:repo:info", "fs:write"}})
+	if err != nil {
+		t.Fatalf("GeneratePlan: %v", err)
+	}
+	if len(plan.Steps) != 3 {
+		t.Fatalf("expected the 3 declared steps, got %d", len(plan.Steps))
+	}
+	write := plan.Steps[2]
+	if write.ID != "write" || write.Risk != "write" || !write.CheckpointBefore || write.OnError != "stop" || len(write.Inputs) != 2 {
+		t.Errorf("write step = %+v", write)
+	}
+	if plan.Steps[0].Intent != "Run github:pr:list" {
+		t.Errorf("default intent = %q", plan.Steps[0].Intent)
+	}
+	if plan.EstimatedRisk != "2 read-only, 1 write operations" {
+		t.Errorf("EstimatedRisk = %q", plan.EstimatedRisk)
+	}
+
+	spec.Steps = []StepDef{{ID: "summary", Uses: "specs/summarize.agsh.yaml", With: map[string]string{"days": "7"}}}
+	plan, err = GeneratePlan(spec, nil)
+	if err != nil {
+		t.Fatalf("GeneratePlan: %v", err)
+	}
+	uses := plan.Steps[0]
+	if uses.Command != UsesCommand || uses.Uses != "specs/summarize.agsh.yaml" || uses.With["days"] != "7" || uses.Risk != "write" {
+		t.Errorf("uses step = %+v", uses)
+	}
+
+	spec.Steps = []StepDef{{ID: "confirm", Ask: &AskDef{Prompt: "Publish the report?"}}}
+	plan, err = GeneratePlan(spec, nil)
+	if err != nil {
+		t.Fatalf("GeneratePlan: %v", err)
+	}
+	ask := plan.Steps[0]
+	if ask.Command != AskCommand || ask.Risk != "read-only" || ask.Intent != "Ask the operator: Publish the report?" {
+		t.Errorf("ask step = %+v", ask)
+	}
+}
+
+func TestGeneratePlanConfinement(t *testing.T) {
+	spec := ProjectSpec{
+		APIVersion:      "agsh/v2",
+		Kind:            "ProjectSpec",
+		Meta:            SpecMeta{Name: "confined"},
+		Goal:            "Write a report",
+		AllowedCommands: []string{"fs:write"},
+		Steps:           []StepDef{{Command: "fs:write"}, {Command: "fs:write"}},
+		Limits:          Limits{NoNetwork: true, MaxSteps: 2},
+		Sandbox:         SpecSandbox{AllowedPaths: []string{"./workspace", "./reports"}, MaxFileSize: "1MB"},
+	}
+	plan, err := GeneratePlan(spec, nil)
+	if err != nil {
+		t.Fatalf("GeneratePlan: %v", err)
+	}
+	if want := "0 read-only, 2 write operations; sandbox: ./workspace, ./reports (max 1MB); network: none"; plan.EstimatedRisk != want {
+		t.Errorf("EstimatedRisk = %q, want %q", plan.EstimatedRisk, want)
+	}
+	if len(plan.Sandbox.AllowedPaths) != 2 {
+		t.Errorf("plan sandbox = %+v", plan.Sandbox)
+	}
+
+	spec.Limits = Limits{AllowedDomains: []string{"api.github.com"}, MaxSteps: 1}
+	if _, err := GeneratePlan(spec, nil); err == nil || !strings.Contains(err.Error(), "limits.max_steps") {
+		t.Errorf("err = %v, want too many steps", err)
+	}
+	spec.Limits.MaxSteps = 0
+	spec.Sandbox = SpecSandbox{}
+	plan, err = GeneratePlan(spec, nil)
+	if err != nil {
+		t.Fatalf("GeneratePlan: %v", err)
+	}
+	if !strings.HasSuffix(plan.EstimatedRisk, "; network: api.github.com") {
+		t.Errorf("EstimatedRisk = %q", plan.EstimatedRisk)
+	}
+}
+
 func TestGeneratePlanGitHubReport(t *testing.T) {
 	spec := ProjectSpec{
 		APIVersion: "agsh/v1",
@@ -216,6 +307,11 @@ func TestIsWriteCommand(t *testing.T) {
 		{"github:issue:create", true},
 		{"http:get", false},
 		{"http:post", true},
+		{"jira:issue:comment", true},
+		{"mail:send", true},
+		{"fs:zip", true},
+		{"fs:unzip", true},
+		{"http:download", true},
 	}
 
 	for _, tt := range tests {
@@ -226,6 +322,66 @@ func TestIsWriteCommand(t *testing.T) {
 	}
 }
 
+// riskLister is a mockLister that classifies github:graphql mutations as
+// destructive.
+type riskLister struct {
+	mockLister
+}
+
+func (r *riskLister) CommandRisk(name string, payload any) string {
+	params, _ := payload.(map[string]any)
+	if query, _ := params["query"].(string); name == "github:graphql" && strings.HasPrefix(query, "mutation") {
+		return "destructive"
+	}
+	return ""
+}
+
+func TestCommandRisk(t *testing.T) {
+	mutation := map[string]any{"query": "mutation { addStar }"}
+	tests := []struct {
+		name    string
+		payload any
+		rc      RiskClassifier
+		want    string
+	}{
+		{"fs:read", nil, nil, "read-only"},
+		{"fs:write", nil, nil, "write"},
+		{"github:graphql", mutation, nil, "read-only"},
+		{"github:graphql", mutation, &riskLister{}, "destructive"},
+		{"github:graphql", map[string]any{"query": "{ viewer }"}, &riskLister{}, "read-only"},
+		{"fs:write", nil, &riskLister{}, "write"},
+	}
+	for _, tt := range tests {
+		if got := CommandRisk(tt.name, tt.payload, tt.rc); got != tt.want {
+			t.Errorf("CommandRisk(%q, %v) = %q, want %q", tt.name, tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratePlanClassifiesParams(t *testing.T) {
+	spec := ProjectSpec{
+		APIVersion:      "agsh/v1",
+		Kind:            "ProjectSpec",
+		Meta:            SpecMeta{Name: "star"},
+		Goal:            "Star the repo",
+		AllowedCommands: []string{"github:graphql"},
+		Steps: []StepDef{
+			{ID: "who", Command: "github:graphql", Params: map[string]any{"query": "{ viewer { login } }"}},
+			{ID: "star", Command: "github:graphql", Params: map[string]any{"query": "mutation { addStar }", "allow_mutation": true}},
+		},
+	}
+	plan, err := GeneratePlan(spec, &riskLister{mockLister{names: []string{"github:graphql"}}})
+	if err != nil {
+		t.Fatalf("GeneratePlan: %v", err)
+	}
+	if who := plan.Steps[0]; who.Risk != "read-only" || who.CheckpointBefore {
+		t.Errorf("query step = %+v", who)
+	}
+	if star := plan.Steps[1]; star.Risk != "destructive" || !star.CheckpointBefore {
+		t.Errorf("mutation step = %+v", star)
+	}
+}
+
 func TestClassifyCommands(t *testing.T) {
 	commands := []string{"fs:list", "fs:read", "fs:write", "github:pr:list", "github:issue:create"}
 	reads, writes := classifyCommands(commands)
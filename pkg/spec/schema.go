@@ -0,0 +1,119 @@
+package spec
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// SchemaID identifies the JSON Schema of the spec format.
+const SchemaID = "https://github.com/cgast/agsh/schema/project-spec.json"
+
+// schemaRequired lists the fields the validator requires, by struct.
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeFor[ProjectSpec](): {"apiVersion", "kind", "metadata", "goal"},
+	reflect.TypeFor[SpecMeta]():    {"name"},
+	reflect.TypeFor[Assertion]():   {"type"},
+	reflect.TypeFor[ParamDef]():    {"name"},
+	reflect.TypeFor[AskDef]():      {"prompt"},
+}
+
+// schemaEnums lists the values of the enumerated fields, by struct and
+// YAML field name.
+func schemaEnums() map[reflect.Type]map[string][]string {
+	assertionTypes := slices.Sorted(maps.Keys(validAssertionTypes))
+	return map[reflect.Type]map[string][]string{
+		reflect.TypeFor[ProjectSpec](): {
+			"apiVersion":        {LatestAPIVersion},
+			"kind":              {"ProjectSpec"},
+			"on_verify_failure": onVerifyFailureValues,
+			"intent_drift":      intentDriftValues,
+		},
+		reflect.TypeFor[Limits]():    {"on_timeout": onTimeoutValues},
+		reflect.TypeFor[StepDef]():   {"on_error": onErrorValues},
+		reflect.TypeFor[Assertion](): {"type": assertionTypes},
+	}
+}
+
+// JSONSchema returns a JSON Schema of spec documents in the latest
+// version, for editors to complete and check .agsh.yaml files with. It
+// is generated from ProjectSpec's YAML fields, so it follows the structs
+// as they change.
+func JSONSchema() map[string]any {
+	g := schemaGenerator{defs: map[string]any{}, enums: schemaEnums()}
+	root := g.object(reflect.TypeFor[ProjectSpec]())
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = SchemaID
+	root["title"] = "agsh project spec (" + LatestAPIVersion + ")"
+	root["$defs"] = g.defs
+	return root
+}
+
+type schemaGenerator struct {
+	defs  map[string]any
+	enums map[reflect.Type]map[string][]string
+}
+
+// schemaFor returns the schema of a field of type t. Structs other than
+// ProjectSpec are defined once under $defs and referred to.
+func (g schemaGenerator) schemaFor(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		s := map[string]any{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			s["additionalProperties"] = g.schemaFor(t.Elem())
+		}
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{} // any value
+	}
+}
+
+// object returns the schema of struct t, whose properties are its YAML
+// fields.
+func (g schemaGenerator) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		s := g.schemaFor(f.Type)
+		if values, ok := g.enums[t][name]; ok {
+			s["enum"] = values
+		}
+		props[name] = s
+	}
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if required, ok := schemaRequired[t]; ok {
+		s["required"] = required
+	}
+	return s
+}
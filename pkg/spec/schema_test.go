@@ -0,0 +1,129 @@
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestJSONSchema(t *testing.T) {
+	data, err := json.Marshal(JSONSchema())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	props := schema["properties"].(map[string]any)
+	for _, name := range []string{"apiVersion", "kind", "metadata", "goal", "success_criteria", "steps", "limits", "intent_drift"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("property %s missing", name)
+		}
+	}
+	if _, ok := props["meta"]; ok {
+		t.Error("agsh/v1 property meta in the schema")
+	}
+	if got := props["apiVersion"].(map[string]any)["enum"]; !jsonEqual(got, []any{LatestAPIVersion}) {
+		t.Errorf("apiVersion enum = %v", got)
+	}
+
+	defs := schema["$defs"].(map[string]any)
+	step := defs["StepDef"].(map[string]any)["properties"].(map[string]any)
+	if _, ok := step["input"]; !ok {
+		t.Error("step property input missing")
+	}
+	if got := step["on_error"].(map[string]any)["enum"]; !jsonEqual(got, []any{"stop", "skip"}) {
+		t.Errorf("on_error enum = %v", got)
+	}
+	if got := step["ask"].(map[string]any)["$ref"]; got != "#/$defs/AskDef" {
+		t.Errorf("ask = %v, want a reference to AskDef", got)
+	}
+	if got := step["with"].(map[string]any)["additionalProperties"]; !jsonEqual(got, map[string]any{"type": "string"}) {
+		t.Errorf("with values = %v, want strings", got)
+	}
+
+	types := defs["Assertion"].(map[string]any)["properties"].(map[string]any)["type"].(map[string]any)["enum"].([]any)
+	if len(types) != len(validAssertionTypes) {
+		t.Errorf("assertion types = %v, want %d", types, len(validAssertionTypes))
+	}
+}
+
+// The bundled templates are in the latest version, so the schema must
+// accept each of their fields.
+func TestJSONSchemaTemplates(t *testing.T) {
+	data, err := json.Marshal(JSONSchema())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	files, _ := filepath.Glob("../../templates/*.yaml")
+	if len(files) == 0 {
+		t.Skip("no templates")
+	}
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc any
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		for _, e := range unknownFields(schema, schema, doc, "") {
+			t.Errorf("%s: %s not in the schema", filepath.Base(file), e)
+		}
+	}
+}
+
+// unknownFields returns the paths of fields in doc that schema does not
+// declare.
+func unknownFields(root, schema map[string]any, doc any, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		def := strings.TrimPrefix(ref, "#/$defs/")
+		schema = root["$defs"].(map[string]any)[def].(map[string]any)
+	}
+	var unknown []string
+	switch doc := doc.(type) {
+	case map[string]any:
+		props, ok := schema["properties"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		for key, value := range doc {
+			prop, ok := props[key].(map[string]any)
+			if !ok {
+				unknown = append(unknown, path+key)
+				continue
+			}
+			unknown = append(unknown, unknownFields(root, prop, value, path+key+".")...)
+		}
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		for i, item := range doc {
+			unknown = append(unknown, unknownFields(root, items, item, fmt.Sprintf("%s[%d].", strings.TrimSuffix(path, "."), i))...)
+		}
+	}
+	return unknown
+}
+
+// jsonEqual reports whether got and want encode to the same JSON.
+func jsonEqual(got, want any) bool {
+	a, _ := json.Marshal(got)
+	b, _ := json.Marshal(want)
+	return slices.Equal(a, b)
+}
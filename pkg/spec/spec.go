@@ -1,11 +1,12 @@
 package spec
 
 // ProjectSpec defines a complete task specification that an agent executes against.
-// It is the contract between human intent and agent execution.
+// It is the contract between human intent and agent execution. Its YAML
+// fields are those of LatestAPIVersion; see version.go for older ones.
 type ProjectSpec struct {
 	APIVersion      string      `yaml:"apiVersion" json:"apiVersion"`
 	Kind            string      `yaml:"kind" json:"kind"`
-	Meta            SpecMeta    `yaml:"meta" json:"meta"`
+	Meta            SpecMeta    `yaml:"metadata" json:"meta"`
 	Goal            string      `yaml:"goal" json:"goal"`
 	Constraints     []string    `yaml:"constraints" json:"constraints"`
 	Guidelines      []string    `yaml:"guidelines" json:"guidelines"`
@@ -13,6 +14,100 @@ type ProjectSpec struct {
 	AllowedCommands []string    `yaml:"allowed_commands" json:"allowed_commands"`
 	Output          OutputSpec  `yaml:"output" json:"output"`
 	Params          []ParamDef  `yaml:"params" json:"params"`
+
+	// Steps, when present, are the steps of the plan, in place of the ones
+	// derived from allowed_commands. Steps with needs or inputs form a
+	// graph whose independent branches run concurrently.
+	Steps []StepDef `yaml:"steps" json:"steps,omitempty"`
+
+	// OnVerifyFailure is "stop" (default) or "rollback": restore the
+	// checkpoint taken before the failing step, or before the first write
+	// step when success criteria fail.
+	OnVerifyFailure string `yaml:"on_verify_failure" json:"on_verify_failure,omitempty"`
+
+	// IntentDrift is what happens when a step does something its risk,
+	// intent or params rule out, such as a read step writing a file:
+	// "warn" (default) reports it, "block" fails the step as if its
+	// verification failed, "off" skips the check.
+	IntentDrift string `yaml:"intent_drift" json:"intent_drift,omitempty"`
+
+	// Limits bound a run of the spec.
+	Limits Limits `yaml:"limits" json:"limits"`
+
+	// Sandbox narrows the configured sandbox for the run of the spec.
+	Sandbox SpecSandbox `yaml:"sandbox" json:"sandbox"`
+}
+
+// Limits bound a run of a spec.
+type Limits struct {
+	// MaxDuration is the deadline for the whole run, e.g. "30m". The step
+	// running when it passes is cancelled.
+	MaxDuration string `yaml:"max_duration" json:"max_duration,omitempty"`
+
+	// OnTimeout is "stop" (default) or "rollback": restore the latest
+	// checkpoint the run saved.
+	OnTimeout string `yaml:"on_timeout" json:"on_timeout,omitempty"`
+
+	// MaxSteps is the most steps the plan may have; 0 means no limit.
+	MaxSteps int `yaml:"max_steps" json:"max_steps,omitempty"`
+
+	// AllowedDomains, when set, are the only hosts the run's commands may
+	// contact, as exact names or "*.example.com" patterns. NoNetwork
+	// allows none.
+	AllowedDomains []string `yaml:"allowed_domains" json:"allowed_domains,omitempty"`
+	NoNetwork      bool     `yaml:"no_network" json:"no_network,omitempty"`
+}
+
+// SpecSandbox is the sandbox a spec's run is held to besides the one the
+// config sets. It can only narrow that one: a path must be allowed by
+// both, and the smaller max_file_size applies.
+type SpecSandbox struct {
+	AllowedPaths []string `yaml:"allowed_paths" json:"allowed_paths,omitempty"`
+	DeniedPaths  []string `yaml:"denied_paths" json:"denied_paths,omitempty"`
+	MaxFileSize  string   `yaml:"max_file_size" json:"max_file_size,omitempty"` // e.g. "1MB"
+}
+
+// IsZero reports whether the sandbox restricts nothing.
+func (s SpecSandbox) IsZero() bool {
+	return len(s.AllowedPaths) == 0 && len(s.DeniedPaths) == 0 && s.MaxFileSize == ""
+}
+
+// StepDef is an explicit step of a spec.
+type StepDef struct {
+	ID      string         `yaml:"id" json:"id,omitempty"`
+	Command string         `yaml:"command" json:"command"`
+	Args    []string       `yaml:"args" json:"args,omitempty"`
+	Params  map[string]any `yaml:"input" json:"params,omitempty"` // the step's input payload ("params" in agsh/v1)
+	Intent  string         `yaml:"intent" json:"intent,omitempty"`
+	OnError string         `yaml:"on_error" json:"on_error,omitempty"` // "stop" (default), "skip"
+
+	// VerifyOutputSchema fails the step when its output does not match
+	// the command's declared output schema.
+	VerifyOutputSchema bool `yaml:"verify_output_schema" json:"verify_output_schema,omitempty"`
+
+	// Needs lists the IDs of steps that must finish first.
+	Needs []string `yaml:"needs" json:"needs,omitempty"`
+
+	// Inputs maps input fields to earlier outputs, e.g.
+	// {prs: fetch_prs.output}; they are merged into Params.
+	Inputs map[string]string `yaml:"inputs" json:"inputs,omitempty"`
+
+	// Uses runs another spec as this step, in place of Command, with With
+	// as its params. A relative path is relative to this spec's file.
+	Uses string            `yaml:"uses" json:"uses,omitempty"`
+	With map[string]string `yaml:"with" json:"with,omitempty"`
+
+	// Ask pauses the run to ask the operator, in place of Command. The
+	// answer is the step's output and is stored in the session context.
+	Ask *AskDef `yaml:"ask" json:"ask,omitempty"`
+}
+
+// AskDef is the question an ask step puts to the operator.
+type AskDef struct {
+	Prompt  string   `yaml:"prompt" json:"prompt"`
+	Choices []string `yaml:"choices" json:"choices,omitempty"` // allowed answers; any when empty
+	Default string   `yaml:"default" json:"default,omitempty"` // used for an empty answer
+	Key     string   `yaml:"key" json:"key,omitempty"`         // session context key; "ask.<step id>" by default
 }
 
 // SpecMeta contains metadata about the spec.
@@ -24,25 +119,52 @@ type SpecMeta struct {
 	Tags        []string `yaml:"tags" json:"tags"`
 }
 
-// OutputSpec describes the expected output.
+// OutputSpec describes the expected output: a main output at Path and
+// any further named Artifacts. After a run, the outputs are listed in
+// its manifest (see BuildManifest).
 type OutputSpec struct {
+	Path      string           `yaml:"path" json:"path"`
+	Format    string           `yaml:"format" json:"format"`
+	Artifacts []OutputArtifact `yaml:"artifacts" json:"artifacts,omitempty"`
+}
+
+// MainOutput is the name of the output at OutputSpec.Path.
+const MainOutput = "output"
+
+// OutputArtifact is a named output of a spec. Success criteria check its
+// content with the target "artifact.<name>".
+type OutputArtifact struct {
+	Name   string `yaml:"name" json:"name"`
 	Path   string `yaml:"path" json:"path"`
-	Format string `yaml:"format" json:"format"`
+	Format string `yaml:"format" json:"format,omitempty"`
+}
+
+// Declared returns the outputs o declares, the main one first.
+func (o OutputSpec) Declared() []OutputArtifact {
+	var outputs []OutputArtifact
+	if o.Path != "" {
+		outputs = append(outputs, OutputArtifact{Name: MainOutput, Path: o.Path, Format: o.Format})
+	}
+	return append(outputs, o.Artifacts...)
 }
 
 // ParamDef defines a runtime parameter that the human provides.
 type ParamDef struct {
 	Name        string `yaml:"name" json:"name"`
-	Type        string `yaml:"type" json:"type"`
+	Type        string `yaml:"type" json:"type"` // e.g. "string", "integer"; "file" and "dir" must exist
 	Default     any    `yaml:"default" json:"default"`
 	Description string `yaml:"description" json:"description"`
+
+	// Content, for a file param, interpolates the file's content rather
+	// than its path.
+	Content bool `yaml:"content" json:"content,omitempty"`
 }
 
 // Assertion defines a machine-checkable condition for verification.
 // This type is compatible with pkg/verify.Assertion (Phase 3).
 type Assertion struct {
-	Type     string `yaml:"type" json:"type"`         // "contains", "not_empty", "json_schema", "count_gte", "matches_regex", "llm_judge"
-	Target   string `yaml:"target" json:"target"`     // what to check: "output", "context.session.x", etc.
+	Type     string `yaml:"type" json:"type"`         // "contains", "not_empty", "json_schema", "count_gte", "matches_regex", "hash_equals", "duration_lte", "llm_judge", "no_writes_outside", "no_network_calls", "max_files_written"
+	Target   string `yaml:"target" json:"target"`     // what to check: "output", "context.session.x", "artifact.report", etc.
 	Expected any    `yaml:"expected" json:"expected"` // the expected value/pattern
 	Message  string `yaml:"message" json:"message"`   // human-readable failure description
 }
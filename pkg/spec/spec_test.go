@@ -83,9 +83,9 @@ func TestProjectSpecRoundTrip(t *testing.T) {
 
 func TestProjectSpecFromYAML(t *testing.T) {
 	yamlData := `
-apiVersion: agsh/v1
+apiVersion: agsh/v2
 kind: ProjectSpec
-meta:
+metadata:
   name: "heading-counter"
   description: "Count markdown headings"
   author: "demo"
@@ -117,7 +117,7 @@ params: []
 		t.Fatalf("Unmarshal: %v", err)
 	}
 
-	if spec.APIVersion != "agsh/v1" {
+	if spec.APIVersion != "agsh/v2" {
 		t.Errorf("APIVersion = %q", spec.APIVersion)
 	}
 	if spec.Meta.Name != "heading-counter" {
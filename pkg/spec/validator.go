@@ -2,7 +2,13 @@ package spec
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/cgast/agsh/internal/glob"
+	"github.com/cgast/agsh/internal/sandbox"
+	agshctx "github.com/cgast/agsh/pkg/context"
 )
 
 // ValidationError represents a single validation failure.
@@ -46,9 +52,9 @@ func ValidateSpec(spec ProjectSpec) ValidationResult {
 		result.Errors = append(result.Errors, ValidationError{
 			Field: "apiVersion", Message: "required",
 		})
-	} else if spec.APIVersion != "agsh/v1" {
+	} else if versionIndex(spec.APIVersion) < 0 {
 		result.Errors = append(result.Errors, ValidationError{
-			Field: "apiVersion", Message: fmt.Sprintf("unsupported version %q (expected agsh/v1)", spec.APIVersion),
+			Field: "apiVersion", Message: fmt.Sprintf("unsupported version %q (expected one of %s)", spec.APIVersion, strings.Join(APIVersions, ", ")),
 		})
 	}
 
@@ -64,7 +70,7 @@ func ValidateSpec(spec ProjectSpec) ValidationResult {
 
 	if spec.Meta.Name == "" {
 		result.Errors = append(result.Errors, ValidationError{
-			Field: "meta.name", Message: "required",
+			Field: fieldName(spec.APIVersion, "", "metadata") + ".name", Message: "required",
 		})
 	}
 
@@ -76,7 +82,7 @@ func ValidateSpec(spec ProjectSpec) ValidationResult {
 
 	// Validate allowed_commands patterns.
 	for i, pattern := range spec.AllowedCommands {
-		if err := validateCommandPattern(pattern); err != nil {
+		if err := glob.Validate(pattern); err != nil {
 			result.Errors = append(result.Errors, ValidationError{
 				Field:   fmt.Sprintf("allowed_commands[%d]", i),
 				Message: err.Error(),
@@ -97,8 +103,58 @@ func ValidateSpec(spec ProjectSpec) ValidationResult {
 				Message: fmt.Sprintf("unknown assertion type %q", a.Type),
 			})
 		}
+		if name, ok := strings.CutPrefix(a.Target, ArtifactTargetPrefix); ok && !declaresOutput(spec.Output, name) {
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   fmt.Sprintf("success_criteria[%d].target", i),
+				Message: fmt.Sprintf("no output named %q", name),
+			})
+		}
+		if strings.HasPrefix(a.Target, "context.") {
+			if _, _, err := agshctx.ParseContextRef(a.Target); err != nil {
+				result.Errors = append(result.Errors, ValidationError{
+					Field:   fmt.Sprintf("success_criteria[%d].target", i),
+					Message: err.Error(),
+				})
+			}
+		}
+	}
+
+	if err := checkEnum(spec.OnVerifyFailure, onVerifyFailureValues); err != nil {
+		result.Errors = append(result.Errors, ValidationError{Field: "on_verify_failure", Message: err.Error()})
+	}
+	if err := checkEnum(spec.IntentDrift, intentDriftValues); err != nil {
+		result.Errors = append(result.Errors, ValidationError{Field: "intent_drift", Message: err.Error()})
 	}
 
+	if spec.Limits.MaxDuration != "" {
+		if d, err := time.ParseDuration(spec.Limits.MaxDuration); err != nil || d <= 0 {
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "limits.max_duration",
+				Message: fmt.Sprintf("invalid duration %q", spec.Limits.MaxDuration),
+			})
+		}
+	}
+	if err := checkEnum(spec.Limits.OnTimeout, onTimeoutValues); err != nil {
+		result.Errors = append(result.Errors, ValidationError{Field: "limits.on_timeout", Message: err.Error()})
+	}
+	if spec.Limits.MaxSteps < 0 {
+		result.Errors = append(result.Errors, ValidationError{Field: "limits.max_steps", Message: "must not be negative"})
+	}
+	if spec.Limits.NoNetwork && len(spec.Limits.AllowedDomains) > 0 {
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "limits.allowed_domains",
+			Message: "cannot be combined with no_network",
+		})
+	}
+	if spec.Sandbox.MaxFileSize != "" {
+		if _, err := sandbox.ParseFileSize(spec.Sandbox.MaxFileSize); err != nil {
+			result.Errors = append(result.Errors, ValidationError{Field: "sandbox.max_file_size", Message: err.Error()})
+		}
+	}
+
+	result.Errors = append(result.Errors, validateSteps(spec)...)
+	result.Errors = append(result.Errors, validateOutputs(spec.Output)...)
+
 	// Validate params.
 	paramNames := make(map[string]bool)
 	for i, p := range spec.Params {
@@ -115,11 +171,129 @@ func ValidateSpec(spec ProjectSpec) ValidationResult {
 		} else {
 			paramNames[p.Name] = true
 		}
+		if p.Content && p.Type != ParamTypeFile {
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   fmt.Sprintf("params[%d].content", i),
+				Message: "only applies to file params",
+			})
+		}
 	}
 
 	return result
 }
 
+// validateSteps checks the spec's explicit steps: each names an allowed
+// command or a spec to use, and their needs and inputs form a graph.
+func validateSteps(spec ProjectSpec) []ValidationError {
+	var errs []ValidationError
+	steps := make([]agshctx.PipelineStep, len(spec.Steps))
+	for i, def := range spec.Steps {
+		field := fmt.Sprintf("steps[%d]", i)
+		kinds := 0
+		for _, set := range []bool{def.Command != "", def.Uses != "", def.Ask != nil} {
+			if set {
+				kinds++
+			}
+		}
+		switch {
+		case kinds > 1:
+			errs = append(errs, ValidationError{Field: field, Message: "command, uses and ask are mutually exclusive"})
+		case def.Uses != "":
+			// The used spec is validated with its own allowed_commands.
+		case def.Ask != nil:
+			errs = append(errs, validateAsk(field+".ask", *def.Ask)...)
+		case def.Command == "":
+			errs = append(errs, ValidationError{Field: field + ".command", Message: "required (or uses or ask)"})
+		case !commandAllowed(def.Command, spec.AllowedCommands):
+			errs = append(errs, ValidationError{
+				Field:   field + ".command",
+				Message: fmt.Sprintf("%s is not in allowed_commands", def.Command),
+			})
+		}
+		if def.VerifyOutputSchema && def.Command == "" {
+			errs = append(errs, ValidationError{Field: field + ".verify_output_schema", Message: "only applies to command steps"})
+		}
+		if err := checkEnum(def.OnError, onErrorValues); err != nil {
+			errs = append(errs, ValidationError{Field: field + ".on_error", Message: err.Error()})
+		}
+		steps[i] = agshctx.PipelineStep{ID: def.ID, Command: def.Command, Needs: def.Needs, Inputs: def.Inputs}
+	}
+	if err := agshctx.ValidateGraph(steps); err != nil {
+		errs = append(errs, ValidationError{Field: "steps", Message: err.Error()})
+	}
+	return errs
+}
+
+// validateOutputs checks that the spec's artifacts have a path and a
+// name no other output has.
+func validateOutputs(out OutputSpec) []ValidationError {
+	var errs []ValidationError
+	names := map[string]bool{}
+	if out.Path != "" {
+		names[MainOutput] = true
+	}
+	for i, a := range out.Artifacts {
+		field := fmt.Sprintf("output.artifacts[%d]", i)
+		switch {
+		case a.Name == "":
+			errs = append(errs, ValidationError{Field: field + ".name", Message: "required"})
+		case names[a.Name]:
+			errs = append(errs, ValidationError{Field: field + ".name", Message: fmt.Sprintf("duplicate output name %q", a.Name)})
+		default:
+			names[a.Name] = true
+		}
+		if a.Path == "" {
+			errs = append(errs, ValidationError{Field: field + ".path", Message: "required"})
+		}
+	}
+	return errs
+}
+
+func declaresOutput(out OutputSpec, name string) bool {
+	return slices.ContainsFunc(out.Declared(), func(a OutputArtifact) bool { return a.Name == name })
+}
+
+func validateAsk(field string, ask AskDef) []ValidationError {
+	var errs []ValidationError
+	if strings.TrimSpace(ask.Prompt) == "" {
+		errs = append(errs, ValidationError{Field: field + ".prompt", Message: "required"})
+	}
+	if ask.Default != "" && len(ask.Choices) > 0 && !slices.Contains(ask.Choices, ask.Default) {
+		errs = append(errs, ValidationError{
+			Field:   field + ".default",
+			Message: fmt.Sprintf("%q is not one of the choices", ask.Default),
+		})
+	}
+	return errs
+}
+
+func commandAllowed(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == name || glob.Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// The values of the spec's enumerated fields; an empty value takes the
+// first. The JSON Schema lists them too.
+var (
+	onVerifyFailureValues = []string{"stop", "rollback"}
+	intentDriftValues     = []string{"warn", "block", "off"}
+	onTimeoutValues       = []string{"stop", "rollback"}
+	onErrorValues         = []string{"stop", "skip"}
+)
+
+// checkEnum reports a value that is neither empty nor one of values.
+func checkEnum(value string, values []string) error {
+	if value == "" || slices.Contains(values, value) {
+		return nil
+	}
+	expected := strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+	return fmt.Errorf("unknown value %q (expected %s)", value, expected)
+}
+
 // validAssertionTypes lists the recognized assertion types.
 var validAssertionTypes = map[string]bool{
 	"not_empty":     true,
@@ -129,27 +303,14 @@ var validAssertionTypes = map[string]bool{
 	"json_schema":   true,
 	"matches_regex": true,
 	"llm_judge":     true,
+	"hash_equals":   true,
+	"duration_lte":  true,
+
+	"no_writes_outside": true,
+	"no_network_calls":  true,
+	"max_files_written": true,
 }
 
 func isValidAssertionType(t string) bool {
 	return validAssertionTypes[t]
 }
-
-// validateCommandPattern checks that a command glob pattern is well-formed.
-// Patterns must be non-empty and use the format "namespace:command" or "namespace:*".
-func validateCommandPattern(pattern string) error {
-	if pattern == "" {
-		return fmt.Errorf("empty command pattern")
-	}
-	if pattern == "*" {
-		return nil // Wildcard for everything.
-	}
-	if !strings.Contains(pattern, ":") {
-		return fmt.Errorf("invalid pattern %q (expected namespace:command format)", pattern)
-	}
-	parts := strings.SplitN(pattern, ":", 2)
-	if parts[0] == "" {
-		return fmt.Errorf("invalid pattern %q (empty namespace)", pattern)
-	}
-	return nil
-}
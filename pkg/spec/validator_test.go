@@ -95,7 +95,7 @@ func TestValidateSpecBadCommandPattern(t *testing.T) {
 }
 
 func TestValidateSpecValidCommandPatterns(t *testing.T) {
-	patterns := []string{"fs:*", "github:repo:info", "*", "http:get"}
+	patterns := []string{"fs:*", "github:repo:info", "*", "http:get", "github:*:list", "*:write"}
 	for _, p := range patterns {
 		spec := validSpec()
 		spec.AllowedCommands = []string{p}
@@ -117,6 +117,77 @@ func TestValidateSpecBadAssertionType(t *testing.T) {
 	}
 }
 
+func TestValidateSpecContextTarget(t *testing.T) {
+	spec := validSpec()
+	spec.SuccessCriteria = []Assertion{
+		{Type: "count_gte", Target: "context.session.total_headings", Expected: 1},
+	}
+	if result := ValidateSpec(spec); !result.Valid() {
+		t.Errorf("expected context.session target to be valid, got: %s", result.Error())
+	}
+
+	spec.SuccessCriteria[0].Target = "context.sesion.total_headings"
+	result := ValidateSpec(spec)
+	if result.Valid() || result.Errors[0].Field != "success_criteria[0].target" {
+		t.Errorf("expected an error for an unknown scope, got: %+v", result.Errors)
+	}
+}
+
+func TestValidateSpecOnVerifyFailure(t *testing.T) {
+	spec := validSpec()
+	spec.OnVerifyFailure = "rollback"
+	if result := ValidateSpec(spec); !result.Valid() {
+		t.Errorf("expected rollback to be valid, got: %s", result.Error())
+	}
+
+	spec.OnVerifyFailure = "retry"
+	if result := ValidateSpec(spec); result.Valid() {
+		t.Error("expected validation error for unknown on_verify_failure")
+	}
+}
+
+func TestValidateSpecIntentDrift(t *testing.T) {
+	spec := validSpec()
+	spec.IntentDrift = "block"
+	if result := ValidateSpec(spec); !result.Valid() {
+		t.Errorf("expected block to be valid, got: %s", result.Error())
+	}
+
+	spec.IntentDrift = "strict"
+	if result := ValidateSpec(spec); result.Valid() {
+		t.Error("expected validation error for unknown intent_drift")
+	}
+}
+
+func TestValidateSpecLimits(t *testing.T) {
+	spec := validSpec()
+	spec.Limits = Limits{MaxDuration: "30m", OnTimeout: "rollback"}
+	if result := ValidateSpec(spec); !result.Valid() {
+		t.Errorf("expected limits to be valid, got: %s", result.Error())
+	}
+
+	spec.Limits = Limits{MaxDuration: "half an hour", OnTimeout: "retry"}
+	result := ValidateSpec(spec)
+	assertHasFieldError(t, result, "limits.max_duration")
+	assertHasFieldError(t, result, "limits.on_timeout")
+}
+
+func TestValidateSpecConfinement(t *testing.T) {
+	spec := validSpec()
+	spec.Limits = Limits{MaxSteps: 5, AllowedDomains: []string{"api.github.com"}}
+	spec.Sandbox = SpecSandbox{AllowedPaths: []string{"./workspace"}, MaxFileSize: "1MB"}
+	if result := ValidateSpec(spec); !result.Valid() {
+		t.Errorf("expected confinement to be valid, got: %s", result.Error())
+	}
+
+	spec.Limits = Limits{MaxSteps: -1, AllowedDomains: []string{"api.github.com"}, NoNetwork: true}
+	spec.Sandbox = SpecSandbox{MaxFileSize: "a lot"}
+	result := ValidateSpec(spec)
+	assertHasFieldError(t, result, "limits.max_steps")
+	assertHasFieldError(t, result, "limits.allowed_domains")
+	assertHasFieldError(t, result, "sandbox.max_file_size")
+}
+
 func TestValidateSpecDuplicateParams(t *testing.T) {
 	spec := validSpec()
 	spec.Params = []ParamDef{
@@ -129,6 +200,65 @@ func TestValidateSpecDuplicateParams(t *testing.T) {
 	}
 }
 
+func TestValidateSpecSteps(t *testing.T) {
+	spec := validSpec()
+	spec.Steps = []StepDef{
+		{ID: "list", Command: "fs:list"},
+		{ID: "read", Command: "fs:read", Needs: []string{"list"}, Inputs: map[string]string{"files": "list.output"}},
+	}
+	if result := ValidateSpec(spec); !result.Valid() {
+		t.Fatalf("expected valid steps, got: %s", result.Error())
+	}
+
+	spec.Steps = []StepDef{
+		{ID: "a", Command: "github:pr:list", OnError: "retry"},
+		{ID: "b", Command: "fs:read", Needs: []string{"c"}},
+	}
+	result := ValidateSpec(spec)
+	assertHasFieldError(t, result, "steps[0].command")
+	assertHasFieldError(t, result, "steps[0].on_error")
+	assertHasFieldError(t, result, "steps")
+
+	spec.Steps = []StepDef{
+		{ID: "a", Uses: "summarize.agsh.yaml"},
+		{ID: "b", Command: "fs:read", Uses: "other.agsh.yaml"},
+	}
+	result = ValidateSpec(spec)
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected only the command/uses conflict, got: %s", result.Error())
+	}
+	assertHasFieldError(t, result, "steps[1]")
+
+	spec.Steps = []StepDef{
+		{ID: "a", Ask: &AskDef{Prompt: "Ship it?", Choices: []string{"yes", "no"}, Default: "no"}},
+		{ID: "b", Ask: &AskDef{Choices: []string{"yes"}, Default: "maybe"}},
+		{ID: "c", Command: "fs:list", Ask: &AskDef{Prompt: "Sure?"}},
+	}
+	result = ValidateSpec(spec)
+	if len(result.Errors) != 3 {
+		t.Fatalf("expected 3 errors, got: %s", result.Error())
+	}
+	assertHasFieldError(t, result, "steps[1].ask.prompt")
+	assertHasFieldError(t, result, "steps[1].ask.default")
+	assertHasFieldError(t, result, "steps[2]")
+
+	spec.Steps = []StepDef{
+		{ID: "a", Command: "fs:list", VerifyOutputSchema: true},
+		{ID: "b", Uses: "summarize.agsh.yaml", VerifyOutputSchema: true},
+	}
+	result = ValidateSpec(spec)
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected only the uses step's error, got: %s", result.Error())
+	}
+	assertHasFieldError(t, result, "steps[1].verify_output_schema")
+
+	spec.Steps = []StepDef{
+		{ID: "a", Command: "fs:list", Needs: []string{"b"}},
+		{ID: "b", Command: "fs:list", Inputs: map[string]string{"x": "a.output"}},
+	}
+	assertHasFieldError(t, ValidateSpec(spec), "steps")
+}
+
 func TestValidateSpecMultipleErrors(t *testing.T) {
 	spec := ProjectSpec{} // Everything missing.
 	result := ValidateSpec(spec)
@@ -0,0 +1,249 @@
+package spec
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec format versions. ProjectSpec describes the latest; a document in an
+// older version is converted into it as it is read, and `agsh spec
+// migrate` rewrites the file itself.
+const (
+	APIVersionV1 = "agsh/v1"
+	APIVersionV2 = "agsh/v2"
+
+	LatestAPIVersion = APIVersionV2
+)
+
+// APIVersions lists the supported versions, oldest first.
+var APIVersions = []string{APIVersionV1, APIVersionV2}
+
+// rename is a field a version renamed: the key From, in the mapping at
+// Path ("" for the top level, "steps[]" for each step), is To from Since
+// on.
+type rename struct {
+	Since    string
+	Path     string
+	From, To string
+}
+
+// renames are the conversions between versions, oldest first.
+var renames = []rename{
+	// The metadata block is "metadata", as in Kubernetes manifests, whose
+	// apiVersion and kind specs already borrow.
+	{Since: APIVersionV2, Path: "", From: "meta", To: "metadata"},
+	// A step's input payload was "params", easily confused with the
+	// spec's runtime params.
+	{Since: APIVersionV2, Path: "steps[]", From: "params", To: "input"},
+}
+
+// versionIndex returns the position of version in APIVersions, or -1.
+func versionIndex(version string) int {
+	return slices.Index(APIVersions, version)
+}
+
+// fieldName returns what version calls the field name of the latest
+// format, found in the mapping at path.
+func fieldName(version, path, name string) string {
+	v := versionIndex(version)
+	if v < 0 {
+		return name
+	}
+	for i := len(renames) - 1; i >= 0; i-- {
+		r := renames[i]
+		if r.Path == path && r.To == name && v < versionIndex(r.Since) {
+			name = r.From
+		}
+	}
+	return name
+}
+
+// edit replaces the scalar at a position of a document's text.
+type edit struct {
+	line, column int // 1-based, as yaml.Node reports them
+	old, new     string
+}
+
+// specDocument is a parsed spec document.
+type specDocument struct {
+	root    *yaml.Node // the top-level mapping; nil if the document is not one
+	version *yaml.Node // the apiVersion value; nil if missing
+}
+
+func parseDocument(data []byte) (*yaml.Node, specDocument, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, specDocument{}, err
+	}
+	var sd specDocument
+	if len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode {
+		sd.root = doc.Content[0]
+		if _, v := mappingEntry(sd.root, "apiVersion"); v != nil && v.Kind == yaml.ScalarNode {
+			sd.version = v
+		}
+	}
+	return &doc, sd, nil
+}
+
+// Version returns the document's apiVersion, or "" without one.
+func (d specDocument) Version() string {
+	if d.version == nil {
+		return ""
+	}
+	return d.version.Value
+}
+
+// mappings returns the mappings at path, with their field paths.
+func (d specDocument) mappings(path string) ([]*yaml.Node, []string) {
+	if d.root == nil {
+		return nil, nil
+	}
+	if path == "" {
+		return []*yaml.Node{d.root}, []string{""}
+	}
+	list, _ := strings.CutSuffix(path, "[]")
+	_, seq := mappingEntry(d.root, list)
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+	var nodes []*yaml.Node
+	var fields []string
+	for i, item := range seq.Content {
+		if item.Kind == yaml.MappingNode {
+			nodes = append(nodes, item)
+			fields = append(fields, fmt.Sprintf("%s[%d].", list, i))
+		}
+	}
+	return nodes, fields
+}
+
+// versionErrors reports fields that belong to another version than the
+// document's: ones a later version renamed, and ones it introduced.
+func (d specDocument) versionErrors() []ValidationError {
+	version := d.Version()
+	v := versionIndex(version)
+	if v < 0 {
+		return nil
+	}
+	var errs []ValidationError
+	for _, r := range renames {
+		nodes, fields := d.mappings(r.Path)
+		for i, m := range nodes {
+			switch since := versionIndex(r.Since); {
+			case v >= since && hasKey(m, r.From):
+				errs = append(errs, ValidationError{
+					Field:   fields[i] + r.From,
+					Message: fmt.Sprintf("renamed to %s in %s", r.To, r.Since),
+				})
+			case v < since && hasKey(m, r.To):
+				errs = append(errs, ValidationError{
+					Field:   fields[i] + r.To,
+					Message: fmt.Sprintf("introduced in %s; %s calls it %s (or run agsh spec migrate)", r.Since, version, r.From),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// upgrade converts the document to the latest version in place and
+// returns the edits that make the same change to its text. A document of
+// the latest, or of an unknown, version is left alone.
+func (d specDocument) upgrade() []edit {
+	v := versionIndex(d.Version())
+	if v < 0 || v == len(APIVersions)-1 {
+		return nil
+	}
+	var edits []edit
+	for _, r := range renames {
+		if versionIndex(r.Since) <= v {
+			continue
+		}
+		nodes, _ := d.mappings(r.Path)
+		for _, m := range nodes {
+			if k, _ := mappingEntry(m, r.From); k != nil {
+				edits = append(edits, edit{k.Line, k.Column, k.Value, r.To})
+				k.Value = r.To
+			}
+		}
+	}
+	edits = append(edits, edit{d.version.Line, d.version.Column, d.version.Value, LatestAPIVersion})
+	d.version.Value = LatestAPIVersion
+	return edits
+}
+
+// Migrate rewrites a spec document in the latest version. Only the
+// renamed keys and apiVersion change, so comments and layout are kept.
+// It returns the version the document was in; a document already in the
+// latest version comes back unchanged.
+func Migrate(data []byte) ([]byte, string, error) {
+	_, d, err := parseDocument(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("parse spec: %w", err)
+	}
+	version := d.Version()
+	switch {
+	case d.root == nil:
+		return nil, "", fmt.Errorf("not a spec document")
+	case version == "":
+		return nil, "", fmt.Errorf("no apiVersion")
+	case versionIndex(version) < 0:
+		return nil, version, fmt.Errorf("unsupported version %q (expected one of %s)", version, strings.Join(APIVersions, ", "))
+	}
+	if errs := d.versionErrors(); len(errs) > 0 {
+		return nil, version, ValidationResult{Errors: errs}
+	}
+	out, err := applyEdits(data, d.upgrade())
+	return out, version, err
+}
+
+// applyEdits makes edits to text, each replacing a plain or quoted scalar.
+func applyEdits(data []byte, edits []edit) ([]byte, error) {
+	if len(edits) == 0 {
+		return data, nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	// Later edits on a line first, so earlier columns stay valid.
+	slices.SortFunc(edits, func(a, b edit) int { return b.column - a.column })
+	for _, e := range edits {
+		if e.line < 1 || e.line > len(lines) {
+			return nil, fmt.Errorf("line %d: out of range", e.line)
+		}
+		line := []rune(lines[e.line-1])
+		if e.column < 1 || e.column > len(line) {
+			return nil, fmt.Errorf("line %d: column %d out of range", e.line, e.column)
+		}
+		before, rest := string(line[:e.column-1]), string(line[e.column-1:])
+		replaced := false
+		for _, quote := range []string{"", `"`, "'"} {
+			if token := quote + e.old + quote; strings.HasPrefix(rest, token) {
+				rest = quote + e.new + quote + rest[len(token):]
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			return nil, fmt.Errorf("line %d: expected %q at column %d", e.line, e.old, e.column)
+		}
+		lines[e.line-1] = before + rest
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// mappingEntry returns the key and value nodes of key in a mapping.
+func mappingEntry(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func hasKey(m *yaml.Node, key string) bool {
+	k, _ := mappingEntry(m, key)
+	return k != nil
+}
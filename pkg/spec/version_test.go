@@ -0,0 +1,121 @@
+package spec
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const specV1 = `# A spec written before agsh/v2.
+apiVersion: "agsh/v1"
+kind: ProjectSpec
+
+meta:            # who and what
+  name: notes
+goal: Write notes
+allowed_commands: ["fs:write", "data:hash"]
+steps:
+  - id: write
+    command: fs:write
+    params:
+      path: notes.txt   # relative to the workdir
+      content: hi
+  - {id: hash, command: data:hash, params: {algo: sha256}}
+`
+
+const specV2 = `# A spec written before agsh/v2.
+apiVersion: "agsh/v2"
+kind: ProjectSpec
+
+metadata:            # who and what
+  name: notes
+goal: Write notes
+allowed_commands: ["fs:write", "data:hash"]
+steps:
+  - id: write
+    command: fs:write
+    input:
+      path: notes.txt   # relative to the workdir
+      content: hi
+  - {id: hash, command: data:hash, input: {algo: sha256}}
+`
+
+func TestMigrate(t *testing.T) {
+	out, from, err := Migrate([]byte(specV1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != APIVersionV1 {
+		t.Errorf("from = %q", from)
+	}
+	if string(out) != specV2 {
+		t.Errorf("migrated:\n%s\nwant:\n%s", out, specV2)
+	}
+
+	// The latest version is left as it is.
+	out, from, err = Migrate([]byte(specV2))
+	if err != nil || from != LatestAPIVersion || string(out) != specV2 {
+		t.Errorf("Migrate(v2) = %q, %q, %v", out, from, err)
+	}
+
+	for _, doc := range []string{"kind: ProjectSpec\n", "apiVersion: agsh/v9\n", "- a\n"} {
+		if _, _, err := Migrate([]byte(doc)); err == nil {
+			t.Errorf("Migrate(%q) succeeded", doc)
+		}
+	}
+}
+
+func TestParseSpecVersions(t *testing.T) {
+	v1, err := ParseSpec([]byte(specV1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := ParseSpec([]byte(specV2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.APIVersion != APIVersionV1 || v2.APIVersion != APIVersionV2 {
+		t.Errorf("versions = %q, %q; each spec keeps its own", v1.APIVersion, v2.APIVersion)
+	}
+	v1.APIVersion = v2.APIVersion
+	if !reflect.DeepEqual(v1, v2) {
+		t.Errorf("agsh/v1 spec reads as\n%+v\nwant\n%+v", v1, v2)
+	}
+	if v1.Meta.Name != "notes" || v1.Steps[1].Params["algo"] != "sha256" {
+		t.Errorf("spec = %+v", v1)
+	}
+}
+
+func TestParseSpecFieldOfOtherVersion(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want []ValidationError
+	}{
+		{strings.Replace(specV2, "metadata:", "meta:", 1),
+			[]ValidationError{{"meta", "renamed to metadata in agsh/v2"}}},
+		{strings.Replace(specV2, "    input:", "    params:", 1),
+			[]ValidationError{{"steps[0].params", "renamed to input in agsh/v2"}}},
+		{strings.Replace(specV1, "meta:", "metadata:", 1),
+			[]ValidationError{{"metadata", "introduced in agsh/v2; agsh/v1 calls it meta (or run agsh spec migrate)"}}},
+	}
+	for _, tt := range tests {
+		_, err := ParseSpec([]byte(tt.doc), nil)
+		var vr ValidationResult
+		if !errors.As(err, &vr) || !reflect.DeepEqual(vr.Errors, tt.want) {
+			t.Errorf("err = %v, want %v", err, tt.want)
+		}
+	}
+}
+
+func TestValidateSpecVersionFieldNames(t *testing.T) {
+	for version, field := range map[string]string{APIVersionV1: "meta.name", APIVersionV2: "metadata.name"} {
+		spec := validSpec()
+		spec.APIVersion = version
+		spec.Meta.Name = ""
+		result := ValidateSpec(spec)
+		if len(result.Errors) != 1 || result.Errors[0].Field != field {
+			t.Errorf("%s: errors = %v, want one for %s", version, result.Errors, field)
+		}
+	}
+}
@@ -1,37 +1,61 @@
 package verify
 
 import (
+	gocontext "context"
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/cgast/agsh/internal/digest"
 	agshctx "github.com/cgast/agsh/pkg/context"
 )
 
 // AssertionChecker is a function that checks a single assertion against an envelope.
 type AssertionChecker func(envelope agshctx.Envelope, assertion Assertion) AssertionResult
 
+// ContextChecker is an AssertionChecker that is handed the verification's
+// context, for checks that may block, such as calls over the network. It
+// should give up once ctx is done.
+type ContextChecker func(ctx gocontext.Context, envelope agshctx.Envelope, assertion Assertion) AssertionResult
+
 // builtinCheckers maps assertion type names to their checker implementations.
-var builtinCheckers = map[string]AssertionChecker{
-	"not_empty":     checkNotEmpty,
-	"contains":      checkContains,
-	"not_contains":  checkNotContains,
-	"count_gte":     checkCountGTE,
-	"matches_regex": checkMatchesRegex,
-	"json_schema":   checkJSONSchema,
+var builtinCheckers = map[string]ContextChecker{
+	"not_empty":     withoutContext(checkNotEmpty),
+	"contains":      withoutContext(checkContains),
+	"not_contains":  withoutContext(checkNotContains),
+	"count_gte":     withoutContext(checkCountGTE),
+	"matches_regex": withoutContext(checkMatchesRegex),
+	"json_schema":   withoutContext(checkJSONSchema),
+	"hash_equals":   withoutContext(checkHashEquals),
+	"duration_lte":  withoutContext(checkDurationLTE),
 }
 
-// RegisterChecker adds a custom assertion checker. Used for llm_judge etc.
+// RegisterChecker adds a custom assertion checker that does not block.
 func RegisterChecker(name string, checker AssertionChecker) {
+	builtinCheckers[name] = withoutContext(checker)
+}
+
+// RegisterContextChecker adds a custom assertion checker that may block
+// and can be cancelled. Used for llm_judge etc.
+func RegisterContextChecker(name string, checker ContextChecker) {
 	builtinCheckers[name] = checker
 }
 
 // GetChecker returns the checker for an assertion type, or nil if not found.
-func GetChecker(name string) AssertionChecker {
+func GetChecker(name string) ContextChecker {
 	return builtinCheckers[name]
 }
 
+// withoutContext adapts a checker that has no use for a context.
+func withoutContext(checker AssertionChecker) ContextChecker {
+	return func(_ gocontext.Context, envelope agshctx.Envelope, assertion Assertion) AssertionResult {
+		return checker(envelope, assertion)
+	}
+}
+
 // resolveTarget extracts the value to check from the envelope based on the target string.
 func resolveTarget(envelope agshctx.Envelope, target string) string {
 	switch {
@@ -49,6 +73,11 @@ func resolveTarget(envelope agshctx.Envelope, target string) string {
 		return envelope.Meta.ContentType
 	case target == "meta.source":
 		return envelope.Meta.Source
+	case target == "meta.duration" || target == "pipeline.duration":
+		if d, ok := resolveDuration(envelope, target); ok {
+			return d.String()
+		}
+		return ""
 	default:
 		return envelope.PayloadString()
 	}
@@ -128,6 +157,9 @@ func checkCountGTE(envelope agshctx.Envelope, assertion Assertion) AssertionResu
 			actual = len(v)
 		case []string:
 			actual = len(v)
+		case int, int64, float64:
+			// A count itself, such as one kept in the context store.
+			actual, _ = toInt(v)
 		default:
 			// Fall back to line count of string representation.
 			value := resolveTarget(envelope, assertion.Target)
@@ -222,6 +254,121 @@ func checkJSONSchema(envelope agshctx.Envelope, assertion Assertion) AssertionRe
 	}
 }
 
+// checkHashEquals verifies the digest of the target matches the expected
+// "algorithm:hex" value (bare hex means sha256). When the envelope comes
+// from data:hash, its computed digest is compared instead of hashing the
+// result itself.
+func checkHashEquals(envelope agshctx.Envelope, assertion Assertion) AssertionResult {
+	algorithm, want, err := digest.Parse(fmt.Sprintf("%v", assertion.Expected))
+	if err != nil {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   fmt.Sprintf("hash_equals: %v", err),
+		}
+	}
+
+	var got string
+	tag := envelope.Meta.Tags["hash"]
+	if envelope.Meta.Source == "data:hash" && (assertion.Target == "" || assertion.Target == "output") && strings.HasPrefix(tag, algorithm+":") {
+		got = strings.TrimPrefix(tag, algorithm+":")
+	} else {
+		got, _, err = digest.Sum(algorithm, strings.NewReader(resolveTarget(envelope, assertion.Target)))
+		if err != nil {
+			return AssertionResult{
+				Assertion: assertion,
+				Passed:    false,
+				Message:   fmt.Sprintf("hash_equals: %v", err),
+			}
+		}
+	}
+
+	passed := got == want
+	msg := assertion.Message
+	if !passed && msg == "" {
+		msg = fmt.Sprintf("%s digest %s does not match expected %s", algorithm, got, want)
+	}
+	return AssertionResult{
+		Assertion: assertion,
+		Passed:    passed,
+		Actual:    algorithm + ":" + got,
+		Message:   msg,
+	}
+}
+
+// resolveDuration returns how long the envelope took to produce:
+// "meta.duration" (the default) is the step that produced it, from its
+// provenance or the "duration_ms" tag the timing middleware sets, and
+// "pipeline.duration" is the whole pipeline.
+func resolveDuration(envelope agshctx.Envelope, target string) (time.Duration, bool) {
+	tag := "duration_ms"
+	switch target {
+	case "", "meta.duration":
+		if n := len(envelope.Provenance); n > 0 && envelope.Provenance[n-1].Duration > 0 {
+			return envelope.Provenance[n-1].Duration, true
+		}
+	case "pipeline.duration":
+		tag = agshctx.PipelineDurationTag
+	default:
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(envelope.Meta.Tags[tag], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// checkDurationLTE verifies a step or the pipeline finished within the
+// expected duration: a Go duration such as "60s" or "1m30s", or a number
+// of seconds.
+func checkDurationLTE(envelope agshctx.Envelope, assertion Assertion) AssertionResult {
+	budget, err := toDuration(assertion.Expected)
+	if err != nil {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   fmt.Sprintf("duration_lte: invalid expected value: %v", assertion.Expected),
+		}
+	}
+	took, ok := resolveDuration(envelope, assertion.Target)
+	if !ok {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   fmt.Sprintf("duration_lte: no duration recorded for target %q (use meta.duration or pipeline.duration)", assertion.Target),
+		}
+	}
+
+	passed := took <= budget
+	msg := assertion.Message
+	if !passed && msg == "" {
+		msg = fmt.Sprintf("took %s, over the budget of %s", took, budget)
+	}
+	return AssertionResult{
+		Assertion: assertion,
+		Passed:    passed,
+		Actual:    took.String(),
+		Message:   msg,
+	}
+}
+
+// toDuration converts a duration string or a number of seconds.
+func toDuration(v any) (time.Duration, error) {
+	switch d := v.(type) {
+	case string:
+		return time.ParseDuration(d)
+	case int:
+		return time.Duration(d) * time.Second, nil
+	case int64:
+		return time.Duration(d) * time.Second, nil
+	case float64:
+		return time.Duration(d * float64(time.Second)), nil
+	default:
+		return 0, fmt.Errorf("cannot convert %T to a duration", v)
+	}
+}
+
 // toInt converts various numeric types to int.
 func toInt(v any) (int, error) {
 	switch n := v.(type) {
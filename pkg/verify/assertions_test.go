@@ -2,6 +2,7 @@ package verify
 
 import (
 	"testing"
+	"time"
 
 	agshctx "github.com/cgast/agsh/pkg/context"
 )
@@ -166,6 +167,76 @@ func TestCheckJSONSchema(t *testing.T) {
 	}
 }
 
+func TestCheckHashEquals(t *testing.T) {
+	const sha = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	hashed := agshctx.NewEnvelope(map[string]any{"hash": sha}, "application/json", "data:hash")
+	hashed.Meta.Tags["hash"] = "sha256:" + sha
+
+	tests := []struct {
+		name     string
+		env      agshctx.Envelope
+		expected string
+		want     bool
+	}{
+		{"bare sha256", envelope("hello"), sha, true},
+		{"prefixed", envelope("hello"), "sha256:" + sha, true},
+		{"md5", envelope("hello"), "md5:5d41402abc4b2a76b9719d911017c592", true},
+		{"modified", envelope("hello!"), sha, false},
+		{"data:hash result", hashed, "sha256:" + sha, true},
+		{"invalid expected", envelope("hello"), "sha256:abc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := checkHashEquals(tt.env, Assertion{Type: "hash_equals", Target: "output", Expected: tt.expected})
+			if r.Passed != tt.want {
+				t.Errorf("Passed = %v, want %v (%s)", r.Passed, tt.want, r.Message)
+			}
+		})
+	}
+}
+
+func TestCheckDurationLTE(t *testing.T) {
+	step := envelope("report")
+	step.AddStep(agshctx.Step{Command: "report:build", Duration: 45 * time.Second, Status: "ok"})
+	step.Meta.Tags[agshctx.PipelineDurationTag] = "75000"
+
+	timed := envelope("report")
+	timed.Meta.Tags["duration_ms"] = "1500"
+
+	tests := []struct {
+		name     string
+		env      agshctx.Envelope
+		target   string
+		expected any
+		want     bool
+	}{
+		{"step within budget", step, "meta.duration", "60s", true},
+		{"default target", step, "", "1m", true},
+		{"step over budget", step, "meta.duration", "30s", false},
+		{"seconds", step, "meta.duration", 45, true},
+		{"pipeline over budget", step, "pipeline.duration", "60s", false},
+		{"pipeline within budget", step, "pipeline.duration", 90.0, true},
+		{"timing tag", timed, "meta.duration", "2s", true},
+		{"no duration", envelope("report"), "meta.duration", "60s", false},
+		{"unknown target", step, "output", "60s", false},
+		{"invalid expected", step, "meta.duration", "soon", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := checkDurationLTE(tt.env, Assertion{Type: "duration_lte", Target: tt.target, Expected: tt.expected})
+			if r.Passed != tt.want {
+				t.Errorf("Passed = %v, want %v (%s)", r.Passed, tt.want, r.Message)
+			}
+		})
+	}
+
+	r := checkDurationLTE(step, Assertion{Type: "duration_lte", Target: "pipeline.duration", Expected: "60s"})
+	if r.Actual != "1m15s" || r.Message != "took 1m15s, over the budget of 1m0s" {
+		t.Errorf("Actual = %v, Message = %q", r.Actual, r.Message)
+	}
+}
+
 func TestResolveTarget(t *testing.T) {
 	env := agshctx.NewEnvelope("payload-data", "text/plain", "test-source")
 	env.Meta.Tags["format"] = "markdown"
@@ -1,14 +1,19 @@
 package verify
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
+	"sync"
 	"time"
 
+	"github.com/cgast/agsh/internal/paths"
 	agshctx "github.com/cgast/agsh/pkg/context"
 )
 
@@ -17,6 +22,7 @@ type CheckpointManager interface {
 	Save(name string, state SessionSnapshot) error
 	Restore(name string) (SessionSnapshot, error)
 	List() ([]CheckpointInfo, error)
+	Delete(name string) error
 	Diff(a, b string) ([]Change, error)
 }
 
@@ -25,12 +31,71 @@ type SessionSnapshot struct {
 	ContextState map[string]map[string]any `json:"context_state"`
 	WorkdirHash  string                    `json:"workdir_hash"`
 	Timestamp    time.Time                 `json:"timestamp"`
+
+	// Filter records what a selective capture included, so a restore only
+	// reconciles the keys the snapshot covers. Nil means everything.
+	Filter *SnapshotFilter `json:"filter,omitempty"`
+	// Skipped lists, per scope, the keys the filter matched but left out
+	// for exceeding MaxValueSize. A restore leaves them alone.
+	Skipped map[string][]string `json:"skipped,omitempty"`
 }
 
 // CheckpointInfo is metadata about a saved checkpoint.
 type CheckpointInfo struct {
 	Name      string    `json:"name"`
 	Timestamp time.Time `json:"timestamp"`
+	Size      int64     `json:"size,omitempty"` // bytes on disk
+}
+
+// RetentionPolicy bounds how many checkpoints are kept. Zero fields are
+// unlimited. The newest checkpoint is always kept.
+type RetentionPolicy struct {
+	MaxCount     int
+	MaxAge       time.Duration
+	MaxTotalSize int64 // bytes
+}
+
+// CheckpointOption configures a checkpoint manager.
+type CheckpointOption func(*checkpointOptions)
+
+type checkpointOptions struct {
+	retention   RetentionPolicy
+	deltaLimit  int
+	lockTimeout time.Duration
+}
+
+func applyCheckpointOptions(opts []CheckpointOption) checkpointOptions {
+	var o checkpointOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithDeltas stores checkpoints as diffs against the most recent full
+// snapshot, writing a fresh full snapshot after limit deltas or when a
+// delta is no longer much smaller than a full copy. Only the Bolt manager
+// supports deltas; limit <= 0 disables them.
+func WithDeltas(limit int) CheckpointOption {
+	return func(o *checkpointOptions) {
+		o.deltaLimit = limit
+	}
+}
+
+// WithLockTimeout sets how long NewBoltCheckpointManager waits for another
+// process to release the database (default 1s). A database still held then
+// fails with bbolt's ErrTimeout.
+func WithLockTimeout(d time.Duration) CheckpointOption {
+	return func(o *checkpointOptions) {
+		o.lockTimeout = d
+	}
+}
+
+// WithRetention prunes checkpoints according to policy after every save.
+func WithRetention(policy RetentionPolicy) CheckpointOption {
+	return func(o *checkpointOptions) {
+		o.retention = policy
+	}
 }
 
 // Change records a difference between two snapshots.
@@ -43,16 +108,28 @@ type Change struct {
 }
 
 // FileCheckpointManager stores checkpoints as JSON files in a directory.
+// Characters the OS does not allow in file names, such as the ':' of
+// "run-step-1-fs:write" on Windows, are escaped in the file names. It is
+// safe for concurrent use.
 type FileCheckpointManager struct {
-	dir string
+	mu        sync.RWMutex
+	dir       string
+	retention RetentionPolicy
+	style     paths.Style // how names become file names
 }
 
 // NewFileCheckpointManager creates a checkpoint manager that stores snapshots as files.
-func NewFileCheckpointManager(dir string) (*FileCheckpointManager, error) {
+func NewFileCheckpointManager(dir string, opts ...CheckpointOption) (*FileCheckpointManager, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("create checkpoint dir: %w", err)
 	}
-	return &FileCheckpointManager{dir: dir}, nil
+	o := applyCheckpointOptions(opts)
+	return &FileCheckpointManager{dir: dir, retention: o.retention, style: paths.Native}, nil
+}
+
+// path returns the file of the checkpoint name.
+func (m *FileCheckpointManager) path(name string) string {
+	return filepath.Join(m.dir, m.style.FileName(name)+".json")
 }
 
 func (m *FileCheckpointManager) Save(name string, state SessionSnapshot) error {
@@ -60,13 +137,91 @@ func (m *FileCheckpointManager) Save(name string, state SessionSnapshot) error {
 	if err != nil {
 		return fmt.Errorf("marshal checkpoint: %w", err)
 	}
-	path := filepath.Join(m.dir, name+".json")
-	return os.WriteFile(path, data, 0644)
+	path := m.path(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	if _, err := m.pruneLocked(); err != nil {
+		return fmt.Errorf("prune checkpoints: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a checkpoint.
+func (m *FileCheckpointManager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.Remove(m.path(name)); err != nil {
+		return fmt.Errorf("delete checkpoint %q: %w", name, err)
+	}
+	return nil
+}
+
+// Prune applies the retention policy and returns the names of the
+// checkpoints it removed. Save calls it automatically.
+func (m *FileCheckpointManager) Prune() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pruneLocked()
+}
+
+func (m *FileCheckpointManager) pruneLocked() ([]string, error) {
+	if m.retention.unlimited() {
+		return nil, nil
+	}
+
+	infos, err := m.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, name := range m.retention.expired(infos) {
+		if err := os.Remove(m.path(name)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+func (r RetentionPolicy) unlimited() bool {
+	return r.MaxCount <= 0 && r.MaxAge <= 0 && r.MaxTotalSize <= 0
+}
+
+// expired returns the names of checkpoints, given oldest first, that fall
+// outside the policy. It walks newest to oldest, keeping checkpoints while
+// they fit.
+func (r RetentionPolicy) expired(infos []CheckpointInfo) []string {
+	var names []string
+	var kept int
+	var total int64
+	cutoff := time.Now().Add(-r.MaxAge)
+	for i := len(infos) - 1; i >= 0; i-- {
+		info := infos[i]
+		keep := kept == 0 ||
+			((r.MaxCount <= 0 || kept < r.MaxCount) &&
+				(r.MaxAge <= 0 || info.Timestamp.After(cutoff)) &&
+				(r.MaxTotalSize <= 0 || total+info.Size <= r.MaxTotalSize))
+		if keep {
+			kept++
+			total += info.Size
+			continue
+		}
+		names = append(names, info.Name)
+	}
+	return names
 }
 
 func (m *FileCheckpointManager) Restore(name string) (SessionSnapshot, error) {
-	path := filepath.Join(m.dir, name+".json")
+	path := m.path(name)
+
+	m.mu.RLock()
 	data, err := os.ReadFile(path)
+	m.mu.RUnlock()
 	if err != nil {
 		return SessionSnapshot{}, fmt.Errorf("read checkpoint %q: %w", name, err)
 	}
@@ -78,6 +233,13 @@ func (m *FileCheckpointManager) Restore(name string) (SessionSnapshot, error) {
 }
 
 func (m *FileCheckpointManager) List() ([]CheckpointInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.list()
+}
+
+// list returns checkpoints ordered oldest first. Callers hold m.mu.
+func (m *FileCheckpointManager) list() ([]CheckpointInfo, error) {
 	entries, err := os.ReadDir(m.dir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -91,7 +253,7 @@ func (m *FileCheckpointManager) List() ([]CheckpointInfo, error) {
 		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
 			continue
 		}
-		name := e.Name()[:len(e.Name())-5] // strip .json
+		name := m.style.ParseFileName(e.Name()[:len(e.Name())-5]) // strip .json
 		info, err := e.Info()
 		if err != nil {
 			continue
@@ -99,6 +261,7 @@ func (m *FileCheckpointManager) List() ([]CheckpointInfo, error) {
 		infos = append(infos, CheckpointInfo{
 			Name:      name,
 			Timestamp: info.ModTime(),
+			Size:      info.Size(),
 		})
 	}
 
@@ -145,7 +308,7 @@ func diffSnapshots(a, b SessionSnapshot) []Change {
 					changes = append(changes, Change{Scope: scope, Key: key, Before: valA, Type: "removed"})
 				} else if valB, ok := bScope[key]; !ok {
 					changes = append(changes, Change{Scope: scope, Key: key, Before: valA, Type: "removed"})
-				} else if fmt.Sprintf("%v", valA) != fmt.Sprintf("%v", valB) {
+				} else if !jsonEqual(valA, valB) {
 					changes = append(changes, Change{Scope: scope, Key: key, Before: valA, After: valB, Type: "modified"})
 				}
 			}
@@ -169,20 +332,163 @@ func diffSnapshots(a, b SessionSnapshot) []Change {
 	return changes
 }
 
+// jsonEqual compares two values by their JSON encoding, so values decoded
+// from JSON compare equal to the Go values they were encoded from.
+func jsonEqual(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+	}
+	return bytes.Equal(ja, jb)
+}
+
+// applyChanges returns a copy of base with changes, as produced by
+// diffSnapshots, applied. Scopes left empty are dropped.
+func applyChanges(base SessionSnapshot, changes []Change) SessionSnapshot {
+	out := SessionSnapshot{
+		ContextState: make(map[string]map[string]any, len(base.ContextState)),
+		WorkdirHash:  base.WorkdirHash,
+		Timestamp:    base.Timestamp,
+	}
+	for scope, items := range base.ContextState {
+		copied := make(map[string]any, len(items))
+		for k, v := range items {
+			copied[k] = v
+		}
+		out.ContextState[scope] = copied
+	}
+
+	for _, c := range changes {
+		switch c.Type {
+		case "removed":
+			delete(out.ContextState[c.Scope], c.Key)
+			if len(out.ContextState[c.Scope]) == 0 {
+				delete(out.ContextState, c.Scope)
+			}
+		default:
+			if out.ContextState[c.Scope] == nil {
+				out.ContextState[c.Scope] = make(map[string]any)
+			}
+			out.ContextState[c.Scope][c.Key] = c.After
+		}
+	}
+	return out
+}
+
+// snapshotScopes are the context scopes captured in a snapshot.
+var snapshotScopes = []string{
+	agshctx.ScopeProject,
+	agshctx.ScopeSession,
+	agshctx.ScopeStep,
+}
+
+// SnapshotFilter limits what CaptureSnapshot records. The zero value
+// captures every key in every snapshot scope.
+type SnapshotFilter struct {
+	Scopes       []string `json:"scopes,omitempty"`         // subset of project, session, step
+	Include      []string `json:"include,omitempty"`        // key patterns (path.Match); empty = all
+	Exclude      []string `json:"exclude,omitempty"`        // key patterns to skip
+	MaxValueSize int64    `json:"max_value_size,omitempty"` // skip values whose JSON is larger; 0 = no limit
+}
+
+func (f *SnapshotFilter) scopes() []string {
+	if f == nil || len(f.Scopes) == 0 {
+		return snapshotScopes
+	}
+	return f.Scopes
+}
+
+// matches reports whether the filter's key patterns select key.
+func (f *SnapshotFilter) matches(key string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Include) > 0 && !matchAny(f.Include, key) {
+		return false
+	}
+	return !matchAny(f.Exclude, key)
+}
+
+// fits reports whether val is within the filter's MaxValueSize.
+func (f *SnapshotFilter) fits(val any) bool {
+	if f == nil || f.MaxValueSize <= 0 {
+		return true
+	}
+	data, err := json.Marshal(val)
+	return err == nil && int64(len(data)) <= f.MaxValueSize
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SnapshotOption configures CaptureSnapshot.
+type SnapshotOption func(*SnapshotFilter)
+
+// WithScopes captures only the given scopes.
+func WithScopes(scopes ...string) SnapshotOption {
+	return func(f *SnapshotFilter) {
+		f.Scopes = scopes
+	}
+}
+
+// WithKeyPatterns captures only keys matching an include pattern (all keys
+// when include is empty) and no exclude pattern.
+func WithKeyPatterns(include, exclude []string) SnapshotOption {
+	return func(f *SnapshotFilter) {
+		f.Include = include
+		f.Exclude = exclude
+	}
+}
+
+// WithMaxValueSize skips values whose JSON encoding exceeds n bytes.
+func WithMaxValueSize(n int64) SnapshotOption {
+	return func(f *SnapshotFilter) {
+		f.MaxValueSize = n
+	}
+}
+
 // CaptureSnapshot takes a snapshot of the current context store state.
-func CaptureSnapshot(store agshctx.ContextStore, workdir string) (SessionSnapshot, error) {
-	scopes := []string{
-		agshctx.ScopeProject,
-		agshctx.ScopeSession,
-		agshctx.ScopeStep,
+// Options restrict the capture to some scopes or keys.
+func CaptureSnapshot(store agshctx.ContextStore, workdir string, opts ...SnapshotOption) (SessionSnapshot, error) {
+	var filter *SnapshotFilter
+	if len(opts) > 0 {
+		filter = &SnapshotFilter{}
+		for _, opt := range opts {
+			opt(filter)
+		}
+		for _, scope := range filter.Scopes {
+			if !isSnapshotScope(scope) {
+				return SessionSnapshot{}, fmt.Errorf("cannot snapshot scope %q", scope)
+			}
+		}
 	}
 
 	state := make(map[string]map[string]any)
-	for _, scope := range scopes {
+	var skipped map[string][]string
+	for _, scope := range filter.scopes() {
 		items, err := store.List(scope)
 		if err != nil {
 			return SessionSnapshot{}, fmt.Errorf("list scope %s: %w", scope, err)
 		}
+		for key, val := range items {
+			if !filter.matches(key) {
+				delete(items, key)
+			} else if !filter.fits(val) {
+				delete(items, key)
+				if skipped == nil {
+					skipped = make(map[string][]string)
+				}
+				skipped[scope] = append(skipped[scope], key)
+			}
+		}
+		sort.Strings(skipped[scope])
 		if len(items) > 0 {
 			state[scope] = items
 		}
@@ -200,11 +506,63 @@ func CaptureSnapshot(store agshctx.ContextStore, workdir string) (SessionSnapsho
 		ContextState: state,
 		WorkdirHash:  hash,
 		Timestamp:    time.Now(),
+		Filter:       filter,
+		Skipped:      skipped,
 	}, nil
 }
 
-// RestoreSnapshot writes a snapshot back into the context store.
-func RestoreSnapshot(store agshctx.ContextStore, snap SessionSnapshot) error {
+func isSnapshotScope(scope string) bool {
+	for _, s := range snapshotScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RestoreOption configures RestoreSnapshot.
+type RestoreOption func(*restoreOptions)
+
+type restoreOptions struct {
+	additive bool
+}
+
+// WithAdditiveRestore only writes the snapshot's keys back, leaving keys
+// created since the snapshot in place.
+func WithAdditiveRestore() RestoreOption {
+	return func(o *restoreOptions) {
+		o.additive = true
+	}
+}
+
+// RestoreSnapshot returns the context store to the state in snap. Each
+// snapshotted scope is reconciled fully: keys created since the snapshot are
+// deleted, unless WithAdditiveRestore is given. Keys a selective snapshot
+// did not cover, or skipped for their size, are left alone.
+func RestoreSnapshot(store agshctx.ContextStore, snap SessionSnapshot, opts ...RestoreOption) error {
+	var o restoreOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	store = agshctx.WithSource(store, "checkpoint.restore")
+
+	if !o.additive {
+		for _, scope := range snap.Filter.scopes() {
+			current, err := store.List(scope)
+			if err != nil {
+				return fmt.Errorf("list scope %s: %w", scope, err)
+			}
+			for key := range current {
+				if _, ok := snap.ContextState[scope][key]; ok || !snap.Filter.matches(key) || slices.Contains(snap.Skipped[scope], key) {
+					continue
+				}
+				if err := store.Delete(scope, key); err != nil {
+					return fmt.Errorf("restore %s/%s: %w", scope, key, err)
+				}
+			}
+		}
+	}
+
 	for scope, items := range snap.ContextState {
 		for key, val := range items {
 			if err := store.Set(scope, key, val); err != nil {
@@ -215,7 +573,9 @@ func RestoreSnapshot(store agshctx.ContextStore, snap SessionSnapshot) error {
 	return nil
 }
 
-// hashDir computes a quick hash of a directory's file listing for change detection.
+// hashDir computes a quick hash of a directory's file listing for change
+// detection. Relative paths are hashed with forward slashes, so the hash
+// does not depend on the OS.
 func hashDir(dir string) (string, error) {
 	h := sha256.New()
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -223,6 +583,7 @@ func hashDir(dir string) (string, error) {
 			return nil // skip errors
 		}
 		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
 		fmt.Fprintf(h, "%s:%d:%d\n", rel, info.Size(), info.ModTime().Unix())
 		return nil
 	})
@@ -0,0 +1,347 @@
+package verify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// checkpointBucket holds one record per checkpoint, keyed by name.
+const checkpointBucket = "checkpoints"
+
+// boltCheckpoint is the stored form of a checkpoint: either a full snapshot,
+// or a delta holding the changes from the full checkpoint named by Base.
+type boltCheckpoint struct {
+	SavedAt  time.Time        `json:"saved_at"`
+	Snapshot *SessionSnapshot `json:"snapshot,omitempty"`
+
+	Base        string          `json:"base,omitempty"`
+	Changes     []Change        `json:"changes,omitempty"`
+	WorkdirHash string          `json:"workdir_hash,omitempty"`
+	Timestamp   time.Time       `json:"timestamp,omitempty"`
+	Filter      *SnapshotFilter `json:"filter,omitempty"`
+}
+
+func (c boltCheckpoint) isDelta() bool { return c.Snapshot == nil }
+
+// BoltCheckpointManager stores checkpoints in a bbolt database, typically
+// .agsh/checkpoints.db next to the project's context store, so snapshots
+// persist with the project. Saves and pruning share one transaction.
+//
+// With WithDeltas, checkpoints are stored as diffs against the latest full
+// snapshot. Deltas always reference a full snapshot directly, so restoring
+// applies at most one diff; deleting a base promotes its oldest dependent to
+// a full snapshot and rebases the rest onto it.
+type BoltCheckpointManager struct {
+	db         *bolt.DB
+	retention  RetentionPolicy
+	deltaLimit int
+}
+
+// NewBoltCheckpointManager opens (or creates) a checkpoint database at path.
+func NewBoltCheckpointManager(path string, opts ...CheckpointOption) (*BoltCheckpointManager, error) {
+	o := applyCheckpointOptions(opts)
+	if o.lockTimeout == 0 {
+		o.lockTimeout = 1 * time.Second
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: o.lockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open checkpoint db: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(checkpointBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init checkpoint bucket: %w", err)
+	}
+
+	return &BoltCheckpointManager{db: db, retention: o.retention, deltaLimit: o.deltaLimit}, nil
+}
+
+func (m *BoltCheckpointManager) Save(name string, state SessionSnapshot) error {
+	return m.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(checkpointBucket))
+
+		// Overwriting a base would invalidate its deltas.
+		if err := m.remove(b, name); err != nil && !errors.Is(err, errCheckpointNotFound) {
+			return err
+		}
+
+		data, err := m.encode(b, state)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(name), data); err != nil {
+			return fmt.Errorf("save checkpoint %q: %w", name, err)
+		}
+		if m.retention.unlimited() {
+			return nil
+		}
+
+		infos, err := listBucket(b)
+		if err != nil {
+			return err
+		}
+		for _, expired := range m.retention.expired(infos) {
+			if err := m.remove(b, expired); err != nil {
+				return fmt.Errorf("prune checkpoint %q: %w", expired, err)
+			}
+		}
+		return nil
+	})
+}
+
+// encode builds the stored record for state, as a delta against the latest
+// full snapshot when deltas are enabled and worthwhile.
+func (m *BoltCheckpointManager) encode(b *bolt.Bucket, state SessionSnapshot) ([]byte, error) {
+	now := time.Now()
+	full, err := json.Marshal(boltCheckpoint{SavedAt: now, Snapshot: &state})
+	if err != nil {
+		return nil, fmt.Errorf("marshal checkpoint: %w", err)
+	}
+	if m.deltaLimit <= 0 {
+		return full, nil
+	}
+
+	baseName, base, deltas, err := latestBase(b)
+	if err != nil || baseName == "" || deltas >= m.deltaLimit {
+		return full, err
+	}
+
+	delta, err := json.Marshal(boltCheckpoint{
+		SavedAt:     now,
+		Base:        baseName,
+		Changes:     diffSnapshots(base, state),
+		WorkdirHash: state.WorkdirHash,
+		Timestamp:   state.Timestamp,
+		Filter:      state.Filter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal checkpoint: %w", err)
+	}
+
+	// Compact: once a delta is no longer much smaller than a full copy,
+	// start a new base.
+	if len(delta)*2 >= len(full) {
+		return full, nil
+	}
+	return delta, nil
+}
+
+// latestBase returns the most recently saved full snapshot and how many
+// deltas reference it.
+func latestBase(b *bolt.Bucket) (string, SessionSnapshot, int, error) {
+	var name string
+	var base boltCheckpoint
+	records := make(map[string]boltCheckpoint)
+	err := b.ForEach(func(k, v []byte) error {
+		var cp boltCheckpoint
+		if err := json.Unmarshal(v, &cp); err != nil {
+			return fmt.Errorf("parse checkpoint %q: %w", string(k), err)
+		}
+		records[string(k)] = cp
+		if !cp.isDelta() && (name == "" || cp.SavedAt.After(base.SavedAt)) {
+			name, base = string(k), cp
+		}
+		return nil
+	})
+	if err != nil || name == "" {
+		return "", SessionSnapshot{}, 0, err
+	}
+
+	deltas := 0
+	for _, cp := range records {
+		if cp.Base == name {
+			deltas++
+		}
+	}
+	return name, *base.Snapshot, deltas, nil
+}
+
+// errCheckpointNotFound is returned by remove for unknown names.
+var errCheckpointNotFound = errors.New("not found")
+
+// remove deletes a checkpoint. If it is the base of any deltas, the oldest
+// delta is promoted to a full snapshot and the others are rebased onto it.
+func (m *BoltCheckpointManager) remove(b *bolt.Bucket, name string) error {
+	cp, err := getRecord(b, name)
+	if err != nil {
+		return err
+	}
+	if err := b.Delete([]byte(name)); err != nil {
+		return err
+	}
+	if cp.isDelta() {
+		return nil
+	}
+
+	type dependent struct {
+		name string
+		cp   boltCheckpoint
+	}
+	var deps []dependent
+	err = b.ForEach(func(k, v []byte) error {
+		var d boltCheckpoint
+		if err := json.Unmarshal(v, &d); err != nil {
+			return fmt.Errorf("parse checkpoint %q: %w", string(k), err)
+		}
+		if d.Base == name {
+			deps = append(deps, dependent{string(k), d})
+		}
+		return nil
+	})
+	if err != nil || len(deps) == 0 {
+		return err
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].cp.SavedAt.Before(deps[j].cp.SavedAt) })
+
+	base := *cp.Snapshot
+	newBase := resolveDelta(base, deps[0].cp)
+	for i, d := range deps {
+		var rec boltCheckpoint
+		if i == 0 {
+			rec = boltCheckpoint{SavedAt: d.cp.SavedAt, Snapshot: &newBase}
+		} else {
+			snap := resolveDelta(base, d.cp)
+			rec = boltCheckpoint{
+				SavedAt:     d.cp.SavedAt,
+				Base:        deps[0].name,
+				Changes:     diffSnapshots(newBase, snap),
+				WorkdirHash: snap.WorkdirHash,
+				Timestamp:   snap.Timestamp,
+				Filter:      snap.Filter,
+			}
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("marshal checkpoint: %w", err)
+		}
+		if err := b.Put([]byte(d.name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveDelta reconstructs the snapshot stored by a delta record.
+func resolveDelta(base SessionSnapshot, cp boltCheckpoint) SessionSnapshot {
+	snap := applyChanges(base, cp.Changes)
+	snap.WorkdirHash = cp.WorkdirHash
+	snap.Timestamp = cp.Timestamp
+	snap.Filter = cp.Filter
+	return snap
+}
+
+func getRecord(b *bolt.Bucket, name string) (boltCheckpoint, error) {
+	var cp boltCheckpoint
+	data := b.Get([]byte(name))
+	if data == nil {
+		return cp, fmt.Errorf("checkpoint %q %w", name, errCheckpointNotFound)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("parse checkpoint %q: %w", name, err)
+	}
+	return cp, nil
+}
+
+func (m *BoltCheckpointManager) Restore(name string) (SessionSnapshot, error) {
+	var snap SessionSnapshot
+	err := m.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(checkpointBucket))
+		cp, err := getRecord(b, name)
+		if err != nil {
+			return err
+		}
+		if !cp.isDelta() {
+			snap = *cp.Snapshot
+			return nil
+		}
+
+		base, err := getRecord(b, cp.Base)
+		if err != nil {
+			return fmt.Errorf("base of checkpoint %q: %w", name, err)
+		}
+		if base.isDelta() {
+			return fmt.Errorf("base of checkpoint %q: %q is not a full snapshot", name, cp.Base)
+		}
+		snap = resolveDelta(*base.Snapshot, cp)
+		return nil
+	})
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	return snap, nil
+}
+
+func (m *BoltCheckpointManager) List() ([]CheckpointInfo, error) {
+	var infos []CheckpointInfo
+	err := m.db.View(func(tx *bolt.Tx) error {
+		var err error
+		infos, err = listBucket(tx.Bucket([]byte(checkpointBucket)))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list checkpoints: %w", err)
+	}
+	return infos, nil
+}
+
+func (m *BoltCheckpointManager) Delete(name string) error {
+	return m.db.Update(func(tx *bolt.Tx) error {
+		if err := m.remove(tx.Bucket([]byte(checkpointBucket)), name); err != nil {
+			return fmt.Errorf("delete checkpoint: %w", err)
+		}
+		return nil
+	})
+}
+
+func (m *BoltCheckpointManager) Diff(a, b string) ([]Change, error) {
+	snapA, err := m.Restore(a)
+	if err != nil {
+		return nil, fmt.Errorf("load checkpoint %q: %w", a, err)
+	}
+	snapB, err := m.Restore(b)
+	if err != nil {
+		return nil, fmt.Errorf("load checkpoint %q: %w", b, err)
+	}
+	return diffSnapshots(snapA, snapB), nil
+}
+
+// Close closes the underlying database.
+func (m *BoltCheckpointManager) Close() error {
+	return m.db.Close()
+}
+
+// listBucket returns checkpoint metadata ordered oldest first.
+func listBucket(b *bolt.Bucket) ([]CheckpointInfo, error) {
+	var infos []CheckpointInfo
+	err := b.ForEach(func(k, v []byte) error {
+		var meta struct {
+			SavedAt time.Time `json:"saved_at"`
+		}
+		if err := json.Unmarshal(v, &meta); err != nil {
+			return fmt.Errorf("parse checkpoint %q: %w", string(k), err)
+		}
+		infos = append(infos, CheckpointInfo{
+			Name:      string(k),
+			Timestamp: meta.SavedAt,
+			Size:      int64(len(v)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Timestamp.Before(infos[j].Timestamp)
+	})
+	return infos, nil
+}
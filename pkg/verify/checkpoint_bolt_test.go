@@ -0,0 +1,217 @@
+package verify
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestBoltManager(t *testing.T, opts ...CheckpointOption) (*BoltCheckpointManager, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "checkpoints.db")
+	mgr, err := NewBoltCheckpointManager(path, opts...)
+	if err != nil {
+		t.Fatalf("NewBoltCheckpointManager: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	return mgr, path
+}
+
+func TestBoltCheckpointSaveRestore(t *testing.T) {
+	mgr, path := newTestBoltManager(t)
+
+	snap := SessionSnapshot{
+		ContextState: map[string]map[string]any{
+			"session": {"key1": "val1"},
+		},
+		WorkdirHash: "abc123",
+		Timestamp:   time.Now(),
+	}
+	if err := mgr.Save("cp-1", snap); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Reopen to check the checkpoint persisted.
+	mgr.Close()
+	mgr, err := NewBoltCheckpointManager(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer mgr.Close()
+
+	restored, err := mgr.Restore("cp-1")
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.WorkdirHash != "abc123" {
+		t.Errorf("WorkdirHash = %q, want %q", restored.WorkdirHash, "abc123")
+	}
+	if restored.ContextState["session"]["key1"] != "val1" {
+		t.Errorf("session.key1 = %v", restored.ContextState["session"]["key1"])
+	}
+
+	if _, err := mgr.Restore("missing"); err == nil {
+		t.Error("expected error for missing checkpoint")
+	}
+}
+
+func TestBoltCheckpointListDeleteDiff(t *testing.T) {
+	mgr, _ := newTestBoltManager(t)
+
+	mgr.Save("a", SessionSnapshot{ContextState: map[string]map[string]any{"session": {"k": "1"}}})
+	mgr.Save("b", SessionSnapshot{ContextState: map[string]map[string]any{"session": {"k": "2"}}})
+
+	infos, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Name != "a" || infos[1].Name != "b" {
+		t.Fatalf("List = %+v, want [a b]", infos)
+	}
+	if infos[0].Size == 0 {
+		t.Error("expected non-zero size")
+	}
+
+	changes, err := mgr.Diff("a", "b")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Type != "modified" {
+		t.Errorf("Diff = %+v, want one modification", changes)
+	}
+
+	if err := mgr.Delete("a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := mgr.Delete("a"); err == nil {
+		t.Error("expected error deleting missing checkpoint")
+	}
+	infos, _ = mgr.List()
+	if len(infos) != 1 {
+		t.Errorf("expected 1 checkpoint after delete, got %d", len(infos))
+	}
+}
+
+func TestBoltCheckpointRetention(t *testing.T) {
+	mgr, _ := newTestBoltManager(t, WithRetention(RetentionPolicy{MaxCount: 2}))
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := mgr.Save(name, SessionSnapshot{}); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	infos, _ := mgr.List()
+	if len(infos) != 2 || infos[0].Name != "b" || infos[1].Name != "c" {
+		t.Errorf("List = %+v, want [b c]", infos)
+	}
+}
+
+// bigSnapshot returns a snapshot large enough that a one-key change is
+// stored as a delta.
+func bigSnapshot(v string) SessionSnapshot {
+	session := map[string]any{"changing": v}
+	for i := 0; i < 50; i++ {
+		session[fmt.Sprintf("key%02d", i)] = strings.Repeat("x", 20)
+	}
+	return SessionSnapshot{
+		ContextState: map[string]map[string]any{"session": session},
+		WorkdirHash:  "hash-" + v,
+	}
+}
+
+func storedRecord(t *testing.T, mgr *BoltCheckpointManager, name string) boltCheckpoint {
+	t.Helper()
+	var cp boltCheckpoint
+	err := mgr.db.View(func(tx *bolt.Tx) error {
+		var err error
+		cp, err = getRecord(tx.Bucket([]byte(checkpointBucket)), name)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return cp
+}
+
+func TestBoltCheckpointDeltas(t *testing.T) {
+	mgr, _ := newTestBoltManager(t, WithDeltas(2))
+
+	for i, name := range []string{"a", "b", "c", "d"} {
+		if err := mgr.Save(name, bigSnapshot(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a is the base, b and c are deltas, d starts a new base after the limit.
+	for name, delta := range map[string]bool{"a": false, "b": true, "c": true, "d": false} {
+		if got := storedRecord(t, mgr, name).isDelta(); got != delta {
+			t.Errorf("%s delta = %v, want %v", name, got, delta)
+		}
+	}
+	if base := storedRecord(t, mgr, "b").Base; base != "a" {
+		t.Errorf("b base = %q, want a", base)
+	}
+
+	for i, name := range []string{"a", "b", "c", "d"} {
+		snap, err := mgr.Restore(name)
+		if err != nil {
+			t.Fatalf("Restore %s: %v", name, err)
+		}
+		if got := snap.ContextState["session"]["changing"]; got != fmt.Sprint(i) {
+			t.Errorf("%s changing = %v, want %d", name, got, i)
+		}
+		if snap.WorkdirHash != fmt.Sprintf("hash-%d", i) {
+			t.Errorf("%s WorkdirHash = %q", name, snap.WorkdirHash)
+		}
+		if len(snap.ContextState["session"]) != 51 {
+			t.Errorf("%s has %d keys, want 51", name, len(snap.ContextState["session"]))
+		}
+	}
+}
+
+func TestBoltCheckpointDeltaCompaction(t *testing.T) {
+	mgr, _ := newTestBoltManager(t, WithDeltas(10))
+
+	mgr.Save("a", SessionSnapshot{ContextState: map[string]map[string]any{"session": {"k": "1"}}})
+	mgr.Save("b", SessionSnapshot{ContextState: map[string]map[string]any{"other": {"j": "2"}}})
+
+	// Nothing in common: the delta would be larger than a full copy.
+	if storedRecord(t, mgr, "b").isDelta() {
+		t.Error("expected b to be stored in full")
+	}
+}
+
+func TestBoltCheckpointDeleteBase(t *testing.T) {
+	mgr, _ := newTestBoltManager(t, WithDeltas(10))
+
+	for i, name := range []string{"a", "b", "c"} {
+		mgr.Save(name, bigSnapshot(fmt.Sprint(i)))
+		time.Sleep(time.Millisecond)
+	}
+	if err := mgr.Delete("a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if storedRecord(t, mgr, "b").isDelta() {
+		t.Error("expected b to be promoted to a full snapshot")
+	}
+	if base := storedRecord(t, mgr, "c").Base; base != "b" {
+		t.Errorf("c base = %q, want b", base)
+	}
+	for i, name := range []string{"b", "c"} {
+		snap, err := mgr.Restore(name)
+		if err != nil {
+			t.Fatalf("Restore %s: %v", name, err)
+		}
+		if got := snap.ContextState["session"]["changing"]; got != fmt.Sprint(i+1) {
+			t.Errorf("%s changing = %v, want %d", name, got, i+1)
+		}
+	}
+}
@@ -3,9 +3,11 @@ package verify
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/cgast/agsh/internal/paths"
 	agshctx "github.com/cgast/agsh/pkg/context"
 )
 
@@ -79,6 +81,120 @@ func TestFileCheckpointList(t *testing.T) {
 	}
 }
 
+// Checkpoint names hold characters Windows does not allow in file names.
+func TestFileCheckpointWindowsNames(t *testing.T) {
+	dir := t.TempDir()
+	mgr, err := NewFileCheckpointManager(dir)
+	if err != nil {
+		t.Fatalf("NewFileCheckpointManager: %v", err)
+	}
+	mgr.style = paths.Windows
+
+	name := "run-step-1-fs:write"
+	if err := mgr.Save(name, SessionSnapshot{Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "run-step-1-fs%3Awrite.json")); err != nil {
+		t.Errorf("checkpoint file: %v", err)
+	}
+	if _, err := mgr.Restore(name); err != nil {
+		t.Errorf("Restore: %v", err)
+	}
+	infos, err := mgr.List()
+	if err != nil || len(infos) != 1 || infos[0].Name != name {
+		t.Errorf("List() = %v, %v, want %s", infos, err, name)
+	}
+	if err := mgr.Delete(name); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+}
+
+func TestFileCheckpointDelete(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	mgr, err := NewFileCheckpointManager(dir)
+	if err != nil {
+		t.Fatalf("NewFileCheckpointManager: %v", err)
+	}
+
+	mgr.Save("cp-a", SessionSnapshot{Timestamp: time.Now()})
+	if err := mgr.Delete("cp-a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := mgr.Restore("cp-a"); err == nil {
+		t.Error("expected error restoring deleted checkpoint")
+	}
+	if err := mgr.Delete("cp-a"); err == nil {
+		t.Error("expected error deleting missing checkpoint")
+	}
+}
+
+// saveAt saves a checkpoint and backdates its file so List ordering is
+// deterministic.
+func saveAt(t *testing.T, mgr *FileCheckpointManager, dir, name string, at time.Time) {
+	t.Helper()
+	if err := mgr.Save(name, SessionSnapshot{Timestamp: at}); err != nil {
+		t.Fatalf("Save %s: %v", name, err)
+	}
+	path := filepath.Join(dir, name+".json")
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkpointNames(t *testing.T, mgr *FileCheckpointManager) []string {
+	t.Helper()
+	infos, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+	return names
+}
+
+func TestFileCheckpointRetention(t *testing.T) {
+	now := time.Now()
+
+	t.Run("max count", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "checkpoints")
+		mgr, _ := NewFileCheckpointManager(dir, WithRetention(RetentionPolicy{MaxCount: 2}))
+		for i, name := range []string{"a", "b", "c"} {
+			saveAt(t, mgr, dir, name, now.Add(time.Duration(i)*time.Minute))
+		}
+		mgr.Prune()
+		names := checkpointNames(t, mgr)
+		if len(names) != 2 || names[0] != "b" || names[1] != "c" {
+			t.Errorf("expected [b c], got %v", names)
+		}
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "checkpoints")
+		mgr, _ := NewFileCheckpointManager(dir, WithRetention(RetentionPolicy{MaxAge: time.Hour}))
+		saveAt(t, mgr, dir, "old", now.Add(-2*time.Hour))
+		// Saving prunes automatically.
+		saveAt(t, mgr, dir, "new", now)
+		names := checkpointNames(t, mgr)
+		if len(names) != 1 || names[0] != "new" {
+			t.Errorf("expected [new], got %v", names)
+		}
+	})
+
+	t.Run("max total size keeps newest", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "checkpoints")
+		mgr, _ := NewFileCheckpointManager(dir, WithRetention(RetentionPolicy{MaxTotalSize: 1}))
+		saveAt(t, mgr, dir, "a", now.Add(-time.Minute))
+		saveAt(t, mgr, dir, "b", now)
+		mgr.Prune()
+		names := checkpointNames(t, mgr)
+		if len(names) != 1 || names[0] != "b" {
+			t.Errorf("expected [b], got %v", names)
+		}
+	})
+}
+
 func TestFileCheckpointDiff(t *testing.T) {
 	dir := filepath.Join(t.TempDir(), "checkpoints")
 	mgr, err := NewFileCheckpointManager(dir)
@@ -196,3 +312,101 @@ func TestRestoreSnapshot(t *testing.T) {
 		t.Errorf("restored value = %v, want %q", val, "restored_val")
 	}
 }
+
+func TestCaptureSnapshotSelective(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := agshctx.NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer store.Close()
+
+	store.Set(agshctx.ScopeProject, "name", "p")
+	store.Set(agshctx.ScopeSession, "user.name", "a")
+	store.Set(agshctx.ScopeSession, "user.token", "secret")
+	store.Set(agshctx.ScopeSession, "cache", strings.Repeat("x", 100))
+
+	snap, err := CaptureSnapshot(store, "",
+		WithScopes(agshctx.ScopeSession),
+		WithKeyPatterns(nil, []string{"*.token"}),
+		WithMaxValueSize(50),
+	)
+	if err != nil {
+		t.Fatalf("CaptureSnapshot: %v", err)
+	}
+	if _, ok := snap.ContextState[agshctx.ScopeProject]; ok {
+		t.Error("project scope should not be captured")
+	}
+	session := snap.ContextState[agshctx.ScopeSession]
+	if len(session) != 1 || session["user.name"] != "a" {
+		t.Errorf("session = %v, want only user.name", session)
+	}
+
+	if got := snap.Skipped[agshctx.ScopeSession]; len(got) != 1 || got[0] != "cache" {
+		t.Errorf("skipped = %v, want [cache]", got)
+	}
+
+	// Restoring leaves uncovered keys alone, as well as keys skipped for
+	// their size, even once they are small enough to have been captured.
+	store.Set(agshctx.ScopeSession, "user.email", "new")
+	store.Set(agshctx.ScopeSession, "cache", "x")
+	if err := RestoreSnapshot(store, snap); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if _, err := store.Get(agshctx.ScopeSession, "user.email"); err == nil {
+		t.Error("expected covered key user.email to be removed")
+	}
+	for _, key := range []string{"user.token", "cache"} {
+		if _, err := store.Get(agshctx.ScopeSession, key); err != nil {
+			t.Errorf("uncovered key %s was removed", key)
+		}
+	}
+	if _, err := store.Get(agshctx.ScopeProject, "name"); err != nil {
+		t.Error("project scope was modified")
+	}
+
+	if _, err := CaptureSnapshot(store, "", WithScopes("history")); err == nil {
+		t.Error("expected error for unsupported scope")
+	}
+}
+
+func TestRestoreSnapshotRemovesNewKeys(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := agshctx.NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer store.Close()
+
+	store.Set(agshctx.ScopeSession, "kept", "before")
+	snap, err := CaptureSnapshot(store, "")
+	if err != nil {
+		t.Fatalf("CaptureSnapshot: %v", err)
+	}
+
+	store.Set(agshctx.ScopeSession, "kept", "after")
+	store.Set(agshctx.ScopeSession, "added", "x")
+	store.Set(agshctx.ScopeProject, "added", "y")
+
+	if err := RestoreSnapshot(store, snap); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if val, _ := store.Get(agshctx.ScopeSession, "kept"); val != "before" {
+		t.Errorf("kept = %v, want before", val)
+	}
+	if _, err := store.Get(agshctx.ScopeSession, "added"); err == nil {
+		t.Error("expected session/added to be removed")
+	}
+	if _, err := store.Get(agshctx.ScopeProject, "added"); err == nil {
+		t.Error("expected project/added to be removed")
+	}
+
+	// Additive restore leaves new keys alone.
+	store.Set(agshctx.ScopeSession, "added", "x")
+	if err := RestoreSnapshot(store, snap, WithAdditiveRestore()); err != nil {
+		t.Fatalf("RestoreSnapshot additive: %v", err)
+	}
+	if val, _ := store.Get(agshctx.ScopeSession, "added"); val != "x" {
+		t.Errorf("additive restore removed session/added (got %v)", val)
+	}
+}
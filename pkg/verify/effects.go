@@ -0,0 +1,167 @@
+package verify
+
+import (
+	gocontext "context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cgast/agsh/internal/paths"
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+// Run-level assertions check what a run did rather than what it produced:
+// they are evaluated against the effects its steps recorded (the files
+// they wrote and the hosts they contacted), so a spec can declare what the
+// agent must not have done.
+func init() {
+	RegisterContextChecker("no_writes_outside", checkNoWritesOutside)
+	RegisterContextChecker("no_network_calls", checkNoNetworkCalls)
+	RegisterContextChecker("max_files_written", checkMaxFilesWritten)
+}
+
+// WithRunEffects sets the effects of the run being verified, which
+// run-level assertions check. Without them, those assertions fail.
+func WithRunEffects(effects agshctx.Effects) Option {
+	return func(e *DefaultEngine) {
+		e.effects = &effects
+	}
+}
+
+type runEffectsKey struct{}
+
+// runEffects returns the effects the engine handed the checkers in ctx.
+func runEffects(ctx gocontext.Context, assertion Assertion) (agshctx.Effects, *AssertionResult) {
+	if effects, ok := ctx.Value(runEffectsKey{}).(agshctx.Effects); ok {
+		return effects, nil
+	}
+	return agshctx.Effects{}, &AssertionResult{
+		Assertion: assertion,
+		Passed:    false,
+		Message:   fmt.Sprintf("%s: no run effects to check (only success criteria can check what a run did)", assertion.Type),
+	}
+}
+
+// checkNoWritesOutside verifies that every file the run wrote lies within
+// the expected directory, or one of the expected list of directories.
+// Relative directories are taken from the working directory.
+func checkNoWritesOutside(ctx gocontext.Context, _ agshctx.Envelope, assertion Assertion) AssertionResult {
+	effects, failed := runEffects(ctx, assertion)
+	if failed != nil {
+		return *failed
+	}
+	dirs := toStrings(assertion.Expected)
+	if len(dirs) == 0 {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   "no_writes_outside: no directory (set expected)",
+		}
+	}
+	for i, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dirs[i] = abs
+		}
+	}
+
+	var outside []string
+	for _, path := range effects.Writes {
+		if !slices.ContainsFunc(dirs, func(dir string) bool { return within(dir, path) }) {
+			outside = append(outside, path)
+		}
+	}
+	passed := len(outside) == 0
+	msg := assertion.Message
+	if !passed && msg == "" {
+		msg = fmt.Sprintf("wrote outside %s: %s", strings.Join(toStrings(assertion.Expected), ", "), strings.Join(outside, ", "))
+	}
+	return AssertionResult{
+		Assertion: assertion,
+		Passed:    passed,
+		Actual:    outside,
+		Message:   msg,
+	}
+}
+
+// checkNoNetworkCalls verifies that the run contacted no host, other than
+// those in the optional expected list.
+func checkNoNetworkCalls(ctx gocontext.Context, _ agshctx.Envelope, assertion Assertion) AssertionResult {
+	effects, failed := runEffects(ctx, assertion)
+	if failed != nil {
+		return *failed
+	}
+	allowed := toStrings(assertion.Expected)
+	var contacted []string
+	for _, host := range effects.Domains {
+		if !slices.ContainsFunc(allowed, func(a string) bool { return strings.EqualFold(a, host) }) {
+			contacted = append(contacted, host)
+		}
+	}
+	passed := len(contacted) == 0
+	msg := assertion.Message
+	if !passed && msg == "" {
+		msg = fmt.Sprintf("contacted %s", strings.Join(contacted, ", "))
+	}
+	return AssertionResult{
+		Assertion: assertion,
+		Passed:    passed,
+		Actual:    contacted,
+		Message:   msg,
+	}
+}
+
+// checkMaxFilesWritten verifies that the run wrote at most the expected
+// number of files.
+func checkMaxFilesWritten(ctx gocontext.Context, _ agshctx.Envelope, assertion Assertion) AssertionResult {
+	effects, failed := runEffects(ctx, assertion)
+	if failed != nil {
+		return *failed
+	}
+	limit, err := toInt(assertion.Expected)
+	if err != nil {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   fmt.Sprintf("max_files_written: invalid expected value: %v", assertion.Expected),
+		}
+	}
+	actual := len(effects.Writes)
+	passed := actual <= limit
+	msg := assertion.Message
+	if !passed && msg == "" {
+		msg = fmt.Sprintf("wrote %d files, more than %d: %s", actual, limit, strings.Join(effects.Writes, ", "))
+	}
+	return AssertionResult{
+		Assertion: assertion,
+		Passed:    passed,
+		Actual:    actual,
+		Message:   msg,
+	}
+}
+
+// within reports whether path is dir or lies below it, compared as the
+// OS compares paths.
+func within(dir, path string) bool {
+	return paths.Native.Within(path, dir)
+}
+
+// toStrings reads an expected value that is a string or a list of them.
+func toStrings(v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return slices.Clone(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
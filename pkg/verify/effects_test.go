@@ -0,0 +1,52 @@
+package verify
+
+import (
+	gocontext "context"
+	"path/filepath"
+	"testing"
+
+	agshctx "github.com/cgast/agsh/pkg/context"
+)
+
+func TestRunLevelAssertions(t *testing.T) {
+	reports, _ := filepath.Abs("reports")
+	effects := agshctx.Effects{
+		Writes:  []string{filepath.Join(reports, "a.md"), filepath.Join(reports, "b.md"), filepath.Join(reports+"-old", "c.md")},
+		Domains: []string{"api.github.com"},
+	}
+	tests := []struct {
+		assertion Assertion
+		passed    bool
+	}{
+		{Assertion{Type: "no_writes_outside", Expected: "./reports"}, false},
+		{Assertion{Type: "no_writes_outside", Expected: []any{"./reports", "./reports-old"}}, true},
+		{Assertion{Type: "no_writes_outside"}, false},
+		{Assertion{Type: "no_network_calls"}, false},
+		{Assertion{Type: "no_network_calls", Expected: []any{"API.github.com"}}, true},
+		{Assertion{Type: "max_files_written", Expected: 3}, true},
+		{Assertion{Type: "max_files_written", Expected: 2}, false},
+		{Assertion{Type: "max_files_written", Expected: "many"}, false},
+	}
+	engine := NewEngine(WithRunEffects(effects))
+	env := agshctx.NewEnvelope("done", "text/plain", "test")
+	for _, tt := range tests {
+		result, err := engine.VerifyContext(gocontext.Background(), env, Intent{Assertions: []Assertion{tt.assertion}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ar := result.Results[0]; ar.Passed != tt.passed {
+			t.Errorf("%s %v: passed = %v (%s)", tt.assertion.Type, tt.assertion.Expected, ar.Passed, ar.Message)
+		}
+	}
+
+	result, _ := engine.VerifyContext(gocontext.Background(), env, Intent{Assertions: []Assertion{{Type: "no_writes_outside", Expected: "./reports"}}})
+	if got := result.Results[0].Actual.([]string); len(got) != 1 || got[0] != filepath.Join(reports+"-old", "c.md") {
+		t.Errorf("writes outside = %v", got)
+	}
+
+	// Without the run's effects, there is nothing to vouch for.
+	result, _ = NewEngine().VerifyContext(gocontext.Background(), env, Intent{Assertions: []Assertion{{Type: "no_network_calls"}}})
+	if result.Passed {
+		t.Error("no_network_calls passed without run effects")
+	}
+}
@@ -1,7 +1,11 @@
 package verify
 
 import (
+	gocontext "context"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	agshctx "github.com/cgast/agsh/pkg/context"
@@ -9,9 +13,21 @@ import (
 
 // VerificationEngine verifies envelopes against intents.
 type VerificationEngine interface {
+	VerifyContext(ctx gocontext.Context, envelope agshctx.Envelope, intent Intent) (VerificationResult, error)
+
+	// Deprecated: Use VerifyContext.
 	Verify(envelope agshctx.Envelope, intent Intent) (VerificationResult, error)
 }
 
+// Engine defaults. Assertions are independent of each other, so up to
+// DefaultConcurrency of them are checked at once; one that takes longer
+// than DefaultAssertionTimeout, such as an llm_judge call that hangs,
+// fails.
+const (
+	DefaultConcurrency      = 4
+	DefaultAssertionTimeout = 2 * time.Minute
+)
+
 // Option configures the DefaultEngine.
 type Option func(*DefaultEngine)
 
@@ -22,61 +38,253 @@ func WithFailFast(ff bool) Option {
 	}
 }
 
+// WithConcurrency sets how many assertions are checked at once; 1 checks
+// them one after another.
+func WithConcurrency(n int) Option {
+	return func(e *DefaultEngine) {
+		e.concurrency = max(n, 1)
+	}
+}
+
+// WithAssertionTimeout sets how long a single assertion may take before
+// it fails; 0 means no limit.
+func WithAssertionTimeout(d time.Duration) Option {
+	return func(e *DefaultEngine) {
+		e.timeout = d
+	}
+}
+
+// WithContextStore sets the store that "context.<scope>.<key>" targets are
+// read from, so assertions can check accumulated state rather than the
+// envelope.
+func WithContextStore(store agshctx.ContextStore) Option {
+	return func(e *DefaultEngine) {
+		e.store = store
+	}
+}
+
+// WithArtifacts sets the files "artifact.<name>" targets read, by name,
+// so assertions can check a run's declared outputs.
+func WithArtifacts(paths map[string]string) Option {
+	return func(e *DefaultEngine) {
+		e.artifacts = paths
+	}
+}
+
+// maxArtifactSize bounds the file an "artifact." target reads.
+const maxArtifactSize = 16 << 20
+
 // DefaultEngine is the standard verification engine.
 type DefaultEngine struct {
-	failFast bool
+	failFast    bool
+	concurrency int
+	timeout     time.Duration
+	store       agshctx.ContextStore
+	artifacts   map[string]string // paths of "artifact." targets, by name
+	effects     *agshctx.Effects  // of the run, for run-level assertions
 }
 
 // NewEngine creates a new verification engine with the given options.
 func NewEngine(opts ...Option) *DefaultEngine {
-	e := &DefaultEngine{}
+	e := &DefaultEngine{concurrency: DefaultConcurrency, timeout: DefaultAssertionTimeout}
 	for _, opt := range opts {
 		opt(e)
 	}
 	return e
 }
 
-// Verify checks an envelope against all assertions in an intent.
+// Verify checks an envelope against an intent with no way to cancel the
+// checks.
+//
+// Deprecated: Use VerifyContext, which stops the checks when its context
+// is done.
 func (e *DefaultEngine) Verify(envelope agshctx.Envelope, intent Intent) (VerificationResult, error) {
-	result := VerificationResult{
-		Passed:    true,
-		Timestamp: time.Now(),
-		Results:   make([]AssertionResult, 0, len(intent.Assertions)),
+	return e.VerifyContext(gocontext.Background(), envelope, intent)
+}
+
+// VerifyContext checks an envelope against all assertions in an intent.
+// The assertions run concurrently, but results are reported in the
+// intent's order. With fail-fast, no further assertions are started once
+// one has failed, and the results end at the first failure.
+//
+// Each checker is handed ctx, bounded by the engine's timeout. Once ctx is
+// done, running checks fail, the assertions not yet started fail as not
+// checked, and the error is the cause of ctx.
+func (e *DefaultEngine) VerifyContext(ctx gocontext.Context, envelope agshctx.Envelope, intent Intent) (VerificationResult, error) {
+	if e.effects != nil {
+		ctx = gocontext.WithValue(ctx, runEffectsKey{}, *e.effects)
+	}
+	results := make([]AssertionResult, len(intent.Assertions))
+	var (
+		mu        sync.Mutex
+		failed    = -1 // index of the first failed assertion
+		scheduled int
+		wg        sync.WaitGroup
+		slots     = make(chan struct{}, max(e.concurrency, 1))
+	)
+	stop := func(i int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return e.failFast && failed >= 0 && failed < i
 	}
+	for i, assertion := range intent.Assertions {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil || stop(i) {
+			break
+		}
+		scheduled++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ar := e.check(ctx, envelope, assertion)
+			mu.Lock()
+			results[i] = ar
+			if !ar.Passed && (failed < 0 || i < failed) {
+				failed = i
+			}
+			mu.Unlock()
+			<-slots
+		}()
+	}
+	wg.Wait()
 
-	for _, assertion := range intent.Assertions {
-		checker := GetChecker(assertion.Type)
-		if checker == nil {
-			ar := AssertionResult{
-				Assertion: assertion,
+	var err error
+	if ctx.Err() != nil {
+		err = gocontext.Cause(ctx)
+		for i := scheduled; i < len(results); i++ {
+			results[i] = AssertionResult{
+				Assertion: intent.Assertions[i],
 				Passed:    false,
-				Message:   fmt.Sprintf("unknown assertion type: %q", assertion.Type),
+				Message:   fmt.Sprintf("%s: not checked: %v", intent.Assertions[i].Type, err),
 			}
-			result.Results = append(result.Results, ar)
-			result.Passed = false
+		}
+		if failed < 0 && scheduled < len(results) {
+			failed = scheduled
+		}
+	}
+	if e.failFast && failed >= 0 {
+		results = results[:failed+1]
+	}
+	result := VerificationResult{
+		Passed:    failed < 0,
+		Timestamp: time.Now(),
+		Results:   results,
+	}
+	return result, err
+}
 
-			if e.failFast {
-				return result, nil
-			}
-			continue
+// check runs one assertion. A "context." or "artifact." target is checked
+// as the output of an envelope holding the stored value or file content.
+func (e *DefaultEngine) check(ctx gocontext.Context, envelope agshctx.Envelope, assertion Assertion) AssertionResult {
+	checker := GetChecker(assertion.Type)
+	if checker == nil {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   fmt.Sprintf("unknown assertion type: %q", assertion.Type),
 		}
+	}
+	var (
+		stored agshctx.Envelope
+		err    error
+	)
+	switch {
+	case strings.HasPrefix(assertion.Target, "context."):
+		stored, err = e.contextEnvelope(assertion.Target)
+	case strings.HasPrefix(assertion.Target, "artifact."):
+		stored, err = e.artifactEnvelope(assertion.Target)
+	default:
+		return e.run(ctx, checker, envelope, assertion)
+	}
+	if err != nil {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   fmt.Sprintf("%s: %v", assertion.Type, err),
+		}
+	}
+	target := assertion
+	target.Target = "output"
+	ar := e.run(ctx, checker, stored, target)
+	ar.Assertion = assertion
+	return ar
+}
 
-		ar := checker(envelope, assertion)
-		result.Results = append(result.Results, ar)
+// contextEnvelope wraps the value a "context." target refers to.
+func (e *DefaultEngine) contextEnvelope(target string) (agshctx.Envelope, error) {
+	scope, key, err := agshctx.ParseContextRef(target)
+	if err != nil {
+		return agshctx.Envelope{}, err
+	}
+	if e.store == nil {
+		return agshctx.Envelope{}, fmt.Errorf("no context store to read %s from", target)
+	}
+	value, err := e.store.Get(scope, key)
+	if err != nil {
+		return agshctx.Envelope{}, err
+	}
+	contentType := "application/json"
+	if _, ok := value.(string); ok {
+		contentType = "text/plain"
+	}
+	return agshctx.NewEnvelope(value, contentType, "context"), nil
+}
 
-		if !ar.Passed {
-			result.Passed = false
-			if e.failFast {
-				return result, nil
-			}
-		}
+// artifactEnvelope wraps the content of the file an "artifact." target
+// refers to.
+func (e *DefaultEngine) artifactEnvelope(target string) (agshctx.Envelope, error) {
+	name := strings.TrimPrefix(target, "artifact.")
+	path, ok := e.artifacts[name]
+	if !ok {
+		return agshctx.Envelope{}, fmt.Errorf("no output named %q", name)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("output %s: %w", name, err)
+	}
+	if info.Size() > maxArtifactSize {
+		return agshctx.Envelope{}, fmt.Errorf("output %s is too large to check (%d bytes)", name, info.Size())
 	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return agshctx.Envelope{}, fmt.Errorf("output %s: %w", name, err)
+	}
+	return agshctx.NewEnvelope(string(data), "text/plain", "artifact"), nil
+}
 
-	return result, nil
+// run checks one assertion, handing its checker ctx bounded by the
+// engine's timeout. A checker that has not returned when that context is
+// done is left to finish in the background; its result is dropped.
+func (e *DefaultEngine) run(ctx gocontext.Context, checker ContextChecker, envelope agshctx.Envelope, assertion Assertion) AssertionResult {
+	if e.timeout > 0 {
+		var cancel gocontext.CancelFunc
+		ctx, cancel = gocontext.WithTimeoutCause(ctx, e.timeout, fmt.Errorf("timed out after %s", e.timeout))
+		defer cancel()
+	}
+	if ctx.Done() == nil {
+		return checker(ctx, envelope, assertion)
+	}
+
+	done := make(chan AssertionResult, 1)
+	go func() { done <- checker(ctx, envelope, assertion) }()
+	select {
+	case ar := <-done:
+		return ar
+	case <-ctx.Done():
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   fmt.Sprintf("%s: %v", assertion.Type, gocontext.Cause(ctx)),
+		}
+	}
 }
 
 // VerifyEnvelope is a convenience function that creates a default engine and verifies.
+//
+// Deprecated: Use NewEngine().VerifyContext.
 func VerifyEnvelope(envelope agshctx.Envelope, intent Intent) (VerificationResult, error) {
-	engine := NewEngine()
-	return engine.Verify(envelope, intent)
+	return NewEngine().VerifyContext(gocontext.Background(), envelope, intent)
 }
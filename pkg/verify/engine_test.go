@@ -1,7 +1,14 @@
 package verify
 
 import (
+	gocontext "context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	agshctx "github.com/cgast/agsh/pkg/context"
 )
@@ -87,6 +94,151 @@ func TestEngineFailFast(t *testing.T) {
 	}
 }
 
+// sleepChecker passes after sleeping Expected milliseconds, or fails for
+// a negative value, recording how many checks ran at once.
+type sleepChecker struct {
+	mu            sync.Mutex
+	running, most int
+}
+
+func (c *sleepChecker) check(_ agshctx.Envelope, a Assertion) AssertionResult {
+	c.mu.Lock()
+	c.running++
+	c.most = max(c.most, c.running)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.running--
+		c.mu.Unlock()
+	}()
+	ms := a.Expected.(int)
+	time.Sleep(time.Duration(max(ms, -ms)) * time.Millisecond)
+	return AssertionResult{Assertion: a, Passed: ms >= 0, Actual: ms}
+}
+
+func TestEngineConcurrency(t *testing.T) {
+	sleeper := &sleepChecker{}
+	RegisterChecker("test_sleep", sleeper.check)
+	defer delete(builtinCheckers, "test_sleep")
+
+	intent := Intent{Assertions: []Assertion{
+		{Type: "test_sleep", Expected: 60},
+		{Type: "test_sleep", Expected: 10},
+		{Type: "test_sleep", Expected: 40},
+		{Type: "test_sleep", Expected: 20},
+		{Type: "test_sleep", Expected: 30},
+	}}
+	result, err := NewEngine(WithConcurrency(2)).Verify(agshctx.NewEnvelope("x", "text/plain", "test"), intent)
+	if err != nil || !result.Passed {
+		t.Fatalf("Verify = %+v, %v", result, err)
+	}
+	for i, ar := range result.Results {
+		if ar.Actual != intent.Assertions[i].Expected {
+			t.Errorf("result %d is of assertion %v; results must keep the intent's order", i, ar.Actual)
+		}
+	}
+	if sleeper.most != 2 {
+		t.Errorf("%d assertions ran at once, want 2", sleeper.most)
+	}
+
+	// With fail-fast, the results end at the first failure even when a
+	// later assertion finishes first.
+	intent.Assertions[0].Expected = -60
+	intent.Assertions[2].Expected = -10
+	result, _ = NewEngine(WithFailFast(true)).Verify(agshctx.NewEnvelope("x", "text/plain", "test"), intent)
+	if result.Passed || len(result.Results) != 1 || result.Results[0].Actual != -60 {
+		t.Errorf("fail-fast results = %+v", result.Results)
+	}
+}
+
+func TestEngineAssertionTimeout(t *testing.T) {
+	sleeper := &sleepChecker{}
+	RegisterChecker("test_sleep", sleeper.check)
+	defer delete(builtinCheckers, "test_sleep")
+
+	intent := Intent{Assertions: []Assertion{
+		{Type: "test_sleep", Expected: 500},
+		{Type: "not_empty"},
+	}}
+	start := time.Now()
+	result, _ := NewEngine(WithAssertionTimeout(20*time.Millisecond)).Verify(agshctx.NewEnvelope("x", "text/plain", "test"), intent)
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Verify took %s despite the timeout", elapsed)
+	}
+	if result.Passed || result.Results[0].Passed || result.Results[0].Message != "test_sleep: timed out after 20ms" {
+		t.Errorf("results = %+v", result.Results)
+	}
+	if !result.Results[1].Passed {
+		t.Error("the other assertion should still pass")
+	}
+}
+
+func TestEngineVerifyContext(t *testing.T) {
+	env := agshctx.NewEnvelope("hello world", "text/plain", "test")
+	intent := Intent{Assertions: []Assertion{
+		{Type: "contains", Target: "output", Expected: "hello"},
+		{Type: "contains", Target: "output", Expected: "goodbye"},
+	}}
+	result, err := NewEngine().VerifyContext(gocontext.Background(), env, intent)
+	if err != nil {
+		t.Fatalf("VerifyContext: %v", err)
+	}
+	if result.Passed || len(result.Results) != 2 || !result.Results[0].Passed || result.Results[1].Passed {
+		t.Errorf("result = %+v", result)
+	}
+
+	// A context cancelled up front checks nothing.
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	result, err = NewEngine().VerifyContext(ctx, env, intent)
+	if !errors.Is(err, gocontext.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	for _, ar := range result.Results {
+		if ar.Passed {
+			t.Errorf("assertion passed after cancellation: %+v", ar)
+		}
+	}
+}
+
+func TestEngineVerifyContextCancel(t *testing.T) {
+	stopped := make(chan struct{}, 1)
+	RegisterContextChecker("test_block", func(ctx gocontext.Context, _ agshctx.Envelope, a Assertion) AssertionResult {
+		<-ctx.Done()
+		stopped <- struct{}{}
+		return AssertionResult{Assertion: a, Passed: false, Message: "test_block: " + ctx.Err().Error()}
+	})
+	defer delete(builtinCheckers, "test_block")
+
+	intent := Intent{Assertions: []Assertion{
+		{Type: "test_block"},
+		{Type: "not_empty"},
+		{Type: "not_empty"},
+	}}
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	result, err := NewEngine(WithConcurrency(1)).VerifyContext(ctx, agshctx.NewEnvelope("x", "text/plain", "test"), intent)
+	if !errors.Is(err, gocontext.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("the checker was not cancelled")
+	}
+	if result.Passed || len(result.Results) != 3 {
+		t.Fatalf("result = %+v", result)
+	}
+	if msg := result.Results[0].Message; msg != "test_block: context canceled" {
+		t.Errorf("running assertion: %q", msg)
+	}
+	for _, ar := range result.Results[1:] {
+		if ar.Passed || ar.Message != "not_empty: not checked: context canceled" {
+			t.Errorf("unstarted assertion: %+v", ar)
+		}
+	}
+}
+
 func TestEngineUnknownAssertionType(t *testing.T) {
 	env := agshctx.NewEnvelope("hello", "text/plain", "test")
 	intent := Intent{
@@ -175,3 +327,99 @@ func TestEngineMultipleAssertionTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestVerificationResultDetails(t *testing.T) {
+	result := VerificationResult{Results: []AssertionResult{
+		{Assertion: Assertion{Type: "count_gte", Target: "output.lines", Expected: 3}, Passed: true, Actual: 5},
+		{Assertion: Assertion{Type: "contains", Target: "output", Expected: "xyz"}, Actual: strings.Repeat("a", 500), Message: "output does not contain xyz"},
+		{Assertion: Assertion{Type: "not_empty"}, Actual: []string{"a", "b"}},
+	}}
+
+	details := result.Details()
+	if len(details) != 3 {
+		t.Fatalf("details = %d, want 3", len(details))
+	}
+	if d := details[0]; d.Type != "count_gte" || d.Target != "output.lines" || d.Expected != 3 || d.Actual != 5 || !d.Passed {
+		t.Errorf("details[0] = %+v", d)
+	}
+	if d := details[1]; d.Passed || d.Message != "output does not contain xyz" || d.Actual != strings.Repeat("a", maxDetailActual)+"..." {
+		t.Errorf("details[1] = %+v", d)
+	}
+	if d := details[2]; d.Actual != "[a b]" {
+		t.Errorf("details[2].Actual = %#v, want the value as text", d.Actual)
+	}
+}
+
+func TestEngineContextTarget(t *testing.T) {
+	store, err := agshctx.NewBoltStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer store.Close()
+	store.Set(agshctx.ScopeSession, "total_headings", 10)
+	store.Set(agshctx.ScopeSession, "files", []string{"a.md", "b.md"})
+	store.Set(agshctx.ScopeProject, "status", "all done")
+
+	intent := Intent{Assertions: []Assertion{
+		{Type: "count_gte", Target: "context.session.total_headings", Expected: 5},
+		{Type: "count_gte", Target: "context.session.files", Expected: 2},
+		{Type: "contains", Target: "context.project.status", Expected: "done"},
+		{Type: "count_gte", Target: "context.session.total_headings", Expected: 11},
+		{Type: "not_empty", Target: "context.session.missing"},
+		{Type: "not_empty", Target: "context.nowhere.x"},
+	}}
+	env := agshctx.NewEnvelope("unrelated output", "text/plain", "test")
+	result, err := NewEngine(WithContextStore(store)).VerifyContext(gocontext.Background(), env, intent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, true, true, false, false, false} {
+		ar := result.Results[i]
+		if ar.Passed != want {
+			t.Errorf("%s %v: passed = %v (%s)", ar.Assertion.Target, ar.Assertion.Expected, ar.Passed, ar.Message)
+		}
+		if ar.Assertion.Target != intent.Assertions[i].Target {
+			t.Errorf("result %d reports target %q", i, ar.Assertion.Target)
+		}
+	}
+	if result.Results[3].Actual != 10 {
+		t.Errorf("actual = %v, want the stored count", result.Results[3].Actual)
+	}
+
+	// Without a store, context targets fail rather than check the output.
+	result, _ = NewEngine().VerifyContext(gocontext.Background(), env, Intent{Assertions: intent.Assertions[:1]})
+	if result.Passed || !strings.Contains(result.Results[0].Message, "no context store") {
+		t.Errorf("without a store: %+v", result.Results[0])
+	}
+}
+
+func TestEngineArtifactTarget(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.md")
+	if err := os.WriteFile(report, []byte("# Weekly report\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(WithArtifacts(map[string]string{
+		"report": report,
+		"data":   filepath.Join(dir, "missing.json"),
+	}))
+
+	intent := Intent{Assertions: []Assertion{
+		{Type: "contains", Target: "artifact.report", Expected: "Weekly report"},
+		{Type: "not_empty", Target: "artifact.data"},
+		{Type: "not_empty", Target: "artifact.other"},
+	}}
+	env := agshctx.NewEnvelope("unrelated output", "text/plain", "test")
+	result, err := engine.VerifyContext(gocontext.Background(), env, intent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false, false} {
+		if ar := result.Results[i]; ar.Passed != want {
+			t.Errorf("%s: passed = %v (%s)", ar.Assertion.Target, ar.Passed, ar.Message)
+		}
+	}
+	if msg := result.Results[2].Message; !strings.Contains(msg, `no output named "other"`) {
+		t.Errorf("message = %q", msg)
+	}
+}
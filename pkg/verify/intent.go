@@ -1,6 +1,9 @@
 package verify
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Intent declares what a command or pipeline step is supposed to achieve.
 type Intent struct {
@@ -10,8 +13,8 @@ type Intent struct {
 
 // Assertion defines a machine-checkable condition.
 type Assertion struct {
-	Type     string `json:"type"`     // "not_empty", "contains", "not_contains", "count_gte", "matches_regex", "json_schema", "llm_judge"
-	Target   string `json:"target"`   // what to check: "output", "output.lines", "meta.tags.y"
+	Type     string `json:"type"`     // "not_empty", "contains", "not_contains", "count_gte", "matches_regex", "json_schema", "hash_equals", "duration_lte", "llm_judge"
+	Target   string `json:"target"`   // what to check: "output", "output.lines", "meta.tags.y", "meta.duration", "pipeline.duration", "context.session.x", "artifact.report"
 	Expected any    `json:"expected"` // the expected value/pattern
 	Message  string `json:"message"`  // human-readable failure description
 }
@@ -29,4 +32,63 @@ type AssertionResult struct {
 	Passed    bool      `json:"passed"`
 	Actual    any       `json:"actual"`
 	Message   string    `json:"message"`
+	Usage     *Usage    `json:"usage,omitempty"` // LLM spend, for llm_judge
+}
+
+// Usage totals the LLM spend of the assertions.
+func (r VerificationResult) Usage() Usage {
+	var total Usage
+	for _, ar := range r.Results {
+		if ar.Usage != nil {
+			total = total.Add(*ar.Usage)
+		}
+	}
+	return total
+}
+
+// maxDetailActual bounds the actual values in AssertionDetails, which go
+// into events.
+const maxDetailActual = 200
+
+// AssertionDetail is the outcome of one assertion as reported in a
+// verify.result event.
+type AssertionDetail struct {
+	Type     string `json:"type"`
+	Target   string `json:"target,omitempty"`
+	Expected any    `json:"expected,omitempty"`
+	Actual   any    `json:"actual,omitempty"` // cut to 200 characters
+	Passed   bool   `json:"passed"`
+	Message  string `json:"message,omitempty"`
+	Usage    *Usage `json:"usage,omitempty"`
+}
+
+// Details returns the outcome of each assertion, in order, for a
+// verify.result event.
+func (r VerificationResult) Details() []AssertionDetail {
+	details := make([]AssertionDetail, len(r.Results))
+	for i, ar := range r.Results {
+		details[i] = AssertionDetail{
+			Type:     ar.Assertion.Type,
+			Target:   ar.Assertion.Target,
+			Expected: ar.Assertion.Expected,
+			Actual:   detailValue(ar.Actual),
+			Passed:   ar.Passed,
+			Message:  ar.Message,
+			Usage:    ar.Usage,
+		}
+	}
+	return details
+}
+
+// detailValue keeps numbers and booleans and cuts anything else to
+// maxDetailActual characters.
+func detailValue(v any) any {
+	switch x := v.(type) {
+	case nil, bool, int, int64, float64:
+		return v
+	case string:
+		return truncate(x, maxDetailActual)
+	default:
+		return truncate(fmt.Sprint(x), maxDetailActual)
+	}
 }
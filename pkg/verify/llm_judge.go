@@ -1,40 +1,181 @@
 package verify
 
 import (
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"sync/atomic"
 
 	agshctx "github.com/cgast/agsh/pkg/context"
 )
 
-// LLMJudgeEndpoint is the URL for the LLM judge service.
-// When empty, llm_judge assertions are skipped with a pass.
-var LLMJudgeEndpoint string
+// Judge decides whether output satisfies criteria written in prose, for
+// llm_judge assertions.
+type Judge interface {
+	Judge(ctx gocontext.Context, criteria, output string) (Verdict, error)
+}
+
+// Verdict is a judge's decision and what it cost.
+type Verdict struct {
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason,omitempty"`
+	Usage  Usage  `json:"usage"`
+}
+
+// Usage is the LLM spend of an assertion or of a whole verification.
+type Usage struct {
+	Model            string `json:"model,omitempty"`
+	Calls            int    `json:"calls"`            // requests made to the model
+	Cached           int    `json:"cached,omitempty"` // verdicts taken from the cache instead
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// Tokens is the total of prompt and completion tokens.
+func (u Usage) Tokens() int { return u.PromptTokens + u.CompletionTokens }
+
+// Add returns the sum of u and o. The model is kept while both agree.
+func (u Usage) Add(o Usage) Usage {
+	model := u.Model
+	if model == "" {
+		model = o.Model
+	} else if o.Model != "" && o.Model != model {
+		model = "mixed"
+	}
+	return Usage{
+		Model:            model,
+		Calls:            u.Calls + o.Calls,
+		Cached:           u.Cached + o.Cached,
+		PromptTokens:     u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+	}
+}
+
+var llmJudge atomic.Pointer[Judge]
+
+// SetLLMJudge sets the judge of llm_judge assertions. With none, they are
+// skipped with a pass.
+func SetLLMJudge(j Judge) {
+	if j == nil {
+		llmJudge.Store(nil)
+		return
+	}
+	llmJudge.Store(&j)
+}
+
+func currentJudge() Judge {
+	if j := llmJudge.Load(); j != nil {
+		return *j
+	}
+	return nil
+}
 
 func init() {
-	RegisterChecker("llm_judge", checkLLMJudge)
+	RegisterContextChecker("llm_judge", checkLLMJudge)
 }
 
-// checkLLMJudge evaluates output against the intent description using an LLM.
-// When no endpoint is configured, it returns a pass with a skip message.
-func checkLLMJudge(envelope agshctx.Envelope, assertion Assertion) AssertionResult {
-	if LLMJudgeEndpoint == "" {
+// checkLLMJudge asks the judge whether the target satisfies the expected
+// criteria (or, without any, the assertion's message). When no judge is
+// configured, it returns a pass with a skip message. The judge's call is
+// abandoned once ctx is done.
+func checkLLMJudge(ctx gocontext.Context, envelope agshctx.Envelope, assertion Assertion) AssertionResult {
+	judge := currentJudge()
+	if judge == nil {
 		return AssertionResult{
 			Assertion: assertion,
 			Passed:    true,
-			Message:   "llm_judge: skipped (no endpoint configured)",
+			Message:   "llm_judge: skipped (no judge configured)",
+		}
+	}
+
+	criteria := fmt.Sprint(assertion.Expected)
+	if assertion.Expected == nil {
+		criteria = assertion.Message
+	}
+	if criteria == "" {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   "llm_judge: no criteria (set expected)",
 		}
 	}
 
-	// When an endpoint is configured, this would:
-	// 1. Send the intent description + output to the LLM
-	// 2. Ask "does this output satisfy the intent?"
-	// 3. Parse the LLM's yes/no response
-	//
-	// For now, return a placeholder that indicates the endpoint exists
-	// but the full implementation is not yet wired.
+	v, err := judge.Judge(ctx, criteria, resolveTarget(envelope, assertion.Target))
+	if err != nil {
+		return AssertionResult{
+			Assertion: assertion,
+			Passed:    false,
+			Message:   fmt.Sprintf("llm_judge: %v", err),
+			Usage:     &v.Usage,
+		}
+	}
+	msg := v.Reason
+	if !v.Passed && assertion.Message != "" {
+		msg = assertion.Message + ": " + v.Reason
+	}
 	return AssertionResult{
 		Assertion: assertion,
-		Passed:    false,
-		Message:   fmt.Sprintf("llm_judge: endpoint %q configured but not yet implemented", LLMJudgeEndpoint),
+		Passed:    v.Passed,
+		Actual:    v.Reason,
+		Message:   msg,
+		Usage:     &v.Usage,
+	}
+}
+
+// CachedJudge remembers the verdicts of Next in the project scope of
+// Store, keyed by a hash of the criteria and the output, so verifying the
+// same output against the same criteria again costs nothing.
+type CachedJudge struct {
+	Next  Judge
+	Store agshctx.ContextStore
+}
+
+// JudgeCacheKey returns the project-scope key a verdict is cached under.
+func JudgeCacheKey(criteria, output string) string {
+	h := sha256.New()
+	h.Write([]byte(criteria))
+	h.Write([]byte{0})
+	h.Write([]byte(output))
+	return "llm_judge." + hex.EncodeToString(h.Sum(nil))
+}
+
+// Judge returns the cached verdict, or asks Next and caches its answer.
+// A cached verdict reports no calls or tokens, only Cached: 1.
+func (c CachedJudge) Judge(ctx gocontext.Context, criteria, output string) (Verdict, error) {
+	key := JudgeCacheKey(criteria, output)
+	if raw, err := c.Store.Get(agshctx.ScopeProject, key); err == nil {
+		if v, ok := decodeVerdict(raw); ok {
+			return Verdict{Passed: v.Passed, Reason: v.Reason, Usage: Usage{Model: v.Usage.Model, Cached: 1}}, nil
+		}
+	}
+	v, err := c.Next.Judge(ctx, criteria, output)
+	if err != nil {
+		return v, err
+	}
+	c.Store.Set(agshctx.ScopeProject, key, map[string]any{
+		"passed": v.Passed,
+		"reason": v.Reason,
+		"usage":  map[string]any{"model": v.Usage.Model},
+	})
+	return v, nil
+}
+
+// decodeVerdict reads a cached verdict, which the store may hand back as
+// a generic map.
+func decodeVerdict(raw any) (Verdict, bool) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return Verdict{}, false
+	}
+	var v struct {
+		Passed *bool  `json:"passed"`
+		Reason string `json:"reason"`
+		Usage  Usage  `json:"usage"`
+	}
+	if json.Unmarshal(data, &v) != nil || v.Passed == nil {
+		return Verdict{}, false
 	}
+	return Verdict{Passed: *v.Passed, Reason: v.Reason, Usage: v.Usage}, true
 }
@@ -1,16 +1,31 @@
 package verify
 
 import (
+	gocontext "context"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	agshctx "github.com/cgast/agsh/pkg/context"
 )
 
-func TestLLMJudgeSkippedWhenNoEndpoint(t *testing.T) {
-	// Ensure no endpoint is configured.
-	oldEndpoint := LLMJudgeEndpoint
-	LLMJudgeEndpoint = ""
-	defer func() { LLMJudgeEndpoint = oldEndpoint }()
+// fakeJudge passes output containing "ok", charging 10 prompt and 2
+// completion tokens per call.
+type fakeJudge struct {
+	calls int
+}
+
+func (j *fakeJudge) Judge(_ gocontext.Context, criteria, output string) (Verdict, error) {
+	j.calls++
+	usage := Usage{Model: "judge-test", Calls: 1, PromptTokens: 10, CompletionTokens: 2}
+	if strings.Contains(output, "ok") {
+		return Verdict{Passed: true, Reason: "meets " + criteria, Usage: usage}, nil
+	}
+	return Verdict{Passed: false, Reason: "does not meet " + criteria, Usage: usage}, nil
+}
+
+func TestLLMJudgeSkippedWhenNoJudge(t *testing.T) {
+	SetLLMJudge(nil)
 
 	env := agshctx.NewEnvelope("some output", "text/plain", "test")
 	assertion := Assertion{
@@ -19,7 +34,7 @@ func TestLLMJudgeSkippedWhenNoEndpoint(t *testing.T) {
 		Message: "output should be meaningful",
 	}
 
-	r := checkLLMJudge(env, assertion)
+	r := checkLLMJudge(gocontext.Background(), env, assertion)
 	if !r.Passed {
 		t.Error("llm_judge should pass (skip) when no endpoint configured")
 	}
@@ -35,28 +50,67 @@ func TestLLMJudgeRegistered(t *testing.T) {
 	}
 }
 
-func TestLLMJudgeWithEndpoint(t *testing.T) {
-	oldEndpoint := LLMJudgeEndpoint
-	LLMJudgeEndpoint = "http://localhost:8080/judge"
-	defer func() { LLMJudgeEndpoint = oldEndpoint }()
+func TestLLMJudgeWithJudge(t *testing.T) {
+	judge := &fakeJudge{}
+	SetLLMJudge(judge)
+	defer SetLLMJudge(nil)
 
-	env := agshctx.NewEnvelope("some output", "text/plain", "test")
-	assertion := Assertion{
-		Type:   "llm_judge",
-		Target: "output",
+	r := checkLLMJudge(gocontext.Background(), agshctx.NewEnvelope("all ok", "text/plain", "test"), Assertion{Type: "llm_judge", Expected: "a status report"})
+	if !r.Passed || r.Message != "meets a status report" {
+		t.Errorf("result = %+v", r)
+	}
+	if r.Usage == nil || r.Usage.Tokens() != 12 || r.Usage.Calls != 1 {
+		t.Errorf("usage = %+v", r.Usage)
+	}
+
+	r = checkLLMJudge(gocontext.Background(), agshctx.NewEnvelope("failed", "text/plain", "test"), Assertion{Type: "llm_judge", Expected: "a status report", Message: "report is off"})
+	if r.Passed || r.Message != "report is off: does not meet a status report" {
+		t.Errorf("result = %+v", r)
+	}
+
+	if r := checkLLMJudge(gocontext.Background(), agshctx.NewEnvelope("all ok", "text/plain", "test"), Assertion{Type: "llm_judge"}); r.Passed || judge.calls != 2 {
+		t.Errorf("without criteria: %+v after %d calls", r, judge.calls)
+	}
+}
+
+func TestCachedJudge(t *testing.T) {
+	store, err := agshctx.NewBoltStore(filepath.Join(t.TempDir(), "ctx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	next := &fakeJudge{}
+	SetLLMJudge(CachedJudge{Next: next, Store: store})
+	defer SetLLMJudge(nil)
+
+	intent := Intent{Assertions: []Assertion{
+		{Type: "llm_judge", Expected: "a status report"},
+		{Type: "llm_judge", Expected: "a status report"},
+		{Type: "llm_judge", Expected: "a haiku"},
+	}}
+	result, err := NewEngine().VerifyContext(gocontext.Background(), agshctx.NewEnvelope("all ok", "text/plain", "test"), intent)
+	if err != nil || !result.Passed {
+		t.Fatalf("Verify = %+v, %v", result, err)
+	}
+	if next.calls != 2 {
+		t.Errorf("judge called %d times, want 2 (one per criteria)", next.calls)
+	}
+	want := Usage{Model: "judge-test", Calls: 2, Cached: 1, PromptTokens: 20, CompletionTokens: 4}
+	if got := result.Usage(); got != want {
+		t.Errorf("Usage = %+v, want %+v", got, want)
+	}
+	if _, err := store.Get(agshctx.ScopeProject, JudgeCacheKey("a haiku", "all ok")); err != nil {
+		t.Errorf("verdict not cached: %v", err)
 	}
 
-	r := checkLLMJudge(env, assertion)
-	// With endpoint configured but no real service, should fail gracefully.
-	if r.Passed {
-		t.Error("llm_judge should fail when endpoint is set but not implemented")
+	// Other output is judged afresh.
+	if r := checkLLMJudge(gocontext.Background(), agshctx.NewEnvelope("failed", "text/plain", "test"), intent.Assertions[0]); r.Passed || next.calls != 3 {
+		t.Errorf("result = %+v after %d calls", r, next.calls)
 	}
 }
 
 func TestLLMJudgeViaEngine(t *testing.T) {
-	oldEndpoint := LLMJudgeEndpoint
-	LLMJudgeEndpoint = ""
-	defer func() { LLMJudgeEndpoint = oldEndpoint }()
+	SetLLMJudge(nil)
 
 	env := agshctx.NewEnvelope("hello", "text/plain", "test")
 	intent := Intent{
@@ -68,7 +122,7 @@ func TestLLMJudgeViaEngine(t *testing.T) {
 	}
 
 	engine := NewEngine()
-	result, err := engine.Verify(env, intent)
+	result, err := engine.VerifyContext(gocontext.Background(), env, intent)
 	if err != nil {
 		t.Fatalf("Verify: %v", err)
 	}